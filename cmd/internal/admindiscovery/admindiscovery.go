@@ -0,0 +1,122 @@
+// Package admindiscovery holds the flags choosing how sshpiperd-admin and
+// sshpiperd-webadmin discover the sshpiperd admin endpoints, besides the
+// --sshpiperd list.
+package admindiscovery
+
+import (
+	"fmt"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"github.com/tg123/sshpiper/libadmin/kubediscovery"
+	"github.com/urfave/cli/v2"
+)
+
+// Flags returns the discovery flags, with env vars prefixed by envPrefix,
+// e.g. SSHPIPERD_WEBADMIN_, and hidden from --help when hidden is true.
+func Flags(envPrefix string, hidden bool) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "discovery-dns",
+			Usage:   "host:port whose A/AAAA records are sshpiperd admin endpoints on port, e.g. a headless Kubernetes service. Repeat for multiple names",
+			EnvVars: []string{envPrefix + "DISCOVERY_DNS"},
+			Hidden:  hidden,
+		},
+		&cli.StringSliceFlag{
+			Name:    "discovery-srv",
+			Usage:   "DNS name whose SRV records are sshpiperd admin endpoints, e.g. _admin._tcp.sshpiperd.default.svc.cluster.local. Repeat for multiple names",
+			EnvVars: []string{envPrefix + "DISCOVERY_SRV"},
+			Hidden:  hidden,
+		},
+		&cli.StringFlag{
+			Name:    "discovery-file",
+			Usage:   "file listing sshpiperd admin endpoints, one host:port per line, watched for changes",
+			EnvVars: []string{envPrefix + "DISCOVERY_FILE"},
+			Hidden:  hidden,
+		},
+		&cli.StringFlag{
+			Name:    "discovery-kubernetes-selector",
+			Usage:   "label selector of the Kubernetes EndpointSlices of the sshpiperd pods, e.g. app=sshpiperd or kubernetes.io/service-name=sshpiperd",
+			EnvVars: []string{envPrefix + "DISCOVERY_KUBERNETES_SELECTOR"},
+			Hidden:  hidden,
+		},
+		&cli.StringFlag{
+			Name:    "discovery-kubernetes-namespace",
+			Usage:   "namespace of the EndpointSlices of --discovery-kubernetes-selector (default: the one of the kubeconfig)",
+			EnvVars: []string{envPrefix + "DISCOVERY_KUBERNETES_NAMESPACE"},
+			Hidden:  hidden,
+		},
+		&cli.StringFlag{
+			Name:    "discovery-kubernetes-port",
+			Usage:   "name or number of the admin gRPC port in the EndpointSlices, may be omitted when they have a single port",
+			EnvVars: []string{envPrefix + "DISCOVERY_KUBERNETES_PORT"},
+			Hidden:  hidden,
+		},
+		&cli.StringFlag{
+			Name:    "kubeconfig",
+			Usage:   "path to the kubeconfig of --discovery-kubernetes-selector (default: the in-cluster config)",
+			EnvVars: []string{envPrefix + "KUBECONFIG"},
+			Hidden:  hidden,
+		},
+	}
+}
+
+// New returns the Discovery of the static endpoints and the discovery flags
+// of ctx, nil when none is set.
+func New(ctx *cli.Context, static []string) (libadmin.Discovery, error) {
+	var m libadmin.MultiDiscovery
+	if len(static) > 0 {
+		m = append(m, libadmin.NewStaticDiscovery(static))
+	}
+	for _, hostport := range ctx.StringSlice("discovery-dns") {
+		d, err := libadmin.NewDNSDiscovery(hostport)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, d)
+	}
+	for _, name := range ctx.StringSlice("discovery-srv") {
+		m = append(m, libadmin.NewSRVDiscovery(name))
+	}
+	if path := ctx.String("discovery-file"); path != "" {
+		m = append(m, libadmin.NewFileDiscovery(path))
+	}
+	if selector := ctx.String("discovery-kubernetes-selector"); selector != "" {
+		d, err := kubediscovery.NewFromKubeconfig(
+			ctx.String("kubeconfig"),
+			ctx.String("discovery-kubernetes-namespace"),
+			selector,
+			ctx.String("discovery-kubernetes-port"),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes discovery: %w", err)
+		}
+		m = append(m, d)
+	}
+	switch len(m) {
+	case 0:
+		return nil, nil
+	case 1:
+		return m[0], nil
+	}
+	return m, nil
+}
+
+// Args returns the discovery flags explicitly set in ctx as arguments, see
+// inheritedGlobalArgs of sshpiperd-admin.
+func Args(ctx *cli.Context) []string {
+	var out []string
+	for _, f := range Flags("", true) {
+		name := f.Names()[0]
+		if !ctx.IsSet(name) {
+			continue
+		}
+		if _, ok := f.(*cli.StringSliceFlag); ok {
+			for _, v := range ctx.StringSlice(name) {
+				out = append(out, "--"+name, v)
+			}
+			continue
+		}
+		out = append(out, "--"+name, ctx.String(name))
+	}
+	return out
+}
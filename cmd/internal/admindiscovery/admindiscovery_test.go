@@ -0,0 +1,70 @@
+package admindiscovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"github.com/urfave/cli/v2"
+)
+
+// run parses args with the discovery flags and calls New with static.
+func run(t *testing.T, static []string, args ...string) (libadmin.Discovery, error) {
+	t.Helper()
+	var (
+		d   libadmin.Discovery
+		err error
+	)
+	app := &cli.App{
+		Flags: Flags("TEST_", false),
+		Action: func(ctx *cli.Context) error {
+			d, err = New(ctx, static)
+			return nil
+		},
+	}
+	if rerr := app.Run(append([]string{"test"}, args...)); rerr != nil {
+		t.Fatal(rerr)
+	}
+	return d, err
+}
+
+func TestNew(t *testing.T) {
+	if d, err := run(t, nil); d != nil || err != nil {
+		t.Fatalf("no flags: got %v, %v", d, err)
+	}
+
+	d, err := run(t, []string{"a:2222"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.(*libadmin.StaticDiscovery); !ok {
+		t.Fatalf("static only: got %T", d)
+	}
+
+	path := filepath.Join(t.TempDir(), "endpoints")
+	if err := os.WriteFile(path, []byte("b:2222\na:2222\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d, err = run(t, []string{"a:2222"}, "--discovery-file", path, "--discovery-dns", "sshpiperd:2222", "--discovery-srv", "_admin._tcp.sshpiperd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := d.(libadmin.MultiDiscovery)
+	if !ok || len(m) != 4 {
+		t.Fatalf("all flags: got %#v", d)
+	}
+	got, err := libadmin.MultiDiscovery{m[0], m[3]}.Endpoints(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a:2222", "b:2222"}; !slices.Equal(got, want) {
+		t.Fatalf("endpoints = %v, want %v", got, want)
+	}
+
+	if _, err := run(t, nil, "--discovery-dns", "sshpiperd"); err == nil {
+		t.Fatal("expected an error for a --discovery-dns without port")
+	}
+}
@@ -7,6 +7,7 @@
 //	sshpiperd-admin --sshpiperd 127.0.0.1:8082 list
 //	sshpiperd-admin --sshpiperd 127.0.0.1:8082 kill <session-id>
 //	sshpiperd-admin --sshpiperd 127.0.0.1:8082 stream <session-id>
+//	sshpiperd-admin --sshpiperd 127.0.0.1:8082 attach <session-id>
 //
 // Multiple --sshpiperd endpoints may be provided; in that case session ids
 // are routed to the correct backend either automatically (when the id is
@@ -14,21 +15,28 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"os"
 	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/tg123/sshpiper/cmd/internal/admindiscovery"
 	"github.com/tg123/sshpiper/cmd/internal/slogutil"
 	"github.com/tg123/sshpiper/libadmin"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
 )
 
 var mainver = "(devel)"
@@ -52,7 +60,7 @@ func version() string {
 // remote SSH sessions where these knobs are baked into the server
 // invocation and cannot be changed by the operator.
 func globalFlags(hidden bool) []cli.Flag {
-	return []cli.Flag{
+	flags := []cli.Flag{
 		&cli.StringSliceFlag{
 			Name:    "sshpiperd",
 			Usage:   "address of a sshpiperd admin gRPC endpoint (host:port). Repeat for multiple instances",
@@ -105,6 +113,7 @@ func globalFlags(hidden bool) []cli.Flag {
 			Hidden:  hidden,
 		},
 	}
+	return append(flags, admindiscovery.Flags("SSHPIPERD_ADMIN_", hidden)...)
 }
 
 // newApp builds the top-level urfave/cli App. It is invoked both by main()
@@ -119,11 +128,22 @@ func globalFlags(hidden bool) []cli.Flag {
 func newApp(includeServe bool) *cli.App {
 	commands := []*cli.Command{
 		listCommand(),
+		showCommand(),
+		historyCommand(),
+		recordingsCommand(),
 		killCommand(),
+		messageCommand(),
+		pauseCommand(),
+		resumeCommand(),
 		streamCommand(),
+		playCommand(),
+		reloadHostKeysCommand(),
+		pluginsCommand(),
 	}
 	if includeServe {
-		commands = append(commands, serveCommand())
+		// attach types from the local terminal, it cannot run over serve,
+		// and verify-audit reads a local file
+		commands = append(commands, attachCommand(), verifyAuditCommand(), serveCommand())
 	}
 	return &cli.App{
 		Name:        "sshpiperd-admin",
@@ -152,9 +172,10 @@ func main() {
 	}
 }
 
-// resolveEndpoints returns the configured admin endpoint list, falling back
-// to a comma-separated env var for parity with sshpiperd-webadmin.
-func resolveEndpoints(ctx *cli.Context) ([]string, error) {
+// resolveDiscovery returns the Discovery of the configured admin endpoint
+// list, falling back to a comma-separated env var for parity with
+// sshpiperd-webadmin, and of the --discovery-* flags.
+func resolveDiscovery(ctx *cli.Context) (libadmin.Discovery, error) {
 	endpoints := ctx.StringSlice("sshpiperd")
 	if len(endpoints) == 0 {
 		if env := os.Getenv("SSHPIPERD_ADMIN_ENDPOINTS"); env != "" {
@@ -165,10 +186,14 @@ func resolveEndpoints(ctx *cli.Context) ([]string, error) {
 			}
 		}
 	}
-	if len(endpoints) == 0 {
-		return nil, fmt.Errorf("no sshpiperd endpoints configured: pass --sshpiperd <addr> at least once or set SSHPIPERD_ADMIN_ENDPOINTS")
+	discovery, err := admindiscovery.New(ctx, endpoints)
+	if err != nil {
+		return nil, err
 	}
-	return endpoints, nil
+	if discovery == nil {
+		return nil, fmt.Errorf("no sshpiperd endpoints configured: pass --sshpiperd <addr> at least once, set SSHPIPERD_ADMIN_ENDPOINTS or use a --discovery-* flag")
+	}
+	return discovery, nil
 }
 
 func dialOpts(ctx *cli.Context) libadmin.DialOptions {
@@ -184,11 +209,11 @@ func dialOpts(ctx *cli.Context) libadmin.DialOptions {
 // newAggregator dials every configured endpoint and refreshes ServerInfo.
 // The caller owns the returned Aggregator and must Close it.
 func newAggregator(ctx *cli.Context) (*libadmin.Aggregator, error) {
-	endpoints, err := resolveEndpoints(ctx)
+	discovery, err := resolveDiscovery(ctx)
 	if err != nil {
 		return nil, err
 	}
-	agg := libadmin.NewAggregator(libadmin.NewStaticDiscovery(endpoints), dialOpts(ctx))
+	agg := libadmin.NewAggregator(discovery, dialOpts(ctx))
 
 	rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
 	defer cancel()
@@ -216,8 +241,17 @@ func listCommand() *cli.Command {
 				Name:  "json",
 				Usage: "emit JSON instead of a human-readable table",
 			},
+			&cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "only list sessions with this label, as key=value. Repeat to require several",
+			},
 		},
 		Action: func(ctx *cli.Context) error {
+			selector, err := libadmin.ParseLabelSelector(ctx.StringSlice("label"))
+			if err != nil {
+				return err
+			}
+
 			agg, err := newAggregator(ctx)
 			if err != nil {
 				return err
@@ -230,20 +264,28 @@ func listCommand() *cli.Command {
 			for _, e := range errs {
 				slog.Warn("list failed", "error", e)
 			}
+			sessions = slices.DeleteFunc(sessions, func(s libadmin.AggregatedSession) bool {
+				return !libadmin.MatchLabels(s.Session.GetLabels(), selector)
+			})
 
 			if ctx.Bool("json") {
 				out := make([]map[string]any, 0, len(sessions))
 				for _, s := range sessions {
 					out = append(out, map[string]any{
-						"instance_id":     s.InstanceID,
-						"instance_addr":   s.InstanceAddr,
-						"id":              s.Session.GetId(),
-						"downstream_user": s.Session.GetDownstreamUser(),
-						"downstream_addr": s.Session.GetDownstreamAddr(),
-						"upstream_user":   s.Session.GetUpstreamUser(),
-						"upstream_addr":   s.Session.GetUpstreamAddr(),
-						"started_at":      s.Session.GetStartedAt(),
-						"streamable":      s.Session.GetStreamable(),
+						"instance_id":      s.InstanceID,
+						"instance_addr":    s.InstanceAddr,
+						"id":               s.Session.GetId(),
+						"downstream_user":  s.Session.GetDownstreamUser(),
+						"downstream_addr":  s.Session.GetDownstreamAddr(),
+						"upstream_user":    s.Session.GetUpstreamUser(),
+						"upstream_addr":    s.Session.GetUpstreamAddr(),
+						"started_at":       s.Session.GetStartedAt(),
+						"streamable":       s.Session.GetStreamable(),
+						"paused":           s.Session.GetPaused(),
+						"channels":         s.Session.GetChannels(),
+						"downstream_bytes": s.Session.GetDownstreamBytes(),
+						"upstream_bytes":   s.Session.GetUpstreamBytes(),
+						"labels":           s.Session.GetLabels(),
 					})
 				}
 				enc := json.NewEncoder(ctx.App.Writer)
@@ -252,17 +294,19 @@ func listCommand() *cli.Command {
 			}
 
 			tw := tabwriter.NewWriter(ctx.App.Writer, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(tw, "INSTANCE\tSESSION ID\tDOWNSTREAM\tUPSTREAM\tSTARTED\tSTREAMABLE")
+			fmt.Fprintln(tw, "INSTANCE\tSESSION ID\tDOWNSTREAM\tUPSTREAM\tSTARTED\tSTREAMABLE\tPAUSED\tLABELS")
 			for _, s := range sessions {
 				started := time.Unix(s.Session.GetStartedAt(), 0).UTC().Format(time.RFC3339)
 				fmt.Fprintf(
-					tw, "%s\t%s\t%s@%s\t%s@%s\t%s\t%v\n",
+					tw, "%s\t%s\t%s@%s\t%s@%s\t%s\t%v\t%v\t%s\n",
 					s.InstanceID,
 					s.Session.GetId(),
 					s.Session.GetDownstreamUser(), s.Session.GetDownstreamAddr(),
 					s.Session.GetUpstreamUser(), s.Session.GetUpstreamAddr(),
 					started,
 					s.Session.GetStreamable(),
+					s.Session.GetPaused(),
+					libadmin.FormatLabels(s.Session.GetLabels()),
 				)
 			}
 			return tw.Flush()
@@ -270,6 +314,339 @@ func listCommand() *cli.Command {
 	}
 }
 
+func showCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "show",
+		Usage:     "show an active session with its channels, algorithms and versions",
+		ArgsUsage: "<session-id>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "instance",
+				Usage: "id of the sshpiperd instance hosting the session (auto-detected when omitted)",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "emit JSON instead of human-readable text",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() != 1 {
+				return fmt.Errorf("expected exactly one <session-id> argument")
+			}
+			sessionID := ctx.Args().First()
+
+			agg, err := newAggregator(ctx)
+			if err != nil {
+				return err
+			}
+			defer agg.Close()
+
+			instance, err := resolveInstance(ctx, agg, sessionID)
+			if err != nil {
+				return err
+			}
+
+			rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+			defer cancel()
+			detail, err := agg.GetSession(rctx, instance, sessionID)
+			if err != nil {
+				return fmt.Errorf("show %s/%s: %w", instance, sessionID, err)
+			}
+
+			if ctx.Bool("json") {
+				enc := json.NewEncoder(ctx.App.Writer)
+				enc.SetIndent("", "  ")
+				return enc.Encode(sessionDetailJSON(instance, detail))
+			}
+			return writeSessionDetail(ctx.App.Writer, instance, detail)
+		},
+	}
+}
+
+// sessionDetailJSON returns the JSON output of show.
+func sessionDetailJSON(instance string, detail *libadmin.GetSessionResponse) map[string]any {
+	s := detail.GetSession()
+	channels := make([]map[string]any, 0, len(detail.GetChannels()))
+	for _, c := range detail.GetChannels() {
+		channels = append(channels, map[string]any{
+			"id":               c.GetId(),
+			"type":             c.GetType(),
+			"request":          c.GetRequest(),
+			"command":          c.GetCommand(),
+			"width":            c.GetWidth(),
+			"height":           c.GetHeight(),
+			"started_at":       c.GetStartedAt(),
+			"downstream_bytes": c.GetDownstreamBytes(),
+			"upstream_bytes":   c.GetUpstreamBytes(),
+		})
+	}
+	algorithms := func(a *libadmin.Algorithms) map[string]string {
+		return map[string]string{
+			"kex":        a.GetKex(),
+			"host_key":   a.GetHostKey(),
+			"cipher_in":  a.GetCipherIn(),
+			"cipher_out": a.GetCipherOut(),
+			"mac_in":     a.GetMacIn(),
+			"mac_out":    a.GetMacOut(),
+		}
+	}
+	return map[string]any{
+		"instance_id":           instance,
+		"id":                    s.GetId(),
+		"downstream_user":       s.GetDownstreamUser(),
+		"downstream_addr":       s.GetDownstreamAddr(),
+		"upstream_user":         s.GetUpstreamUser(),
+		"upstream_addr":         s.GetUpstreamAddr(),
+		"started_at":            s.GetStartedAt(),
+		"last_activity_at":      detail.GetLastActivityAt(),
+		"streamable":            s.GetStreamable(),
+		"paused":                s.GetPaused(),
+		"downstream_bytes":      s.GetDownstreamBytes(),
+		"upstream_bytes":        s.GetUpstreamBytes(),
+		"labels":                s.GetLabels(),
+		"client_version":        detail.GetClientVersion(),
+		"server_version":        detail.GetServerVersion(),
+		"downstream_algorithms": algorithms(detail.GetDownstreamAlgorithms()),
+		"upstream_algorithms":   algorithms(detail.GetUpstreamAlgorithms()),
+		"channels":              channels,
+	}
+}
+
+// writeSessionDetail writes the human-readable output of show.
+func writeSessionDetail(w io.Writer, instance string, detail *libadmin.GetSessionResponse) error {
+	s := detail.GetSession()
+	unix := func(ts int64) string {
+		if ts == 0 {
+			return "-"
+		}
+		return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+	}
+	algorithms := func(a *libadmin.Algorithms) string {
+		if a.GetKex() == "" {
+			return "-"
+		}
+		return fmt.Sprintf("kex=%s hostkey=%s cipher=%s/%s mac=%s/%s", a.GetKex(), a.GetHostKey(), a.GetCipherIn(), a.GetCipherOut(), a.GetMacIn(), a.GetMacOut())
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Instance:\t%s\n", instance)
+	fmt.Fprintf(tw, "Session ID:\t%s\n", s.GetId())
+	fmt.Fprintf(tw, "Downstream:\t%s@%s\t%s\n", s.GetDownstreamUser(), s.GetDownstreamAddr(), detail.GetClientVersion())
+	fmt.Fprintf(tw, "Upstream:\t%s@%s\t%s\n", s.GetUpstreamUser(), s.GetUpstreamAddr(), detail.GetServerVersion())
+	fmt.Fprintf(tw, "Started:\t%s\n", unix(s.GetStartedAt()))
+	fmt.Fprintf(tw, "Last activity:\t%s\n", unix(detail.GetLastActivityAt()))
+	fmt.Fprintf(tw, "Bytes in/out:\t%d/%d\n", s.GetDownstreamBytes(), s.GetUpstreamBytes())
+	fmt.Fprintf(tw, "Paused:\t%v\n", s.GetPaused())
+	fmt.Fprintf(tw, "Labels:\t%s\n", libadmin.FormatLabels(s.GetLabels()))
+	fmt.Fprintf(tw, "Downstream algorithms:\t%s\n", algorithms(detail.GetDownstreamAlgorithms()))
+	fmt.Fprintf(tw, "Upstream algorithms:\t%s\n", algorithms(detail.GetUpstreamAlgorithms()))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANNEL\tTYPE\tREQUEST\tCOMMAND\tPTY\tSTARTED\tBYTES IN\tBYTES OUT")
+	for _, c := range detail.GetChannels() {
+		pty := "-"
+		if c.GetWidth() > 0 {
+			pty = fmt.Sprintf("%dx%d", c.GetWidth(), c.GetHeight())
+		}
+		request, command := c.GetRequest(), "-"
+		if request == "" {
+			request = "-"
+		}
+		if c.GetCommand() != "" {
+			command = strconv.Quote(c.GetCommand())
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n", c.GetId(), c.GetType(), request, command, pty, unix(c.GetStartedAt()), c.GetDownstreamBytes(), c.GetUpstreamBytes())
+	}
+	return tw.Flush()
+}
+
+func historyCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "history",
+		Usage: "list finished sessions across all configured sshpiperd instances, most recently ended first",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "user",
+				Usage: "only list the sessions of this downstream user",
+			},
+			&cli.StringFlag{
+				Name:  "upstream",
+				Usage: "only list the sessions to this upstream address, as host:port or host",
+			},
+			&cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "only list sessions with this label, as key=value. Repeat to require several",
+			},
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "only list the sessions open after this time, as RFC 3339 or a duration ago, e.g. 24h",
+			},
+			&cli.StringFlag{
+				Name:  "until",
+				Usage: "only list the sessions open before this time, as RFC 3339 or a duration ago",
+			},
+			&cli.IntFlag{
+				Name:  "limit",
+				Value: libadmin.DefaultHistoryPageSize,
+				Usage: "number of sessions to list",
+			},
+			&cli.StringFlag{
+				Name:  "page-token",
+				Usage: "continue a previous listing, see the token it printed",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "emit JSON instead of a human-readable table",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			selector, err := libadmin.ParseLabelSelector(ctx.StringSlice("label"))
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			since, err := parseHistoryTime(ctx.String("since"), now)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			until, err := parseHistoryTime(ctx.String("until"), now)
+			if err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+			if ctx.Int("limit") <= 0 {
+				return fmt.Errorf("--limit must be positive")
+			}
+
+			agg, err := newAggregator(ctx)
+			if err != nil {
+				return err
+			}
+			defer agg.Close()
+
+			rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+			defer cancel()
+			entries, next, errs := agg.ListSessionHistory(rctx, &libadmin.ListSessionHistoryRequest{
+				DownstreamUser: ctx.String("user"),
+				UpstreamAddr:   ctx.String("upstream"),
+				Labels:         selector,
+				Since:          since,
+				Until:          until,
+				PageSize:       int32(min(ctx.Int("limit"), math.MaxInt32)), //nolint:gosec // clamped
+				PageToken:      ctx.String("page-token"),
+			})
+			for _, e := range errs {
+				if errors.Is(e, libadmin.ErrBadHistoryPageToken) {
+					return fmt.Errorf("invalid --page-token: %w", e)
+				}
+				slog.Warn("history failed", "error", e)
+			}
+
+			if ctx.Bool("json") {
+				out := make([]map[string]any, 0, len(entries))
+				for _, e := range entries {
+					out = append(out, historyEntryJSON(e))
+				}
+				enc := json.NewEncoder(ctx.App.Writer)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]any{"sessions": out, "next_page_token": next})
+			}
+			if err := writeHistory(ctx.App.Writer, entries); err != nil {
+				return err
+			}
+			if next != "" {
+				fmt.Fprintf(ctx.App.ErrWriter, "more sessions: --page-token %s\n", next)
+			}
+			return nil
+		},
+	}
+}
+
+// parseHistoryTime parses the --since and --until flags, as RFC 3339 or a
+// duration before now, into unix seconds, 0 when empty.
+func parseHistoryTime(value string, now time.Time) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d).Unix(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither RFC 3339 nor a duration", value)
+	}
+	return t.Unix(), nil
+}
+
+// historyEntryJSON returns the JSON output of history for e.
+func historyEntryJSON(e libadmin.AggregatedHistoryEntry) map[string]any {
+	s := e.Entry.GetSession()
+	return map[string]any{
+		"instance_id":      e.InstanceID,
+		"instance_addr":    e.InstanceAddr,
+		"id":               s.GetId(),
+		"downstream_user":  s.GetDownstreamUser(),
+		"downstream_addr":  s.GetDownstreamAddr(),
+		"upstream_user":    s.GetUpstreamUser(),
+		"upstream_addr":    s.GetUpstreamAddr(),
+		"started_at":       s.GetStartedAt(),
+		"ended_at":         e.Entry.GetEndedAt(),
+		"downstream_bytes": s.GetDownstreamBytes(),
+		"upstream_bytes":   s.GetUpstreamBytes(),
+		"labels":           s.GetLabels(),
+		"close_reason":     e.Entry.GetCloseReason(),
+		"commands":         e.Entry.GetCommands(),
+		"channels":         e.Entry.GetChannels(),
+		"exit_statuses":    e.Entry.GetExitStatuses(),
+		"recording_files":  e.Entry.GetRecordingFiles(),
+		"client_version":   e.Entry.GetClientVersion(),
+		"server_version":   e.Entry.GetServerVersion(),
+
+		"dropped_commands":      e.Entry.GetDroppedCommands(),
+		"dropped_exit_statuses": e.Entry.GetDroppedExitStatuses(),
+	}
+}
+
+// writeHistory writes the human-readable output of history.
+func writeHistory(w io.Writer, entries []libadmin.AggregatedHistoryEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INSTANCE\tSESSION ID\tDOWNSTREAM\tUPSTREAM\tSTARTED\tDURATION\tBYTES IN/OUT\tCLOSE REASON\tCOMMANDS")
+	for _, e := range entries {
+		s := e.Entry.GetSession()
+		reason := e.Entry.GetCloseReason()
+		if reason == "" {
+			reason = "-"
+		}
+		commands := "-"
+		if len(e.Entry.GetCommands()) > 0 {
+			quoted := make([]string, len(e.Entry.GetCommands()))
+			for i, c := range e.Entry.GetCommands() {
+				quoted[i] = strconv.Quote(c)
+			}
+			commands = strings.Join(quoted, " ")
+			if n := e.Entry.GetDroppedCommands(); n > 0 {
+				commands += fmt.Sprintf(" (+%d more)", n)
+			}
+		}
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s@%s\t%s@%s\t%s\t%s\t%d/%d\t%s\t%s\n",
+			e.InstanceID,
+			s.GetId(),
+			s.GetDownstreamUser(), s.GetDownstreamAddr(),
+			s.GetUpstreamUser(), s.GetUpstreamAddr(),
+			time.Unix(s.GetStartedAt(), 0).UTC().Format(time.RFC3339),
+			time.Duration(e.Entry.GetEndedAt()-s.GetStartedAt())*time.Second,
+			s.GetDownstreamBytes(), s.GetUpstreamBytes(),
+			reason,
+			commands,
+		)
+	}
+	return tw.Flush()
+}
+
 // resolveInstance returns the instance id that hosts sessionID. When the
 // caller passes an explicit --instance it is used verbatim; otherwise the
 // aggregator is queried and the call succeeds only when exactly one
@@ -313,19 +690,54 @@ func resolveInstance(ctx *cli.Context, agg *libadmin.Aggregator, sessionID strin
 func killCommand() *cli.Command {
 	return &cli.Command{
 		Name:      "kill",
-		Usage:     "kill an active session",
-		ArgsUsage: "<session-id>",
+		Usage:     "kill an active session, or every session matching --user, --upstream, --cidr and --label",
+		ArgsUsage: "[<session-id>]",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "instance",
 				Usage: "id of the sshpiperd instance hosting the session (auto-detected when omitted)",
 			},
+			&cli.StringFlag{
+				Name:  "reason",
+				Usage: "reason sent to the client in the ssh disconnect message",
+			},
+			&cli.StringFlag{
+				Name:  "user",
+				Usage: "kill the sessions of this downstream user on every instance",
+			},
+			&cli.StringFlag{
+				Name:  "upstream",
+				Usage: "kill the sessions to this upstream address, as host:port or host, on every instance",
+			},
+			&cli.StringFlag{
+				Name:  "cidr",
+				Usage: "kill the sessions from this downstream network, e.g. 10.0.0.0/8, on every instance",
+			},
+			&cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "kill the sessions with this label, as key=value, on every instance. Repeat to require several",
+			},
 		},
 		Action: func(ctx *cli.Context) error {
-			if ctx.NArg() != 1 {
-				return fmt.Errorf("expected exactly one <session-id> argument")
+			selector, err := libadmin.ParseLabelSelector(ctx.StringSlice("label"))
+			if err != nil {
+				return err
+			}
+			bulk := &libadmin.KillSessionsRequest{
+				DownstreamUser: ctx.String("user"),
+				UpstreamAddr:   ctx.String("upstream"),
+				DownstreamCidr: ctx.String("cidr"),
+				Labels:         selector,
+				Reason:         ctx.String("reason"),
+			}
+			isBulk := bulk.DownstreamUser != "" || bulk.UpstreamAddr != "" || bulk.DownstreamCidr != "" || len(selector) > 0
+
+			switch {
+			case isBulk && ctx.NArg() != 0:
+				return fmt.Errorf("pass either a <session-id> or --user, --upstream, --cidr and --label, not both")
+			case !isBulk && ctx.NArg() != 1:
+				return fmt.Errorf("expected exactly one <session-id> argument, or --user, --upstream, --cidr or --label")
 			}
-			sessionID := ctx.Args().First()
 
 			agg, err := newAggregator(ctx)
 			if err != nil {
@@ -333,6 +745,11 @@ func killCommand() *cli.Command {
 			}
 			defer agg.Close()
 
+			if isBulk {
+				return killSessions(ctx, agg, bulk)
+			}
+			sessionID := ctx.Args().First()
+
 			instance, err := resolveInstance(ctx, agg, sessionID)
 			if err != nil {
 				return err
@@ -340,7 +757,7 @@ func killCommand() *cli.Command {
 
 			rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
 			defer cancel()
-			killed, err := agg.KillSession(rctx, instance, sessionID)
+			killed, err := agg.KillSessionWithReason(rctx, instance, sessionID, ctx.String("reason"))
 			if err != nil {
 				return fmt.Errorf("kill %s/%s: %w", instance, sessionID, err)
 			}
@@ -353,6 +770,299 @@ func killCommand() *cli.Command {
 	}
 }
 
+// killSessions kills the sessions matching req on every instance, and
+// prints them.
+func killSessions(ctx *cli.Context, agg *libadmin.Aggregator, req *libadmin.KillSessionsRequest) error {
+	rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+	defer cancel()
+	killed, errs := agg.KillSessions(rctx, req)
+	for _, s := range killed {
+		fmt.Fprintf(ctx.App.Writer, "killed %s (%s@%s) on %s\n", s.Session.GetId(), s.Session.GetDownstreamUser(), s.Session.GetDownstreamAddr(), s.InstanceID)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("kill failed on %d instances: %w", len(errs), errors.Join(errs...))
+	}
+	if len(killed) == 0 {
+		fmt.Fprintln(ctx.App.Writer, "no matching sessions")
+	}
+	return nil
+}
+
+func messageCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "message",
+		Usage:     "write a message to the terminal of an active session",
+		ArgsUsage: "<session-id> <message...>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "instance",
+				Usage: "id of the sshpiperd instance hosting the session (auto-detected when omitted)",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() < 2 {
+				return fmt.Errorf("expected a <session-id> and a <message> argument")
+			}
+			sessionID := ctx.Args().First()
+			message := strings.Join(ctx.Args().Tail(), " ")
+
+			agg, err := newAggregator(ctx)
+			if err != nil {
+				return err
+			}
+			defer agg.Close()
+
+			instance, err := resolveInstance(ctx, agg, sessionID)
+			if err != nil {
+				return err
+			}
+
+			rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+			defer cancel()
+			channels, err := agg.MessageSession(rctx, instance, sessionID, message)
+			if err != nil {
+				return fmt.Errorf("message %s/%s: %w", instance, sessionID, err)
+			}
+			fmt.Fprintf(ctx.App.Writer, "messaged %s on %s (%d channels)\n", sessionID, instance, channels)
+			return nil
+		},
+	}
+}
+
+func pauseCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "pause",
+		Usage:     "stop forwarding the input of an active session, keeping it connected",
+		ArgsUsage: "<session-id>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "instance",
+				Usage: "id of the sshpiperd instance hosting the session (auto-detected when omitted)",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			return pauseOrResume(ctx, "pause", (*libadmin.Aggregator).PauseSession)
+		},
+	}
+}
+
+func resumeCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "resume",
+		Usage:     "forward the input of a paused session again",
+		ArgsUsage: "<session-id>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "instance",
+				Usage: "id of the sshpiperd instance hosting the session (auto-detected when omitted)",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			return pauseOrResume(ctx, "resume", (*libadmin.Aggregator).ResumeSession)
+		},
+	}
+}
+
+// pauseOrResume runs the shared body of the pause and resume commands.
+func pauseOrResume(ctx *cli.Context, verb string, call func(*libadmin.Aggregator, context.Context, string, string) (bool, error)) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expected exactly one <session-id> argument")
+	}
+	sessionID := ctx.Args().First()
+
+	agg, err := newAggregator(ctx)
+	if err != nil {
+		return err
+	}
+	defer agg.Close()
+
+	instance, err := resolveInstance(ctx, agg, sessionID)
+	if err != nil {
+		return err
+	}
+
+	rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+	defer cancel()
+	ok, err := call(agg, rctx, instance, sessionID)
+	if err != nil {
+		return fmt.Errorf("%s %s/%s: %w", verb, instance, sessionID, err)
+	}
+	if !ok {
+		return fmt.Errorf("session %s/%s not found", instance, sessionID)
+	}
+	fmt.Fprintf(ctx.App.Writer, "%sd %s on %s\n", verb, sessionID, instance)
+	return nil
+}
+
+func reloadHostKeysCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "reload-hostkeys",
+		Usage: "reload host keys and certificates on sshpiperd instances",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "instance",
+				Usage: "id of the sshpiperd instance to reload (all configured instances when omitted)",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			agg, err := newAggregator(ctx)
+			if err != nil {
+				return err
+			}
+			defer agg.Close()
+
+			var instances []string
+			if explicit := ctx.String("instance"); explicit != "" {
+				instances = []string{explicit}
+			} else {
+				for id := range agg.Instances() {
+					instances = append(instances, id)
+				}
+				sort.Strings(instances)
+			}
+
+			var failed int
+			for _, instance := range instances {
+				c := agg.ClientFor(instance)
+				if c == nil {
+					slog.Warn("unknown instance", "instance", instance)
+					failed++
+					continue
+				}
+
+				rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+				resp, err := c.ReloadHostKeys(rctx)
+				cancel()
+				if err != nil {
+					slog.Warn("reload host keys failed", "instance", instance, "error", err)
+					failed++
+					continue
+				}
+
+				fmt.Fprintf(ctx.App.Writer, "%s: %s\n", instance, strings.Join(resp.GetFingerprints(), " "))
+				if resp.GetRetireAt() > 0 {
+					fmt.Fprintf(ctx.App.Writer, "%s: retiring %s at %s\n", instance, strings.Join(resp.GetRetiringFingerprints(), " "), time.Unix(resp.GetRetireAt(), 0).UTC().Format(time.RFC3339))
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("host key reload failed on %d of %d instances", failed, len(instances))
+			}
+			return nil
+		},
+	}
+}
+
+func verifyAuditCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "verify-audit",
+		Usage:       "check the hash chain of a sshpiperd admin audit log",
+		ArgsUsage:   "<file>",
+		Description: "Reads a file written with sshpiperd --admin-grpc-audit-log and fails on the first line whose prev_hash does not match the line before it, i.e. after a line was removed or changed. Keep the printed hash of the last line elsewhere to also detect removed lines at the end.",
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() != 1 {
+				return fmt.Errorf("usage: verify-audit <file>")
+			}
+			f, err := os.Open(ctx.Args().First())
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			n, last, err := libadmin.VerifyAuditLog(f)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(ctx.App.Writer, "%d records, chain intact, last line hash %s\n", n, last)
+			return nil
+		},
+	}
+}
+
+func pluginsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "plugins",
+		Usage: "show the plugins, their restarts and rpc statistics on sshpiperd instances",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "rpc-stats",
+				Usage: "also show latency and error statistics per plugin rpc",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			agg, err := newAggregator(ctx)
+			if err != nil {
+				return err
+			}
+			defer agg.Close()
+
+			var instances []string
+			for id := range agg.Instances() {
+				instances = append(instances, id)
+			}
+			sort.Strings(instances)
+
+			var infos []*libadmin.ServerInfoResponse
+			tw := tabwriter.NewWriter(ctx.App.Writer, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "INSTANCE\tPLUGIN\tVERSION\tPROTOCOL\tAVAILABLE\tCIRCUIT OPEN\tRESTARTS\tLAST RESTART\tLAST ERROR")
+			for _, instance := range instances {
+				c := agg.ClientFor(instance)
+				if c == nil {
+					continue
+				}
+
+				rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+				info, err := c.ServerInfo(rctx)
+				cancel()
+				if err != nil {
+					slog.Warn("server info failed", "instance", instance, "error", err)
+					continue
+				}
+
+				for _, p := range info.GetPlugins() {
+					lastRestart := "-"
+					if p.GetLastRestartAt() > 0 {
+						lastRestart = time.Unix(p.GetLastRestartAt(), 0).UTC().Format(time.RFC3339)
+					}
+					version, protocol := "-", "-"
+					if p.GetPluginVersion() != "" {
+						version = p.GetPluginVersion()
+					}
+					if p.GetProtocolVersion() > 0 {
+						protocol = strconv.FormatUint(uint64(p.GetProtocolVersion()), 10)
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%v\t%v\t%d\t%s\t%s\n", instance, p.GetName(), version, protocol, p.GetAvailable(), p.GetCircuitOpen(), p.GetRestarts(), lastRestart, p.GetLastError())
+				}
+
+				infos = append(infos, info)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if !ctx.Bool("rpc-stats") {
+				return nil
+			}
+
+			fmt.Fprintln(ctx.App.Writer)
+			tw = tabwriter.NewWriter(ctx.App.Writer, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "INSTANCE\tPLUGIN\tRPC\tCALLS\tERRORS\tTIMEOUTS\tREJECTED\tAVG\tMAX")
+			for _, info := range infos {
+				for _, p := range info.GetPlugins() {
+					for _, r := range p.GetRpcs() {
+						var avg time.Duration
+						if r.GetCalls() > 0 {
+							avg = time.Duration(r.GetTotalLatencyUs()/r.GetCalls()) * time.Microsecond
+						}
+						fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n", info.GetId(), p.GetName(), r.GetMethod(), r.GetCalls(), r.GetErrors(), r.GetTimeouts(), r.GetRejected(), avg, time.Duration(r.GetMaxLatencyUs())*time.Microsecond)
+					}
+				}
+			}
+			return tw.Flush()
+		},
+	}
+}
+
 func streamCommand() *cli.Command {
 	return &cli.Command{
 		Name:      "stream",
@@ -413,6 +1123,111 @@ func streamCommand() *cli.Command {
 	}
 }
 
+// attachDetachKey is Ctrl-], which detaches attach like telnet.
+const attachDetachKey = 0x1d
+
+func attachCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "attach",
+		Usage:       "attach read-write to a shell of an active session",
+		ArgsUsage:   "<session-id>",
+		Description: "Shows the screen of a shell/exec channel of the session and types into it, as its user would. The user sees a line telling you joined and left. Press Ctrl-] to detach. Input piped to stdin is sent as is, the command detaches at its end. sshpiperd must run with --admin-grpc-allow-attach.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "instance",
+				Usage: "id of the sshpiperd instance hosting the session (auto-detected when omitted)",
+			},
+			&cli.UintFlag{
+				Name:  "channel",
+				Usage: "id of the channel to attach to, as in the headers of stream (the first shell/exec channel when omitted)",
+			},
+			&cli.StringFlag{
+				Name:  "name",
+				Value: os.Getenv("USER"),
+				Usage: "name shown to the user, replaced by the common name of the client certificate when sshpiperd requires one",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() != 1 {
+				return fmt.Errorf("expected exactly one <session-id> argument")
+			}
+			sessionID := ctx.Args().First()
+
+			agg, err := newAggregator(ctx)
+			if err != nil {
+				return err
+			}
+			defer agg.Close()
+
+			instance, err := resolveInstance(ctx, agg, sessionID)
+			if err != nil {
+				return err
+			}
+
+			req := &libadmin.AttachSessionRequest{Id: sessionID, Name: ctx.String("name"), Replay: true}
+			if ctx.IsSet("channel") {
+				channel := uint32(ctx.Uint("channel")) //nolint:gosec // ssh channel ids are uint32
+				req.ChannelId = &channel
+			}
+
+			// Like stream, no per-call timeout: the attachment lasts until
+			// detached.
+			stream, err := agg.AttachSession(ctx.Context, instance, req)
+			if err != nil {
+				return fmt.Errorf("attach %s/%s: %w", instance, sessionID, err)
+			}
+
+			if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+				state, err := term.MakeRaw(fd)
+				if err != nil {
+					return fmt.Errorf("set terminal raw mode: %w", err)
+				}
+				defer func() { _ = term.Restore(fd, state) }()
+				fmt.Fprintf(ctx.App.ErrWriter, "attaching to %s on %s, press Ctrl-] to detach\r\n", sessionID, instance)
+			}
+
+			go func() {
+				defer func() { _ = stream.CloseSend() }()
+				buf := make([]byte, 4096)
+				for {
+					n, err := os.Stdin.Read(buf)
+					input := buf[:n]
+					detach := false
+					if i := bytes.IndexByte(input, attachDetachKey); i >= 0 {
+						input, detach = input[:i], true
+					}
+					if len(input) > 0 {
+						if err := stream.Send(&libadmin.AttachSessionRequest{Input: append([]byte(nil), input...)}); err != nil {
+							return
+						}
+					}
+					if detach || err != nil {
+						return
+					}
+				}
+			}()
+
+			for {
+				frame, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return fmt.Errorf("attach %s/%s: %w", instance, sessionID, err)
+				}
+				if ev := frame.GetEvent(); ev.GetKind() == "o" {
+					if _, err := ctx.App.Writer.Write(ev.GetData()); err != nil {
+						return err
+					}
+				}
+			}
+		},
+	}
+}
+
 // streamHandler returns a frame handler that writes session frames to w
 // in the requested format.
 func streamHandler(format string, w io.Writer) func(*libadmin.SessionFrame) error {
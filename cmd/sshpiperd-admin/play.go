@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"github.com/urfave/cli/v2"
+)
+
+func recordingsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "recordings",
+		Usage: "list screen recordings across all configured sshpiperd instances, most recently written first",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "session",
+				Usage: "only list the recordings of this session id",
+			},
+			&cli.StringFlag{
+				Name:  "user",
+				Usage: "only list the recordings of this downstream user",
+			},
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "only list the recordings written after this time, as RFC 3339 or a duration ago, e.g. 24h",
+			},
+			&cli.StringFlag{
+				Name:  "until",
+				Usage: "only list the recordings started before this time, as RFC 3339 or a duration ago",
+			},
+			&cli.IntFlag{
+				Name:  "limit",
+				Value: libadmin.DefaultRecordingsPageSize,
+				Usage: "number of recordings to list",
+			},
+			&cli.StringFlag{
+				Name:  "page-token",
+				Usage: "continue a previous listing, see the token it printed",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "emit JSON instead of a human-readable table",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			now := time.Now()
+			since, err := parseHistoryTime(ctx.String("since"), now)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			until, err := parseHistoryTime(ctx.String("until"), now)
+			if err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+			if ctx.Int("limit") <= 0 {
+				return fmt.Errorf("--limit must be positive")
+			}
+
+			agg, err := newAggregator(ctx)
+			if err != nil {
+				return err
+			}
+			defer agg.Close()
+
+			rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+			defer cancel()
+			recordings, next, errs := agg.ListRecordings(rctx, &libadmin.ListRecordingsRequest{
+				SessionId:      ctx.String("session"),
+				DownstreamUser: ctx.String("user"),
+				Since:          since,
+				Until:          until,
+				PageSize:       int32(min(ctx.Int("limit"), math.MaxInt32)), //nolint:gosec // clamped
+				PageToken:      ctx.String("page-token"),
+			})
+			for _, e := range errs {
+				if errors.Is(e, libadmin.ErrBadRecordingsPageToken) {
+					return fmt.Errorf("invalid --page-token: %w", e)
+				}
+				slog.Warn("recordings failed", "error", e)
+			}
+
+			if ctx.Bool("json") {
+				out := make([]map[string]any, 0, len(recordings))
+				for _, r := range recordings {
+					out = append(out, map[string]any{
+						"instance_id":     r.InstanceID,
+						"instance_addr":   r.InstanceAddr,
+						"path":            r.Recording.GetPath(),
+						"format":          r.Recording.GetFormat(),
+						"session_id":      r.Recording.GetSessionId(),
+						"downstream_user": r.Recording.GetDownstreamUser(),
+						"started_at":      r.Recording.GetStartedAt(),
+						"modified_at":     r.Recording.GetModifiedAt(),
+						"size":            r.Recording.GetSize(),
+						"timing_path":     r.Recording.GetTimingPath(),
+					})
+				}
+				enc := json.NewEncoder(ctx.App.Writer)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]any{"recordings": out, "next_page_token": next})
+			}
+			if err := writeRecordings(ctx.App.Writer, recordings); err != nil {
+				return err
+			}
+			if next != "" {
+				fmt.Fprintf(ctx.App.ErrWriter, "more recordings: --page-token %s\n", next)
+			}
+			return nil
+		},
+	}
+}
+
+// writeRecordings writes the human-readable output of recordings.
+func writeRecordings(w io.Writer, recordings []libadmin.AggregatedRecording) error {
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INSTANCE\tPATH\tFORMAT\tSESSION ID\tUSER\tSTARTED\tDURATION\tSIZE")
+	for _, r := range recordings {
+		rec := r.Recording
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			r.InstanceID,
+			rec.GetPath(),
+			rec.GetFormat(),
+			orDash(rec.GetSessionId()),
+			orDash(rec.GetDownstreamUser()),
+			time.Unix(rec.GetStartedAt(), 0).UTC().Format(time.RFC3339),
+			time.Duration(max(rec.GetModifiedAt()-rec.GetStartedAt(), 0))*time.Second,
+			rec.GetSize(),
+		)
+	}
+	return tw.Flush()
+}
+
+// maxRecordingsPage is the page size play lists the recordings with, the
+// largest sshpiperd returns.
+const maxRecordingsPage = 1000
+
+func playCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "play",
+		Usage:       "replay a screen recording in the terminal",
+		ArgsUsage:   "<recording-path | session-id>",
+		Description: "Replays a recording listed by `recordings`, or every recording of a session one after another, with the pauses of the original session. Press Ctrl-C to stop.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "instance",
+				Usage: "id of the sshpiperd instance storing the recording (auto-detected when omitted)",
+			},
+			&cli.Float64Flag{
+				Name:  "speed",
+				Value: 1,
+				Usage: "playback speed, e.g. 2 plays twice as fast",
+			},
+			&cli.DurationFlag{
+				Name:  "idle-limit",
+				Usage: "shorten the pauses longer than this, 0 keeps them",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() != 1 {
+				return fmt.Errorf("expected exactly one <recording-path | session-id> argument")
+			}
+			if ctx.Float64("speed") <= 0 {
+				return fmt.Errorf("--speed must be positive")
+			}
+			arg := ctx.Args().First()
+
+			agg, err := newAggregator(ctx)
+			if err != nil {
+				return err
+			}
+			defer agg.Close()
+
+			rctx, cancel := context.WithTimeout(ctx.Context, ctx.Duration("timeout"))
+			req := &libadmin.ListRecordingsRequest{PageSize: maxRecordingsPage}
+			isPath := slices.Contains([]string{".cast", ".typescript"}, path.Ext(arg))
+			if !isPath {
+				req.SessionId = arg
+			}
+			var recordings []libadmin.AggregatedRecording
+			for {
+				page, next, errs := agg.ListRecordings(rctx, req)
+				for _, e := range errs {
+					slog.Warn("recordings failed", "error", e)
+				}
+				recordings = append(recordings, page...)
+				if next == "" {
+					break
+				}
+				req.PageToken = next
+			}
+			cancel()
+			recordings = slices.DeleteFunc(recordings, func(r libadmin.AggregatedRecording) bool {
+				return isPath && r.Recording.GetPath() != arg ||
+					ctx.String("instance") != "" && r.InstanceID != ctx.String("instance")
+			})
+			if len(recordings) == 0 {
+				return fmt.Errorf("no recording %q on any configured sshpiperd instance", arg)
+			}
+			if isPath && len(recordings) > 1 {
+				return fmt.Errorf("recording %q is stored on several instances; pass --instance to disambiguate", arg)
+			}
+			// oldest first, e.g. the channels of a session in order
+			slices.Reverse(recordings)
+
+			for _, r := range recordings {
+				frames, err := fetchRecording(ctx.Context, agg, r)
+				if err != nil {
+					return fmt.Errorf("get %s/%s: %w", r.InstanceID, r.Recording.GetPath(), err)
+				}
+				if err := playFrames(ctx.Context, ctx.App.Writer, frames, ctx.Float64("speed"), ctx.Duration("idle-limit")); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// recordingFrame is output of a recording, written delay after the
+// previous frame.
+type recordingFrame struct {
+	delay time.Duration
+	data  []byte
+}
+
+// fetchRecording downloads r, with the timing file of a typescript, and
+// parses its frames.
+func fetchRecording(ctx context.Context, agg *libadmin.Aggregator, r libadmin.AggregatedRecording) ([]recordingFrame, error) {
+	var data bytes.Buffer
+	if err := agg.GetRecording(ctx, r.InstanceID, r.Recording.GetPath(), &data); err != nil {
+		return nil, err
+	}
+	if r.Recording.GetFormat() != "typescript" {
+		return parseAsciicast(&data)
+	}
+	var timing bytes.Buffer
+	if err := agg.GetRecording(ctx, r.InstanceID, r.Recording.GetTimingPath(), &timing); err != nil {
+		return nil, err
+	}
+	return parseTypescript(data.Bytes(), &timing)
+}
+
+// parseAsciicast returns the output frames of an asciicast v2 recording.
+func parseAsciicast(r io.Reader) ([]recordingFrame, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("asciicast: missing header")
+	}
+	var (
+		frames []recordingFrame
+		last   float64
+	)
+	for n := 2; scanner.Scan(); n++ {
+		var event []any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// the last line of a recording still written may be partial
+			if !scanner.Scan() {
+				break
+			}
+			return nil, fmt.Errorf("asciicast line %d: %w", n, err)
+		}
+		if len(event) != 3 {
+			return nil, fmt.Errorf("asciicast line %d: want [time, code, data]", n)
+		}
+		t, ok1 := event[0].(float64)
+		code, _ := event[1].(string)
+		data, ok2 := event[2].(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("asciicast line %d: want [time, code, data]", n)
+		}
+		if code != "o" {
+			continue
+		}
+		frames = append(frames, recordingFrame{delay: time.Duration(max(t-last, 0) * float64(time.Second)), data: []byte(data)})
+		last = t
+	}
+	return frames, scanner.Err()
+}
+
+// parseTypescript returns the frames of a typescript recording, split by
+// its timing file as written by script(1).
+func parseTypescript(typescript []byte, timing io.Reader) ([]recordingFrame, error) {
+	// skip the "Script started on" header line
+	if i := bytes.IndexByte(typescript, '\n'); i >= 0 {
+		typescript = typescript[i+1:]
+	}
+	var frames []recordingFrame
+	scanner := bufio.NewScanner(timing)
+	for n := 1; scanner.Scan(); n++ {
+		delay, size, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		seconds, err1 := strconv.ParseFloat(delay, 64)
+		length, err2 := strconv.Atoi(size)
+		if !ok || err1 != nil || err2 != nil || length < 0 {
+			return nil, fmt.Errorf("timing line %d: want <seconds> <bytes>", n)
+		}
+		length = min(length, len(typescript))
+		frames = append(frames, recordingFrame{delay: time.Duration(seconds * float64(time.Second)), data: typescript[:length]})
+		typescript = typescript[length:]
+	}
+	return frames, scanner.Err()
+}
+
+// playFrames writes frames to w with their delays divided by speed and
+// capped to idleLimit, if set, until ctx is done.
+func playFrames(ctx context.Context, w io.Writer, frames []recordingFrame, speed float64, idleLimit time.Duration) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for _, f := range frames {
+		delay := f.delay
+		if idleLimit > 0 {
+			delay = min(delay, idleLimit)
+		}
+		timer.Reset(time.Duration(float64(delay) / speed))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
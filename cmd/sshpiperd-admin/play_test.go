@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tg123/sshpiper/libadmin"
+)
+
+func TestParseAsciicast(t *testing.T) {
+	cast := `{"version": 2, "width": 80, "height": 24, "timestamp": 1700000000, "env": {}}
+[0.5,"o","$ "]
+[0.7,"r", "100x30"]
+[1.5,"o","ls\r\n"]
+[1.6,"o","partial`
+	frames, err := parseAsciicast(strings.NewReader(cast))
+	if err != nil {
+		t.Fatalf("parseAsciicast: %v", err)
+	}
+	if len(frames) != 2 || frames[0].delay != 500*time.Millisecond || string(frames[0].data) != "$ " ||
+		frames[1].delay != time.Second || string(frames[1].data) != "ls\r\n" {
+		t.Fatalf("frames = %+v", frames)
+	}
+
+	if _, err := parseAsciicast(strings.NewReader("{}\nnope\n[1,\"o\",\"x\"]\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("parseAsciicast of a bad line = %v", err)
+	}
+	if _, err := parseAsciicast(strings.NewReader("")); err == nil {
+		t.Fatal("parseAsciicast of an empty file succeeded")
+	}
+}
+
+func TestParseTypescript(t *testing.T) {
+	typescript := []byte("Script started on Tue Nov 14 22:13:20 2023\n$ ls\r\nfile\r\n")
+	frames, err := parseTypescript(typescript, strings.NewReader("0.250000 2\n1.000000 4\n0.000010 6\n"))
+	if err != nil {
+		t.Fatalf("parseTypescript: %v", err)
+	}
+	if len(frames) != 3 || frames[0].delay != 250*time.Millisecond || string(frames[0].data) != "$ " ||
+		string(frames[1].data) != "ls\r\n" || frames[2].delay != 10*time.Microsecond || string(frames[2].data) != "file\r\n" {
+		t.Fatalf("frames = %+v", frames)
+	}
+	if _, err := parseTypescript(typescript, strings.NewReader("x 2\n")); err == nil {
+		t.Fatal("parseTypescript of a bad timing file succeeded")
+	}
+}
+
+func TestPlayFrames(t *testing.T) {
+	frames := []recordingFrame{{delay: 0, data: []byte("a")}, {delay: time.Hour, data: []byte("b")}, {delay: 200 * time.Millisecond, data: []byte("c")}}
+
+	var out bytes.Buffer
+	start := time.Now()
+	if err := playFrames(context.Background(), &out, frames, 4, 100*time.Millisecond); err != nil {
+		t.Fatalf("playFrames: %v", err)
+	}
+	// the hour is capped to 100ms and 200ms is played in 50ms
+	if elapsed := time.Since(start); out.String() != "abc" || elapsed < 50*time.Millisecond || elapsed > 5*time.Second {
+		t.Fatalf("played %q in %v", out.String(), elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out.Reset()
+	if err := playFrames(ctx, &out, frames[1:], 1, 0); err == nil || out.Len() != 0 {
+		t.Fatalf("playFrames after cancel = %v, wrote %q", err, out.String())
+	}
+}
+
+func TestWriteRecordings(t *testing.T) {
+	var out bytes.Buffer
+	err := writeRecordings(&out, []libadmin.AggregatedRecording{{
+		InstanceID: "piper-a",
+		Recording:  &libadmin.Recording{Path: "s1/shell-channel-0.cast", Format: "asciicast", SessionId: "s1", StartedAt: 1700000000, ModifiedAt: 1700000090, Size: 42},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "INSTANCE  PATH                     FORMAT     SESSION ID  USER  STARTED               DURATION  SIZE\n" +
+		"piper-a   s1/shell-channel-0.cast  asciicast  s1          -     2023-11-14T22:13:20Z  1m30s     42\n"
+	if out.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -37,6 +37,8 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/tg123/sshpiper/cmd/internal/admindiscovery"
+	"github.com/tg123/sshpiper/libadmin"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/term"
@@ -161,7 +163,7 @@ func inheritedGlobalArgs(ctx *cli.Context) []string {
 	if ctx.IsSet("log-level") {
 		out = append(out, "--log-level", ctx.String("log-level"))
 	}
-	return out
+	return append(out, admindiscovery.Args(ctx)...)
 }
 
 // loadOrGenerateHostKey returns a signer for `path` if it points at a
@@ -252,13 +254,17 @@ func (c *authorizedKeysChecker) reload() error {
 	return nil
 }
 
+// keyFingerprintExtension is the ssh.Permissions extension holding the
+// fingerprint of the authorized key of a connection.
+const keyFingerprintExtension = "key-fingerprint"
+
 func (c *authorizedKeysChecker) callback(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
 	marshaled := key.Marshal()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	for _, k := range c.keys {
 		if subtle.ConstantTimeCompare(k, marshaled) == 1 {
-			return nil, nil
+			return &ssh.Permissions{Extensions: map[string]string{keyFingerprintExtension: ssh.FingerprintSHA256(key)}}, nil
 		}
 	}
 	return nil, fmt.Errorf("public key not authorized")
@@ -279,6 +285,13 @@ func handleConn(parent *cli.Context, c net.Conn, cfg *ssh.ServerConfig, inherite
 
 	go ssh.DiscardRequests(reqs)
 
+	// tell sshpiperd whose key the commands of this connection run for, to
+	// record in its audit log
+	ctx := parent.Context
+	if sconn.Permissions != nil && sconn.Permissions.Extensions[keyFingerprintExtension] != "" {
+		ctx = libadmin.WithOnBehalfOf(ctx, sconn.User()+" "+sconn.Permissions.Extensions[keyFingerprintExtension])
+	}
+
 	for newCh := range chans {
 		if newCh.ChannelType() != "session" {
 			_ = newCh.Reject(ssh.UnknownChannelType, "only session channels are supported")
@@ -289,14 +302,14 @@ func handleConn(parent *cli.Context, c net.Conn, cfg *ssh.ServerConfig, inherite
 			slog.Warn("serve: accept channel failed", "error", err)
 			continue
 		}
-		go handleSession(parent, ch, chReqs, inherited)
+		go handleSession(ctx, ch, chReqs, inherited)
 	}
 }
 
 // handleSession dispatches a single SSH "session" channel: an `exec`
 // request runs the parsed command once and exits; a `shell` request
 // drops the user into a small line-based REPL.
-func handleSession(parent *cli.Context, ch ssh.Channel, reqs <-chan *ssh.Request, inherited []string) {
+func handleSession(ctx context.Context, ch ssh.Channel, reqs <-chan *ssh.Request, inherited []string) {
 	defer ch.Close()
 
 	hasPTY := false
@@ -316,14 +329,14 @@ func handleSession(parent *cli.Context, ch ssh.Channel, reqs <-chan *ssh.Request
 			if req.WantReply {
 				_ = req.Reply(true, nil)
 			}
-			status := runRemoteCommand(parent, ch, inherited, cmd, hasPTY)
+			status := runRemoteCommand(ctx, ch, inherited, cmd, hasPTY)
 			sendExitStatus(ch, status)
 			return
 		case "shell":
 			if req.WantReply {
 				_ = req.Reply(true, nil)
 			}
-			runRemoteShell(parent, ch, inherited, hasPTY)
+			runRemoteShell(ctx, ch, inherited, hasPTY)
 			sendExitStatus(ch, 0)
 			return
 		default:
@@ -353,7 +366,7 @@ func parseStringPayload(p []byte) string {
 // list`) the channel is in raw mode, so LF bytes are translated to
 // CRLF the same way as the interactive shell to avoid the staircase
 // effect. Returns the SSH exit status to send back to the client.
-func runRemoteCommand(parent *cli.Context, ch ssh.Channel, inherited []string, cmd string, hasPTY bool) uint32 {
+func runRemoteCommand(ctx context.Context, ch ssh.Channel, inherited []string, cmd string, hasPTY bool) uint32 {
 	args, err := splitArgs(cmd)
 	if err != nil {
 		fmt.Fprintf(ch.Stderr(), "sshpiperd-admin: %v\n", err)
@@ -371,13 +384,13 @@ func runRemoteCommand(parent *cli.Context, ch ssh.Channel, inherited []string, c
 		stdout = &crlfWriter{w: ch}
 		stderr = &crlfWriter{w: ch.Stderr()}
 	}
-	return runSubApp(parent.Context, stdout, stderr, inherited, args)
+	return runSubApp(ctx, stdout, stderr, inherited, args)
 }
 
 // runRemoteShell runs an interactive REPL on the SSH channel. Each line
 // is split with `splitArgs` and dispatched to a fresh sub-app, exactly
 // as if the user had run `ssh host <line>`.
-func runRemoteShell(parent *cli.Context, ch ssh.Channel, inherited []string, hasPTY bool) {
+func runRemoteShell(ctx context.Context, ch ssh.Channel, inherited []string, hasPTY bool) {
 	const banner = "sshpiperd-admin: type 'help' for commands, 'exit' to quit\n"
 
 	// When the client allocated a PTY the channel is in raw mode, so
@@ -422,7 +435,7 @@ func runRemoteShell(parent *cli.Context, ch ssh.Channel, inherited []string, has
 			if err != nil {
 				return
 			}
-			if !runShellLine(parent, out, errOut, inherited, line, mux) {
+			if !runShellLine(ctx, out, errOut, inherited, line, mux) {
 				return
 			}
 		}
@@ -442,7 +455,7 @@ func runRemoteShell(parent *cli.Context, ch ssh.Channel, inherited []string, has
 				}
 				line := strings.TrimRight(string(buf[:idx]), "\r")
 				buf = buf[idx+1:]
-				if !runShellLine(parent, out, errOut, inherited, line, nil) {
+				if !runShellLine(ctx, out, errOut, inherited, line, nil) {
 					return
 				}
 			}
@@ -572,7 +585,7 @@ func bytesIndex(b []byte, c byte) int {
 // the sub-app runs under a cancellable context registered with the mux
 // so that a Ctrl-C from the operator interrupts a long-running command
 // (e.g. `stream <id>`).
-func runShellLine(parent *cli.Context, out, errOut io.Writer, inherited []string, line string, mux *inputMux) bool {
+func runShellLine(ctx context.Context, out, errOut io.Writer, inherited []string, line string, mux *inputMux) bool {
 	line = strings.TrimSpace(line)
 	if line == "" {
 		return true
@@ -584,7 +597,7 @@ func runShellLine(parent *cli.Context, out, errOut io.Writer, inherited []string
 		// Defer to the CLI app's built-in help so the REPL output stays
 		// in sync with the actual command surface (subcommands, flags,
 		// descriptions, examples).
-		_ = runSubApp(parent.Context, out, errOut, inherited, []string{"help"})
+		_ = runSubApp(ctx, out, errOut, inherited, []string{"help"})
 		fmt.Fprintln(out, "REPL commands: help, exit (or quit). Anything else is parsed as a sshpiperd-admin subcommand.")
 		return true
 	}
@@ -594,13 +607,13 @@ func runShellLine(parent *cli.Context, out, errOut io.Writer, inherited []string
 		return true
 	}
 
-	ctx, cancel := context.WithCancel(parent.Context)
+	cmdCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	if mux != nil {
 		mux.setCancel(cancel)
 		defer mux.clearCancel()
 	}
-	_ = runSubApp(ctx, out, errOut, inherited, args)
+	_ = runSubApp(cmdCtx, out, errOut, inherited, args)
 	return true
 }
 
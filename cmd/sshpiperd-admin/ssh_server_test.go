@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/tg123/sshpiper/libadmin"
 	"github.com/urfave/cli/v2"
@@ -124,8 +125,11 @@ func TestAuthorizedKeysChecker_LoadsKeysAndSkipsCommentsAndBlankLines(t *testing
 	}
 
 	for _, k := range []ssh.PublicKey{pub1, pub2} {
-		if _, err := c.callback(nil, k); err != nil {
+		perms, err := c.callback(nil, k)
+		if err != nil {
 			t.Errorf("expected key to be authorized: %v", err)
+		} else if got := perms.Extensions[keyFingerprintExtension]; got != ssh.FingerprintSHA256(k) {
+			t.Errorf("fingerprint extension = %q, want %q", got, ssh.FingerprintSHA256(k))
 		}
 	}
 
@@ -197,6 +201,9 @@ func TestInheritedGlobalArgsOnlyEmitsExplicitFlags(t *testing.T) {
 			"--insecure=false",
 			"--timeout", "30s",
 			"--log-level", "debug",
+			"--discovery-srv", "_admin._tcp.a",
+			"--discovery-srv", "_admin._tcp.b",
+			"--discovery-kubernetes-selector", "app=sshpiperd,tier=edge",
 		}
 		if !reflect.DeepEqual(got, want) {
 			t.Errorf("inheritedGlobalArgs: got %v, want %v", got, want)
@@ -209,6 +216,9 @@ func TestInheritedGlobalArgsOnlyEmitsExplicitFlags(t *testing.T) {
 		"--insecure=false",
 		"--timeout", "30s",
 		"--log-level", "debug",
+		"--discovery-srv", "_admin._tcp.a",
+		"--discovery-srv", "_admin._tcp.b",
+		"--discovery-kubernetes-selector", "app=sshpiperd,tier=edge",
 	}
 	if err := app2.Run(args); err != nil {
 		t.Fatalf("run: %v", err)
@@ -268,3 +278,73 @@ func TestStreamHandlerAsciicastSingleHeaderAndChannelFilter(t *testing.T) {
 		t.Errorf("dropped-channel event should not be emitted: %q", buf.String())
 	}
 }
+
+func TestWriteSessionDetail(t *testing.T) {
+	var buf strings.Builder
+	err := writeSessionDetail(&buf, "inst", &libadmin.GetSessionResponse{
+		Session:              &libadmin.Session{Id: "s1", DownstreamUser: "alice", DownstreamAddr: "10.0.0.1:5000"},
+		ClientVersion:        "SSH-2.0-OpenSSH_9.6",
+		DownstreamAlgorithms: &libadmin.Algorithms{Kex: "curve25519-sha256", CipherIn: "aes128-ctr", CipherOut: "aes128-ctr"},
+		Channels: []*libadmin.Channel{
+			{Id: 0, Type: "session", Request: "exec", Command: "uptime -p", Width: 80, Height: 24, UpstreamBytes: 12},
+			{Id: 1, Type: "direct-tcpip"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"alice@10.0.0.1:5000  SSH-2.0-OpenSSH_9.6",
+		"kex=curve25519-sha256",
+		"Upstream algorithms:    -",
+		`exec     "uptime -p"  80x24`,
+		"1        direct-tcpip  -        -",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHistory(t *testing.T) {
+	var buf strings.Builder
+	err := writeHistory(&buf, []libadmin.AggregatedHistoryEntry{
+		{InstanceID: "inst", Entry: &libadmin.SessionHistoryEntry{
+			Session:     &libadmin.Session{Id: "s1", DownstreamUser: "alice", UpstreamAddr: "10.0.0.2:22", StartedAt: 1700000000, DownstreamBytes: 5, UpstreamBytes: 7},
+			EndedAt:     1700000090,
+			CloseReason: "killed by admin: bye",
+			Commands:    []string{"uptime -p", "id"},
+		}},
+		{InstanceID: "inst", Entry: &libadmin.SessionHistoryEntry{Session: &libadmin.Session{Id: "s2"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"2023-11-14T22:13:20Z  1m30s",
+		`5/7           killed by admin: bye  "uptime -p" "id"`,
+		"0/0           -                     -",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for value, want := range map[string]int64{
+		"":                     0,
+		"24h":                  1700000000 - 24*3600,
+		"2023-11-14T00:00:00Z": 1699920000,
+	} {
+		if got, err := parseHistoryTime(value, now); err != nil || got != want {
+			t.Errorf("parseHistoryTime(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	if _, err := parseHistoryTime("yesterday", now); err == nil {
+		t.Error("parseHistoryTime(yesterday) should fail")
+	}
+}
@@ -17,7 +17,8 @@ import (
 // background refresh loop.
 type Aggregator struct {
 	*libadmin.Aggregator
-	interval time.Duration
+	discovery libadmin.Discovery
+	interval  time.Duration
 
 	cancelMu sync.Mutex
 	cancel   context.CancelFunc
@@ -32,12 +33,14 @@ func New(d libadmin.Discovery, opts libadmin.DialOptions, interval time.Duration
 	}
 	return &Aggregator{
 		Aggregator: libadmin.NewAggregator(d, opts),
+		discovery:  d,
 		interval:   interval,
 	}
 }
 
 // StartBackgroundRefresh kicks off a goroutine that periodically calls
-// Refresh. It is safe to call multiple times: extra calls are no-ops.
+// Refresh, and as soon as a libadmin.DiscoveryWatcher reports a change. It
+// is safe to call multiple times: extra calls are no-ops.
 func (a *Aggregator) StartBackgroundRefresh() {
 	a.cancelMu.Lock()
 	defer a.cancelMu.Unlock()
@@ -52,19 +55,29 @@ func (a *Aggregator) StartBackgroundRefresh() {
 func (a *Aggregator) loop(ctx context.Context) {
 	t := time.NewTicker(a.interval)
 	defer t.Stop()
+	var changes <-chan struct{}
+	if w, ok := a.discovery.(libadmin.DiscoveryWatcher); ok {
+		changes = w.Watch(ctx)
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			slog.Debug("sshpiperd endpoints changed, refreshing")
 		case <-t.C:
-			rctx, cancel := context.WithTimeout(ctx, a.interval)
-			if _, errs := a.Refresh(rctx); len(errs) > 0 {
-				for _, err := range errs {
-					slog.Debug("aggregator refresh failed", "error", err)
-				}
+		}
+		rctx, cancel := context.WithTimeout(ctx, a.interval)
+		if _, errs := a.Refresh(rctx); len(errs) > 0 {
+			for _, err := range errs {
+				slog.Debug("aggregator refresh failed", "error", err)
 			}
-			cancel()
 		}
+		cancel()
 	}
 }
 
@@ -4,27 +4,43 @@ package httpapi
 
 import (
 	"context"
+	"crypto/rand"
 	"embed"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tg123/sshpiper/cmd/sshpiperd-webadmin/internal/aggregator"
 	"github.com/tg123/sshpiper/libadmin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Options configures the HTTP handler.
 type Options struct {
-	// AllowKill controls whether DELETE /api/v1/sessions/... is allowed.
+	// AllowKill controls whether DELETE /api/v1/sessions[/...] is allowed.
 	// Set to false for read-only deployments.
 	AllowKill bool
+	// AllowMessage controls whether POST /api/v1/sessions/.../message is
+	// allowed, writing a message to the terminal of the user.
+	AllowMessage bool
+	// AllowPause controls whether POST /api/v1/sessions/.../pause and
+	// .../resume are allowed, holding back the input of the user.
+	AllowPause bool
+	// AllowAttach controls whether /api/v1/sessions/.../attach is allowed,
+	// letting the UI type into live sessions. sshpiperd must allow it too.
+	AllowAttach bool
 	// Version is reported by /api/v1/version.
 	Version string
 	// StaticPath chooses where the browser UI is served from:
@@ -45,13 +61,19 @@ var webFS embed.FS
 // New returns an http.Handler exposing the admin API and embedded UI.
 func New(agg *aggregator.Aggregator, opts Options) http.Handler {
 	mux := http.NewServeMux()
-	h := &handler{agg: agg, opts: opts}
+	h := &handler{agg: agg, opts: opts, hub: newSessionHub(agg), attachments: make(map[string]*attachment)}
 
 	mux.HandleFunc("/api/v1/version", h.version)
 	mux.HandleFunc("/api/v1/instances", h.instances)
 	mux.HandleFunc("/api/v1/sessions", h.sessions)
-	// /api/v1/sessions/{instance}/{id}                — DELETE
+	mux.HandleFunc("/api/v1/sessions/watch", h.watchSessions)
+	mux.HandleFunc("/api/v1/history", h.history)
+	mux.HandleFunc("/api/v1/recordings", h.recordings)
+	// /api/v1/recordings/{instance}/{path...}       — GET the file
+	mux.HandleFunc("/api/v1/recordings/", h.recordingFile)
+	// /api/v1/sessions/{instance}/{id}                — GET, DELETE
 	// /api/v1/sessions/{instance}/{id}/stream         — GET (SSE)
+	// /api/v1/sessions/{instance}/{id}/attach         — GET (SSE), POST input
 	mux.HandleFunc("/api/v1/sessions/", h.sessionByID)
 
 	switch opts.StaticPath {
@@ -120,6 +142,21 @@ func (n noListing) Open(name string) (http.File, error) {
 type handler struct {
 	agg  *aggregator.Aggregator
 	opts Options
+	hub  *sessionHub
+
+	mu sync.Mutex
+	// attachments are the open attach SSE responses, by token.
+	attachments map[string]*attachment
+}
+
+// attachment is an AttachSession stream opened by an attach SSE response,
+// which the input POSTed with its token is sent on.
+type attachment struct {
+	instance string
+	id       string
+
+	mu     sync.Mutex
+	stream libadmin.SshPiperAdmin_AttachSessionClient
 }
 
 func writeJSON(w http.ResponseWriter, status int, body any) {
@@ -135,8 +172,11 @@ func writeError(w http.ResponseWriter, status int, msg string) {
 
 func (h *handler) version(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{
-		"version":    h.opts.Version,
-		"allow_kill": h.opts.AllowKill,
+		"version":       h.opts.Version,
+		"allow_kill":    h.opts.AllowKill,
+		"allow_attach":  h.opts.AllowAttach,
+		"allow_message": h.opts.AllowMessage,
+		"allow_pause":   h.opts.AllowPause,
 	})
 }
 
@@ -146,6 +186,10 @@ type instanceJSON struct {
 	Version   string `json:"version,omitempty"`
 	SSHAddr   string `json:"ssh_addr,omitempty"`
 	StartedAt int64  `json:"started_at,omitempty"`
+	// Role and AllowedRPCs are what the instance lets this webadmin do,
+	// see --admin-grpc-policy-file.
+	Role        string   `json:"role,omitempty"`
+	AllowedRPCs []string `json:"allowed_rpcs,omitempty"`
 }
 
 func (h *handler) instances(w http.ResponseWriter, r *http.Request) {
@@ -161,55 +205,216 @@ func (h *handler) instances(w http.ResponseWriter, r *http.Request) {
 			Version:   info.Info.GetVersion(),
 			SSHAddr:   info.Info.GetSshAddr(),
 			StartedAt: info.Info.GetStartedAt(),
+
+			Role:        info.Info.GetRole(),
+			AllowedRPCs: info.Info.GetAllowedRpcs(),
 		})
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"instances": out})
 }
 
 type sessionJSON struct {
-	InstanceID     string `json:"instance_id"`
-	InstanceAddr   string `json:"instance_addr"`
-	ID             string `json:"id"`
-	DownstreamUser string `json:"downstream_user"`
-	DownstreamAddr string `json:"downstream_addr"`
-	UpstreamUser   string `json:"upstream_user"`
-	UpstreamAddr   string `json:"upstream_addr"`
-	StartedAt      int64  `json:"started_at"`
-	Streamable     bool   `json:"streamable"`
+	InstanceID      string            `json:"instance_id"`
+	InstanceAddr    string            `json:"instance_addr"`
+	ID              string            `json:"id"`
+	DownstreamUser  string            `json:"downstream_user"`
+	DownstreamAddr  string            `json:"downstream_addr"`
+	UpstreamUser    string            `json:"upstream_user"`
+	UpstreamAddr    string            `json:"upstream_addr"`
+	StartedAt       int64             `json:"started_at"`
+	Streamable      bool              `json:"streamable"`
+	Paused          bool              `json:"paused"`
+	Channels        uint32            `json:"channels"`
+	DownstreamBytes uint64            `json:"downstream_bytes"`
+	UpstreamBytes   uint64            `json:"upstream_bytes"`
+	Labels          map[string]string `json:"labels,omitempty"`
 }
 
+// sessions lists the sessions, only those with every label=key=value query
+// parameter when given.
 func (h *handler) sessions(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		h.killSessions(w, r)
+		return
+	default:
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	selector, err := libadmin.ParseLabelSelector(r.URL.Query()["label"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 	all, errs := h.agg.ListAllSessions(ctx)
 	out := make([]sessionJSON, 0, len(all))
 	for _, s := range all {
-		out = append(out, sessionJSON{
-			InstanceID:     s.InstanceID,
-			InstanceAddr:   s.InstanceAddr,
-			ID:             s.Session.GetId(),
-			DownstreamUser: s.Session.GetDownstreamUser(),
-			DownstreamAddr: s.Session.GetDownstreamAddr(),
-			UpstreamUser:   s.Session.GetUpstreamUser(),
-			UpstreamAddr:   s.Session.GetUpstreamAddr(),
-			StartedAt:      s.Session.GetStartedAt(),
-			Streamable:     s.Session.GetStreamable(),
-		})
+		if !libadmin.MatchLabels(s.Session.GetLabels(), selector) {
+			continue
+		}
+		out = append(out, toSessionJSON(s))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sessions": out,
+		"errors":   errorStrings(errs),
+	})
+}
+
+// killSessions kills the sessions on every instance matching the user,
+// upstream, cidr and label query parameters, telling their clients the
+// reason parameter.
+func (h *handler) killSessions(w http.ResponseWriter, r *http.Request) {
+	if !h.opts.AllowKill {
+		writeError(w, http.StatusForbidden, "kill is disabled on this server (--allow-kill=false)")
+		return
+	}
+	q := r.URL.Query()
+	selector, err := libadmin.ParseLabelSelector(q["label"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	errMsgs := make([]string, 0, len(errs))
+	req := &libadmin.KillSessionsRequest{
+		DownstreamUser: q.Get("user"),
+		UpstreamAddr:   q.Get("upstream"),
+		DownstreamCidr: q.Get("cidr"),
+		Labels:         selector,
+		Reason:         q.Get("reason"),
+	}
+	if req.DownstreamUser == "" && req.UpstreamAddr == "" && req.DownstreamCidr == "" && len(selector) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one of user, upstream, cidr or label is required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	killed, errs := h.agg.KillSessions(ctx, req)
+	out := make([]sessionJSON, 0, len(killed))
+	for _, s := range killed {
+		out = append(out, toSessionJSON(s))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"killed": out,
+		"errors": errorStrings(errs),
+	})
+}
+
+func toSessionJSON(s libadmin.AggregatedSession) sessionJSON {
+	return sessionJSON{
+		InstanceID:      s.InstanceID,
+		InstanceAddr:    s.InstanceAddr,
+		ID:              s.Session.GetId(),
+		DownstreamUser:  s.Session.GetDownstreamUser(),
+		DownstreamAddr:  s.Session.GetDownstreamAddr(),
+		UpstreamUser:    s.Session.GetUpstreamUser(),
+		UpstreamAddr:    s.Session.GetUpstreamAddr(),
+		StartedAt:       s.Session.GetStartedAt(),
+		Streamable:      s.Session.GetStreamable(),
+		Paused:          s.Session.GetPaused(),
+		Channels:        s.Session.GetChannels(),
+		DownstreamBytes: s.Session.GetDownstreamBytes(),
+		UpstreamBytes:   s.Session.GetUpstreamBytes(),
+		Labels:          s.Session.GetLabels(),
+	}
+}
+
+type historyJSON struct {
+	sessionJSON
+	EndedAt        int64            `json:"ended_at"`
+	CloseReason    string           `json:"close_reason,omitempty"`
+	Commands       []string         `json:"commands,omitempty"`
+	ChannelTypes   map[string]int64 `json:"channel_types,omitempty"`
+	ExitStatuses   []uint32         `json:"exit_statuses,omitempty"`
+	RecordingFiles []string         `json:"recording_files,omitempty"`
+	ClientVersion  string           `json:"client_version,omitempty"`
+	ServerVersion  string           `json:"server_version,omitempty"`
+
+	// DroppedCommands and DroppedExitStatuses are the number of commands
+	// and exit statuses left out of Commands and ExitStatuses.
+	DroppedCommands     int64 `json:"dropped_commands,omitempty"`
+	DroppedExitStatuses int64 `json:"dropped_exit_statuses,omitempty"`
+}
+
+// history lists a page of the finished sessions matching the user,
+// upstream and label query parameters, open between since and until (unix
+// seconds), at most limit of them, after page_token.
+func (h *handler) history(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	selector, err := libadmin.ParseLabelSelector(q["label"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req := &libadmin.ListSessionHistoryRequest{
+		DownstreamUser: q.Get("user"),
+		UpstreamAddr:   q.Get("upstream"),
+		Labels:         selector,
+		PageToken:      q.Get("page_token"),
+	}
+	for name, dst := range map[string]*int64{"since": &req.Since, "until": &req.Until} {
+		if v := q.Get(name); v != "" {
+			if *dst, err = strconv.ParseInt(v, 10, 64); err != nil || *dst < 0 {
+				writeError(w, http.StatusBadRequest, name+" must be a unix time")
+				return
+			}
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		req.PageSize = int32(limit)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	entries, next, errs := h.agg.ListSessionHistory(ctx, req)
 	for _, e := range errs {
-		errMsgs = append(errMsgs, e.Error())
+		if errors.Is(e, libadmin.ErrBadHistoryPageToken) {
+			writeError(w, http.StatusBadRequest, e.Error())
+			return
+		}
+	}
+	out := make([]historyJSON, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, historyJSON{
+			sessionJSON:    toSessionJSON(libadmin.AggregatedSession{InstanceID: e.InstanceID, InstanceAddr: e.InstanceAddr, Session: e.Entry.GetSession()}),
+			EndedAt:        e.Entry.GetEndedAt(),
+			CloseReason:    e.Entry.GetCloseReason(),
+			Commands:       e.Entry.GetCommands(),
+			ChannelTypes:   e.Entry.GetChannels(),
+			ExitStatuses:   e.Entry.GetExitStatuses(),
+			RecordingFiles: e.Entry.GetRecordingFiles(),
+
+			DroppedCommands:     e.Entry.GetDroppedCommands(),
+			DroppedExitStatuses: e.Entry.GetDroppedExitStatuses(),
+			ClientVersion:       e.Entry.GetClientVersion(),
+			ServerVersion:       e.Entry.GetServerVersion(),
+		})
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
-		"sessions": out,
-		"errors":   errMsgs,
+		"sessions":        out,
+		"next_page_token": next,
+		"errors":          errorStrings(errs),
 	})
 }
 
+func errorStrings(errs []error) []string {
+	out := make([]string, 0, len(errs))
+	for _, e := range errs {
+		out = append(out, e.Error())
+	}
+	return out
+}
+
 // parseSessionPath splits "/api/v1/sessions/{instance}/{id}[/stream]" into
 // its components. The input must be the raw (still percent-encoded) path so
 // that instance IDs containing "/" (e.g. "host/[::]:2222") survive the split;
@@ -255,22 +460,132 @@ func (h *handler) sessionByID(w http.ResponseWriter, r *http.Request) {
 	}
 	switch action {
 	case "":
-		if r.Method == http.MethodDelete {
+		switch r.Method {
+		case http.MethodGet:
+			h.getSession(w, r, instance, id)
+		case http.MethodDelete:
 			h.killSession(w, r, instance, id)
-			return
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		}
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 	case "stream":
 		if r.Method != http.MethodGet {
 			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 			return
 		}
 		h.streamSession(w, r, instance, id)
+	case "message", "pause", "resume":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if action == "message" {
+			h.messageSession(w, r, instance, id)
+		} else {
+			h.pauseSession(w, r, instance, id, action == "pause")
+		}
+	case "attach":
+		switch r.Method {
+		case http.MethodGet:
+			h.attachSession(w, r, instance, id)
+		case http.MethodPost:
+			h.attachInput(w, r, instance, id)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
 	default:
 		writeError(w, http.StatusNotFound, "not found")
 	}
 }
 
+// sessionDetailJSON is a session with its open channels, algorithms and
+// versions.
+type sessionDetailJSON struct {
+	sessionJSON
+	LastActivityAt       int64          `json:"last_activity_at"`
+	ClientVersion        string         `json:"client_version"`
+	ServerVersion        string         `json:"server_version"`
+	DownstreamAlgorithms algorithmsJSON `json:"downstream_algorithms"`
+	UpstreamAlgorithms   algorithmsJSON `json:"upstream_algorithms"`
+	OpenChannels         []channelJSON  `json:"open_channels"`
+}
+
+type algorithmsJSON struct {
+	Kex       string `json:"kex"`
+	HostKey   string `json:"host_key"`
+	CipherIn  string `json:"cipher_in"`
+	CipherOut string `json:"cipher_out"`
+	MACIn     string `json:"mac_in"`
+	MACOut    string `json:"mac_out"`
+}
+
+type channelJSON struct {
+	ID              uint32 `json:"id"`
+	Type            string `json:"type"`
+	Request         string `json:"request"`
+	Command         string `json:"command"`
+	Width           uint32 `json:"width"`
+	Height          uint32 `json:"height"`
+	StartedAt       int64  `json:"started_at"`
+	DownstreamBytes uint64 `json:"downstream_bytes"`
+	UpstreamBytes   uint64 `json:"upstream_bytes"`
+}
+
+func toAlgorithmsJSON(a *libadmin.Algorithms) algorithmsJSON {
+	return algorithmsJSON{
+		Kex:       a.GetKex(),
+		HostKey:   a.GetHostKey(),
+		CipherIn:  a.GetCipherIn(),
+		CipherOut: a.GetCipherOut(),
+		MACIn:     a.GetMacIn(),
+		MACOut:    a.GetMacOut(),
+	}
+}
+
+// getSession returns the session with its channels, algorithms and
+// versions, 404 when the instance does not know it.
+func (h *handler) getSession(w http.ResponseWriter, r *http.Request, instance, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	detail, err := h.agg.GetSession(ctx, instance, id)
+	if status.Code(err) == codes.NotFound {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	var addr string
+	if info, ok := h.agg.Instances()[instance]; ok {
+		addr = info.Addr
+	}
+	out := sessionDetailJSON{
+		sessionJSON:          toSessionJSON(libadmin.AggregatedSession{InstanceID: instance, InstanceAddr: addr, Session: detail.GetSession()}),
+		LastActivityAt:       detail.GetLastActivityAt(),
+		ClientVersion:        detail.GetClientVersion(),
+		ServerVersion:        detail.GetServerVersion(),
+		DownstreamAlgorithms: toAlgorithmsJSON(detail.GetDownstreamAlgorithms()),
+		UpstreamAlgorithms:   toAlgorithmsJSON(detail.GetUpstreamAlgorithms()),
+		OpenChannels:         make([]channelJSON, 0, len(detail.GetChannels())),
+	}
+	for _, c := range detail.GetChannels() {
+		out.OpenChannels = append(out.OpenChannels, channelJSON{
+			ID:              c.GetId(),
+			Type:            c.GetType(),
+			Request:         c.GetRequest(),
+			Command:         c.GetCommand(),
+			Width:           c.GetWidth(),
+			Height:          c.GetHeight(),
+			StartedAt:       c.GetStartedAt(),
+			DownstreamBytes: c.GetDownstreamBytes(),
+			UpstreamBytes:   c.GetUpstreamBytes(),
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
 func (h *handler) killSession(w http.ResponseWriter, r *http.Request, instance, id string) {
 	if !h.opts.AllowKill {
 		writeError(w, http.StatusForbidden, "kill is disabled on this server (--allow-kill=false)")
@@ -278,7 +593,7 @@ func (h *handler) killSession(w http.ResponseWriter, r *http.Request, instance,
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
-	killed, err := h.agg.KillSession(ctx, instance, id)
+	killed, err := h.agg.KillSessionWithReason(ctx, instance, id, r.URL.Query().Get("reason"))
 	if err != nil {
 		writeError(w, http.StatusBadGateway, err.Error())
 		return
@@ -286,14 +601,63 @@ func (h *handler) killSession(w http.ResponseWriter, r *http.Request, instance,
 	writeJSON(w, http.StatusOK, map[string]any{"killed": killed})
 }
 
-// streamSession opens an SSE response and forwards admin frames as they
-// arrive. Each event is named after the frame kind ("header", "o", "i",
-// "r") and carries a JSON payload.
-func (h *handler) streamSession(w http.ResponseWriter, r *http.Request, instance, id string) {
+// messageSession writes the "message" of the JSON request body to the
+// terminal of the user.
+func (h *handler) messageSession(w http.ResponseWriter, r *http.Request, instance, id string) {
+	if !h.opts.AllowMessage {
+		writeError(w, http.StatusForbidden, "message is disabled on this server (--allow-message=false)")
+		return
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	channels, err := h.agg.MessageSession(ctx, instance, id, body.Message)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"channels": channels})
+}
+
+// pauseSession pauses the input of the user, or resumes it when pause is
+// false.
+func (h *handler) pauseSession(w http.ResponseWriter, r *http.Request, instance, id string, pause bool) {
+	if !h.opts.AllowPause {
+		writeError(w, http.StatusForbidden, "pause is disabled on this server (--allow-pause=false)")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if pause {
+		paused, err := h.agg.PauseSession(ctx, instance, id)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"paused": paused})
+		return
+	}
+	resumed, err := h.agg.ResumeSession(ctx, instance, id)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"resumed": resumed})
+}
+
+// startSSE starts an SSE response and returns a func sending one event
+// with a JSON payload, or false after writing an error.
+func startSSE(w http.ResponseWriter) (func(event string, payload any) error, bool) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		writeError(w, http.StatusInternalServerError, "streaming unsupported")
-		return
+		return nil, false
 	}
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -302,7 +666,7 @@ func (h *handler) streamSession(w http.ResponseWriter, r *http.Request, instance
 	w.WriteHeader(http.StatusOK)
 	flusher.Flush()
 
-	send := func(event string, payload any) error {
+	return func(event string, payload any) error {
 		b, err := json.Marshal(payload)
 		if err != nil {
 			return err
@@ -312,28 +676,139 @@ func (h *handler) streamSession(w http.ResponseWriter, r *http.Request, instance
 		}
 		flusher.Flush()
 		return nil
+	}, true
+}
+
+// sendFrame sends an admin frame as an SSE event named after the frame
+// kind ("header", "o", "i", "r").
+func sendFrame(send func(event string, payload any) error, frame *libadmin.SessionFrame) error {
+	if hdr := frame.GetHeader(); hdr != nil {
+		return send("header", map[string]any{
+			"width":      hdr.GetWidth(),
+			"height":     hdr.GetHeight(),
+			"timestamp":  hdr.GetTimestamp(),
+			"env":        hdr.GetEnv(),
+			"channel_id": hdr.GetChannelId(),
+		})
+	}
+	if ev := frame.GetEvent(); ev != nil {
+		return send(ev.GetKind(), map[string]any{
+			"data":       base64.StdEncoding.EncodeToString(ev.GetData()),
+			"channel_id": ev.GetChannelId(),
+		})
+	}
+	return nil
+}
+
+// streamSession opens an SSE response and forwards admin frames as they
+// arrive. Each event is named after the frame kind ("header", "o", "i",
+// "r") and carries a JSON payload.
+func (h *handler) streamSession(w http.ResponseWriter, r *http.Request, instance, id string) {
+	send, ok := startSSE(w)
+	if !ok {
+		return
 	}
 
 	err := h.agg.StreamSession(r.Context(), instance, id, true, func(frame *libadmin.SessionFrame) error {
-		if hdr := frame.GetHeader(); hdr != nil {
-			return send("header", map[string]any{
-				"width":      hdr.GetWidth(),
-				"height":     hdr.GetHeight(),
-				"timestamp":  hdr.GetTimestamp(),
-				"env":        hdr.GetEnv(),
-				"channel_id": hdr.GetChannelId(),
-			})
-		}
-		if ev := frame.GetEvent(); ev != nil {
-			return send(ev.GetKind(), map[string]any{
-				"data":       base64.StdEncoding.EncodeToString(ev.GetData()),
-				"channel_id": ev.GetChannelId(),
-			})
-		}
-		return nil
+		return sendFrame(send, frame)
 	})
 	if err != nil && r.Context().Err() == nil {
 		slog.Debug("stream ended", "instance", instance, "id", id, "error", err)
 		_ = send("error", map[string]string{"error": err.Error()})
 	}
 }
+
+// attachSession attaches read-write to the session, the channel query
+// parameter choosing the channel and name the name shown to the user. The
+// SSE response starts with an "attached" event carrying the token to POST
+// input with, followed by the frames of the channel like streamSession.
+func (h *handler) attachSession(w http.ResponseWriter, r *http.Request, instance, id string) {
+	if !h.opts.AllowAttach {
+		writeError(w, http.StatusForbidden, "attach is disabled on this server (--allow-attach=false)")
+		return
+	}
+
+	req := &libadmin.AttachSessionRequest{Id: id, Name: r.URL.Query().Get("name"), Replay: true}
+	if req.Name == "" {
+		req.Name = "webadmin"
+	}
+	if c := r.URL.Query().Get("channel"); c != "" {
+		channel, err := strconv.ParseUint(c, 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid channel %q", c))
+			return
+		}
+		ch := uint32(channel)
+		req.ChannelId = &ch
+	}
+
+	stream, err := h.agg.AttachSession(r.Context(), instance, req)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	token := rand.Text()
+	h.mu.Lock()
+	h.attachments[token] = &attachment{instance: instance, id: id, stream: stream}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.attachments, token)
+		h.mu.Unlock()
+	}()
+
+	send, ok := startSSE(w)
+	if !ok {
+		return
+	}
+	if err := send("attached", map[string]string{"token": token}); err != nil {
+		return
+	}
+
+	for {
+		frame, err := stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && r.Context().Err() == nil {
+				slog.Debug("attach ended", "instance", instance, "id", id, "error", err)
+				_ = send("error", map[string]string{"error": err.Error()})
+			}
+			return
+		}
+		if err := sendFrame(send, frame); err != nil {
+			return
+		}
+	}
+}
+
+// attachInput sends the request body as input to the attachment with the
+// token query parameter.
+func (h *handler) attachInput(w http.ResponseWriter, r *http.Request, instance, id string) {
+	if !h.opts.AllowAttach {
+		writeError(w, http.StatusForbidden, "attach is disabled on this server (--allow-attach=false)")
+		return
+	}
+
+	h.mu.Lock()
+	a, ok := h.attachments[r.URL.Query().Get("token")]
+	h.mu.Unlock()
+	if !ok || a.instance != instance || a.id != id {
+		writeError(w, http.StatusNotFound, "attachment not found")
+		return
+	}
+
+	input, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64<<10))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.mu.Lock()
+	err = a.stream.Send(&libadmin.AttachSessionRequest{Input: input})
+	a.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
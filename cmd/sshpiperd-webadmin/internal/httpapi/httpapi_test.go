@@ -1,19 +1,25 @@
 package httpapi
 
 import (
+	"bufio"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/tg123/sshpiper/cmd/sshpiperd-webadmin/internal/aggregator"
 	"github.com/tg123/sshpiper/libadmin"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // stub mirrors libadmin/aggregator_test.go's stub but lives here to avoid a
@@ -22,23 +28,156 @@ type stub struct {
 	libadmin.UnimplementedSshPiperAdminServer
 	id       string
 	sessions []*libadmin.Session
+	role     string
+	rpcs     []string
 }
 
 func (s *stub) ServerInfo(_ context.Context, _ *libadmin.ServerInfoRequest) (*libadmin.ServerInfoResponse, error) {
-	return &libadmin.ServerInfoResponse{Id: s.id, Version: "stub"}, nil
+	return &libadmin.ServerInfoResponse{Id: s.id, Version: "stub", Role: s.role, AllowedRpcs: s.rpcs}, nil
 }
 
 func (s *stub) ListSessions(_ context.Context, _ *libadmin.ListSessionsRequest) (*libadmin.ListSessionsResponse, error) {
 	return &libadmin.ListSessionsResponse{Sessions: s.sessions}, nil
 }
 
+func (s *stub) GetSession(_ context.Context, req *libadmin.GetSessionRequest) (*libadmin.GetSessionResponse, error) {
+	for _, sess := range s.sessions {
+		if sess.GetId() == req.GetId() {
+			return &libadmin.GetSessionResponse{
+				Session:       sess,
+				ClientVersion: "SSH-2.0-client",
+				Channels:      []*libadmin.Channel{{Id: 0, Type: "session", Request: "exec", Command: "uptime"}},
+			}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "session %q not found", req.GetId())
+}
+
+// ListSessionHistory lists the sessions of the requested user as ended a
+// minute after they started, in one page.
+func (s *stub) ListSessionHistory(_ context.Context, req *libadmin.ListSessionHistoryRequest) (*libadmin.ListSessionHistoryResponse, error) {
+	resp := &libadmin.ListSessionHistoryResponse{}
+	for _, sess := range s.sessions {
+		if req.GetDownstreamUser() == "" || sess.GetDownstreamUser() == req.GetDownstreamUser() {
+			resp.Sessions = append(resp.Sessions, &libadmin.SessionHistoryEntry{
+				Session:     sess,
+				EndedAt:     sess.GetStartedAt() + 60,
+				CloseReason: "killed by admin",
+				Commands:    []string{"uptime"},
+				Cursor:      sess.GetId(),
+			})
+		}
+	}
+	return resp, nil
+}
+
+// ListRecordings lists a recording per session of the requested user.
+func (s *stub) ListRecordings(_ context.Context, req *libadmin.ListRecordingsRequest) (*libadmin.ListRecordingsResponse, error) {
+	resp := &libadmin.ListRecordingsResponse{}
+	for _, sess := range s.sessions {
+		if req.GetDownstreamUser() == "" || sess.GetDownstreamUser() == req.GetDownstreamUser() {
+			resp.Recordings = append(resp.Recordings, &libadmin.Recording{
+				Path:           sess.GetId() + "/shell-channel-0.cast",
+				Format:         "asciicast",
+				SessionId:      sess.GetId(),
+				DownstreamUser: sess.GetDownstreamUser(),
+				StartedAt:      sess.GetStartedAt(),
+				ModifiedAt:     sess.GetStartedAt() + 60,
+			})
+		}
+	}
+	return resp, nil
+}
+
+// GetRecording sends the path of the recordings of ListRecordings as their
+// content.
+func (s *stub) GetRecording(req *libadmin.GetRecordingRequest, stream libadmin.SshPiperAdmin_GetRecordingServer) error {
+	for _, sess := range s.sessions {
+		if req.GetPath() == sess.GetId()+"/shell-channel-0.cast" {
+			return stream.Send(&libadmin.RecordingChunk{Data: []byte(req.GetPath())})
+		}
+	}
+	return status.Errorf(codes.NotFound, "recording %q not found", req.GetPath())
+}
+
 func (s *stub) KillSession(_ context.Context, req *libadmin.KillSessionRequest) (*libadmin.KillSessionResponse, error) {
-	return &libadmin.KillSessionResponse{Killed: req.GetId() == "k"}, nil
+	return &libadmin.KillSessionResponse{Killed: req.GetId() == "k" && req.GetReason() != "wrong"}, nil
+}
+
+func (s *stub) KillSessions(_ context.Context, req *libadmin.KillSessionsRequest) (*libadmin.KillSessionsResponse, error) {
+	var out []*libadmin.Session
+	for _, sess := range s.sessions {
+		if sess.GetDownstreamUser() == req.GetDownstreamUser() && libadmin.MatchLabels(sess.GetLabels(), req.GetLabels()) {
+			out = append(out, sess)
+		}
+	}
+	return &libadmin.KillSessionsResponse{Sessions: out}, nil
+}
+
+func (s *stub) MessageSession(_ context.Context, req *libadmin.MessageSessionRequest) (*libadmin.MessageSessionResponse, error) {
+	return &libadmin.MessageSessionResponse{Channels: int32(len(req.GetMessage()))}, nil //nolint:gosec // test message
+}
+
+func (s *stub) PauseSession(_ context.Context, req *libadmin.PauseSessionRequest) (*libadmin.PauseSessionResponse, error) {
+	return &libadmin.PauseSessionResponse{Paused: req.GetId() == "k"}, nil
+}
+
+func (s *stub) ResumeSession(_ context.Context, req *libadmin.ResumeSessionRequest) (*libadmin.ResumeSessionResponse, error) {
+	return &libadmin.ResumeSessionResponse{Resumed: req.GetId() == "k"}, nil
+}
+
+// WatchSessions adds the sessions, syncs, then pauses them.
+func (s *stub) WatchSessions(_ *libadmin.WatchSessionsRequest, stream libadmin.SshPiperAdmin_WatchSessionsServer) error {
+	for _, sess := range s.sessions {
+		if err := stream.Send(&libadmin.SessionEvent{Type: libadmin.SessionEventType_SESSION_ADDED, Session: sess}); err != nil {
+			return err
+		}
+	}
+	if err := stream.Send(&libadmin.SessionEvent{Type: libadmin.SessionEventType_SESSION_SYNCED}); err != nil {
+		return err
+	}
+	for _, sess := range s.sessions {
+		paused := &libadmin.Session{Id: sess.GetId(), Labels: sess.GetLabels(), Paused: true, Channels: 2}
+		if err := stream.Send(&libadmin.SessionEvent{Type: libadmin.SessionEventType_SESSION_UPDATED, Session: paused}); err != nil {
+			return err
+		}
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+// AttachSession echoes the input of the attachment as output, after a
+// header carrying the requested name in its env.
+func (s *stub) AttachSession(stream libadmin.SshPiperAdmin_AttachSessionServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&libadmin.SessionFrame{Frame: &libadmin.SessionFrame_Header{Header: &libadmin.AsciicastHeader{
+		Width: 80, Height: 24, ChannelId: req.GetChannelId(), Env: map[string]string{"name": req.GetName()},
+	}}}); err != nil {
+		return err
+	}
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		if err := stream.Send(&libadmin.SessionFrame{Frame: &libadmin.SessionFrame_Event{Event: &libadmin.AsciicastEvent{
+			Kind: "o", Data: req.GetInput(),
+		}}}); err != nil {
+			return err
+		}
+	}
 }
 
 func startStub(t *testing.T, id string, sessions []*libadmin.Session) string {
 	t.Helper()
-	s := &stub{id: id, sessions: sessions}
+	return serveStub(t, &stub{id: id, sessions: sessions})
+}
+
+func serveStub(t *testing.T, s *stub) string {
+	t.Helper()
 	gs := grpc.NewServer()
 	libadmin.RegisterSshPiperAdminServer(gs, s)
 	lis, err := net.Listen("tcp", "127.0.0.1:0")
@@ -62,7 +201,10 @@ func newAgg(t *testing.T, addrs ...string) *aggregator.Aggregator {
 }
 
 func TestHTTP_SessionsAndKill(t *testing.T) {
-	addr := startStub(t, "i1", []*libadmin.Session{{Id: "s1", DownstreamUser: "u"}})
+	addr := startStub(t, "i1", []*libadmin.Session{
+		{Id: "s1", DownstreamUser: "u"},
+		{Id: "s2", DownstreamUser: "u", Labels: map[string]string{"team": "db"}},
+	})
 	a := newAgg(t, addr)
 	h := New(a, Options{AllowKill: true, Version: "v"})
 
@@ -79,10 +221,28 @@ func TestHTTP_SessionsAndKill(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &listResp); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
-	if len(listResp.Sessions) != 1 || listResp.Sessions[0].ID != "s1" || listResp.Sessions[0].InstanceID != "i1" {
+	if len(listResp.Sessions) != 2 || listResp.Sessions[0].ID != "s1" || listResp.Sessions[0].InstanceID != "i1" {
 		t.Fatalf("unexpected sessions: %+v", listResp.Sessions)
 	}
 
+	// label selector
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/sessions?label=team%3Ddb", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if err := json.Unmarshal(w.Body.Bytes(), &listResp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(listResp.Sessions) != 1 || listResp.Sessions[0].ID != "s2" || listResp.Sessions[0].Labels["team"] != "db" {
+		t.Fatalf("unexpected sessions for label selector: %+v", listResp.Sessions)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/sessions?label=team", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid label selector: status %d", w.Code)
+	}
+
 	// DELETE /api/v1/sessions/i1/k → killed=true
 	r = httptest.NewRequest(http.MethodDelete, "/api/v1/sessions/i1/k", nil)
 	w = httptest.NewRecorder()
@@ -95,6 +255,35 @@ func TestHTTP_SessionsAndKill(t *testing.T) {
 	if !killResp.Killed {
 		t.Fatalf("kill body: %s", w.Body.String())
 	}
+
+	// the reason is passed on
+	r = httptest.NewRequest(http.MethodDelete, "/api/v1/sessions/i1/k?reason=wrong", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), `"killed":false`) {
+		t.Fatalf("kill with reason body: %s", w.Body.String())
+	}
+
+	// DELETE /api/v1/sessions?user=u&label=team%3Ddb → s2
+	r = httptest.NewRequest(http.MethodDelete, "/api/v1/sessions?user=u&label=team%3Ddb&reason=bye", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var bulkResp struct {
+		Killed []sessionJSON `json:"killed"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &bulkResp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("bulk kill: status %d, body=%s", w.Code, w.Body.String())
+	}
+	if len(bulkResp.Killed) != 1 || bulkResp.Killed[0].ID != "s2" || bulkResp.Killed[0].InstanceID != "i1" {
+		t.Fatalf("unexpected killed sessions: %+v", bulkResp.Killed)
+	}
+
+	r = httptest.NewRequest(http.MethodDelete, "/api/v1/sessions?reason=bye", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bulk kill without selector: status %d", w.Code)
+	}
 }
 
 func TestHTTP_KillForbiddenWhenReadonly(t *testing.T) {
@@ -102,11 +291,221 @@ func TestHTTP_KillForbiddenWhenReadonly(t *testing.T) {
 	a := newAgg(t, addr)
 	h := New(a, Options{AllowKill: false, Version: "v"})
 
-	r := httptest.NewRequest(http.MethodDelete, "/api/v1/sessions/i1/x", nil)
+	for _, path := range []string{"/api/v1/sessions/i1/x", "/api/v1/sessions?user=u"} {
+		r := httptest.NewRequest(http.MethodDelete, path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("DELETE %s: status %d, want 403", path, w.Code)
+		}
+	}
+}
+
+func TestHTTP_MessageAndPause(t *testing.T) {
+	addr := startStub(t, "i1", nil)
+	a := newAgg(t, addr)
+	h := New(a, Options{AllowMessage: true, AllowPause: true, Version: "v"})
+
+	cases := []struct {
+		method, path, body, want string
+	}{
+		{http.MethodPost, "/api/v1/sessions/i1/k/message", `{"message":"bye"}`, `{"channels":3}`},
+		{http.MethodPost, "/api/v1/sessions/i1/k/pause", "", `{"paused":true}`},
+		{http.MethodPost, "/api/v1/sessions/i1/x/pause", "", `{"paused":false}`},
+		{http.MethodPost, "/api/v1/sessions/i1/k/resume", "", `{"resumed":true}`},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != c.want {
+			t.Fatalf("%s %s: status %d, body=%s, want %s", c.method, c.path, w.Code, w.Body.String(), c.want)
+		}
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/sessions/i1/k/message": http.StatusBadRequest,
+		"/api/v1/sessions/i1/k/resume":  http.StatusOK,
+	} {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader("not json"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Fatalf("POST %s: status %d, want %d", path, w.Code, want)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/i1/k/pause", nil)
 	w := httptest.NewRecorder()
 	h.ServeHTTP(w, r)
-	if w.Code != http.StatusForbidden {
-		t.Fatalf("status %d, want 403", w.Code)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET pause: status %d, want 405", w.Code)
+	}
+
+	readonly := New(a, Options{Version: "v"})
+	for _, action := range []string{"message", "pause", "resume"} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/i1/k/"+action, strings.NewReader(`{"message":"bye"}`))
+		w := httptest.NewRecorder()
+		readonly.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%s when disabled: status %d, want 403", action, w.Code)
+		}
+	}
+}
+
+func TestHTTP_GetSession(t *testing.T) {
+	addr := startStub(t, "i1", []*libadmin.Session{{Id: "s1", DownstreamUser: "alice"}})
+	h := New(newAgg(t, addr), Options{Version: "v"})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/i1/s1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body=%s", w.Code, w.Body.String())
+	}
+	var got sessionDetailJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.InstanceID != "i1" || got.InstanceAddr != addr || got.DownstreamUser != "alice" || got.ClientVersion != "SSH-2.0-client" {
+		t.Fatalf("unexpected session %+v", got)
+	}
+	if len(got.OpenChannels) != 1 || got.OpenChannels[0].Command != "uptime" {
+		t.Fatalf("unexpected channels %+v", got.OpenChannels)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/sessions/i1/missing": http.StatusNotFound,
+		"/api/v1/sessions/i9/s1":      http.StatusBadGateway,
+	} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Fatalf("GET %s: status %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestHTTP_History(t *testing.T) {
+	addr := startStub(t, "i1", []*libadmin.Session{
+		{Id: "s1", DownstreamUser: "alice", StartedAt: 100},
+		{Id: "s2", DownstreamUser: "bob", StartedAt: 200},
+	})
+	h := New(newAgg(t, addr), Options{Version: "v"})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/history?user=alice&since=50&limit=10", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body=%s", w.Code, w.Body.String())
+	}
+	var got struct {
+		Sessions      []historyJSON `json:"sessions"`
+		NextPageToken string        `json:"next_page_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Sessions) != 1 || got.NextPageToken != "" {
+		t.Fatalf("unexpected history %+v", got)
+	}
+	if e := got.Sessions[0]; e.InstanceID != "i1" || e.ID != "s1" || e.EndedAt != 160 || e.CloseReason != "killed by admin" || len(e.Commands) != 1 {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+
+	for _, query := range []string{"since=yesterday", "limit=0", "page_token=!", "label=nokey"} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/history?"+query, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("GET ?%s: status %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestHTTP_Recordings(t *testing.T) {
+	addr := startStub(t, "i1", []*libadmin.Session{
+		{Id: "s1", DownstreamUser: "alice", StartedAt: 100},
+		{Id: "s2", DownstreamUser: "bob", StartedAt: 200},
+	})
+	h := New(newAgg(t, addr), Options{Version: "v"})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/recordings?user=alice&since=50", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body=%s", w.Code, w.Body.String())
+	}
+	var got struct {
+		Recordings    []recordingJSON `json:"recordings"`
+		NextPageToken string          `json:"next_page_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Recordings) != 1 || got.NextPageToken != "" {
+		t.Fatalf("unexpected recordings %+v", got)
+	}
+	if rec := got.Recordings[0]; rec.InstanceID != "i1" || rec.Path != "s1/shell-channel-0.cast" || rec.SessionID != "s1" || rec.ModifiedAt != 160 {
+		t.Fatalf("unexpected recording %+v", rec)
+	}
+
+	for _, query := range []string{"until=soon", "limit=0", "page_token=!"} {
+		r = httptest.NewRequest(http.MethodGet, "/api/v1/recordings?"+query, nil)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("GET ?%s: status %d, want 400", query, w.Code)
+		}
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/recordings/i1/s1/shell-channel-0.cast":   http.StatusOK,
+		"/api/v1/recordings/i1/s1%2Fshell-channel-0.cast": http.StatusOK,
+		"/api/v1/recordings/i1/s3/shell-channel-0.cast":   http.StatusNotFound,
+		"/api/v1/recordings/i1":                           http.StatusNotFound,
+		"/api/v1/recordings/nope/s1/shell-channel-0.cast": http.StatusBadGateway,
+	} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Fatalf("GET %s: status %d, want %d, body=%s", target, w.Code, want, w.Body.String())
+		}
+		if want == http.StatusOK && w.Body.String() != "s1/shell-channel-0.cast" {
+			t.Fatalf("GET %s: body %q", target, w.Body.String())
+		}
+	}
+}
+
+func TestHTTP_InstanceRole(t *testing.T) {
+	addr := serveStub(t, &stub{id: "i1", role: "viewer", rpcs: []string{"ServerInfo", "ListSessions"}})
+	h := New(newAgg(t, addr, startStub(t, "i2", nil)), Options{Version: "v"})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/instances", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var got struct {
+		Instances []instanceJSON `json:"instances"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Instances) != 2 {
+		t.Fatalf("instances = %+v", got.Instances)
+	}
+	for _, i := range got.Instances {
+		switch i.ID {
+		case "i1":
+			if i.Role != "viewer" || len(i.AllowedRPCs) != 2 {
+				t.Fatalf("i1 = %+v", i)
+			}
+		case "i2":
+			if i.Role != "" || len(i.AllowedRPCs) != 0 {
+				t.Fatalf("i2 = %+v", i)
+			}
+		}
 	}
 }
 
@@ -225,3 +624,157 @@ func TestStaticPath_DirectoryListingIsDisabled(t *testing.T) {
 		t.Fatalf("GET / = %d, want 200 (index.html should be served)", w.Code)
 	}
 }
+
+func TestHTTP_Attach(t *testing.T) {
+	addr := startStub(t, "i1", nil)
+	a := newAgg(t, addr)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/i1/s1/attach", nil)
+	w := httptest.NewRecorder()
+	New(a, Options{Version: "v"}).ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 by default, got %d", w.Code)
+	}
+
+	srv := httptest.NewServer(New(a, Options{AllowAttach: true, Version: "v"}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/sessions/i1/s1/attach?channel=x")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid channel: status %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/sessions/i1/s1/attach?channel=3&name=alice", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	events := bufio.NewScanner(resp.Body)
+	next := func(want string) map[string]any {
+		t.Helper()
+		var event string
+		for events.Scan() {
+			line := events.Text()
+			if e, ok := strings.CutPrefix(line, "event: "); ok {
+				event = e
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				if event != want {
+					t.Fatalf("got %s event %s, want %s", event, data, want)
+				}
+				var payload map[string]any
+				if err := json.Unmarshal([]byte(data), &payload); err != nil {
+					t.Fatalf("decode %s: %v", data, err)
+				}
+				return payload
+			}
+		}
+		t.Fatalf("stream ended before %s: %v", want, events.Err())
+		return nil
+	}
+
+	token, _ := next("attached")["token"].(string)
+	header := next("header")
+	if header["channel_id"] != float64(3) || header["env"].(map[string]any)["name"] != "alice" {
+		t.Fatalf("unexpected header %v", header)
+	}
+
+	input := func(path, token string) int {
+		t.Helper()
+		resp, err := http.Post(srv.URL+path+"?token="+url.QueryEscape(token), "application/octet-stream", strings.NewReader("ls\r"))
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := input("/api/v1/sessions/i1/s1/attach", "bogus"); code != http.StatusNotFound {
+		t.Fatalf("unknown token: status %d", code)
+	}
+	if code := input("/api/v1/sessions/i1/s2/attach", token); code != http.StatusNotFound {
+		t.Fatalf("token of another session: status %d", code)
+	}
+	if code := input("/api/v1/sessions/i1/s1/attach", token); code != http.StatusNoContent {
+		t.Fatalf("input: status %d", code)
+	}
+
+	if out := next("o"); out["data"] != base64.StdEncoding.EncodeToString([]byte("ls\r")) {
+		t.Fatalf("unexpected output %v", out)
+	}
+}
+
+func TestHTTP_WatchSessions(t *testing.T) {
+	addr := startStub(t, "i1", []*libadmin.Session{
+		{Id: "s1"},
+		{Id: "s2", Labels: map[string]string{"team": "db"}},
+	})
+	srv := httptest.NewServer(New(newAgg(t, addr), Options{Version: "v"}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	watch := func() (*bufio.Scanner, func()) {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/sessions/watch?label=team%3Ddb", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+		return bufio.NewScanner(resp.Body), func() { _ = resp.Body.Close() }
+	}
+	next := func(events *bufio.Scanner) (string, map[string]any) {
+		t.Helper()
+		var event string
+		for events.Scan() {
+			line := events.Text()
+			if e, ok := strings.CutPrefix(line, "event: "); ok {
+				event = e
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var payload map[string]any
+				if err := json.Unmarshal([]byte(data), &payload); err != nil {
+					t.Fatalf("decode %s: %v", data, err)
+				}
+				return event, payload
+			}
+		}
+		t.Fatalf("stream ended: %v", events.Err())
+		return "", nil
+	}
+
+	events, closeWatch := watch()
+	defer closeWatch()
+	if event, _ := next(events); event != "snapshot" {
+		t.Fatalf("first event %s, want snapshot", event)
+	}
+	for {
+		event, payload := next(events)
+		if payload["id"] != "s2" {
+			t.Fatalf("unexpected %s event %v, only s2 has the label", event, payload)
+		}
+		if event == "updated" && payload["paused"] == true {
+			break
+		}
+	}
+
+	// a second browser gets the current state right away
+	second, closeSecond := watch()
+	defer closeSecond()
+	event, payload := next(second)
+	sessions, _ := payload["sessions"].([]any)
+	if event != "snapshot" || len(sessions) != 1 || sessions[0].(map[string]any)["paused"] != true || sessions[0].(map[string]any)["channels"] != float64(2) {
+		t.Fatalf("unexpected %s event %v", event, payload)
+	}
+}
@@ -0,0 +1,166 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingJSON is a screen recording, see libadmin.Recording.
+type recordingJSON struct {
+	InstanceID     string `json:"instance_id"`
+	InstanceAddr   string `json:"instance_addr"`
+	Path           string `json:"path"`
+	Format         string `json:"format"`
+	SessionID      string `json:"session_id"`
+	DownstreamUser string `json:"downstream_user"`
+	StartedAt      int64  `json:"started_at"`
+	ModifiedAt     int64  `json:"modified_at"`
+	Size           uint64 `json:"size"`
+	TimingPath     string `json:"timing_path,omitempty"`
+}
+
+// recordings lists a page of the recordings of every instance matching the
+// session, user, since and until query parameters, at most limit of them,
+// after page_token.
+func (h *handler) recordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	req := &libadmin.ListRecordingsRequest{
+		SessionId:      q.Get("session"),
+		DownstreamUser: q.Get("user"),
+		PageToken:      q.Get("page_token"),
+	}
+	for name, dst := range map[string]*int64{"since": &req.Since, "until": &req.Until} {
+		if v := q.Get(name); v != "" {
+			var err error
+			if *dst, err = strconv.ParseInt(v, 10, 64); err != nil || *dst < 0 {
+				writeError(w, http.StatusBadRequest, name+" must be a unix time")
+				return
+			}
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		req.PageSize = int32(limit)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	recordings, next, errs := h.agg.ListRecordings(ctx, req)
+	for _, e := range errs {
+		if errors.Is(e, libadmin.ErrBadRecordingsPageToken) {
+			writeError(w, http.StatusBadRequest, e.Error())
+			return
+		}
+	}
+	out := make([]recordingJSON, 0, len(recordings))
+	for _, rec := range recordings {
+		out = append(out, recordingJSON{
+			InstanceID:     rec.InstanceID,
+			InstanceAddr:   rec.InstanceAddr,
+			Path:           rec.Recording.GetPath(),
+			Format:         rec.Recording.GetFormat(),
+			SessionID:      rec.Recording.GetSessionId(),
+			DownstreamUser: rec.Recording.GetDownstreamUser(),
+			StartedAt:      rec.Recording.GetStartedAt(),
+			ModifiedAt:     rec.Recording.GetModifiedAt(),
+			Size:           rec.Recording.GetSize(),
+			TimingPath:     rec.Recording.GetTimingPath(),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"recordings":      out,
+		"next_page_token": next,
+		"errors":          errorStrings(errs),
+	})
+}
+
+// parseRecordingPath splits "/api/v1/recordings/{instance}/{path...}" into
+// the percent-decoded instance and recording path, see parseSessionPath.
+func parseRecordingPath(escapedPath string) (instance, path string, ok bool) {
+	const prefix = "/api/v1/recordings/"
+	if !strings.HasPrefix(escapedPath, prefix) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(escapedPath, prefix), "/")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	for i, p := range parts {
+		u, err := url.PathUnescape(p)
+		if p == "" || err != nil {
+			return "", "", false
+		}
+		parts[i] = u
+	}
+	return parts[0], strings.Join(parts[1:], "/"), true
+}
+
+// recordingFile sends a file listed by recordings, the recording or the
+// timing file of a typescript, as it is.
+func (h *handler) recordingFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	instance, path, ok := parseRecordingPath(r.URL.EscapedPath())
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	// a recording may be large, so it is streamed and the status sent with
+	// its first chunk
+	out := &lazyHeaderWriter{w: w}
+	err := h.agg.GetRecording(r.Context(), instance, path, out)
+	switch {
+	case err != nil && out.started:
+		slog.Warn("recording download failed", "instance", instance, "path", path, "error", err)
+	case status.Code(err) == codes.NotFound:
+		writeError(w, http.StatusNotFound, err.Error())
+	case status.Code(err) == codes.InvalidArgument:
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+	case !out.started:
+		out.start()
+	}
+}
+
+// lazyHeaderWriter writes the headers of a recording with its first byte.
+type lazyHeaderWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (l *lazyHeaderWriter) start() {
+	l.started = true
+	l.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	l.w.Header().Set("Cache-Control", "no-store")
+	l.w.Header().Set("X-Content-Type-Options", "nosniff")
+	l.w.WriteHeader(http.StatusOK)
+}
+
+func (l *lazyHeaderWriter) Write(p []byte) (int, error) {
+	if !l.started {
+		l.start()
+	}
+	return l.w.Write(p)
+}
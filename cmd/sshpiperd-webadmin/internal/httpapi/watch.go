@@ -0,0 +1,201 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tg123/sshpiper/cmd/sshpiperd-webadmin/internal/aggregator"
+	"github.com/tg123/sshpiper/libadmin"
+)
+
+const (
+	// watchInterval is how often the instances refresh the counters of the
+	// watched sessions.
+	watchInterval = 5 * time.Second
+	// watchBuffer is how many events a browser may lag behind before it is
+	// dropped, and reconnects for a new snapshot.
+	watchBuffer = 256
+)
+
+// hubEvent is an SSE event for the browsers watching the sessions.
+type hubEvent struct {
+	name    string
+	session *sessionJSON
+	errors  []string
+}
+
+// sessionHub watches the sessions of every instance while at least one
+// browser watches, keeping their current state for the snapshot of new
+// browsers.
+type sessionHub struct {
+	agg *aggregator.Aggregator
+
+	mu sync.Mutex
+	// gen tells the events of the current watch from those of a stopped
+	// one still in flight.
+	gen      int
+	cancel   context.CancelFunc
+	sessions map[string]sessionJSON
+	errors   map[string]string
+	subs     map[chan hubEvent]struct{}
+}
+
+func newSessionHub(agg *aggregator.Aggregator) *sessionHub {
+	return &sessionHub{agg: agg, subs: make(map[chan hubEvent]struct{})}
+}
+
+// subscribe returns the current sessions and instance errors, and the
+// events after them until cancel is called. The channel is closed when the
+// subscriber lags behind.
+func (hub *sessionHub) subscribe() ([]sessionJSON, []string, <-chan hubEvent, func()) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if len(hub.subs) == 0 {
+		hub.gen++
+		hub.sessions = make(map[string]sessionJSON)
+		hub.errors = make(map[string]string)
+		ctx, cancel := context.WithCancel(context.Background())
+		hub.cancel = cancel
+		gen := hub.gen
+		go func() {
+			_ = hub.agg.WatchAllSessions(ctx, watchInterval, func(ev libadmin.AggregatedSessionEvent) {
+				hub.handle(gen, ev)
+			})
+		}()
+	}
+
+	sessions := make([]sessionJSON, 0, len(hub.sessions))
+	for _, s := range hub.sessions {
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedAt < sessions[j].StartedAt })
+
+	ch := make(chan hubEvent, watchBuffer)
+	hub.subs[ch] = struct{}{}
+	return sessions, hub.errorsLocked(), ch, func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		if _, ok := hub.subs[ch]; ok {
+			delete(hub.subs, ch)
+			close(ch)
+		}
+		if len(hub.subs) == 0 && hub.cancel != nil {
+			hub.cancel()
+			hub.cancel = nil
+		}
+	}
+}
+
+// handle applies an event of the watch gen and sends it to the
+// subscribers.
+func (hub *sessionHub) handle(gen int, ev libadmin.AggregatedSessionEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if gen != hub.gen {
+		return
+	}
+
+	if ev.Err != nil {
+		hub.errors[ev.InstanceID] = ev.Err.Error()
+		hub.broadcastLocked(hubEvent{name: "errors", errors: hub.errorsLocked()})
+		return
+	}
+
+	s := toSessionJSON(libadmin.AggregatedSession{InstanceID: ev.InstanceID, InstanceAddr: ev.InstanceAddr, Session: ev.Session})
+	key := s.InstanceID + "/" + s.ID
+	switch ev.Type {
+	case libadmin.SessionEventType_SESSION_ADDED:
+		hub.sessions[key] = s
+		hub.broadcastLocked(hubEvent{name: "added", session: &s})
+	case libadmin.SessionEventType_SESSION_UPDATED:
+		hub.sessions[key] = s
+		hub.broadcastLocked(hubEvent{name: "updated", session: &s})
+	case libadmin.SessionEventType_SESSION_REMOVED:
+		delete(hub.sessions, key)
+		hub.broadcastLocked(hubEvent{name: "removed", session: &s})
+	case libadmin.SessionEventType_SESSION_SYNCED:
+		if _, ok := hub.errors[ev.InstanceID]; ok {
+			delete(hub.errors, ev.InstanceID)
+			hub.broadcastLocked(hubEvent{name: "errors", errors: hub.errorsLocked()})
+		}
+	}
+}
+
+func (hub *sessionHub) broadcastLocked(ev hubEvent) {
+	for ch := range hub.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(hub.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (hub *sessionHub) errorsLocked() []string {
+	out := make([]string, 0, len(hub.errors))
+	for _, e := range hub.errors {
+		out = append(out, e)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// watchSessions streams the sessions over SSE: a "snapshot" event with the
+// sessions and errors, then "added", "updated" and "removed" events with a
+// session, and "errors" events when the instance errors change. The label
+// query parameters filter the sessions like in sessions.
+func (h *handler) watchSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	selector, err := libadmin.ParseLabelSelector(r.URL.Query()["label"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sessions, errs, events, cancel := h.hub.subscribe()
+	defer cancel()
+
+	send, ok := startSSE(w)
+	if !ok {
+		return
+	}
+
+	matching := make([]sessionJSON, 0, len(sessions))
+	for _, s := range sessions {
+		if libadmin.MatchLabels(s.Labels, selector) {
+			matching = append(matching, s)
+		}
+	}
+	if err := send("snapshot", map[string]any{"sessions": matching, "errors": errs}); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			var payload any = map[string]any{"errors": ev.errors}
+			if ev.session != nil {
+				if !libadmin.MatchLabels(ev.session.Labels, selector) {
+					continue
+				}
+				payload = ev.session
+			}
+			if err := send(ev.name, payload); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 // Vanilla-JS client for the sshpiperd-webadmin HTTP API.
 // Polls /api/v1/sessions and /api/v1/instances, renders sortable tables,
-// and opens a <dialog>-based xterm.js viewer for each session's SSE stream.
+// and opens a <dialog>-based xterm.js viewer for each session's SSE stream,
+// which also types into the session when attached, a details dialog
+// with the channels, algorithms and versions of a session, and a player
+// replaying the screen recordings of past sessions in the same viewer.
 
 import { Terminal } from '@xterm/xterm';
 import { FitAddon } from '@xterm/addon-fit';
@@ -26,16 +29,58 @@ const viewerTitle = $('viewer-title');
 const viewerClose = $('viewer-close');
 const viewerCopy = $('viewer-copy');
 const viewerRecord = $('viewer-record');
+const viewerSpeed = $('viewer-speed');
+const viewerPause = $('viewer-pause');
+
+const details = $('details');
+const detailsTitle = $('details-title');
+const detailsBody = $('details-body');
+const detailsClose = $('details-close');
+
+const historyForm = $('history-form');
+const historyBody = document.querySelector('#history tbody');
+const historyEmpty = $('history-empty');
+const historyMore = $('history-more');
+const historyErrors = $('history-errors');
+
+const recordingsForm = $('recordings-form');
+const recordingsBody = document.querySelector('#recordings tbody');
+const recordingsEmpty = $('recordings-empty');
+const recordingsMore = $('recordings-more');
+const recordingsErrors = $('recordings-errors');
 
 let allowKill = true;
+let allowAttach = false;
+let allowMessage = false;
+let allowPause = false;
 let activeStream = null;
+// Set while attached: the token to POST input with and the xterm onData
+// subscription forwarding the keystrokes.
+let attachToken = null;
+let attachInput = null;
+let attachQueue = Promise.resolve();
 let lastSessions = [];
+// allowed rpcs by instance id, absent for instances without a role policy
+let instanceRPCs = new Map();
 let sessionErrors = [];
 let sortKey = 'started_at';
 let sortDir = 'desc'; // 'asc' | 'desc'
 let filterText = '';
-let autoRefreshTimer = null;
+// The EventSource of /api/v1/sessions/watch while live updates are on.
+let sessionWatch = null;
+let renderPending = false;
 let timestampTicker = null;
+// The session shown in the details dialog and the timer refreshing it.
+let detailsSession = null;
+let detailsTimer = null;
+
+let historyQuery = null;
+let historyNextToken = '';
+let recordingsQuery = null;
+let recordingsNextToken = '';
+
+// The recording replayed in the viewer, see playRecordings.
+let activePlayback = null;
 
 // ---------- helpers ----------
 
@@ -84,6 +129,25 @@ function fmtSince(unixSec) {
   return Math.floor(s / 86400) + 'd';
 }
 
+function fmtDuration(sec) {
+  sec = Math.max(0, sec || 0);
+  if (sec < 60) return sec + 's';
+  if (sec < 3600) return Math.floor(sec / 60) + 'm ' + (sec % 60) + 's';
+  if (sec < 86400) return Math.floor(sec / 3600) + 'h ' + Math.floor((sec % 3600) / 60) + 'm';
+  return Math.floor(sec / 86400) + 'd ' + Math.floor((sec % 86400) / 3600) + 'h';
+}
+
+function fmtBytes(n) {
+  if (!n) return '0';
+  const units = ['B', 'KiB', 'MiB', 'GiB', 'TiB'];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return (i ? n.toFixed(1) : n) + ' ' + units[i];
+}
+
 function showToast(message, kind = 'info', timeout = 4000) {
   const el = document.createElement('div');
   el.className = 'toast' + (kind ? ' ' + kind : '');
@@ -126,6 +190,9 @@ async function loadVersion() {
     const r = await fetch('/api/v1/version');
     const j = await r.json();
     allowKill = !!j.allow_kill;
+    allowAttach = !!j.allow_attach;
+    allowMessage = !!j.allow_message;
+    allowPause = !!j.allow_pause;
     meta.textContent = 'v' + j.version + (allowKill ? '' : ' • read-only');
   } catch (e) {
     meta.textContent = '(version unavailable)';
@@ -138,7 +205,7 @@ async function loadInstances() {
     const r = await fetch('/api/v1/instances');
     payload = await r.json();
   } catch (e) {
-    instancesBody.innerHTML = `<tr><td colspan="6">${escapeHtml(String(e))}</td></tr>`;
+    instancesBody.innerHTML = `<tr><td colspan="7">${escapeHtml(String(e))}</td></tr>`;
     return;
   }
   const list = payload.instances || [];
@@ -149,7 +216,9 @@ async function loadInstances() {
   instancesMeta.textContent = `${list.length} reachable`
     + (degraded.ids.size ? ` • ${degraded.ids.size} degraded` : '');
   instancesBody.innerHTML = '';
+  instanceRPCs = new Map();
   for (const i of list) {
+    if (i.role) instanceRPCs.set(i.id, new Set(i.allowed_rpcs || []));
     const isDegraded = degraded.ids.has(i.id) || degraded.addrs.has(i.addr);
     const tr = document.createElement('tr');
     const idCell = `<code class="copy" data-copy="${escapeHtml(i.id)}" title="copy">${escapeHtml(i.id)}</code>`;
@@ -162,10 +231,21 @@ async function loadInstances() {
       <td>${sshCell}</td>
       <td><span class="mono">${escapeHtml(i.version || '')}</span></td>
       <td data-since="${i.started_at || ''}">${fmtSince(i.started_at)}</td>
+      <td>${i.role
+        ? `<span class="pill label" title="${escapeHtml('allowed: ' + (i.allowed_rpcs || []).join(', '))}">${escapeHtml(i.role)}</span>`
+        : '<span class="muted" title="no role policy, every rpc is allowed">full access</span>'}</td>
       <td><span class="pill ${isDegraded ? 'offline' : 'online'}">${isDegraded ? 'degraded' : 'online'}</span></td>`;
     instancesBody.appendChild(tr);
   }
   updateStats({ instances: list.length, degraded: degraded.ids.size });
+  renderSoon();
+}
+
+// Reports whether the role of this webadmin on the instance of session s
+// allows rpc.
+function can(s, rpc) {
+  const rpcs = instanceRPCs.get(s.instance_id);
+  return !rpcs || rpcs.has(rpc);
 }
 
 // ---------- stat cards ----------
@@ -210,14 +290,27 @@ function setText(el, v) {
 
 // ---------- sessions ----------
 
+function labelPairs(s) {
+  return Object.entries(s.labels || {})
+    .map(([k, v]) => `${k}=${v}`)
+    .sort();
+}
+
+// Words of the filter containing "=" select sessions by label (key=value,
+// exact match), the others are matched against every column.
 function sessionMatches(s, q) {
   if (!q) return true;
+  const pairs = labelPairs(s);
   const hay = [
     s.instance_id, s.id,
     s.downstream_user, s.downstream_addr,
     s.upstream_user, s.upstream_addr,
+    ...pairs,
   ].join(' ').toLowerCase();
-  return hay.includes(q);
+  const lowerPairs = pairs.map((p) => p.toLowerCase());
+  return q.split(/\s+/).every((word) => (
+    word.includes('=') ? lowerPairs.includes(word) : hay.includes(word)
+  ));
 }
 
 function sortValue(s, key) {
@@ -227,6 +320,7 @@ function sortValue(s, key) {
     case 'id': return (s.id || '').toLowerCase();
     case 'downstream': return `${s.downstream_user}@${s.downstream_addr}`.toLowerCase();
     case 'upstream': return `${s.upstream_user}@${s.upstream_addr}`.toLowerCase();
+    case 'traffic': return (s.downstream_bytes || 0) + (s.upstream_bytes || 0);
     default: return 0;
   }
 }
@@ -252,21 +346,55 @@ function renderSessions() {
   sessionsBody.innerHTML = '';
   for (const s of rows) {
     const tr = document.createElement('tr');
-    if (!allowKill) tr.classList.add('kill-disabled');
-    const idCell = `<code class="copy" data-copy="${escapeHtml(s.id)}" title="copy">${escapeHtml(s.id)}</code>`;
+    if (!allowKill || !can(s, 'KillSession')) tr.classList.add('kill-disabled');
+    const roleDenied = 'disabled title="not allowed by the role of this console on the instance"';
+    const canView = s.streamable && can(s, 'StreamSession');
+    const canAttach = allowAttach && can(s, 'AttachSession');
+    const canMessage = allowMessage && can(s, 'MessageSession');
+    const canPause = allowPause && can(s, s.paused ? 'ResumeSession' : 'PauseSession');
+    const idCell = `<code class="copy" data-copy="${escapeHtml(s.id)}" title="copy">${escapeHtml(s.id)}</code>`
+      + (s.paused ? ' <span class="pill paused" title="input is held back">paused</span>' : '');
     const dCell = `<code class="copy" data-copy="${escapeHtml(s.downstream_user + '@' + s.downstream_addr)}" title="copy">${escapeHtml(s.downstream_user)}@${escapeHtml(s.downstream_addr)}</code>`;
     const uCell = `<code class="copy" data-copy="${escapeHtml(s.upstream_user + '@' + s.upstream_addr)}" title="copy">${escapeHtml(s.upstream_user)}@${escapeHtml(s.upstream_addr)}</code>`;
+    const lCell = labelPairs(s)
+      .map((p) => `<span class="pill label" data-label="${escapeHtml(p)}" title="filter by ${escapeHtml(p)}">${escapeHtml(p)}</span>`)
+      .join(' ');
     tr.innerHTML = `<td>${escapeHtml(s.instance_id)}</td>
       <td>${idCell}</td>
       <td data-since="${s.started_at || ''}">${fmtSince(s.started_at)}</td>
       <td>${dCell}</td>
       <td>${uCell}</td>
+      <td class="traffic"><span title="open channels">${s.channels || 0} ch</span>
+        <span title="bytes from the client">↑ ${fmtBytes(s.downstream_bytes)}</span>
+        <span title="bytes from the upstream">↓ ${fmtBytes(s.upstream_bytes)}</span></td>
+      <td>${lCell}</td>
       <td><div class="row-actions">
-        <button class="view btn btn-ghost" type="button" ${s.streamable ? '' : 'disabled title="no active shell channel"'}>view</button>
+        <button class="details btn btn-ghost" type="button" ${can(s, 'GetSession') ? 'title="channels, algorithms and versions"' : roleDenied}>details</button>
+        <button class="view btn btn-ghost" type="button" ${canView ? '' : (s.streamable ? roleDenied : 'disabled title="no active shell channel"')}>view</button>
+        ${canAttach ? `<button class="attach btn btn-ghost" type="button" ${s.streamable ? 'title="type into the session, its user is told"' : 'disabled title="no active shell channel"'}>attach</button>` : ''}
+        ${canMessage ? '<button class="message btn btn-ghost" type="button" title="write a message to the terminal of the user">message</button>' : ''}
+        ${canPause ? `<button class="pause btn btn-ghost" type="button" title="${s.paused ? 'forward the input of the user again' : 'hold back the input of the user'}">${s.paused ? 'resume' : 'pause'}</button>` : ''}
         <button class="kill btn btn-danger" type="button">kill</button>
       </div></td>`;
+    tr.querySelector('button.details').addEventListener('click', () => openDetails(s));
     tr.querySelector('button.view').addEventListener('click', () => openStream(s));
+    if (canAttach) {
+      tr.querySelector('button.attach').addEventListener('click', () => attachSession(s));
+    }
+    if (canMessage) {
+      tr.querySelector('button.message').addEventListener('click', () => messageSession(s));
+    }
+    if (canPause) {
+      tr.querySelector('button.pause').addEventListener('click', () => pauseSession(s, !s.paused));
+    }
     tr.querySelector('button.kill').addEventListener('click', () => killSession(s));
+    for (const pill of tr.querySelectorAll('[data-label]')) {
+      pill.addEventListener('click', () => {
+        filterText = pill.dataset.label;
+        filterInput.value = filterText;
+        renderSessions();
+      });
+    }
     sessionsBody.appendChild(tr);
   }
 
@@ -310,10 +438,12 @@ async function loadSessions() {
 }
 
 async function killSession(s) {
-  if (!confirm(`Kill session ${s.id} on ${s.instance_id}?`)) return;
+  const reason = prompt(`Kill session ${s.id} on ${s.instance_id}?\nReason shown to the user (optional):`, '');
+  if (reason === null) return;
   try {
     const r = await fetch(
-      `/api/v1/sessions/${encodeURIComponent(s.instance_id)}/${encodeURIComponent(s.id)}`,
+      `/api/v1/sessions/${encodeURIComponent(s.instance_id)}/${encodeURIComponent(s.id)}`
+        + (reason ? `?reason=${encodeURIComponent(reason)}` : ''),
       { method: 'DELETE' },
     );
     if (!r.ok) {
@@ -329,6 +459,429 @@ async function killSession(s) {
   }
 }
 
+async function messageSession(s) {
+  const message = prompt(`Message to ${s.downstream_user} on session ${s.id}:`);
+  if (!message) return;
+  try {
+    const r = await fetch(
+      `/api/v1/sessions/${encodeURIComponent(s.instance_id)}/${encodeURIComponent(s.id)}/message`,
+      { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ message }) },
+    );
+    const j = await r.json().catch(() => ({}));
+    if (!r.ok) {
+      showToast('Message failed: ' + (j.error || r.status), 'error');
+      return;
+    }
+    showToast(j.channels ? `Messaged ${s.id}` : `Session ${s.id} has no open channel`, j.channels ? 'success' : 'info');
+  } catch (e) {
+    showToast(String(e), 'error');
+  }
+}
+
+async function pauseSession(s, pause) {
+  const action = pause ? 'pause' : 'resume';
+  try {
+    const r = await fetch(
+      `/api/v1/sessions/${encodeURIComponent(s.instance_id)}/${encodeURIComponent(s.id)}/${action}`,
+      { method: 'POST' },
+    );
+    const j = await r.json().catch(() => ({}));
+    if (!r.ok) {
+      showToast(`${pause ? 'Pause' : 'Resume'} failed: ` + (j.error || r.status), 'error');
+      return;
+    }
+    const ok = pause ? j.paused : j.resumed;
+    showToast(ok ? `${pause ? 'Paused' : 'Resumed'} ${s.id}` : `Session ${s.id} not found`, ok ? 'success' : 'info');
+    loadSessions();
+  } catch (e) {
+    showToast(String(e), 'error');
+  }
+}
+
+// ---------- session details ----------
+
+function sessionPath(s) {
+  return `/api/v1/sessions/${encodeURIComponent(s.instance_id)}/${encodeURIComponent(s.id)}`;
+}
+
+function openDetails(s) {
+  detailsSession = s;
+  detailsTitle.textContent = `${s.instance_id} / ${s.id}`;
+  detailsBody.innerHTML = '<div class="empty">Loading…</div>';
+  if (!details.open) details.showModal();
+  history.replaceState(null, '', '#' + sessionPath(s).replace('/api/v1', ''));
+  loadDetails();
+  clearInterval(detailsTimer);
+  detailsTimer = setInterval(loadDetails, 5000);
+}
+
+async function loadDetails() {
+  const s = detailsSession;
+  if (!s) return;
+  try {
+    const r = await fetch(sessionPath(s));
+    const j = await r.json().catch(() => ({}));
+    if (detailsSession !== s) return;
+    if (!r.ok) {
+      detailsBody.innerHTML = `<div class="errors">${escapeHtml(r.status === 404 ? 'The session ended.' : (j.error || r.status))}</div>`;
+      clearInterval(detailsTimer);
+      return;
+    }
+    renderDetails(j);
+  } catch (e) {
+    detailsBody.innerHTML = `<div class="errors">${escapeHtml(String(e))}</div>`;
+  }
+}
+
+function renderDetails(d) {
+  const when = (t) => (t ? `${new Date(t * 1000).toLocaleString()} (${fmtSince(t)} ago)` : '—');
+  const algs = (a) => {
+    if (!a || !a.kex) return '—';
+    const mac = (m) => m || 'implicit';
+    return `<code>${escapeHtml(a.kex)}</code>, host key <code>${escapeHtml(a.host_key)}</code>,
+      cipher <code>${escapeHtml(a.cipher_in)}</code> / <code>${escapeHtml(a.cipher_out)}</code>,
+      mac <code>${escapeHtml(mac(a.mac_in))}</code> / <code>${escapeHtml(mac(a.mac_out))}</code>`;
+  };
+  const labels = labelPairs(d).map((p) => `<span class="pill label">${escapeHtml(p)}</span>`).join(' ') || '—';
+
+  detailsTitle.textContent = `${d.downstream_user}@${d.downstream_addr} → ${d.upstream_user}@${d.upstream_addr}`;
+  const channels = (d.open_channels || []).map((c) => `<tr>
+      <td>${c.id}</td>
+      <td>${escapeHtml(c.type)}</td>
+      <td>${escapeHtml(c.request || '—')}</td>
+      <td><code>${escapeHtml(c.command || '')}</code></td>
+      <td>${c.width ? `${c.width}×${c.height}` : '—'}</td>
+      <td data-since="${c.started_at || ''}">${fmtSince(c.started_at)}</td>
+      <td class="traffic">↑ ${fmtBytes(c.downstream_bytes)} ↓ ${fmtBytes(c.upstream_bytes)}</td>
+    </tr>`).join('');
+
+  detailsBody.innerHTML = `<dl>
+      <dt>instance</dt><dd><code>${escapeHtml(d.instance_id)}</code> ${escapeHtml(d.instance_addr || '')}</dd>
+      <dt>session</dt><dd><code class="copy" data-copy="${escapeHtml(d.id)}" title="copy">${escapeHtml(d.id)}</code>
+        ${d.paused ? '<span class="pill paused" title="input is held back">paused</span>' : ''}</dd>
+      <dt>client</dt><dd><code>${escapeHtml(d.client_version || '—')}</code></dd>
+      <dt>server</dt><dd><code>${escapeHtml(d.server_version || '—')}</code></dd>
+      <dt>downstream algorithms</dt><dd>${algs(d.downstream_algorithms)}</dd>
+      <dt>upstream algorithms</dt><dd>${algs(d.upstream_algorithms)}</dd>
+      <dt>started</dt><dd>${when(d.started_at)}</dd>
+      <dt>last activity</dt><dd>${when(d.last_activity_at)}</dd>
+      <dt>traffic</dt><dd>↑ ${fmtBytes(d.downstream_bytes)} from the client, ↓ ${fmtBytes(d.upstream_bytes)} from the upstream</dd>
+      <dt>labels</dt><dd>${labels}</dd>
+    </dl>
+    <h3>${(d.open_channels || []).length} open channels</h3>
+    <div class="table-wrap"><table>
+      <thead><tr><th>id</th><th>type</th><th>request</th><th>command</th><th>pty</th><th>since</th><th>traffic</th></tr></thead>
+      <tbody>${channels}</tbody>
+    </table></div>`;
+}
+
+function closeDetails() {
+  clearInterval(detailsTimer);
+  detailsTimer = null;
+  detailsSession = null;
+  if (location.hash) history.replaceState(null, '', location.pathname + location.search);
+}
+
+// Opens the details of the session in the #/sessions/{instance}/{id} hash.
+function openDetailsFromHash() {
+  const m = /^#\/sessions\/([^/]+)\/([^/]+)$/.exec(location.hash);
+  if (!m) return;
+  const instance = decodeURIComponent(m[1]);
+  const id = decodeURIComponent(m[2]);
+  openDetails(lastSessions.find((s) => s.instance_id === instance && s.id === id) || { instance_id: instance, id });
+}
+
+// ---------- session history ----------
+
+// Lists the first page of the finished sessions matching the history form.
+function searchHistory() {
+  const form = new FormData(historyForm);
+  const q = new URLSearchParams();
+  for (const name of ['user', 'upstream', 'label']) {
+    const v = String(form.get(name) || '').trim();
+    if (v) q.set(name, v);
+  }
+  for (const name of ['since', 'until']) {
+    const v = form.get(name);
+    if (v) q.set(name, String(Math.floor(new Date(v).getTime() / 1000)));
+  }
+  historyQuery = q;
+  historyNextToken = '';
+  historyBody.innerHTML = '';
+  loadHistory();
+}
+
+async function loadHistory() {
+  const q = new URLSearchParams(historyQuery);
+  if (historyNextToken) q.set('page_token', historyNextToken);
+  historyMore.disabled = true;
+  try {
+    const r = await fetch('/api/v1/history?' + q);
+    const j = await r.json().catch(() => ({}));
+    if (!r.ok) {
+      historyErrors.textContent = j.error || String(r.status);
+      return;
+    }
+    for (const e of j.sessions || []) historyBody.appendChild(historyRow(e));
+    historyNextToken = j.next_page_token || '';
+    historyErrors.textContent = (j.errors || []).join('\n');
+  } catch (e) {
+    historyErrors.textContent = String(e);
+  } finally {
+    historyMore.disabled = false;
+    historyMore.hidden = !historyNextToken;
+    historyEmpty.style.display = historyBody.children.length ? 'none' : '';
+    historyEmpty.textContent = 'No finished sessions.';
+  }
+}
+
+function historyRow(e) {
+  const tr = document.createElement('tr');
+  let commands = (e.commands || []).map((c) => `<code>${escapeHtml(c)}</code>`).join(' ');
+  if (e.dropped_commands) {
+    commands += ` <span class="muted">+${e.dropped_commands} more</span>`;
+  }
+  const recordings = (e.recording_files || []).join('\n');
+  tr.innerHTML = `<td>${escapeHtml(e.instance_id)}</td>
+    <td><code class="copy" data-copy="${escapeHtml(e.id)}" title="copy">${escapeHtml(e.id)}</code></td>
+    <td title="${escapeHtml(new Date(e.started_at * 1000).toLocaleString())}">${fmtSince(e.started_at)} ago</td>
+    <td title="ended ${escapeHtml(new Date(e.ended_at * 1000).toLocaleString())}">${fmtDuration(e.ended_at - e.started_at)}</td>
+    <td><code>${escapeHtml(e.downstream_user)}@${escapeHtml(e.downstream_addr)}</code></td>
+    <td><code>${escapeHtml(e.upstream_user)}@${escapeHtml(e.upstream_addr)}</code></td>
+    <td class="traffic"><span title="bytes from the client">↑ ${fmtBytes(e.downstream_bytes)}</span>
+      <span title="bytes from the upstream">↓ ${fmtBytes(e.upstream_bytes)}</span></td>
+    <td>${escapeHtml(e.close_reason || '—')}</td>
+    <td ${recordings ? `title="recorded in ${escapeHtml(recordings)}"` : ''}>${commands || '—'}</td>
+    <td>${recordings ? `<button class="play btn btn-ghost" type="button" ${can(e, 'GetRecording') ? 'title="replay its screen recordings"' : 'disabled title="not allowed by the role of this console on the instance"'}>play</button>` : ''}</td>`;
+  const play = tr.querySelector('button.play');
+  if (play) play.addEventListener('click', () => playSession(e));
+  return tr;
+}
+
+// ---------- screen recordings ----------
+
+// Lists the recordings matching the recordings form.
+async function searchRecordings() {
+  const form = new FormData(recordingsForm);
+  const q = new URLSearchParams();
+  for (const name of ['session', 'user']) {
+    const v = String(form.get(name) || '').trim();
+    if (v) q.set(name, v);
+  }
+  for (const name of ['since', 'until']) {
+    const v = form.get(name);
+    if (v) q.set(name, String(Math.floor(new Date(v).getTime() / 1000)));
+  }
+  recordingsQuery = q;
+  recordingsNextToken = '';
+  recordingsBody.innerHTML = '';
+  loadRecordings();
+}
+
+async function loadRecordings() {
+  const q = new URLSearchParams(recordingsQuery);
+  if (recordingsNextToken) q.set('page_token', recordingsNextToken);
+  recordingsMore.disabled = true;
+  try {
+    const j = await fetchRecordings(q);
+    for (const rec of j.recordings || []) recordingsBody.appendChild(recordingRow(rec));
+    recordingsNextToken = j.next_page_token || '';
+    recordingsErrors.textContent = (j.errors || []).join('\n');
+  } catch (e) {
+    recordingsErrors.textContent = String(e.message || e);
+  } finally {
+    recordingsMore.disabled = false;
+    recordingsMore.hidden = !recordingsNextToken;
+    recordingsEmpty.style.display = recordingsBody.children.length ? 'none' : '';
+    recordingsEmpty.textContent = 'No recordings.';
+  }
+}
+
+async function fetchRecordings(q) {
+  const r = await fetch('/api/v1/recordings?' + q);
+  const j = await r.json().catch(() => ({}));
+  if (!r.ok) throw new Error(j.error || String(r.status));
+  return j;
+}
+
+function recordingRow(rec) {
+  const tr = document.createElement('tr');
+  const session = rec.session_id
+    ? `<code class="copy" data-copy="${escapeHtml(rec.session_id)}" title="copy">${escapeHtml(rec.session_id)}</code>`
+    : '—';
+  tr.innerHTML = `<td>${escapeHtml(rec.instance_id)}</td>
+    <td class="path"><code>${escapeHtml(rec.path)}</code></td>
+    <td>${session}</td>
+    <td>${rec.downstream_user ? `<code>${escapeHtml(rec.downstream_user)}</code>` : '—'}</td>
+    <td title="${escapeHtml(new Date(rec.started_at * 1000).toLocaleString())}">${fmtSince(rec.started_at)} ago</td>
+    <td title="last written ${escapeHtml(new Date(rec.modified_at * 1000).toLocaleString())}">${fmtDuration(Math.max(rec.modified_at - rec.started_at, 0))}</td>
+    <td>${fmtBytes(rec.size)}</td>
+    <td><div class="row-actions">
+      <button class="play btn btn-ghost" type="button" ${can(rec, 'GetRecording') ? '' : 'disabled title="not allowed by the role of this console on the instance"'}>play</button>
+    </div></td>`;
+  tr.querySelector('button.play').addEventListener('click', () => playRecordings([rec], `${rec.instance_id} • ${rec.path}`));
+  return tr;
+}
+
+// Replays every recording of the finished session e, oldest first.
+async function playSession(e) {
+  try {
+    const q = new URLSearchParams({ session: e.id, limit: '1000' });
+    let recs = [];
+    for (;;) {
+      const j = await fetchRecordings(q);
+      recs = recs.concat(j.recordings || []);
+      if (!j.next_page_token) break;
+      q.set('page_token', j.next_page_token);
+    }
+    recs = recs.filter((rec) => rec.instance_id === e.instance_id).reverse();
+    if (!recs.length) {
+      showToast(`No recording of ${e.id} left`, 'error');
+      return;
+    }
+    playRecordings(recs, `${e.instance_id} • ${e.id}`);
+  } catch (err) {
+    showToast('Recordings failed: ' + (err.message || err), 'error');
+  }
+}
+
+function recordingFileURL(instance, path) {
+  return `/api/v1/recordings/${encodeURIComponent(instance)}/${path.split('/').map(encodeURIComponent).join('/')}`;
+}
+
+async function fetchRecordingFile(instance, path) {
+  const r = await fetch(recordingFileURL(instance, path));
+  if (!r.ok) {
+    const j = await r.json().catch(() => ({}));
+    throw new Error(j.error || String(r.status));
+  }
+  return new Uint8Array(await r.arrayBuffer());
+}
+
+// Downloads rec, with the timing file of a typescript, and returns its
+// frames: [{ delay in ms, data }].
+async function fetchRecordingFrames(rec) {
+  const data = await fetchRecordingFile(rec.instance_id, rec.path);
+  if (rec.format !== 'typescript') {
+    return parseAsciicast(new TextDecoder('utf-8', { fatal: false }).decode(data));
+  }
+  const timing = await fetchRecordingFile(rec.instance_id, rec.timing_path);
+  return parseTypescript(data, new TextDecoder().decode(timing));
+}
+
+// Returns the output frames of an asciicast v2 recording.
+function parseAsciicast(text) {
+  const lines = text.split('\n');
+  const frames = [];
+  let last = 0;
+  // lines[0] is the header
+  for (let i = 1; i < lines.length; i++) {
+    if (!lines[i].trim()) continue;
+    let ev;
+    try {
+      ev = JSON.parse(lines[i]);
+    } catch (e) {
+      // the last line of a recording still written may be partial
+      if (i === lines.length - 1) break;
+      throw new Error(`asciicast line ${i + 1}: ${e.message}`);
+    }
+    if (!Array.isArray(ev) || ev[1] !== 'o') continue;
+    frames.push({ delay: Math.max(ev[0] - last, 0) * 1000, data: ev[2] });
+    last = ev[0];
+  }
+  return frames;
+}
+
+// Returns the frames of a typescript recording, split by its script(1)
+// timing file.
+function parseTypescript(data, timing) {
+  // skip the "Script started on" header line
+  let off = data.indexOf(10) + 1;
+  const frames = [];
+  for (const line of timing.split('\n')) {
+    const m = /^\s*([\d.]+)\s+(\d+)\s*$/.exec(line);
+    if (!m) continue;
+    const end = Math.min(off + parseInt(m[2], 10), data.length);
+    frames.push({ delay: parseFloat(m[1]) * 1000, data: data.subarray(off, end) });
+    off = end;
+  }
+  return frames;
+}
+
+// Replays recs one after another in the viewer, with the speed and pause
+// controls of its header.
+async function playRecordings(recs, title) {
+  closeStream();
+  viewerTitle.textContent = `${title} • replay`;
+  if (typeof viewer.showModal === 'function') {
+    viewer.showModal();
+  } else {
+    viewer.setAttribute('open', '');
+  }
+  ensureTerminal();
+  term.reset();
+  if (fitAddon) {
+    try { fitAddon.fit(); } catch (e) { /* ignore */ }
+  }
+  const pb = { stopped: false, paused: false, timer: null, wake: null };
+  activePlayback = pb;
+  viewerRecord.hidden = true;
+  viewerSpeed.hidden = false;
+  viewerPause.hidden = false;
+  viewerPause.disabled = false;
+  viewerPause.textContent = 'pause';
+
+  for (const rec of recs) {
+    termWriteText(`\x1b[2m[replaying ${rec.path}]\x1b[0m\r\n`);
+    let frames;
+    try {
+      frames = await fetchRecordingFrames(rec);
+    } catch (e) {
+      if (!pb.stopped) termWriteText(`\r\n\x1b[33m[${e.message || e}]\x1b[0m\r\n`);
+      continue;
+    }
+    for (const f of frames) {
+      await playbackWait(pb, f.delay);
+      if (pb.stopped) return;
+      term.write(f.data);
+    }
+  }
+  if (pb.stopped) return;
+  termWriteText('\r\n\x1b[33m[end of recording]\x1b[0m\r\n');
+  viewerPause.disabled = true;
+}
+
+// Waits ms at the chosen speed, and for as long as pb is paused.
+async function playbackWait(pb, ms) {
+  await new Promise((resolve) => {
+    pb.wake = resolve;
+    pb.timer = setTimeout(resolve, ms / (parseFloat(viewerSpeed.value) || 1));
+  });
+  while (pb.paused && !pb.stopped) {
+    await new Promise((resolve) => { pb.wake = resolve; });
+  }
+}
+
+function togglePlayback() {
+  const pb = activePlayback;
+  if (!pb) return;
+  pb.paused = !pb.paused;
+  viewerPause.textContent = pb.paused ? 'resume' : 'pause';
+  if (!pb.paused && pb.wake) pb.wake();
+}
+
+function stopPlayback() {
+  const pb = activePlayback;
+  if (!pb) return;
+  activePlayback = null;
+  pb.stopped = true;
+  clearTimeout(pb.timer);
+  if (pb.wake) pb.wake();
+  viewerRecord.hidden = false;
+  viewerSpeed.hidden = true;
+  viewerPause.hidden = true;
+}
+
 // ---------- asciicast recorder ----------
 //
 // Captures the active SSE stream as an asciicast v2 file
@@ -461,9 +1014,42 @@ function termWriteText(s) {
   term.write(s);
 }
 
-function openStream(s) {
+// Attaches read-write to the first shell of the session: the user sees a
+// line telling `name` joined, and what is typed in the viewer is sent to
+// the session.
+function attachSession(s) {
+  const saved = localStorage.getItem('sshpiper.attachName') || '';
+  const name = prompt(`Attach to ${s.id}? Its user will see you joined.\nYour name:`, saved);
+  if (name === null) return;
+  localStorage.setItem('sshpiper.attachName', name);
+  openStream(s, name);
+}
+
+function sendInput(data) {
+  const token = attachToken;
+  if (!token || !activeStream) return;
+  const url = activeStream.url.replace(/\?.*$/, '') + `?token=${encodeURIComponent(token)}`;
+  // chained so keystrokes reach the session in order
+  attachQueue = attachQueue.then(async () => {
+    const r = await fetch(url, { method: 'POST', body: data });
+    if (!r.ok) {
+      const j = await r.json().catch(() => ({}));
+      showToast('Input failed: ' + (j.error || r.status), 'error');
+    }
+  }).catch((e) => showToast(String(e), 'error'));
+}
+
+function detachInput() {
+  attachToken = null;
+  if (attachInput) { attachInput.dispose(); attachInput = null; }
+  if (term) term.options.disableStdin = true;
+}
+
+// Streams the session, attached as attachName when given.
+function openStream(s, attachName) {
   closeStream();
-  viewerTitle.textContent = `${s.instance_id} • ${s.id}`;
+  const attach = attachName !== undefined;
+  viewerTitle.textContent = `${s.instance_id} • ${s.id}` + (attach ? ' • attached' : '');
   recorder.sessionLabel = `${s.instance_id}-${s.id}`;
   if (typeof viewer.showModal === 'function') {
     viewer.showModal();
@@ -478,15 +1064,28 @@ function openStream(s) {
     try { fitAddon.fit(); } catch (e) { /* ignore */ }
     requestAnimationFrame(() => { try { fitAddon.fit(); } catch (e) { /* ignore */ } });
   }
-  const url = `/api/v1/sessions/${encodeURIComponent(s.instance_id)}/${encodeURIComponent(s.id)}/stream`;
+  const base = `/api/v1/sessions/${encodeURIComponent(s.instance_id)}/${encodeURIComponent(s.id)}`;
+  const url = attach ? `${base}/attach?name=${encodeURIComponent(attachName)}` : `${base}/stream`;
   const es = new EventSource(url);
   activeStream = es;
+  if (attach) {
+    // a reconnect attaches again, with a new token
+    es.addEventListener('attached', (e) => {
+      try {
+        attachToken = JSON.parse(e.data).token;
+        term.options.disableStdin = false;
+        if (!attachInput) attachInput = term.onData(sendInput);
+        term.focus();
+      } catch (err) { /* ignore */ }
+    });
+  }
   // Track whether we've already displayed a terminal "session ended" notice
   // so we don't keep repeating it as EventSource auto-reconnects.
   let streamEnded = false;
   const endStream = (msg) => {
     if (streamEnded) return;
     streamEnded = true;
+    detachInput();
     try { termWriteText(`\r\n\x1b[33m[${msg}]\x1b[0m\r\n`); } catch (e) { /* ignore */ }
     if (activeStream === es) activeStream = null;
     try { es.close(); } catch (e) { /* ignore */ }
@@ -549,6 +1148,8 @@ function appendData(e) {
 }
 
 function closeStream() {
+  detachInput();
+  stopPlayback();
   if (recorder.active) stopRecording('stream closed');
   if (activeStream) { activeStream.close(); activeStream = null; }
   if (viewer.open) {
@@ -569,18 +1170,57 @@ function tickTimestamps() {
   }
 }
 
-// ---------- auto-refresh control ----------
+// ---------- live updates ----------
+
+// renderSoon renders the sessions once per frame however many events came.
+function renderSoon() {
+  if (renderPending) return;
+  renderPending = true;
+  requestAnimationFrame(() => {
+    renderPending = false;
+    renderSessions();
+  });
+}
 
+function sessionKey(s) {
+  return s.instance_id + '/' + s.id;
+}
+
+// setAutoRefresh keeps the sessions up to date from the watch stream. The
+// stream starts with a snapshot, also after EventSource reconnects.
 function setAutoRefresh(on) {
-  if (autoRefreshTimer) {
-    clearInterval(autoRefreshTimer);
-    autoRefreshTimer = null;
-  }
-  if (on) {
-    autoRefreshTimer = setInterval(() => {
-      loadSessions();
-    }, 5000);
+  if (sessionWatch) {
+    sessionWatch.close();
+    sessionWatch = null;
   }
+  if (!on) return;
+
+  const es = new EventSource('/api/v1/sessions/watch');
+  sessionWatch = es;
+  const upsert = (e) => {
+    const s = JSON.parse(e.data);
+    const i = lastSessions.findIndex((x) => sessionKey(x) === sessionKey(s));
+    if (i >= 0) lastSessions[i] = s;
+    else lastSessions.push(s);
+    renderSoon();
+  };
+  es.addEventListener('snapshot', (e) => {
+    const j = JSON.parse(e.data);
+    lastSessions = j.sessions || [];
+    sessionErrors = j.errors || [];
+    renderSoon();
+  });
+  es.addEventListener('added', upsert);
+  es.addEventListener('updated', upsert);
+  es.addEventListener('removed', (e) => {
+    const key = sessionKey(JSON.parse(e.data));
+    lastSessions = lastSessions.filter((x) => sessionKey(x) !== key);
+    renderSoon();
+  });
+  es.addEventListener('errors', (e) => {
+    sessionErrors = JSON.parse(e.data).errors || [];
+    renderSoon();
+  });
 }
 
 // ---------- event wiring ----------
@@ -633,11 +1273,20 @@ window.addEventListener('resize', () => {
 
 viewerClose.addEventListener('click', closeStream);
 viewer.addEventListener('close', () => {
+  detachInput();
+  stopPlayback();
   if (recorder.active) stopRecording('viewer closed');
   if (activeStream) { activeStream.close(); activeStream = null; }
 });
 viewer.addEventListener('cancel', (e) => {
   // Allow ESC to close (default), but make sure stream is torn down.
+  // While attached ESC belongs to the session.
+  if (attachToken) {
+    e.preventDefault();
+    return;
+  }
+  detachInput();
+  stopPlayback();
   if (recorder.active) stopRecording('viewer closed');
   if (activeStream) { activeStream.close(); activeStream = null; }
   // Don't preventDefault — let the browser close the dialog.
@@ -654,6 +1303,22 @@ if (viewerRecord) {
   updateRecordButton();
 }
 
+historyForm.addEventListener('submit', (e) => {
+  e.preventDefault();
+  searchHistory();
+});
+historyMore.addEventListener('click', loadHistory);
+
+recordingsForm.addEventListener('submit', (e) => {
+  e.preventDefault();
+  searchRecordings();
+});
+recordingsMore.addEventListener('click', loadRecordings);
+viewerPause.addEventListener('click', togglePlayback);
+
+detailsClose.addEventListener('click', () => details.close());
+details.addEventListener('close', closeDetails);
+
 viewerCopy.addEventListener('click', async () => {
   if (!term) return;
   let text = term.getSelection();
@@ -680,6 +1345,7 @@ viewerCopy.addEventListener('click', async () => {
   await loadVersion();
   await loadInstances();
   await loadSessions();
+  openDetailsFromHash();
   setAutoRefresh(autoRefreshChk.checked);
   setInterval(loadInstances, 30000);
   timestampTicker = setInterval(tickTimestamps, 1000);
@@ -19,6 +19,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tg123/sshpiper/cmd/internal/admindiscovery"
 	"github.com/tg123/sshpiper/cmd/internal/slogutil"
 	"github.com/tg123/sshpiper/cmd/sshpiperd-webadmin/internal/aggregator"
 	"github.com/tg123/sshpiper/cmd/sshpiperd-webadmin/internal/httpapi"
@@ -47,7 +48,7 @@ func main() {
 		Usage:       "browser-based admin dashboard for one or more sshpiperd instances",
 		Description: "sshpiperd-webadmin connects to sshpiperd admin gRPC endpoints and exposes a unified HTTP UI for listing live SSH sessions, viewing live screen output, and killing sessions.\nhttps://github.com/tg123/sshpiper",
 		Version:     version(),
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "address",
 				Aliases: []string{"l"},
@@ -100,6 +101,22 @@ func main() {
 				Usage:   "allow the UI to kill sessions; set to false for read-only deployments",
 				EnvVars: []string{"SSHPIPERD_WEBADMIN_ALLOW_KILL"},
 			},
+			&cli.BoolFlag{
+				Name:    "allow-message",
+				Usage:   "allow the UI to write messages to the terminal of sessions (defaults to --allow-kill)",
+				EnvVars: []string{"SSHPIPERD_WEBADMIN_ALLOW_MESSAGE"},
+			},
+			&cli.BoolFlag{
+				Name:    "allow-pause",
+				Usage:   "allow the UI to pause and resume the input of sessions (defaults to --allow-kill)",
+				EnvVars: []string{"SSHPIPERD_WEBADMIN_ALLOW_PAUSE"},
+			},
+			&cli.BoolFlag{
+				Name:    "allow-attach",
+				Value:   false,
+				Usage:   "allow the UI to attach read-write to sessions and type into them; sshpiperd must run with --admin-grpc-allow-attach too",
+				EnvVars: []string{"SSHPIPERD_WEBADMIN_ALLOW_ATTACH"},
+			},
 			&cli.StringFlag{
 				Name:    "web-static-path",
 				Value:   "",
@@ -112,7 +129,7 @@ func main() {
 				Usage:   "log level: debug, info, warn, error",
 				EnvVars: []string{"SSHPIPERD_WEBADMIN_LOG_LEVEL"},
 			},
-		},
+		}, admindiscovery.Flags("SSHPIPERD_WEBADMIN_", false)...),
 		Action: func(ctx *cli.Context) error {
 			level, err := slogutil.ParseLevel(ctx.String("log-level"))
 			if err != nil {
@@ -134,11 +151,14 @@ func main() {
 					}
 				}
 			}
-			if len(endpoints) == 0 {
-				return fmt.Errorf("no sshpiperd endpoints configured: pass --sshpiperd <addr> at least once or set SSHPIPERD_WEBADMIN_ENDPOINTS")
+			discovery, err := admindiscovery.New(ctx, endpoints)
+			if err != nil {
+				return err
+			}
+			if discovery == nil {
+				return fmt.Errorf("no sshpiperd endpoints configured: pass --sshpiperd <addr> at least once, set SSHPIPERD_WEBADMIN_ENDPOINTS or use a --discovery-* flag")
 			}
 
-			discovery := libadmin.NewStaticDiscovery(endpoints)
 			dialOpts := libadmin.DialOptions{
 				Insecure: ctx.Bool("insecure"),
 				CAFile:   ctx.String("tls-cacert"),
@@ -156,14 +176,27 @@ func main() {
 			}
 			agg.StartBackgroundRefresh()
 
+			// messaging and pausing are as disruptive as killing, follow
+			// --allow-kill unless set on their own
+			allowMessage, allowPause := ctx.Bool("allow-kill"), ctx.Bool("allow-kill")
+			if ctx.IsSet("allow-message") {
+				allowMessage = ctx.Bool("allow-message")
+			}
+			if ctx.IsSet("allow-pause") {
+				allowPause = ctx.Bool("allow-pause")
+			}
+
 			handler := httpapi.New(agg, httpapi.Options{
-				AllowKill:  ctx.Bool("allow-kill"),
-				Version:    version(),
-				StaticPath: ctx.String("web-static-path"),
+				AllowKill:    ctx.Bool("allow-kill"),
+				AllowMessage: allowMessage,
+				AllowPause:   allowPause,
+				AllowAttach:  ctx.Bool("allow-attach"),
+				Version:      version(),
+				StaticPath:   ctx.String("web-static-path"),
 			})
 
 			addr := fmt.Sprintf("%s:%d", ctx.String("address"), ctx.Int("port"))
-			slog.Info("sshpiperd-webadmin listening", "version", version(), "address", addr, "endpoints", len(endpoints))
+			slog.Info("sshpiperd-webadmin listening", "version", version(), "address", addr, "instances", len(agg.Instances()))
 			srv := &http.Server{
 				Addr:              addr,
 				Handler:           handler,
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sync"
 	"time"
 )
 
@@ -41,6 +42,7 @@ func readString(buf *bytes.Reader) string {
 type asciicastLogger struct {
 	starttime    time.Time
 	envs         map[string]string
+	labels       map[string]string
 	initWidth    uint32
 	initHeight   uint32
 	channels     map[uint32]*os.File
@@ -48,6 +50,9 @@ type asciicastLogger struct {
 	root         *os.Root
 	recorddir    string
 	prefix       string // prefix for the output file
+
+	mu    sync.Mutex
+	files []string
 }
 
 // newAsciicastLogger creates a recorder that writes .cast files under
@@ -55,9 +60,11 @@ type asciicastLogger struct {
 // configured screen recording root (see daemon.recordRoot); every file this
 // logger opens goes through root.OpenFile so that even if recorddir
 // contains a symlink planted by an attacker, the write cannot escape root.
-func newAsciicastLogger(root *os.Root, recorddir string, prefix string) *asciicastLogger {
+// labels, the labels of the session, are written into the headers.
+func newAsciicastLogger(root *os.Root, recorddir string, prefix string, labels map[string]string) *asciicastLogger {
 	return &asciicastLogger{
 		envs:         make(map[string]string),
+		labels:       labels,
 		root:         root,
 		recorddir:    recorddir,
 		channels:     make(map[uint32]*os.File),
@@ -128,26 +135,36 @@ func (l *asciicastLogger) downhook(msg []byte) error {
 				return err
 			}
 
-			f, err := l.root.OpenFile(
-				path.Join(l.recorddir, fmt.Sprintf("%s%s-channel-%d.cast", l.prefix, reqType, clientChannelID)),
-				os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
-				0o600,
-			)
+			name := path.Join(l.recorddir, fmt.Sprintf("%s%s-channel-%d.cast", l.prefix, reqType, clientChannelID))
+			f, err := l.root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 			if err != nil {
 				return err
 			}
+			l.mu.Lock()
+			l.files = append(l.files, name)
+			l.mu.Unlock()
 
 			l.channels[clientChannelID] = f
 
 			l.starttime = time.Now()
 
+			var jsonLabels []byte
+			if len(l.labels) > 0 {
+				b, err := json.Marshal(l.labels)
+				if err != nil {
+					return err
+				}
+				jsonLabels = append([]byte(", \"labels\": "), b...)
+			}
+
 			_, err = fmt.Fprintf(
 				f,
-				"{\"version\": 2, \"width\": %d, \"height\": %d, \"timestamp\": %d, \"env\": %v}\n",
+				"{\"version\": 2, \"width\": %d, \"height\": %d, \"timestamp\": %d, \"env\": %v%s}\n",
 				l.initWidth,
 				l.initHeight,
 				l.starttime.Unix(),
 				string(jsonEnvs),
+				jsonLabels,
 			)
 			if err != nil {
 				return err
@@ -157,6 +174,14 @@ func (l *asciicastLogger) downhook(msg []byte) error {
 	return nil
 }
 
+// Files returns the paths of the .cast files created so far, relative to
+// the recording root.
+func (l *asciicastLogger) Files() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.files...)
+}
+
 func (l *asciicastLogger) Close() (err error) {
 	for _, f := range l.channels {
 		_ = f.Close()
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRecordingHeadersLabels(t *testing.T) {
+	dir := t.TempDir()
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	if err := root.Mkdir("s", 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]string{"ticket": "INC-1", "team": "db"}
+
+	t.Run("asciicast", func(t *testing.T) {
+		l := newAsciicastLogger(root, "s", "", labels)
+		defer l.Close()
+
+		if err := l.downhook(ssh.Marshal(channelRequest{RecipientChannel: 0, Request: "shell", WantReply: true})); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, "s", "shell-channel-0.cast"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var header struct {
+			Version int               `json:"version"`
+			Labels  map[string]string `json:"labels"`
+		}
+		if err := json.Unmarshal(b, &header); err != nil {
+			t.Fatalf("invalid header %q: %v", b, err)
+		}
+		if header.Version != 2 || header.Labels["ticket"] != "INC-1" || header.Labels["team"] != "db" {
+			t.Errorf("unexpected header %q", b)
+		}
+		if files := l.Files(); len(files) != 1 || files[0] != "s/shell-channel-0.cast" {
+			t.Errorf("Files() = %v", files)
+		}
+	})
+
+	t.Run("typescript", func(t *testing.T) {
+		l, err := newFilePtyLogger(root, "s", labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		matches, err := filepath.Glob(filepath.Join(dir, "s", "*.typescript"))
+		if err != nil || len(matches) != 1 {
+			t.Fatalf("expected one typescript, got %v, %v", matches, err)
+		}
+		if files := l.Files(); len(files) != 2 || files[0] != filepath.Join("s", filepath.Base(matches[0])) || !strings.HasSuffix(files[1], ".timing") {
+			t.Errorf("Files() = %v", files)
+		}
+		b, err := os.ReadFile(matches[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasSuffix(strings.TrimSpace(string(b)), ` [team="db" ticket="INC-1"]`) {
+			t.Errorf("unexpected header %q", b)
+		}
+	})
+
+	if got := typescriptLabels(nil); got != "" {
+		t.Errorf("typescriptLabels(nil) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"sync"
+
+	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+)
+
+// deniedChannelRequest replaces the type of a denied channel request the
+// client wants a reply to. The upstream server does not know it and fails
+// it, so the failure reaches the client in order with the replies to its
+// earlier requests on the channel.
+const deniedChannelRequest = "denied@sshpiper"
+
+// channelAuthorizer asks the plugins about the channels the client opens
+// and the shell, exec, subsystem, pty-req and env requests it makes on them.
+// Denied channels are refused, denied requests are failed, see
+// deniedChannelRequest, or dropped when the client wants no reply, and
+// shell, exec and subsystem requests may be rewritten. Remote forward requests are asked about by the
+// forwardingFilter, see authorizeGlobal.
+type channelAuthorizer struct {
+	authorize func(req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error)
+
+	mu sync.Mutex
+
+	// clientOpening and serverOpening are the types of the channels opened
+	// and not confirmed yet, by the channel id of the side opening them.
+	clientOpening map[uint32]string
+	serverOpening map[uint32]string
+
+	// channels are the types of the open channels by the id the upstream
+	// server gave them, the recipient of the requests of the client.
+	channels map[uint32]string
+}
+
+func newChannelAuthorizer(authorize func(req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error)) *channelAuthorizer {
+	return &channelAuthorizer{
+		authorize:     authorize,
+		clientOpening: make(map[uint32]string),
+		serverOpening: make(map[uint32]string),
+		channels:      make(map[uint32]string),
+	}
+}
+
+// ask returns the decision of the plugins about req, a failing plugin
+// denies.
+func (a *channelAuthorizer) ask(req *libplugin.AuthorizeChannelRequest) *libplugin.AuthorizeChannelResponse {
+	resp, err := a.authorize(req)
+	if err != nil {
+		slog.Warn("channel request denied, plugin failed to authorize it", "request", req.GetRequest(), "channel_type", req.GetChannelType(), "error", err)
+		return &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_DENY}
+	}
+
+	if resp.GetDecision() == libplugin.ChannelDecision_CHANNEL_DENY {
+		slog.Info("channel request denied by plugin", "request", req.GetRequest(), "channel_type", req.GetChannelType(), "message", resp.GetMessage())
+	}
+
+	return resp
+}
+
+// channelOpenRequest returns the AuthorizeChannelRequest of open.
+func channelOpenRequest(open channelOpen) *libplugin.AuthorizeChannelRequest {
+	req := &libplugin.AuthorizeChannelRequest{
+		Request:     "channel-open",
+		ChannelType: open.Type,
+	}
+
+	switch open.Type {
+	case "direct-tcpip":
+		var dest struct {
+			Host string
+			Port uint32
+			Rest []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(open.TypeSpecificData, &dest); err == nil {
+			req.Host = dest.Host
+			req.Port = dest.Port
+		}
+	case "direct-streamlocal@openssh.com":
+		var dest struct {
+			SocketPath string
+			Rest       []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(open.TypeSpecificData, &dest); err == nil {
+			req.SocketPath = dest.SocketPath
+		}
+	}
+
+	return req
+}
+
+// channelRequestRequest returns the AuthorizeChannelRequest of r, nil for
+// requests the plugins are not asked about.
+func channelRequestRequest(r channelRequest, channelType string) *libplugin.AuthorizeChannelRequest {
+	req := &libplugin.AuthorizeChannelRequest{
+		Request:     r.Request,
+		ChannelType: channelType,
+	}
+
+	switch r.Request {
+	case "shell":
+	case "exec":
+		var exec struct{ Command string }
+		if err := ssh.Unmarshal(r.Data, &exec); err != nil {
+			return nil
+		}
+		req.Command = exec.Command
+	case "subsystem":
+		var subsystem struct{ Name string }
+		if err := ssh.Unmarshal(r.Data, &subsystem); err != nil {
+			return nil
+		}
+		req.Subsystem = subsystem.Name
+	case "pty-req":
+		var pty struct {
+			Term string
+			Rest []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(r.Data, &pty); err != nil {
+			return nil
+		}
+		req.Term = pty.Term
+	case "env":
+		var env struct {
+			Name  string
+			Value string
+		}
+		if err := ssh.Unmarshal(r.Data, &env); err != nil {
+			return nil
+		}
+		req.EnvName = env.Name
+		req.EnvValue = env.Value
+	default:
+		return nil
+	}
+
+	return req
+}
+
+// down handles the packets sent by the client.
+func (a *channelAuthorizer) down(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
+	if len(packet) == 0 {
+		return ssh.PipePacketHookTransform, packet, nil
+	}
+
+	switch packet[0] {
+	case msgChannelOpen:
+		var open channelOpen
+		if err := ssh.Unmarshal(packet, &open); err != nil {
+			return ssh.PipePacketHookTransform, packet, nil
+		}
+
+		resp := a.ask(channelOpenRequest(open))
+		if resp.GetDecision() == libplugin.ChannelDecision_CHANNEL_DENY {
+			description := resp.GetMessage()
+			if description == "" {
+				description = "channel denied"
+			}
+			return ssh.PipePacketHookReply, ssh.Marshal(channelOpenFailure{
+				RecipientChannel: open.SenderChannel,
+				ReasonCode:       connectionFailedAdministratively,
+				Description:      description,
+			}), nil
+		}
+
+		a.mu.Lock()
+		a.clientOpening[open.SenderChannel] = open.Type
+		a.mu.Unlock()
+
+	case msgChannelOpenConfirm:
+		// the client accepted a channel opened by the upstream server
+		if len(packet) < 9 {
+			break
+		}
+		serverID := binary.BigEndian.Uint32(packet[1:5])
+
+		a.mu.Lock()
+		a.channels[serverID] = a.serverOpening[serverID]
+		delete(a.serverOpening, serverID)
+		a.mu.Unlock()
+
+	case msgChannelRequest:
+		var r channelRequest
+		if err := ssh.Unmarshal(packet, &r); err != nil {
+			return ssh.PipePacketHookTransform, packet, nil
+		}
+
+		a.mu.Lock()
+		channelType := a.channels[r.RecipientChannel]
+		a.mu.Unlock()
+
+		req := channelRequestRequest(r, channelType)
+		if req == nil {
+			break
+		}
+
+		resp := a.ask(req)
+		if resp.GetDecision() == libplugin.ChannelDecision_CHANNEL_DENY {
+			if !r.WantReply {
+				return ssh.PipePacketHookTransform, nil, nil
+			}
+			return ssh.PipePacketHookTransform, ssh.Marshal(channelRequest{
+				RecipientChannel: r.RecipientChannel,
+				Request:          deniedChannelRequest,
+				WantReply:        true,
+			}), nil
+		}
+
+		rewritten := plugin.RewriteChannelRequest(req, resp)
+		if rewritten == req {
+			break
+		}
+
+		slog.Info("channel request rewritten by plugin", "request", req.GetRequest(), "command", req.GetCommand(), "subsystem", req.GetSubsystem(), "new_request", rewritten.GetRequest(), "new_command", rewritten.GetCommand(), "new_subsystem", rewritten.GetSubsystem())
+
+		var data []byte
+		if rewritten.GetRequest() == "subsystem" {
+			data = ssh.Marshal(struct{ Name string }{rewritten.GetSubsystem()})
+		} else {
+			data = ssh.Marshal(struct{ Command string }{rewritten.GetCommand()})
+		}
+
+		return ssh.PipePacketHookTransform, ssh.Marshal(channelRequest{
+			RecipientChannel: r.RecipientChannel,
+			Request:          rewritten.GetRequest(),
+			WantReply:        r.WantReply,
+			Data:             data,
+		}), nil
+	}
+
+	return ssh.PipePacketHookTransform, packet, nil
+}
+
+// up handles the packets sent by the upstream server, to learn the channels
+// the client sends requests to.
+func (a *channelAuthorizer) up(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
+	if len(packet) == 0 {
+		return ssh.PipePacketHookTransform, packet, nil
+	}
+
+	switch packet[0] {
+	case msgChannelOpen:
+		var open channelOpen
+		if err := ssh.Unmarshal(packet, &open); err == nil {
+			a.mu.Lock()
+			a.serverOpening[open.SenderChannel] = open.Type
+			a.mu.Unlock()
+		}
+
+	case msgChannelOpenConfirm:
+		if len(packet) < 9 {
+			break
+		}
+		clientID := binary.BigEndian.Uint32(packet[1:5])
+		serverID := binary.BigEndian.Uint32(packet[5:9])
+
+		a.mu.Lock()
+		a.channels[serverID] = a.clientOpening[clientID]
+		delete(a.clientOpening, clientID)
+		a.mu.Unlock()
+
+	case msgChannelOpenFailed:
+		if len(packet) < 5 {
+			break
+		}
+
+		a.mu.Lock()
+		delete(a.clientOpening, binary.BigEndian.Uint32(packet[1:5]))
+		a.mu.Unlock()
+	}
+
+	return ssh.PipePacketHookTransform, packet, nil
+}
+
+// authorizeGlobal asks the plugins about the remote forward request, it is
+// the forwardingFilter authorize callback.
+func (a *channelAuthorizer) authorizeGlobal(request globalRequest) bool {
+	req := &libplugin.AuthorizeChannelRequest{Request: request.Type}
+
+	switch request.Type {
+	case "tcpip-forward":
+		var bind struct {
+			Host string
+			Port uint32
+		}
+		if err := ssh.Unmarshal(request.Data, &bind); err == nil {
+			req.Host = bind.Host
+			req.Port = bind.Port
+		}
+	case "streamlocal-forward@openssh.com":
+		var bind struct{ SocketPath string }
+		if err := ssh.Unmarshal(request.Data, &bind); err == nil {
+			req.SocketPath = bind.SocketPath
+		}
+	default:
+		// cancel requests
+		return true
+	}
+
+	return a.ask(req).GetDecision() != libplugin.ChannelDecision_CHANNEL_DENY
+}
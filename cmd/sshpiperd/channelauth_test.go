@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+)
+
+func channelOpenConfirmPkt(recipient, sender uint32) []byte {
+	p := []byte{msgChannelOpenConfirm}
+	p = binary.BigEndian.AppendUint32(p, recipient)
+	p = binary.BigEndian.AppendUint32(p, sender)
+	return append(p, make([]byte, 8)...)
+}
+
+func TestChannelAuthorizer(t *testing.T) {
+	var asked []*libplugin.AuthorizeChannelRequest
+	a := newChannelAuthorizer(func(req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error) {
+		asked = append(asked, req)
+
+		switch {
+		case req.GetRequest() == "channel-open" && req.GetHost() == "db":
+			return &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_DENY, Message: "no db for you"}, nil
+		case req.GetCommand() == "rm -rf /":
+			return &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_DENY}, nil
+		case req.GetRequest() == "shell":
+			return &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_REWRITE, Subsystem: "sftp"}, nil
+		case req.GetRequest() == "env":
+			return nil, errors.New("plugin is down")
+		}
+		return &libplugin.AuthorizeChannelResponse{}, nil
+	})
+
+	pass := func(hook ssh.PipePacketHook, packet []byte) {
+		t.Helper()
+		method, out, err := hook(packet)
+		if err != nil || method != ssh.PipePacketHookTransform || !bytes.Equal(out, packet) {
+			t.Fatalf("expected packet to pass through unchanged, got %v, %v, %v", method, out, err)
+		}
+	}
+
+	t.Run("channel open", func(t *testing.T) {
+		pass(a.down, ssh.Marshal(channelOpen{Type: "session", SenderChannel: 3}))
+		pass(a.up, channelOpenConfirmPkt(3, 7))
+
+		dest := ssh.Marshal(struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}{"db", 5432, "127.0.0.1", 1234})
+		method, reply, err := a.down(ssh.Marshal(channelOpen{Type: "direct-tcpip", SenderChannel: 4, TypeSpecificData: dest}))
+		if err != nil || method != ssh.PipePacketHookReply {
+			t.Fatalf("expected the channel to be refused, got %v, %v", method, err)
+		}
+
+		var failure channelOpenFailure
+		if err := ssh.Unmarshal(reply, &failure); err != nil {
+			t.Fatal(err)
+		}
+		if failure.RecipientChannel != 4 || failure.Description != "no db for you" {
+			t.Errorf("unexpected failure %+v", failure)
+		}
+
+		last := asked[len(asked)-1]
+		if last.GetChannelType() != "direct-tcpip" || last.GetPort() != 5432 {
+			t.Errorf("unexpected request %v", last)
+		}
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		exec := ssh.Marshal(channelRequest{RecipientChannel: 7, Request: "exec", WantReply: true, Data: ssh.Marshal(struct{ Command string }{"ls"})})
+		pass(a.down, exec)
+		if last := asked[len(asked)-1]; last.GetChannelType() != "session" || last.GetCommand() != "ls" {
+			t.Errorf("unexpected request %v", last)
+		}
+
+		exec = ssh.Marshal(channelRequest{RecipientChannel: 7, Request: "exec", WantReply: true, Data: ssh.Marshal(struct{ Command string }{"rm -rf /"})})
+		method, out, err := a.down(exec)
+		if err != nil || method != ssh.PipePacketHookTransform {
+			t.Fatalf("unexpected %v, %v", method, err)
+		}
+
+		// left to the upstream server to fail, in order with its other replies
+		var r channelRequest
+		if err := ssh.Unmarshal(out, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.RecipientChannel != 7 || r.Request != deniedChannelRequest || !r.WantReply || len(r.Data) != 0 {
+			t.Errorf("unexpected denied request %+v", r)
+		}
+	})
+
+	t.Run("denied without reply is dropped", func(t *testing.T) {
+		env := ssh.Marshal(channelRequest{RecipientChannel: 7, Request: "env", Data: ssh.Marshal(struct{ Name, Value string }{"LANG", "C"})})
+		method, out, err := a.down(env)
+		if err != nil || method != ssh.PipePacketHookTransform || out != nil {
+			t.Fatalf("expected the request to be dropped, got %v, %v, %v", method, out, err)
+		}
+	})
+
+	t.Run("rewrite", func(t *testing.T) {
+		method, out, err := a.down(ssh.Marshal(channelRequest{RecipientChannel: 7, Request: "shell", WantReply: true}))
+		if err != nil || method != ssh.PipePacketHookTransform {
+			t.Fatalf("unexpected %v, %v", method, err)
+		}
+
+		var r channelRequest
+		if err := ssh.Unmarshal(out, &r); err != nil {
+			t.Fatal(err)
+		}
+		var subsystem struct{ Name string }
+		if err := ssh.Unmarshal(r.Data, &subsystem); err != nil {
+			t.Fatal(err)
+		}
+		if r.RecipientChannel != 7 || r.Request != "subsystem" || !r.WantReply || subsystem.Name != "sftp" {
+			t.Errorf("unexpected rewritten request %+v %v", r, subsystem.Name)
+		}
+	})
+
+	t.Run("other requests are not asked about", func(t *testing.T) {
+		n := len(asked)
+		pass(a.down, ssh.Marshal(channelRequest{RecipientChannel: 7, Request: "window-change", Data: make([]byte, 16)}))
+		if len(asked) != n {
+			t.Error("window-change must not be asked about")
+		}
+	})
+
+	t.Run("remote forward", func(t *testing.T) {
+		if !a.authorizeGlobal(globalRequest{Type: "tcpip-forward", Data: ssh.Marshal(struct {
+			Host string
+			Port uint32
+		}{"0.0.0.0", 8080})}) {
+			t.Error("expected the forward to be allowed")
+		}
+		if last := asked[len(asked)-1]; last.GetRequest() != "tcpip-forward" || last.GetPort() != 8080 {
+			t.Errorf("unexpected request %v", last)
+		}
+	})
+}
+
+func TestForwardingFilterAuthorize(t *testing.T) {
+	filter := newForwardingFilter(false, false)
+	filter.authorize = func(request globalRequest) bool {
+		return request.Type != "tcpip-forward"
+	}
+
+	method, reply, err := filter.down(ssh.Marshal(globalRequest{Type: "tcpip-forward", WantReply: true}))
+	if err != nil || method != ssh.PipePacketHookReply || !bytes.Equal(reply, []byte{msgRequestFailure}) {
+		t.Fatalf("expected SSH_MSG_REQUEST_FAILURE, got %v, %v, %v", method, reply, err)
+	}
+
+	packet := ssh.Marshal(globalRequest{Type: "streamlocal-forward@openssh.com", WantReply: true})
+	method, out, err := filter.down(packet)
+	if err != nil || method != ssh.PipePacketHookTransform || !bytes.Equal(out, packet) {
+		t.Fatalf("expected the request to be forwarded, got %v, %v, %v", method, out, err)
+	}
+}
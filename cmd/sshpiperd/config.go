@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/tg123/sshpiper/cmd/internal/slogutil"
+	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed config.schema.json
+var configSchemaJSON []byte
+
+var configSchema = sync.OnceValues(func() (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(configSchemaJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("config.schema.json", doc); err != nil {
+		return nil, err
+	}
+
+	return c.Compile("config.schema.json")
+})
+
+// configFile is the --config file. Any sshpiperd flag can be set at the top
+// level by its long name, plugins replaces the `<plugin> -- <plugin>`
+// arguments and routes pick a different chain of them per connection.
+type configFile struct {
+	Version string         `yaml:"version"`
+	Plugins []pluginConfig `yaml:"plugins"`
+	Routes  []routeConfig  `yaml:"routes"`
+	Flags   map[string]any `yaml:",inline"`
+}
+
+// pluginConfig is one plugin in the chain, either a command started by
+// sshpiperd or a grpc endpoint.
+type pluginConfig struct {
+	// Name refers to the plugin in routes
+	Name    string            `yaml:"name"`
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Env     map[string]string `yaml:"env"`
+	Grpc    *grpcPluginConfig `yaml:"grpc"`
+
+	// override the --plugin-rpc-* and --plugin-circuit-breaker-* flags
+	RPCTimeout     *time.Duration           `yaml:"rpc-timeout"`
+	RPCTimeouts    map[string]time.Duration `yaml:"rpc-timeouts"`
+	CircuitBreaker *circuitBreakerConfig    `yaml:"circuit-breaker"`
+
+	// override --plugin-error-policy and --plugin-error-retries
+	ErrorPolicy  string `yaml:"error-policy"`
+	ErrorRetries *int   `yaml:"error-retries"`
+}
+
+type circuitBreakerConfig struct {
+	Failures int            `yaml:"failures"`
+	Cooldown *time.Duration `yaml:"cooldown"`
+	Skip     bool           `yaml:"skip"`
+}
+
+// routeConfig is a chain of named plugins used instead of all plugins for
+// the connections that match.
+type routeConfig struct {
+	Name    string            `yaml:"name"`
+	Match   *routeMatchConfig `yaml:"match"`
+	Plugins []string          `yaml:"plugins"`
+}
+
+type routeMatchConfig struct {
+	Source        []string          `yaml:"source"`
+	Listener      []string          `yaml:"listener"`
+	ClientVersion []string          `yaml:"client-version"`
+	ProxyTLV      map[string]string `yaml:"proxy-tlv"`
+}
+
+type grpcPluginConfig struct {
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+	Key      string `yaml:"key"`
+	Cert     string `yaml:"cert"`
+	CACert   string `yaml:"cacert"`
+}
+
+// pluginSpec is how a plugin of the chain is started, args is what would
+// appear between `--` on the command line.
+type pluginSpec struct {
+	args []string
+	env  map[string]string
+
+	// config is set for plugins from the config file
+	config *pluginConfig
+}
+
+// reloadableFlags are the flags a config reload applies to a running
+// daemon, changing any other flag needs a restart.
+var reloadableFlags = map[string]bool{
+	"log-level":                        true,
+	"login-grace-time":                 true,
+	"drop-hostkeys-message":            true,
+	"reply-ping":                       true,
+	"disable-local-forwarding":         true,
+	"disable-remote-forwarding":        true,
+	"disable-agent-forwarding":         true,
+	"disable-x11-forwarding":           true,
+	"session-idle-timeout":             true,
+	"session-max-duration":             true,
+	"session-bandwidth-limit":          true,
+	"inject-env":                       true,
+	"server-key":                       true,
+	"server-key-data":                  true,
+	"server-cert":                      true,
+	"server-cert-data":                 true,
+	"server-key-agent":                 true,
+	"server-key-agent-fingerprint":     true,
+	"server-key-remotesigner":          true,
+	"server-key-remotesigner-meta":     true,
+	"server-key-remotesigner-insecure": true,
+	"server-key-remotesigner-cert":     true,
+	"server-key-remotesigner-key":      true,
+	"server-key-remotesigner-cacert":   true,
+	"server-key-generate-mode":         true, // keys are never generated on reload
+}
+
+func loadConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file %v: %w", path, err)
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("cannot parse config file %v: %w", path, err)
+	}
+
+	// the schema validates json values, round trip to get rid of yaml
+	// specific go types
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file %v: %w", path, err)
+	}
+
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file %v: %w", path, err)
+	}
+
+	schema, err := configSchema()
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile config schema: %w", err)
+	}
+
+	if err := schema.Validate(inst); err != nil {
+		return nil, fmt.Errorf("invalid config file %v: %w", path, err)
+	}
+
+	var cfg configFile
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse config file %v: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// applyConfigFlags sets the flags in cfg that are not already set on the
+// command line or through their env var.
+func applyConfigFlags(ctx *cli.Context, cfg *configFile) error {
+	names := make([]string, 0, len(cfg.Flags))
+	for name := range cfg.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ctx.IsSet(name) {
+			continue
+		}
+
+		var values []string
+		switch v := cfg.Flags[name].(type) {
+		case []any:
+			for _, e := range v {
+				values = append(values, fmt.Sprint(e))
+			}
+		default:
+			values = []string{fmt.Sprint(v)}
+		}
+
+		for _, v := range values {
+			if err := ctx.Set(name, v); err != nil {
+				return fmt.Errorf("invalid value %q for %v in config file: %w", v, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// pluginSpecs converts the plugin chain into the same form as the command
+// line.
+func (c *configFile) pluginSpecs() []pluginSpec {
+	specs := make([]pluginSpec, 0, len(c.Plugins))
+	for i := range c.Plugins {
+		p := &c.Plugins[i]
+
+		if p.Grpc != nil {
+			args := []string{"grpc", "--endpoint", p.Grpc.Endpoint}
+			if p.Grpc.Insecure {
+				args = append(args, "--insecure")
+			}
+			if p.Grpc.Key != "" {
+				args = append(args, "--key", p.Grpc.Key)
+			}
+			if p.Grpc.Cert != "" {
+				args = append(args, "--cert", p.Grpc.Cert)
+			}
+			if p.Grpc.CACert != "" {
+				args = append(args, "--cacert", p.Grpc.CACert)
+			}
+
+			specs = append(specs, pluginSpec{args: args, config: p})
+			continue
+		}
+
+		specs = append(specs, pluginSpec{
+			args:   append([]string{p.Command}, p.Args...),
+			env:    p.Env,
+			config: p,
+		})
+	}
+
+	return specs
+}
+
+// rpcPolicy returns base with the settings of the plugin applied.
+func (p *pluginConfig) rpcPolicy(base plugin.RPCPolicy) (plugin.RPCPolicy, error) {
+	policy := base
+
+	if p.RPCTimeout != nil {
+		policy.Timeout = *p.RPCTimeout
+	}
+
+	if len(p.RPCTimeouts) > 0 {
+		policy.Timeouts = maps.Clone(base.Timeouts)
+		if policy.Timeouts == nil {
+			policy.Timeouts = make(map[string]time.Duration, len(p.RPCTimeouts))
+		}
+		maps.Copy(policy.Timeouts, p.RPCTimeouts)
+	}
+
+	if cb := p.CircuitBreaker; cb != nil {
+		policy.BreakerFailures = cb.Failures
+		policy.BreakerSkip = cb.Skip
+		if cb.Cooldown != nil {
+			policy.BreakerCooldown = *cb.Cooldown
+		}
+	}
+
+	return policy, policy.Validate()
+}
+
+// applyErrorPolicy sets the error policy of g, started from p, when p
+// overrides the flags.
+func (p *pluginConfig) applyErrorPolicy(g *plugin.GrpcPlugin) error {
+	if p.ErrorPolicy != "" {
+		policy, err := plugin.ParseErrorPolicy(p.ErrorPolicy)
+		if err != nil {
+			return err
+		}
+		g.ErrorPolicy = policy
+	}
+
+	if p.ErrorRetries != nil {
+		g.ErrorRetries = *p.ErrorRetries
+	}
+
+	return nil
+}
+
+// pluginRoutes resolves the plugin names of the routes, plugins are the
+// started c.Plugins in the same order.
+func (c *configFile) pluginRoutes(plugins []*plugin.GrpcPlugin) ([]*pluginRoute, error) {
+	named := make(map[string]*plugin.GrpcPlugin)
+	for i, p := range c.Plugins {
+		if p.Name == "" {
+			continue
+		}
+
+		if _, ok := named[p.Name]; ok {
+			return nil, fmt.Errorf("duplicate plugin name %q", p.Name)
+		}
+		named[p.Name] = plugins[i]
+	}
+
+	var routes []*pluginRoute
+	for _, rc := range c.Routes {
+		match, err := newRouteMatch(rc.Match)
+		if err != nil {
+			return nil, fmt.Errorf("route %v: %w", rc.Name, err)
+		}
+
+		route := &pluginRoute{
+			name:  rc.Name,
+			match: match,
+		}
+
+		for _, name := range rc.Plugins {
+			p, ok := named[name]
+			if !ok {
+				return nil, fmt.Errorf("route %v: unknown plugin %q", rc.Name, name)
+			}
+			route.plugins = append(route.plugins, p)
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// parseFlagsWithConfig parses args into a fresh context of app, as if
+// sshpiperd was started again with cfg.
+func parseFlagsWithConfig(app *cli.App, args []string, cfg *configFile) (*cli.Context, error) {
+	set := flag.NewFlagSet(app.Name, flag.ContinueOnError)
+	set.SetOutput(io.Discard)
+
+	for _, f := range app.Flags {
+		if err := f.Apply(set); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+
+	ctx := cli.NewContext(app, set, nil)
+	if err := applyConfigFlags(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
+
+// configReloader applies a changed --config file to a running daemon.
+type configReloader struct {
+	path string
+	args []string
+
+	// running, plugins and routes are what the daemon was started with
+	running *cli.Context
+	plugins []pluginConfig
+	routes  []routeConfig
+
+	level *slog.LevelVar
+	d     *daemon
+}
+
+// reload re-reads the config file and applies the reloadable flags: the log
+// level, the host keys and the per-connection options. Nothing is applied
+// unless all of them are valid. Other changes are logged as needing a
+// restart.
+func (r *configReloader) reload() error {
+	cfg, err := loadConfigFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := parseFlagsWithConfig(r.running.App, r.args, cfg)
+	if err != nil {
+		return err
+	}
+
+	level, err := slogutil.ParseLevel(ctx.String("log-level"))
+	if err != nil {
+		return fmt.Errorf("invalid log-level %q: %w", ctx.String("log-level"), err)
+	}
+
+	opts, err := connOptionsFromFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.d.hostKeys.reloadFrom(hostKeyLoader(ctx, r.d.hostKeys.remoteSigner)); err != nil {
+		return fmt.Errorf("failed to reload host keys: %w", err)
+	}
+
+	r.level.Set(level)
+	r.d.setOptions(opts)
+
+	for _, f := range r.running.App.Flags {
+		name := f.Names()[0]
+		if reloadableFlags[name] || slices.Contains([]string{"help", "version"}, name) {
+			continue
+		}
+
+		if !reflect.DeepEqual(r.running.Value(name), ctx.Value(name)) {
+			slog.Warn("config change ignored until restart", "flag", name)
+		}
+	}
+
+	if !reflect.DeepEqual(r.plugins, cfg.Plugins) {
+		slog.Warn("config change ignored until restart", "section", "plugins")
+	}
+
+	if !reflect.DeepEqual(r.routes, cfg.Routes) {
+		slog.Warn("config change ignored until restart", "section", "routes")
+	}
+
+	slog.Info("config reloaded", "config", r.path)
+	return nil
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"golang.org/x/crypto/ssh"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sshpiperd.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	return path
+}
+
+func TestConfigSchemaCoversFlags(t *testing.T) {
+	var schema struct {
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(configSchemaJSON, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var inSchema []string
+	for name := range schema.Definitions["sshpiperd"].Properties {
+		if name != "version" && name != "plugins" && name != "routes" {
+			inSchema = append(inSchema, name)
+		}
+	}
+	sort.Strings(inSchema)
+
+	var flags []string
+	for _, f := range newApp().Flags {
+		if name := f.Names()[0]; name != "config" {
+			flags = append(flags, name)
+		}
+	}
+	sort.Strings(flags)
+
+	if !slices.Equal(inSchema, flags) {
+		t.Errorf("schema properties %v do not match flags %v", inSchema, flags)
+	}
+}
+
+func TestConfigSchemaRPCTimeouts(t *testing.T) {
+	var schema struct {
+		Definitions map[string]struct {
+			Properties map[string]struct {
+				PropertyNames struct {
+					Enum []string `json:"enum"`
+				} `json:"propertyNames"`
+			} `json:"properties"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(configSchemaJSON, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	inSchema := schema.Definitions["plugin"].Properties["rpc-timeouts"].PropertyNames.Enum
+	if !slices.Equal(inSchema, plugin.PolicyRPCs()) {
+		t.Errorf("schema rpc-timeouts names %v do not match plugin rpcs %v", inSchema, plugin.PolicyRPCs())
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cfg, err := loadConfigFile(writeTestConfig(t, `
+version: "1"
+port: 2200
+log-level: debug
+allowed-proxy-addresses: [10.0.0.0/8, 192.168.0.0/16]
+plugins:
+  - command: /usr/local/bin/fixed
+    args: [--target, 127.0.0.1:5522]
+    env:
+      FOO: bar
+  - grpc:
+      endpoint: 127.0.0.1:9000
+      insecure: true
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.Version != "1" || len(cfg.Plugins) != 2 {
+			t.Errorf("unexpected config %+v", cfg)
+		}
+		if _, ok := cfg.Flags["version"]; ok {
+			t.Errorf("version must not be treated as a flag")
+		}
+		if cfg.Flags["port"] != 2200 {
+			t.Errorf("port = %v, want 2200", cfg.Flags["port"])
+		}
+	})
+
+	for name, content := range map[string]string{
+		"unknown flag":      "version: \"1\"\nno-such-flag: true\n",
+		"wrong type":        "version: \"1\"\nport: not-a-number\n",
+		"bad enum":          "version: \"1\"\nlog-format: xml\n",
+		"missing version":   "port: 2222\n",
+		"plugin ambiguity":  "version: \"1\"\nplugins:\n  - command: a\n    grpc:\n      endpoint: b\n",
+		"plugin no command": "version: \"1\"\nplugins:\n  - args: [a]\n",
+		"invalid yaml":      "version: [\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadConfigFile(writeTestConfig(t, content)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestApplyConfigFlags(t *testing.T) {
+	cfg := &configFile{
+		Flags: map[string]any{
+			"port":                    3000,
+			"address":                 "127.0.0.1",
+			"reply-ping":              false,
+			"login-grace-time":        "10s",
+			"allowed-proxy-addresses": []any{"10.0.0.0/8", "192.168.0.0/16"},
+		},
+	}
+
+	ctx, err := parseFlagsWithConfig(newApp(), []string{"--port", "2000"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ctx.Int("port"); got != 2000 {
+		t.Errorf("port = %v, want command line value 2000", got)
+	}
+	if got := ctx.String("address"); got != "127.0.0.1" {
+		t.Errorf("address = %v, want 127.0.0.1", got)
+	}
+	if ctx.Bool("reply-ping") {
+		t.Errorf("reply-ping = true, want false")
+	}
+	if got := ctx.Duration("login-grace-time"); got != 10*time.Second {
+		t.Errorf("login-grace-time = %v, want 10s", got)
+	}
+	if got := ctx.StringSlice("allowed-proxy-addresses"); !slices.Equal(got, []string{"10.0.0.0/8", "192.168.0.0/16"}) {
+		t.Errorf("allowed-proxy-addresses = %v", got)
+	}
+
+	t.Run("env takes precedence", func(t *testing.T) {
+		t.Setenv("SSHPIPERD_PORT", "4000")
+
+		ctx, err := parseFlagsWithConfig(newApp(), nil, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ctx.Int("port"); got != 4000 {
+			t.Errorf("port = %v, want env value 4000", got)
+		}
+	})
+}
+
+func TestConfigPluginSpecs(t *testing.T) {
+	cfg := &configFile{
+		Plugins: []pluginConfig{
+			{Command: "fixed", Args: []string{"--target", "127.0.0.1:5522"}, Env: map[string]string{"FOO": "bar"}},
+			{Grpc: &grpcPluginConfig{Endpoint: "127.0.0.1:9000", Insecure: true}},
+			{Grpc: &grpcPluginConfig{Endpoint: "plugin:9000", Cert: "c.pem", Key: "k.pem", CACert: "ca.pem"}},
+		},
+	}
+
+	specs := cfg.pluginSpecs()
+	want := [][]string{
+		{"fixed", "--target", "127.0.0.1:5522"},
+		{"grpc", "--endpoint", "127.0.0.1:9000", "--insecure"},
+		{"grpc", "--endpoint", "plugin:9000", "--key", "k.pem", "--cert", "c.pem", "--cacert", "ca.pem"},
+	}
+
+	if len(specs) != len(want) {
+		t.Fatalf("got %d specs, want %d", len(specs), len(want))
+	}
+	for i := range want {
+		if !slices.Equal(specs[i].args, want[i]) {
+			t.Errorf("spec %d args = %v, want %v", i, specs[i].args, want[i])
+		}
+	}
+	if specs[0].env["FOO"] != "bar" {
+		t.Errorf("expected command plugin env to be kept, got %v", specs[0].env)
+	}
+}
+
+func TestConfigReloader(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := generateTestKey(t, dir, "old")
+	newKey := generateTestKey(t, dir, "new")
+
+	writeConfig := func(path, key string, replyPing bool, port int) {
+		content := fmt.Sprintf("version: \"1\"\nserver-key: %v\nlog-level: debug\nreply-ping: %v\nport: %v\n", filepath.Join(dir, key), replyPing, port)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "sshpiperd.yaml")
+	writeConfig(path, "old", true, 2222)
+
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newApp()
+	running, err := parseFlagsWithConfig(app, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := connOptionsFromFlags(running)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &daemon{
+		hostKeys:    newHostKeyRing([]ssh.Signer{oldKey}, hostKeyLoader(running, &remoteSignerConn{}), 0),
+		connOptions: opts,
+	}
+
+	level := new(slog.LevelVar)
+	r := &configReloader{
+		path:    path,
+		running: running,
+		plugins: cfg.Plugins,
+		level:   level,
+		d:       d,
+	}
+
+	writeConfig(path, "new", false, 2223)
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := d.hostKeys.presented(); len(got) != 1 || string(got[0].PublicKey().Marshal()) != string(newKey.PublicKey().Marshal()) {
+		t.Errorf("expected the new host key, got %v", hostKeyFingerprints(got))
+	}
+	if d.options().replyPing {
+		t.Errorf("expected reply-ping to be reloaded")
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("log level = %v, want debug", level.Level())
+	}
+
+	t.Run("invalid config keeps the current settings", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("version: \"1\"\nreply-ping: maybe\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.reload(); err == nil {
+			t.Fatal("expected reload error, got nil")
+		}
+		if got := d.hostKeys.presented(); string(got[0].PublicKey().Marshal()) != string(newKey.PublicKey().Marshal()) {
+			t.Errorf("expected host key to be kept")
+		}
+	})
+}
+
+func TestConfigPluginRPCPolicy(t *testing.T) {
+	cfg, err := loadConfigFile(writeTestConfig(t, `
+version: "1"
+plugins:
+  - command: /usr/local/bin/fixed
+    rpc-timeout: 2s
+    rpc-timeouts:
+      PasswordAuth: 5s
+    circuit-breaker:
+      failures: 3
+      skip: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := plugin.RPCPolicy{
+		Timeout:         time.Second,
+		Timeouts:        map[string]time.Duration{"Banner": time.Second},
+		BreakerCooldown: 30 * time.Second,
+	}
+
+	policy, err := cfg.Plugins[0].rpcPolicy(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if policy.Timeout != 2*time.Second {
+		t.Errorf("timeout = %v, want 2s", policy.Timeout)
+	}
+	if policy.Timeouts["PasswordAuth"] != 5*time.Second || policy.Timeouts["Banner"] != time.Second {
+		t.Errorf("unexpected timeouts %v", policy.Timeouts)
+	}
+	if _, ok := base.Timeouts["PasswordAuth"]; ok {
+		t.Errorf("base policy must not be modified")
+	}
+	if policy.BreakerFailures != 3 || !policy.BreakerSkip || policy.BreakerCooldown != 30*time.Second {
+		t.Errorf("unexpected breaker settings %+v", policy)
+	}
+
+	t.Run("unknown rpc", func(t *testing.T) {
+		if _, err := loadConfigFile(writeTestConfig(t, "version: \"1\"\nplugins:\n  - command: a\n    rpc-timeouts:\n      NoSuchAuth: 1s\n")); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestConfigPluginRoutes(t *testing.T) {
+	cfg, err := loadConfigFile(writeTestConfig(t, `
+version: "1"
+plugins:
+  - name: failtoban
+    command: /usr/local/bin/failtoban
+  - name: workingdir
+    command: /usr/local/bin/workingdir
+routes:
+  - name: internal
+    match:
+      source: [10.0.0.0/8]
+      proxy-tlv:
+        "0xE0": internal
+    plugins: [workingdir]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failtoban := &plugin.GrpcPlugin{Name: "failtoban"}
+	workingdir := &plugin.GrpcPlugin{Name: "workingdir"}
+
+	routes, err := cfg.pluginRoutes([]*plugin.GrpcPlugin{failtoban, workingdir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].name != "internal" || !slices.Equal(routes[0].plugins, []*plugin.GrpcPlugin{workingdir}) {
+		t.Errorf("unexpected routes %+v", routes)
+	}
+
+	t.Run("unknown plugin", func(t *testing.T) {
+		cfg.Routes[0].Plugins = []string{"simplemath"}
+		if _, err := cfg.pluginRoutes([]*plugin.GrpcPlugin{failtoban, workingdir}); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		cfg.Plugins[1].Name = "failtoban"
+		if _, err := cfg.pluginRoutes([]*plugin.GrpcPlugin{failtoban, workingdir}); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+
+	for name, content := range map[string]string{
+		"route without plugins": "version: \"1\"\nroutes:\n  - name: a\n    plugins: []\n",
+		"unknown condition":     "version: \"1\"\nroutes:\n  - name: a\n    match:\n      user: [root]\n    plugins: [b]\n",
+		"bad tlv type":          "version: \"1\"\nroutes:\n  - name: a\n    match:\n      proxy-tlv:\n        foo: bar\n    plugins: [b]\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadConfigFile(writeTestConfig(t, content)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -1,37 +1,130 @@
 package main
 
 import (
+	"context"
 	"crypto/ed25519"
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/admin"
 	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"github.com/tg123/sshpiper/libadmin"
+	"github.com/tg123/sshpiper/libplugin"
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
 )
 
-type daemon struct {
-	config         *plugin.GrpcPluginConfig
-	lis            net.Listener
-	loginGraceTime time.Duration
-
-	recorddir             string
-	recordfmt             string
-	usernameAsRecorddir   bool
+// connOptions are the per-connection settings a config reload may change on
+// a running daemon. run takes a snapshot of them for every new connection.
+type connOptions struct {
+	loginGraceTime        time.Duration
 	filterHostkeysReqeust bool
 	replyPing             bool
 	disableLocalForward   bool
 	disableRemoteForward  bool
+	disableAgentForward   bool
+	disableX11Forward     bool
+	idleTimeout           time.Duration
+	maxDuration           time.Duration
+	bandwidthLimit        uint64
+
+	// injectEnv is merged into every upstream session's env-injection.
+	// Plugin-provided env (Upstream.Env) takes precedence on key
+	// collisions. Empty (nil/zero-length) means no global injection.
+	injectEnv map[string]string
+}
+
+func connOptionsFromFlags(ctx *cli.Context) (connOptions, error) {
+	opts := connOptions{
+		loginGraceTime:        ctx.Duration("login-grace-time"),
+		filterHostkeysReqeust: ctx.Bool("drop-hostkeys-message"),
+		replyPing:             ctx.Bool("reply-ping"),
+		disableLocalForward:   ctx.Bool("disable-local-forwarding"),
+		disableRemoteForward:  ctx.Bool("disable-remote-forwarding"),
+		disableAgentForward:   ctx.Bool("disable-agent-forwarding"),
+		disableX11Forward:     ctx.Bool("disable-x11-forwarding"),
+		idleTimeout:           ctx.Duration("session-idle-timeout"),
+		maxDuration:           ctx.Duration("session-max-duration"),
+	}
+
+	if limit := ctx.Int("session-bandwidth-limit"); limit < 0 {
+		return connOptions{}, fmt.Errorf("invalid --session-bandwidth-limit %v: must not be negative", limit)
+	} else {
+		opts.bandwidthLimit = uint64(limit)
+	}
+
+	if raw := ctx.StringSlice("inject-env"); len(raw) > 0 {
+		opts.injectEnv = make(map[string]string, len(raw))
+		for _, kv := range raw {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || k == "" {
+				return connOptions{}, fmt.Errorf("invalid --inject-env %q: expected KEY=VALUE", kv)
+			}
+			opts.injectEnv[k] = v
+		}
+	}
+
+	return opts, nil
+}
+
+// installedPlugins is the result of installing the plugins, replaced as a
+// whole when they are installed again.
+type installedPlugins struct {
+	config *plugin.GrpcPluginConfig
+
+	// routed is set when there are routes, its route 0 is all plugins and
+	// route i+1 is d.routes[i].
+	routed *plugin.RoutedPlugins
+}
+
+type daemon struct {
+	// config is the base config plugins are installed into, installed is
+	// the result used for new connections.
+	config    *plugin.GrpcPluginConfig
+	installed atomic.Pointer[installedPlugins]
+	hostKeys  *hostKeyRing
+	lis       net.Listener
+
+	// installMu serializes installing plugins, which happens again when a
+	// supervised plugin restarted.
+	installMu sync.Mutex
+	plugins   []*plugin.GrpcPlugin
+
+	// routes pick the plugin chain of a connection, the first route it
+	// matches wins and connections matching none go through all plugins.
+	routes []*pluginRoute
+
+	// supervised are the plugins restarted by the daemon, new connections
+	// are refused with unavailableBanner while any of them is down.
+	supervised        []*plugin.SupervisedCmdPlugin
+	unavailableBanner string
+
+	// optsMu guards connOptions, which a config reload replaces while
+	// connections are being accepted.
+	optsMu sync.RWMutex
+	connOptions
+
+	recorddir           string
+	recordfmt           string
+	usernameAsRecorddir bool
 
 	// recordRoot is an os.Root scoped to recorddir, opened by
 	// initScreenRecording. All per-connection recording directories and
@@ -42,15 +135,26 @@ type daemon struct {
 	// checks in safeJoinUserRecordDir are only a defense-in-depth measure.
 	recordRoot *os.Root
 
-	// injectEnv is merged into every upstream session's env-injection.
-	// Plugin-provided env (Upstream.Env) takes precedence on key
-	// collisions. Empty (nil/zero-length) means no global injection.
-	injectEnv map[string]string
+	// metrics is set when --metrics-address is enabled.
+	metrics *daemonMetrics
+
+	// accepting is set while the accept loop runs and acceptErrors tracks
+	// its failing Accepts, they back the liveness probe.
+	accepting    atomic.Bool
+	acceptErrors acceptErrors
+
+	// draining fails the readiness probe while the daemon keeps serving
+	// for --drain-delay before it exits.
+	draining atomic.Bool
 
 	// adminRegistry tracks live ssh.PiperConn pipes for the admin gRPC API.
 	// Set by main.go when --admin-grpc-port is enabled; nil otherwise, in
 	// which case the daemon path is unchanged.
 	adminRegistry *admin.Registry
+
+	// history records the finished sessions, set by main.go along with
+	// adminRegistry when --admin-grpc-history-file is set.
+	history *admin.History
 }
 
 func generateSshKey(keyfile string) error {
@@ -127,7 +231,53 @@ func findMatchingCert(private ssh.Signer, certFiles []string) string {
 	return ""
 }
 
-func loadHostKeys(ctx *cli.Context) ([]ssh.Signer, error) {
+// attachHostCert pairs private with its host certificate when --server-cert
+// or --server-cert-data is configured, and returns private unchanged
+// otherwise. name identifies the key in logs and errors.
+func attachHostCert(private ssh.Signer, name string, certBytes []byte, certFiles []string) (ssh.Signer, error) {
+	if certBytes != nil {
+		certSigner, err := certSignerFromBytes(private, certBytes, "--server-cert-data")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load host certificate from --server-cert-data for key %v: %w", name, err)
+		}
+
+		slog.Info("loaded host certificate from --server-cert-data for key", "key", name)
+		return certSigner, nil
+	}
+
+	if len(certFiles) > 0 {
+		certFile := findMatchingCert(private, certFiles)
+		if certFile == "" {
+			return nil, fmt.Errorf("no host certificate in %v matched key %v", certFiles, name)
+		}
+
+		certSigner, err := loadCertSigner(private, certFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load host certificate %v: %w", certFile, err)
+		}
+
+		slog.Info("loaded host certificate matched by fingerprint", "certificate", certFile)
+		return certSigner, nil
+	}
+
+	return private, nil
+}
+
+// loadHostKeys loads the host keys configured in ctx, with the remote
+// signer connections of conns.
+func loadHostKeys(ctx *cli.Context, conns *remoteSignerConn) ([]ssh.Signer, error) {
+	return loadHostKeysWithGenerateMode(ctx, ctx.String("server-key-generate-mode"), conns)
+}
+
+// hostKeyLoader returns how the host keys configured in ctx are reloaded.
+// Keys are only ever generated at startup, a reload reads them back.
+func hostKeyLoader(ctx *cli.Context, conns *remoteSignerConn) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		return loadHostKeysWithGenerateMode(ctx, "disable", conns)
+	}
+}
+
+func loadHostKeysWithGenerateMode(ctx *cli.Context, generateMode string, conns *remoteSignerConn) ([]ssh.Signer, error) {
 	keybase64 := ctx.String("server-key-data")
 	certPattern := ctx.String("server-cert")
 	certBase64 := ctx.String("server-cert-data")
@@ -155,6 +305,73 @@ func loadHostKeys(ctx *cli.Context) ([]ssh.Signer, error) {
 		}
 	}
 
+	agentSocket := ctx.String("server-key-agent")
+	remoteSignerEndpoint := ctx.String("server-key-remotesigner")
+
+	if agentSocket != "" || remoteSignerEndpoint != "" {
+		var signers []ssh.Signer
+
+		if agentSocket != "" {
+			slog.Info("loading host keys from ssh-agent", "socket", agentSocket)
+			agentSigners, err := loadAgentHostKeys(agentSocket, ctx.StringSlice("server-key-agent-fingerprint"))
+			if err != nil {
+				return nil, err
+			}
+
+			signers = append(signers, agentSigners...)
+		}
+
+		if remoteSignerEndpoint != "" {
+			slog.Info("loading host keys from remote signer", "endpoint", remoteSignerEndpoint)
+			insecure := ctx.Bool("server-key-remotesigner-insecure")
+			cert := ctx.String("server-key-remotesigner-cert")
+			key := ctx.String("server-key-remotesigner-key")
+			cacert := ctx.String("server-key-remotesigner-cacert")
+
+			var remoteSigners []ssh.Signer
+			err := conns.load(
+				fmt.Sprintf("%v|%v|%v|%v|%v", remoteSignerEndpoint, insecure, cert, key, cacert),
+				func() (*grpc.ClientConn, error) {
+					secopt, err := grpcTransportCredentials(insecure, cert, key, cacert)
+					if err != nil {
+						return nil, fmt.Errorf("failed to configure remote signer connection: %w", err)
+					}
+
+					conn, err := grpc.NewClient(remoteSignerEndpoint, secopt)
+					if err != nil {
+						return nil, fmt.Errorf("failed to connect to remote signer %v: %w", remoteSignerEndpoint, err)
+					}
+
+					return conn, nil
+				},
+				func(conn grpc.ClientConnInterface) (err error) {
+					remoteSigners, err = loadRemoteSignerHostKeys(conn, ctx.StringSlice("server-key-remotesigner-meta"))
+					return err
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			signers = append(signers, remoteSigners...)
+		}
+
+		if certBytes != nil && len(signers) > 1 {
+			return nil, fmt.Errorf("--server-cert-data provides a single certificate but %d server keys were loaded; use --server-cert with a glob pattern for multi-key setups", len(signers))
+		}
+
+		for i, signer := range signers {
+			signer, err := attachHostCert(signer, ssh.FingerprintSHA256(signer.PublicKey()), certBytes, certFiles)
+			if err != nil {
+				return nil, err
+			}
+
+			signers[i] = signer
+		}
+
+		return signers, nil
+	}
+
 	if keybase64 != "" {
 		slog.Info("parsing host key in base64 params")
 
@@ -202,14 +419,14 @@ func loadHostKeys(ctx *cli.Context) ([]ssh.Signer, error) {
 
 	generate := false
 
-	switch ctx.String("server-key-generate-mode") {
+	switch generateMode {
 	case "notexist":
 		generate = len(privateKeyFiles) == 0
 	case "always":
 		generate = true
 	case "disable":
 	default:
-		return nil, fmt.Errorf("unknown server-key-generate-mode %v", ctx.String("server-key-generate-mode"))
+		return nil, fmt.Errorf("unknown server-key-generate-mode %v", generateMode)
 	}
 
 	if generate {
@@ -244,27 +461,9 @@ func loadHostKeys(ctx *cli.Context) ([]ssh.Signer, error) {
 			return nil, fmt.Errorf("failed to parse server key %v: %w", privateKey, err)
 		}
 
-		if certBytes != nil {
-			certSigner, err := certSignerFromBytes(private, certBytes, "--server-cert-data")
-			if err != nil {
-				return nil, fmt.Errorf("failed to load host certificate from --server-cert-data for key %v: %w", privateKey, err)
-			}
-
-			private = certSigner
-			slog.Info("loaded host certificate from --server-cert-data for key", "keyfile", privateKey)
-		} else if certPattern != "" && len(certFiles) > 0 {
-			certFile := findMatchingCert(private, certFiles)
-			if certFile == "" {
-				return nil, fmt.Errorf("no host certificate in %v matched key %v", certFiles, privateKey)
-			}
-
-			certSigner, err := loadCertSigner(private, certFile)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load host certificate %v: %w", certFile, err)
-			}
-
-			private = certSigner
-			slog.Info("loaded host certificate matched by fingerprint", "certificate", certFile)
+		private, err = attachHostCert(private, privateKey, certBytes, certFiles)
+		if err != nil {
+			return nil, err
 		}
 
 		signers = append(signers, private)
@@ -313,12 +512,17 @@ func newDaemon(ctx *cli.Context) (*daemon, error) {
 	// however, this is to make sure that the default values are set no matter sshiper.go calls SetDefaults or not
 	config.SetDefaults()
 
-	signers, err := loadHostKeys(ctx)
+	remoteSigner := &remoteSignerConn{}
+	signers, err := loadHostKeys(ctx, remoteSigner)
 	if err != nil {
 		return nil, err
 	}
-	for _, signer := range signers {
-		config.AddHostKey(signer)
+	hostKeys := newHostKeyRing(signers, hostKeyLoader(ctx, remoteSigner), ctx.Duration("server-key-rotation-window"))
+	hostKeys.useRemoteSigner(remoteSigner)
+
+	opts, err := connOptionsFromFlags(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	lis, err := net.Listen("tcp", net.JoinHostPort(ctx.String("address"), ctx.String("port")))
@@ -391,30 +595,254 @@ func newDaemon(ctx *cli.Context) (*daemon, error) {
 	}
 
 	return &daemon{
-		config:         config,
-		lis:            lis,
-		loginGraceTime: ctx.Duration("login-grace-time"),
+		config:      config,
+		hostKeys:    hostKeys,
+		lis:         lis,
+		connOptions: opts,
 	}, nil
 }
 
+// options returns a snapshot of the current per-connection settings.
+func (d *daemon) options() connOptions {
+	d.optsMu.RLock()
+	defer d.optsMu.RUnlock()
+
+	return d.connOptions
+}
+
+// setOptions replaces the per-connection settings for new connections.
+func (d *daemon) setOptions(opts connOptions) {
+	d.optsMu.Lock()
+	defer d.optsMu.Unlock()
+
+	d.connOptions = opts
+}
+
+// piperConfig returns the config for a new connection, serving the host
+// keys currently presented by d.hostKeys.
+func (d *daemon) piperConfig(config *plugin.GrpcPluginConfig) *ssh.PiperConfig {
+	if d.hostKeys == nil {
+		return &config.PiperConfig
+	}
+
+	return d.hostKeys.piperConfig(&config.PiperConfig)
+}
+
+// reloadHostKeys re-reads the host keys and certificates for new
+// connections. On error the keys in use are kept.
+func (d *daemon) reloadHostKeys() (hostKeyStatus, error) {
+	if d.hostKeys == nil {
+		return hostKeyStatus{}, fmt.Errorf("host keys are not reloadable")
+	}
+
+	return d.hostKeys.reload()
+}
+
+// install installs plugins into a copy of d.config and uses it for new
+// connections.
 func (d *daemon) install(plugins ...*plugin.GrpcPlugin) error {
 	if len(plugins) == 0 {
 		return fmt.Errorf("no plugins found")
 	}
 
-	if len(plugins) == 1 {
-		return plugins[0].InstallPiperConfig(d.config)
+	d.installMu.Lock()
+	defer d.installMu.Unlock()
+
+	config := *d.config
+	installed := &installedPlugins{config: &config}
+
+	if len(d.routes) > 0 {
+		routed := &plugin.RoutedPlugins{}
+
+		if err := routed.Append(plugins...); err != nil {
+			return err
+		}
+
+		for _, route := range d.routes {
+			if err := routed.Append(route.plugins...); err != nil {
+				return fmt.Errorf("route %v: %w", route.name, err)
+			}
+		}
+
+		if err := routed.InstallPiperConfig(&config); err != nil {
+			return err
+		}
+
+		installed.routed = routed
+	} else if len(plugins) == 1 {
+		if err := plugins[0].InstallPiperConfig(&config); err != nil {
+			return err
+		}
+	} else {
+		m := plugin.ChainPlugins{}
+
+		for _, p := range plugins {
+			if err := m.Append(p); err != nil {
+				return err
+			}
+		}
+
+		if err := m.InstallPiperConfig(&config); err != nil {
+			return err
+		}
+	}
+
+	d.metrics.instrumentAuth(&config)
+
+	if len(d.supervised) > 0 {
+		createChallengeContext := config.CreateChallengeContext
+		config.CreateChallengeContext = func(conn ssh.ServerPreAuthConn) (ssh.ChallengeContext, error) {
+			if err := d.pluginsAvailable(conn); err != nil {
+				return nil, err
+			}
+
+			return createChallengeContext(conn)
+		}
+	}
+
+	d.plugins = plugins
+	d.installed.Store(installed)
+
+	return nil
+}
+
+// pluginsAvailable refuses the connection with d.unavailableBanner while a
+// supervised plugin is down.
+func (d *daemon) pluginsAvailable(conn ssh.ServerPreAuthConn) error {
+	for _, p := range d.supervised {
+		if !p.Available() {
+			if err := conn.SendAuthBanner(d.unavailableBanner); err != nil {
+				slog.Debug("cannot send plugin unavailable banner", "error", err)
+			}
+			return fmt.Errorf("plugin %v is restarting", p.Name)
+		}
+	}
+
+	return nil
+}
+
+// connPiperConfig returns the config of the connection c, traced under
+// handshake. With routes, the connection is started on the plugin chain of
+// the first route it matches.
+func (d *daemon) connPiperConfig(installed *installedPlugins, c net.Conn, handshake *connTrace) *ssh.PiperConfig {
+	config := *d.piperConfig(installed.config)
+
+	if installed.routed != nil {
+		config.CreateChallengeContext = func(conn ssh.ServerPreAuthConn) (ssh.ChallengeContext, error) {
+			if err := d.pluginsAvailable(conn); err != nil {
+				return nil, err
+			}
+
+			route, name := 0, "default"
+			for i, r := range d.routes {
+				if r.match.matches(c, conn) {
+					route, name = i+1, r.name
+					break
+				}
+			}
+
+			slog.Debug("connection routed", "route", name, "remote_addr", conn.RemoteAddr(), "client_version", string(conn.ClientVersion()))
+			return installed.routed.CreateChallengeContext(conn, route)
+		}
 	}
 
-	m := plugin.ChainPlugins{}
+	handshake.instrument(&config)
+	return &config
+}
+
+// pluginStatus reports the installed plugins for the admin API.
+func (d *daemon) pluginStatus() []*libadmin.PluginStatus {
+	d.installMu.Lock()
+	plugins := d.plugins
+	d.installMu.Unlock()
 
+	statuses := make([]*libadmin.PluginStatus, 0, len(plugins))
 	for _, p := range plugins {
-		if err := m.Append(p); err != nil {
-			return err
+		info := p.Info()
+		ps := &libadmin.PluginStatus{
+			Name:            p.Name,
+			Available:       true,
+			CircuitOpen:     p.BreakerOpen(),
+			PluginName:      info.Name,
+			PluginVersion:   info.Version,
+			ProtocolVersion: info.ProtocolVersion,
+			Features:        info.Features,
+		}
+
+		for _, sp := range d.supervised {
+			if &sp.GrpcPlugin != p {
+				continue
+			}
+
+			st := sp.Status()
+			ps.Available = st.Available
+			ps.Restarts = st.Restarts
+			if st.LastError != nil {
+				ps.LastError = st.LastError.Error()
+			}
+			if !st.LastRestartAt.IsZero() {
+				ps.LastRestartAt = st.LastRestartAt.Unix()
+			}
+		}
+
+		stats := p.Stats()
+		for _, method := range slices.Sorted(maps.Keys(stats)) {
+			st := stats[method]
+			ps.Rpcs = append(ps.Rpcs, &libadmin.PluginRpcStats{
+				Method:         method,
+				Calls:          st.Calls,
+				Errors:         st.Errors,
+				Timeouts:       st.Timeouts,
+				Rejected:       st.Rejected,
+				TotalLatencyUs: st.TotalLatency.Microseconds(),
+				MaxLatencyUs:   st.MaxLatency.Microseconds(),
+			})
 		}
+
+		statuses = append(statuses, ps)
 	}
 
-	return m.InstallPiperConfig(d.config)
+	return statuses
+}
+
+// adminAlgorithms returns the algorithms negotiated on the connection of
+// meta for the admin API, zero when it does not tell them.
+func adminAlgorithms(meta ssh.ConnMetadata) admin.Algorithms {
+	conn, ok := meta.(ssh.AlgorithmsConnMetadata)
+	if !ok {
+		return admin.Algorithms{}
+	}
+	algs := conn.Algorithms()
+	return admin.Algorithms{
+		KeyExchange: algs.KeyExchange,
+		HostKey:     algs.HostKey,
+		CipherIn:    algs.Read.Cipher,
+		CipherOut:   algs.Write.Cipher,
+		MACIn:       algs.Read.MAC,
+		MACOut:      algs.Write.MAC,
+	}
+}
+
+// reinstall installs the plugins again after the supervised plugin p
+// restarted, so callbacks it changed are picked up. Only p is asked for its
+// callbacks, the others keep theirs. On error the previous install is kept.
+func (d *daemon) reinstall(p *plugin.GrpcPlugin) {
+	d.installMu.Lock()
+	plugins := d.plugins
+	d.installMu.Unlock()
+
+	if len(plugins) == 0 {
+		return
+	}
+
+	if err := p.Refresh(); err != nil {
+		slog.Error("cannot refresh plugin after restart, keeping the previous callbacks", "plugin", p.Name, "error", err)
+		return
+	}
+
+	if err := d.install(plugins...); err != nil {
+		slog.Error("cannot install plugins again after restart, keeping the previous callbacks", "error", err)
+	}
 }
 
 // initScreenRecording prepares screen recording, if enabled via
@@ -423,9 +851,10 @@ func (d *daemon) install(plugins ...*plugin.GrpcPlugin) error {
 // directory/file is subsequently created/opened through that root (see
 // setupScreenRecording), so a symlink placed under the recording root --
 // whether pre-existing or planted while the daemon is running -- cannot be
-// used to escape it. It is a no-op if recording is disabled.
+// used to escape it. It is a no-op if recording is disabled or already
+// prepared.
 func (d *daemon) initScreenRecording() error {
-	if d.recorddir == "" {
+	if d.recorddir == "" || d.recordRoot != nil {
 		return nil
 	}
 
@@ -442,21 +871,60 @@ func (d *daemon) initScreenRecording() error {
 	return nil
 }
 
+// recordHistory adds the session that just ended to d.history, before it
+// is removed from d.adminRegistry.
+func (d *daemon) recordHistory(info admin.Session, end *libplugin.PipeStats, recorder screenRecorder) {
+	rec := admin.HistoryRecord{
+		Session:         info,
+		EndedAt:         time.UnixMilli(end.EndedAt),
+		DownstreamBytes: uint64(max(end.DownstreamBytes, 0)), //nolint:gosec // clamped to positive
+		UpstreamBytes:   uint64(max(end.UpstreamBytes, 0)),   //nolint:gosec // clamped to positive
+		CloseReason:     end.CloseReason,
+		Commands:        end.ExecCommands,
+		Channels:        end.Channels,
+		ExitStatuses:    end.ExitStatuses,
+
+		DroppedCommands:     end.DroppedExecCommands,
+		DroppedExitStatuses: end.DroppedExitStatuses,
+	}
+	if reason, killed := d.adminRegistry.Killed(info.ID); killed {
+		rec.CloseReason = "killed by admin"
+		if reason != "" {
+			rec.CloseReason += ": " + reason
+		}
+	}
+	if recorder != nil {
+		rec.RecordingFiles = recorder.Files()
+	}
+	if err := d.history.Add(rec); err != nil {
+		slog.Error("failed to record session history", "session_id", info.ID, "error", err)
+	}
+}
+
+// screenRecorder is a recorder set up by setupScreenRecording.
+type screenRecorder interface {
+	Close() error
+	// Files returns the paths of the recordings written so far, relative
+	// to the recording root.
+	Files() []string
+}
+
 // setupScreenRecording wires the screen-recording packet-inspection hooks
-// for a single piped connection into uphookchain/downhookchain.
+// for a single piped connection into uphookchain/downhookchain, recording
+// in format, see sessionPolicy.recordFormat.
 //
 // ok reports whether the caller should proceed with the connection. It is
-// true when screen recording is disabled (d.recordRoot == nil, closer is
-// nil) or was set up successfully (closer releases the recorder's resources
-// and must be called once the connection ends, if non-nil). It is false
+// true when screen recording is disabled (d.recordRoot == nil or format is
+// empty, recorder is nil) or was set up successfully (recorder must be
+// closed once the connection ends, if non-nil). It is false
 // when screen recording is enabled but this particular connection must be
 // rejected -- e.g. the downstream username fails the
 // --username-as-recorddir path-traversal guard, or the recording
 // directory/files could not be created -- in which case the caller must
 // abort the connection entirely, matching sshpiperd's historical
 // fail-closed behavior for screen recording.
-func (d *daemon) setupScreenRecording(p *ssh.PiperConn, uphookchain, downhookchain *hookChain) (closer func() error, ok bool) {
-	if d.recordRoot == nil {
+func (d *daemon) setupScreenRecording(p *ssh.PiperConn, format string, uphookchain, downhookchain *hookChain) (recorder screenRecorder, ok bool) {
+	if d.recordRoot == nil || format == "" {
 		return nil, true
 	}
 
@@ -478,21 +946,23 @@ func (d *daemon) setupScreenRecording(p *ssh.PiperConn, uphookchain, downhookcha
 		return nil, false
 	}
 
-	switch d.recordfmt {
+	labels := plugin.UpstreamLabels(p.ChallengeContext())
+
+	switch format {
 	case "asciicast":
 		prefix := ""
 		if d.usernameAsRecorddir {
 			// add prefix to avoid conflict
 			prefix = fmt.Sprintf("%d-", time.Now().Unix())
 		}
-		recorder := newAsciicastLogger(d.recordRoot, subdir, prefix)
+		recorder := newAsciicastLogger(d.recordRoot, subdir, prefix, labels)
 
 		uphookchain.append(ssh.InspectPacketHook(recorder.uphook))
 		downhookchain.append(ssh.InspectPacketHook(recorder.downhook))
 
-		return recorder.Close, true
+		return recorder, true
 	case "typescript":
-		recorder, err := newFilePtyLogger(d.recordRoot, subdir)
+		recorder, err := newFilePtyLogger(d.recordRoot, subdir, labels)
 		if err != nil {
 			slog.Error("cannot create screen recording logger", "error", err)
 			return nil, false
@@ -500,7 +970,7 @@ func (d *daemon) setupScreenRecording(p *ssh.PiperConn, uphookchain, downhookcha
 
 		uphookchain.append(ssh.InspectPacketHook(recorder.loggingTty))
 
-		return recorder.Close, true
+		return recorder, true
 	}
 
 	return nil, true
@@ -517,23 +987,58 @@ func (d *daemon) run() error {
 		defer d.recordRoot.Close()
 	}
 
+	d.accepting.Store(true)
+	defer d.accepting.Store(false)
+
+	var delay time.Duration
 	for {
 		conn, err := d.lis.Accept()
 		if err != nil {
-			slog.Debug("failed to accept connection", "error", err)
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+
+			d.acceptErrors.failed(time.Now())
+
+			// back off on errors such as EMFILE, like net/http
+			delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+			slog.Debug("failed to accept connection", "error", err, "retry_in", delay)
+			time.Sleep(delay)
 			continue
 		}
+		d.acceptErrors.reset()
+		delay = 0
 
 		slog.Debug("connection accepted", "remote_addr", conn.RemoteAddr())
 
 		go func(c net.Conn) {
 			defer c.Close()
 
+			ctx, span := tracer.Start(context.Background(), "sshpiperd.connection",
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attribute.String("downstream", c.RemoteAddr().String())),
+			)
+			defer span.End()
+
+			start := time.Now()
+
+			opts := d.options()
+			installed := d.installed.Load()
+			config := installed.config
+
 			pipec := make(chan *ssh.PiperConn)
 			errorc := make(chan error)
 
+			handshakeCtx, handshakeSpan := tracer.Start(ctx, "handshake")
+			handshake := &connTrace{ctx: handshakeCtx}
+			handshakeFailed := func(err error) {
+				handshake.endUpstreamAuth(err)
+				endSpan(handshakeSpan, err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
 			go func() {
-				p, err := ssh.NewSSHPiperConn(c, &d.config.PiperConfig)
+				p, err := ssh.NewSSHPiperConn(c, d.connPiperConfig(installed, c, handshake))
 				if err != nil {
 					errorc <- err
 					return
@@ -548,15 +1053,20 @@ func (d *daemon) run() error {
 			case p = <-pipec:
 			case err := <-errorc:
 				slog.Debug("connection establishing failed", "remote_addr", c.RemoteAddr(), "error", err)
-				if d.config.PipeCreateErrorCallback != nil {
-					d.config.PipeCreateErrorCallback(c, err)
+				d.metrics.handshakeDone(start, "failure")
+				handshakeFailed(err)
+				if config.PipeCreateErrorCallback != nil {
+					config.PipeCreateErrorCallback(c, err)
 				}
 
 				return
-			case <-time.After(d.loginGraceTime):
+			case <-time.After(opts.loginGraceTime):
 				slog.Debug("pipe establishing timeout, disconnected connection", "remote_addr", c.RemoteAddr())
-				if d.config.PipeCreateErrorCallback != nil {
-					d.config.PipeCreateErrorCallback(c, fmt.Errorf("pipe establishing timeout"))
+				d.metrics.handshakeDone(start, "timeout")
+				err := fmt.Errorf("pipe establishing timeout")
+				handshakeFailed(err)
+				if config.PipeCreateErrorCallback != nil {
+					config.PipeCreateErrorCallback(c, err)
 				}
 
 				return
@@ -564,6 +1074,20 @@ func (d *daemon) run() error {
 
 			defer p.Close()
 
+			d.metrics.handshakeDone(start, "success")
+			handshake.endUpstreamAuth(nil)
+			handshakeSpan.End()
+
+			span.SetAttributes(
+				attribute.String("session", plugin.GetUniqueID(p.ChallengeContext())),
+				attribute.String("downstream.user", p.DownstreamConnMeta().User()),
+				attribute.String("upstream", p.UpstreamConnMeta().RemoteAddr().String()),
+				attribute.String("upstream.user", p.UpstreamConnMeta().User()),
+			)
+			plugin.SetTraceContext(p.ChallengeContext(), ctx)
+			d.metrics.pipeOpened()
+			defer d.metrics.pipeClosed()
+
 			slog.Info(
 				"ssh connection pipe created",
 				"downstream_addr", p.DownstreamConnMeta().RemoteAddr(),
@@ -575,72 +1099,102 @@ func (d *daemon) run() error {
 			uphookchain := &hookChain{}
 			downhookchain := &hookChain{}
 
+			policy := d.sessionPolicy(opts, plugin.UpstreamSessionPolicy(p.ChallengeContext()))
+
+			var authorizer *channelAuthorizer
+			if config.AuthorizeChannelCallback != nil {
+				authorizer = newChannelAuthorizer(func(req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error) {
+					return config.AuthorizeChannelCallback(p.DownstreamConnMeta(), p.ChallengeContext(), req)
+				})
+			}
+
+			rotation := d.hostKeys != nil && d.hostKeys.window > 0
+			if policy.disableLocalForward || policy.disableRemoteForward || policy.disableAgentForward || policy.disableX11Forward || rotation || authorizer != nil {
+				filter := newForwardingFilter(policy.disableLocalForward, policy.disableRemoteForward)
+				filter.disableAgent = policy.disableAgentForward
+				filter.disableX11 = policy.disableX11Forward
+				filter.onReject = d.metrics.forwardingRejected
+				if authorizer != nil {
+					filter.authorize = authorizer.authorizeGlobal
+				}
+				if rotation {
+					sessionID := p.DownstreamConnMeta().SessionID()
+					filter.answer = func(request globalRequest) ([]byte, bool) {
+						return d.hostKeys.proveHostkeys(sessionID, request)
+					}
+				}
+				downhookchain.append(filter.down)
+				if policy.disableRemoteForward || filter.answer != nil || filter.authorize != nil {
+					// Only needed when down can generate its own reply to a
+					// global request: up must observe genuine upstream
+					// replies to earlier requests so those local replies
+					// can be released in the same order the client sent
+					// the requests. See forwardingFilter's docs.
+					uphookchain.append(filter.up)
+				}
+			}
+
+			// before the admin stream and the recorders, so that they only
+			// see what is forwarded, and before the env injector, its env
+			// requests are not the client's
+			if authorizer != nil {
+				uphookchain.append(authorizer.up)
+				downhookchain.append(authorizer.down)
+			}
+
 			// Register the live pipe with the admin registry (if enabled) so
-			// the admin gRPC service can list/kill/stream this session. The
+			// the admin gRPC service can list/kill/stream/attach this session. The
 			// streaming hook is appended to the existing hook chains so it
 			// shares packet inspection cost with the recorder.
+			var sessionInfo admin.Session
 			if d.adminRegistry != nil {
 				uniqID := plugin.GetUniqueID(p.ChallengeContext())
-				bc := d.adminRegistry.Add(admin.Session{
+				sessionInfo = admin.Session{
 					ID:             uniqID,
 					DownstreamUser: p.DownstreamConnMeta().User(),
 					DownstreamAddr: p.DownstreamConnMeta().RemoteAddr().String(),
 					UpstreamUser:   p.UpstreamConnMeta().User(),
 					UpstreamAddr:   p.UpstreamConnMeta().RemoteAddr().String(),
 					StartedAt:      time.Now(),
-				}, p)
+					Labels:         plugin.UpstreamLabels(p.ChallengeContext()),
+					ClientVersion:  string(p.DownstreamConnMeta().ClientVersion()),
+					ServerVersion:  string(p.UpstreamConnMeta().ServerVersion()),
+
+					DownstreamAlgorithms: adminAlgorithms(p.DownstreamConnMeta()),
+					UpstreamAlgorithms:   adminAlgorithms(p.UpstreamConnMeta()),
+				}
+				bc := d.adminRegistry.Add(sessionInfo, p)
 				defer d.adminRegistry.Remove(uniqID)
 
 				sh := admin.NewStreamHook(bc)
+				sh.SetPacketWriter(p)
+				d.adminRegistry.SetStreamHook(uniqID, sh)
 				uphookchain.append(sh.Up)
 				downhookchain.append(sh.Down)
 			}
 
-			closeRecorder, ok := d.setupScreenRecording(p, uphookchain, downhookchain)
+			recorder, ok := d.setupScreenRecording(p, policy.recordFormat, uphookchain, downhookchain)
 			if !ok {
+				d.metrics.recordingFailed()
 				return
 			}
-			if closeRecorder != nil {
-				defer closeRecorder()
+			if recorder != nil {
+				defer recorder.Close()
 			}
 
-			if d.filterHostkeysReqeust {
-				uphookchain.append(func(b []byte) (ssh.PipePacketHookMethod, []byte, error) {
-					if b[0] == 80 {
-						var x struct {
-							RequestName string `sshtype:"80"`
-						}
-						_ = ssh.Unmarshal(b, &x)
-						if x.RequestName == "hostkeys-prove-00@openssh.com" || x.RequestName == "hostkeys-00@openssh.com" {
-							return ssh.PipePacketHookTransform, nil, nil
-						}
-					}
-
-					return ssh.PipePacketHookTransform, b, nil
-				})
+			announcer := d.hostKeys.newHostkeysAnnouncer(opts.filterHostkeysReqeust)
+			if announcer != nil {
+				uphookchain.append(announcer.up)
 			}
 
-			if d.replyPing {
+			if opts.replyPing {
 				downhookchain.append(ssh.PingPacketReply)
 			}
 
-			if d.disableLocalForward || d.disableRemoteForward {
-				filter := newForwardingFilter(d.disableLocalForward, d.disableRemoteForward)
-				downhookchain.append(filter.down)
-				if d.disableRemoteForward {
-					// Only needed when down can generate its own reply to a
-					// blocked global request: up must observe genuine
-					// upstream replies to earlier requests so those local
-					// replies can be released in the same order the client
-					// sent the requests. See forwardingFilter's docs.
-					uphookchain.append(filter.up)
-				}
-			}
-
 			env := plugin.UpstreamEnv(p.ChallengeContext())
-			if len(d.injectEnv) > 0 {
-				merged := make(map[string]string, len(d.injectEnv)+len(env))
-				for k, v := range d.injectEnv {
+			if len(opts.injectEnv) > 0 {
+				merged := make(map[string]string, len(opts.injectEnv)+len(env))
+				for k, v := range opts.injectEnv {
 					merged[k] = v
 				}
 				// Plugin-provided env wins on collision.
@@ -655,14 +1209,67 @@ func (d *daemon) run() error {
 				downhookchain.append(inj.down)
 			}
 
-			if d.config.PipeStartCallback != nil {
-				d.config.PipeStartCallback(p.DownstreamConnMeta(), p.ChallengeContext())
+			// after the recorders, they must see the confirmation of the
+			// channel the message is written to
+			if policy.postAuthMessage != "" {
+				message := newPostAuthMessage(p, policy.postAuthMessage)
+				uphookchain.append(message.up)
+				downhookchain.append(message.down)
+			}
+
+			var limits *sessionLimits
+			if policy.idleTimeout > 0 || policy.maxDuration > 0 {
+				limits = newSessionLimits(policy.idleTimeout, policy.maxDuration, time.Now())
+				uphookchain.append(limits.hook)
+				downhookchain.append(limits.hook)
+
+				done := make(chan struct{})
+				defer close(done)
+				go limits.watch(time.Now(), p.Close, done)
+			}
+
+			if policy.bandwidthLimit > 0 {
+				uphookchain.append(newBandwidthLimiter(policy.bandwidthLimit).hook)
+				downhookchain.append(newBandwidthLimiter(policy.bandwidthLimit).hook)
+			}
+
+			// last, so packets answered or dropped by the hooks above are
+			// not counted
+			uphookchain.append(d.metrics.pipeHook("upstream"))
+			downhookchain.append(d.metrics.pipeHook("downstream"))
+
+			var stats *pipeStats
+			if config.PipeEndCallback != nil || d.history != nil {
+				stats = newPipeStats(time.Now())
+				uphookchain.append(stats.hook("upstream"))
+				downhookchain.append(stats.hook("downstream"))
+			}
+
+			if config.PipeStartCallback != nil {
+				config.PipeStartCallback(p.DownstreamConnMeta(), p.ChallengeContext())
+			}
+
+			if err := announcer.announce(p.WriteDownstreamPacket); err != nil {
+				slog.Debug("failed to announce host keys", "error", err)
 			}
 
 			err = p.WaitWithHook(uphookchain.hook(), downhookchain.hook())
+			if limits != nil && limits.err() != nil {
+				err = limits.err()
+			}
 
-			if d.config.PipeErrorCallback != nil {
-				d.config.PipeErrorCallback(p.DownstreamConnMeta(), p.ChallengeContext(), err)
+			if config.PipeErrorCallback != nil {
+				config.PipeErrorCallback(p.DownstreamConnMeta(), p.ChallengeContext(), err)
+			}
+
+			if stats != nil {
+				end := stats.end(time.Now(), err)
+				if config.PipeEndCallback != nil {
+					config.PipeEndCallback(p.DownstreamConnMeta(), p.ChallengeContext(), end)
+				}
+				if d.history != nil {
+					d.recordHistory(sessionInfo, end, recorder)
+				}
 			}
 
 			slog.Info("connection closed", "remote_addr", c.RemoteAddr(), "reason", err)
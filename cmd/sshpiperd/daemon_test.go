@@ -335,7 +335,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		signers, err := loadHostKeys(ctx)
+		signers, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err != nil {
 			t.Fatalf("expected no error, got %v", err)
 		}
@@ -356,7 +356,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error when cert glob matches nothing, got nil")
 		}
@@ -374,7 +374,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for malformed glob, got nil")
 		}
@@ -389,7 +389,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for invalid base64 key-data, got nil")
 		}
@@ -405,7 +405,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for garbage key-data, got nil")
 		}
@@ -426,7 +426,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for unreadable key file, got nil")
 		}
@@ -450,7 +450,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for invalid key file, got nil")
 		}
@@ -471,7 +471,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "always",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error when cert doesn't match generated key, got nil")
 		}
@@ -489,7 +489,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error when no cert matches key, got nil")
 		}
@@ -509,7 +509,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error when only user cert available, got nil")
 		}
@@ -527,7 +527,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		signers, err := loadHostKeys(ctx)
+		signers, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err != nil {
 			t.Fatalf("expected no error, got %v", err)
 		}
@@ -548,7 +548,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error when no cert matches base64 key, got nil")
 		}
@@ -568,7 +568,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error when only user cert available, got nil")
 		}
@@ -586,7 +586,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		signers, err := loadHostKeys(ctx)
+		signers, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err != nil {
 			t.Fatalf("expected no error, got %v", err)
 		}
@@ -607,7 +607,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		signers, err := loadHostKeys(ctx)
+		signers, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err != nil {
 			t.Fatalf("expected no error, got %v", err)
 		}
@@ -628,7 +628,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for mismatched key + cert-data, got nil")
 		}
@@ -646,7 +646,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for user cert via cert-data, got nil")
 		}
@@ -664,7 +664,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for invalid base64 cert-data, got nil")
 		}
@@ -682,7 +682,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		signers, err := loadHostKeys(ctx)
+		signers, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err != nil {
 			t.Fatalf("expected no error, got %v", err)
 		}
@@ -703,7 +703,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for mismatched file key + cert-data, got nil")
 		}
@@ -723,7 +723,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		signers, err := loadHostKeys(ctx)
+		signers, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err != nil {
 			t.Fatalf("expected cert-data to take priority, got error: %v", err)
 		}
@@ -748,7 +748,7 @@ func TestLoadHostKeys(t *testing.T) {
 			"server-key-generate-mode": "disable",
 		})
 
-		_, err := loadHostKeys(ctx)
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
 		if err == nil {
 			t.Fatal("expected error for cert-data with multiple keys, got nil")
 		}
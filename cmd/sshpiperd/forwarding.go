@@ -16,8 +16,8 @@ const (
 	connectionFailedAdministratively = 1
 )
 
-// forwardingFilter blocks local/remote port-forwarding requests on the
-// downstream->upstream stream.
+// forwardingFilter blocks local/remote port-forwarding, agent forwarding
+// and X11 forwarding requests on the downstream->upstream stream.
 //
 // Global requests (SSH_MSG_GLOBAL_REQUEST) are replied to with
 // SSH_MSG_REQUEST_SUCCESS/FAILURE, neither of which carries a request ID:
@@ -38,6 +38,26 @@ type forwardingFilter struct {
 	disableLocal  bool
 	disableRemote bool
 
+	// disableAgent and disableX11 block the auth-agent-req@openssh.com and
+	// x11-req channel requests, failed like denied channel requests, see
+	// deniedChannelRequest.
+	disableAgent bool
+	disableX11   bool
+
+	// answer, when set, may answer an allowed want-reply global request
+	// locally instead of forwarding it upstream, e.g. hostkeys-prove-00
+	// during a host key rotation. The reply is sequenced exactly like a
+	// blocked request's failure.
+	answer func(request globalRequest) (reply []byte, ok bool)
+
+	// authorize, when set, is asked about remote forwarding requests not
+	// blocked by disableRemote, those it refuses are blocked too.
+	authorize func(request globalRequest) bool
+
+	// onReject, when set, is called with local, remote, agent or x11 for
+	// every blocked forwarding request.
+	onReject func(kind string)
+
 	mu      sync.Mutex
 	cond    *sync.Cond
 	seq     int
@@ -107,6 +127,12 @@ func isLocalForwardChannelType(t string) bool {
 	}
 }
 
+func (f *forwardingFilter) rejected(kind string) {
+	if f.onReject != nil {
+		f.onReject(kind)
+	}
+}
+
 func (f *forwardingFilter) down(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
 	if len(packet) == 0 {
 		return ssh.PipePacketHookTransform, packet, nil
@@ -119,7 +145,11 @@ func (f *forwardingFilter) down(packet []byte) (ssh.PipePacketHookMethod, []byte
 			return ssh.PipePacketHookTransform, packet, nil
 		}
 
-		blocked := f.disableRemote && isRemoteForwardRequestType(request.Type)
+		blocked := isRemoteForwardRequestType(request.Type) &&
+			(f.disableRemote || (f.authorize != nil && !f.authorize(request)))
+		if blocked {
+			f.rejected("remote")
+		}
 
 		if !request.WantReply {
 			if blocked {
@@ -137,13 +167,21 @@ func (f *forwardingFilter) down(packet []byte) (ssh.PipePacketHookMethod, []byte
 		f.seq++
 		f.mu.Unlock()
 
+		reply := ssh.Marshal(globalRequestFailure{})
 		if !blocked {
-			return ssh.PipePacketHookTransform, packet, nil
+			var ok bool
+			if f.answer != nil {
+				reply, ok = f.answer(request)
+			}
+
+			if !ok {
+				return ssh.PipePacketHookTransform, packet, nil
+			}
 		}
 
 		// Wait until every earlier want-reply global request has already
 		// been replied to (by up, for ones forwarded upstream) before
-		// sending our own locally-generated failure, so replies reach the
+		// sending our own locally-generated reply, so replies reach the
 		// client in the same order the requests were sent.
 		f.mu.Lock()
 		for f.replied < mySeq {
@@ -153,7 +191,7 @@ func (f *forwardingFilter) down(packet []byte) (ssh.PipePacketHookMethod, []byte
 		f.cond.Broadcast()
 		f.mu.Unlock()
 
-		return ssh.PipePacketHookReply, ssh.Marshal(globalRequestFailure{}), nil
+		return ssh.PipePacketHookReply, reply, nil
 
 	case msgChannelOpen:
 		var open channelOpen
@@ -163,11 +201,40 @@ func (f *forwardingFilter) down(packet []byte) (ssh.PipePacketHookMethod, []byte
 		if !f.disableLocal || !isLocalForwardChannelType(open.Type) {
 			return ssh.PipePacketHookTransform, packet, nil
 		}
+		f.rejected("local")
 		return ssh.PipePacketHookReply, ssh.Marshal(channelOpenFailure{
 			RecipientChannel: open.SenderChannel,
 			ReasonCode:       connectionFailedAdministratively,
 			Description:      "port forwarding is disabled",
 		}), nil
+
+	case msgChannelRequest:
+		if !f.disableAgent && !f.disableX11 {
+			return ssh.PipePacketHookTransform, packet, nil
+		}
+
+		var r channelRequest
+		if err := ssh.Unmarshal(packet, &r); err != nil {
+			return ssh.PipePacketHookTransform, packet, nil
+		}
+
+		switch {
+		case f.disableAgent && r.Request == "auth-agent-req@openssh.com":
+			f.rejected("agent")
+		case f.disableX11 && r.Request == "x11-req":
+			f.rejected("x11")
+		default:
+			return ssh.PipePacketHookTransform, packet, nil
+		}
+
+		if !r.WantReply {
+			return ssh.PipePacketHookTransform, nil, nil
+		}
+		return ssh.PipePacketHookTransform, ssh.Marshal(channelRequest{
+			RecipientChannel: r.RecipientChannel,
+			Request:          deniedChannelRequest,
+			WantReply:        true,
+		}), nil
 	}
 
 	return ssh.PipePacketHookTransform, packet, nil
@@ -180,9 +247,9 @@ func (f *forwardingFilter) down(packet []byte) (ssh.PipePacketHookMethod, []byte
 // here lets a later, blocked, want-reply request's locally-generated
 // failure in down proceed only once every earlier reply has already gone
 // out, preserving the client-observed reply order. up must only be
-// installed when disableRemote is set, since that is the only case where
-// down can generate a reply of its own that needs to be sequenced against
-// genuine upstream replies.
+// installed when disableRemote or answer is set, since those are the only
+// cases where down can generate a reply of its own that needs to be
+// sequenced against genuine upstream replies.
 func (f *forwardingFilter) up(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
 	if len(packet) > 0 && (packet[0] == msgRequestSuccess || packet[0] == msgRequestFailure) {
 		f.mu.Lock()
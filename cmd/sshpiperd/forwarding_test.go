@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 	"time"
 
@@ -214,3 +215,95 @@ func TestForwardingFilterPreservesGlobalRequestReplyOrder(t *testing.T) {
 		t.Fatalf("reply = %v, want SSH_MSG_REQUEST_FAILURE", blockedReply)
 	}
 }
+
+// TestForwardingFilterAnswerPreservesGlobalRequestReplyOrder verifies that a
+// reply produced by answer is sequenced behind the upstream reply to an
+// earlier forwarded request, the same as a blocked request's failure.
+func TestForwardingFilterAnswerPreservesGlobalRequestReplyOrder(t *testing.T) {
+	filter := newForwardingFilter(false, false)
+	filter.answer = func(request globalRequest) ([]byte, bool) {
+		if request.Type != "answer-me" {
+			return nil, false
+		}
+		return []byte{msgRequestSuccess}, true
+	}
+
+	unrelated := ssh.Marshal(globalRequest{Type: "keepalive@openssh.com", WantReply: true})
+	method, out, err := filter.down(unrelated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != ssh.PipePacketHookTransform || !bytes.Equal(out, unrelated) {
+		t.Fatalf("expected unrelated request to be forwarded, got %v %v", method, out)
+	}
+
+	done := make(chan struct{})
+	var (
+		answeredMethod ssh.PipePacketHookMethod
+		answeredReply  []byte
+	)
+	go func() {
+		answeredMethod, answeredReply, _ = filter.down(ssh.Marshal(globalRequest{Type: "answer-me", WantReply: true}))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("down answered the request before the earlier request's upstream reply arrived")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if _, _, err := filter.up([]byte{msgRequestFailure}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for down to answer the request after the earlier reply arrived")
+	}
+
+	if answeredMethod != ssh.PipePacketHookReply {
+		t.Fatalf("method = %v, want PipePacketHookReply", answeredMethod)
+	}
+	if !bytes.Equal(answeredReply, []byte{msgRequestSuccess}) {
+		t.Fatalf("reply = %v, want SSH_MSG_REQUEST_SUCCESS", answeredReply)
+	}
+}
+
+func TestForwardingFilterDisablesAgentAndX11Forwarding(t *testing.T) {
+	filter := newForwardingFilter(false, false)
+	filter.disableAgent = true
+	filter.disableX11 = true
+
+	var rejected []string
+	filter.onReject = func(kind string) { rejected = append(rejected, kind) }
+
+	for _, tt := range []struct {
+		request   string
+		wantReply bool
+		want      []byte
+	}{
+		{"auth-agent-req@openssh.com", true, ssh.Marshal(channelRequest{RecipientChannel: 7, Request: deniedChannelRequest, WantReply: true})},
+		{"auth-agent-req@openssh.com", false, nil},
+		{"x11-req", true, ssh.Marshal(channelRequest{RecipientChannel: 7, Request: deniedChannelRequest, WantReply: true})},
+		{"pty-req", true, ssh.Marshal(channelRequest{RecipientChannel: 7, Request: "pty-req", WantReply: true})},
+	} {
+		t.Run(tt.request, func(t *testing.T) {
+			method, out, err := filter.down(ssh.Marshal(channelRequest{RecipientChannel: 7, Request: tt.request, WantReply: tt.wantReply}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if method != ssh.PipePacketHookTransform {
+				t.Fatalf("method = %v, want PipePacketHookTransform", method)
+			}
+			if !bytes.Equal(out, tt.want) {
+				t.Fatalf("packet = %v, want %v", out, tt.want)
+			}
+		})
+	}
+
+	if want := []string{"agent", "agent", "x11"}; strings.Join(rejected, ",") != strings.Join(want, ",") {
+		t.Errorf("rejected = %v, want %v", rejected, want)
+	}
+}
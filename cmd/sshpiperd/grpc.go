@@ -13,6 +13,39 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+// grpcTransportCredentials returns the dial option shared by every outgoing
+// gRPC connection of sshpiperd: plaintext when insecureTransport is set,
+// otherwise TLS with the given client keypair and optional CA.
+func grpcTransportCredentials(insecureTransport bool, cert, key, cacert string) (grpc.DialOption, error) {
+	if insecureTransport {
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+	}
+
+	if cacert != "" {
+		ca, err := os.ReadFile(cacert)
+		if err != nil {
+			return nil, err
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to append ca")
+		}
+
+		config.RootCAs = certPool
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(config)), nil
+}
+
 func createNetGrpcPlugin(args []string) (grpcPlugin *plugin.GrpcPlugin, err error) {
 	app := &cli.App{
 		Name:            "grpc",
@@ -48,35 +81,9 @@ func createNetGrpcPlugin(args []string) (grpcPlugin *plugin.GrpcPlugin, err erro
 			},
 		},
 		Action: func(c *cli.Context) error {
-			var secopt grpc.DialOption
-			if c.Bool("insecure") {
-				secopt = grpc.WithTransportCredentials(insecure.NewCredentials())
-			} else {
-
-				clientCert, err := tls.LoadX509KeyPair(c.String("cert"), c.String("key"))
-				if err != nil {
-					return err
-				}
-
-				config := &tls.Config{
-					Certificates: []tls.Certificate{clientCert},
-				}
-
-				cacert := c.String("cacert")
-				if cacert != "" {
-					ca, err := os.ReadFile(cacert)
-					if err != nil {
-						return err
-					}
-					certPool := x509.NewCertPool()
-					if !certPool.AppendCertsFromPEM(ca) {
-						return fmt.Errorf("failed to append ca")
-					}
-
-					config.RootCAs = certPool
-				}
-
-				secopt = grpc.WithTransportCredentials(credentials.NewTLS(config))
+			secopt, err := grpcTransportCredentials(c.Bool("insecure"), c.String("cert"), c.String("key"), c.String("cacert"))
+			if err != nil {
+				return err
 			}
 
 			conn, err := grpc.NewClient(c.String("endpoint"), secopt)
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// healthPingTimeout bounds the Ping of each plugin done by a readiness
+// check.
+const healthPingTimeout = 3 * time.Second
+
+// acceptFailureGrace is how long Accept must keep failing before the
+// liveness probe fails, a single error such as EMFILE must not get a
+// healthy daemon restarted.
+const acceptFailureGrace = 30 * time.Second
+
+// acceptErrors tracks the streak of failing Accepts since the last one
+// that succeeded.
+type acceptErrors struct {
+	mu    sync.Mutex
+	first time.Time
+	last  time.Time
+}
+
+func (a *acceptErrors) failed(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.first.IsZero() {
+		a.first = now
+	}
+	a.last = now
+}
+
+func (a *acceptErrors) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.first = time.Time{}
+	a.last = time.Time{}
+}
+
+// failing reports whether Accept kept failing for acceptFailureGrace and
+// still fails. An error followed by no further Accept, as on an idle
+// node, stops counting after the grace.
+func (a *acceptErrors) failing(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.first.IsZero() {
+		return false
+	}
+
+	return now.Sub(a.first) >= acceptFailureGrace && now.Sub(a.last) < acceptFailureGrace
+}
+
+// healthCheck is the result of one of the readiness checks.
+type healthCheck struct {
+	name string
+	err  error
+}
+
+// liveness reports whether the accept loop is running and accepting.
+func (d *daemon) liveness() error {
+	if !d.accepting.Load() {
+		return fmt.Errorf("accept loop is not running")
+	}
+
+	if d.acceptErrors.failing(time.Now()) {
+		return fmt.Errorf("accepting connections is failing for more than %v", acceptFailureGrace)
+	}
+
+	return nil
+}
+
+// readiness runs the checks new connections depend on: the daemon is not
+// draining, every plugin is connected and answers Ping, the host keys are
+// loaded and the recording dir, if any, is writable.
+func (d *daemon) readiness(ctx context.Context) []healthCheck {
+	checks := []healthCheck{
+		{name: "draining", err: d.drainingCheck()},
+		{name: "plugins", err: d.pluginsCheck(ctx)},
+		{name: "hostkeys", err: d.hostKeysCheck()},
+	}
+
+	if d.recordRoot != nil {
+		checks = append(checks, healthCheck{name: "recorddir", err: d.recordDirCheck()})
+	}
+
+	return checks
+}
+
+func (d *daemon) drainingCheck() error {
+	if d.draining.Load() {
+		return fmt.Errorf("sshpiperd is draining")
+	}
+
+	return nil
+}
+
+func (d *daemon) pluginsCheck(ctx context.Context) error {
+	if d.installed.Load() == nil {
+		return fmt.Errorf("plugins are not installed")
+	}
+
+	for _, sp := range d.supervised {
+		if !sp.Available() {
+			return fmt.Errorf("plugin %v is restarting", sp.Name)
+		}
+	}
+
+	d.installMu.Lock()
+	plugins := d.plugins
+	d.installMu.Unlock()
+
+	for _, p := range plugins {
+		pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+		err := p.Ping(pingCtx)
+		cancel()
+
+		if err != nil {
+			return fmt.Errorf("plugin %v does not answer: %w", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *daemon) hostKeysCheck() error {
+	if d.hostKeys == nil || len(d.hostKeys.presented()) == 0 {
+		return fmt.Errorf("no host keys loaded")
+	}
+
+	return nil
+}
+
+// recordDirCheck creates and removes a file in the recording dir.
+func (d *daemon) recordDirCheck() error {
+	name := ".sshpiperd-readyz-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	f, err := d.recordRoot.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("recording dir is not writable: %w", err)
+	}
+	_ = f.Close()
+
+	return d.recordRoot.Remove(name)
+}
+
+func writeHealth(w http.ResponseWriter, checks []healthCheck) {
+	status := http.StatusOK
+	for _, c := range checks {
+		if c.err != nil {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+
+	for _, c := range checks {
+		if c.err != nil {
+			_, _ = fmt.Fprintf(w, "[-]%v failed: %v\n", c.name, c.err)
+		} else {
+			_, _ = fmt.Fprintf(w, "[+]%v ok\n", c.name)
+		}
+	}
+
+	if status == http.StatusOK {
+		_, _ = io.WriteString(w, "ok\n")
+	}
+}
+
+// registerHealth serves the liveness probe at /healthz and the readiness
+// probe at /readyz on mux.
+func (d *daemon) registerHealth(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, []healthCheck{{name: "accept", err: d.liveness()}})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, d.readiness(r.Context()))
+	})
+}
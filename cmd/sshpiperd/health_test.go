@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// startTestPlugin serves an empty libplugin plugin on loopback and returns
+// it dialed, with the server to stop it.
+func startTestPlugin(t *testing.T) (*plugin.GrpcPlugin, *grpc.Server) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer()
+	p, err := libplugin.NewFromGrpc(libplugin.SshPiperPluginConfig{}, s, lis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = p.Serve() }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	g, err := plugin.DialGrpc(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Name = "test"
+
+	return g, s
+}
+
+func getHealth(t *testing.T, d *daemon, path string) (int, string) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	d.registerHealth(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rec.Code, string(body)
+}
+
+func TestHealthLiveness(t *testing.T) {
+	d := &daemon{}
+
+	if code, _ := getHealth(t, d, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("code = %d before the accept loop runs, want 503", code)
+	}
+
+	d.accepting.Store(true)
+	if code, body := getHealth(t, d, "/healthz"); code != http.StatusOK {
+		t.Errorf("code = %d, want 200: %s", code, body)
+	}
+
+	d.acceptErrors.failed(time.Now())
+	if code, body := getHealth(t, d, "/healthz"); code != http.StatusOK {
+		t.Errorf("code = %d after a single accept error, want 200: %s", code, body)
+	}
+
+	d.acceptErrors.reset()
+	d.acceptErrors.failed(time.Now().Add(-acceptFailureGrace))
+	d.acceptErrors.failed(time.Now())
+	if code, _ := getHealth(t, d, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("code = %d while accept keeps failing, want 503", code)
+	}
+
+	d.acceptErrors.reset()
+	if code, body := getHealth(t, d, "/healthz"); code != http.StatusOK {
+		t.Errorf("code = %d after accept succeeded again, want 200: %s", code, body)
+	}
+}
+
+func TestAcceptErrorsFailing(t *testing.T) {
+	start := time.Now()
+
+	var a acceptErrors
+	a.failed(start)
+	if a.failing(start.Add(time.Second)) {
+		t.Error("a single error must not fail liveness")
+	}
+	if a.failing(start.Add(2 * acceptFailureGrace)) {
+		t.Error("an old error not followed by others must not fail liveness")
+	}
+
+	a.failed(start.Add(acceptFailureGrace))
+	if !a.failing(start.Add(acceptFailureGrace + time.Second)) {
+		t.Error("errors persisting for the grace must fail liveness")
+	}
+}
+
+func TestHealthReadiness(t *testing.T) {
+	g, server := startTestPlugin(t)
+
+	root, err := os.OpenRoot(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	d := &daemon{
+		hostKeys:   newHostKeyRing([]ssh.Signer{newTestHostKey(t)}, nil, 0),
+		plugins:    []*plugin.GrpcPlugin{g},
+		recordRoot: root,
+	}
+
+	if code, body := getHealth(t, d, "/readyz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "[-]plugins") {
+		t.Errorf("expected plugins to be not ready before install, got %d: %s", code, body)
+	}
+
+	d.installed.Store(&installedPlugins{})
+
+	code, body := getHealth(t, d, "/readyz")
+	if code != http.StatusOK {
+		t.Fatalf("code = %d, want 200: %s", code, body)
+	}
+	for _, check := range []string{"[+]draining", "[+]plugins", "[+]hostkeys", "[+]recorddir"} {
+		if !strings.Contains(body, check) {
+			t.Errorf("expected %s in %s", check, body)
+		}
+	}
+
+	entries, err := os.ReadDir(root.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the recording dir check to clean up, got %v", entries)
+	}
+
+	d.draining.Store(true)
+	if code, body := getHealth(t, d, "/readyz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "[-]draining") {
+		t.Errorf("expected not ready while draining, got %d: %s", code, body)
+	}
+	d.draining.Store(false)
+
+	server.Stop()
+	if code, body := getHealth(t, d, "/readyz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "[-]plugins failed: plugin test does not answer") {
+		t.Errorf("expected not ready with the plugin down, got %d: %s", code, body)
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	hostkeysRequestType      = "hostkeys-00@openssh.com"
+	hostkeysProveRequestType = "hostkeys-prove-00@openssh.com"
+)
+
+type globalRequestSuccess struct {
+	Data []byte `ssh:"rest" sshtype:"81"`
+}
+
+// hostKeyRing holds the host keys presented to downstream clients and
+// swaps them when reloaded.
+//
+// Without a rotation window a reload takes effect for the next connection.
+// With one, the previously presented keys stay in use until the window
+// expires while both the old and new keys are advertised to clients via the
+// OpenSSH hostkeys-00@openssh.com extension, so clients with UpdateHostKeys
+// enabled can learn the new keys before the old ones are retired.
+type hostKeyRing struct {
+	window time.Duration
+	// remoteSigner holds the remote signer connections of the loaded keys,
+	// closed once none of the keys signing through them are in use.
+	remoteSigner *remoteSignerConn
+
+	// reloadMu serializes reloads, so that the remote signer connection
+	// used by a load is the one of its keys.
+	reloadMu sync.Mutex
+
+	mu       sync.Mutex
+	load     func() ([]ssh.Signer, error)
+	current  []ssh.Signer
+	previous []ssh.Signer
+	retireAt time.Time
+	// currentConn and previousConn are the remoteSigner keys of the
+	// connections of current and previous, empty without a remote signer.
+	currentConn  string
+	previousConn string
+}
+
+// hostKeyStatus is a snapshot of the keys held by a hostKeyRing.
+type hostKeyStatus struct {
+	current  []ssh.Signer
+	previous []ssh.Signer
+	retireAt time.Time
+}
+
+// newHostKeyRing returns a ring presenting signers. load is how reload
+// reads the keys again.
+func newHostKeyRing(signers []ssh.Signer, load func() ([]ssh.Signer, error), window time.Duration) *hostKeyRing {
+	return &hostKeyRing{
+		load:         load,
+		window:       window,
+		current:      signers,
+		remoteSigner: &remoteSignerConn{},
+	}
+}
+
+// useRemoteSigner sets the remote signer connections the current keys were
+// loaded with, which later reloads must use too.
+func (r *hostKeyRing) useRemoteSigner(c *remoteSignerConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remoteSigner = c
+	r.currentConn = c.lastKey()
+}
+
+// rotating reports whether a rotation window is open. The caller must hold
+// r.mu.
+func (r *hostKeyRing) rotating() bool {
+	return len(r.previous) > 0 && time.Now().Before(r.retireAt)
+}
+
+// reload loads the host keys again and swaps them in. On error the keys in
+// use are left untouched.
+func (r *hostKeyRing) reload() (hostKeyStatus, error) {
+	r.mu.Lock()
+	load := r.load
+	r.mu.Unlock()
+
+	return r.reloadFrom(load)
+}
+
+// reloadFrom is reload with a different loader, which on success replaces
+// the one used by later reloads.
+func (r *hostKeyRing) reloadFrom(load func() ([]ssh.Signer, error)) (hostKeyStatus, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	r.remoteSigner.begin()
+	signers, err := load()
+	if err != nil {
+		return hostKeyStatus{}, err
+	}
+	conn := r.remoteSigner.lastKey()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.load = load
+
+	if r.window > 0 {
+		// a reload during an open window replaces the keys being rolled
+		// out, the keys clients currently trust are still the old ones
+		if !r.rotating() {
+			r.previous = r.current
+			r.previousConn = r.currentConn
+		}
+		r.retireAt = time.Now().Add(r.window)
+		time.AfterFunc(r.window, r.retire)
+	} else {
+		r.previous = nil
+		r.previousConn = ""
+		r.retireAt = time.Time{}
+	}
+	r.current = signers
+	r.currentConn = conn
+	r.keepConnsLocked()
+
+	if r.rotating() {
+		slog.Info("host keys reloaded, rotation window open", "fingerprints", hostKeyFingerprints(r.current), "retiring", hostKeyFingerprints(r.previous), "retire_at", r.retireAt)
+	} else {
+		slog.Info("host keys reloaded", "fingerprints", hostKeyFingerprints(r.current))
+	}
+
+	return r.statusLocked(), nil
+}
+
+// retire drops the previous keys, and closes their remote signer
+// connection, once the rotation window is over. A reload extending the
+// window leaves it to its own timer.
+func (r *hostKeyRing) retire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.previous) == 0 || r.rotating() {
+		return
+	}
+
+	r.previous = nil
+	r.previousConn = ""
+	r.keepConnsLocked()
+	slog.Info("rotation window closed, retired host keys dropped", "fingerprints", hostKeyFingerprints(r.current))
+}
+
+// keepConnsLocked closes the remote signer connections no presented or
+// advertised key signs through. The caller must hold r.mu.
+func (r *hostKeyRing) keepConnsLocked() {
+	keys := []string{r.currentConn}
+	if r.rotating() {
+		keys = append(keys, r.previousConn)
+	}
+
+	r.remoteSigner.keep(keys...)
+}
+
+func (r *hostKeyRing) statusLocked() hostKeyStatus {
+	if !r.rotating() {
+		return hostKeyStatus{current: r.current}
+	}
+
+	return hostKeyStatus{
+		current:  r.current,
+		previous: r.previous,
+		retireAt: r.retireAt,
+	}
+}
+
+// presented returns the keys new connections are served with.
+func (r *hostKeyRing) presented() []ssh.Signer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rotating() {
+		return r.previous
+	}
+
+	return r.current
+}
+
+// advertised returns every key to announce through hostkeys-00@openssh.com
+// while a rotation window is open, and nil otherwise.
+func (r *hostKeyRing) advertised() []ssh.Signer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.rotating() {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var signers []ssh.Signer
+	for _, s := range append(append([]ssh.Signer{}, r.previous...), r.current...) {
+		blob := string(plainHostKey(s.PublicKey()).Marshal())
+		if seen[blob] {
+			continue
+		}
+		seen[blob] = true
+		signers = append(signers, s)
+	}
+
+	return signers
+}
+
+// piperConfig returns a copy of base serving the presented host keys. base
+// must not have any host key added, otherwise the copy would share them.
+func (r *hostKeyRing) piperConfig(base *ssh.PiperConfig) *ssh.PiperConfig {
+	config := *base
+	for _, signer := range r.presented() {
+		config.AddHostKey(signer)
+	}
+
+	return &config
+}
+
+// hostkeysMessage returns the hostkeys-00@openssh.com message announcing
+// the advertised keys, nil outside a rotation window.
+func (r *hostKeyRing) hostkeysMessage() []byte {
+	signers := r.advertised()
+	if len(signers) == 0 {
+		return nil
+	}
+
+	var data []byte
+	for _, s := range signers {
+		data = appendSSHString(data, plainHostKey(s.PublicKey()).Marshal())
+	}
+
+	return ssh.Marshal(globalRequest{
+		Type: hostkeysRequestType,
+		Data: data,
+	})
+}
+
+// hostkeysAnnouncer tells a downstream client the daemon's keys through
+// hostkeys-00@openssh.com while a rotation window is open, whether or not
+// the upstream sends the message. OpenSSH clients disconnect on a second
+// one, so it is sent at most once per session.
+type hostkeysAnnouncer struct {
+	ring      *hostKeyRing
+	drop      bool
+	announced atomic.Bool
+}
+
+// newHostkeysAnnouncer returns the announcer of a session, which drops the
+// upstream's hostkeys-00@openssh.com messages when drop is set. It is nil
+// when there is nothing to announce nor drop.
+func (r *hostKeyRing) newHostkeysAnnouncer(drop bool) *hostkeysAnnouncer {
+	if r == nil && !drop {
+		return nil
+	}
+
+	return &hostkeysAnnouncer{ring: r, drop: drop}
+}
+
+// announce sends the daemon's keys with write, the downstream packet
+// writer, if a rotation window is open.
+func (a *hostkeysAnnouncer) announce(write func([]byte) error) error {
+	if a == nil || a.ring == nil {
+		return nil
+	}
+
+	msg := a.ring.hostkeysMessage()
+	if msg == nil || a.announced.Swap(true) {
+		return nil
+	}
+
+	return write(msg)
+}
+
+// up handles the hostkeys-00@openssh.com message the upstream sends after
+// authentication. The upstream's keys mean nothing to the downstream
+// client, so the message is either replaced with the daemon's own keys
+// while a rotation window is open, dropped when they were announced
+// already or drop is set, or passed through as is.
+func (a *hostkeysAnnouncer) up(b []byte) (ssh.PipePacketHookMethod, []byte, error) {
+	if len(b) == 0 || b[0] != msgGlobalRequest {
+		return ssh.PipePacketHookTransform, b, nil
+	}
+
+	var request globalRequest
+	if err := ssh.Unmarshal(b, &request); err != nil {
+		return ssh.PipePacketHookTransform, b, nil
+	}
+
+	if request.Type != hostkeysRequestType && request.Type != hostkeysProveRequestType {
+		return ssh.PipePacketHookTransform, b, nil
+	}
+
+	if request.Type == hostkeysRequestType {
+		if a.announced.Load() {
+			return ssh.PipePacketHookTransform, nil, nil
+		}
+
+		if a.ring != nil {
+			if msg := a.ring.hostkeysMessage(); msg != nil && !a.announced.Swap(true) {
+				return ssh.PipePacketHookTransform, msg, nil
+			}
+		}
+	}
+
+	if a.drop {
+		return ssh.PipePacketHookTransform, nil, nil
+	}
+
+	return ssh.PipePacketHookTransform, b, nil
+}
+
+// proveHostkeys answers a downstream hostkeys-prove-00@openssh.com request
+// for the keys advertised during a rotation window. The upstream cannot
+// prove the daemon's keys, so the request is never forwarded while a window
+// is open. ok is false for any other request.
+func (r *hostKeyRing) proveHostkeys(sessionID []byte, request globalRequest) (reply []byte, ok bool) {
+	if request.Type != hostkeysProveRequestType {
+		return nil, false
+	}
+
+	signers := r.advertised()
+	if len(signers) == 0 {
+		return nil, false
+	}
+
+	var sigs []byte
+	rest := request.Data
+	for len(rest) > 0 {
+		var blob []byte
+		blob, rest, ok = parseSSHString(rest)
+		if !ok {
+			slog.Debug("malformed hostkeys-prove request")
+			return ssh.Marshal(globalRequestFailure{}), true
+		}
+
+		sig, err := signHostkeysProof(signers, sessionID, blob)
+		if err != nil {
+			slog.Debug("cannot prove host key", "error", err)
+			return ssh.Marshal(globalRequestFailure{}), true
+		}
+
+		sigs = appendSSHString(sigs, ssh.Marshal(sig))
+	}
+
+	return ssh.Marshal(globalRequestSuccess{Data: sigs}), true
+}
+
+func signHostkeysProof(signers []ssh.Signer, sessionID, blob []byte) (*ssh.Signature, error) {
+	for _, s := range signers {
+		pub := plainHostKey(s.PublicKey())
+		if string(pub.Marshal()) != string(blob) {
+			continue
+		}
+
+		data := ssh.Marshal(struct {
+			Request   string
+			SessionID []byte
+			HostKey   []byte
+		}{hostkeysProveRequestType, sessionID, blob})
+
+		// openssh verifies rsa proofs made on a non-rsa kex with any
+		// rsa-sha2 algorithm, rsa-sha2-512 is what sshd itself uses
+		if as, ok := s.(ssh.AlgorithmSigner); ok && pub.Type() == ssh.KeyAlgoRSA {
+			return as.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA512)
+		}
+
+		return s.Sign(rand.Reader, data)
+	}
+
+	return nil, fmt.Errorf("requested host key was not advertised")
+}
+
+// plainHostKey strips the certificate from pub, hostkeys-00@openssh.com
+// only carries plain keys.
+func plainHostKey(pub ssh.PublicKey) ssh.PublicKey {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		return cert.Key
+	}
+
+	return pub
+}
+
+func hostKeyFingerprints(signers []ssh.Signer) []string {
+	fps := make([]string, 0, len(signers))
+	for _, s := range signers {
+		fps = append(fps, ssh.FingerprintSHA256(plainHostKey(s.PublicKey())))
+	}
+
+	return fps
+}
+
+func appendSSHString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s))) //nolint:gosec // packet sized
+	return append(b, s...)
+}
+
+func parseSSHString(b []byte) (s, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+
+	n := binary.BigEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(n) {
+		return nil, nil, false
+	}
+
+	return b[4 : 4+n], b[4+n:], true
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ssh.NewSignerFromKey(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// newTestHostKeyRing returns a ring whose loader hands out the keys queued
+// in next, one set per call.
+func newTestHostKeyRing(t *testing.T, window time.Duration, next ...[]ssh.Signer) *hostKeyRing {
+	t.Helper()
+
+	initial := next[0]
+	next = next[1:]
+
+	return newHostKeyRing(initial, func() ([]ssh.Signer, error) {
+		if len(next) == 0 {
+			return nil, fmt.Errorf("no more keys")
+		}
+		keys := next[0]
+		next = next[1:]
+		return keys, nil
+	}, window)
+}
+
+func hostkeysBlobs(t *testing.T, packet []byte) [][]byte {
+	t.Helper()
+
+	var request globalRequest
+	if err := ssh.Unmarshal(packet, &request); err != nil {
+		t.Fatal(err)
+	}
+	if request.Type != hostkeysRequestType {
+		t.Fatalf("request type = %v, want %v", request.Type, hostkeysRequestType)
+	}
+
+	var blobs [][]byte
+	rest := request.Data
+	for len(rest) > 0 {
+		var blob []byte
+		var ok bool
+		blob, rest, ok = parseSSHString(rest)
+		if !ok {
+			t.Fatalf("malformed hostkeys payload %v", request.Data)
+		}
+		blobs = append(blobs, blob)
+	}
+
+	return blobs
+}
+
+func TestHostKeyRingReload(t *testing.T) {
+	oldKey := newTestHostKey(t)
+	newKey := newTestHostKey(t)
+
+	t.Run("switches immediately without a window", func(t *testing.T) {
+		r := newTestHostKeyRing(t, 0, []ssh.Signer{oldKey}, []ssh.Signer{newKey})
+
+		st, err := r.reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(st.previous) != 0 || !st.retireAt.IsZero() {
+			t.Errorf("expected no retiring keys, got %v until %v", st.previous, st.retireAt)
+		}
+		if got := r.presented(); len(got) != 1 || got[0] != newKey {
+			t.Errorf("expected the new key to be presented, got %v", hostKeyFingerprints(got))
+		}
+		if got := r.advertised(); got != nil {
+			t.Errorf("expected nothing advertised, got %v", hostKeyFingerprints(got))
+		}
+	})
+
+	t.Run("keeps the previous keys during the window", func(t *testing.T) {
+		r := newTestHostKeyRing(t, time.Hour, []ssh.Signer{oldKey}, []ssh.Signer{newKey})
+
+		st, err := r.reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(st.previous) != 1 || st.retireAt.IsZero() {
+			t.Errorf("expected the old key to be retiring, got %v until %v", st.previous, st.retireAt)
+		}
+		if got := r.presented(); len(got) != 1 || got[0] != oldKey {
+			t.Errorf("expected the old key to be presented, got %v", hostKeyFingerprints(got))
+		}
+		if got := r.advertised(); len(got) != 2 {
+			t.Errorf("expected both keys advertised, got %v", hostKeyFingerprints(got))
+		}
+
+		// close the window
+		r.retireAt = time.Now().Add(-time.Second)
+
+		if got := r.presented(); len(got) != 1 || got[0] != newKey {
+			t.Errorf("expected the new key to be presented after the window, got %v", hostKeyFingerprints(got))
+		}
+		if got := r.advertised(); got != nil {
+			t.Errorf("expected nothing advertised after the window, got %v", hostKeyFingerprints(got))
+		}
+	})
+
+	t.Run("keeps the current keys when reload fails", func(t *testing.T) {
+		r := newTestHostKeyRing(t, time.Hour, []ssh.Signer{oldKey})
+
+		if _, err := r.reload(); err == nil {
+			t.Fatal("expected reload error, got nil")
+		}
+		if got := r.presented(); len(got) != 1 || got[0] != oldKey {
+			t.Errorf("expected the old key to be presented, got %v", hostKeyFingerprints(got))
+		}
+		if got := r.advertised(); got != nil {
+			t.Errorf("expected nothing advertised, got %v", hostKeyFingerprints(got))
+		}
+	})
+
+	t.Run("piper config serves the presented keys", func(t *testing.T) {
+		r := newTestHostKeyRing(t, 0, []ssh.Signer{oldKey}, []ssh.Signer{newKey})
+		base := &ssh.PiperConfig{}
+
+		before := r.piperConfig(base)
+		if _, err := r.reload(); err != nil {
+			t.Fatal(err)
+		}
+		after := r.piperConfig(base)
+
+		if before == after || before == base || after == base {
+			t.Errorf("expected a fresh config per call")
+		}
+	})
+}
+
+func TestHostkeysAnnouncer(t *testing.T) {
+	oldKey := newTestHostKey(t)
+	newKey := newTestHostKey(t)
+	upstreamKey := newTestHostKey(t)
+
+	upstreamHostkeys := ssh.Marshal(globalRequest{
+		Type: hostkeysRequestType,
+		Data: appendSSHString(nil, upstreamKey.PublicKey().Marshal()),
+	})
+	other := ssh.Marshal(globalRequest{Type: "keepalive@openssh.com", WantReply: true})
+
+	t.Run("no hook without ring or drop", func(t *testing.T) {
+		var r *hostKeyRing
+		if r.newHostkeysAnnouncer(false) != nil {
+			t.Fatal("expected nil announcer")
+		}
+	})
+
+	t.Run("drops when not rotating", func(t *testing.T) {
+		r := newTestHostKeyRing(t, time.Hour, []ssh.Signer{oldKey})
+		hook := r.newHostkeysAnnouncer(true).up
+
+		_, out, err := hook(upstreamHostkeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != nil {
+			t.Errorf("expected hostkeys message to be dropped, got %v", out)
+		}
+
+		_, out, _ = hook(other)
+		if !bytes.Equal(out, other) {
+			t.Errorf("expected unrelated request to pass, got %v", out)
+		}
+	})
+
+	t.Run("passes through when not rotating", func(t *testing.T) {
+		r := newTestHostKeyRing(t, time.Hour, []ssh.Signer{oldKey})
+
+		_, out, _ := r.newHostkeysAnnouncer(false).up(upstreamHostkeys)
+		if !bytes.Equal(out, upstreamHostkeys) {
+			t.Errorf("expected hostkeys message to pass through, got %v", out)
+		}
+	})
+
+	t.Run("advertises own keys while rotating", func(t *testing.T) {
+		r := newTestHostKeyRing(t, time.Hour, []ssh.Signer{oldKey}, []ssh.Signer{newKey})
+		if _, err := r.reload(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, drop := range []bool{false, true} {
+			_, out, err := r.newHostkeysAnnouncer(drop).up(upstreamHostkeys)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			blobs := hostkeysBlobs(t, out)
+			if len(blobs) != 2 {
+				t.Fatalf("expected 2 advertised keys, got %d", len(blobs))
+			}
+			if !bytes.Equal(blobs[0], oldKey.PublicKey().Marshal()) || !bytes.Equal(blobs[1], newKey.PublicKey().Marshal()) {
+				t.Errorf("expected old and new keys advertised, got %v", blobs)
+			}
+		}
+	})
+
+	t.Run("announces own keys once while rotating", func(t *testing.T) {
+		r := newTestHostKeyRing(t, time.Hour, []ssh.Signer{oldKey}, []ssh.Signer{newKey})
+		a := r.newHostkeysAnnouncer(false)
+
+		var sent [][]byte
+		write := func(b []byte) error {
+			sent = append(sent, b)
+			return nil
+		}
+
+		if err := a.announce(write); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) != 0 {
+			t.Fatalf("expected nothing announced when not rotating, got %d messages", len(sent))
+		}
+
+		if _, err := r.reload(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := a.announce(write); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(sent) != 1 {
+			t.Fatalf("expected a single announcement, got %d", len(sent))
+		}
+		if blobs := hostkeysBlobs(t, sent[0]); len(blobs) != 2 {
+			t.Errorf("expected 2 announced keys, got %d", len(blobs))
+		}
+
+		_, out, err := a.up(upstreamHostkeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != nil {
+			t.Errorf("expected the upstream hostkeys message to be dropped after the announcement, got %v", out)
+		}
+	})
+}
+
+func TestProveHostkeys(t *testing.T) {
+	oldKey := newTestHostKey(t)
+	newKey := newTestHostKey(t)
+	sessionID := []byte("session-id")
+
+	r := newTestHostKeyRing(t, time.Hour, []ssh.Signer{oldKey}, []ssh.Signer{newKey})
+
+	prove := globalRequest{
+		Type:      hostkeysProveRequestType,
+		WantReply: true,
+		Data:      appendSSHString(nil, newKey.PublicKey().Marshal()),
+	}
+
+	t.Run("not answered when not rotating", func(t *testing.T) {
+		if _, ok := r.proveHostkeys(sessionID, prove); ok {
+			t.Fatal("expected prove request to be forwarded")
+		}
+	})
+
+	if _, err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("ignores other requests", func(t *testing.T) {
+		if _, ok := r.proveHostkeys(sessionID, globalRequest{Type: "keepalive@openssh.com"}); ok {
+			t.Fatal("expected request to be forwarded")
+		}
+	})
+
+	t.Run("signs advertised keys", func(t *testing.T) {
+		reply, ok := r.proveHostkeys(sessionID, prove)
+		if !ok {
+			t.Fatal("expected prove request to be answered")
+		}
+		if reply[0] != msgRequestSuccess {
+			t.Fatalf("expected SSH_MSG_REQUEST_SUCCESS, got %v", reply[0])
+		}
+
+		sigBlob, rest, ok := parseSSHString(reply[1:])
+		if !ok || len(rest) != 0 {
+			t.Fatalf("expected exactly one signature, got %v", reply)
+		}
+
+		var sig ssh.Signature
+		if err := ssh.Unmarshal(sigBlob, &sig); err != nil {
+			t.Fatal(err)
+		}
+
+		data := ssh.Marshal(struct {
+			Request   string
+			SessionID []byte
+			HostKey   []byte
+		}{hostkeysProveRequestType, sessionID, newKey.PublicKey().Marshal()})
+		if err := newKey.PublicKey().Verify(data, &sig); err != nil {
+			t.Errorf("proof does not verify: %v", err)
+		}
+	})
+
+	t.Run("fails for unknown keys", func(t *testing.T) {
+		reply, ok := r.proveHostkeys(sessionID, globalRequest{
+			Type:      hostkeysProveRequestType,
+			WantReply: true,
+			Data:      appendSSHString(nil, newTestHostKey(t).PublicKey().Marshal()),
+		})
+		if !ok {
+			t.Fatal("expected prove request to be answered")
+		}
+		if !bytes.Equal(reply, []byte{msgRequestFailure}) {
+			t.Errorf("expected SSH_MSG_REQUEST_FAILURE, got %v", reply)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/tg123/remotesigner"
+	"github.com/tg123/remotesigner/grpcsigner"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"google.golang.org/grpc"
+)
+
+// agentHostKeySigner signs with a host key held by an ssh-agent. The agent
+// socket is dialed for every signature instead of holding one connection
+// open for the daemon's lifetime, so restarting the agent (or the HSM
+// bridge behind it) does not leave sshpiperd with a dead host key.
+type agentHostKeySigner struct {
+	socket string
+	pub    ssh.PublicKey
+}
+
+var _ ssh.AlgorithmSigner = (*agentHostKeySigner)(nil)
+
+func (s *agentHostKeySigner) PublicKey() ssh.PublicKey {
+	return s.pub
+}
+
+func (s *agentHostKeySigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.SignWithAlgorithm(rand, data, "")
+}
+
+func (s *agentHostKeySigner) SignWithAlgorithm(_ io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	var flags agent.SignatureFlags
+	switch algorithm {
+	case "", s.pub.Type():
+	case ssh.KeyAlgoRSASHA256:
+		flags = agent.SignatureFlagRsaSha256
+	case ssh.KeyAlgoRSASHA512:
+		flags = agent.SignatureFlagRsaSha512
+	default:
+		return nil, fmt.Errorf("ssh-agent host key: unsupported algorithm %q", algorithm)
+	}
+
+	conn, err := net.Dial("unix", s.socket)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to ssh-agent %v: %w", s.socket, err)
+	}
+	defer conn.Close()
+
+	return agent.NewClient(conn).SignWithFlags(s.pub, data, flags)
+}
+
+// loadAgentHostKeys returns a signer for every key offered by the ssh-agent
+// listening on socket. When fingerprints is not empty only the keys whose
+// SHA256 fingerprint is listed are used.
+func loadAgentHostKeys(socket string, fingerprints []string) ([]ssh.Signer, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to ssh-agent %v: %w", socket, err)
+	}
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list keys in ssh-agent %v: %w", socket, err)
+	}
+
+	var signers []ssh.Signer
+	for _, k := range keys {
+		pub, err := ssh.ParsePublicKey(k.Marshal())
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse key %q from ssh-agent: %w", k.Comment, err)
+		}
+
+		fp := ssh.FingerprintSHA256(pub)
+		if len(fingerprints) > 0 && !slices.Contains(fingerprints, fp) {
+			slog.Debug("skipping ssh-agent key not in fingerprint list", "fingerprint", fp, "comment", k.Comment)
+			continue
+		}
+
+		slog.Info("loaded host key from ssh-agent", "fingerprint", fp, "comment", k.Comment)
+		signers = append(signers, &agentHostKeySigner{socket: socket, pub: pub})
+	}
+
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no usable host key found in ssh-agent %v", socket)
+	}
+
+	return signers, nil
+}
+
+// loadRemoteSignerHostKeys returns one signer per entry in metas, each backed
+// by the grpcsigner service reachable through conn. metas is passed verbatim
+// as the grpcsigner metadata, which is how the signer tells keys apart.
+func loadRemoteSignerHostKeys(conn grpc.ClientConnInterface, metas []string) ([]ssh.Signer, error) {
+	if len(metas) == 0 {
+		metas = []string{""}
+	}
+
+	client := grpcsigner.NewSignerClient(conn)
+
+	signers := make([]ssh.Signer, 0, len(metas))
+	for _, meta := range metas {
+		rs := remotesigner.New(grpcsigner.New(client, meta))
+		if rs.Public() == nil {
+			return nil, fmt.Errorf("remote signer returned no public key for meta %q", meta)
+		}
+
+		signer, err := ssh.NewSignerFromSigner(rs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create host key from remote signer for meta %q: %w", meta, err)
+		}
+
+		slog.Info("loaded host key from remote signer", "meta", meta, "fingerprint", ssh.FingerprintSHA256(signer.PublicKey()))
+		signers = append(signers, signer)
+	}
+
+	return signers, nil
+}
+
+// remoteSignerConn holds the connections to the remote signer, keyed by
+// the remote signer flags they were dialed with, so host key reloads reuse
+// them instead of dialing again. A hostKeyRing closes the ones no presented
+// or advertised key signs through anymore, see keep.
+type remoteSignerConn struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+	// last is the key of the connection the last load used, empty when it
+	// did not use the remote signer.
+	last string
+}
+
+// load calls use with the connection for key, dialing it with dial when
+// there is none yet. A new connection is kept only when use succeeds.
+func (c *remoteSignerConn) load(key string, dial func() (*grpc.ClientConn, error), use func(grpc.ClientConnInterface) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, ok := c.conns[key]; ok {
+		if err := use(conn); err != nil {
+			return err
+		}
+
+		c.last = key
+		return nil
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return err
+	}
+
+	if err := use(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	if c.conns == nil {
+		c.conns = make(map[string]*grpc.ClientConn)
+	}
+
+	c.conns[key] = conn
+	c.last = key
+	return nil
+}
+
+// begin forgets the connection of the previous load, before loading again.
+func (c *remoteSignerConn) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.last = ""
+}
+
+// lastKey returns the key of the connection the last load used.
+func (c *remoteSignerConn) lastKey() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.last
+}
+
+// keep closes the connections whose key is not in keys.
+func (c *remoteSignerConn) keep(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, conn := range c.conns {
+		if !slices.Contains(keys, key) {
+			_ = conn.Close()
+			delete(c.conns, key)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tg123/remotesigner/grpcsigner"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func startTestAgent(t *testing.T, keys ...ed25519.PrivateKey) string {
+	t.Helper()
+
+	keyring := agent.NewKeyring()
+	for _, k := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: k}); err != nil {
+			t.Fatalf("failed to add key to agent: %v", err)
+		}
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on agent socket: %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	go func() {
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_ = agent.ServeAgent(keyring, c)
+			}()
+		}
+	}()
+
+	return socket
+}
+
+func verifyHostKeySignature(t *testing.T, signer ssh.Signer) {
+	t.Helper()
+
+	data := []byte("sshpiperd host key test")
+	sig, err := signer.Sign(rand.Reader, data)
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+
+	if err := signer.PublicKey().Verify(data, sig); err != nil {
+		t.Fatalf("signature verification failed: %v", err)
+	}
+}
+
+func TestLoadAgentHostKeys(t *testing.T) {
+	_, k1, _ := ed25519.GenerateKey(rand.Reader)
+	_, k2, _ := ed25519.GenerateKey(rand.Reader)
+	socket := startTestAgent(t, k1, k2)
+
+	s1, _ := ssh.NewSignerFromKey(k1)
+	fp1 := ssh.FingerprintSHA256(s1.PublicKey())
+
+	t.Run("loads every key", func(t *testing.T) {
+		signers, err := loadAgentHostKeys(socket, nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(signers) != 2 {
+			t.Fatalf("expected 2 signers, got %d", len(signers))
+		}
+		for _, s := range signers {
+			verifyHostKeySignature(t, s)
+		}
+	})
+
+	t.Run("filters by fingerprint", func(t *testing.T) {
+		signers, err := loadAgentHostKeys(socket, []string{fp1})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(signers) != 1 {
+			t.Fatalf("expected 1 signer, got %d", len(signers))
+		}
+		if got := ssh.FingerprintSHA256(signers[0].PublicKey()); got != fp1 {
+			t.Errorf("expected fingerprint %v, got %v", fp1, got)
+		}
+	})
+
+	t.Run("errors when no key matches", func(t *testing.T) {
+		if _, err := loadAgentHostKeys(socket, []string{"SHA256:nope"}); err == nil {
+			t.Fatal("expected error when no agent key matches, got nil")
+		}
+	})
+
+	t.Run("errors when socket is missing", func(t *testing.T) {
+		if _, err := loadAgentHostKeys(filepath.Join(t.TempDir(), "missing.sock"), nil); err == nil {
+			t.Fatal("expected error for missing agent socket, got nil")
+		}
+	})
+
+	t.Run("matches host certificate by fingerprint", func(t *testing.T) {
+		dir := t.TempDir()
+		certPath := generateTestCert(t, s1, dir, "agent-cert.pub")
+
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		for _, f := range []cli.Flag{
+			&cli.StringFlag{Name: "server-key-agent", Value: socket},
+			&cli.StringSliceFlag{Name: "server-key-agent-fingerprint", Value: cli.NewStringSlice(fp1)},
+			&cli.StringFlag{Name: "server-cert", Value: certPath},
+		} {
+			if err := f.Apply(set); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		signers, err := loadHostKeys(cli.NewContext(&cli.App{}, set, nil), &remoteSignerConn{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(signers) != 1 {
+			t.Fatalf("expected 1 signer, got %d", len(signers))
+		}
+		if signers[0].PublicKey().Type() != "ssh-ed25519-cert-v01@openssh.com" {
+			t.Errorf("expected cert key type, got %v", signers[0].PublicKey().Type())
+		}
+		verifyHostKeySignature(t, signers[0])
+	})
+
+	t.Run("errors when an agent key has no matching certificate", func(t *testing.T) {
+		dir := t.TempDir()
+		certPath := generateTestCert(t, s1, dir, "agent-cert.pub")
+
+		ctx := newTestCLIContext(t, map[string]string{
+			"server-key-agent": socket,
+			"server-cert":      certPath,
+		})
+
+		_, err := loadHostKeys(ctx, &remoteSignerConn{})
+		if err == nil {
+			t.Fatal("expected error since the second agent key has no certificate, got nil")
+		}
+		if !strings.Contains(err.Error(), "no host certificate") {
+			t.Errorf("expected 'no host certificate' in error, got: %v", err)
+		}
+	})
+}
+
+func TestLoadRemoteSignerHostKeys(t *testing.T) {
+	// remotesigner signs digests, so only rsa and ecdsa keys are supported
+	k1, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	k2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	gs := grpc.NewServer()
+	ss, err := grpcsigner.NewSignerServer(func(meta string) (crypto.Signer, error) {
+		if meta == "second" {
+			return k2, nil
+		}
+		return k1, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcsigner.RegisterSignerServer(gs, ss)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	t.Run("defaults to a single key", func(t *testing.T) {
+		signers, err := loadRemoteSignerHostKeys(conn, nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(signers) != 1 {
+			t.Fatalf("expected 1 signer, got %d", len(signers))
+		}
+		verifyHostKeySignature(t, signers[0])
+	})
+
+	t.Run("one key per meta", func(t *testing.T) {
+		signers, err := loadRemoteSignerHostKeys(conn, []string{"first", "second"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(signers) != 2 {
+			t.Fatalf("expected 2 signers, got %d", len(signers))
+		}
+
+		s2, _ := ssh.NewSignerFromKey(k2)
+		if ssh.FingerprintSHA256(signers[1].PublicKey()) != ssh.FingerprintSHA256(s2.PublicKey()) {
+			t.Errorf("expected second signer to use the key selected by meta")
+		}
+		for _, s := range signers {
+			verifyHostKeySignature(t, s)
+		}
+	})
+}
+
+func TestRemoteSignerConnReuse(t *testing.T) {
+	var c remoteSignerConn
+	t.Cleanup(func() { c.keep() })
+
+	var dials int
+	dial := func() (*grpc.ClientConn, error) {
+		dials++
+		return grpc.NewClient("127.0.0.1:1", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	var used grpc.ClientConnInterface
+	use := func(conn grpc.ClientConnInterface) error {
+		used = conn
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := c.load("a", dial, use); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if dials != 1 {
+		t.Fatalf("expected reloads to reuse the connection, got %d dials", dials)
+	}
+	first := c.conns["a"]
+
+	c.begin()
+	if err := c.load("b", dial, func(grpc.ClientConnInterface) error { return errors.New("no keys") }); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if len(c.conns) != 1 || c.lastKey() != "" {
+		t.Fatal("expected no connection to be kept when loading from it fails")
+	}
+
+	if err := c.load("b", dial, use); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if c.lastKey() != "b" || used != c.conns["b"] || used == first {
+		t.Fatal("expected a new connection for the changed flags")
+	}
+	if first.GetState() == connectivity.Shutdown {
+		t.Fatal("expected the old connection to stay open until it is not kept")
+	}
+
+	c.keep("b")
+	if first.GetState() != connectivity.Shutdown {
+		t.Errorf("expected the old connection to be closed, got %v", first.GetState())
+	}
+	if _, ok := c.conns["b"]; !ok || len(c.conns) != 1 {
+		t.Errorf("expected only the kept connection, got %v", c.conns)
+	}
+}
+
+func TestHostKeyRingRemoteSignerConnRetire(t *testing.T) {
+	oldKey := newTestHostKey(t)
+	newKey := newTestHostKey(t)
+
+	c := &remoteSignerConn{}
+	t.Cleanup(func() { c.keep() })
+
+	loader := func(key string, signer ssh.Signer) func() ([]ssh.Signer, error) {
+		return func() ([]ssh.Signer, error) {
+			err := c.load(key, func() (*grpc.ClientConn, error) {
+				return grpc.NewClient("127.0.0.1:1", grpc.WithTransportCredentials(insecure.NewCredentials()))
+			}, func(grpc.ClientConnInterface) error { return nil })
+			return []ssh.Signer{signer}, err
+		}
+	}
+
+	signers, err := loader("a", oldKey)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newHostKeyRing(signers, loader("a", oldKey), 200*time.Millisecond)
+	r.useRemoteSigner(c)
+	old := c.conns["a"]
+
+	if _, err := r.reloadFrom(loader("b", newKey)); err != nil {
+		t.Fatal(err)
+	}
+	if old.GetState() == connectivity.Shutdown {
+		t.Fatal("expected the connection of the retiring keys to stay open during the window")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for old.GetState() != connectivity.Shutdown {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the connection of the retired keys to be closed after the window")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := r.presented(); len(got) != 1 || got[0] != newKey {
+		t.Errorf("expected the new key to be presented, got %v", hostKeyFingerprints(got))
+	}
+	if c.conns["b"] == nil || c.conns["b"].GetState() == connectivity.Shutdown {
+		t.Error("expected the connection of the current keys to stay open")
+	}
+}
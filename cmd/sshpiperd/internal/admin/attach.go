@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PacketWriter writes raw SSH packets to either side of a pipe.
+// *ssh.PiperConn implements it.
+type PacketWriter interface {
+	WriteUpstreamPacket(packet []byte) error
+	WriteDownstreamPacket(packet []byte) error
+}
+
+var (
+	// ErrNoChannel is returned by Attach when the session has no matching
+	// shell/exec channel open.
+	ErrNoChannel = errors.New("no matching shell or exec channel")
+	// ErrChannelEnded is returned by Attachment.Write once the channel no
+	// longer takes input.
+	ErrChannelEnded = errors.New("channel ended")
+	// errNoWriter is returned by Attach and Message when no PacketWriter
+	// is set.
+	errNoWriter = errors.New("session does not accept input")
+)
+
+// extendedDataStderr is SSH_EXTENDED_DATA_STDERR.
+const extendedDataStderr = 1
+
+// SetPacketWriter lets admins Attach to the channels of the session, writing
+// through w. It must be called before the hook sees any packet.
+func (h *StreamHook) SetPacketWriter(w PacketWriter) {
+	h.writer = w
+}
+
+// Attachment is an admin attached read-write to a shell/exec channel.
+//
+// Input is written to the upstream server as channel data of the client.
+// The upstream server counts it against the window of the channel, which the
+// client never spent, so its next window adjustments to the client are
+// reduced by the size of the input. Likewise the notices written to the
+// stderr of the client reduce the next window adjustments of the client.
+// Both sides thus keep the same view of the windows. Admin input is small,
+// it is not held back when it exceeds the remaining window.
+type Attachment struct {
+	h         *StreamHook
+	channelID uint32
+	state     *channelState
+	name      string
+}
+
+// Attach attaches name to the shell/exec channel with the client-side
+// channelID, or the first one when channelID is nil, and tells the user
+// name joined.
+func (h *StreamHook) Attach(channelID *uint32, name string) (*Attachment, error) {
+	if h.writer == nil {
+		return nil, errNoWriter
+	}
+
+	h.mu.Lock()
+	a := &Attachment{h: h, name: name}
+	for id, state := range h.channels {
+		if !state.shell || state.ended || (channelID != nil && id != *channelID) {
+			continue
+		}
+		if a.state == nil || id < a.channelID {
+			a.channelID, a.state = id, state
+		}
+	}
+	h.mu.Unlock()
+
+	if a.state == nil {
+		return nil, ErrNoChannel
+	}
+
+	if err := a.notice(fmt.Sprintf("admin %s joined", name)); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ChannelID returns the client-side id of the channel, as in Frame.
+func (a *Attachment) ChannelID() uint32 {
+	return a.channelID
+}
+
+// Done is closed once the channel no longer takes input.
+func (a *Attachment) Done() <-chan struct{} {
+	return a.state.done
+}
+
+// Write writes p to the channel as if typed by the client, and publishes it
+// as an "i" frame.
+func (a *Attachment) Write(p []byte) error {
+	for len(p) > 0 {
+		a.h.mu.Lock()
+		if a.state.ended {
+			a.h.mu.Unlock()
+			return ErrChannelEnded
+		}
+		// the maximum packet size counts the data only
+		n := len(p)
+		if maxPacket := a.h.maxPacket[a.state.serverID]; maxPacket > 0 && uint32(n) > maxPacket { //nolint:gosec // len is positive
+			n = int(maxPacket)
+		}
+		a.state.inputDeficit += uint32(n) //nolint:gosec // bounded by maxPacket
+		serverID := a.state.serverID
+		a.h.mu.Unlock()
+
+		packet := make([]byte, 9+n)
+		packet[0] = msgChannelData
+		binary.BigEndian.PutUint32(packet[1:5], serverID)
+		binary.BigEndian.PutUint32(packet[5:9], uint32(n)) //nolint:gosec // bounded by maxPacket
+		copy(packet[9:], p[:n])
+		if err := a.h.writer.WriteUpstreamPacket(packet); err != nil {
+			return err
+		}
+
+		a.h.bc.Publish(Frame{
+			Kind:      "i",
+			ChannelID: a.channelID,
+			Time:      time.Now(),
+			Data:      append([]byte(nil), p[:n]...),
+		})
+		p = p[n:]
+	}
+	return nil
+}
+
+// Detach tells the user the admin left. The attachment must not be used
+// afterwards.
+func (a *Attachment) Detach() {
+	_ = a.notice(fmt.Sprintf("admin %s left", a.name))
+}
+
+// notice writes an sshpiper line to the stderr of the client.
+func (a *Attachment) notice(text string) error {
+	a.h.mu.Lock()
+	if a.state.ended {
+		a.h.mu.Unlock()
+		return ErrChannelEnded
+	}
+	packet := a.h.noticePacket(a.channelID, a.state, text)
+	a.h.mu.Unlock()
+
+	return a.h.writer.WriteDownstreamPacket(packet)
+}
+
+// Message writes text to the stderr of every open session channel, and
+// returns how many there were.
+func (h *StreamHook) Message(text string) (int, error) {
+	if h.writer == nil {
+		return 0, errNoWriter
+	}
+
+	h.mu.Lock()
+	packets := make([][]byte, 0, len(h.channels))
+	for id, state := range h.channels {
+		if state.kind != "session" {
+			continue
+		}
+		packets = append(packets, h.noticePacket(id, state, text))
+	}
+	h.mu.Unlock()
+
+	for _, packet := range packets {
+		if err := h.writer.WriteDownstreamPacket(packet); err != nil {
+			return 0, err
+		}
+	}
+	return len(packets), nil
+}
+
+// noticePacket returns the packet writing an sshpiper line to the stderr of
+// the client on a channel, whose window adjustments it is taken out of.
+// h.mu must be held.
+func (h *StreamHook) noticePacket(clientChannelID uint32, state *channelState, text string) []byte {
+	data := []byte(fmt.Sprintf("\r\n[sshpiper: %s]\r\n", text))
+	state.noticeDeficit += uint32(len(data)) //nolint:gosec // bounded by maxMessageLen
+
+	packet := make([]byte, 13+len(data))
+	packet[0] = msgChannelExtendedData
+	binary.BigEndian.PutUint32(packet[1:5], clientChannelID)
+	binary.BigEndian.PutUint32(packet[5:9], extendedDataStderr)
+	binary.BigEndian.PutUint32(packet[9:13], uint32(len(data))) //nolint:gosec // bounded by maxMessageLen
+	copy(packet[13:], data)
+	return packet
+}
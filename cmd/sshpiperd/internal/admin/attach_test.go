@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeWriter struct {
+	mu         sync.Mutex
+	upstream   [][]byte
+	downstream [][]byte
+}
+
+func (w *fakeWriter) WriteUpstreamPacket(p []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.upstream = append(w.upstream, p)
+	return nil
+}
+
+func (w *fakeWriter) WriteDownstreamPacket(p []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.downstream = append(w.downstream, p)
+	return nil
+}
+
+func packet(msgType byte, fields ...any) []byte {
+	buf := []byte{msgType}
+	for _, f := range fields {
+		switch f := f.(type) {
+		case uint32:
+			buf = binary.BigEndian.AppendUint32(buf, f)
+		case string:
+			buf = binary.BigEndian.AppendUint32(buf, uint32(len(f)))
+			buf = append(buf, f...)
+		case bool:
+			if f {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+			}
+		}
+	}
+	return buf
+}
+
+// openShell opens a shell on client channel 1 / server channel 11 accepting
+// packets of up to 4 bytes.
+func openShell(t *testing.T, h *StreamHook) {
+	t.Helper()
+	h.Down(packet(msgChannelOpen, "session", uint32(1), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenConfirm, uint32(1), uint32(11), uint32(1000), uint32(4)))
+	h.Down(packet(msgChannelRequest, uint32(11), "shell", true))
+}
+
+func TestAttachment(t *testing.T) {
+	bc := NewBroadcaster()
+	h := NewStreamHook(bc)
+
+	if _, err := h.Attach(nil, "alice"); err == nil {
+		t.Fatal("attached without a packet writer")
+	}
+
+	w := &fakeWriter{}
+	h.SetPacketWriter(w)
+
+	if _, err := h.Attach(nil, "alice"); !errors.Is(err, ErrNoChannel) {
+		t.Fatalf("Attach without channel = %v, want %v", err, ErrNoChannel)
+	}
+
+	openShell(t, h)
+
+	other := uint32(2)
+	if _, err := h.Attach(&other, "alice"); !errors.Is(err, ErrNoChannel) {
+		t.Fatalf("Attach to unknown channel = %v, want %v", err, ErrNoChannel)
+	}
+
+	a, err := h.Attach(nil, "alice")
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	if a.ChannelID() != 1 {
+		t.Fatalf("ChannelID() = %d, want 1", a.ChannelID())
+	}
+
+	notice := "\r\n[sshpiper: admin alice joined]\r\n"
+	if len(w.downstream) != 1 || !bytes.Equal(w.downstream[0], append(packet(msgChannelExtendedData, uint32(1), uint32(1)), packet(0, notice)[1:]...)) {
+		t.Fatalf("downstream = %q", w.downstream)
+	}
+
+	frames, cancel := bc.Subscribe(false)
+	defer cancel()
+
+	if err := a.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := [][]byte{packet(msgChannelData, uint32(11), "hell"), packet(msgChannelData, uint32(11), "o")}
+	if len(w.upstream) != 2 || !bytes.Equal(w.upstream[0], want[0]) || !bytes.Equal(w.upstream[1], want[1]) {
+		t.Fatalf("upstream = %q, want %q", w.upstream, want)
+	}
+	if f := <-frames; f.Kind != "i" || string(f.Data) != "hell" || f.ChannelID != 1 {
+		t.Fatalf("unexpected frame %+v", f)
+	}
+
+	// the window adjustments of the upstream server cover the input first
+	if _, out, _ := h.Up(packet(msgChannelWindowAdjust, uint32(1), uint32(3))); out != nil {
+		t.Fatalf("expected adjustment to be dropped, got %v", out)
+	}
+	if _, out, _ := h.Up(packet(msgChannelWindowAdjust, uint32(1), uint32(10))); !bytes.Equal(out, packet(msgChannelWindowAdjust, uint32(1), uint32(8))) {
+		t.Fatalf("adjustment = %v, want 8 bytes", out)
+	}
+
+	// and those of the client the notice
+	if _, out, _ := h.Down(packet(msgChannelWindowAdjust, uint32(11), uint32(100))); !bytes.Equal(out, packet(msgChannelWindowAdjust, uint32(11), uint32(100-len(notice)))) {
+		t.Fatalf("adjustment = %v, want %d bytes", out, 100-len(notice))
+	}
+	if _, out, _ := h.Down(packet(msgChannelWindowAdjust, uint32(11), uint32(100))); !bytes.Equal(out, packet(msgChannelWindowAdjust, uint32(11), uint32(100))) {
+		t.Fatalf("adjustment = %v, want 100 bytes", out)
+	}
+
+	// no input after the client sent EOF
+	h.Down(packet(msgChannelEOF, uint32(11)))
+	select {
+	case <-a.Done():
+	default:
+		t.Fatal("attachment not done after EOF")
+	}
+	if err := a.Write([]byte("x")); !errors.Is(err, ErrChannelEnded) {
+		t.Fatalf("Write after EOF = %v, want %v", err, ErrChannelEnded)
+	}
+	if _, err := h.Attach(nil, "bob"); !errors.Is(err, ErrNoChannel) {
+		t.Fatalf("Attach after EOF = %v, want %v", err, ErrNoChannel)
+	}
+}
+
+func TestAttachmentChannelClosedByServer(t *testing.T) {
+	h := NewStreamHook(NewBroadcaster())
+	h.SetPacketWriter(&fakeWriter{})
+	openShell(t, h)
+
+	a, err := h.Attach(nil, "alice")
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+
+	h.Up(packet(msgChannelClose, uint32(1)))
+	select {
+	case <-a.Done():
+	default:
+		t.Fatal("attachment not done after close")
+	}
+}
+
+func TestStreamHookMessage(t *testing.T) {
+	h := NewStreamHook(NewBroadcaster())
+	w := &fakeWriter{}
+	h.SetPacketWriter(w)
+	openShell(t, h)
+
+	// a session without shell, e.g. sftp, gets the message too
+	h.Down(packet(msgChannelOpen, "session", uint32(2), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenConfirm, uint32(2), uint32(12), uint32(1000), uint32(1000)))
+	// but not a forwarded port, nor a refused or closed session
+	h.Down(packet(msgChannelOpen, "direct-tcpip", uint32(3), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenConfirm, uint32(3), uint32(13), uint32(1000), uint32(1000)))
+	h.Down(packet(msgChannelOpen, "session", uint32(4), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenFailure, uint32(4), uint32(1), "", ""))
+	h.Down(packet(msgChannelOpen, "session", uint32(5), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenConfirm, uint32(5), uint32(15), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelClose, uint32(5)))
+
+	n, err := h.Message("bye")
+	if err != nil || n != 2 {
+		t.Fatalf("Message() = %d, %v, want 2 channels", n, err)
+	}
+
+	var channels []uint32
+	for _, p := range w.downstream {
+		if !bytes.HasSuffix(p, []byte("\r\n[sshpiper: bye]\r\n")) {
+			t.Fatalf("unexpected packet %q", p)
+		}
+		channels = append(channels, binary.BigEndian.Uint32(p[1:5]))
+	}
+	if len(channels) != 2 || channels[0]+channels[1] != 3 {
+		t.Fatalf("message written to channels %v, want 1 and 2", channels)
+	}
+}
+
+func TestStreamHookPause(t *testing.T) {
+	h := NewStreamHook(NewBroadcaster())
+	openShell(t, h)
+
+	h.Pause()
+	if !h.Paused() {
+		t.Fatal("not paused")
+	}
+
+	// only channel data is held
+	h.Down(packet(msgChannelWindowAdjust, uint32(11), uint32(10)))
+
+	forwarded := make(chan struct{})
+	go func() {
+		h.Down(packet(msgChannelData, uint32(11), "ls"))
+		close(forwarded)
+	}()
+
+	select {
+	case <-forwarded:
+		t.Fatal("channel data forwarded while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	h.Resume()
+	select {
+	case <-forwarded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel data not forwarded after resume")
+	}
+	if h.Paused() {
+		t.Fatal("still paused")
+	}
+}
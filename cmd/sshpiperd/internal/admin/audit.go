@@ -0,0 +1,189 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuditLog appends a hash-chained libadmin.AuditRecord to a JSONL file for
+// every admin rpc, see libadmin.VerifyAuditLog.
+type AuditLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	prev string
+	now  func() time.Time
+}
+
+// OpenAuditLog opens the audit log at path, created if missing, and
+// continues its hash chain. A broken chain is logged, not refused, so that
+// sshpiperd keeps auditing after the file was tampered with.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open admin audit log: %w", err)
+	}
+	n, prev, err := libadmin.VerifyAuditLog(f)
+	if err != nil {
+		slog.Error("admin audit log hash chain is broken", "file", path, "records", n, "error", err)
+	}
+	return &AuditLog{f: f, prev: prev, now: time.Now}, nil
+}
+
+// Close closes the file of the log.
+func (a *AuditLog) Close() error {
+	return a.f.Close()
+}
+
+// write chains rec to the previous record and appends it.
+func (a *AuditLog) write(rec *libadmin.AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rec.PrevHash = a.prev
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := a.f.Sync(); err != nil {
+		return err
+	}
+	a.prev = libadmin.AuditLineHash(line)
+	return nil
+}
+
+// UnaryServerInterceptor returns the interceptor auditing the unary rpcs.
+// It must run before the interceptors of a Policy to audit the denied
+// calls too.
+func (a *AuditLog) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		rec := a.newRecord(ctx, info.FullMethod)
+		resp, err := handler(context.WithValue(ctx, auditKey{}, rec), req)
+		rec.Sessions = auditedSessions(req, resp)
+		a.finish(rec, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns the interceptor auditing the streaming
+// rpcs, with a record once they received their first request, naming the
+// session, and one chained to it once they end.
+func (a *AuditLog) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rec := a.newRecord(ss.Context(), info.FullMethod)
+		start := a.now()
+		err := handler(srv, &auditStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), auditKey{}, rec), rec: rec, log: a})
+		end := a.now()
+		rec.Time = end.UTC()
+		rec.DurationMs = end.Sub(start).Milliseconds()
+		rec.Stream = "end"
+		a.finish(rec, err)
+		return err
+	}
+}
+
+// newRecord returns the record of a call of method, with its caller.
+func (a *AuditLog) newRecord(ctx context.Context, method string) *libadmin.AuditRecord {
+	rec := &libadmin.AuditRecord{
+		Time: a.now().UTC(),
+		RPC:  strings.TrimPrefix(method, "/"+libadmin.SshPiperAdmin_ServiceDesc.ServiceName+"/"),
+	}
+	cert, addr := peerCertificate(ctx)
+	if cert != nil {
+		rec.Caller = cert.Subject.String()
+	}
+	rec.RemoteAddr = addr
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		rec.OnBehalfOf = strings.Join(md.Get(libadmin.OnBehalfOfMetadataKey), ",")
+	}
+	return rec
+}
+
+// finish sets the result of rec from err and writes it. Failing to write
+// is logged, the rpc has already run.
+func (a *AuditLog) finish(rec *libadmin.AuditRecord, err error) {
+	st := status.Convert(err)
+	rec.Result = st.Code().String()
+	rec.Error = st.Message()
+	if werr := a.write(rec); werr != nil {
+		slog.Error("failed to write admin audit log", "rpc", rec.RPC, "error", werr)
+	}
+}
+
+// started writes the start record of the stream of rec.
+func (a *AuditLog) started(rec *libadmin.AuditRecord) {
+	start := *rec
+	start.Stream = "start"
+	if err := a.write(&start); err != nil {
+		slog.Error("failed to write admin audit log", "rpc", rec.RPC, "error", err)
+	}
+}
+
+// auditedSessions returns the ids of the sessions a unary rpc targeted.
+func auditedSessions(req, resp any) []string {
+	if r, ok := req.(interface{ GetId() string }); ok && r.GetId() != "" {
+		return []string{r.GetId()}
+	}
+	if r, ok := resp.(*libadmin.KillSessionsResponse); ok {
+		var ids []string
+		for _, s := range r.GetSessions() {
+			ids = append(ids, s.GetId())
+		}
+		return ids
+	}
+	return nil
+}
+
+type auditKey struct{}
+
+// auditRole notes role in the audit record of the rpc of ctx, if any.
+func auditRole(ctx context.Context, role string) {
+	if rec, ok := ctx.Value(auditKey{}).(*libadmin.AuditRecord); ok {
+		rec.Role = role
+	}
+}
+
+// auditStream is a grpc.ServerStream noting the session or recording of
+// the first request in its record, and writing its start record then.
+type auditStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	rec     *libadmin.AuditRecord
+	log     *AuditLog
+	started bool
+}
+
+func (s *auditStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *auditStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+	if r, ok := m.(interface{ GetId() string }); ok && len(s.rec.Sessions) == 0 && r.GetId() != "" {
+		s.rec.Sessions = []string{r.GetId()}
+	}
+	if r, ok := m.(*libadmin.GetRecordingRequest); ok {
+		s.rec.Recording = r.GetPath()
+	}
+	if !s.started {
+		s.started = true
+		s.log.started(s.rec)
+	}
+	return nil
+}
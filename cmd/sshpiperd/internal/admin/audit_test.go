@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(writePolicy(t, testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// call runs an rpc returning resp through the audit and policy
+	// interceptors, chained as in sshpiperd.
+	call := func(ctx context.Context, method string, req, resp any) {
+		t.Helper()
+		_, _ = audit.UnaryServerInterceptor()(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+			return p.UnaryServerInterceptor()(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, any) (any, error) {
+				return resp, nil
+			})
+		})
+	}
+
+	operator := &x509.Certificate{Subject: pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"sre"}}}
+	viewer := &x509.Certificate{Subject: pkix.Name{CommonName: "audit-1", Organization: []string{"Example"}}}
+	ctx := metadata.NewIncomingContext(peerContext(operator), metadata.Pairs(libadmin.OnBehalfOfMetadataKey, "SHA256:abc"))
+	call(ctx, libadmin.SshPiperAdmin_KillSession_FullMethodName, &libadmin.KillSessionRequest{Id: "s1"}, &libadmin.KillSessionResponse{Killed: true})
+	call(peerContext(viewer), libadmin.SshPiperAdmin_KillSession_FullMethodName, &libadmin.KillSessionRequest{Id: "s2"}, nil)
+	call(peerContext(operator), libadmin.SshPiperAdmin_KillSessions_FullMethodName, &libadmin.KillSessionsRequest{DownstreamUser: "bob"},
+		&libadmin.KillSessionsResponse{Sessions: []*libadmin.Session{{Id: "s3"}, {Id: "s4"}}})
+
+	err = audit.StreamServerInterceptor()(nil, &recvServerStream{fakeServerStream{ctx: peerContext(operator)}}, &grpc.StreamServerInfo{FullMethod: libadmin.SshPiperAdmin_StreamSession_FullMethodName},
+		func(_ any, ss grpc.ServerStream) error {
+			var req libadmin.StreamSessionRequest
+			return ss.RecvMsg(&req)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = audit.StreamServerInterceptor()(nil, &recvServerStream{fakeServerStream{ctx: peerContext(operator)}}, &grpc.StreamServerInfo{FullMethod: libadmin.SshPiperAdmin_GetRecording_FullMethodName},
+		func(_ any, ss grpc.ServerStream) error {
+			var req libadmin.GetRecordingRequest
+			return ss.RecvMsg(&req)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recs []libadmin.AuditRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec libadmin.AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad line %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 7 {
+		t.Fatalf("got %d records, want 7", len(recs))
+	}
+	for i, want := range []libadmin.AuditRecord{
+		{RPC: "KillSession", Caller: "CN=alice,OU=sre", Role: RoleOperator, OnBehalfOf: "SHA256:abc", Sessions: []string{"s1"}, Result: "OK"},
+		{RPC: "KillSession", Caller: "CN=audit-1,O=Example", Role: RoleViewer, Sessions: []string{"s2"}, Result: "PermissionDenied"},
+		{RPC: "KillSessions", Caller: "CN=alice,OU=sre", Role: RoleOperator, Sessions: []string{"s3", "s4"}, Result: "OK"},
+		{RPC: "StreamSession", Caller: "CN=alice,OU=sre", Sessions: []string{"streamed"}, Stream: "start"},
+		{RPC: "StreamSession", Caller: "CN=alice,OU=sre", Sessions: []string{"streamed"}, Result: "OK", Stream: "end"},
+	} {
+		got := recs[i]
+		if got.RPC != want.RPC || got.Caller != want.Caller || got.Role != want.Role || got.OnBehalfOf != want.OnBehalfOf ||
+			!slices.Equal(got.Sessions, want.Sessions) || got.Result != want.Result || got.Stream != want.Stream || got.RemoteAddr != "127.0.0.1:5000" || got.Time.IsZero() {
+			t.Errorf("record %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if recs[5].RPC != "GetRecording" || recs[5].Recording != "s1/shell-channel-0.cast" || recs[5].Stream != "start" || recs[6].Stream != "end" {
+		t.Errorf("GetRecording records = %+v", recs[5:])
+	}
+	if recs[1].Error == "" || recs[0].PrevHash != "" || recs[1].PrevHash == "" {
+		t.Errorf("unexpected records %+v", recs[:2])
+	}
+
+	if n, _, err := libadmin.VerifyAuditLog(bytes.NewReader(data)); n != 7 || err != nil {
+		t.Fatalf("VerifyAuditLog = %d, %v", n, err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	tampered := bytes.Join(slices.Delete(slices.Clone(lines), 1, 2), nil)
+	if _, _, err := libadmin.VerifyAuditLog(bytes.NewReader(tampered)); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("VerifyAuditLog of a log without line 2 = %v", err)
+	}
+
+	// reopening continues the chain
+	audit, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call(peerContext(operator), libadmin.SshPiperAdmin_ListSessions_FullMethodName, &libadmin.ListSessionsRequest{}, &libadmin.ListSessionsResponse{})
+	_ = audit.Close()
+	data, _ = os.ReadFile(path)
+	if n, _, err := libadmin.VerifyAuditLog(bytes.NewReader(data)); n != 8 || err != nil {
+		t.Fatalf("VerifyAuditLog after reopening = %d, %v", n, err)
+	}
+}
+
+// recvServerStream receives a StreamSessionRequest for session streamed,
+// or a GetRecordingRequest for s1/shell-channel-0.cast.
+type recvServerStream struct {
+	fakeServerStream
+}
+
+func (s *recvServerStream) RecvMsg(m any) error {
+	switch m := m.(type) {
+	case *libadmin.StreamSessionRequest:
+		m.Id = "streamed"
+	case *libadmin.GetRecordingRequest:
+		m.Path = "s1/shell-channel-0.cast"
+	}
+	return nil
+}
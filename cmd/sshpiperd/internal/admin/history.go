@@ -0,0 +1,295 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tg123/sshpiper/libadmin"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DefaultHistoryRetention is how long History keeps the sessions by
+	// default.
+	DefaultHistoryRetention = 30 * 24 * time.Hour
+
+	maxHistoryPageSize = 1000
+)
+
+// historyBucket holds the sessions by key, see historyKey.
+var historyBucket = []byte("sessions")
+
+// recordingsBucket indexes the sessions of historyBucket by the paths of
+// their screen recordings, see recordingSession.
+var recordingsBucket = []byte("recordings")
+
+// errBadPageToken is returned by History.List for a page token it did not
+// hand out.
+var errBadPageToken = errors.New("invalid page token")
+
+// HistoryRecord is a finished session.
+type HistoryRecord struct {
+	Session
+	EndedAt         time.Time
+	DownstreamBytes uint64
+	UpstreamBytes   uint64
+	// CloseReason is why the session ended, empty when the client
+	// disconnected.
+	CloseReason string
+	// Commands are the commands of the exec requests of the client.
+	Commands     []string
+	Channels     map[string]int64
+	ExitStatuses []uint32
+	// DroppedCommands and DroppedExitStatuses are the number of commands
+	// and exit statuses left out of Commands and ExitStatuses, which only
+	// keep the first ones.
+	DroppedCommands     int64
+	DroppedExitStatuses int64
+	// RecordingFiles are the paths of the screen recordings, relative to
+	// the recording dir.
+	RecordingFiles []string
+}
+
+// HistoryFilter selects the records of History.List. Zero fields match
+// every record.
+type HistoryFilter struct {
+	DownstreamUser string
+	// UpstreamAddr is host:port or host only.
+	UpstreamAddr string
+	Labels       map[string]string
+	// Since and Until select the sessions open at some point between them.
+	Since time.Time
+	Until time.Time
+}
+
+func (f HistoryFilter) match(rec *HistoryRecord) bool {
+	switch {
+	case f.DownstreamUser != "" && rec.DownstreamUser != f.DownstreamUser:
+		return false
+	case f.UpstreamAddr != "" && !matchAddr(rec.UpstreamAddr, f.UpstreamAddr):
+		return false
+	case !f.Until.IsZero() && !rec.StartedAt.Before(f.Until):
+		return false
+	}
+	return libadmin.MatchLabels(rec.Labels, f.Labels)
+}
+
+// History stores the finished sessions in a bbolt database, dropping them
+// once older than its retention.
+type History struct {
+	db        *bolt.DB
+	retention time.Duration
+	now       func() time.Time
+}
+
+// OpenHistory opens or creates the history at path, keeping the sessions
+// that ended within retention, or forever when it is 0.
+func OpenHistory(path string, retention time.Duration) (*History, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open session history %s: %w", path, err)
+	}
+	h := &History{db: db, retention: retention, now: time.Now}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(historyBucket)
+		if err != nil {
+			return err
+		}
+		// a history written before the index was added
+		indexed := tx.Bucket(recordingsBucket) != nil
+		idx, err := tx.CreateBucketIfNotExists(recordingsBucket)
+		if err != nil {
+			return err
+		}
+		if err := h.pruneLocked(b, idx); err != nil {
+			return err
+		}
+		if indexed {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var rec HistoryRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return fmt.Errorf("session history record %x: %w", k, err)
+			}
+			return indexRecordings(idx, &rec)
+		})
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("open session history %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// Close closes the database.
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+// Add records rec, and drops the sessions past the retention.
+func (h *History) Add(rec HistoryRecord) error {
+	value, err := json.Marshal(&rec)
+	if err != nil {
+		return err
+	}
+	return h.db.Update(func(tx *bolt.Tx) error {
+		b, idx := tx.Bucket(historyBucket), tx.Bucket(recordingsBucket)
+		if err := b.Put(historyKey(rec.EndedAt, rec.ID), value); err != nil {
+			return err
+		}
+		if err := indexRecordings(idx, &rec); err != nil {
+			return err
+		}
+		return h.pruneLocked(b, idx)
+	})
+}
+
+// pruneLocked deletes the sessions that ended before the retention, the
+// first keys of b, and their recordings from the index idx.
+func (h *History) pruneLocked(b, idx *bolt.Bucket) error {
+	if h.retention <= 0 {
+		return nil
+	}
+	cutoff := historyKey(h.now().Add(-h.retention), "")
+	c := b.Cursor()
+	for k, v := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, v = c.First() {
+		var rec HistoryRecord
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return fmt.Errorf("session history record %x: %w", k, err)
+		}
+		for _, f := range rec.RecordingFiles {
+			// unless a later session wrote the same file
+			if s, ok := lookupRecording(idx, f); ok && s.ID == rec.ID {
+				if err := idx.Delete([]byte(f)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordingSession is the value of recordingsBucket, the part of a session
+// ListRecordings reports.
+type recordingSession struct {
+	ID             string `json:"id"`
+	DownstreamUser string `json:"downstream_user,omitempty"`
+}
+
+// indexRecordings adds the recordings of rec to idx.
+func indexRecordings(idx *bolt.Bucket, rec *HistoryRecord) error {
+	if len(rec.RecordingFiles) == 0 {
+		return nil
+	}
+	value, err := json.Marshal(recordingSession{ID: rec.ID, DownstreamUser: rec.DownstreamUser})
+	if err != nil {
+		return err
+	}
+	for _, f := range rec.RecordingFiles {
+		if err := idx.Put([]byte(f), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lookupRecording(idx *bolt.Bucket, path string) (recordingSession, bool) {
+	var s recordingSession
+	v := idx.Get([]byte(path))
+	return s, v != nil && json.Unmarshal(v, &s) == nil
+}
+
+// List returns up to pageSize records matching filter, most recently ended
+// first, starting after the record of pageToken, and the token of the next
+// page, empty on the last one.
+func (h *History) List(filter HistoryFilter, pageSize int, pageToken string) ([]HistoryCursor, string, error) {
+	if pageSize <= 0 {
+		pageSize = libadmin.DefaultHistoryPageSize
+	}
+	pageSize = min(pageSize, maxHistoryPageSize)
+
+	var after []byte
+	if pageToken != "" {
+		var err error
+		after, err = base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil || len(after) < 8 {
+			return nil, "", errBadPageToken
+		}
+	}
+	var stop []byte
+	if !filter.Since.IsZero() {
+		stop = historyKey(filter.Since, "")
+	}
+
+	var out []HistoryCursor
+	var next string
+	err := h.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(historyBucket).Cursor()
+		var k, v []byte
+		if after == nil {
+			k, v = c.Last()
+		} else if k, v = c.Seek(after); k == nil {
+			k, v = c.Last()
+		}
+		for ; k != nil; k, v = c.Prev() {
+			if after != nil && bytes.Compare(k, after) >= 0 {
+				continue
+			}
+			if stop != nil && bytes.Compare(k, stop) < 0 {
+				return nil
+			}
+			if len(out) == pageSize {
+				next = out[len(out)-1].Cursor
+				return nil
+			}
+
+			var rec HistoryRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return fmt.Errorf("session history record %x: %w", k, err)
+			}
+			if filter.match(&rec) {
+				out = append(out, HistoryCursor{HistoryRecord: rec, Cursor: base64.RawURLEncoding.EncodeToString(k)})
+			}
+		}
+		return nil
+	})
+	return out, next, err
+}
+
+// HistoryCursor is a HistoryRecord listed by History.List, with the page
+// token listing the records after it.
+type HistoryCursor struct {
+	HistoryRecord
+	Cursor string
+}
+
+// historyKey orders the records by end time, then id.
+func historyKey(ended time.Time, id string) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(max(ended.UnixNano(), 0))) //nolint:gosec // clamped to positive
+	return append(key, id...)
+}
+
+// RecordingSessions returns the sessions of the recordings at paths, by
+// path, leaving out the recordings of no recorded session.
+func (h *History) RecordingSessions(paths []string) (map[string]Session, error) {
+	out := make(map[string]Session)
+	err := h.db.View(func(tx *bolt.Tx) error {
+		idx := tx.Bucket(recordingsBucket)
+		for _, p := range paths {
+			if s, ok := lookupRecording(idx, p); ok {
+				out[p] = Session{ID: s.ID, DownstreamUser: s.DownstreamUser}
+			}
+		}
+		return nil
+	})
+	return out, err
+}
@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestHistory(t *testing.T, retention time.Duration) *History {
+	t.Helper()
+	h, err := OpenHistory(filepath.Join(t.TempDir(), "history.db"), retention)
+	if err != nil {
+		t.Fatalf("OpenHistory: %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+func historyIDs(records []HistoryCursor) []string {
+	ids := make([]string, len(records))
+	for i, rec := range records {
+		ids[i] = rec.ID
+	}
+	return ids
+}
+
+func TestHistoryListPages(t *testing.T) {
+	h := openTestHistory(t, 0)
+	base := time.Unix(1700000000, 0)
+	for i, user := range []string{"alice", "bob", "alice", "carol", "alice"} {
+		err := h.Add(HistoryRecord{
+			Session: Session{
+				ID:             string(rune('a' + i)),
+				DownstreamUser: user,
+				UpstreamAddr:   "10.0.0.1:22",
+				StartedAt:      base.Add(time.Duration(i) * time.Minute),
+				Labels:         map[string]string{"env": "prod"},
+			},
+			EndedAt:     base.Add(time.Duration(i)*time.Minute + 30*time.Second),
+			CloseReason: "killed by admin",
+			Commands:    []string{"uptime"},
+		})
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	var got []string
+	token := ""
+	for {
+		page, next, err := h.List(HistoryFilter{}, 2, token)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		got = append(got, historyIDs(page)...)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if want := "edcba"; strings.Join(got, "") != want {
+		t.Fatalf("pages = %v, want %s", got, want)
+	}
+
+	page, _, err := h.List(HistoryFilter{DownstreamUser: "alice", UpstreamAddr: "10.0.0.1"}, 0, "")
+	if err != nil || strings.Join(historyIDs(page), "") != "eca" {
+		t.Fatalf("alice = %v, %v", historyIDs(page), err)
+	}
+	if page[0].CloseReason != "killed by admin" || len(page[0].Commands) != 1 || page[0].Labels["env"] != "prod" {
+		t.Fatalf("record = %+v", page[0].HistoryRecord)
+	}
+
+	// sessions open at some point after b ended and before d started
+	page, _, err = h.List(HistoryFilter{Since: base.Add(95 * time.Second), Until: base.Add(3 * time.Minute)}, 0, "")
+	if err != nil || strings.Join(historyIDs(page), "") != "c" {
+		t.Fatalf("time range = %v, %v", historyIDs(page), err)
+	}
+
+	page, _, err = h.List(HistoryFilter{Labels: map[string]string{"env": "dev"}}, 0, "")
+	if err != nil || len(page) != 0 {
+		t.Fatalf("env=dev = %v, %v", historyIDs(page), err)
+	}
+
+	if _, _, err := h.List(HistoryFilter{}, 0, "!"); err != errBadPageToken {
+		t.Fatalf("bad token err = %v", err)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	h := openTestHistory(t, time.Hour)
+	now := time.Unix(1700000000, 0)
+	h.now = func() time.Time { return now }
+
+	for id, ended := range map[string]time.Time{"old": now.Add(-2 * time.Hour), "new": now.Add(-time.Minute)} {
+		if err := h.Add(HistoryRecord{Session: Session{ID: id}, EndedAt: ended}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	page, _, err := h.List(HistoryFilter{}, 0, "")
+	if err != nil || len(page) != 1 || page[0].ID != "new" {
+		t.Fatalf("List = %v, %v, want only the new session", historyIDs(page), err)
+	}
+}
+
+func TestHistoryRecordingSessions(t *testing.T) {
+	h := openTestHistory(t, time.Hour)
+	now := time.Unix(1700000000, 0)
+	h.now = func() time.Time { return now }
+
+	for _, rec := range []HistoryRecord{
+		{Session: Session{ID: "old", DownstreamUser: "alice"}, EndedAt: now.Add(-2 * time.Hour), RecordingFiles: []string{"alice/1.typescript", "alice/2.typescript"}},
+		{Session: Session{ID: "new", DownstreamUser: "alice"}, EndedAt: now.Add(-time.Minute), RecordingFiles: []string{"alice/2.typescript"}},
+		{Session: Session{ID: "bob", DownstreamUser: "bob"}, EndedAt: now, RecordingFiles: []string{"bob/3.typescript"}},
+	} {
+		if err := h.Add(rec); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	got, err := h.RecordingSessions([]string{"alice/1.typescript", "alice/2.typescript", "bob/3.typescript", "nope.cast"})
+	if err != nil {
+		t.Fatalf("RecordingSessions: %v", err)
+	}
+	// alice/1 went with the pruned session, alice/2 was written again
+	if len(got) != 2 || got["alice/2.typescript"].ID != "new" || got["bob/3.typescript"].DownstreamUser != "bob" {
+		t.Fatalf("RecordingSessions = %v", got)
+	}
+}
+
+func TestHistoryRecordingSessionsIndexesOldHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _ := json.Marshal(HistoryRecord{Session: Session{ID: "s1", DownstreamUser: "alice"}, RecordingFiles: []string{"s1/shell-channel-0.cast"}})
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket(historyBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(historyKey(time.Unix(1700000000, 0), "s1"), value)
+	})
+	if err := errors.Join(err, db.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := OpenHistory(path, 0)
+	if err != nil {
+		t.Fatalf("OpenHistory: %v", err)
+	}
+	defer h.Close()
+	got, err := h.RecordingSessions([]string{"s1/shell-channel-0.cast"})
+	if err != nil || got["s1/shell-channel-0.cast"].DownstreamUser != "alice" {
+		t.Fatalf("RecordingSessions = %v, %v", got, err)
+	}
+}
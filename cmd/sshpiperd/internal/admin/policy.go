@@ -0,0 +1,255 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// Roles of a Policy, each allowed the rpcs of the previous one.
+const (
+	// RoleViewer may list and inspect the sessions.
+	RoleViewer = "viewer"
+	// RoleStreamer may also watch the terminal of the sessions.
+	RoleStreamer = "streamer"
+	// RoleOperator may call every rpc.
+	RoleOperator = "operator"
+)
+
+// roleRPCs are the rpcs of each role but operator, allowed every rpc.
+var roleRPCs = map[string][]string{
+	RoleViewer: {
+		"ServerInfo",
+		"ListSessions",
+		"GetSession",
+		"ListSessionHistory",
+		"ListRecordings",
+		"WatchSessions",
+	},
+	RoleStreamer: {
+		"StreamSession",
+		"GetRecording",
+	},
+}
+
+// roleRank orders the roles by what they may do.
+var roleRank = map[string]int{RoleViewer: 1, RoleStreamer: 2, RoleOperator: 3}
+
+// Policy maps the client certificates of the admin gRPC API to roles, and
+// enforces them with its interceptors.
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+	// DefaultRole is the role of the certificates matching no rule, which
+	// are denied when it is empty.
+	DefaultRole string `yaml:"default_role"`
+}
+
+// PolicyRule gives Role to the certificates matching all of its non-empty
+// patterns, in path.Match syntax.
+type PolicyRule struct {
+	Role string `yaml:"role"`
+	// Subject matches the subject distinguished name, e.g.
+	// CN=alice,OU=sre,O=Example.
+	Subject            string `yaml:"subject"`
+	CommonName         string `yaml:"common_name"`
+	OrganizationalUnit string `yaml:"organizational_unit"`
+	// SAN matches any DNS, email, URI or IP subject alternative name.
+	SAN string `yaml:"san"`
+}
+
+// LoadPolicy reads the policy file at path.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read admin policy: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse admin policy %s: %w", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("admin policy %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	if p.DefaultRole != "" && roleRank[p.DefaultRole] == 0 {
+		return fmt.Errorf("unknown default_role %q, want viewer, streamer or operator", p.DefaultRole)
+	}
+	for i, r := range p.Rules {
+		if roleRank[r.Role] == 0 {
+			return fmt.Errorf("rule %d: unknown role %q, want viewer, streamer or operator", i+1, r.Role)
+		}
+		if r.Subject == "" && r.CommonName == "" && r.OrganizationalUnit == "" && r.SAN == "" {
+			return fmt.Errorf("rule %d: no subject, common_name, organizational_unit or san", i+1)
+		}
+		for _, pattern := range []string{r.Subject, r.CommonName, r.OrganizationalUnit, r.SAN} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %d: bad pattern %q: %w", i+1, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Role returns the role of cert, the one of the first matching rule, empty
+// when it is denied.
+func (p *Policy) Role(cert *x509.Certificate) string {
+	for _, r := range p.Rules {
+		if r.match(cert) {
+			return r.Role
+		}
+	}
+	return p.DefaultRole
+}
+
+func (r *PolicyRule) match(cert *x509.Certificate) bool {
+	if r.Subject != "" && !globMatch(r.Subject, cert.Subject.String()) {
+		return false
+	}
+	if r.CommonName != "" && !globMatch(r.CommonName, cert.Subject.CommonName) {
+		return false
+	}
+	if r.OrganizationalUnit != "" && !anyGlobMatch(r.OrganizationalUnit, cert.Subject.OrganizationalUnit) {
+		return false
+	}
+	if r.SAN != "" {
+		sans := append(append([]string(nil), cert.DNSNames...), cert.EmailAddresses...)
+		for _, u := range cert.URIs {
+			sans = append(sans, u.String())
+		}
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		if !anyGlobMatch(r.SAN, sans) {
+			return false
+		}
+	}
+	return true
+}
+
+func globMatch(pattern, s string) bool {
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+func anyGlobMatch(pattern string, values []string) bool {
+	for _, v := range values {
+		if globMatch(pattern, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleAllows reports whether role may call rpc, by its name, e.g.
+// KillSession.
+func RoleAllows(role, rpc string) bool {
+	rank := roleRank[role]
+	if rank == roleRank[RoleOperator] {
+		return true
+	}
+	for r, rpcs := range roleRPCs {
+		if roleRank[r] <= rank && slices.Contains(rpcs, rpc) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor returns the interceptor enforcing p on the unary
+// rpcs.
+func (p *Policy) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := p.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the interceptor enforcing p on the
+// streaming rpcs.
+func (p *Policy) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := p.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &roleStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorize returns ctx carrying the role of the caller of method, or
+// codes.PermissionDenied when the role does not allow it.
+func (p *Policy) authorize(ctx context.Context, method string) (context.Context, error) {
+	rpc := strings.TrimPrefix(method, "/"+libadmin.SshPiperAdmin_ServiceDesc.ServiceName+"/")
+	cert, addr := peerCertificate(ctx)
+	role := ""
+	if cert != nil {
+		role = p.Role(cert)
+	}
+	auditRole(ctx, role)
+	if role == "" || !RoleAllows(role, rpc) {
+		subject := ""
+		if cert != nil {
+			subject = cert.Subject.String()
+		}
+		slog.Warn("admin rpc denied", "rpc", rpc, "subject", subject, "role", role, "remote_addr", addr)
+		if role == "" {
+			return nil, status.Errorf(codes.PermissionDenied, "no admin role for this client certificate")
+		}
+		return nil, status.Errorf(codes.PermissionDenied, "role %s may not call %s", role, rpc)
+	}
+	return context.WithValue(ctx, roleKey{}, role), nil
+}
+
+// peerCertificate returns the verified client certificate of the caller,
+// nil without one, and its address.
+func peerCertificate(ctx context.Context) (*x509.Certificate, string) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ""
+	}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+		return tlsInfo.State.VerifiedChains[0][0], p.Addr.String()
+	}
+	return nil, p.Addr.String()
+}
+
+type roleKey struct{}
+
+// callerRole returns the role authorized by a Policy interceptor, empty
+// without a policy.
+func callerRole(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey{}).(string)
+	return role
+}
+
+// roleStream is a grpc.ServerStream carrying the role in its context.
+type roleStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *roleStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,180 @@
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testPolicy = `
+rules:
+  - role: operator
+    organizational_unit: sre
+  - role: streamer
+    san: "*.support.example.com"
+  - role: viewer
+    subject: "CN=audit-*,O=Example"
+default_role: ""
+`
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// peerContext returns a context of a call made with cert, or without a
+// client certificate when cert is nil.
+func peerContext(cert *x509.Certificate) context.Context {
+	var state tls.ConnectionState
+	if cert != nil {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000},
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+}
+
+func TestLoadPolicy(t *testing.T) {
+	p, err := LoadPolicy(writePolicy(t, testPolicy))
+	if err != nil {
+		t.Fatalf("LoadPolicy: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		cert *x509.Certificate
+		want string
+	}{
+		{"ou", &x509.Certificate{Subject: pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"dev", "sre"}}}, RoleOperator},
+		{"san", &x509.Certificate{Subject: pkix.Name{CommonName: "bob"}, DNSNames: []string{"bob.support.example.com"}}, RoleStreamer},
+		{"subject", &x509.Certificate{Subject: pkix.Name{CommonName: "audit-1", Organization: []string{"Example"}}}, RoleViewer},
+		{"none", &x509.Certificate{Subject: pkix.Name{CommonName: "mallory"}}, ""},
+	} {
+		if got := p.Role(tc.cert); got != tc.want {
+			t.Errorf("%s: Role() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	for content, want := range map[string]string{
+		"rules:\n  - role: admin\n    common_name: x\n": "unknown role",
+		"rules:\n  - role: viewer\n":                    "no subject",
+		"rules:\n  - role: viewer\n    cn: x\n":         "not found",
+		"default_role: root\n":                          "unknown default_role",
+		"rules:\n  - role: viewer\n    san: \"[\"\n":    "bad pattern",
+	} {
+		if _, err := LoadPolicy(writePolicy(t, content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadPolicy(%q) = %v, want %q", content, err, want)
+		}
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	for _, tc := range []struct {
+		role, rpc string
+		want      bool
+	}{
+		{RoleViewer, "ListSessions", true},
+		{RoleViewer, "StreamSession", false},
+		{RoleViewer, "KillSession", false},
+		{RoleStreamer, "WatchSessions", true},
+		{RoleStreamer, "StreamSession", true},
+		{RoleStreamer, "AttachSession", false},
+		{RoleOperator, "KillSessions", true},
+		{"", "ServerInfo", false},
+	} {
+		if got := RoleAllows(tc.role, tc.rpc); got != tc.want {
+			t.Errorf("RoleAllows(%q, %q) = %v, want %v", tc.role, tc.rpc, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyInterceptors(t *testing.T) {
+	p, err := LoadPolicy(writePolicy(t, testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer := &x509.Certificate{Subject: pkix.Name{CommonName: "audit-1", Organization: []string{"Example"}}}
+	unary := p.UnaryServerInterceptor()
+	srv := NewServer(NewRegistry(), "id", "v", "addr")
+
+	resp, err := unary(peerContext(viewer), &libadmin.ServerInfoRequest{}, &grpc.UnaryServerInfo{FullMethod: libadmin.SshPiperAdmin_ServerInfo_FullMethodName},
+		func(ctx context.Context, req any) (any, error) {
+			return srv.ServerInfo(ctx, req.(*libadmin.ServerInfoRequest))
+		})
+	if err != nil {
+		t.Fatalf("ServerInfo as viewer: %v", err)
+	}
+	info := resp.(*libadmin.ServerInfoResponse)
+	if info.GetRole() != RoleViewer || !slices.Contains(info.GetAllowedRpcs(), "ListSessions") || slices.Contains(info.GetAllowedRpcs(), "KillSession") ||
+		slices.Contains(info.GetAllowedRpcs(), "ListSessionHistory") {
+		t.Fatalf("ServerInfo = role %q, rpcs %v", info.GetRole(), info.GetAllowedRpcs())
+	}
+
+	called := false
+	handler := func(context.Context, any) (any, error) {
+		called = true
+		return nil, nil
+	}
+	for _, tc := range []struct {
+		cert   *x509.Certificate
+		method string
+	}{
+		{viewer, libadmin.SshPiperAdmin_KillSession_FullMethodName},
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "mallory"}}, libadmin.SshPiperAdmin_ListSessions_FullMethodName},
+		{nil, libadmin.SshPiperAdmin_ListSessions_FullMethodName},
+	} {
+		_, err := unary(peerContext(tc.cert), nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("%v calling %s: err = %v, want PermissionDenied", tc.cert, tc.method, err)
+		}
+	}
+	if called {
+		t.Fatal("handler called for a denied rpc")
+	}
+
+	stream := p.StreamServerInterceptor()
+	streamer := &x509.Certificate{Subject: pkix.Name{CommonName: "bob"}, DNSNames: []string{"bob.support.example.com"}}
+	err = stream(nil, &fakeServerStream{ctx: peerContext(streamer)}, &grpc.StreamServerInfo{FullMethod: libadmin.SshPiperAdmin_StreamSession_FullMethodName},
+		func(_ any, ss grpc.ServerStream) error {
+			if role := callerRole(ss.Context()); role != RoleStreamer {
+				t.Errorf("stream role = %q", role)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("StreamSession as streamer: %v", err)
+	}
+	err = stream(nil, &fakeServerStream{ctx: peerContext(streamer)}, &grpc.StreamServerInfo{FullMethod: libadmin.SshPiperAdmin_AttachSession_FullMethodName},
+		func(any, grpc.ServerStream) error { return nil })
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("AttachSession as streamer: err = %v, want PermissionDenied", err)
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
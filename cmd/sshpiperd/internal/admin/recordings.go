@@ -0,0 +1,244 @@
+package admin
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tg123/sshpiper/libadmin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// recordingChunkSize is the size of the chunks of GetRecording.
+	recordingChunkSize = 32 * 1024
+
+	maxRecordingsPageSize = 1000
+)
+
+// SetRecordings enables the ListRecordings and GetRecording RPCs on the
+// screen recording dir fsys, refused with codes.FailedPrecondition
+// otherwise. byUser tells its directories are named after the downstream
+// users, see --username-as-recorddir, rather than the session ids.
+func (s *Server) SetRecordings(fsys fs.FS, byUser bool) {
+	s.recordings = fsys
+	s.recordingsByUser = byUser
+}
+
+// ListRecordings implements libadmin.SshPiperAdminServer.
+//
+// The session of a recording is the one of its directory, or found in the
+// session history.
+func (s *Server) ListRecordings(_ context.Context, req *libadmin.ListRecordingsRequest) (*libadmin.ListRecordingsResponse, error) {
+	if s.recordings == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "screen recording is disabled, see --screen-recording-dir")
+	}
+	if req.GetPageSize() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must not be negative")
+	}
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = libadmin.DefaultRecordingsPageSize
+	}
+	pageSize = min(pageSize, maxRecordingsPageSize)
+	var after *libadmin.Recording
+	if req.GetPageToken() != "" {
+		var err error
+		if after, err = parseRecordingCursor(req.GetPageToken()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
+
+	var recordings []*libadmin.Recording
+	var paths []string
+	err := fs.WalkDir(s.recordings, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// e.g. a symlink escaping the dir, skipped
+			if name == "." {
+				return err
+			}
+			return nil
+		}
+		var format string
+		switch path.Ext(name) {
+		case ".cast":
+			format = "asciicast"
+		case ".typescript":
+			format = "typescript"
+		default:
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		rec := &libadmin.Recording{
+			Path:       name,
+			Format:     format,
+			ModifiedAt: info.ModTime().Unix(),
+			Size:       uint64(max(info.Size(), 0)), //nolint:gosec // clamped to positive
+		}
+		if req.GetSince() > 0 && rec.GetModifiedAt() < req.GetSince() {
+			return nil
+		}
+		if format == "typescript" {
+			rec.TimingPath = strings.TrimSuffix(name, ".typescript") + ".timing"
+		}
+		rec.StartedAt = s.recordingStart(name, format, info.ModTime())
+		if req.GetUntil() > 0 && rec.GetStartedAt() >= req.GetUntil() {
+			return nil
+		}
+		recordings = append(recordings, rec)
+		paths = append(paths, name)
+		return nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list recordings: %v", err)
+	}
+
+	var finished map[string]Session
+	if s.history != nil {
+		if finished, err = s.history.RecordingSessions(paths); err != nil {
+			return nil, status.Errorf(codes.Internal, "read session history: %v", err)
+		}
+	}
+	recordings = slices.DeleteFunc(recordings, func(rec *libadmin.Recording) bool {
+		dir, _, _ := strings.Cut(rec.GetPath(), "/")
+		if sess, ok := finished[rec.GetPath()]; ok {
+			rec.SessionId = sess.ID
+			rec.DownstreamUser = sess.DownstreamUser
+		} else if s.recordingsByUser {
+			rec.DownstreamUser = dir
+		} else {
+			rec.SessionId = dir
+			if sess, _, ok := s.registry.Get(dir); ok {
+				rec.DownstreamUser = sess.DownstreamUser
+			}
+		}
+		return req.GetSessionId() != "" && rec.GetSessionId() != req.GetSessionId() ||
+			req.GetDownstreamUser() != "" && rec.GetDownstreamUser() != req.GetDownstreamUser()
+	})
+
+	slices.SortFunc(recordings, compareRecordings)
+	if after != nil {
+		i, _ := slices.BinarySearchFunc(recordings, after, compareRecordings)
+		if i < len(recordings) && compareRecordings(recordings[i], after) == 0 {
+			i++
+		}
+		recordings = recordings[i:]
+	}
+	resp := &libadmin.ListRecordingsResponse{}
+	if len(recordings) > pageSize {
+		recordings = recordings[:pageSize]
+		resp.NextPageToken = recordingCursor(recordings[pageSize-1])
+	}
+	for _, rec := range recordings {
+		rec.Cursor = recordingCursor(rec)
+	}
+	resp.Recordings = recordings
+	return resp, nil
+}
+
+// compareRecordings orders the recordings most recently modified first,
+// then by path.
+func compareRecordings(a, b *libadmin.Recording) int {
+	return cmp.Or(cmp.Compare(b.GetModifiedAt(), a.GetModifiedAt()), strings.Compare(a.GetPath(), b.GetPath()))
+}
+
+// recordingCursor returns the page token listing the recordings after rec:
+// its modification time and path.
+func recordingCursor(rec *libadmin.Recording) string {
+	token := binary.BigEndian.AppendUint64(nil, uint64(rec.GetModifiedAt())) //nolint:gosec // reversed by parseRecordingCursor
+	return base64.RawURLEncoding.EncodeToString(append(token, rec.GetPath()...))
+}
+
+// parseRecordingCursor reverses recordingCursor.
+func parseRecordingCursor(token string) (*libadmin.Recording, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) < 8 {
+		return nil, errBadPageToken
+	}
+	return &libadmin.Recording{
+		ModifiedAt: int64(binary.BigEndian.Uint64(b)), //nolint:gosec // written by recordingCursor
+		Path:       string(b[8:]),
+	}, nil
+}
+
+// recordingStart returns the unix time the recording name started: the
+// timestamp of the header of an asciicast, the name of a typescript. It
+// falls back to modified.
+func (s *Server) recordingStart(name, format string, modified time.Time) int64 {
+	switch format {
+	case "asciicast":
+		f, err := s.recordings.Open(name)
+		if err != nil {
+			break
+		}
+		defer f.Close()
+		line, err := bufio.NewReader(f).ReadSlice('\n')
+		if err != nil {
+			break
+		}
+		var header struct {
+			Timestamp int64 `json:"timestamp"`
+		}
+		if json.Unmarshal(line, &header) == nil && header.Timestamp > 0 {
+			return header.Timestamp
+		}
+	case "typescript":
+		if ts, err := strconv.ParseInt(strings.TrimSuffix(path.Base(name), ".typescript"), 10, 64); err == nil {
+			return ts
+		}
+	}
+	return modified.Unix()
+}
+
+// GetRecording implements libadmin.SshPiperAdminServer.
+func (s *Server) GetRecording(req *libadmin.GetRecordingRequest, stream libadmin.SshPiperAdmin_GetRecordingServer) error {
+	if s.recordings == nil {
+		return status.Errorf(codes.FailedPrecondition, "screen recording is disabled, see --screen-recording-dir")
+	}
+	name := req.GetPath()
+	// only the files ListRecordings lists, not whatever else is in the dir
+	if !fs.ValidPath(name) || !slices.Contains([]string{".cast", ".typescript", ".timing"}, path.Ext(name)) {
+		return status.Errorf(codes.InvalidArgument, "%q is not a recording path", name)
+	}
+
+	f, err := s.recordings.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return status.Errorf(codes.NotFound, "recording %q not found", name)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "open recording %q: %v", name, err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, recordingChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if serr := stream.Send(&libadmin.RecordingChunk{Data: buf[:n]}); serr != nil {
+				return serr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "read recording %q: %v", name, err)
+		}
+	}
+}
@@ -14,6 +14,7 @@
 package admin
 
 import (
+	"encoding/binary"
 	"sort"
 	"sync"
 	"time"
@@ -27,6 +28,29 @@ type Session struct {
 	UpstreamUser   string
 	UpstreamAddr   string
 	StartedAt      time.Time
+	// Labels the plugins attached to the session, see
+	// libplugin.Upstream.Labels.
+	Labels map[string]string
+	// ClientVersion and ServerVersion are the SSH version strings of the
+	// downstream client and of the upstream server.
+	ClientVersion string
+	ServerVersion string
+	// DownstreamAlgorithms and UpstreamAlgorithms are the algorithms
+	// negotiated with the client and with the upstream server, zero when
+	// unknown.
+	DownstreamAlgorithms Algorithms
+	UpstreamAlgorithms   Algorithms
+}
+
+// Algorithms are the algorithms negotiated on one leg of a session. In and
+// Out are seen from sshpiperd: In is what it receives, Out what it sends.
+type Algorithms struct {
+	KeyExchange string
+	HostKey     string
+	CipherIn    string
+	CipherOut   string
+	MACIn       string
+	MACOut      string
 }
 
 // SessionPipe is the minimal subset of *ssh.PiperConn the registry needs.
@@ -42,18 +66,47 @@ type sessionEntry struct {
 	info        Session
 	pipe        SessionPipe
 	broadcaster *Broadcaster
+	hook        *StreamHook
 	closeOnce   sync.Once
+	// killed is set by the first Kill, with its reason.
+	killed     bool
+	killReason string
 }
 
 // Registry is a concurrency-safe collection of live sessions.
 type Registry struct {
 	mu       sync.RWMutex
 	sessions map[string]*sessionEntry
+	// changed is closed and replaced on every change, see Changed.
+	changed chan struct{}
 }
 
 // NewRegistry returns an empty Registry.
 func NewRegistry() *Registry {
-	return &Registry{sessions: make(map[string]*sessionEntry)}
+	return &Registry{
+		sessions: make(map[string]*sessionEntry),
+		changed:  make(chan struct{}),
+	}
+}
+
+// Changed returns a channel closed on the next Add, Remove or Notify.
+func (r *Registry) Changed() <-chan struct{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.changed
+}
+
+// Notify wakes up the waiters on Changed, for changes of a session made
+// outside the registry, e.g. pausing it.
+func (r *Registry) Notify() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifyLocked()
+}
+
+func (r *Registry) notifyLocked() {
+	close(r.changed)
+	r.changed = make(chan struct{})
 }
 
 // Add registers a new session and returns its broadcaster. The broadcaster
@@ -67,6 +120,7 @@ func (r *Registry) Add(info Session, pipe SessionPipe) *Broadcaster {
 		pipe:        pipe,
 		broadcaster: b,
 	}
+	r.notifyLocked()
 	return b
 }
 
@@ -77,10 +131,14 @@ func (r *Registry) Remove(id string) {
 	entry, ok := r.sessions[id]
 	if ok {
 		delete(r.sessions, id)
+		r.notifyLocked()
 	}
 	r.mu.Unlock()
 	if ok {
 		entry.broadcaster.Close()
+		if entry.hook != nil {
+			entry.hook.Resume()
+		}
 	}
 }
 
@@ -115,19 +173,105 @@ func (r *Registry) Get(id string) (Session, *Broadcaster, bool) {
 	return entry.info, entry.broadcaster, true
 }
 
+// SetStreamHook makes the StreamHook of the session available to
+// AttachSession. It is a no-op for an unknown id.
+func (r *Registry) SetStreamHook(id string, h *StreamHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.sessions[id]; ok {
+		entry.hook = h
+	}
+}
+
+// StreamHook returns the StreamHook set by SetStreamHook, and ok=true if
+// the id is registered with one.
+func (r *Registry) StreamHook(id string) (*StreamHook, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.sessions[id]
+	if !ok || entry.hook == nil {
+		return nil, false
+	}
+	return entry.hook, true
+}
+
+// disconnectWriteTimeout bounds how long Kill waits for the disconnect
+// message to be written, a client not reading must not keep its pipe open.
+var disconnectWriteTimeout = 2 * time.Second
+
 // Kill closes the pipe associated with id, which causes the daemon's
 // connection goroutine to unwind and Remove the session. The pipe close is
 // guarded by sync.Once so concurrent kills (or kill+natural-disconnect)
-// are safe. Returns true if the id was found.
-func (r *Registry) Kill(id string) bool {
-	r.mu.RLock()
+// are safe. A non-empty reason is first sent to the client in an SSH
+// disconnect message when the pipe is a PacketWriter, the pipe is closed
+// regardless of the write after disconnectWriteTimeout. Returns true if the
+// id was found.
+func (r *Registry) Kill(id, reason string) bool {
+	r.mu.Lock()
 	entry, ok := r.sessions[id]
-	r.mu.RUnlock()
+	var hook *StreamHook
+	if ok {
+		hook = entry.hook
+		if !entry.killed {
+			entry.killed, entry.killReason = true, reason
+		}
+	}
+	r.mu.Unlock()
 	if !ok {
 		return false
 	}
 	entry.closeOnce.Do(func() {
+		if w, ok := entry.pipe.(PacketWriter); ok && reason != "" {
+			writeDisconnect(w, reason)
+		}
 		entry.pipe.Close()
 	})
+	// let a paused pipe see it is closed
+	if hook != nil {
+		hook.Resume()
+	}
 	return true
 }
+
+// Killed reports whether session id was killed, and the reason of the
+// first Kill.
+func (r *Registry) Killed(id string) (reason string, killed bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if entry, ok := r.sessions[id]; ok {
+		return entry.killReason, entry.killed
+	}
+	return "", false
+}
+
+// writeDisconnect sends the disconnect message of reason with w, giving up
+// after disconnectWriteTimeout. The write goes on in the background until
+// the pipe is closed.
+func writeDisconnect(w PacketWriter, reason string) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = w.WriteDownstreamPacket(disconnectPacket(reason))
+	}()
+
+	t := time.NewTimer(disconnectWriteTimeout)
+	defer t.Stop()
+	select {
+	case <-done:
+	case <-t.C:
+	}
+}
+
+// disconnectByApplication is SSH_DISCONNECT_BY_APPLICATION.
+const disconnectByApplication = 11
+
+// disconnectPacket returns an SSH disconnect message with reason as its
+// description.
+func disconnectPacket(reason string) []byte {
+	packet := []byte{msgDisconnect}
+	packet = binary.BigEndian.AppendUint32(packet, disconnectByApplication)
+	packet = binary.BigEndian.AppendUint32(packet, uint32(len(reason))) //nolint:gosec // bounded by maxMessageLen
+	packet = append(packet, reason...)
+	// empty language tag
+	return binary.BigEndian.AppendUint32(packet, 0)
+}
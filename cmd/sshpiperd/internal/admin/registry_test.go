@@ -40,10 +40,10 @@ func TestRegistry_KillCallsCloseOnce(t *testing.T) {
 	pipe := &fakePipe{}
 	r.Add(Session{ID: "k1"}, pipe)
 
-	if !r.Kill("k1") {
+	if !r.Kill("k1", "") {
 		t.Fatal("Kill should return true for known id")
 	}
-	if r.Kill("k1") != true {
+	if r.Kill("k1", "") != true {
 		// Still registered (daemon goroutine hasn't called Remove yet),
 		// so a second Kill is allowed but the underlying Close must not
 		// be invoked twice.
@@ -52,11 +52,67 @@ func TestRegistry_KillCallsCloseOnce(t *testing.T) {
 	if got := pipe.closed.Load(); got != 1 {
 		t.Fatalf("Close called %d times, want 1", got)
 	}
-	if r.Kill("missing") {
+	if r.Kill("missing", "") {
 		t.Fatal("Kill should return false for unknown id")
 	}
 }
 
+func TestRegistry_Killed(t *testing.T) {
+	r := NewRegistry()
+	r.Add(Session{ID: "k1"}, &fakePipe{})
+	r.Add(Session{ID: "k2"}, &fakePipe{})
+
+	r.Kill("k1", "maintenance")
+	r.Kill("k1", "again")
+	if reason, killed := r.Killed("k1"); !killed || reason != "maintenance" {
+		t.Fatalf("Killed(k1) = %q, %v, want the first reason", reason, killed)
+	}
+	if _, killed := r.Killed("k2"); killed {
+		t.Fatal("k2 was not killed")
+	}
+}
+
+// blockedPipe is a pipe whose downstream writes block until it is closed,
+// like one to a client that stopped reading.
+type blockedPipe struct {
+	fakePipe
+	closing chan struct{}
+}
+
+func (b *blockedPipe) Close() {
+	b.fakePipe.Close()
+	close(b.closing)
+}
+
+func (b *blockedPipe) WriteDownstreamPacket([]byte) error {
+	<-b.closing
+	return nil
+}
+
+func TestRegistry_KillClosesBlockedPipe(t *testing.T) {
+	old := disconnectWriteTimeout
+	disconnectWriteTimeout = 50 * time.Millisecond
+	defer func() { disconnectWriteTimeout = old }()
+
+	r := NewRegistry()
+	pipe := &blockedPipe{closing: make(chan struct{})}
+	r.Add(Session{ID: "k1"}, pipe)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Kill("k1", "maintenance")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Kill blocked on the disconnect message")
+	}
+	if pipe.closed.Load() != 1 {
+		t.Fatal("expected the pipe to be closed")
+	}
+}
+
 func TestRegistry_RemoveClosesBroadcaster(t *testing.T) {
 	r := NewRegistry()
 	bc := r.Add(Session{ID: "x"}, &fakePipe{})
@@ -88,3 +144,31 @@ func TestRegistry_ListIsSortedByStartedAt(t *testing.T) {
 		t.Fatalf("List = %+v, want sorted oldest-first a,b,c", out)
 	}
 }
+
+func TestRegistry_Changed(t *testing.T) {
+	r := NewRegistry()
+
+	changed := func(do func()) bool {
+		ch := r.Changed()
+		do()
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if !changed(func() { r.Add(Session{ID: "a"}, &fakePipe{}) }) {
+		t.Fatal("Add did not signal a change")
+	}
+	if !changed(r.Notify) {
+		t.Fatal("Notify did not signal a change")
+	}
+	if changed(func() { r.Remove("missing") }) {
+		t.Fatal("removing an unknown id signalled a change")
+	}
+	if !changed(func() { r.Remove("a") }) {
+		t.Fatal("Remove did not signal a change")
+	}
+}
@@ -2,15 +2,23 @@ package admin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"net"
 	"os"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/tg123/sshpiper/libadmin"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 // Server is the in-process admin gRPC service for a single sshpiperd
@@ -23,8 +31,22 @@ type Server struct {
 	id        string
 	version   string
 	sshAddr   string
+
+	reloadHostKeys HostKeyReloader
+	pluginStatus   PluginStatusFunc
+	allowAttach    bool
+	history        *History
+
+	recordings       fs.FS
+	recordingsByUser bool
 }
 
+// HostKeyReloader reloads the daemon's host keys, see SetHostKeyReloader.
+type HostKeyReloader func() (*libadmin.ReloadHostKeysResponse, error)
+
+// PluginStatusFunc reports the supervised plugins, see SetPluginStatus.
+type PluginStatusFunc func() []*libadmin.PluginStatus
+
 // NewServer returns a Server bound to the given Registry. id and version are
 // echoed back to clients via ServerInfo; sshAddr is the listening address of
 // the SSH proxy this admin server represents.
@@ -42,6 +64,29 @@ func NewServer(reg *Registry, id, version, sshAddr string) *Server {
 	}
 }
 
+// SetHostKeyReloader enables the ReloadHostKeys RPC. Without a reloader the
+// RPC returns codes.Unimplemented.
+func (s *Server) SetHostKeyReloader(fn HostKeyReloader) {
+	s.reloadHostKeys = fn
+}
+
+// SetPluginStatus makes ServerInfo report the supervised plugins.
+func (s *Server) SetPluginStatus(fn PluginStatusFunc) {
+	s.pluginStatus = fn
+}
+
+// SetAllowAttach enables the AttachSession RPC, refused with
+// codes.PermissionDenied otherwise.
+func (s *Server) SetAllowAttach(allow bool) {
+	s.allowAttach = allow
+}
+
+// SetHistory enables the ListSessionHistory RPC, refused with
+// codes.FailedPrecondition otherwise.
+func (s *Server) SetHistory(h *History) {
+	s.history = h
+}
+
 // Register attaches the admin service to grpcServer.
 func (s *Server) Register(grpcServer *grpc.Server) {
 	libadmin.RegisterSshPiperAdminServer(grpcServer, s)
@@ -55,13 +100,48 @@ func (s *Server) Serve(lis net.Listener, grpcServer *grpc.Server) error {
 }
 
 // ServerInfo implements libadmin.SshPiperAdminServer.
-func (s *Server) ServerInfo(_ context.Context, _ *libadmin.ServerInfoRequest) (*libadmin.ServerInfoResponse, error) {
-	return &libadmin.ServerInfoResponse{
+func (s *Server) ServerInfo(ctx context.Context, _ *libadmin.ServerInfoRequest) (*libadmin.ServerInfoResponse, error) {
+	resp := &libadmin.ServerInfoResponse{
 		Id:        s.id,
 		Version:   s.version,
 		SshAddr:   s.sshAddr,
 		StartedAt: s.startedAt.Unix(),
-	}, nil
+		Role:      callerRole(ctx),
+	}
+	if s.pluginStatus != nil {
+		resp.Plugins = s.pluginStatus()
+	}
+	for _, rpc := range s.rpcNames() {
+		if resp.Role == "" || RoleAllows(resp.Role, rpc) {
+			resp.AllowedRpcs = append(resp.AllowedRpcs, rpc)
+		}
+	}
+	return resp, nil
+}
+
+// rpcNames returns the names of the rpcs enabled on s.
+func (s *Server) rpcNames() []string {
+	desc := libadmin.SshPiperAdmin_ServiceDesc
+	var names []string
+	for _, m := range desc.Methods {
+		names = append(names, m.MethodName)
+	}
+	for _, st := range desc.Streams {
+		names = append(names, st.StreamName)
+	}
+	return slices.DeleteFunc(names, func(name string) bool {
+		switch name {
+		case "AttachSession":
+			return !s.allowAttach
+		case "ReloadHostKeys":
+			return s.reloadHostKeys == nil
+		case "ListSessionHistory":
+			return s.history == nil
+		case "ListRecordings", "GetRecording":
+			return s.recordings == nil
+		}
+		return false
+	})
 }
 
 // ListSessions implements libadmin.SshPiperAdminServer.
@@ -69,27 +149,350 @@ func (s *Server) ListSessions(_ context.Context, _ *libadmin.ListSessionsRequest
 	sessions := s.registry.List()
 	out := make([]*libadmin.Session, 0, len(sessions))
 	for _, sess := range sessions {
-		_, bc, ok := s.registry.Get(sess.ID)
-		streamable := ok && bc.HasHeader()
-		out = append(out, &libadmin.Session{
-			Id:             sess.ID,
-			DownstreamUser: sess.DownstreamUser,
-			DownstreamAddr: sess.DownstreamAddr,
-			UpstreamUser:   sess.UpstreamUser,
-			UpstreamAddr:   sess.UpstreamAddr,
-			StartedAt:      sess.StartedAt.Unix(),
-			Streamable:     streamable,
-		})
+		out = append(out, s.sessionProto(sess))
 	}
 	return &libadmin.ListSessionsResponse{Sessions: out}, nil
 }
 
+// GetSession implements libadmin.SshPiperAdminServer.
+func (s *Server) GetSession(_ context.Context, req *libadmin.GetSessionRequest) (*libadmin.GetSessionResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "id is required")
+	}
+	sess, _, ok := s.registry.Get(req.GetId())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session %q not found", req.GetId())
+	}
+
+	resp := &libadmin.GetSessionResponse{
+		Session:              s.sessionProto(sess),
+		DownstreamAlgorithms: algorithmsProto(sess.DownstreamAlgorithms),
+		UpstreamAlgorithms:   algorithmsProto(sess.UpstreamAlgorithms),
+		ClientVersion:        sess.ClientVersion,
+		ServerVersion:        sess.ServerVersion,
+	}
+	if hook, ok := s.registry.StreamHook(sess.ID); ok {
+		for _, c := range hook.Channels() {
+			resp.Channels = append(resp.Channels, &libadmin.Channel{
+				Id:              c.ID,
+				Type:            c.Type,
+				Request:         c.Request,
+				Command:         c.Command,
+				Width:           c.Width,
+				Height:          c.Height,
+				StartedAt:       c.StartedAt.Unix(),
+				DownstreamBytes: c.DownstreamBytes,
+				UpstreamBytes:   c.UpstreamBytes,
+			})
+		}
+		if t := hook.LastActivity(); !t.IsZero() {
+			resp.LastActivityAt = t.Unix()
+		}
+	}
+	return resp, nil
+}
+
+func algorithmsProto(a Algorithms) *libadmin.Algorithms {
+	return &libadmin.Algorithms{
+		Kex:       a.KeyExchange,
+		HostKey:   a.HostKey,
+		CipherIn:  a.CipherIn,
+		CipherOut: a.CipherOut,
+		MacIn:     a.MACIn,
+		MacOut:    a.MACOut,
+	}
+}
+
+// sessionProto returns the libadmin view of sess.
+func (s *Server) sessionProto(sess Session) *libadmin.Session {
+	_, bc, ok := s.registry.Get(sess.ID)
+	streamable := ok && bc.HasHeader()
+	out := &libadmin.Session{
+		Id:             sess.ID,
+		DownstreamUser: sess.DownstreamUser,
+		DownstreamAddr: sess.DownstreamAddr,
+		UpstreamUser:   sess.UpstreamUser,
+		UpstreamAddr:   sess.UpstreamAddr,
+		StartedAt:      sess.StartedAt.Unix(),
+		Streamable:     streamable,
+		Labels:         sess.Labels,
+	}
+	if hook, ok := s.registry.StreamHook(sess.ID); ok {
+		out.Paused = hook.Paused()
+		channels, downstreamBytes, upstreamBytes := hook.Stats()
+		out.Channels = uint32(channels) //nolint:gosec // channel count
+		out.DownstreamBytes = downstreamBytes
+		out.UpstreamBytes = upstreamBytes
+	}
+	return out
+}
+
+// ListSessionHistory implements libadmin.SshPiperAdminServer.
+func (s *Server) ListSessionHistory(_ context.Context, req *libadmin.ListSessionHistoryRequest) (*libadmin.ListSessionHistoryResponse, error) {
+	if s.history == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "session history is disabled, see --admin-grpc-history-file")
+	}
+	if req.GetPageSize() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must not be negative")
+	}
+
+	filter := HistoryFilter{
+		DownstreamUser: req.GetDownstreamUser(),
+		UpstreamAddr:   req.GetUpstreamAddr(),
+		Labels:         req.GetLabels(),
+	}
+	if req.GetSince() > 0 {
+		filter.Since = time.Unix(req.GetSince(), 0)
+	}
+	if req.GetUntil() > 0 {
+		filter.Until = time.Unix(req.GetUntil(), 0)
+	}
+
+	records, next, err := s.history.List(filter, int(req.GetPageSize()), req.GetPageToken())
+	if errors.Is(err, errBadPageToken) {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list session history: %v", err)
+	}
+
+	resp := &libadmin.ListSessionHistoryResponse{NextPageToken: next}
+	for _, rec := range records {
+		resp.Sessions = append(resp.Sessions, &libadmin.SessionHistoryEntry{
+			Session: &libadmin.Session{
+				Id:              rec.ID,
+				DownstreamUser:  rec.DownstreamUser,
+				DownstreamAddr:  rec.DownstreamAddr,
+				UpstreamUser:    rec.UpstreamUser,
+				UpstreamAddr:    rec.UpstreamAddr,
+				StartedAt:       rec.StartedAt.Unix(),
+				Labels:          rec.Labels,
+				DownstreamBytes: rec.DownstreamBytes,
+				UpstreamBytes:   rec.UpstreamBytes,
+			},
+			EndedAt:        rec.EndedAt.Unix(),
+			CloseReason:    rec.CloseReason,
+			Commands:       rec.Commands,
+			Channels:       rec.Channels,
+			ExitStatuses:   rec.ExitStatuses,
+			RecordingFiles: rec.RecordingFiles,
+			ClientVersion:  rec.ClientVersion,
+			ServerVersion:  rec.ServerVersion,
+			Cursor:         rec.Cursor,
+
+			DroppedCommands:     rec.DroppedCommands,
+			DroppedExitStatuses: rec.DroppedExitStatuses,
+		})
+	}
+	return resp, nil
+}
+
+// defaultWatchInterval is how often WatchSessions refreshes the counters
+// by default.
+const defaultWatchInterval = 5 * time.Second
+
+// WatchSessions implements libadmin.SshPiperAdminServer.
+//
+// It diffs snapshots of the registry, taken whenever the registry changes
+// and every interval for the counters.
+func (s *Server) WatchSessions(req *libadmin.WatchSessionsRequest, stream libadmin.SshPiperAdmin_WatchSessionsServer) error {
+	interval := time.Duration(req.GetIntervalSeconds()) * time.Second
+	if interval == 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(max(interval, time.Second))
+	defer ticker.Stop()
+
+	send := func(t libadmin.SessionEventType, sess *libadmin.Session) error {
+		return stream.Send(&libadmin.SessionEvent{Type: t, Session: sess})
+	}
+
+	last := make(map[string]*libadmin.Session)
+	synced := false
+	for {
+		// taken before the snapshot, so no change is missed
+		changed := s.registry.Changed()
+
+		current := make(map[string]*libadmin.Session, len(last))
+		for _, sess := range s.registry.List() {
+			out := s.sessionProto(sess)
+			current[sess.ID] = out
+			prev, ok := last[sess.ID]
+			switch {
+			case !ok:
+				if err := send(libadmin.SessionEventType_SESSION_ADDED, out); err != nil {
+					return err
+				}
+			case !proto.Equal(prev, out):
+				if err := send(libadmin.SessionEventType_SESSION_UPDATED, out); err != nil {
+					return err
+				}
+			}
+		}
+		for id, prev := range last {
+			if _, ok := current[id]; !ok {
+				if err := send(libadmin.SessionEventType_SESSION_REMOVED, prev); err != nil {
+					return err
+				}
+			}
+		}
+		last = current
+
+		if !synced {
+			if err := send(libadmin.SessionEventType_SESSION_SYNCED, nil); err != nil {
+				return err
+			}
+			synced = true
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
 // KillSession implements libadmin.SshPiperAdminServer.
 func (s *Server) KillSession(_ context.Context, req *libadmin.KillSessionRequest) (*libadmin.KillSessionResponse, error) {
 	if req.GetId() == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "id is required")
 	}
-	return &libadmin.KillSessionResponse{Killed: s.registry.Kill(req.GetId())}, nil
+	reason, err := killReason(req.GetReason())
+	if err != nil {
+		return nil, err
+	}
+	killed := s.registry.Kill(req.GetId(), reason)
+	if killed {
+		slog.Info("admin killed session", "session", req.GetId(), "reason", reason)
+	}
+	return &libadmin.KillSessionResponse{Killed: killed}, nil
+}
+
+// KillSessions implements libadmin.SshPiperAdminServer.
+func (s *Server) KillSessions(_ context.Context, req *libadmin.KillSessionsRequest) (*libadmin.KillSessionsResponse, error) {
+	if req.GetDownstreamUser() == "" && req.GetUpstreamAddr() == "" && req.GetDownstreamCidr() == "" && len(req.GetLabels()) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "at least one selector is required")
+	}
+	var cidr *net.IPNet
+	if req.GetDownstreamCidr() != "" {
+		var err error
+		if _, cidr, err = net.ParseCIDR(req.GetDownstreamCidr()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid downstream cidr: %v", err)
+		}
+	}
+	reason, err := killReason(req.GetReason())
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*libadmin.Session
+	for _, sess := range s.registry.List() {
+		if req.GetDownstreamUser() != "" && sess.DownstreamUser != req.GetDownstreamUser() {
+			continue
+		}
+		if req.GetUpstreamAddr() != "" && !matchAddr(sess.UpstreamAddr, req.GetUpstreamAddr()) {
+			continue
+		}
+		if cidr != nil && !matchCIDR(sess.DownstreamAddr, cidr) {
+			continue
+		}
+		if !libadmin.MatchLabels(sess.Labels, req.GetLabels()) {
+			continue
+		}
+		// taken before the kill, which may unregister the session
+		info := s.sessionProto(sess)
+		if s.registry.Kill(sess.ID, reason) {
+			out = append(out, info)
+		}
+	}
+	slog.Info("admin killed sessions", "count", len(out), "downstreamUser", req.GetDownstreamUser(), "upstreamAddr", req.GetUpstreamAddr(), "downstreamCIDR", req.GetDownstreamCidr(), "labels", req.GetLabels(), "reason", reason)
+	return &libadmin.KillSessionsResponse{Sessions: out}, nil
+}
+
+// killReason validates and sanitizes the reason of a kill.
+func killReason(reason string) (string, error) {
+	if len(reason) > maxMessageLen {
+		return "", status.Errorf(codes.InvalidArgument, "reason is longer than %d bytes", maxMessageLen)
+	}
+	return strings.TrimSpace(stripControl(reason, false)), nil
+}
+
+// matchAddr reports whether addr, as host:port, is want, or has want as
+// its host.
+func matchAddr(addr, want string) bool {
+	if addr == want {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && host == strings.Trim(want, "[]")
+}
+
+// matchCIDR reports whether the host of addr, as host:port, is in cidr.
+func matchCIDR(addr string, cidr *net.IPNet) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && cidr.Contains(ip)
+}
+
+// maxMessageLen bounds the messages of MessageSession.
+const maxMessageLen = 4096
+
+// MessageSession implements libadmin.SshPiperAdminServer.
+func (s *Server) MessageSession(_ context.Context, req *libadmin.MessageSessionRequest) (*libadmin.MessageSessionResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "id is required")
+	}
+	if len(req.GetMessage()) > maxMessageLen {
+		return nil, status.Errorf(codes.InvalidArgument, "message is longer than %d bytes", maxMessageLen)
+	}
+	message := strings.ReplaceAll(stripControl(req.GetMessage(), true), "\n", "\r\n")
+	if strings.TrimSpace(message) == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "message is required")
+	}
+
+	hook, ok := s.registry.StreamHook(req.GetId())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session %q not found", req.GetId())
+	}
+	n, err := hook.Message(message)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "message session %q: %v", req.GetId(), err)
+	}
+	slog.Info("admin messaged session", "session", req.GetId(), "channels", n)
+	return &libadmin.MessageSessionResponse{Channels: int32(n)}, nil //nolint:gosec // channel count
+}
+
+// PauseSession implements libadmin.SshPiperAdminServer.
+func (s *Server) PauseSession(_ context.Context, req *libadmin.PauseSessionRequest) (*libadmin.PauseSessionResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "id is required")
+	}
+	hook, ok := s.registry.StreamHook(req.GetId())
+	if ok {
+		hook.Pause()
+		s.registry.Notify()
+		slog.Info("admin paused session", "session", req.GetId())
+	}
+	return &libadmin.PauseSessionResponse{Paused: ok}, nil
+}
+
+// ResumeSession implements libadmin.SshPiperAdminServer.
+func (s *Server) ResumeSession(_ context.Context, req *libadmin.ResumeSessionRequest) (*libadmin.ResumeSessionResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "id is required")
+	}
+	hook, ok := s.registry.StreamHook(req.GetId())
+	if ok {
+		hook.Resume()
+		s.registry.Notify()
+		slog.Info("admin resumed session", "session", req.GetId())
+	}
+	return &libadmin.ResumeSessionResponse{Resumed: ok}, nil
 }
 
 // StreamSession implements libadmin.SshPiperAdminServer.
@@ -125,6 +528,147 @@ func (s *Server) StreamSession(req *libadmin.StreamSessionRequest, stream libadm
 	}
 }
 
+// AttachSession implements libadmin.SshPiperAdminServer.
+func (s *Server) AttachSession(stream libadmin.SshPiperAdmin_AttachSessionServer) error {
+	if !s.allowAttach {
+		return status.Errorf(codes.PermissionDenied, "attach is disabled on this sshpiperd (--admin-grpc-allow-attach=false)")
+	}
+
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if req.GetId() == "" {
+		return status.Errorf(codes.InvalidArgument, "id is required")
+	}
+	_, bc, ok := s.registry.Get(req.GetId())
+	hook, attachable := s.registry.StreamHook(req.GetId())
+	if !ok || !attachable {
+		return status.Errorf(codes.NotFound, "session %q not found", req.GetId())
+	}
+
+	ctx := stream.Context()
+	name, peerAddr := attachIdentity(ctx, req.GetName())
+
+	// subscribe first so no output after the joined notice is missed
+	frames, cancel := bc.Subscribe(req.GetReplay())
+	defer cancel()
+
+	a, err := hook.Attach(req.ChannelId, name)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "attach to session %q: %v", req.GetId(), err)
+	}
+
+	var written int
+	slog.Info("admin attached to session", "session", req.GetId(), "channel", a.ChannelID(), "admin", name, "peer", peerAddr)
+	defer func() {
+		a.Detach()
+		slog.Info("admin detached from session", "session", req.GetId(), "channel", a.ChannelID(), "admin", name, "peer", peerAddr, "input_bytes", written)
+	}()
+
+	// stream.Recv must not be called concurrently with itself, only with
+	// stream.Send, so all input is read by this goroutine.
+	inputs := make(chan []byte)
+	recvErr := make(chan error, 1)
+	go func(req *libadmin.AttachSessionRequest) {
+		for {
+			if len(req.GetInput()) > 0 {
+				select {
+				case inputs <- req.GetInput():
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			var err error
+			if req, err = stream.Recv(); err != nil {
+				recvErr <- err
+				return
+			}
+		}
+	}(req)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.Done():
+			return nil
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case input := <-inputs:
+			if err := a.Write(input); err != nil {
+				if errors.Is(err, ErrChannelEnded) {
+					return nil
+				}
+				return status.Errorf(codes.Unavailable, "write to session %q: %v", req.GetId(), err)
+			}
+			written += len(input)
+		case f, ok := <-frames:
+			if !ok {
+				return nil
+			}
+			if f.ChannelID != a.ChannelID() {
+				continue
+			}
+			msg, err := frameToProto(f)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// attachIdentity returns the admin name shown to the user, the common name
+// of the verified client certificate when there is one, else the sanitized
+// requested name, and the address of the admin.
+func attachIdentity(ctx context.Context, requested string) (name, addr string) {
+	name = stripControl(requested, false)
+	if r := []rune(name); len(r) > 64 {
+		name = string(r[:64])
+	}
+
+	cert, addr := peerCertificate(ctx)
+	if cert != nil && cert.Subject.CommonName != "" {
+		name = cert.Subject.CommonName
+	}
+
+	if strings.TrimSpace(name) == "" {
+		name = "(unnamed)"
+	}
+	return name, addr
+}
+
+// stripControl removes the control characters from text written to the
+// terminal of a user, but newlines when keepNewline is set.
+func stripControl(text string, keepNewline bool) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && (r != '\n' || !keepNewline) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// ReloadHostKeys implements libadmin.SshPiperAdminServer.
+func (s *Server) ReloadHostKeys(_ context.Context, _ *libadmin.ReloadHostKeysRequest) (*libadmin.ReloadHostKeysResponse, error) {
+	if s.reloadHostKeys == nil {
+		return nil, status.Errorf(codes.Unimplemented, "host key reload is not enabled")
+	}
+
+	resp, err := s.reloadHostKeys()
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "reload host keys: %v", err)
+	}
+	return resp, nil
+}
+
 func frameToProto(f Frame) (*libadmin.SessionFrame, error) {
 	switch f.Kind {
 	case "header":
@@ -1,21 +1,33 @@
 package admin
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/tg123/sshpiper/libadmin"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // startTestServer spins up an admin gRPC server on a random local port and
 // returns a connected client plus the registry it operates on.
-func startTestServer(t *testing.T) (*libadmin.Client, *Registry) {
+func startTestServer(t *testing.T, opts ...func(*Server)) (*libadmin.Client, *Registry) {
 	t.Helper()
 	reg := NewRegistry()
 	srv := NewServer(reg, "test-id", "test-version", "127.0.0.1:0")
+	for _, opt := range opts {
+		opt(srv)
+	}
 	gs := grpc.NewServer()
 	srv.Register(gs)
 
@@ -46,9 +58,13 @@ func TestServer_ServerInfoListKill(t *testing.T) {
 	if info.GetId() != "test-id" || info.GetVersion() != "test-version" {
 		t.Fatalf("unexpected info: %+v", info)
 	}
+	// without a policy every enabled rpc is allowed
+	if info.GetRole() != "" || !slices.Contains(info.GetAllowedRpcs(), "KillSession") || slices.Contains(info.GetAllowedRpcs(), "AttachSession") {
+		t.Fatalf("role %q, allowed rpcs %v", info.GetRole(), info.GetAllowedRpcs())
+	}
 
 	pipe := &fakePipe{}
-	reg.Add(Session{ID: "sess-1", DownstreamUser: "u", StartedAt: time.Now()}, pipe)
+	reg.Add(Session{ID: "sess-1", DownstreamUser: "u", StartedAt: time.Now(), Labels: map[string]string{"team": "db"}}, pipe)
 
 	sess, err := c.ListSessions(ctx)
 	if err != nil {
@@ -57,6 +73,9 @@ func TestServer_ServerInfoListKill(t *testing.T) {
 	if len(sess) != 1 || sess[0].GetId() != "sess-1" {
 		t.Fatalf("unexpected sessions: %+v", sess)
 	}
+	if sess[0].GetLabels()["team"] != "db" {
+		t.Fatalf("unexpected labels: %v", sess[0].GetLabels())
+	}
 
 	killed, err := c.KillSession(ctx, "sess-1")
 	if err != nil {
@@ -113,3 +132,602 @@ func TestServer_StreamSession(t *testing.T) {
 		t.Fatalf("bad event frame: %+v", frame)
 	}
 }
+
+func TestServer_ReloadHostKeys(t *testing.T) {
+	srv := NewServer(NewRegistry(), "test-id", "test-version", "127.0.0.1:0")
+	gs := grpc.NewServer()
+	srv.Register(gs)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(func() { gs.Stop() })
+
+	c, err := libadmin.NewClient(lis.Addr().String(), libadmin.DialOptions{Insecure: true})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.ReloadHostKeys(ctx); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented without a reloader, got %v", err)
+	}
+
+	srv.SetHostKeyReloader(func() (*libadmin.ReloadHostKeysResponse, error) {
+		return &libadmin.ReloadHostKeysResponse{Fingerprints: []string{"SHA256:new"}}, nil
+	})
+	resp, err := c.ReloadHostKeys(ctx)
+	if err != nil {
+		t.Fatalf("ReloadHostKeys: %v", err)
+	}
+	if len(resp.GetFingerprints()) != 1 || resp.GetFingerprints()[0] != "SHA256:new" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	srv.SetHostKeyReloader(func() (*libadmin.ReloadHostKeysResponse, error) {
+		return nil, fmt.Errorf("bad key")
+	})
+	if _, err := c.ReloadHostKeys(ctx); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition on reload error, got %v", err)
+	}
+}
+
+func TestServer_ServerInfoPlugins(t *testing.T) {
+	srv := NewServer(NewRegistry(), "test-id", "test-version", "127.0.0.1:0")
+	gs := grpc.NewServer()
+	srv.Register(gs)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(func() { gs.Stop() })
+
+	c, err := libadmin.NewClient(lis.Addr().String(), libadmin.DialOptions{Insecure: true})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	info, err := c.ServerInfo(ctx)
+	if err != nil {
+		t.Fatalf("ServerInfo: %v", err)
+	}
+	if len(info.GetPlugins()) != 0 {
+		t.Fatalf("expected no plugins without status func, got %+v", info.GetPlugins())
+	}
+
+	srv.SetPluginStatus(func() []*libadmin.PluginStatus {
+		return []*libadmin.PluginStatus{{Name: "fixed", Available: false, Restarts: 3, LastError: "plugin process exited"}}
+	})
+	info, err = c.ServerInfo(ctx)
+	if err != nil {
+		t.Fatalf("ServerInfo: %v", err)
+	}
+	if len(info.GetPlugins()) != 1 || info.GetPlugins()[0].GetRestarts() != 3 || info.GetPlugins()[0].GetAvailable() {
+		t.Fatalf("unexpected plugins: %+v", info.GetPlugins())
+	}
+}
+
+func TestServer_AttachSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	attach := func(c *libadmin.Client, req *libadmin.AttachSessionRequest) libadmin.SshPiperAdmin_AttachSessionClient {
+		t.Helper()
+		stream, err := c.RPC().AttachSession(ctx)
+		if err != nil {
+			t.Fatalf("AttachSession: %v", err)
+		}
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		return stream
+	}
+
+	c, _ := startTestServer(t)
+	if _, err := attach(c, &libadmin.AttachSessionRequest{Id: "s"}).Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied by default, got %v", err)
+	}
+
+	c, reg := startTestServer(t, func(s *Server) { s.SetAllowAttach(true) })
+	if _, err := attach(c, &libadmin.AttachSessionRequest{Id: "missing"}).Recv(); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	bc := reg.Add(Session{ID: "s"}, &fakePipe{})
+	h := NewStreamHook(bc)
+	w := &fakeWriter{}
+	h.SetPacketWriter(w)
+	reg.SetStreamHook("s", h)
+	openShell(t, h)
+
+	stream := attach(c, &libadmin.AttachSessionRequest{Id: "s", Name: "alice\x1b[2J", Input: []byte("ls\r")})
+
+	// the output is subscribed to before the user is told
+	for joined := false; !joined; {
+		w.mu.Lock()
+		joined = len(w.downstream) > 0
+		w.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	// other channels are not streamed
+	bc.Publish(Frame{Kind: "o", ChannelID: 2, Data: []byte("other")})
+	bc.Publish(Frame{Kind: "o", ChannelID: 1, Data: []byte("files")})
+	for {
+		frame, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		ev := frame.GetEvent()
+		if ev.GetChannelId() != 1 {
+			t.Fatalf("frame of channel %d streamed", ev.GetChannelId())
+		}
+		if ev.GetKind() == "o" {
+			if string(ev.GetData()) != "files" {
+				t.Fatalf("unexpected output %q", ev.GetData())
+			}
+			break
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expected EOF after detach, got %v", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.upstream) != 1 || string(w.upstream[0][9:]) != "ls\r" {
+		t.Fatalf("upstream = %q", w.upstream)
+	}
+	if len(w.downstream) != 2 || !bytes.Contains(w.downstream[0], []byte("admin alice[2J joined")) || !bytes.Contains(w.downstream[1], []byte("admin alice[2J left")) {
+		t.Fatalf("downstream = %q", w.downstream)
+	}
+}
+
+func TestServer_MessagePauseResume(t *testing.T) {
+	c, reg := startTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := &fakePipe{}
+	bc := reg.Add(Session{ID: "s"}, pipe)
+	h := NewStreamHook(bc)
+	w := &fakeWriter{}
+	h.SetPacketWriter(w)
+	reg.SetStreamHook("s", h)
+	openShell(t, h)
+
+	resp, err := c.RPC().MessageSession(ctx, &libadmin.MessageSessionRequest{Id: "s", Message: "going down\nin 5m\x1b[2J"})
+	if err != nil || resp.GetChannels() != 1 {
+		t.Fatalf("MessageSession() = %v, %v", resp, err)
+	}
+	if len(w.downstream) != 1 || !bytes.HasSuffix(w.downstream[0], []byte("[sshpiper: going down\r\nin 5m[2J]\r\n")) {
+		t.Fatalf("downstream = %q", w.downstream)
+	}
+	for _, req := range []*libadmin.MessageSessionRequest{
+		{Id: "s"},
+		{Id: "s", Message: string(make([]byte, maxMessageLen+1))},
+	} {
+		if _, err := c.RPC().MessageSession(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, got %v", err)
+		}
+	}
+	if _, err := c.RPC().MessageSession(ctx, &libadmin.MessageSessionRequest{Id: "missing", Message: "hi"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	paused, err := c.RPC().PauseSession(ctx, &libadmin.PauseSessionRequest{Id: "s"})
+	if err != nil || !paused.GetPaused() {
+		t.Fatalf("PauseSession() = %v, %v", paused, err)
+	}
+	sessions, err := c.ListSessions(ctx)
+	if err != nil || len(sessions) != 1 || !sessions[0].GetPaused() {
+		t.Fatalf("ListSessions() = %v, %v, want paused session", sessions, err)
+	}
+
+	resumed, err := c.RPC().ResumeSession(ctx, &libadmin.ResumeSessionRequest{Id: "s"})
+	if err != nil || !resumed.GetResumed() || h.Paused() {
+		t.Fatalf("ResumeSession() = %v, %v", resumed, err)
+	}
+
+	if paused, err := c.RPC().PauseSession(ctx, &libadmin.PauseSessionRequest{Id: "missing"}); err != nil || paused.GetPaused() {
+		t.Fatalf("PauseSession(missing) = %v, %v", paused, err)
+	}
+
+	// killing a paused session resumes it, so its pipe can unwind
+	h.Pause()
+	if !reg.Kill("s", "") || h.Paused() {
+		t.Fatal("kill did not resume the session")
+	}
+}
+
+func TestServer_GetSession(t *testing.T) {
+	c, reg := startTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bc := reg.Add(Session{
+		ID:                   "s",
+		DownstreamUser:       "alice",
+		ClientVersion:        "SSH-2.0-OpenSSH_9.6",
+		ServerVersion:        "SSH-2.0-OpenSSH_8.9",
+		DownstreamAlgorithms: Algorithms{KeyExchange: "curve25519-sha256", CipherIn: "aes128-ctr", MACIn: "hmac-sha2-256"},
+	}, &fakePipe{})
+	h := NewStreamHook(bc)
+	reg.SetStreamHook("s", h)
+	openShell(t, h)
+	h.Down(packet(msgChannelData, uint32(11), "ls\n"))
+
+	resp, err := c.GetSession(ctx, "s")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if resp.GetSession().GetDownstreamUser() != "alice" || resp.GetSession().GetChannels() != 1 {
+		t.Fatalf("session = %v", resp.GetSession())
+	}
+	if resp.GetClientVersion() != "SSH-2.0-OpenSSH_9.6" || resp.GetServerVersion() != "SSH-2.0-OpenSSH_8.9" {
+		t.Fatalf("versions = %q, %q", resp.GetClientVersion(), resp.GetServerVersion())
+	}
+	if algs := resp.GetDownstreamAlgorithms(); algs.GetKex() != "curve25519-sha256" || algs.GetCipherIn() != "aes128-ctr" || algs.GetMacIn() != "hmac-sha2-256" {
+		t.Fatalf("downstream algorithms = %v", algs)
+	}
+	if len(resp.GetChannels()) != 1 {
+		t.Fatalf("channels = %v", resp.GetChannels())
+	}
+	if ch := resp.GetChannels()[0]; ch.GetId() != 1 || ch.GetType() != "session" || ch.GetRequest() != "shell" || ch.GetDownstreamBytes() != 3 || ch.GetStartedAt() == 0 {
+		t.Fatalf("channel = %v", ch)
+	}
+	if resp.GetLastActivityAt() == 0 {
+		t.Fatal("no last activity")
+	}
+
+	if _, err := c.GetSession(ctx, "missing"); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	if _, err := c.GetSession(ctx, ""); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestServer_ListSessionHistory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, _ := startTestServer(t)
+	if _, err := c.ListSessionHistory(ctx, &libadmin.ListSessionHistoryRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without history, got %v", err)
+	}
+
+	h := openTestHistory(t, 0)
+	c, _ = startTestServer(t, func(s *Server) { s.SetHistory(h) })
+	started := time.Unix(1700000000, 0)
+	for _, id := range []string{"s1", "s2"} {
+		err := h.Add(HistoryRecord{
+			Session:         Session{ID: id, DownstreamUser: "alice", StartedAt: started, ClientVersion: "SSH-2.0-OpenSSH_9.6"},
+			EndedAt:         started.Add(time.Minute),
+			DownstreamBytes: 3,
+			CloseReason:     "killed by admin: bye",
+			Commands:        []string{"uptime"},
+			Channels:        map[string]int64{"session": 1},
+			ExitStatuses:    []uint32{0},
+			RecordingFiles:  []string{"alice/1700000000.typescript"},
+		})
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	resp, err := c.ListSessionHistory(ctx, &libadmin.ListSessionHistoryRequest{DownstreamUser: "alice", PageSize: 1})
+	if err != nil {
+		t.Fatalf("ListSessionHistory: %v", err)
+	}
+	if len(resp.GetSessions()) != 1 || resp.GetNextPageToken() == "" {
+		t.Fatalf("first page = %v", resp)
+	}
+	e := resp.GetSessions()[0]
+	if e.GetSession().GetId() != "s2" || e.GetSession().GetStartedAt() != started.Unix() || e.GetEndedAt() != started.Add(time.Minute).Unix() ||
+		e.GetSession().GetDownstreamBytes() != 3 || e.GetCloseReason() != "killed by admin: bye" || e.GetClientVersion() != "SSH-2.0-OpenSSH_9.6" ||
+		len(e.GetCommands()) != 1 || e.GetChannels()["session"] != 1 || len(e.GetExitStatuses()) != 1 || len(e.GetRecordingFiles()) != 1 {
+		t.Fatalf("entry = %v", e)
+	}
+
+	resp, err = c.ListSessionHistory(ctx, &libadmin.ListSessionHistoryRequest{PageSize: 1, PageToken: resp.GetNextPageToken()})
+	if err != nil || len(resp.GetSessions()) != 1 || resp.GetSessions()[0].GetSession().GetId() != "s1" || resp.GetNextPageToken() != "" {
+		t.Fatalf("second page = %v, %v", resp, err)
+	}
+
+	if _, err := c.ListSessionHistory(ctx, &libadmin.ListSessionHistoryRequest{PageToken: "!"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a bad token, got %v", err)
+	}
+}
+
+func TestServer_Recordings(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, _ := startTestServer(t)
+	if _, err := c.ListRecordings(ctx, &libadmin.ListRecordingsRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without recordings, got %v", err)
+	}
+
+	dir := t.TempDir()
+	cast := `{"version": 2, "width": 80, "height": 24, "timestamp": 1700000100, "env": {}}` + "\n" + `[0.5,"o","hi"]` + "\n"
+	for name, content := range map[string]string{
+		"live/shell-channel-0.cast":   cast,
+		"done/shell-channel-0.cast":   cast,
+		"old/1600000000.typescript":   "Script started\n",
+		"old/1600000000.timing":       "0.1 1\n",
+		"notes.txt":                   "not a recording",
+		"done/shell-channel-1.cast.x": "",
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Unix(1600000100, 0)
+	if err := os.Chtimes(filepath.Join(dir, "old/1600000000.typescript"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	h := openTestHistory(t, 0)
+	if err := h.Add(HistoryRecord{Session: Session{ID: "done", DownstreamUser: "bob"}, EndedAt: time.Now(), RecordingFiles: []string{"done/shell-channel-0.cast"}}); err != nil {
+		t.Fatal(err)
+	}
+	c, reg := startTestServer(t, func(s *Server) {
+		s.SetHistory(h)
+		s.SetRecordings(root.FS(), false)
+	})
+	reg.Add(Session{ID: "live", DownstreamUser: "alice"}, &fakePipe{})
+
+	resp, err := c.ListRecordings(ctx, &libadmin.ListRecordingsRequest{})
+	if err != nil {
+		t.Fatalf("ListRecordings: %v", err)
+	}
+	byPath := make(map[string]*libadmin.Recording)
+	for _, r := range resp.GetRecordings() {
+		byPath[r.GetPath()] = r
+	}
+	if len(resp.GetRecordings()) != 3 || resp.GetRecordings()[2].GetPath() != "old/1600000000.typescript" || resp.GetNextPageToken() != "" {
+		t.Fatalf("ListRecordings = %v", resp)
+	}
+	if r := byPath["live/shell-channel-0.cast"]; r.GetSessionId() != "live" || r.GetDownstreamUser() != "alice" || r.GetFormat() != "asciicast" ||
+		r.GetStartedAt() != 1700000100 || r.GetSize() != uint64(len(cast)) {
+		t.Errorf("live recording = %v", r)
+	}
+	if r := byPath["done/shell-channel-0.cast"]; r.GetSessionId() != "done" || r.GetDownstreamUser() != "bob" {
+		t.Errorf("finished recording = %v", r)
+	}
+	if r := byPath["old/1600000000.typescript"]; r.GetFormat() != "typescript" || r.GetTimingPath() != "old/1600000000.timing" ||
+		r.GetStartedAt() != 1600000000 || r.GetModifiedAt() != old.Unix() || r.GetDownstreamUser() != "" {
+		t.Errorf("typescript recording = %v", r)
+	}
+
+	for req, want := range map[*libadmin.ListRecordingsRequest][]string{
+		{DownstreamUser: "bob"}: {"done/shell-channel-0.cast"},
+		{SessionId: "live"}:     {"live/shell-channel-0.cast"},
+		{Until: 1650000000}:     {"old/1600000000.typescript"},
+		{Since: 1650000000}:     {"done/shell-channel-0.cast", "live/shell-channel-0.cast"},
+	} {
+		resp, err := c.ListRecordings(ctx, req)
+		if err != nil {
+			t.Fatalf("ListRecordings(%v): %v", req, err)
+		}
+		var got []string
+		for _, r := range resp.GetRecordings() {
+			got = append(got, r.GetPath())
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("ListRecordings(%v) = %v, want %v", req, got, want)
+		}
+	}
+
+	var paged []string
+	req := &libadmin.ListRecordingsRequest{PageSize: 2}
+	for {
+		resp, err := c.ListRecordings(ctx, req)
+		if err != nil {
+			t.Fatalf("ListRecordings(%v): %v", req, err)
+		}
+		for _, r := range resp.GetRecordings() {
+			paged = append(paged, r.GetPath())
+		}
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		if len(resp.GetRecordings()) != 2 || resp.GetNextPageToken() != resp.GetRecordings()[1].GetCursor() {
+			t.Fatalf("ListRecordings(%v) = %v", req, resp)
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if len(paged) != 3 || paged[2] != "old/1600000000.typescript" {
+		t.Errorf("paged ListRecordings = %v", paged)
+	}
+	if _, err := c.ListRecordings(ctx, &libadmin.ListRecordingsRequest{PageToken: "!"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListRecordings with a bad page token = %v, want InvalidArgument", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.GetRecording(ctx, "live/shell-channel-0.cast", &buf); err != nil || buf.String() != cast {
+		t.Fatalf("GetRecording = %q, %v", buf.String(), err)
+	}
+	for path, code := range map[string]codes.Code{
+		"notes.txt":         codes.InvalidArgument,
+		"../etc/x.cast":     codes.InvalidArgument,
+		"/live/x.cast":      codes.InvalidArgument,
+		"nope/1.typescript": codes.NotFound,
+	} {
+		if err := c.GetRecording(ctx, path, io.Discard); status.Code(err) != code {
+			t.Errorf("GetRecording(%q) = %v, want %v", path, err, code)
+		}
+	}
+}
+
+// writerPipe is a fakePipe that records the packets written to it.
+type writerPipe struct {
+	fakePipe
+	fakeWriter
+}
+
+func TestServer_KillSessionReason(t *testing.T) {
+	c, reg := startTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := &writerPipe{}
+	reg.Add(Session{ID: "s"}, pipe)
+
+	killed, err := c.KillSessionWithReason(ctx, "s", "offboarded\x1b[2J ")
+	if err != nil || !killed {
+		t.Fatalf("KillSession() = %v, %v", killed, err)
+	}
+	want := packet(msgDisconnect, uint32(disconnectByApplication), "offboarded[2J", "")
+	if len(pipe.downstream) != 1 || !bytes.Equal(pipe.downstream[0], want) {
+		t.Fatalf("downstream = %q, want %q", pipe.downstream, want)
+	}
+	if pipe.closed.Load() != 1 {
+		t.Fatalf("close calls = %d", pipe.closed.Load())
+	}
+
+	if _, err := c.KillSessionWithReason(ctx, "s", string(make([]byte, maxMessageLen+1))); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestServer_KillSessions(t *testing.T) {
+	c, reg := startTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipes := map[string]*writerPipe{}
+	for _, s := range []Session{
+		{ID: "a", DownstreamUser: "alice", DownstreamAddr: "10.0.0.1:5000", UpstreamAddr: "db:22", Labels: map[string]string{"team": "db"}},
+		{ID: "b", DownstreamUser: "alice", DownstreamAddr: "192.168.0.1:5000", UpstreamAddr: "web:22"},
+		{ID: "c", DownstreamUser: "bob", DownstreamAddr: "10.0.0.2:5000", UpstreamAddr: "db:22", Labels: map[string]string{"team": "db"}},
+		{ID: "d", DownstreamUser: "bob", DownstreamAddr: "[fd00::1]:5000", UpstreamAddr: "[fd00::2]:22"},
+	} {
+		pipes[s.ID] = &writerPipe{}
+		reg.Add(s, pipes[s.ID])
+	}
+
+	if _, err := c.KillSessions(ctx, &libadmin.KillSessionsRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument without selector, got %v", err)
+	}
+	if _, err := c.KillSessions(ctx, &libadmin.KillSessionsRequest{DownstreamCidr: "10.0.0.0"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for invalid cidr, got %v", err)
+	}
+
+	cases := []struct {
+		req  *libadmin.KillSessionsRequest
+		want []string
+	}{
+		{&libadmin.KillSessionsRequest{DownstreamUser: "alice", DownstreamCidr: "10.0.0.0/8"}, []string{"a"}},
+		{&libadmin.KillSessionsRequest{UpstreamAddr: "db", Labels: map[string]string{"team": "db"}, Reason: "bye"}, []string{"c"}},
+		{&libadmin.KillSessionsRequest{UpstreamAddr: "web:22"}, []string{"b"}},
+		{&libadmin.KillSessionsRequest{UpstreamAddr: "[fd00::2]", DownstreamCidr: "fd00::/64"}, []string{"d"}},
+		{&libadmin.KillSessionsRequest{DownstreamUser: "carol"}, nil},
+	}
+	for _, tc := range cases {
+		sessions, err := c.KillSessions(ctx, tc.req)
+		if err != nil {
+			t.Fatalf("KillSessions(%v): %v", tc.req, err)
+		}
+		var got []string
+		for _, s := range sessions {
+			got = append(got, s.GetId())
+			reg.Remove(s.GetId())
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("KillSessions(%v) = %v, want %v", tc.req, got, tc.want)
+		}
+	}
+
+	if len(pipes["c"].downstream) != 1 || !bytes.Contains(pipes["c"].downstream[0], []byte("bye")) {
+		t.Fatalf("reason not sent: %q", pipes["c"].downstream)
+	}
+	if len(pipes["a"].downstream) != 0 {
+		t.Fatalf("disconnect without reason: %q", pipes["a"].downstream)
+	}
+}
+
+func TestServer_WatchSessions(t *testing.T) {
+	c, reg := startTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reg.Add(Session{ID: "a", DownstreamUser: "alice"}, &fakePipe{})
+
+	stream, err := c.RPC().WatchSessions(ctx, &libadmin.WatchSessionsRequest{IntervalSeconds: 1})
+	if err != nil {
+		t.Fatalf("WatchSessions: %v", err)
+	}
+	next := func(want libadmin.SessionEventType, id string) *libadmin.Session {
+		t.Helper()
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if ev.GetType() != want || ev.GetSession().GetId() != id {
+			t.Fatalf("event = %v, want %v %q", ev, want, id)
+		}
+		return ev.GetSession()
+	}
+
+	if s := next(libadmin.SessionEventType_SESSION_ADDED, "a"); s.GetDownstreamUser() != "alice" {
+		t.Fatalf("unexpected session %v", s)
+	}
+	next(libadmin.SessionEventType_SESSION_SYNCED, "")
+
+	bc := reg.Add(Session{ID: "b"}, &fakePipe{})
+	next(libadmin.SessionEventType_SESSION_ADDED, "b")
+
+	// counters are refreshed periodically
+	h := NewStreamHook(bc)
+	reg.SetStreamHook("b", h)
+	openShell(t, h)
+	h.Down(packet(msgChannelData, uint32(11), "ls"))
+	if s := next(libadmin.SessionEventType_SESSION_UPDATED, "b"); s.GetChannels() != 1 || s.GetDownstreamBytes() != 2 {
+		t.Fatalf("unexpected counters %v", s)
+	}
+
+	// pausing is sent right away
+	if _, err := c.RPC().PauseSession(ctx, &libadmin.PauseSessionRequest{Id: "b"}); err != nil {
+		t.Fatalf("PauseSession: %v", err)
+	}
+	if s := next(libadmin.SessionEventType_SESSION_UPDATED, "b"); !s.GetPaused() {
+		t.Fatalf("session not paused %v", s)
+	}
+	if _, err := c.RPC().ResumeSession(ctx, &libadmin.ResumeSessionRequest{Id: "b"}); err != nil {
+		t.Fatalf("ResumeSession: %v", err)
+	}
+	if s := next(libadmin.SessionEventType_SESSION_UPDATED, "b"); s.GetPaused() {
+		t.Fatalf("session still paused %v", s)
+	}
+
+	reg.Remove("a")
+	if s := next(libadmin.SessionEventType_SESSION_REMOVED, "a"); s.GetDownstreamUser() != "alice" {
+		t.Fatalf("removed session lost its state %v", s)
+	}
+}
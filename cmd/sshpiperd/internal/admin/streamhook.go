@@ -3,7 +3,9 @@ package admin
 import (
 	"bytes"
 	"encoding/binary"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -12,9 +14,16 @@ import (
 // Mirrors the constants in cmd/sshpiperd/asciicast.go. Duplicated here to
 // keep this package free of an upward import.
 const (
-	msgChannelData        = 94
-	msgChannelRequest     = 98
-	msgChannelOpenConfirm = 91
+	msgDisconnect          = 1
+	msgChannelOpen         = 90
+	msgChannelOpenFailure  = 92
+	msgChannelData         = 94
+	msgChannelRequest      = 98
+	msgChannelOpenConfirm  = 91
+	msgChannelWindowAdjust = 93
+	msgChannelExtendedData = 95
+	msgChannelEOF          = 96
+	msgChannelClose        = 97
 )
 
 // StreamHook is an inspector that converts raw SSH packets observed by
@@ -27,26 +36,76 @@ const (
 //   - upstream → downstream channel-data becomes "o" (output) frames;
 //   - downstream → upstream pty-req / window-change become "header" / "r"
 //     frames with the correct terminal geometry.
+//
+// With a PacketWriter set, admins may also Attach to a channel and write to
+// it, see Attachment, and Message the user. Pause holds the channel data of
+// the client.
 type StreamHook struct {
-	bc *Broadcaster
+	bc     *Broadcaster
+	writer PacketWriter
 
 	mu sync.Mutex
 	// channelIDMap[server-side id] = client-side id, populated from
 	// channel-open-confirm packets so window-change requests addressed to
-	// the server-side id can be remapped to the per-channel header.
+	// the server-side id can be remapped to the per-channel header. It has
+	// an entry for every open channel.
 	channelIDMap map[uint32]uint32
-	// per-channel state — start time and accumulated env for the next
-	// pty-req-induced header.
+	// maxPacket[server-side id] is the maximum packet size the upstream
+	// server accepts on the channel, from the channel-open-confirm.
+	maxPacket map[uint32]uint32
+	// opening are the types of the channels opened by the client, not
+	// confirmed yet, by client-side id.
+	opening map[uint32]string
+	// channels is the state of the open channels, by client-side id.
 	channels map[uint32]*channelState
 	// pending env / pty info collected before "shell"/"exec" arrives.
 	pendingEnv  map[string]string
 	pendingW    uint32
 	pendingH    uint32
 	pendingTerm string
+
+	// resume is closed to resume the channel data of the client, nil while
+	// not paused.
+	resume chan struct{}
+
+	// downstreamBytes and upstreamBytes count the channel data received
+	// from the client and from the upstream server.
+	downstreamBytes atomic.Uint64
+	upstreamBytes   atomic.Uint64
+	// lastActivity is the unix nano time of the last channel data of
+	// either side, 0 before any.
+	lastActivity atomic.Int64
 }
 
 type channelState struct {
 	startTime time.Time
+	serverID  uint32
+	// kind is the channel type, e.g. session.
+	kind string
+	// request is the shell, exec or subsystem request of a session
+	// channel, command the exec command or subsystem name.
+	request string
+	command string
+	// width and height are the terminal size, 0 without a pty.
+	width  uint32
+	height uint32
+	// downstreamBytes and upstreamBytes count the channel data of the
+	// channel like those of StreamHook.
+	downstreamBytes uint64
+	upstreamBytes   uint64
+	// shell is set once the client asked for a shell or exec, only those
+	// channels are streamed.
+	shell bool
+
+	// done is closed once the client sent EOF or either side closed the
+	// channel, no input may be written to it anymore.
+	done  chan struct{}
+	ended bool
+	// inputDeficit and noticeDeficit are the bytes written by attached
+	// admins, to the upstream server and to the client, not yet taken out
+	// of the window adjustments of the other side. See Attachment.
+	inputDeficit  uint32
+	noticeDeficit uint32
 }
 
 // NewStreamHook returns a StreamHook that publishes to bc.
@@ -54,6 +113,8 @@ func NewStreamHook(bc *Broadcaster) *StreamHook {
 	return &StreamHook{
 		bc:           bc,
 		channelIDMap: make(map[uint32]uint32),
+		maxPacket:    make(map[uint32]uint32),
+		opening:      make(map[uint32]string),
 		channels:     make(map[uint32]*channelState),
 		pendingEnv:   make(map[string]string),
 	}
@@ -71,18 +132,22 @@ func (h *StreamHook) Up(msg []byte) (ssh.PipePacketHookMethod, []byte, error) {
 	// right channel id), but we can skip the per-packet output copy.
 	hasSubs := h.bc.HasSubscribers()
 	switch msg[0] {
-	case msgChannelData:
-		if !hasSubs {
-			break
-		}
-		if len(msg) < 9 {
+	case msgChannelData, msgChannelExtendedData:
+		if len(msg) < 5 {
 			break
 		}
+		n := channelDataLen(msg)
+		h.upstreamBytes.Add(n)
+		h.lastActivity.Store(time.Now().UnixNano())
 		clientChannelID := binary.BigEndian.Uint32(msg[1:5])
 		h.mu.Lock()
-		_, ok := h.channels[clientChannelID]
+		state, ok := h.channels[clientChannelID]
+		if ok {
+			state.upstreamBytes += n
+		}
+		ok = ok && state.shell
 		h.mu.Unlock()
-		if !ok {
+		if !ok || !hasSubs || msg[0] != msgChannelData || len(msg) < 9 {
 			break
 		}
 		// the data payload starts at offset 9 (1 byte msg + 4 channel + 4 length)
@@ -101,6 +166,48 @@ func (h *StreamHook) Up(msg []byte) (ssh.PipePacketHookMethod, []byte, error) {
 		serverChannelID := binary.BigEndian.Uint32(msg[5:9])
 		h.mu.Lock()
 		h.channelIDMap[serverChannelID] = clientChannelID
+		if len(msg) >= 17 {
+			h.maxPacket[serverChannelID] = binary.BigEndian.Uint32(msg[13:17])
+		}
+		if kind, ok := h.opening[clientChannelID]; ok {
+			delete(h.opening, clientChannelID)
+			h.channels[clientChannelID] = &channelState{
+				startTime: time.Now(),
+				serverID:  serverChannelID,
+				kind:      kind,
+				done:      make(chan struct{}),
+			}
+		}
+		h.mu.Unlock()
+	case msgChannelOpenFailure:
+		if len(msg) < 5 {
+			break
+		}
+		h.mu.Lock()
+		delete(h.opening, binary.BigEndian.Uint32(msg[1:5]))
+		h.mu.Unlock()
+	case msgChannelWindowAdjust:
+		if len(msg) < 9 {
+			break
+		}
+		clientChannelID := binary.BigEndian.Uint32(msg[1:5])
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if state, ok := h.channels[clientChannelID]; ok {
+			return ssh.PipePacketHookTransform, reduceWindowAdjust(msg, &state.inputDeficit), nil
+		}
+	case msgChannelClose:
+		if len(msg) < 5 {
+			break
+		}
+		clientChannelID := binary.BigEndian.Uint32(msg[1:5])
+		h.mu.Lock()
+		for serverChannelID, id := range h.channelIDMap {
+			if id == clientChannelID {
+				h.closeChannel(serverChannelID, clientChannelID)
+				break
+			}
+		}
 		h.mu.Unlock()
 	}
 	return ssh.PipePacketHookTransform, msg, nil
@@ -110,13 +217,197 @@ func (h *StreamHook) Up(msg []byte) (ssh.PipePacketHookMethod, []byte, error) {
 // upstream server. It tracks pty-req / env / window-change / shell+exec
 // requests and emits header and "r" resize frames as needed.
 func (h *StreamHook) Down(msg []byte) (ssh.PipePacketHookMethod, []byte, error) {
-	if len(msg) == 0 || msg[0] != msgChannelRequest {
-		return ssh.PipePacketHookTransform, msg, nil
-	}
 	if len(msg) < 5 {
 		return ssh.PipePacketHookTransform, msg, nil
 	}
 	serverChannelID := binary.BigEndian.Uint32(msg[1:5])
+
+	switch msg[0] {
+	case msgChannelOpen:
+		buf := bytes.NewReader(msg[1:])
+		kind := readSSHString(buf)
+		var clientChannelID uint32
+		if err := binary.Read(buf, binary.BigEndian, &clientChannelID); err != nil {
+			break
+		}
+		h.mu.Lock()
+		h.opening[clientChannelID] = kind
+		h.mu.Unlock()
+	case msgChannelData, msgChannelExtendedData:
+		n := channelDataLen(msg)
+		h.downstreamBytes.Add(n)
+		h.lastActivity.Store(time.Now().UnixNano())
+		h.mu.Lock()
+		if state := h.channelLocked(serverChannelID); state != nil {
+			state.downstreamBytes += n
+		}
+		resume := h.resume
+		h.mu.Unlock()
+		if resume != nil {
+			<-resume
+		}
+	case msgChannelRequest:
+		h.channelRequest(serverChannelID, msg)
+	case msgChannelWindowAdjust:
+		if len(msg) < 9 {
+			break
+		}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if state := h.channelLocked(serverChannelID); state != nil {
+			return ssh.PipePacketHookTransform, reduceWindowAdjust(msg, &state.noticeDeficit), nil
+		}
+	case msgChannelEOF, msgChannelClose:
+		// the client sends nothing after EOF, so neither may admins
+		h.mu.Lock()
+		if clientChannelID, ok := h.channelIDMap[serverChannelID]; ok {
+			if msg[0] == msgChannelClose {
+				h.closeChannel(serverChannelID, clientChannelID)
+			} else if state, ok := h.channels[clientChannelID]; ok {
+				state.end()
+			}
+		}
+		h.mu.Unlock()
+	}
+	return ssh.PipePacketHookTransform, msg, nil
+}
+
+// Pause holds the channel data sent by the client, and everything it sends
+// after, until Resume. The connection stays open meanwhile.
+func (h *StreamHook) Pause() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.resume == nil {
+		h.resume = make(chan struct{})
+	}
+}
+
+// Resume forwards the packets held by Pause.
+func (h *StreamHook) Resume() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.resume != nil {
+		close(h.resume)
+		h.resume = nil
+	}
+}
+
+// Paused reports whether the session is paused.
+func (h *StreamHook) Paused() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.resume != nil
+}
+
+// Stats returns the number of open channels, and the bytes of channel data
+// received from the client and from the upstream server.
+func (h *StreamHook) Stats() (channels int, downstreamBytes, upstreamBytes uint64) {
+	h.mu.Lock()
+	channels = len(h.channelIDMap)
+	h.mu.Unlock()
+	return channels, h.downstreamBytes.Load(), h.upstreamBytes.Load()
+}
+
+// ChannelInfo describes an open channel of the session.
+type ChannelInfo struct {
+	// ID is the client-side id, as in Frame.
+	ID   uint32
+	Type string
+	// Request is the shell, exec or subsystem request of a session
+	// channel, Command the exec command or subsystem name.
+	Request string
+	Command string
+	// Width and Height are the terminal size, 0 without a pty.
+	Width     uint32
+	Height    uint32
+	StartedAt time.Time
+	// DownstreamBytes and UpstreamBytes count the channel data like Stats.
+	DownstreamBytes uint64
+	UpstreamBytes   uint64
+}
+
+// Channels returns the open channels, by id.
+func (h *StreamHook) Channels() []ChannelInfo {
+	h.mu.Lock()
+	out := make([]ChannelInfo, 0, len(h.channels))
+	for id, state := range h.channels {
+		out = append(out, ChannelInfo{
+			ID:              id,
+			Type:            state.kind,
+			Request:         state.request,
+			Command:         state.command,
+			Width:           state.width,
+			Height:          state.height,
+			StartedAt:       state.startTime,
+			DownstreamBytes: state.downstreamBytes,
+			UpstreamBytes:   state.upstreamBytes,
+		})
+	}
+	h.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// LastActivity returns when either side last sent channel data, the zero
+// time if none did.
+func (h *StreamHook) LastActivity() time.Time {
+	n := h.lastActivity.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// closeChannel forgets the channel closed by either side, h.mu must be
+// held.
+func (h *StreamHook) closeChannel(serverChannelID, clientChannelID uint32) {
+	delete(h.channelIDMap, serverChannelID)
+	delete(h.maxPacket, serverChannelID)
+	if state, ok := h.channels[clientChannelID]; ok {
+		state.end()
+		delete(h.channels, clientChannelID)
+	}
+}
+
+func (c *channelState) end() {
+	if !c.ended {
+		c.ended = true
+		close(c.done)
+	}
+}
+
+// channelDataLen returns the length of the data of a channel data or
+// extended data msg.
+func channelDataLen(msg []byte) uint64 {
+	header := 9
+	if msg[0] == msgChannelExtendedData {
+		header = 13
+	}
+	if len(msg) < header {
+		return 0
+	}
+	return uint64(len(msg) - header)
+}
+
+// reduceWindowAdjust takes up to *deficit bytes out of the window-adjust
+// msg, dropping it when nothing is left.
+func reduceWindowAdjust(msg []byte, deficit *uint32) []byte {
+	if *deficit == 0 {
+		return msg
+	}
+	add := binary.BigEndian.Uint32(msg[5:9])
+	reduce := min(*deficit, add)
+	*deficit -= reduce
+	if add == reduce {
+		return nil
+	}
+	out := append([]byte(nil), msg...)
+	binary.BigEndian.PutUint32(out[5:9], add-reduce)
+	return out
+}
+
+// channelRequest handles a channel request of the client.
+func (h *StreamHook) channelRequest(serverChannelID uint32, msg []byte) {
 	buf := bytes.NewReader(msg[5:])
 	reqType := readSSHString(buf)
 
@@ -131,6 +422,9 @@ func (h *StreamHook) Down(msg []byte) (ssh.PipePacketHookMethod, []byte, error)
 		h.pendingTerm = term
 		h.pendingW = w
 		h.pendingH = hgt
+		if state := h.channelLocked(serverChannelID); state != nil {
+			state.width, state.height = w, hgt
+		}
 		h.mu.Unlock()
 	case "env":
 		_, _ = buf.ReadByte()
@@ -146,6 +440,9 @@ func (h *StreamHook) Down(msg []byte) (ssh.PipePacketHookMethod, []byte, error)
 		_ = binary.Read(buf, binary.BigEndian, &hgt)
 		h.mu.Lock()
 		clientChannelID, ok := h.channelIDMap[serverChannelID]
+		if state := h.channelLocked(serverChannelID); state != nil {
+			state.width, state.height = w, hgt
+		}
 		h.mu.Unlock()
 		if !ok {
 			break
@@ -157,7 +454,20 @@ func (h *StreamHook) Down(msg []byte) (ssh.PipePacketHookMethod, []byte, error)
 			Width:     w,
 			Height:    hgt,
 		})
+	case "subsystem":
+		_, _ = buf.ReadByte()
+		name := readSSHString(buf)
+		h.mu.Lock()
+		if state := h.channelLocked(serverChannelID); state != nil {
+			state.request, state.command = reqType, name
+		}
+		h.mu.Unlock()
 	case "shell", "exec":
+		var command string
+		if reqType == "exec" {
+			_, _ = buf.ReadByte()
+			command = readSSHString(buf)
+		}
 		h.mu.Lock()
 		clientChannelID, ok := h.channelIDMap[serverChannelID]
 		if !ok {
@@ -176,7 +486,13 @@ func (h *StreamHook) Down(msg []byte) (ssh.PipePacketHookMethod, []byte, error)
 		h.pendingEnv = make(map[string]string)
 		h.pendingTerm = ""
 		h.pendingW, h.pendingH = 0, 0
-		h.channels[clientChannelID] = &channelState{startTime: time.Now()}
+		state, ok := h.channels[clientChannelID]
+		if !ok {
+			state = &channelState{startTime: time.Now(), serverID: serverChannelID, kind: "session", done: make(chan struct{})}
+			h.channels[clientChannelID] = state
+		}
+		state.shell = true
+		state.request, state.command = reqType, command
 		h.mu.Unlock()
 
 		h.bc.Publish(Frame{
@@ -188,7 +504,16 @@ func (h *StreamHook) Down(msg []byte) (ssh.PipePacketHookMethod, []byte, error)
 			Env:       env,
 		})
 	}
-	return ssh.PipePacketHookTransform, msg, nil
+}
+
+// channelLocked returns the state of the channel with the server-side id,
+// nil if unknown. h.mu must be held.
+func (h *StreamHook) channelLocked(serverChannelID uint32) *channelState {
+	state, ok := h.channels[h.channelIDMap[serverChannelID]]
+	if !ok || state.serverID != serverChannelID {
+		return nil
+	}
+	return state
 }
 
 // readSSHString reads an SSH-style length-prefixed string from buf.
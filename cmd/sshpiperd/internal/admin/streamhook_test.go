@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreamHookStats(t *testing.T) {
+	h := NewStreamHook(NewBroadcaster())
+	openShell(t, h)
+	h.Down(packet(msgChannelOpen, "direct-tcpip", uint32(2), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenConfirm, uint32(2), uint32(12), uint32(1000), uint32(1000)))
+
+	h.Down(packet(msgChannelData, uint32(11), "ls\n"))
+	h.Up(packet(msgChannelData, uint32(1), "file\n"))
+	h.Up(packet(msgChannelExtendedData, uint32(1), uint32(1), "err\n"))
+	h.Down(packet(msgChannelData, uint32(12), "GET /"))
+
+	if channels, down, up := h.Stats(); channels != 2 || down != 8 || up != 9 {
+		t.Fatalf("Stats() = %d, %d, %d, want 2, 8, 9", channels, down, up)
+	}
+
+	// either side may close first
+	h.Up(packet(msgChannelClose, uint32(2)))
+	h.Down(packet(msgChannelClose, uint32(12)))
+	if channels, _, _ := h.Stats(); channels != 1 {
+		t.Fatalf("channels = %d after closing the forwarding, want 1", channels)
+	}
+	h.Down(packet(msgChannelClose, uint32(11)))
+	if channels, _, _ := h.Stats(); channels != 0 {
+		t.Fatalf("channels = %d after closing the shell, want 0", channels)
+	}
+}
+
+func TestStreamHookChannels(t *testing.T) {
+	h := NewStreamHook(NewBroadcaster())
+	if !h.LastActivity().IsZero() {
+		t.Fatal("activity before any channel data")
+	}
+
+	h.Down(packet(msgChannelOpen, "session", uint32(1), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenConfirm, uint32(1), uint32(11), uint32(1000), uint32(1000)))
+	h.Down(packet(msgChannelRequest, uint32(11), "pty-req", true, "xterm", uint32(80), uint32(24)))
+	h.Down(packet(msgChannelRequest, uint32(11), "exec", true, "uptime"))
+	h.Down(packet(msgChannelRequest, uint32(11), "window-change", false, uint32(120), uint32(40)))
+	h.Down(packet(msgChannelOpen, "session", uint32(2), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenConfirm, uint32(2), uint32(12), uint32(1000), uint32(1000)))
+	h.Down(packet(msgChannelRequest, uint32(12), "subsystem", true, "sftp"))
+	h.Down(packet(msgChannelOpen, "direct-tcpip", uint32(3), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenConfirm, uint32(3), uint32(13), uint32(1000), uint32(1000)))
+	h.Down(packet(msgChannelOpen, "session", uint32(4), uint32(1000), uint32(1000)))
+	h.Up(packet(msgChannelOpenFailure, uint32(4), uint32(1), "", ""))
+
+	h.Up(packet(msgChannelData, uint32(1), "up 3 days\n"))
+	h.Down(packet(msgChannelData, uint32(12), "ls"))
+	h.Up(packet(msgChannelExtendedData, uint32(3), uint32(1), "err"))
+
+	channels := h.Channels()
+	if len(channels) != 3 {
+		t.Fatalf("Channels() = %+v, want 3 channels", channels)
+	}
+	for i, want := range []ChannelInfo{
+		{ID: 1, Type: "session", Request: "exec", Command: "uptime", Width: 120, Height: 40, UpstreamBytes: 10},
+		{ID: 2, Type: "session", Request: "subsystem", Command: "sftp", DownstreamBytes: 2},
+		{ID: 3, Type: "direct-tcpip", UpstreamBytes: 3},
+	} {
+		got := channels[i]
+		if got.StartedAt.IsZero() {
+			t.Fatalf("channel %d has no start time", got.ID)
+		}
+		got.StartedAt = time.Time{}
+		if got != want {
+			t.Fatalf("channel %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if time.Since(h.LastActivity()) > time.Minute {
+		t.Fatalf("LastActivity() = %v", h.LastActivity())
+	}
+
+	h.Up(packet(msgChannelClose, uint32(2)))
+	if channels := h.Channels(); len(channels) != 2 || channels[1].ID != 3 {
+		t.Fatalf("Channels() after close = %+v", channels)
+	}
+}
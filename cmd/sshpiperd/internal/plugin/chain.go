@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"slices"
 
 	"github.com/google/uuid"
 	"github.com/tg123/sshpiper/libplugin"
 	"golang.org/x/crypto/ssh"
+	"google.golang.org/protobuf/proto"
 )
 
 type ChainPlugins struct {
@@ -21,13 +23,22 @@ func (cp *ChainPlugins) Append(p *GrpcPlugin) error {
 		return err
 	}
 
-	p.OnNextPlugin = cp.onNextPlugin
+	// set once, a plugin installed again may be in use by live connections
+	if p.OnNextPlugin == nil {
+		p.OnNextPlugin = nextPlugin
+	}
 	cp.pluginsCallback = append(cp.pluginsCallback, config)
 	cp.plugins = append(cp.plugins, p)
 
 	return nil
 }
 
+// nextPlugin moves the connection along the chain it was started on, a
+// plugin may be part of several chains.
+func nextPlugin(challengeCtx ssh.ChallengeContext, upstream *libplugin.UpstreamNextPluginAuth) error {
+	return challengeCtx.(*chainConnMeta).chain.onNextPlugin(challengeCtx, upstream)
+}
+
 func (cp *ChainPlugins) onNextPlugin(challengeCtx ssh.ChallengeContext, upstream *libplugin.UpstreamNextPluginAuth) error {
 	chain := challengeCtx.(*chainConnMeta)
 
@@ -49,8 +60,15 @@ func (cp *ChainPlugins) onNextPlugin(challengeCtx ssh.ChallengeContext, upstream
 	return nil
 }
 
+// skippedAuth is the result of an auth callback of a skipped plugin, the
+// client is asked to authenticate to the next plugin.
+func skippedAuth() (*ssh.Upstream, error) {
+	return nil, nil
+}
+
 type chainConnMeta struct {
 	PluginConnMeta
+	chain   *ChainPlugins
 	current int
 }
 
@@ -68,11 +86,13 @@ func (cp *ChainPlugins) CreateChallengeContext(conn ssh.ServerPreAuthConn) (ssh.
 				UniqId:   uiq.String(),
 				Metadata: make(map[string]string),
 			},
+			traceCtx: connTraceContext(conn),
 		},
+		chain: cp,
 	}
 
 	for _, p := range cp.plugins {
-		if err := p.NewConnection(&meta.PluginConnMeta); err != nil {
+		if err := newConnection(p, &meta.PluginConnMeta); err != nil {
 			return nil, err
 		}
 	}
@@ -80,12 +100,29 @@ func (cp *ChainPlugins) CreateChallengeContext(conn ssh.ServerPreAuthConn) (ssh.
 	return &meta, nil
 }
 
-func (cp *ChainPlugins) NextAuthMethods(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) ([]string, error) {
+// current returns the callbacks of the plugin the connection is at, moving
+// past plugins whose circuit breaker is open and that may be skipped. The
+// last plugin is never skipped.
+func (cp *ChainPlugins) current(challengeCtx ssh.ChallengeContext) *GrpcPluginConfig {
 	chain := challengeCtx.(*chainConnMeta)
-	config := cp.pluginsCallback[chain.current]
+
+	for chain.current+1 < len(cp.plugins) && cp.plugins[chain.current].skippable() {
+		slog.Warn("skipping plugin with open circuit breaker", "plugin", cp.plugins[chain.current].Name, "session", chain.UniqId)
+		chain.current++
+	}
+
+	return cp.pluginsCallback[chain.current]
+}
+
+func (cp *ChainPlugins) NextAuthMethods(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) ([]string, error) {
+	config := cp.current(challengeCtx)
 
 	if config.NextAuthMethods != nil {
-		return config.NextAuthMethods(conn, challengeCtx)
+		return withErrorPolicy(cp, challengeCtx, "NextAuthMethods", func(config *GrpcPluginConfig) ([]string, error) {
+			return config.NextAuthMethods(conn, challengeCtx)
+		}, func() ([]string, error) {
+			return cp.NextAuthMethods(conn, challengeCtx)
+		})
 	}
 
 	var methods []string
@@ -122,35 +159,43 @@ func (cp *ChainPlugins) InstallPiperConfig(config *GrpcPluginConfig) error {
 	config.NextAuthMethods = cp.NextAuthMethods
 
 	config.NoClientAuthCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
-		cur := cp.pluginsCallback[challengeCtx.(*chainConnMeta).current]
+		cur := cp.current(challengeCtx)
 		if cur.NoClientAuthCallback == nil {
 			return nil, fmt.Errorf("none auth callback is not implemented")
 		}
-		return cur.NoClientAuthCallback(conn, challengeCtx)
+		return withErrorPolicy(cp, challengeCtx, "NoneAuth", func(config *GrpcPluginConfig) (*ssh.Upstream, error) {
+			return config.NoClientAuthCallback(conn, challengeCtx)
+		}, skippedAuth)
 	}
 
 	config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
-		cur := cp.pluginsCallback[challengeCtx.(*chainConnMeta).current]
+		cur := cp.current(challengeCtx)
 		if cur.PasswordCallback == nil {
 			return nil, fmt.Errorf("password auth callback is not implemented")
 		}
-		return cur.PasswordCallback(conn, password, challengeCtx)
+		return withErrorPolicy(cp, challengeCtx, "PasswordAuth", func(config *GrpcPluginConfig) (*ssh.Upstream, error) {
+			return config.PasswordCallback(conn, password, challengeCtx)
+		}, skippedAuth)
 	}
 
 	config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
-		cur := cp.pluginsCallback[challengeCtx.(*chainConnMeta).current]
+		cur := cp.current(challengeCtx)
 		if cur.PublicKeyCallback == nil {
 			return nil, fmt.Errorf("publickey auth callback is not implemented")
 		}
-		return cur.PublicKeyCallback(conn, key, challengeCtx)
+		return withErrorPolicy(cp, challengeCtx, "PublicKeyAuth", func(config *GrpcPluginConfig) (*ssh.Upstream, error) {
+			return config.PublicKeyCallback(conn, key, challengeCtx)
+		}, skippedAuth)
 	}
 
 	config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
-		cur := cp.pluginsCallback[challengeCtx.(*chainConnMeta).current]
+		cur := cp.current(challengeCtx)
 		if cur.KeyboardInteractiveCallback == nil {
 			return nil, fmt.Errorf("keyboard-interactive auth callback is not implemented")
 		}
-		return cur.KeyboardInteractiveCallback(conn, client, challengeCtx)
+		return withErrorPolicy(cp, challengeCtx, "KeyboardInteractiveAuth", func(config *GrpcPluginConfig) (*ssh.Upstream, error) {
+			return config.KeyboardInteractiveCallback(conn, client, challengeCtx)
+		}, skippedAuth)
 	}
 
 	config.UpstreamAuthFailureCallback = func(conn ssh.ConnMetadata, method string, err error, challengeCtx ssh.ChallengeContext) {
@@ -162,7 +207,7 @@ func (cp *ChainPlugins) InstallPiperConfig(config *GrpcPluginConfig) error {
 	}
 
 	config.DownstreamBannerCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) string {
-		cur := cp.pluginsCallback[challengeCtx.(*chainConnMeta).current]
+		cur := cp.current(challengeCtx)
 		if cur.DownstreamBannerCallback != nil {
 			return cur.DownstreamBannerCallback(conn, challengeCtx)
 		}
@@ -186,6 +231,22 @@ func (cp *ChainPlugins) InstallPiperConfig(config *GrpcPluginConfig) error {
 		}
 	}
 
+	// only set when a plugin wants it, sshpiperd skips collecting the
+	// statistics otherwise
+	if slices.ContainsFunc(cp.pluginsCallback, func(p *GrpcPluginConfig) bool { return p.PipeEndCallback != nil }) {
+		config.PipeEndCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, stats *libplugin.PipeStats) {
+			for _, p := range cp.pluginsCallback {
+				if p.PipeEndCallback != nil {
+					p.PipeEndCallback(conn, challengeCtx, stats)
+				}
+			}
+		}
+	}
+
+	if slices.ContainsFunc(cp.pluginsCallback, func(p *GrpcPluginConfig) bool { return p.AuthorizeChannelCallback != nil }) {
+		config.AuthorizeChannelCallback = cp.authorizeChannel
+	}
+
 	config.PipeCreateErrorCallback = func(conn net.Conn, err error) {
 		for _, p := range cp.pluginsCallback {
 			if p.PipeCreateErrorCallback != nil {
@@ -196,3 +257,75 @@ func (cp *ChainPlugins) InstallPiperConfig(config *GrpcPluginConfig) error {
 
 	return nil
 }
+
+// authorizeChannel asks every plugin of the chain, in order, about req. The
+// first denial wins, a rewrite is what the following plugins are asked
+// about.
+func (cp *ChainPlugins) authorizeChannel(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error) {
+	rewritten := false
+
+	for _, p := range cp.pluginsCallback {
+		if p.AuthorizeChannelCallback == nil {
+			continue
+		}
+
+		resp, err := p.AuthorizeChannelCallback(conn, challengeCtx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.GetDecision() {
+		case libplugin.ChannelDecision_CHANNEL_DENY:
+			return resp, nil
+		case libplugin.ChannelDecision_CHANNEL_REWRITE:
+			if r := RewriteChannelRequest(req, resp); r != req {
+				req = r
+				rewritten = true
+			}
+		}
+	}
+
+	if !rewritten {
+		return &libplugin.AuthorizeChannelResponse{}, nil
+	}
+
+	resp := &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_REWRITE}
+	if req.GetRequest() == "subsystem" {
+		resp.Subsystem = req.GetSubsystem()
+	} else {
+		resp.Command = req.GetCommand()
+	}
+
+	return resp, nil
+}
+
+// RewriteChannelRequest returns req rewritten as told by resp, req itself
+// when there is nothing to rewrite. Only shell, exec and subsystem requests
+// are rewritten, into an exec or a subsystem.
+func RewriteChannelRequest(req *libplugin.AuthorizeChannelRequest, resp *libplugin.AuthorizeChannelResponse) *libplugin.AuthorizeChannelRequest {
+	if resp.GetDecision() != libplugin.ChannelDecision_CHANNEL_REWRITE {
+		return req
+	}
+
+	switch req.GetRequest() {
+	case "shell", "exec", "subsystem":
+	default:
+		return req
+	}
+
+	r := proto.Clone(req).(*libplugin.AuthorizeChannelRequest)
+	switch {
+	case resp.GetSubsystem() != "":
+		r.Request = "subsystem"
+		r.Subsystem = resp.GetSubsystem()
+		r.Command = ""
+	case resp.GetCommand() != "":
+		r.Request = "exec"
+		r.Command = resp.GetCommand()
+		r.Subsystem = ""
+	default:
+		return req
+	}
+
+	return r
+}
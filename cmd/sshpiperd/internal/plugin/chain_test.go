@@ -363,3 +363,100 @@ func TestChainPluginsNilCallbacksNotAdvertised(t *testing.T) {
 		t.Fatalf("expected no methods to be advertised, got %v", methods)
 	}
 }
+
+func TestChainPluginsPipeEndNotifiesEveryPlugin(t *testing.T) {
+	cp := &ChainPlugins{
+		pluginsCallback: []*GrpcPluginConfig{{}, {}},
+	}
+
+	config := &GrpcPluginConfig{}
+	if err := cp.InstallPiperConfig(config); err != nil {
+		t.Fatalf("InstallPiperConfig returned error: %v", err)
+	}
+	if config.PipeEndCallback != nil {
+		t.Fatal("expected no PipeEndCallback without a plugin wanting it")
+	}
+
+	var got []*libplugin.PipeStats
+	for _, p := range cp.pluginsCallback {
+		p.PipeEndCallback = func(_ ssh.ConnMetadata, _ ssh.ChallengeContext, stats *libplugin.PipeStats) {
+			got = append(got, stats)
+		}
+	}
+	cp.pluginsCallback = append(cp.pluginsCallback, &GrpcPluginConfig{})
+
+	if err := cp.InstallPiperConfig(config); err != nil {
+		t.Fatalf("InstallPiperConfig returned error: %v", err)
+	}
+
+	// the connection ended at the first plugin, the others still hear of it
+	stats := &libplugin.PipeStats{DownstreamBytes: 1}
+	config.PipeEndCallback(mockConnMetadata{}, &chainConnMeta{}, stats)
+
+	if len(got) != 2 || got[0] != stats || got[1] != stats {
+		t.Fatalf("expected both plugins to get the stats, got %v", got)
+	}
+}
+
+func TestChainPluginsAuthorizeChannel(t *testing.T) {
+	var seen []string
+	authorize := func(decision libplugin.ChannelDecision, command string) func(ssh.ConnMetadata, ssh.ChallengeContext, *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error) {
+		return func(_ ssh.ConnMetadata, _ ssh.ChallengeContext, req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error) {
+			seen = append(seen, req.GetRequest()+":"+req.GetCommand())
+			return &libplugin.AuthorizeChannelResponse{Decision: decision, Command: command}, nil
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		plugins []*GrpcPluginConfig
+		want    *libplugin.AuthorizeChannelResponse
+		seen    []string
+	}{
+		{
+			name:    "allow",
+			plugins: []*GrpcPluginConfig{{AuthorizeChannelCallback: authorize(libplugin.ChannelDecision_CHANNEL_ALLOW, "")}, {}},
+			want:    &libplugin.AuthorizeChannelResponse{},
+			seen:    []string{"shell:"},
+		},
+		{
+			name: "rewrite is passed on",
+			plugins: []*GrpcPluginConfig{
+				{AuthorizeChannelCallback: authorize(libplugin.ChannelDecision_CHANNEL_REWRITE, "top")},
+				{AuthorizeChannelCallback: authorize(libplugin.ChannelDecision_CHANNEL_ALLOW, "")},
+			},
+			want: &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_REWRITE, Command: "top"},
+			seen: []string{"shell:", "exec:top"},
+		},
+		{
+			name: "deny wins",
+			plugins: []*GrpcPluginConfig{
+				{AuthorizeChannelCallback: authorize(libplugin.ChannelDecision_CHANNEL_REWRITE, "top")},
+				{AuthorizeChannelCallback: authorize(libplugin.ChannelDecision_CHANNEL_DENY, "")},
+				{AuthorizeChannelCallback: authorize(libplugin.ChannelDecision_CHANNEL_ALLOW, "")},
+			},
+			want: &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_DENY},
+			seen: []string{"shell:", "exec:top"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			cp := &ChainPlugins{pluginsCallback: tt.plugins}
+			config := &GrpcPluginConfig{}
+			if err := cp.InstallPiperConfig(config); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := config.AuthorizeChannelCallback(mockConnMetadata{}, &chainConnMeta{}, &libplugin.AuthorizeChannelRequest{Request: "shell"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.GetDecision() != tt.want.GetDecision() || resp.GetCommand() != tt.want.GetCommand() {
+				t.Errorf("response = %v, want %v", resp, tt.want)
+			}
+			if !reflect.DeepEqual(seen, tt.seen) {
+				t.Errorf("plugins saw %v, want %v", seen, tt.seen)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorPolicy is what a plugin chain does when one of its plugins cannot be
+// reached. Errors the plugin answered with, such as a wrong password or a
+// banned address, always fail the call.
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyFailClosed fails the authentication attempt.
+	ErrorPolicyFailClosed ErrorPolicy = iota
+
+	// ErrorPolicySkip moves on to the next plugin, as if the plugin
+	// returned NextPluginAuth. The last plugin of a chain is never skipped.
+	ErrorPolicySkip
+
+	// ErrorPolicyRetryCurrent calls the plugin again, up to the retries of
+	// the plugin, then fails the authentication attempt.
+	ErrorPolicyRetryCurrent
+)
+
+var errorPolicyNames = map[ErrorPolicy]string{
+	ErrorPolicyFailClosed:   "fail-closed",
+	ErrorPolicySkip:         "skip",
+	ErrorPolicyRetryCurrent: "retry-current",
+}
+
+func (p ErrorPolicy) String() string {
+	if name, ok := errorPolicyNames[p]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ErrorPolicy(%d)", int(p))
+}
+
+// ParseErrorPolicy parses fail-closed, skip or retry-current.
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	for p, name := range errorPolicyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+
+	return ErrorPolicyFailClosed, fmt.Errorf("unknown plugin error policy %q, allowed: fail-closed, skip, retry-current", s)
+}
+
+// isUnavailable reports whether the call failed because the plugin could
+// not be reached in time, as opposed to the plugin denying the request or
+// sshpiperd failing to act on the answer.
+func isUnavailable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+
+	return false
+}
+
+// withErrorPolicy calls the current plugin of the connection and applies
+// the ErrorPolicy of the plugin when the call fails. skipped returns the
+// result once the chain moved on to the next plugin.
+func withErrorPolicy[T any](cp *ChainPlugins, challengeCtx ssh.ChallengeContext, method string, call func(config *GrpcPluginConfig) (T, error), skipped func() (T, error)) (T, error) {
+	chain := challengeCtx.(*chainConnMeta)
+	config := cp.current(challengeCtx)
+
+	v, err := call(config)
+	if err == nil || !isUnavailable(err) || chain.current >= len(cp.plugins) {
+		return v, err
+	}
+
+	p := cp.plugins[chain.current]
+	log := slog.With("plugin", p.Name, "method", method, "session", chain.UniqId, "policy", p.ErrorPolicy)
+
+	switch p.ErrorPolicy {
+	case ErrorPolicyRetryCurrent:
+		for attempt := 1; attempt <= p.ErrorRetries; attempt++ {
+			log.Warn("plugin call failed, retrying the plugin", "attempt", attempt, "error", err)
+
+			if v, err = call(config); err == nil || !isUnavailable(err) {
+				return v, err
+			}
+		}
+
+		log.Warn("plugin call failed, no retries left, failing the authentication", "error", err)
+		return v, err
+
+	case ErrorPolicySkip:
+		if chain.current+1 >= len(cp.plugins) {
+			log.Warn("plugin call failed, last plugin cannot be skipped, failing the authentication", "error", err)
+			return v, err
+		}
+
+		log.Warn("plugin call failed, skipping to the next plugin", "error", err)
+		if err := cp.onNextPlugin(challengeCtx, &libplugin.UpstreamNextPluginAuth{}); err != nil {
+			var zero T
+			return zero, err
+		}
+
+		return skipped()
+	}
+
+	log.Warn("plugin call failed, failing the authentication", "error", err)
+	return v, err
+}
+
+// newConnection notifies p of the connection, applying the ErrorPolicy of p
+// when the call fails.
+func newConnection(p *GrpcPlugin, meta *PluginConnMeta) error {
+	err := p.NewConnection(meta)
+	if err == nil || !isUnavailable(err) {
+		return err
+	}
+
+	log := slog.With("plugin", p.Name, "method", "NewConnection", "session", meta.UniqId, "policy", p.ErrorPolicy)
+
+	switch {
+	case errors.Is(err, ErrCircuitOpen) && p.Policy.BreakerSkip:
+		return nil
+
+	case p.ErrorPolicy == ErrorPolicyRetryCurrent:
+		for attempt := 1; attempt <= p.ErrorRetries; attempt++ {
+			log.Warn("plugin call failed, retrying the plugin", "attempt", attempt, "error", err)
+
+			if err = p.NewConnection(meta); err == nil || !isUnavailable(err) {
+				return err
+			}
+		}
+
+		log.Warn("plugin call failed, no retries left, refusing the connection", "error", err)
+		return err
+
+	case p.ErrorPolicy == ErrorPolicySkip:
+		log.Warn("plugin call failed, ignoring it", "error", err)
+		return nil
+	}
+
+	log.Warn("plugin call failed, refusing the connection", "error", err)
+	return err
+}
@@ -0,0 +1,228 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseErrorPolicy(t *testing.T) {
+	for _, p := range []ErrorPolicy{ErrorPolicyFailClosed, ErrorPolicySkip, ErrorPolicyRetryCurrent} {
+		got, err := ParseErrorPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParseErrorPolicy(%q) = %v, %v", p, got, err)
+		}
+	}
+
+	if _, err := ParseErrorPolicy("fail-open"); err == nil {
+		t.Error("expected error for unknown policy, got nil")
+	}
+}
+
+// newErrorPolicyChain returns a chain of two plugins, the first failing its
+// password callback with the errors in fails, one per call, then succeeding.
+func newErrorPolicyChain(policy ErrorPolicy, retries int, fails ...error) (*ChainPlugins, *int, *int) {
+	var firstCalls, secondCalls int
+	upstream := &ssh.Upstream{}
+
+	cp := &ChainPlugins{
+		pluginsCallback: []*GrpcPluginConfig{
+			{
+				PiperConfig: ssh.PiperConfig{
+					PasswordCallback: func(ssh.ConnMetadata, []byte, ssh.ChallengeContext) (*ssh.Upstream, error) {
+						firstCalls++
+						if firstCalls <= len(fails) {
+							return nil, fails[firstCalls-1]
+						}
+						return upstream, nil
+					},
+				},
+			},
+			{
+				PiperConfig: ssh.PiperConfig{
+					PasswordCallback: func(ssh.ConnMetadata, []byte, ssh.ChallengeContext) (*ssh.Upstream, error) {
+						secondCalls++
+						return upstream, nil
+					},
+				},
+			},
+		},
+		plugins: []*GrpcPlugin{
+			{Name: "first", ErrorPolicy: policy, ErrorRetries: retries},
+			{Name: "second"},
+		},
+	}
+
+	return cp, &firstCalls, &secondCalls
+}
+
+func TestChainPluginsErrorPolicy(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "plugin is down")
+	conn := mockConnMetadata{user: "user", remoteAddr: mockAddr("remote:22"), localAddr: mockAddr("local:22")}
+
+	password := func(cp *ChainPlugins, ctx *chainConnMeta) (*ssh.Upstream, error) {
+		config := &GrpcPluginConfig{}
+		if err := cp.InstallPiperConfig(config); err != nil {
+			t.Fatal(err)
+		}
+		return config.PasswordCallback(conn, []byte("pass"), ctx)
+	}
+
+	t.Run("fail-closed", func(t *testing.T) {
+		cp, first, second := newErrorPolicyChain(ErrorPolicyFailClosed, 0, unavailable)
+		ctx := &chainConnMeta{}
+
+		if _, err := password(cp, ctx); !errors.Is(err, unavailable) {
+			t.Errorf("expected plugin error, got %v", err)
+		}
+		if *first != 1 || *second != 0 || ctx.current != 0 {
+			t.Errorf("unexpected calls %d, %d at plugin %d", *first, *second, ctx.current)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		cp, _, _ := newErrorPolicyChain(ErrorPolicySkip, 0, unavailable)
+		ctx := &chainConnMeta{}
+
+		up, err := password(cp, ctx)
+		if err != nil || up != nil {
+			t.Fatalf("expected the chain to move on, got %v, %v", up, err)
+		}
+		if ctx.current != 1 {
+			t.Errorf("expected to be at the second plugin, got %d", ctx.current)
+		}
+	})
+
+	t.Run("skip ignores errors of sshpiperd", func(t *testing.T) {
+		cp, _, _ := newErrorPolicyChain(ErrorPolicySkip, 0, fmt.Errorf("client retry requested"))
+		ctx := &chainConnMeta{}
+
+		if _, err := password(cp, ctx); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if ctx.current != 0 {
+			t.Errorf("expected to stay at the first plugin, got %d", ctx.current)
+		}
+	})
+
+	// errors answered by libplugin plugins, e.g. failtoban, arrive as Unknown
+	denied := status.Error(codes.Unknown, "failtoban: ip 10.0.0.1 too auth many failures")
+
+	t.Run("skip does not skip denials", func(t *testing.T) {
+		cp, _, second := newErrorPolicyChain(ErrorPolicySkip, 0, denied)
+		ctx := &chainConnMeta{}
+
+		if _, err := password(cp, ctx); !errors.Is(err, denied) {
+			t.Fatalf("expected the denial, got %v", err)
+		}
+		if ctx.current != 0 || *second != 0 {
+			t.Errorf("expected to stay at the first plugin, got plugin %d and %d calls to the second", ctx.current, *second)
+		}
+	})
+
+	t.Run("retry-current does not retry denials", func(t *testing.T) {
+		cp, first, _ := newErrorPolicyChain(ErrorPolicyRetryCurrent, 2, status.Error(codes.PermissionDenied, "wrong password"))
+
+		if _, err := password(cp, &chainConnMeta{}); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected the denial, got %v", err)
+		}
+		if *first != 1 {
+			t.Errorf("expected 1 call, got %d", *first)
+		}
+	})
+
+	t.Run("skip never skips the last plugin", func(t *testing.T) {
+		cp, _, _ := newErrorPolicyChain(ErrorPolicySkip, 0, unavailable)
+		cp.pluginsCallback = cp.pluginsCallback[:1]
+		cp.plugins = cp.plugins[:1]
+
+		if _, err := password(cp, &chainConnMeta{}); !errors.Is(err, unavailable) {
+			t.Errorf("expected plugin error, got %v", err)
+		}
+	})
+
+	t.Run("retry-current", func(t *testing.T) {
+		cp, first, _ := newErrorPolicyChain(ErrorPolicyRetryCurrent, 2, unavailable, unavailable)
+
+		up, err := password(cp, &chainConnMeta{})
+		if err != nil || up == nil {
+			t.Fatalf("expected upstream after retries, got %v, %v", up, err)
+		}
+		if *first != 3 {
+			t.Errorf("expected 3 calls, got %d", *first)
+		}
+	})
+
+	t.Run("retry-current gives up", func(t *testing.T) {
+		cp, first, _ := newErrorPolicyChain(ErrorPolicyRetryCurrent, 1, unavailable, unavailable)
+
+		if _, err := password(cp, &chainConnMeta{}); !errors.Is(err, unavailable) {
+			t.Errorf("expected plugin error, got %v", err)
+		}
+		if *first != 2 {
+			t.Errorf("expected 2 calls, got %d", *first)
+		}
+	})
+}
+
+type newConnectionMockClient struct {
+	libplugin.SshPiperPluginClient
+	fails int
+	deny  bool
+	calls int
+}
+
+func (m *newConnectionMockClient) NewConnection(context.Context, *libplugin.NewConnectionRequest, ...grpc.CallOption) (*libplugin.NewConnectionResponse, error) {
+	m.calls++
+	if m.deny {
+		return nil, status.Error(codes.Unknown, "failtoban: ip 10.0.0.1 too auth many failures")
+	}
+	if m.calls <= m.fails {
+		return nil, status.Error(codes.Unavailable, "plugin is down")
+	}
+	return &libplugin.NewConnectionResponse{}, nil
+}
+
+func TestNewConnectionErrorPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy  ErrorPolicy
+		retries int
+		fails   int
+		deny    bool
+		wantErr bool
+		calls   int
+	}{
+		{policy: ErrorPolicyFailClosed, fails: 1, wantErr: true, calls: 1},
+		{policy: ErrorPolicySkip, fails: 1, calls: 1},
+		{policy: ErrorPolicyRetryCurrent, retries: 1, fails: 1, calls: 2},
+		{policy: ErrorPolicyRetryCurrent, retries: 1, fails: 2, wantErr: true, calls: 2},
+		{policy: ErrorPolicySkip, deny: true, wantErr: true, calls: 1},
+		{policy: ErrorPolicyRetryCurrent, retries: 2, deny: true, wantErr: true, calls: 1},
+	} {
+		t.Run(fmt.Sprintf("%v fails %d deny %v", tt.policy, tt.fails, tt.deny), func(t *testing.T) {
+			client := &newConnectionMockClient{fails: tt.fails, deny: tt.deny}
+			p := &GrpcPlugin{
+				Name:         "plugin",
+				ErrorPolicy:  tt.policy,
+				ErrorRetries: tt.retries,
+				client:       client,
+				callbacks:    testCallbacks(&pluginCallbacks{newConnection: true}),
+			}
+
+			err := newConnection(p, &PluginConnMeta{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if client.calls != tt.calls {
+				t.Errorf("calls = %d, want %d", client.calls, tt.calls)
+			}
+		})
+	}
+}
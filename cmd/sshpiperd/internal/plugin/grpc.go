@@ -6,10 +6,18 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net"
 	"net/url"
 	"os"
 	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/tg123/remotesigner"
@@ -17,10 +25,14 @@ import (
 	"github.com/tg123/sshpiper/libplugin"
 	"github.com/tg123/sshpiper/libplugin/connovergrpc"
 	"github.com/tg123/sshpiper/libplugin/ioconn"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type GrpcPluginConfig struct {
@@ -29,21 +41,43 @@ type GrpcPluginConfig struct {
 	PipeCreateErrorCallback func(conn net.Conn, err error)
 	PipeStartCallback       func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext)
 	PipeErrorCallback       func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, err error)
+	PipeEndCallback         func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, stats *libplugin.PipeStats)
+
+	AuthorizeChannelCallback func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error)
 }
 
 type GrpcPlugin struct {
 	Name         string
 	OnNextPlugin func(conn ssh.ChallengeContext, upstream *libplugin.UpstreamNextPluginAuth) error
 
+	// Policy bounds the calls to the plugin, set it before installing the
+	// plugin.
+	Policy RPCPolicy
+
+	// ErrorPolicy is applied by a plugin chain when a call to the plugin
+	// fails, ErrorRetries bounds ErrorPolicyRetryCurrent.
+	ErrorPolicy  ErrorPolicy
+	ErrorRetries int
+
+	// OnRPC, when set, is called after every RPC to the plugin, calls
+	// failed by the circuit breaker have no latency and ErrCircuitOpen.
+	OnRPC func(method string, latency time.Duration, err error)
+
+	// DaemonVersion is the sshpiperd version sent to the plugin in the
+	// Handshake.
+	DaemonVersion string
+
 	grpcconn           *grpc.ClientConn
 	client             libplugin.SshPiperPluginClient
 	connClient         connovergrpc.ConnOverGrpcClient
 	remotesignerClient grpcsigner.SignerClient
 
-	hasNewConnectionCallback bool
-	hasCreateConnCallback    bool
-	hasVerifyHostKeyCallback bool
-	allowedMethod            map[string]bool
+	callbacks     *callbackStore
+	allowedMethod map[string]bool
+
+	breaker *circuitBreaker
+	stats   *rpcStats
+	info    *pluginInfoStore
 }
 
 func DialGrpc(conn *grpc.ClientConn) (*GrpcPlugin, error) {
@@ -52,17 +86,98 @@ func DialGrpc(conn *grpc.ClientConn) (*GrpcPlugin, error) {
 		client:             libplugin.NewSshPiperPluginClient(conn),
 		connClient:         connovergrpc.NewConnOverGrpcClient(conn),
 		remotesignerClient: grpcsigner.NewSignerClient(conn),
+		breaker:            &circuitBreaker{},
+		stats:              &rpcStats{},
+		info:               &pluginInfoStore{},
+		callbacks:          &callbackStore{},
 	}
 
 	return p, nil
 }
 
-func (g *GrpcPlugin) InstallPiperConfig(config *GrpcPluginConfig) error {
-	cb, err := g.client.ListCallbacks(context.Background(), &libplugin.ListCallbackRequest{})
+// pluginCallbacks are the callbacks listed by the plugin. They are replaced
+// as a whole when the plugin is installed again after a restart, live
+// connections keep reading the previous ones.
+type pluginCallbacks struct {
+	names         []string
+	newConnection bool
+	createConn    bool
+	verifyHostKey bool
+}
+
+type callbackStore struct {
+	p atomic.Pointer[pluginCallbacks]
+}
+
+// load returns nil before the plugin was asked for its callbacks.
+func (s *callbackStore) load() *pluginCallbacks {
+	if s == nil {
+		return nil
+	}
+
+	return s.p.Load()
+}
+
+func (g *GrpcPlugin) listedCallbacks() *pluginCallbacks {
+	if cb := g.callbacks.load(); cb != nil {
+		return cb
+	}
+
+	return &pluginCallbacks{}
+}
+
+// Refresh does the Handshake and asks the plugin for its callbacks again,
+// e.g. after the plugin process was restarted. Configs installed later use
+// the new callbacks.
+func (g *GrpcPlugin) Refresh() error {
+	if err := g.handshake(); err != nil {
+		return err
+	}
+
+	ctx, done, err := g.call(context.Background(), "ListCallbacks")
+	if err != nil {
+		return err
+	}
+	resp, err := g.client.ListCallbacks(ctx, &libplugin.ListCallbackRequest{})
+	done(err)
 	if err != nil {
 		return err
 	}
 
+	cb := &pluginCallbacks{names: resp.GetCallbacks()}
+	for _, c := range cb.names {
+		switch c {
+		case "NewConnection":
+			cb.newConnection = true
+		case "CreateConn":
+			cb.createConn = true
+		case "VerifyHostKey":
+			cb.verifyHostKey = true
+		case "NextAuthMethods", "NoneAuth", "PasswordAuth", "PublicKeyAuth", "KeyboardInteractiveAuth",
+			"UpstreamAuthFailure", "Banner", "PipeStart", "PipeError", "PipeCreateError", "PipeEnd", "AuthorizeChannel":
+		default:
+			return fmt.Errorf("unknown callback %s", c)
+		}
+	}
+
+	if g.callbacks == nil {
+		g.callbacks = &callbackStore{}
+	}
+	g.callbacks.p.Store(cb)
+	return nil
+}
+
+// InstallPiperConfig installs the callbacks of the plugin into config. The
+// plugin is asked for its callbacks the first time only, see Refresh.
+func (g *GrpcPlugin) InstallPiperConfig(config *GrpcPluginConfig) error {
+	cb := g.callbacks.load()
+	if cb == nil {
+		if err := g.Refresh(); err != nil {
+			return err
+		}
+		cb = g.callbacks.load()
+	}
+
 	config.CreateChallengeContext = func(conn ssh.ServerPreAuthConn) (ssh.ChallengeContext, error) {
 		ctx, err := g.CreateChallengeContext(conn)
 		if err != nil {
@@ -71,12 +186,8 @@ func (g *GrpcPlugin) InstallPiperConfig(config *GrpcPluginConfig) error {
 		return ctx, err
 	}
 
-	for _, c := range cb.Callbacks {
+	for _, c := range cb.names {
 		switch c {
-		case "NewConnection":
-			g.hasNewConnectionCallback = true
-		case "CreateConn":
-			g.hasCreateConnCallback = true
 		case "NextAuthMethods":
 			config.NextAuthMethods = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) ([]string, error) {
 				methods, err := g.NextAuthMethodsRemote(conn, challengeCtx)
@@ -131,16 +242,16 @@ func (g *GrpcPlugin) InstallPiperConfig(config *GrpcPluginConfig) error {
 			}
 		case "Banner":
 			config.DownstreamBannerCallback = g.DownstreamBannerCallback
-		case "VerifyHostKey":
-			g.hasVerifyHostKeyCallback = true
 		case "PipeStart":
 			config.PipeStartCallback = g.PipeStartCallback
 		case "PipeError":
 			config.PipeErrorCallback = g.PipeErrorCallback
 		case "PipeCreateError":
 			config.PipeCreateErrorCallback = g.PipeCreateErrorCallback
-		default:
-			return fmt.Errorf("unknown callback %s", c)
+		case "PipeEnd":
+			config.PipeEndCallback = g.PipeEndCallback
+		case "AuthorizeChannel":
+			config.AuthorizeChannelCallback = g.AuthorizeChannelCallback
 		}
 	}
 
@@ -159,6 +270,18 @@ type PluginConnMeta struct {
 	// libplugin.Upstream.Env. Populated by createUpstream; consumed by
 	// the daemon when wiring the env-injection hook on the PiperConn.
 	Env map[string]string
+
+	// SessionPolicy is the libplugin.Upstream.SessionPolicy of the plugin
+	// that authenticated the connection, nil when it set none.
+	SessionPolicy *libplugin.SessionPolicy
+
+	// Labels are the labels of the session, the libplugin.LabelMetaPrefix
+	// entries of Metadata overridden by libplugin.Upstream.Labels.
+	Labels map[string]string
+
+	// traceCtx is the parent of the spans of the plugin calls made for the
+	// connection.
+	traceCtx context.Context
 }
 
 // ChallengedUsername implements ssh.ChallengeContext
@@ -184,14 +307,19 @@ func (g *GrpcPlugin) CreateChallengeContext(conn ssh.ServerPreAuthConn) (ssh.Cha
 			UniqId:   uiq.String(),
 			Metadata: make(map[string]string),
 		},
+		traceCtx: connTraceContext(conn),
 	}
 
 	return &meta, g.NewConnection(&meta)
 }
 
 func (g *GrpcPlugin) NewConnection(meta *PluginConnMeta) error {
-	if g.hasNewConnectionCallback {
-		_, err := g.client.NewConnection(context.Background(), &libplugin.NewConnectionRequest{
+	if g.listedCallbacks().newConnection {
+		ctx, done, err := g.call(meta.traceCtx, "NewConnection")
+		if err != nil {
+			return err
+		}
+		_, err = g.client.NewConnection(ctx, &libplugin.NewConnectionRequest{
 			Meta: &libplugin.ConnMeta{
 				UserName: meta.UserName,
 				FromAddr: meta.FromAddr,
@@ -199,6 +327,7 @@ func (g *GrpcPlugin) NewConnection(meta *PluginConnMeta) error {
 				Metadata: meta.Metadata,
 			},
 		})
+		done(err)
 
 		return err
 	}
@@ -221,9 +350,14 @@ func toMeta(challengeCtx ssh.ChallengeContext, conn ssh.ConnMetadata) *libplugin
 
 func (g *GrpcPlugin) NextAuthMethodsRemote(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) ([]string, error) {
 	meta := toMeta(challengeCtx, conn)
-	reply, err := g.client.NextAuthMethods(context.Background(), &libplugin.NextAuthMethodsRequest{
+	ctx, done, err := g.call(traceContext(challengeCtx), "NextAuthMethods")
+	if err != nil {
+		return nil, err
+	}
+	reply, err := g.client.NextAuthMethods(ctx, &libplugin.NextAuthMethodsRequest{
 		Meta: meta,
 	})
+	done(err)
 	if err != nil {
 		return nil, err
 	}
@@ -268,12 +402,17 @@ func (g *GrpcPlugin) UpstreamAuthFailureCallbackRemote(conn ssh.ConnMetadata, me
 		}
 	}
 
-	_, _ = g.client.UpstreamAuthFailureNotice(context.Background(), &libplugin.UpstreamAuthFailureNoticeRequest{
+	ctx, done, callErr := g.call(traceContext(challengeCtx), "UpstreamAuthFailureNotice")
+	if callErr != nil {
+		return
+	}
+	_, callErr = g.client.UpstreamAuthFailureNotice(ctx, &libplugin.UpstreamAuthFailureNoticeRequest{
 		Meta:           toMeta(challengeCtx, conn),
 		Method:         method,
 		Error:          err.Error(),
 		AllowedMethods: allowed,
 	})
+	done(callErr)
 }
 
 func (g *GrpcPlugin) createUpstream(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, upstream *libplugin.Upstream) (*ssh.Upstream, error) {
@@ -301,7 +440,7 @@ func (g *GrpcPlugin) createUpstream(conn ssh.ConnMetadata, challengeCtx ssh.Chal
 
 	config := ssh.ClientConfig{
 		User:            upstream.UserName,
-		HostKeyCallback: g.buildHostKeyCallback(meta, upstream),
+		HostKeyCallback: g.buildHostKeyCallback(traceContext(challengeCtx), meta, upstream),
 	}
 
 	config.SetDefaults()
@@ -363,7 +502,9 @@ func (g *GrpcPlugin) createUpstream(conn ssh.ConnMetadata, challengeCtx ssh.Chal
 		config.Auth = append(config.Auth, ssh.NoneAuth())
 	}
 
+	_, span := startSpan(traceContext(challengeCtx), "upstream.dial", attribute.String("upstream", upstreamUri))
 	upstreamConn, addr, err := g.dialUpstream(upstreamUri)
+	endSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -373,6 +514,8 @@ func (g *GrpcPlugin) createUpstream(conn ssh.ConnMetadata, challengeCtx ssh.Chal
 	// Always (re)set env so a retry / later auth attempt on the same
 	// ChallengeContext can't inherit a previous attempt's env.
 	setUpstreamEnv(challengeCtx, upstream.GetEnv())
+	setUpstreamSessionPolicy(challengeCtx, upstream.GetSessionPolicy())
+	setUpstreamLabels(challengeCtx, sessionLabels(meta.Metadata, upstream.GetLabels()))
 
 	return &ssh.Upstream{
 		Conn:         upstreamConn,
@@ -389,7 +532,7 @@ func (g *GrpcPlugin) dialUpstream(uri string) (net.Conn, string, error) {
 		return nil, "", fmt.Errorf("empty upstream uri")
 	}
 
-	if g.hasCreateConnCallback {
+	if g.listedCallbacks().createConn {
 		conn, err := connovergrpc.DialContext(context.Background(), g.connClient, uri)
 		if err != nil {
 			return nil, "", err
@@ -425,15 +568,20 @@ func (g *GrpcPlugin) dialUpstream(uri string) (net.Conn, string, error) {
 	return upstreamConn, addr, nil
 }
 
-func (g *GrpcPlugin) buildHostKeyCallback(meta *libplugin.ConnMeta, upstream *libplugin.Upstream) ssh.HostKeyCallback {
-	if g.hasVerifyHostKeyCallback {
+func (g *GrpcPlugin) buildHostKeyCallback(traceCtx context.Context, meta *libplugin.ConnMeta, upstream *libplugin.Upstream) ssh.HostKeyCallback {
+	if g.listedCallbacks().verifyHostKey {
 		return func(hostname string, addr net.Addr, key ssh.PublicKey) error {
-			verify, err := g.client.VerifyHostKey(context.Background(), &libplugin.VerifyHostKeyRequest{
+			ctx, done, err := g.call(traceCtx, "VerifyHostKey")
+			if err != nil {
+				return err
+			}
+			verify, err := g.client.VerifyHostKey(ctx, &libplugin.VerifyHostKeyRequest{
 				Meta:       meta,
 				Hostname:   hostname,
 				Netaddress: addr.String(),
 				Key:        key.Marshal(),
 			})
+			done(err)
 			if err != nil {
 				return err
 			}
@@ -464,9 +612,14 @@ func (g *GrpcPlugin) buildHostKeyCallback(meta *libplugin.ConnMeta, upstream *li
 
 func (g *GrpcPlugin) NoClientAuthCallback(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
 	meta := toMeta(challengeCtx, conn)
-	reply, err := g.client.NoneAuth(context.Background(), &libplugin.NoneAuthRequest{
+	ctx, done, err := g.call(traceContext(challengeCtx), "NoneAuth")
+	if err != nil {
+		return nil, err
+	}
+	reply, err := g.client.NoneAuth(ctx, &libplugin.NoneAuthRequest{
 		Meta: meta,
 	})
+	done(err)
 	if err != nil {
 		return nil, err
 	}
@@ -476,10 +629,15 @@ func (g *GrpcPlugin) NoClientAuthCallback(conn ssh.ConnMetadata, challengeCtx ss
 
 func (g *GrpcPlugin) PasswordCallback(conn ssh.ConnMetadata, password []byte, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
 	meta := toMeta(challengeCtx, conn)
-	reply, err := g.client.PasswordAuth(context.Background(), &libplugin.PasswordAuthRequest{
+	ctx, done, err := g.call(traceContext(challengeCtx), "PasswordAuth")
+	if err != nil {
+		return nil, err
+	}
+	reply, err := g.client.PasswordAuth(ctx, &libplugin.PasswordAuthRequest{
 		Meta:     meta,
 		Password: password,
 	})
+	done(err)
 	if err != nil {
 		return nil, err
 	}
@@ -489,10 +647,15 @@ func (g *GrpcPlugin) PasswordCallback(conn ssh.ConnMetadata, password []byte, ch
 
 func (g *GrpcPlugin) PublicKeyCallback(conn ssh.ConnMetadata, key ssh.PublicKey, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
 	meta := toMeta(challengeCtx, conn)
-	reply, err := g.client.PublicKeyAuth(context.Background(), &libplugin.PublicKeyAuthRequest{
+	ctx, done, err := g.call(traceContext(challengeCtx), "PublicKeyAuth")
+	if err != nil {
+		return nil, err
+	}
+	reply, err := g.client.PublicKeyAuth(ctx, &libplugin.PublicKeyAuthRequest{
 		Meta:      meta,
 		PublicKey: key.Marshal(),
 	})
+	done(err)
 	if err != nil {
 		return nil, err
 	}
@@ -500,8 +663,16 @@ func (g *GrpcPlugin) PublicKeyCallback(conn ssh.ConnMetadata, key ssh.PublicKey,
 	return g.createUpstream(conn, challengeCtx, reply.Upstream)
 }
 
-func (g *GrpcPlugin) KeyboardInteractiveCallback(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
-	stream, err := g.client.KeyboardInteractiveAuth(context.Background())
+func (g *GrpcPlugin) KeyboardInteractiveCallback(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge, challengeCtx ssh.ChallengeContext) (u *ssh.Upstream, err error) {
+	ctx, done, err := g.call(traceContext(challengeCtx), "KeyboardInteractiveAuth")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		done(err)
+	}()
+
+	stream, err := g.client.KeyboardInteractiveAuth(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -568,9 +739,15 @@ func (g *GrpcPlugin) KeyboardInteractiveCallback(conn ssh.ConnMetadata, client s
 
 func (g *GrpcPlugin) DownstreamBannerCallback(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) string {
 	meta := toMeta(challengeCtx, conn)
-	reply, err := g.client.Banner(context.Background(), &libplugin.BannerRequest{
+	ctx, done, err := g.call(traceContext(challengeCtx), "Banner")
+	if err != nil {
+		slog.Debug("failed to get banner", "error", err)
+		return ""
+	}
+	reply, err := g.client.Banner(ctx, &libplugin.BannerRequest{
 		Meta: meta,
 	})
+	done(err)
 	if err != nil {
 		slog.Debug("failed to get banner", "error", err)
 		return ""
@@ -580,25 +757,77 @@ func (g *GrpcPlugin) DownstreamBannerCallback(conn ssh.ConnMetadata, challengeCt
 }
 
 func (g *GrpcPlugin) PipeCreateErrorCallback(conn net.Conn, err error) {
-	_, _ = g.client.PipeCreateErrorNotice(context.Background(), &libplugin.PipeCreateErrorNoticeRequest{
+	ctx, done, callErr := g.call(context.Background(), "PipeCreateErrorNotice")
+	if callErr != nil {
+		return
+	}
+	_, callErr = g.client.PipeCreateErrorNotice(ctx, &libplugin.PipeCreateErrorNoticeRequest{
 		FromAddr: conn.RemoteAddr().String(),
 		Error:    err.Error(),
 	})
+	done(callErr)
 }
 
 func (g *GrpcPlugin) PipeStartCallback(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) {
 	meta := toMeta(challengeCtx, conn)
-	_, _ = g.client.PipeStartNotice(context.Background(), &libplugin.PipeStartNoticeRequest{
+	ctx, done, err := g.call(traceContext(challengeCtx), "PipeStartNotice")
+	if err != nil {
+		return
+	}
+	_, err = g.client.PipeStartNotice(ctx, &libplugin.PipeStartNoticeRequest{
 		Meta: meta,
 	})
+	done(err)
 }
 
 func (g *GrpcPlugin) PipeErrorCallback(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, pipeerr error) {
 	meta := toMeta(challengeCtx, conn)
-	_, _ = g.client.PipeErrorNotice(context.Background(), &libplugin.PipeErrorNoticeRequest{
+	ctx, done, err := g.call(traceContext(challengeCtx), "PipeErrorNotice")
+	if err != nil {
+		return
+	}
+	_, err = g.client.PipeErrorNotice(ctx, &libplugin.PipeErrorNoticeRequest{
 		Meta:  meta,
 		Error: pipeerr.Error(),
 	})
+	done(err)
+}
+
+func (g *GrpcPlugin) PipeEndCallback(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, stats *libplugin.PipeStats) {
+	meta := toMeta(challengeCtx, conn)
+	ctx, done, err := g.call(traceContext(challengeCtx), "PipeEndNotice")
+	if err != nil {
+		return
+	}
+	_, err = g.client.PipeEndNotice(ctx, &libplugin.PipeEndNoticeRequest{
+		Meta:  meta,
+		Stats: stats,
+	})
+	done(err)
+}
+
+func (g *GrpcPlugin) AuthorizeChannelCallback(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error) {
+	req = proto.Clone(req).(*libplugin.AuthorizeChannelRequest)
+	req.Meta = toMeta(challengeCtx, conn)
+
+	ctx, done, err := g.call(traceContext(challengeCtx), "AuthorizeChannel")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.client.AuthorizeChannel(ctx, req)
+	done(err)
+	return resp, err
+}
+
+// Ping checks that the plugin is connected and answering. Plugins built
+// before the Ping RPC answer Unimplemented, which counts as answering.
+func (g *GrpcPlugin) Ping(ctx context.Context) error {
+	_, err := g.client.Ping(ctx, &libplugin.PingRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+
+	return err
 }
 
 func (g *GrpcPlugin) RecvLogs(writer io.Writer, level string) error {
@@ -700,3 +929,112 @@ func setUpstreamEnv(ctx ssh.ChallengeContext, env map[string]string) {
 		meta.Env = cp
 	}
 }
+
+// UpstreamSessionPolicy returns the session policy the plugin that
+// authenticated the connection set in its Upstream, nil when none.
+func UpstreamSessionPolicy(ctx ssh.ChallengeContext) *libplugin.SessionPolicy {
+	switch meta := ctx.(type) {
+	case *PluginConnMeta:
+		return meta.SessionPolicy
+	case *chainConnMeta:
+		return meta.SessionPolicy
+	}
+	return nil
+}
+
+func setUpstreamSessionPolicy(ctx ssh.ChallengeContext, policy *libplugin.SessionPolicy) {
+	switch meta := ctx.(type) {
+	case *PluginConnMeta:
+		meta.SessionPolicy = policy
+	case *chainConnMeta:
+		meta.SessionPolicy = policy
+	}
+}
+
+// UpstreamLabels returns the labels of the session, nil when it has none.
+func UpstreamLabels(ctx ssh.ChallengeContext) map[string]string {
+	switch meta := ctx.(type) {
+	case *PluginConnMeta:
+		return meta.Labels
+	case *chainConnMeta:
+		return meta.Labels
+	}
+	return nil
+}
+
+func setUpstreamLabels(ctx ssh.ChallengeContext, labels map[string]string) {
+	switch meta := ctx.(type) {
+	case *PluginConnMeta:
+		meta.Labels = labels
+	case *chainConnMeta:
+		meta.Labels = labels
+	}
+}
+
+const (
+	// maxSessionLabels is the number of labels kept per session, the first
+	// ones by key.
+	maxSessionLabels = 32
+	// maxLabelValueLen is the length in bytes label values are cut to.
+	maxLabelValueLen = 256
+)
+
+// labelKeyPattern are the label keys kept, they end up in recording headers
+// and the output of sshpiperd-admin.
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]{1,63}$`)
+
+// sessionLabels returns the labels passed along the chain in metadata,
+// overridden by the labels of the upstream, nil when there are none.
+// Labels with an invalid key are dropped, control characters are stripped
+// from the values, and both the number of labels and the length of the
+// values are capped.
+func sessionLabels(metadata, labels map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for k, v := range metadata {
+		if name, ok := strings.CutPrefix(k, libplugin.LabelMetaPrefix); ok && name != "" {
+			merged[name] = v
+		}
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+
+	var out map[string]string
+	for _, k := range slices.Sorted(maps.Keys(merged)) {
+		if !labelKeyPattern.MatchString(k) {
+			slog.Warn("session label dropped, invalid key", "key", k)
+			continue
+		}
+		if len(out) == maxSessionLabels {
+			slog.Warn("session labels dropped, too many", "max", maxSessionLabels, "dropped_from", k)
+			break
+		}
+
+		if out == nil {
+			out = make(map[string]string)
+		}
+		out[k] = labelValue(merged[k])
+	}
+
+	return out
+}
+
+// labelValue returns v without control characters, cut to
+// maxLabelValueLen bytes on a rune boundary.
+func labelValue(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, v)
+	if len(v) <= maxLabelValueLen {
+		return v
+	}
+
+	v = v[:maxLabelValueLen]
+	for !utf8.ValidString(v) {
+		v = v[:len(v)-1]
+	}
+	return v
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"errors"
+	"fmt"
 	"net"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -29,6 +31,13 @@ func (m *hostKeyMockClient) VerifyHostKey(_ context.Context, in *libplugin.Verif
 	return m.verifyFn(in)
 }
 
+// testCallbacks returns callbacks as if the plugin listed them.
+func testCallbacks(cb *pluginCallbacks) *callbackStore {
+	s := &callbackStore{}
+	s.p.Store(cb)
+	return s
+}
+
 func newTestHostKey(t *testing.T) ssh.PublicKey {
 	t.Helper()
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -44,7 +53,7 @@ func newTestHostKey(t *testing.T) ssh.PublicKey {
 
 func TestBuildHostKeyCallbackEmptyDataIsInsecureSkip(t *testing.T) {
 	g := &GrpcPlugin{}
-	cb := g.buildHostKeyCallback(&libplugin.ConnMeta{}, &libplugin.Upstream{})
+	cb := g.buildHostKeyCallback(context.Background(), &libplugin.ConnMeta{}, &libplugin.Upstream{})
 	if cb == nil {
 		t.Fatal("expected non-nil callback")
 	}
@@ -59,7 +68,7 @@ func TestBuildHostKeyCallbackKnownHostsDataMatches(t *testing.T) {
 	line := knownhosts.Line([]string{host}, pub)
 
 	g := &GrpcPlugin{}
-	cb := g.buildHostKeyCallback(&libplugin.ConnMeta{}, &libplugin.Upstream{KnownHostsData: []byte(line)})
+	cb := g.buildHostKeyCallback(context.Background(), &libplugin.ConnMeta{}, &libplugin.Upstream{KnownHostsData: []byte(line)})
 
 	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:2222")
 	if err != nil {
@@ -77,7 +86,7 @@ func TestBuildHostKeyCallbackKnownHostsDataMismatch(t *testing.T) {
 	line := knownhosts.Line([]string{host}, expected)
 
 	g := &GrpcPlugin{}
-	cb := g.buildHostKeyCallback(&libplugin.ConnMeta{}, &libplugin.Upstream{KnownHostsData: []byte(line)})
+	cb := g.buildHostKeyCallback(context.Background(), &libplugin.ConnMeta{}, &libplugin.Upstream{KnownHostsData: []byte(line)})
 
 	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:2222")
 	if err != nil {
@@ -90,7 +99,7 @@ func TestBuildHostKeyCallbackKnownHostsDataMismatch(t *testing.T) {
 
 func TestBuildHostKeyCallbackMalformedKnownHostsData(t *testing.T) {
 	g := &GrpcPlugin{}
-	cb := g.buildHostKeyCallback(&libplugin.ConnMeta{}, &libplugin.Upstream{KnownHostsData: []byte("not-a-known-hosts-line\n")})
+	cb := g.buildHostKeyCallback(context.Background(), &libplugin.ConnMeta{}, &libplugin.Upstream{KnownHostsData: []byte("not-a-known-hosts-line\n")})
 	err := cb("host:22", mockAddr("1.2.3.4:22"), newTestHostKey(t))
 	if err == nil || !strings.Contains(err.Error(), "failed to parse known_hosts data") {
 		t.Fatalf("expected parse-error callback, got %v", err)
@@ -103,8 +112,8 @@ func TestBuildHostKeyCallbackRPCVerified(t *testing.T) {
 			return &libplugin.VerifyHostKeyResponse{Verified: true}, nil
 		},
 	}
-	g := &GrpcPlugin{client: mock, hasVerifyHostKeyCallback: true}
-	cb := g.buildHostKeyCallback(&libplugin.ConnMeta{UserName: "alice"}, &libplugin.Upstream{})
+	g := &GrpcPlugin{client: mock, callbacks: testCallbacks(&pluginCallbacks{verifyHostKey: true})}
+	cb := g.buildHostKeyCallback(context.Background(), &libplugin.ConnMeta{UserName: "alice"}, &libplugin.Upstream{})
 
 	pub := newTestHostKey(t)
 	if err := cb("host", mockAddr("1.2.3.4:22"), pub); err != nil {
@@ -124,8 +133,8 @@ func TestBuildHostKeyCallbackRPCRejected(t *testing.T) {
 			return &libplugin.VerifyHostKeyResponse{Verified: false}, nil
 		},
 	}
-	g := &GrpcPlugin{client: mock, hasVerifyHostKeyCallback: true}
-	cb := g.buildHostKeyCallback(&libplugin.ConnMeta{}, &libplugin.Upstream{})
+	g := &GrpcPlugin{client: mock, callbacks: testCallbacks(&pluginCallbacks{verifyHostKey: true})}
+	cb := g.buildHostKeyCallback(context.Background(), &libplugin.ConnMeta{}, &libplugin.Upstream{})
 
 	if err := cb("host", mockAddr("1.2.3.4:22"), newTestHostKey(t)); err == nil {
 		t.Fatal("expected unverified RPC response to fail")
@@ -139,8 +148,8 @@ func TestBuildHostKeyCallbackRPCError(t *testing.T) {
 			return nil, rpcErr
 		},
 	}
-	g := &GrpcPlugin{client: mock, hasVerifyHostKeyCallback: true}
-	cb := g.buildHostKeyCallback(&libplugin.ConnMeta{}, &libplugin.Upstream{})
+	g := &GrpcPlugin{client: mock, callbacks: testCallbacks(&pluginCallbacks{verifyHostKey: true})}
+	cb := g.buildHostKeyCallback(context.Background(), &libplugin.ConnMeta{}, &libplugin.Upstream{})
 
 	if err := cb("host", mockAddr("1.2.3.4:22"), newTestHostKey(t)); !errors.Is(err, rpcErr) {
 		t.Fatalf("expected rpc error to propagate, got %v", err)
@@ -156,8 +165,8 @@ func TestBuildHostKeyCallbackRPCTakesPrecedenceOverData(t *testing.T) {
 			return &libplugin.VerifyHostKeyResponse{Verified: true}, nil
 		},
 	}
-	g := &GrpcPlugin{client: mock, hasVerifyHostKeyCallback: true}
-	cb := g.buildHostKeyCallback(&libplugin.ConnMeta{}, &libplugin.Upstream{KnownHostsData: []byte("not-a-known-hosts-line\n")})
+	g := &GrpcPlugin{client: mock, callbacks: testCallbacks(&pluginCallbacks{verifyHostKey: true})}
+	cb := g.buildHostKeyCallback(context.Background(), &libplugin.ConnMeta{}, &libplugin.Upstream{KnownHostsData: []byte("not-a-known-hosts-line\n")})
 
 	if err := cb("host", mockAddr("1.2.3.4:22"), newTestHostKey(t)); err != nil {
 		t.Fatalf("expected RPC path to be used, got %v", err)
@@ -166,3 +175,114 @@ func TestBuildHostKeyCallbackRPCTakesPrecedenceOverData(t *testing.T) {
 		t.Fatal("expected RPC to be called even when KnownHostsData is also set")
 	}
 }
+
+// callbacksMockClient lists callbacks, counting the calls.
+type callbacksMockClient struct {
+	libplugin.SshPiperPluginClient
+	callbacks []string
+	listed    int
+}
+
+func (m *callbacksMockClient) Handshake(context.Context, *libplugin.HandshakeRequest, ...grpc.CallOption) (*libplugin.HandshakeResponse, error) {
+	return &libplugin.HandshakeResponse{ProtocolVersion: libplugin.ProtocolVersion}, nil
+}
+
+func (m *callbacksMockClient) ListCallbacks(context.Context, *libplugin.ListCallbackRequest, ...grpc.CallOption) (*libplugin.ListCallbackResponse, error) {
+	m.listed++
+	return &libplugin.ListCallbackResponse{Callbacks: m.callbacks}, nil
+}
+
+func TestGrpcPluginRefreshCallbacks(t *testing.T) {
+	mock := &callbacksMockClient{callbacks: []string{"PasswordAuth", "NewConnection"}}
+	g := &GrpcPlugin{client: mock, info: &pluginInfoStore{}, callbacks: &callbackStore{}}
+
+	first := &GrpcPluginConfig{}
+	if err := g.InstallPiperConfig(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.InstallPiperConfig(&GrpcPluginConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.listed != 1 {
+		t.Errorf("ListCallbacks called %d times, want once before a refresh", mock.listed)
+	}
+
+	old := g.listedCallbacks()
+	if first.PasswordCallback == nil || !old.newConnection {
+		t.Fatalf("expected password and new connection callbacks, got %+v", old)
+	}
+
+	mock.callbacks = []string{"PublicKeyAuth"}
+	if err := g.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := &GrpcPluginConfig{}
+	if err := g.InstallPiperConfig(second); err != nil {
+		t.Fatal(err)
+	}
+	if second.PasswordCallback != nil || second.PublicKeyCallback == nil || g.listedCallbacks().newConnection {
+		t.Errorf("expected the refreshed callbacks, got %+v", g.listedCallbacks())
+	}
+	if !old.newConnection {
+		t.Error("refresh must not change callbacks in use by live connections")
+	}
+
+	mock.callbacks = []string{"Teleport"}
+	if err := g.Refresh(); err == nil {
+		t.Error("expected error for unknown callback")
+	}
+	if g.listedCallbacks().names[0] != "PublicKeyAuth" {
+		t.Error("a failed refresh must keep the previous callbacks")
+	}
+}
+
+func TestSessionLabels(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		metadata map[string]string
+		labels   map[string]string
+		want     map[string]string
+	}{
+		{name: "none", metadata: map[string]string{"team": "db"}},
+		{
+			name:     "from metadata",
+			metadata: map[string]string{"label.team": "db", "label.": "empty", "ticket": "INC-1"},
+			want:     map[string]string{"team": "db"},
+		},
+		{
+			name:     "upstream wins",
+			metadata: map[string]string{"label.team": "db", "label.env": "prod"},
+			labels:   map[string]string{"team": "web", "ticket": "INC-1"},
+			want:     map[string]string{"team": "web", "env": "prod", "ticket": "INC-1"},
+		},
+		{
+			name:   "invalid keys dropped",
+			labels: map[string]string{"team db": "x", "env\x1b[2J": "x", strings.Repeat("k", 64): "x", "app.kubernetes.io/name": "web"},
+			want:   map[string]string{"app.kubernetes.io/name": "web"},
+		},
+		{
+			name:   "values stripped and cut",
+			labels: map[string]string{"ticket": "INC-1\x1b[2J\r\n", "note": strings.Repeat("é", 200)},
+			want:   map[string]string{"ticket": "INC-1[2J", "note": strings.Repeat("é", 128)},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionLabels(tt.metadata, tt.labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sessionLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	many := make(map[string]string)
+	for i := 0; i < maxSessionLabels+10; i++ {
+		many[fmt.Sprintf("l%02d", i)] = "x"
+	}
+	got := sessionLabels(nil, many)
+	if len(got) != maxSessionLabels {
+		t.Fatalf("got %d labels, want %d", len(got), maxSessionLabels)
+	}
+	if _, ok := got[fmt.Sprintf("l%02d", maxSessionLabels-1)]; !ok {
+		t.Errorf("expected the first labels by key to be kept, got %v", got)
+	}
+}
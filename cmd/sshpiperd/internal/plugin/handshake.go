@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// minProtocolVersion is the oldest plugin protocol sshpiperd works with.
+const minProtocolVersion = 1
+
+// daemonFeatures are the optional features of the plugin protocol
+// sshpiperd implements, every feature of the libplugin it is built with.
+var daemonFeatures = libplugin.Features
+
+// PluginInfo is what a plugin reported about itself in the Handshake.
+type PluginInfo struct {
+	ProtocolVersion uint32
+	Name            string
+	Version         string
+	Features        []string
+
+	// Legacy is set for plugins built before the Handshake RPC, they
+	// report nothing else.
+	Legacy bool
+}
+
+// HasFeature reports whether the plugin announced feature.
+func (i PluginInfo) HasFeature(feature string) bool {
+	return slices.Contains(i.Features, feature)
+}
+
+// handshake exchanges the protocol version and features with the plugin
+// and refuses plugins sshpiperd cannot work with.
+func (g *GrpcPlugin) handshake() error {
+	ctx, done, err := g.call(context.Background(), "Handshake")
+	if err != nil {
+		return err
+	}
+	resp, err := g.client.Handshake(ctx, &libplugin.HandshakeRequest{
+		ProtocolVersion: libplugin.ProtocolVersion,
+		Version:         g.DaemonVersion,
+		Features:        daemonFeatures,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		done(nil)
+		slog.Info("plugin does not support the handshake, assuming protocol version 1", "plugin", g.Name)
+		g.info.store(&PluginInfo{ProtocolVersion: 1, Legacy: true})
+		return nil
+	}
+	done(err)
+	if status.Code(err) == codes.FailedPrecondition {
+		return fmt.Errorf("plugin %v refused sshpiperd: %v", g.Name, status.Convert(err).Message())
+	}
+	if err != nil {
+		return err
+	}
+
+	info := &PluginInfo{
+		ProtocolVersion: resp.GetProtocolVersion(),
+		Name:            resp.GetName(),
+		Version:         resp.GetVersion(),
+		Features:        resp.GetFeatures(),
+	}
+
+	if err := checkPluginInfo(info, resp.GetRequiredFeatures()); err != nil {
+		return fmt.Errorf("plugin %v (%v %v) is incompatible: %w", g.Name, info.Name, info.Version, err)
+	}
+
+	slog.Debug("plugin handshake done", "plugin", g.Name, "name", info.Name, "version", info.Version, "protocol_version", info.ProtocolVersion, "features", info.Features)
+	g.info.store(info)
+	return nil
+}
+
+func checkPluginInfo(info *PluginInfo, required []string) error {
+	if info.ProtocolVersion < minProtocolVersion || info.ProtocolVersion > libplugin.ProtocolVersion {
+		return fmt.Errorf("plugin protocol version %v is not supported, sshpiperd supports %v to %v, upgrade the older of the two", info.ProtocolVersion, minProtocolVersion, libplugin.ProtocolVersion)
+	}
+
+	var missing []string
+	for _, f := range required {
+		if !slices.Contains(daemonFeatures, f) {
+			missing = append(missing, f)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("plugin requires features %v not supported by this sshpiperd, upgrade sshpiperd", missing)
+	}
+
+	return nil
+}
+
+// pluginInfoStore holds the PluginInfo of the last Handshake, a supervised
+// plugin does it again after each restart.
+type pluginInfoStore struct {
+	mu   sync.Mutex
+	info PluginInfo
+}
+
+func (s *pluginInfoStore) store(info *PluginInfo) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info = *info
+}
+
+func (s *pluginInfoStore) load() PluginInfo {
+	if s == nil {
+		return PluginInfo{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info
+}
+
+// Info returns what the plugin reported in the Handshake, the zero value
+// before the plugin is installed.
+func (g *GrpcPlugin) Info() PluginInfo {
+	return g.info.load()
+}
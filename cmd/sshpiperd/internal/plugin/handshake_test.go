@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type handshakeMockClient struct {
+	libplugin.SshPiperPluginClient
+	resp *libplugin.HandshakeResponse
+	err  error
+	req  *libplugin.HandshakeRequest
+}
+
+func (m *handshakeMockClient) Handshake(_ context.Context, req *libplugin.HandshakeRequest, _ ...grpc.CallOption) (*libplugin.HandshakeResponse, error) {
+	m.req = req
+	return m.resp, m.err
+}
+
+func TestGrpcPluginHandshake(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		resp    *libplugin.HandshakeResponse
+		err     error
+		wantErr string
+		want    PluginInfo
+	}{
+		{
+			name: "compatible",
+			resp: &libplugin.HandshakeResponse{
+				ProtocolVersion:  libplugin.ProtocolVersion,
+				Name:             "fixed",
+				Version:          "v1.2.3",
+				Features:         []string{libplugin.FeaturePing},
+				RequiredFeatures: []string{libplugin.FeatureUpstreamEnv},
+			},
+			want: PluginInfo{ProtocolVersion: libplugin.ProtocolVersion, Name: "fixed", Version: "v1.2.3", Features: []string{libplugin.FeaturePing}},
+		},
+		{
+			name: "legacy plugin",
+			err:  status.Error(codes.Unimplemented, "unknown method Handshake"),
+			want: PluginInfo{ProtocolVersion: 1, Legacy: true},
+		},
+		{
+			name:    "newer protocol",
+			resp:    &libplugin.HandshakeResponse{ProtocolVersion: libplugin.ProtocolVersion + 1, Name: "fixed"},
+			wantErr: "plugin protocol version 2 is not supported",
+		},
+		{
+			name:    "missing protocol version",
+			resp:    &libplugin.HandshakeResponse{Name: "fixed"},
+			wantErr: "plugin protocol version 0 is not supported",
+		},
+		{
+			name:    "unsupported required feature",
+			resp:    &libplugin.HandshakeResponse{ProtocolVersion: libplugin.ProtocolVersion, RequiredFeatures: []string{"teleport"}},
+			wantErr: "plugin requires features [teleport]",
+		},
+		{
+			name:    "refused by plugin",
+			err:     status.Error(codes.FailedPrecondition, "sshpiperd v9 is too old"),
+			wantErr: "plugin plugin refused sshpiperd: sshpiperd v9 is too old",
+		},
+		{
+			name:    "plugin down",
+			err:     status.Error(codes.Unavailable, "connection refused"),
+			wantErr: "connection refused",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &handshakeMockClient{resp: tt.resp, err: tt.err}
+			g := &GrpcPlugin{Name: "plugin", client: client, DaemonVersion: "v9", info: &pluginInfoStore{}}
+
+			err := g.handshake()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("handshake error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if client.req.GetVersion() != "v9" || client.req.GetProtocolVersion() != libplugin.ProtocolVersion || !slices.Equal(client.req.GetFeatures(), libplugin.Features) {
+				t.Errorf("unexpected handshake request %v", client.req)
+			}
+
+			got := g.Info()
+			if got.ProtocolVersion != tt.want.ProtocolVersion || got.Name != tt.want.Name || got.Version != tt.want.Version || got.Legacy != tt.want.Legacy || len(got.Features) != len(tt.want.Features) {
+				t.Errorf("Info() = %+v, want %+v", got, tt.want)
+			}
+			if len(tt.want.Features) > 0 && !got.HasFeature(tt.want.Features[0]) {
+				t.Errorf("expected feature %v in %v", tt.want.Features[0], got.Features)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package plugin
+
+import (
+	"net"
+	"slices"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+)
+
+// RoutedPlugins runs every connection through one of several plugin
+// chains, the routes. The route is picked when the connection starts, see
+// CreateChallengeContext, and all later callbacks go to its chain.
+type RoutedPlugins struct {
+	routes []routedChain
+
+	// pipeCreateErrors are called for connections that failed before a
+	// route was picked, once per plugin.
+	pipeCreateErrors []func(conn net.Conn, err error)
+	notified         map[*GrpcPlugin]bool
+}
+
+type routedChain struct {
+	chain  *ChainPlugins
+	config *GrpcPluginConfig
+}
+
+// Append adds a route made of plugins, routes are numbered from 0 in the
+// order they are appended. A plugin may be part of several routes.
+func (r *RoutedPlugins) Append(plugins ...*GrpcPlugin) error {
+	cp := &ChainPlugins{}
+
+	for _, p := range plugins {
+		if err := cp.Append(p); err != nil {
+			return err
+		}
+	}
+
+	config := &GrpcPluginConfig{}
+	if err := cp.InstallPiperConfig(config); err != nil {
+		return err
+	}
+
+	if r.notified == nil {
+		r.notified = make(map[*GrpcPlugin]bool)
+	}
+
+	for i, p := range cp.plugins {
+		if r.notified[p] {
+			continue
+		}
+
+		r.notified[p] = true
+		if cb := cp.pluginsCallback[i].PipeCreateErrorCallback; cb != nil {
+			r.pipeCreateErrors = append(r.pipeCreateErrors, cb)
+		}
+	}
+
+	r.routes = append(r.routes, routedChain{chain: cp, config: config})
+	return nil
+}
+
+// CreateChallengeContext starts the connection on the chain of route.
+func (r *RoutedPlugins) CreateChallengeContext(conn ssh.ServerPreAuthConn, route int) (ssh.ChallengeContext, error) {
+	return r.routes[route].config.CreateChallengeContext(conn)
+}
+
+func (r *RoutedPlugins) config(challengeCtx ssh.ChallengeContext) *GrpcPluginConfig {
+	chain := challengeCtx.(*chainConnMeta).chain
+
+	for _, route := range r.routes {
+		if route.chain == chain {
+			return route.config
+		}
+	}
+
+	panic("connection was not started on a route")
+}
+
+// InstallPiperConfig installs callbacks that follow the route of the
+// connection. Its CreateChallengeContext starts connections on route 0.
+func (r *RoutedPlugins) InstallPiperConfig(config *GrpcPluginConfig) error {
+	config.CreateChallengeContext = func(conn ssh.ServerPreAuthConn) (ssh.ChallengeContext, error) {
+		return r.CreateChallengeContext(conn, 0)
+	}
+
+	config.NextAuthMethods = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) ([]string, error) {
+		return r.config(challengeCtx).NextAuthMethods(conn, challengeCtx)
+	}
+
+	config.NoClientAuthCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+		return r.config(challengeCtx).NoClientAuthCallback(conn, challengeCtx)
+	}
+
+	config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+		return r.config(challengeCtx).PasswordCallback(conn, password, challengeCtx)
+	}
+
+	config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+		return r.config(challengeCtx).PublicKeyCallback(conn, key, challengeCtx)
+	}
+
+	config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+		return r.config(challengeCtx).KeyboardInteractiveCallback(conn, client, challengeCtx)
+	}
+
+	config.UpstreamAuthFailureCallback = func(conn ssh.ConnMetadata, method string, err error, challengeCtx ssh.ChallengeContext) {
+		r.config(challengeCtx).UpstreamAuthFailureCallback(conn, method, err, challengeCtx)
+	}
+
+	config.DownstreamBannerCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) string {
+		return r.config(challengeCtx).DownstreamBannerCallback(conn, challengeCtx)
+	}
+
+	config.PipeStartCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) {
+		r.config(challengeCtx).PipeStartCallback(conn, challengeCtx)
+	}
+
+	config.PipeErrorCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, err error) {
+		r.config(challengeCtx).PipeErrorCallback(conn, challengeCtx, err)
+	}
+
+	if slices.ContainsFunc(r.routes, func(route routedChain) bool { return route.config.PipeEndCallback != nil }) {
+		config.PipeEndCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, stats *libplugin.PipeStats) {
+			if cb := r.config(challengeCtx).PipeEndCallback; cb != nil {
+				cb(conn, challengeCtx, stats)
+			}
+		}
+	}
+
+	if slices.ContainsFunc(r.routes, func(route routedChain) bool { return route.config.AuthorizeChannelCallback != nil }) {
+		config.AuthorizeChannelCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext, req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error) {
+			cb := r.config(challengeCtx).AuthorizeChannelCallback
+			if cb == nil {
+				return &libplugin.AuthorizeChannelResponse{}, nil
+			}
+			return cb(conn, challengeCtx, req)
+		}
+	}
+
+	config.PipeCreateErrorCallback = func(conn net.Conn, err error) {
+		for _, cb := range r.pipeCreateErrors {
+			cb(conn, err)
+		}
+	}
+
+	return nil
+}
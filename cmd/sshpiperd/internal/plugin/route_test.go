@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// routeMockClient is a plugin that answers password auth with upstream, or
+// err when set, and counts pipe create error notices.
+type routeMockClient struct {
+	libplugin.SshPiperPluginClient
+	upstream         *libplugin.Upstream
+	err              error
+	passwordCalls    int
+	pipeCreateErrors int
+}
+
+func (m *routeMockClient) Handshake(context.Context, *libplugin.HandshakeRequest, ...grpc.CallOption) (*libplugin.HandshakeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown method Handshake")
+}
+
+func (m *routeMockClient) ListCallbacks(context.Context, *libplugin.ListCallbackRequest, ...grpc.CallOption) (*libplugin.ListCallbackResponse, error) {
+	return &libplugin.ListCallbackResponse{Callbacks: []string{"PasswordAuth", "PipeCreateError"}}, nil
+}
+
+func (m *routeMockClient) PasswordAuth(context.Context, *libplugin.PasswordAuthRequest, ...grpc.CallOption) (*libplugin.PasswordAuthResponse, error) {
+	m.passwordCalls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &libplugin.PasswordAuthResponse{Upstream: m.upstream}, nil
+}
+
+func (m *routeMockClient) PipeCreateErrorNotice(context.Context, *libplugin.PipeCreateErrorNoticeRequest, ...grpc.CallOption) (*libplugin.PipeCreateErrorNoticeResponse, error) {
+	m.pipeCreateErrors++
+	return &libplugin.PipeCreateErrorNoticeResponse{}, nil
+}
+
+// mockPreAuthConn satisfies ssh.ServerPreAuthConn through the nil embedded
+// interface, only the ConnMetadata methods may be called.
+type mockPreAuthConn struct {
+	ssh.ServerPreAuthConn
+	meta mockConnMetadata
+}
+
+func (m mockPreAuthConn) User() string          { return m.meta.User() }
+func (m mockPreAuthConn) SessionID() []byte     { return m.meta.SessionID() }
+func (m mockPreAuthConn) ClientVersion() []byte { return m.meta.ClientVersion() }
+func (m mockPreAuthConn) ServerVersion() []byte { return m.meta.ServerVersion() }
+func (m mockPreAuthConn) RemoteAddr() net.Addr  { return m.meta.RemoteAddr() }
+func (m mockPreAuthConn) LocalAddr() net.Addr   { return m.meta.LocalAddr() }
+
+func TestRoutedPlugins(t *testing.T) {
+	next := &routeMockClient{upstream: &libplugin.Upstream{Auth: &libplugin.Upstream_NextPlugin{NextPlugin: &libplugin.UpstreamNextPluginAuth{}}}}
+	last := &routeMockClient{err: errors.New("last plugin")}
+
+	shared := &GrpcPlugin{Name: "shared", client: next}
+	tail := &GrpcPlugin{Name: "tail", client: last}
+
+	r := &RoutedPlugins{}
+	if err := r.Append(shared, tail); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Append(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &GrpcPluginConfig{}
+	if err := r.InstallPiperConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := mockConnMetadata{user: "user", remoteAddr: mockAddr("remote:22"), localAddr: mockAddr("local:22")}
+	conn := mockPreAuthConn{meta: meta}
+
+	t.Run("default route moves along its chain", func(t *testing.T) {
+		ctx, err := config.CreateChallengeContext(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := config.PasswordCallback(meta, []byte("pass"), ctx); err != nil {
+			t.Fatalf("expected next plugin, got %v", err)
+		}
+		if _, err := config.PasswordCallback(meta, []byte("pass"), ctx); err == nil || err.Error() != "last plugin" {
+			t.Fatalf("expected the error of the second plugin, got %v", err)
+		}
+	})
+
+	t.Run("shared plugin follows the chain of the route", func(t *testing.T) {
+		ctx, err := r.CreateChallengeContext(conn, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		calls := last.passwordCalls
+		if _, err := config.PasswordCallback(meta, []byte("pass"), ctx); err == nil || err.Error() != "no more plugins" {
+			t.Fatalf("expected end of the single plugin route, got %v", err)
+		}
+		if last.passwordCalls != calls {
+			t.Errorf("plugin of another route was called")
+		}
+	})
+
+	t.Run("pipe create errors notify every plugin once", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		config.PipeCreateErrorCallback(server, errors.New("handshake failed"))
+
+		if next.pipeCreateErrors != 1 || last.pipeCreateErrors != 1 {
+			t.Errorf("unexpected notices %d, %d", next.pipeCreateErrors, last.pipeCreateErrors)
+		}
+	})
+}
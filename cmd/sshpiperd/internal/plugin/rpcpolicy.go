@@ -0,0 +1,324 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned without calling the plugin while its circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("plugin circuit breaker is open")
+
+// policyRPCs are the plugin RPCs bounded by RPCPolicy, by their name in
+// libplugin.
+var policyRPCs = []string{
+	"Handshake",
+	"ListCallbacks",
+	"NewConnection",
+	"NextAuthMethods",
+	"NoneAuth",
+	"PasswordAuth",
+	"PublicKeyAuth",
+	"KeyboardInteractiveAuth",
+	"UpstreamAuthFailureNotice",
+	"Banner",
+	"VerifyHostKey",
+	"PipeStartNotice",
+	"PipeErrorNotice",
+	"PipeEndNotice",
+	"AuthorizeChannel",
+	"PipeCreateErrorNotice",
+}
+
+// PolicyRPCs returns the names of the plugin RPCs bounded by RPCPolicy.
+func PolicyRPCs() []string {
+	return slices.Clone(policyRPCs)
+}
+
+// RPCPolicy bounds the grpc calls made to a plugin.
+type RPCPolicy struct {
+	// Timeout is the deadline of every call, 0 for none.
+	Timeout time.Duration
+
+	// Timeouts overrides Timeout per RPC, keyed by the RPC name in
+	// libplugin, e.g. PasswordAuth. KeyboardInteractiveAuth waits for the
+	// user to answer, so it only has a deadline when set here.
+	Timeouts map[string]time.Duration
+
+	// BreakerFailures is the number of consecutive timed out or unavailable
+	// calls that opens the circuit breaker, 0 disables it.
+	BreakerFailures int
+
+	// BreakerCooldown is how long an open breaker fails calls before one is
+	// let through to check whether the plugin recovered.
+	BreakerCooldown time.Duration
+
+	// BreakerSkip makes a plugin chain move on to the next plugin while the
+	// breaker is open instead of failing the authentication.
+	BreakerSkip bool
+}
+
+// Validate checks the RPC names in Timeouts.
+func (p RPCPolicy) Validate() error {
+	for name, d := range p.Timeouts {
+		if !slices.Contains(policyRPCs, name) {
+			return fmt.Errorf("unknown plugin rpc %q, allowed: %v", name, policyRPCs)
+		}
+		if d < 0 {
+			return fmt.Errorf("negative timeout for plugin rpc %v", name)
+		}
+	}
+
+	return nil
+}
+
+func (p RPCPolicy) timeout(method string) time.Duration {
+	if d, ok := p.Timeouts[method]; ok {
+		return d
+	}
+
+	if method == "KeyboardInteractiveAuth" {
+		return 0
+	}
+
+	return p.Timeout
+}
+
+// RPCStats are the statistics of one plugin RPC.
+type RPCStats struct {
+	Calls    int64
+	Errors   int64
+	Timeouts int64
+	// Rejected counts the calls failed by the open circuit breaker, they
+	// are not included in Calls.
+	Rejected     int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+type rpcStats struct {
+	mu    sync.Mutex
+	stats map[string]*RPCStats
+}
+
+func (s *rpcStats) get(method string) *RPCStats {
+	if s.stats == nil {
+		s.stats = make(map[string]*RPCStats)
+	}
+
+	st, ok := s.stats[method]
+	if !ok {
+		st = &RPCStats{}
+		s.stats[method] = st
+	}
+
+	return st
+}
+
+func (s *rpcStats) record(method string, latency time.Duration, err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.get(method)
+	st.Calls++
+	st.TotalLatency += latency
+	st.MaxLatency = max(st.MaxLatency, latency)
+
+	if err != nil {
+		st.Errors++
+		if status.Code(err) == codes.DeadlineExceeded {
+			st.Timeouts++
+		}
+	}
+}
+
+func (s *rpcStats) reject(method string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.get(method).Rejected++
+}
+
+func (s *rpcStats) snapshot() map[string]RPCStats {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[string]RPCStats, len(s.stats))
+	for method, st := range s.stats {
+		snapshot[method] = *st
+	}
+
+	return snapshot
+}
+
+// breakerProbeTimeout bounds the Ping probing a plugin whose breaker is half
+// open, when the call that would be the probe has no deadline.
+const breakerProbeTimeout = 5 * time.Second
+
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// tripsBreaker reports whether err means the plugin is hung or gone, as
+// opposed to the plugin answering with an error.
+func tripsBreaker(err error) bool {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.Unavailable:
+		return true
+	}
+
+	return false
+}
+
+// allow reports whether a call may go to the plugin, probe is set for the
+// single call checking the plugin once the cooldown of the open breaker
+// passed.
+func (b *circuitBreaker) allow(p RPCPolicy) (ok bool, probe bool) {
+	if b == nil || p.BreakerFailures <= 0 {
+		return true, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < p.BreakerFailures {
+		return true, false
+	}
+
+	// half open, a single call checks the plugin once the cooldown passed
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false, false
+	}
+
+	b.probing = true
+	return true, true
+}
+
+func (b *circuitBreaker) record(p RPCPolicy, name string, err error) {
+	if b == nil || p.BreakerFailures <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if !tripsBreaker(err) {
+		if b.failures >= p.BreakerFailures {
+			slog.Info("plugin circuit breaker closed", "plugin", name)
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= p.BreakerFailures {
+		b.openUntil = time.Now().Add(p.BreakerCooldown)
+		slog.Warn("plugin circuit breaker open", "plugin", name, "failures", b.failures, "until", b.openUntil, "error", err)
+	}
+}
+
+func (b *circuitBreaker) open(p RPCPolicy) bool {
+	if b == nil || p.BreakerFailures <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures >= p.BreakerFailures && (b.probing || time.Now().Before(b.openUntil))
+}
+
+// call starts the RPC method to the plugin under g.Policy, traced as a
+// child of parent. done must be called with the result of the RPC.
+func (g *GrpcPlugin) call(parent context.Context, method string) (ctx context.Context, done func(err error), err error) {
+	ctx, span := startSpan(parent, "plugin."+method, attribute.String("plugin", g.Name))
+
+	ok, probe := g.breaker.allow(g.Policy)
+	if ok && probe && g.Policy.timeout(method) <= 0 {
+		// a call without deadline, e.g. KeyboardInteractiveAuth waiting for
+		// the user, would keep the breaker half open for as long as it
+		// runs, probe with a bounded Ping instead
+		ok = g.probe()
+	}
+
+	if !ok {
+		g.stats.reject(method)
+		err := fmt.Errorf("%w: %v", ErrCircuitOpen, g.Name)
+		if g.OnRPC != nil {
+			g.OnRPC(method, 0, err)
+		}
+		endSpan(span, err)
+		return nil, nil, err
+	}
+
+	ctx, cancel := withTraceMetadata(ctx), context.CancelFunc(func() {})
+	if d := g.Policy.timeout(method); d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
+	}
+
+	start := time.Now()
+	return ctx, func(err error) {
+		cancel()
+		latency := time.Since(start)
+		g.stats.record(method, latency, err)
+		g.breaker.record(g.Policy, g.Name, err)
+		if g.OnRPC != nil {
+			g.OnRPC(method, latency, err)
+		}
+		endSpan(span, err)
+	}, nil
+}
+
+// probe checks the plugin with a Ping bounded by breakerProbeTimeout and
+// records the result in the breaker.
+func (g *GrpcPlugin) probe() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), breakerProbeTimeout)
+	defer cancel()
+
+	err := g.Ping(ctx)
+	g.breaker.record(g.Policy, g.Name, err)
+	return !tripsBreaker(err)
+}
+
+// BreakerOpen reports whether calls to the plugin are currently failed by
+// its circuit breaker.
+func (g *GrpcPlugin) BreakerOpen() bool {
+	return g.breaker.open(g.Policy)
+}
+
+// skippable reports whether a plugin chain should move past the plugin.
+func (g *GrpcPlugin) skippable() bool {
+	return g.Policy.BreakerSkip && g.BreakerOpen()
+}
+
+// Stats returns the statistics of the RPCs made to the plugin, keyed by
+// RPC name.
+func (g *GrpcPlugin) Stats() map[string]RPCStats {
+	return g.stats.snapshot()
+}
@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRPCPolicyTimeout(t *testing.T) {
+	p := RPCPolicy{
+		Timeout:  time.Second,
+		Timeouts: map[string]time.Duration{"PasswordAuth": 3 * time.Second},
+	}
+
+	if got := p.timeout("NewConnection"); got != time.Second {
+		t.Errorf("NewConnection timeout = %v, want 1s", got)
+	}
+	if got := p.timeout("PasswordAuth"); got != 3*time.Second {
+		t.Errorf("PasswordAuth timeout = %v, want 3s", got)
+	}
+	if got := p.timeout("KeyboardInteractiveAuth"); got != 0 {
+		t.Errorf("KeyboardInteractiveAuth timeout = %v, want none", got)
+	}
+
+	p.Timeouts["KeyboardInteractiveAuth"] = time.Minute
+	if got := p.timeout("KeyboardInteractiveAuth"); got != time.Minute {
+		t.Errorf("KeyboardInteractiveAuth timeout = %v, want 1m", got)
+	}
+}
+
+func TestRPCPolicyValidate(t *testing.T) {
+	if err := (RPCPolicy{Timeouts: map[string]time.Duration{"PublicKeyAuth": time.Second}}).Validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := (RPCPolicy{Timeouts: map[string]time.Duration{"NoSuchAuth": time.Second}}).Validate(); err == nil {
+		t.Error("expected error for unknown rpc, got nil")
+	}
+	if err := (RPCPolicy{Timeouts: map[string]time.Duration{"Banner": -time.Second}}).Validate(); err == nil {
+		t.Error("expected error for negative timeout, got nil")
+	}
+}
+
+func TestGrpcPluginCircuitBreaker(t *testing.T) {
+	g := &GrpcPlugin{
+		Name: "test",
+		Policy: RPCPolicy{
+			Timeout:         time.Second,
+			BreakerFailures: 2,
+			BreakerCooldown: 50 * time.Millisecond,
+			BreakerSkip:     true,
+		},
+		breaker: &circuitBreaker{},
+		stats:   &rpcStats{},
+	}
+
+	timeout := status.Error(codes.DeadlineExceeded, "deadline exceeded")
+
+	for i := 0; i < 2; i++ {
+		_, done, err := g.call(context.Background(), "PasswordAuth")
+		if err != nil {
+			t.Fatalf("call %d: unexpected error %v", i, err)
+		}
+		done(timeout)
+	}
+
+	if !g.BreakerOpen() || !g.skippable() {
+		t.Fatal("expected breaker to be open after consecutive timeouts")
+	}
+
+	if _, _, err := g.call(context.Background(), "PasswordAuth"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// half open, only a single probe is let through
+	_, done, err := g.call(context.Background(), "PasswordAuth")
+	if err != nil {
+		t.Fatalf("expected probe call after cooldown, got %v", err)
+	}
+	if _, _, err := g.call(context.Background(), "PasswordAuth"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected second call during probe to be rejected, got %v", err)
+	}
+
+	done(status.Error(codes.PermissionDenied, "denied"))
+	if g.BreakerOpen() {
+		t.Error("expected breaker to close after the plugin answered")
+	}
+
+	st := g.Stats()["PasswordAuth"]
+	if st.Calls != 3 || st.Errors != 3 || st.Timeouts != 2 || st.Rejected != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestGrpcPluginCircuitBreakerIgnoresPluginErrors(t *testing.T) {
+	g := &GrpcPlugin{
+		Policy:  RPCPolicy{BreakerFailures: 1, BreakerCooldown: time.Minute},
+		breaker: &circuitBreaker{},
+		stats:   &rpcStats{},
+	}
+
+	_, done, err := g.call(context.Background(), "NewConnection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(status.Error(codes.Unknown, "no such user"))
+
+	if g.BreakerOpen() {
+		t.Error("an error answered by the plugin must not open the breaker")
+	}
+}
+
+type pingMockClient struct {
+	libplugin.SshPiperPluginClient
+	err   error
+	pings int
+}
+
+func (m *pingMockClient) Ping(context.Context, *libplugin.PingRequest, ...grpc.CallOption) (*libplugin.PingResponse, error) {
+	m.pings++
+	return &libplugin.PingResponse{}, m.err
+}
+
+func TestGrpcPluginCircuitBreakerProbesCallsWithoutDeadline(t *testing.T) {
+	client := &pingMockClient{err: status.Error(codes.Unavailable, "plugin is down")}
+	g := &GrpcPlugin{
+		Name:    "test",
+		Policy:  RPCPolicy{Timeout: time.Second, BreakerFailures: 1, BreakerCooldown: 10 * time.Millisecond},
+		client:  client,
+		breaker: &circuitBreaker{},
+		stats:   &rpcStats{},
+	}
+
+	_, done, err := g.call(context.Background(), "PasswordAuth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(status.Error(codes.Unavailable, "plugin is down"))
+
+	time.Sleep(20 * time.Millisecond)
+
+	// the user may take minutes to answer, the breaker is probed with a
+	// Ping instead of the call
+	if _, _, err := g.call(context.Background(), "KeyboardInteractiveAuth"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen with the plugin still down, got %v", err)
+	}
+	if client.pings != 1 || !g.BreakerOpen() {
+		t.Fatalf("expected a failed ping to open the breaker again, pings %d", client.pings)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	client.err = nil
+
+	_, kiDone, err := g.call(context.Background(), "KeyboardInteractiveAuth")
+	if err != nil {
+		t.Fatalf("expected the call once the ping answered, got %v", err)
+	}
+	if client.pings != 2 || g.BreakerOpen() {
+		t.Fatalf("expected the ping to close the breaker, pings %d", client.pings)
+	}
+
+	// the pending call does not hold the breaker half open
+	_, done, err = g.call(context.Background(), "PasswordAuth")
+	if err != nil {
+		t.Errorf("expected calls while the user is typing, got %v", err)
+	} else {
+		done(nil)
+	}
+	kiDone(nil)
+}
@@ -0,0 +1,275 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"github.com/tg123/sshpiper/libplugin/ioconn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const supervisorCheckTimeout = 10 * time.Second
+
+// SupervisorConfig configures DialSupervisedCmd.
+type SupervisorConfig struct {
+	// Name is the name of the plugin in logs and status.
+	Name string
+
+	// NewCmd returns the command of the plugin, it is called again for
+	// every restart.
+	NewCmd func() *exec.Cmd
+
+	// Started is called once the process of cmd is running.
+	Started func(cmd *exec.Cmd) error
+
+	// MinBackoff and MaxBackoff bound the delay before a restart. The delay
+	// doubles after every failed restart and goes back to MinBackoff once
+	// the plugin stayed up for MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// HealthCheckInterval is how often the plugin is asked for its
+	// callbacks to detect a process that stopped answering, 0 disables the
+	// check.
+	HealthCheckInterval time.Duration
+
+	// OnRestart is called after the plugin was restarted and answers again.
+	OnRestart func()
+}
+
+// SupervisedCmdPlugin is a child process plugin that is restarted when the
+// process exits or stops answering. The grpc connection outlives the
+// process, it redials whichever process is currently running.
+type SupervisedCmdPlugin struct {
+	GrpcPlugin
+
+	config SupervisorConfig
+	conns  chan net.Conn
+
+	available atomic.Bool
+	restarts  atomic.Int64
+
+	mu            sync.Mutex
+	lastError     error
+	lastRestartAt time.Time
+}
+
+// SupervisedCmdStatus is a snapshot of a SupervisedCmdPlugin.
+type SupervisedCmdStatus struct {
+	Name          string
+	Available     bool
+	Restarts      int64
+	LastError     error
+	LastRestartAt time.Time
+}
+
+// DialSupervisedCmd starts the plugin and keeps it running.
+func DialSupervisedCmd(config SupervisorConfig) (*SupervisedCmdPlugin, error) {
+	s := &SupervisedCmdPlugin{
+		config: config,
+		conns:  make(chan net.Conn, 1),
+	}
+
+	// this dummy 127.0.0.1 is not used
+	conn, err := grpc.NewClient("127.0.0.1",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(s.dial),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			// restarts are paced by the supervisor, redial as soon as the
+			// new process is there
+			Backoff: backoff.Config{
+				BaseDelay:  100 * time.Millisecond,
+				Multiplier: 1.6,
+				Jitter:     0.2,
+				MaxDelay:   time.Second,
+			},
+			MinConnectTimeout: 20 * time.Second,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := DialGrpc(conn)
+	if err != nil {
+		return nil, err
+	}
+	s.GrpcPlugin = *g
+	s.Name = config.Name
+
+	cmd, exited, err := s.start()
+	if err != nil {
+		return nil, err
+	}
+
+	s.available.Store(true)
+	go s.supervise(cmd, exited)
+
+	return s, nil
+}
+
+// Available reports whether the plugin process is up.
+func (s *SupervisedCmdPlugin) Available() bool {
+	return s.available.Load()
+}
+
+// Status returns the restart statistics of the plugin.
+func (s *SupervisedCmdPlugin) Status() SupervisedCmdStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return SupervisedCmdStatus{
+		Name:          s.Name,
+		Available:     s.available.Load(),
+		Restarts:      s.restarts.Load(),
+		LastError:     s.lastError,
+		LastRestartAt: s.lastRestartAt,
+	}
+}
+
+// dial hands the connection of the running process to grpc, waiting for a
+// restart if there is none.
+func (s *SupervisedCmdPlugin) dial(ctx context.Context, _ string) (net.Conn, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *SupervisedCmdPlugin) start() (*exec.Cmd, <-chan error, error) {
+	cmd := s.config.NewCmd()
+
+	cmdconn, stderr, err := ioconn.DialCmd(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	go func() {
+		_, _ = io.Copy(os.Stderr, stderr)
+	}()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	if s.config.Started != nil {
+		if err := s.config.Started(cmd); err != nil {
+			_ = cmdconn.Close()
+			_ = cmd.Process.Kill()
+			<-exited
+			return nil, nil, err
+		}
+	}
+
+	// a process that died before grpc dialed it may have left its conn
+	select {
+	case stale := <-s.conns:
+		_ = stale.Close()
+	default:
+	}
+	s.conns <- cmdconn
+
+	return cmd, exited, nil
+}
+
+// check asks the plugin for its callbacks, waiting for grpc to connect.
+func (s *SupervisedCmdPlugin) check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), supervisorCheckTimeout)
+	defer cancel()
+
+	_, err := s.client.ListCallbacks(ctx, &libplugin.ListCallbackRequest{}, grpc.WaitForReady(true))
+	return err
+}
+
+// wait returns why the process went down.
+func (s *SupervisedCmdPlugin) wait(cmd *exec.Cmd, exited <-chan error) error {
+	var tick <-chan time.Time
+	if s.config.HealthCheckInterval > 0 {
+		ticker := time.NewTicker(s.config.HealthCheckInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				return fmt.Errorf("plugin process exited")
+			}
+			return fmt.Errorf("plugin process exited: %w", err)
+		case <-tick:
+			if err := s.check(); err != nil {
+				_ = cmd.Process.Kill()
+				<-exited
+				return fmt.Errorf("plugin health check failed: %w", err)
+			}
+		}
+	}
+}
+
+func (s *SupervisedCmdPlugin) setLastError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastError = err
+}
+
+func (s *SupervisedCmdPlugin) supervise(cmd *exec.Cmd, exited <-chan error) {
+	delay := s.config.MinBackoff
+
+	for {
+		upSince := time.Now()
+		err := s.wait(cmd, exited)
+
+		s.available.Store(false)
+		s.setLastError(err)
+
+		if time.Since(upSince) >= s.config.MaxBackoff {
+			delay = s.config.MinBackoff
+		}
+
+		for {
+			slog.Error("plugin is down, restarting", "plugin", s.Name, "error", err, "backoff", delay, "restarts", s.restarts.Load())
+			time.Sleep(delay)
+			delay = min(delay*2, s.config.MaxBackoff)
+
+			cmd, exited, err = s.start()
+			if err == nil {
+				if err = s.check(); err == nil {
+					break
+				}
+
+				_ = cmd.Process.Kill()
+				<-exited
+			}
+
+			s.setLastError(err)
+		}
+
+		s.mu.Lock()
+		s.lastRestartAt = time.Now()
+		s.mu.Unlock()
+
+		restarts := s.restarts.Add(1)
+		s.available.Store(true)
+		slog.Info("plugin restarted", "plugin", s.Name, "restarts", restarts)
+
+		if s.config.OnRestart != nil {
+			s.config.OnRestart()
+		}
+	}
+}
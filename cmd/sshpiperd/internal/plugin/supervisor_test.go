@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tg123/sshpiper/libplugin"
+)
+
+// TestSupervisedCmdPluginHelper is the plugin process started by
+// TestSupervisedCmdPlugin, it does nothing when run as a test.
+func TestSupervisedCmdPluginHelper(t *testing.T) {
+	if os.Getenv("SSHPIPERD_TEST_SUPERVISED_PLUGIN") != "1" {
+		return
+	}
+
+	p, err := libplugin.NewFromStdio(libplugin.SshPiperPluginConfig{
+		PasswordCallback: func(conn libplugin.ConnMetadata, password []byte) (*libplugin.Upstream, error) {
+			return nil, nil
+		},
+	})
+	if err != nil {
+		os.Exit(1)
+	}
+
+	_ = p.Serve()
+	os.Exit(0)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %v", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSupervisedCmdPlugin(t *testing.T) {
+	var cmd atomic.Pointer[exec.Cmd]
+	restarted := make(chan struct{}, 1)
+
+	p, err := DialSupervisedCmd(SupervisorConfig{
+		Name: "helper",
+		NewCmd: func() *exec.Cmd {
+			c := exec.Command(os.Args[0], "-test.run=^TestSupervisedCmdPluginHelper$")
+			c.Env = append(os.Environ(), "SSHPIPERD_TEST_SUPERVISED_PLUGIN=1")
+			cmd.Store(c)
+			return c
+		},
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 100 * time.Millisecond,
+		OnRestart: func() {
+			restarted <- struct{}{}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Load().Process.Kill()
+	})
+
+	config, err := p.CreatePiperConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PasswordCallback == nil {
+		t.Fatal("expected password callback to be installed")
+	}
+	if !p.Available() {
+		t.Fatal("expected plugin to be available")
+	}
+
+	first := cmd.Load()
+	if err := first.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "plugin to be marked down", func() bool { return p.Status().LastError != nil })
+
+	select {
+	case <-restarted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for restart")
+	}
+
+	if cmd.Load() == first {
+		t.Error("expected a new process")
+	}
+
+	st := p.Status()
+	if !st.Available || st.Restarts != 1 || st.LastRestartAt.IsZero() {
+		t.Errorf("unexpected status after restart %+v", st)
+	}
+
+	// the grpc connection now reaches the new process
+	if err := p.Refresh(); err != nil {
+		t.Errorf("plugin does not answer after restart: %v", err)
+	}
+}
+
+func TestSupervisedCmdPluginStartedFails(t *testing.T) {
+	var cmd *exec.Cmd
+
+	_, err := DialSupervisedCmd(SupervisorConfig{
+		Name: "helper",
+		NewCmd: func() *exec.Cmd {
+			cmd = exec.Command(os.Args[0], "-test.run=^TestSupervisedCmdPluginHelper$")
+			cmd.Env = append(os.Environ(), "SSHPIPERD_TEST_SUPERVISED_PLUGIN=1")
+			return cmd
+		},
+		Started: func(*exec.Cmd) error {
+			return errors.New("cannot add process to job")
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error when Started fails")
+	}
+
+	if cmd.ProcessState == nil {
+		t.Error("expected the process to be killed and waited for")
+	}
+}
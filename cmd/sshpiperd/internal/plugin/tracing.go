@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/metadata"
+)
+
+var tracer = otel.Tracer("github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin")
+
+// tracePropagator sends the W3C trace context to plugins, libplugin reads
+// it to continue the trace.
+var tracePropagator = propagation.TraceContext{}
+
+type tracedConn struct {
+	ssh.ServerPreAuthConn
+	ctx context.Context
+}
+
+// WithTraceContext returns conn carrying ctx to CreateChallengeContext. The
+// plugin calls made for the connection are traced as children of ctx.
+func WithTraceContext(conn ssh.ServerPreAuthConn, ctx context.Context) ssh.ServerPreAuthConn {
+	return tracedConn{ServerPreAuthConn: conn, ctx: ctx}
+}
+
+func connTraceContext(conn ssh.ServerPreAuthConn) context.Context {
+	if c, ok := conn.(tracedConn); ok {
+		return c.ctx
+	}
+
+	return context.Background()
+}
+
+func pluginConnMeta(challengeCtx ssh.ChallengeContext) *PluginConnMeta {
+	switch meta := challengeCtx.(type) {
+	case *PluginConnMeta:
+		return meta
+	case *chainConnMeta:
+		return &meta.PluginConnMeta
+	}
+
+	return nil
+}
+
+// SetTraceContext traces the later plugin calls of the connection, e.g.
+// PipeStartNotice, as children of ctx.
+func SetTraceContext(challengeCtx ssh.ChallengeContext, ctx context.Context) {
+	if meta := pluginConnMeta(challengeCtx); meta != nil {
+		meta.traceCtx = ctx
+	}
+}
+
+func traceContext(challengeCtx ssh.ChallengeContext) context.Context {
+	if meta := pluginConnMeta(challengeCtx); meta != nil && meta.traceCtx != nil {
+		return meta.traceCtx
+	}
+
+	return context.Background()
+}
+
+// startSpan starts a span under parent, unless parent is not traced, so
+// calls outside of a connection, e.g. ListCallbacks, do not start traces.
+func startSpan(parent context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(parent).IsValid() {
+		return parent, trace.SpanFromContext(parent)
+	}
+
+	return tracer.Start(parent, name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// withTraceMetadata adds the trace context of ctx to the grpc metadata of
+// the call.
+func withTraceMetadata(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{}
+	tracePropagator.Inject(ctx, carrier)
+
+	for k, v := range carrier {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+
+	return ctx
+}
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tg123/sshpiper/libplugin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type traceMockClient struct {
+	libplugin.SshPiperPluginClient
+	traceparent []string
+}
+
+func (m *traceMockClient) NewConnection(ctx context.Context, _ *libplugin.NewConnectionRequest, _ ...grpc.CallOption) (*libplugin.NewConnectionResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	m.traceparent = md.Get("traceparent")
+	return &libplugin.NewConnectionResponse{}, nil
+}
+
+func TestGrpcPluginPropagatesTraceContext(t *testing.T) {
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "handshake")
+	defer span.End()
+
+	conn := mockPreAuthConn{meta: mockConnMetadata{user: "user", remoteAddr: mockAddr("remote:22")}}
+
+	for _, tt := range []struct {
+		name   string
+		traced bool
+	}{
+		{name: "traced connection", traced: true},
+		{name: "untraced connection"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &traceMockClient{}
+			g := &GrpcPlugin{
+				Name:      "plugin",
+				client:    client,
+				callbacks: testCallbacks(&pluginCallbacks{newConnection: true}),
+				breaker:   &circuitBreaker{},
+				stats:     &rpcStats{},
+			}
+
+			var preAuth ssh.ServerPreAuthConn = conn
+			if tt.traced {
+				preAuth = WithTraceContext(conn, ctx)
+			}
+
+			challengeCtx, err := g.CreateChallengeContext(preAuth)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.traced {
+				if len(client.traceparent) != 0 {
+					t.Errorf("expected no traceparent, got %v", client.traceparent)
+				}
+				return
+			}
+
+			if len(client.traceparent) != 1 || !strings.Contains(client.traceparent[0], span.SpanContext().TraceID().String()) {
+				t.Errorf("traceparent = %v, want trace %v", client.traceparent, span.SpanContext().TraceID())
+			}
+			if traceContext(challengeCtx) != ctx {
+				t.Error("expected the challenge context to carry the trace context")
+			}
+		})
+	}
+}
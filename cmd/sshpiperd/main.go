@@ -1,23 +1,30 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime/debug"
 	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/pires/go-proxyproto"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tg123/sshpiper/cmd/internal/slogutil"
 	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/admin"
 	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"github.com/tg123/sshpiper/libadmin"
 	"github.com/urfave/cli/v2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -84,16 +91,28 @@ func splitByDash(args []string) ([]string, []string) {
 	return args, nil
 }
 
-func createCmdPlugin(args []string) (*plugin.CmdPlugin, error) {
+func newPluginCmd(args []string, env map[string]string) *exec.Cmd {
 	exe := args[0]
 
 	cmd := exec.Command(exe)
 	cmd.Args = args
+	if len(env) > 0 {
+		cmd.Env = os.Environ()
+		for _, k := range slices.Sorted(maps.Keys(env)) {
+			cmd.Env = append(cmd.Env, k+"="+env[k])
+		}
+	}
 	setPdeathsig(cmd)
 
 	slog.Info("starting child process plugin", "exe", exe, "argCount", len(cmd.Args))
 	slog.Debug("child process plugin args", "args", cmd.Args)
 
+	return cmd
+}
+
+func createCmdPlugin(args []string, env map[string]string) (*plugin.CmdPlugin, error) {
+	cmd := newPluginCmd(args, env)
+
 	p, err := plugin.DialCmd(cmd)
 	if err != nil {
 		return nil, err
@@ -103,24 +122,89 @@ func createCmdPlugin(args []string) (*plugin.CmdPlugin, error) {
 		return nil, err
 	}
 
-	p.Name = exe
+	p.Name = args[0]
+
+	return p, nil
+}
+
+func createSupervisedCmdPlugin(ctx *cli.Context, args []string, env map[string]string, onRestart func(p *plugin.SupervisedCmdPlugin)) (*plugin.SupervisedCmdPlugin, error) {
+	var p *plugin.SupervisedCmdPlugin
+	restarted := make(chan struct{})
+
+	p, err := plugin.DialSupervisedCmd(plugin.SupervisorConfig{
+		Name: args[0],
+		NewCmd: func() *exec.Cmd {
+			return newPluginCmd(args, env)
+		},
+		Started:             addProcessToJob,
+		MinBackoff:          time.Second,
+		MaxBackoff:          ctx.Duration("plugin-restart-max-backoff"),
+		HealthCheckInterval: ctx.Duration("plugin-health-check-interval"),
+		OnRestart: func() {
+			<-restarted
+			onRestart(p)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	close(restarted)
 
 	return p, nil
 }
 
+func rpcPolicyFromFlags(ctx *cli.Context) (plugin.RPCPolicy, error) {
+	policy := plugin.RPCPolicy{
+		Timeout:         ctx.Duration("plugin-rpc-timeout"),
+		BreakerFailures: ctx.Int("plugin-circuit-breaker-failures"),
+		BreakerCooldown: ctx.Duration("plugin-circuit-breaker-cooldown"),
+		BreakerSkip:     ctx.Bool("plugin-circuit-breaker-skip"),
+	}
+
+	if raw := ctx.StringSlice("plugin-rpc-timeouts"); len(raw) > 0 {
+		policy.Timeouts = make(map[string]time.Duration, len(raw))
+		for _, kv := range raw {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return plugin.RPCPolicy{}, fmt.Errorf("invalid --plugin-rpc-timeouts %q: expected RPC=DURATION", kv)
+			}
+
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return plugin.RPCPolicy{}, fmt.Errorf("invalid --plugin-rpc-timeouts %q: %w", kv, err)
+			}
+			policy.Timeouts[k] = d
+		}
+	}
+
+	return policy, policy.Validate()
+}
+
+func recvPluginLogs(p *plugin.GrpcPlugin, level slog.Level) {
+	if err := p.RecvLogs(os.Stderr, level.String()); err != nil {
+		slog.Error("plugin recv logs error", "plugin", p.Name, "error", err)
+	}
+}
+
 func isValidLogFormat(logFormat string) bool {
 	validFormats := []string{"text", "json"}
 	return slices.Contains(validFormats, logFormat)
 }
 
-func main() {
-	app := &cli.App{
+func newApp() *cli.App {
+	return &cli.App{
 		Name:        "sshpiperd",
 		Usage:       "the missing reverse proxy for ssh scp",
 		UsageText:   "sshpiperd [options] <plugin1> [plugin options] [-- [plugin2] [plugin options] [-- ...]]",
 		Description: "sshpiperd works as a proxy-like ware, and route connections by username, src ip , etc.\nhttps://github.com/tg123/sshpiper",
 		Version:     version(),
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Usage:   "yaml config file with sshpiperd flags and the plugin chain, command line flags and env take precedence; reloaded on SIGHUP",
+				EnvVars: []string{"SSHPIPERD_CONFIG"},
+			},
 			&cli.StringFlag{
 				Name:    "address",
 				Aliases: []string{"l"},
@@ -157,6 +241,54 @@ func main() {
 				Usage:   "server certificate in base64 format, server-cert will be ignored if set",
 				EnvVars: []string{"SSHPIPERD_SERVER_CERT_DATA"},
 			},
+			&cli.StringFlag{
+				Name:    "server-key-agent",
+				Usage:   "path to an ssh-agent socket holding the server keys, server-key, server-key-data, server-key-generate-mode will be ignored if set",
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_AGENT"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "server-key-agent-fingerprint",
+				Value:   cli.NewStringSlice(),
+				Usage:   "SHA256 fingerprints of the ssh-agent keys to use as server keys, empty will use every key in the agent",
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_AGENT_FINGERPRINT"},
+			},
+			&cli.StringFlag{
+				Name:    "server-key-remotesigner",
+				Usage:   "grpc endpoint of a remote signer (github.com/tg123/remotesigner/grpcsigner) holding rsa or ecdsa server keys, server-key, server-key-data, server-key-generate-mode will be ignored if set",
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_REMOTESIGNER"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "server-key-remotesigner-meta",
+				Value:   cli.NewStringSlice(),
+				Usage:   "metadata sent to the remote signer to select a server key, one server key per value, empty will use a single key with empty metadata",
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_REMOTESIGNER_META"},
+			},
+			&cli.BoolFlag{
+				Name:    "server-key-remotesigner-insecure",
+				Usage:   "disable tls when connecting to the remote signer",
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_REMOTESIGNER_INSECURE"},
+			},
+			&cli.StringFlag{
+				Name:    "server-key-remotesigner-cert",
+				Usage:   "client cert path used to connect to the remote signer",
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_REMOTESIGNER_CERT"},
+			},
+			&cli.StringFlag{
+				Name:    "server-key-remotesigner-key",
+				Usage:   "client key path used to connect to the remote signer",
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_REMOTESIGNER_KEY"},
+			},
+			&cli.StringFlag{
+				Name:    "server-key-remotesigner-cacert",
+				Usage:   "ca cert path used to verify the remote signer",
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_REMOTESIGNER_CACERT"},
+			},
+			&cli.DurationFlag{
+				Name:    "server-key-rotation-window",
+				Usage:   "after host keys are reloaded (SIGHUP or admin API), keep presenting the previous keys for this long while advertising the new ones to clients via hostkeys-00@openssh.com, 0 switches to the new keys immediately",
+				Value:   0,
+				EnvVars: []string{"SSHPIPERD_SERVER_KEY_ROTATION_WINDOW"},
+			},
 			&cli.StringFlag{
 				Name:    "server-key-generate-mode",
 				Usage:   "server key generate mode, one of: disable, notexist, always. generated key will be written to `server-key` if notexist or always",
@@ -217,6 +349,71 @@ func main() {
 				Usage:   "upstream banner mode, allowed values: 'passthrough' (pass the banner from upstream to downstream), 'ignore' (ignore the banner from upstream), 'dedup' (deduplicate the banner from upstream, only pass same banner once to downstream), 'first-only' (only pass the first banner from upstream to downstream)",
 				EnvVars: []string{"SSHPIPERD_UPSTREAM_BANNER_MODE"},
 			},
+			&cli.BoolFlag{
+				Name:    "plugin-restart",
+				Value:   true,
+				Usage:   "restart child process plugins that exit or stop answering instead of exiting sshpiperd, new connections are refused while a plugin is restarting",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_RESTART"},
+			},
+			&cli.DurationFlag{
+				Name:    "plugin-restart-max-backoff",
+				Value:   time.Minute,
+				Usage:   "maximum delay between plugin restarts, the delay starts at 1s and doubles after every failed restart",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_RESTART_MAX_BACKOFF"},
+			},
+			&cli.DurationFlag{
+				Name:    "plugin-health-check-interval",
+				Value:   10 * time.Second,
+				Usage:   "interval of checking that child process plugins answer, a plugin that does not is restarted, 0 to disable",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_HEALTH_CHECK_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "plugin-unavailable-banner",
+				Value:   "sshpiperd is restarting a plugin, please try again later\n",
+				Usage:   "banner sent to clients refused while a plugin is restarting",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_UNAVAILABLE_BANNER"},
+			},
+			&cli.DurationFlag{
+				Name:    "plugin-rpc-timeout",
+				Value:   0,
+				Usage:   "deadline of plugin grpc calls, 0 for none; keyboard-interactive auth waits for the user and is only bounded by --plugin-rpc-timeouts",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_RPC_TIMEOUT"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "plugin-rpc-timeouts",
+				Usage:   "deadline of a single plugin grpc call in RPC=DURATION form, e.g. PasswordAuth=5s, overrides --plugin-rpc-timeout",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_RPC_TIMEOUTS"},
+			},
+			&cli.IntFlag{
+				Name:    "plugin-circuit-breaker-failures",
+				Value:   0,
+				Usage:   "consecutive timed out or unavailable calls to a plugin that open its circuit breaker and fail further calls fast, 0 to disable",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_CIRCUIT_BREAKER_FAILURES"},
+			},
+			&cli.DurationFlag{
+				Name:    "plugin-circuit-breaker-cooldown",
+				Value:   30 * time.Second,
+				Usage:   "how long an open plugin circuit breaker fails calls before trying the plugin again",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_CIRCUIT_BREAKER_COOLDOWN"},
+			},
+			&cli.BoolFlag{
+				Name:    "plugin-circuit-breaker-skip",
+				Value:   false,
+				Usage:   "move on to the next plugin of the chain while a plugin's circuit breaker is open instead of failing the authentication, the last plugin is never skipped",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_CIRCUIT_BREAKER_SKIP"},
+			},
+			&cli.StringFlag{
+				Name:    "plugin-error-policy",
+				Value:   "fail-closed",
+				Usage:   "what a plugin chain does when a plugin is unreachable or times out: fail-closed fails the authentication, skip moves on to the next plugin, retry-current calls the plugin again; errors answered by the plugin always fail",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_ERROR_POLICY"},
+			},
+			&cli.IntFlag{
+				Name:    "plugin-error-retries",
+				Value:   1,
+				Usage:   "how many times an unreachable or timed out plugin call is retried with --plugin-error-policy retry-current",
+				EnvVars: []string{"SSHPIPERD_PLUGIN_ERROR_RETRIES"},
+			},
 			&cli.BoolFlag{
 				Name:    "drop-hostkeys-message",
 				Value:   false,
@@ -241,6 +438,36 @@ func main() {
 				Usage:   "reject remote port forwarding requests from downstream clients (ssh -R)",
 				EnvVars: []string{"SSHPIPERD_DISABLE_REMOTE_FORWARDING"},
 			},
+			&cli.BoolFlag{
+				Name:    "disable-agent-forwarding",
+				Value:   false,
+				Usage:   "reject ssh-agent forwarding requests from downstream clients (ssh -A)",
+				EnvVars: []string{"SSHPIPERD_DISABLE_AGENT_FORWARDING"},
+			},
+			&cli.BoolFlag{
+				Name:    "disable-x11-forwarding",
+				Value:   false,
+				Usage:   "reject X11 forwarding requests from downstream clients (ssh -X)",
+				EnvVars: []string{"SSHPIPERD_DISABLE_X11_FORWARDING"},
+			},
+			&cli.DurationFlag{
+				Name:    "session-idle-timeout",
+				Value:   0,
+				Usage:   "close sessions without channel data in either direction for this long, 0 to disable",
+				EnvVars: []string{"SSHPIPERD_SESSION_IDLE_TIMEOUT"},
+			},
+			&cli.DurationFlag{
+				Name:    "session-max-duration",
+				Value:   0,
+				Usage:   "close sessions after this long, 0 to disable",
+				EnvVars: []string{"SSHPIPERD_SESSION_MAX_DURATION"},
+			},
+			&cli.IntFlag{
+				Name:    "session-bandwidth-limit",
+				Value:   0,
+				Usage:   "limit the channel data of a session to this many bytes per second in each direction, 0 to disable",
+				EnvVars: []string{"SSHPIPERD_SESSION_BANDWIDTH_LIMIT"},
+			},
 			&cli.StringSliceFlag{
 				Name:    "allowed-proxy-addresses",
 				Value:   cli.NewStringSlice(),
@@ -283,6 +510,54 @@ func main() {
 				Usage:   "allowed public key algorithms for downstream connections, empty will allow default algorithms",
 				EnvVars: []string{"SSHPIPERD_ALLOWED_DOWNSTREAM_PUBKEY_ALGOS"},
 			},
+			&cli.StringFlag{
+				Name:    "metrics-address",
+				Value:   "",
+				Usage:   "listening address, e.g. 127.0.0.1:9100, for prometheus metrics served at /metrics, empty will disable metrics",
+				EnvVars: []string{"SSHPIPERD_METRICS_ADDRESS"},
+			},
+			&cli.StringFlag{
+				Name:    "health-address",
+				Value:   "",
+				Usage:   "listening address, e.g. 0.0.0.0:8080, for the liveness probe at /healthz and the readiness probe at /readyz, may be the same as --metrics-address, empty will disable health checks",
+				EnvVars: []string{"SSHPIPERD_HEALTH_ADDRESS"},
+			},
+			&cli.DurationFlag{
+				Name:    "drain-delay",
+				Value:   0,
+				Usage:   "on SIGTERM, fail the readiness probe and keep serving for this long before exiting, so load balancers stop sending new connections first, 0 exits immediately",
+				EnvVars: []string{"SSHPIPERD_DRAIN_DELAY"},
+			},
+			&cli.StringFlag{
+				Name:    "tracing-exporter",
+				Value:   "",
+				Usage:   "opentelemetry trace exporter, otlp or file, one trace per connection, empty will disable tracing",
+				EnvVars: []string{"SSHPIPERD_TRACING_EXPORTER"},
+			},
+			&cli.StringFlag{
+				Name:    "tracing-otlp-endpoint",
+				Value:   "localhost:4317",
+				Usage:   "host:port of the otlp grpc collector, used by the otlp tracing exporter",
+				EnvVars: []string{"SSHPIPERD_TRACING_OTLP_ENDPOINT"},
+			},
+			&cli.BoolFlag{
+				Name:    "tracing-otlp-insecure",
+				Value:   false,
+				Usage:   "disable TLS to the otlp grpc collector",
+				EnvVars: []string{"SSHPIPERD_TRACING_OTLP_INSECURE"},
+			},
+			&cli.StringFlag{
+				Name:    "tracing-file",
+				Value:   "",
+				Usage:   "file the spans are appended to as json, used by the file tracing exporter",
+				EnvVars: []string{"SSHPIPERD_TRACING_FILE"},
+			},
+			&cli.Float64Flag{
+				Name:    "tracing-sample-ratio",
+				Value:   1,
+				Usage:   "ratio of the connections traced, from 0 to 1",
+				EnvVars: []string{"SSHPIPERD_TRACING_SAMPLE_RATIO"},
+			},
 			&cli.StringFlag{
 				Name:    "admin-grpc-address",
 				Value:   "127.0.0.1",
@@ -325,8 +600,51 @@ func main() {
 				Usage:   "CA certificate (PEM) used to verify admin gRPC clients. When set, mutual TLS is required and clients must present a certificate signed by this CA",
 				EnvVars: []string{"SSHPIPERD_ADMIN_GRPC_TLS_CACERT"},
 			},
+			&cli.BoolFlag{
+				Name:    "admin-grpc-allow-attach",
+				Value:   false,
+				Usage:   "allow admin gRPC clients to attach read-write to live sessions and type into them (AttachSession). The user is told when an admin joins and leaves",
+				EnvVars: []string{"SSHPIPERD_ADMIN_GRPC_ALLOW_ATTACH"},
+			},
+			&cli.StringFlag{
+				Name:    "admin-grpc-audit-log",
+				Value:   "",
+				Usage:   "JSONL file recording every admin gRPC call with its caller, sessions and result, hash-chained to detect removed lines. Created if missing, empty disables the audit log",
+				EnvVars: []string{"SSHPIPERD_ADMIN_GRPC_AUDIT_LOG"},
+			},
+			&cli.StringFlag{
+				Name:    "admin-grpc-policy-file",
+				Value:   "",
+				Usage:   "YAML file mapping admin client certificates to the viewer, streamer or operator role, requires --admin-grpc-tls-cacert. Empty gives every client full access",
+				EnvVars: []string{"SSHPIPERD_ADMIN_GRPC_POLICY_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "admin-grpc-history-file",
+				Value:   "",
+				Usage:   "database file recording the finished sessions for the ListSessionHistory admin RPC, created if missing. Empty disables the session history",
+				EnvVars: []string{"SSHPIPERD_ADMIN_GRPC_HISTORY_FILE"},
+			},
+			&cli.DurationFlag{
+				Name:    "admin-grpc-history-retention",
+				Value:   admin.DefaultHistoryRetention,
+				Usage:   "how long the session history keeps the finished sessions, 0 keeps them forever",
+				EnvVars: []string{"SSHPIPERD_ADMIN_GRPC_HISTORY_RETENTION"},
+			},
 		},
 		Action: func(ctx *cli.Context) error {
+			var cfg *configFile
+			if path := ctx.String("config"); path != "" {
+				var err error
+				cfg, err = loadConfigFile(path)
+				if err != nil {
+					return err
+				}
+
+				if err := applyConfigFlags(ctx, cfg); err != nil {
+					return err
+				}
+			}
+
 			level, err := slogutil.ParseLevel(ctx.String("log-level"))
 			if err != nil {
 				slog.Warn("unknown log level, falling back to info", "logLevel", ctx.String("log-level"), "error", err)
@@ -336,7 +654,9 @@ func main() {
 			if !isValidLogFormat(logFormat) {
 				return fmt.Errorf("not a valid log-format: %v", logFormat)
 			}
-			handlerOptions := &slog.HandlerOptions{Level: level}
+			levelVar := new(slog.LevelVar)
+			levelVar.Set(level)
+			handlerOptions := &slog.HandlerOptions{Level: levelVar}
 			if logFormat == "json" {
 				slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, handlerOptions)))
 			} else {
@@ -349,8 +669,104 @@ func main() {
 				return err
 			}
 
+			// metrics and health share one listener when their addresses
+			// are the same
+			muxes := make(map[string]*http.ServeMux)
+			muxFor := func(addr string) *http.ServeMux {
+				if muxes[addr] == nil {
+					muxes[addr] = http.NewServeMux()
+				}
+				return muxes[addr]
+			}
+
+			if addr := ctx.String("metrics-address"); addr != "" {
+				reg := prometheus.NewRegistry()
+				d.metrics = newDaemonMetrics(reg)
+				muxFor(addr).Handle("/metrics", metricsHandler(reg))
+			}
+
+			if addr := ctx.String("health-address"); addr != "" {
+				d.registerHealth(muxFor(addr))
+			}
+
+			for addr, mux := range muxes {
+				if err := serveHTTP(addr, mux); err != nil {
+					return fmt.Errorf("failed to listen on %v: %w", addr, err)
+				}
+			}
+
+			shutdownTracing, err := setupTracing(tracingOptions{
+				exporter:     ctx.String("tracing-exporter"),
+				otlpEndpoint: ctx.String("tracing-otlp-endpoint"),
+				otlpInsecure: ctx.Bool("tracing-otlp-insecure"),
+				file:         ctx.String("tracing-file"),
+				sampleRatio:  ctx.Float64("tracing-sample-ratio"),
+			})
+			if err != nil {
+				return err
+			}
+			defer func() {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				if err := shutdownTracing(shutdownCtx); err != nil {
+					slog.Warn("failed to flush traces", "error", err)
+				}
+			}()
+
 			quit := make(chan error)
 
+			var reloader *configReloader
+			if cfg != nil {
+				reloader = &configReloader{
+					path:    ctx.String("config"),
+					args:    os.Args[1:],
+					running: ctx,
+					plugins: cfg.Plugins,
+					routes:  cfg.Routes,
+					level:   levelVar,
+					d:       d,
+				}
+			}
+
+			go func() {
+				sigChan := make(chan os.Signal, 1)
+				signal.Notify(sigChan, syscall.SIGHUP)
+
+				for range sigChan {
+					if reloader != nil {
+						slog.Info("reloading config due to SIGHUP", "config", reloader.path)
+						if err := reloader.reload(); err != nil {
+							slog.Error("failed to reload config, keeping the current one", "error", err)
+						}
+						continue
+					}
+
+					slog.Info("reloading host keys due to SIGHUP")
+					if _, err := d.reloadHostKeys(); err != nil {
+						slog.Error("failed to reload host keys, keeping the current ones", "error", err)
+					}
+				}
+			}()
+
+			if delay := ctx.Duration("drain-delay"); delay > 0 {
+				go func() {
+					sigChan := make(chan os.Signal, 1)
+					signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
+
+					<-sigChan
+					slog.Info("draining before exit, send the signal again to exit now", "delay", delay)
+					d.draining.Store(true)
+
+					select {
+					case <-time.After(delay):
+					case <-sigChan:
+					}
+
+					quit <- nil
+				}()
+			}
+
 			allowedproxyaddresses := ctx.StringSlice("allowed-proxy-addresses")
 
 			if len(allowedproxyaddresses) > 0 {
@@ -370,8 +786,17 @@ func main() {
 
 			args := ctx.Args().Slice()
 
+			var specs []pluginSpec
+			if len(args) == 0 && cfg != nil {
+				specs = cfg.pluginSpecs()
+			}
+
+			if cfg != nil && len(cfg.Routes) > 0 && len(specs) == 0 {
+				return fmt.Errorf("routes need the plugins to be set in the config file")
+			}
+
 			// If no command-line arguments are provided, fall back to the PLUGIN environment variable.
-			if len(args) == 0 {
+			if len(args) == 0 && len(specs) == 0 {
 				pluginEnv := os.Getenv("PLUGIN")
 				if pluginEnv != "" {
 
@@ -419,13 +844,27 @@ func main() {
 					continue
 				}
 
+				specs = append(specs, pluginSpec{args: args})
+			}
+
+			rpcPolicy, err := rpcPolicyFromFlags(ctx)
+			if err != nil {
+				return err
+			}
+
+			errorPolicy, err := plugin.ParseErrorPolicy(ctx.String("plugin-error-policy"))
+			if err != nil {
+				return err
+			}
+
+			for _, spec := range specs {
 				var p *plugin.GrpcPlugin
 
-				switch args[0] {
+				switch spec.args[0] {
 				case "grpc":
 					slog.Info("starting net grpc plugin")
 
-					grpcplugin, err := createNetGrpcPlugin(args)
+					grpcplugin, err := createNetGrpcPlugin(spec.args)
 					if err != nil {
 						return err
 					}
@@ -433,7 +872,21 @@ func main() {
 					p = grpcplugin
 
 				default:
-					cmdplugin, err := createCmdPlugin(args)
+					if ctx.Bool("plugin-restart") {
+						supervised, err := createSupervisedCmdPlugin(ctx, spec.args, spec.env, func(p *plugin.SupervisedCmdPlugin) {
+							go recvPluginLogs(&p.GrpcPlugin, levelVar.Level())
+							d.reinstall(&p.GrpcPlugin)
+						})
+						if err != nil {
+							return err
+						}
+
+						d.supervised = append(d.supervised, supervised)
+						p = &supervised.GrpcPlugin
+						break
+					}
+
+					cmdplugin, err := createCmdPlugin(spec.args, spec.env)
 					if err != nil {
 						return err
 					}
@@ -445,15 +898,38 @@ func main() {
 					p = &cmdplugin.GrpcPlugin
 				}
 
-				go func() {
-					if err := p.RecvLogs(os.Stderr, level.String()); err != nil {
-						slog.Error("plugin recv logs error", "plugin", p.Name, "error", err)
+				p.Policy = rpcPolicy
+				p.ErrorPolicy = errorPolicy
+				p.ErrorRetries = ctx.Int("plugin-error-retries")
+				if spec.config != nil {
+					if p.Policy, err = spec.config.rpcPolicy(rpcPolicy); err != nil {
+						return err
 					}
-				}()
+
+					if err := spec.config.applyErrorPolicy(p); err != nil {
+						return err
+					}
+
+					if spec.config.Name != "" {
+						p.Name = spec.config.Name
+					}
+				}
+
+				p.OnRPC = d.metrics.pluginRPC(p.Name)
+				p.DaemonVersion = version()
+
+				go recvPluginLogs(p, level)
 
 				plugins = append(plugins, p)
 			}
 
+			if cfg != nil && len(cfg.Routes) > 0 {
+				if d.routes, err = cfg.pluginRoutes(plugins); err != nil {
+					return err
+				}
+			}
+
+			d.unavailableBanner = ctx.String("plugin-unavailable-banner")
 			if err := d.install(plugins...); err != nil {
 				return err
 			}
@@ -461,21 +937,6 @@ func main() {
 			d.recorddir = ctx.String("screen-recording-dir")
 			d.recordfmt = ctx.String("screen-recording-format")
 			d.usernameAsRecorddir = ctx.Bool("username-as-recorddir")
-			d.filterHostkeysReqeust = ctx.Bool("drop-hostkeys-message")
-			d.replyPing = ctx.Bool("reply-ping")
-			d.disableLocalForward = ctx.Bool("disable-local-forwarding")
-			d.disableRemoteForward = ctx.Bool("disable-remote-forwarding")
-
-			if raw := ctx.StringSlice("inject-env"); len(raw) > 0 {
-				d.injectEnv = make(map[string]string, len(raw))
-				for _, kv := range raw {
-					k, v, ok := strings.Cut(kv, "=")
-					if !ok || k == "" {
-						return fmt.Errorf("invalid --inject-env %q: expected KEY=VALUE", kv)
-					}
-					d.injectEnv[k] = v
-				}
-			}
 
 			if d.recordfmt != "typescript" && d.recordfmt != "asciicast" {
 				return fmt.Errorf("invalid screen recording format: %v", d.recordfmt)
@@ -514,9 +975,75 @@ func main() {
 					}
 				}
 
+				// before the policy, to audit the denied calls too
+				if auditFile := ctx.String("admin-grpc-audit-log"); auditFile != "" {
+					audit, err := admin.OpenAuditLog(auditFile)
+					if err != nil {
+						return err
+					}
+					defer audit.Close()
+					grpcOpts = append(grpcOpts,
+						grpc.ChainUnaryInterceptor(audit.UnaryServerInterceptor()),
+						grpc.ChainStreamInterceptor(audit.StreamServerInterceptor()),
+					)
+					slog.Info("auditing admin gRPC calls", "file", auditFile)
+				}
+
+				if policyFile := ctx.String("admin-grpc-policy-file"); policyFile != "" {
+					if adminCACert == "" {
+						return fmt.Errorf("--admin-grpc-policy-file requires --admin-grpc-tls-cacert to identify the clients")
+					}
+					policy, err := admin.LoadPolicy(policyFile)
+					if err != nil {
+						return err
+					}
+					grpcOpts = append(grpcOpts,
+						grpc.ChainUnaryInterceptor(policy.UnaryServerInterceptor()),
+						grpc.ChainStreamInterceptor(policy.StreamServerInterceptor()),
+					)
+					slog.Info("admin gRPC API enforces roles", "policy", policyFile)
+				}
+
 				d.adminRegistry = admin.NewRegistry()
 				adminSrv := admin.NewServer(d.adminRegistry, ctx.String("admin-grpc-id"), version(), d.lis.Addr().String())
 				grpcSrv := grpc.NewServer(grpcOpts...)
+				adminSrv.SetHostKeyReloader(func() (*libadmin.ReloadHostKeysResponse, error) {
+					st, err := d.reloadHostKeys()
+					if err != nil {
+						return nil, err
+					}
+
+					resp := &libadmin.ReloadHostKeysResponse{
+						Fingerprints:         hostKeyFingerprints(st.current),
+						RetiringFingerprints: hostKeyFingerprints(st.previous),
+					}
+					if !st.retireAt.IsZero() {
+						resp.RetireAt = st.retireAt.Unix()
+					}
+					return resp, nil
+				})
+				adminSrv.SetPluginStatus(d.pluginStatus)
+				if ctx.Bool("admin-grpc-allow-attach") {
+					adminSrv.SetAllowAttach(true)
+					slog.Warn("admin gRPC clients may attach read-write to live sessions (--admin-grpc-allow-attach)")
+				}
+				if historyFile := ctx.String("admin-grpc-history-file"); historyFile != "" {
+					history, err := admin.OpenHistory(historyFile, ctx.Duration("admin-grpc-history-retention"))
+					if err != nil {
+						return err
+					}
+					defer history.Close()
+					d.history = history
+					adminSrv.SetHistory(history)
+					slog.Info("recording session history", "file", historyFile, "retention", ctx.Duration("admin-grpc-history-retention"))
+				}
+				// ahead of d.run, to serve the recordings through the same root
+				if err := d.initScreenRecording(); err != nil {
+					return err
+				}
+				if d.recordRoot != nil {
+					adminSrv.SetRecordings(d.recordRoot.FS(), d.usernameAsRecorddir)
+				}
 				adminSrv.Register(grpcSrv)
 				slog.Info("admin gRPC API listening", "address", adminLis.Addr().String())
 
@@ -534,6 +1061,10 @@ func main() {
 			return <-quit
 		},
 	}
+}
+
+func main() {
+	app := newApp()
 
 	if err := app.Run(os.Args); err != nil {
 		slog.Error("run failed", "error", err)
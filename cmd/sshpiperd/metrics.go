@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/status"
+)
+
+// metricsChannelTypes are the channel types counted by name, others are
+// counted as other to keep the label bounded.
+var metricsChannelTypes = map[string]bool{
+	"session":                           true,
+	"direct-tcpip":                      true,
+	"forwarded-tcpip":                   true,
+	"x11":                               true,
+	"auth-agent@openssh.com":            true,
+	"direct-streamlocal@openssh.com":    true,
+	"forwarded-streamlocal@openssh.com": true,
+}
+
+// daemonMetrics are the prometheus metrics of sshpiperd. None of the labels
+// carry addresses or user names, so their cardinality stays bounded. A nil
+// *daemonMetrics records nothing.
+type daemonMetrics struct {
+	handshakeDuration    *prometheus.HistogramVec
+	authAttempts         *prometheus.CounterVec
+	pipes                prometheus.Gauge
+	pipeBytes            *prometheus.CounterVec
+	channels             *prometheus.CounterVec
+	pluginRPCDuration    *prometheus.HistogramVec
+	pluginRPCErrors      *prometheus.CounterVec
+	recordingFailures    prometheus.Counter
+	forwardingRejections *prometheus.CounterVec
+}
+
+func newDaemonMetrics(reg prometheus.Registerer) *daemonMetrics {
+	m := &daemonMetrics{
+		handshakeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "sshpiperd",
+			Name:      "handshake_duration_seconds",
+			Help:      "Time from accepting a connection until the pipe to the upstream is established or failed, partitioned by result",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"result"}),
+		authAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sshpiperd",
+			Name:      "auth_attempts_total",
+			Help:      "Downstream authentication attempts partitioned by method and result",
+		}, []string{"method", "result"}),
+		pipes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "sshpiperd",
+			Subsystem: "pipe",
+			Name:      "open",
+			Help:      "Number of established pipes",
+		}),
+		pipeBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sshpiperd",
+			Subsystem: "pipe",
+			Name:      "bytes_total",
+			Help:      "Channel data bytes piped, partitioned by the side that sent them",
+		}, []string{"from"}),
+		channels: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sshpiperd",
+			Subsystem: "pipe",
+			Name:      "channels_opened_total",
+			Help:      "Channels opened through pipes partitioned by channel type and the side that opened them",
+		}, []string{"type", "from"}),
+		pluginRPCDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "sshpiperd",
+			Subsystem: "plugin",
+			Name:      "rpc_duration_seconds",
+			Help:      "Latency of the grpc calls to plugins partitioned by plugin and rpc",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"plugin", "rpc"}),
+		pluginRPCErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sshpiperd",
+			Subsystem: "plugin",
+			Name:      "rpc_errors_total",
+			Help:      "Failed grpc calls to plugins partitioned by plugin, rpc and grpc status code, circuit_open for calls failed by the circuit breaker",
+		}, []string{"plugin", "rpc", "code"}),
+		recordingFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "sshpiperd",
+			Subsystem: "recording",
+			Name:      "failures_total",
+			Help:      "Connections refused because their screen recording could not be set up",
+		}),
+		forwardingRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sshpiperd",
+			Subsystem: "forwarding",
+			Name:      "rejections_total",
+			Help:      "Forwarding requests rejected by the --disable-*-forwarding flags or the session policy of the connection, partitioned by kind",
+		}, []string{"kind"}),
+	}
+
+	reg.MustRegister(
+		m.handshakeDuration,
+		m.authAttempts,
+		m.pipes,
+		m.pipeBytes,
+		m.channels,
+		m.pluginRPCDuration,
+		m.pluginRPCErrors,
+		m.recordingFailures,
+		m.forwardingRejections,
+	)
+
+	return m
+}
+
+// metricsHandler serves the metrics of reg, with the go runtime and process
+// metrics.
+func metricsHandler(reg *prometheus.Registry) http.Handler {
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+// httpReadTimeout bounds reading a request of the metrics and health
+// endpoints, so that slow clients do not hold their connections open.
+const httpReadTimeout = 10 * time.Second
+
+// serveHTTP serves mux, the metrics and health endpoints, on address.
+func serveHTTP(address string, mux *http.ServeMux) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: httpReadTimeout,
+		ReadTimeout:       httpReadTimeout,
+	}
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			slog.Error("http server error", "error", err)
+		}
+	}()
+
+	slog.Info("http server is listening", "address", lis.Addr().String())
+	return nil
+}
+
+func (m *daemonMetrics) handshakeDone(start time.Time, result string) {
+	if m == nil {
+		return
+	}
+
+	m.handshakeDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+func (m *daemonMetrics) pipeOpened() {
+	if m == nil {
+		return
+	}
+
+	m.pipes.Inc()
+}
+
+func (m *daemonMetrics) pipeClosed() {
+	if m == nil {
+		return
+	}
+
+	m.pipes.Dec()
+}
+
+func (m *daemonMetrics) recordingFailed() {
+	if m == nil {
+		return
+	}
+
+	m.recordingFailures.Inc()
+}
+
+func (m *daemonMetrics) forwardingRejected(kind string) {
+	if m == nil {
+		return
+	}
+
+	m.forwardingRejections.WithLabelValues(kind).Inc()
+}
+
+// msgChannelExtendedData is SSH_MSG_CHANNEL_EXTENDED_DATA, stderr of a
+// session.
+const msgChannelExtendedData = 95
+
+// channelDataLen returns the length of the data carried by a
+// SSH_MSG_CHANNEL_DATA or SSH_MSG_CHANNEL_EXTENDED_DATA packet, 0 for any
+// other packet.
+func channelDataLen(packet []byte) int {
+	// type, recipient channel and data length
+	header := 9
+	switch {
+	case len(packet) == 0:
+		return 0
+	case packet[0] == msgChannelData:
+	case packet[0] == msgChannelExtendedData:
+		// data type code
+		header += 4
+	default:
+		return 0
+	}
+
+	if len(packet) < header {
+		return 0
+	}
+
+	return len(packet) - header
+}
+
+// pipeHook counts the channel data bytes and opened channels of the packets
+// sent by from, downstream or upstream. It must be the last hook of the
+// chain, to only count what is forwarded.
+func (m *daemonMetrics) pipeHook(from string) ssh.PipePacketHook {
+	if m == nil {
+		return nil
+	}
+
+	sent := m.pipeBytes.WithLabelValues(from)
+
+	return func(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
+		if n := channelDataLen(packet); n > 0 {
+			sent.Add(float64(n))
+		}
+
+		if len(packet) > 0 && packet[0] == msgChannelOpen {
+			var open channelOpen
+			if err := ssh.Unmarshal(packet, &open); err == nil {
+				typ := open.Type
+				if !metricsChannelTypes[typ] {
+					typ = "other"
+				}
+				m.channels.WithLabelValues(typ, from).Inc()
+			}
+		}
+
+		return ssh.PipePacketHookTransform, packet, nil
+	}
+}
+
+// pluginRPC returns the plugin.GrpcPlugin OnRPC callback of the plugin name.
+func (m *daemonMetrics) pluginRPC(name string) func(method string, latency time.Duration, err error) {
+	if m == nil {
+		return nil
+	}
+
+	return func(method string, latency time.Duration, err error) {
+		if errors.Is(err, plugin.ErrCircuitOpen) {
+			m.pluginRPCErrors.WithLabelValues(name, method, "circuit_open").Inc()
+			return
+		}
+
+		m.pluginRPCDuration.WithLabelValues(name, method).Observe(latency.Seconds())
+		if err != nil {
+			m.pluginRPCErrors.WithLabelValues(name, method, status.Code(err).String()).Inc()
+		}
+	}
+}
+
+func authResult(u *ssh.Upstream, err error) string {
+	switch {
+	case err != nil:
+		return "failure"
+	case u == nil:
+		return "next_plugin"
+	}
+
+	return "success"
+}
+
+// instrumentAuth counts the authentication attempts going through the auth
+// callbacks of config.
+func (m *daemonMetrics) instrumentAuth(config *plugin.GrpcPluginConfig) {
+	if m == nil {
+		return
+	}
+
+	if cb := config.NoClientAuthCallback; cb != nil {
+		config.NoClientAuthCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+			u, err := cb(conn, challengeCtx)
+			m.authAttempts.WithLabelValues("none", authResult(u, err)).Inc()
+			return u, err
+		}
+	}
+
+	if cb := config.PasswordCallback; cb != nil {
+		config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+			u, err := cb(conn, password, challengeCtx)
+			m.authAttempts.WithLabelValues("password", authResult(u, err)).Inc()
+			return u, err
+		}
+	}
+
+	if cb := config.PublicKeyCallback; cb != nil {
+		config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+			u, err := cb(conn, key, challengeCtx)
+			m.authAttempts.WithLabelValues("publickey", authResult(u, err)).Inc()
+			return u, err
+		}
+	}
+
+	if cb := config.KeyboardInteractiveCallback; cb != nil {
+		config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+			u, err := cb(conn, client, challengeCtx)
+			m.authAttempts.WithLabelValues("keyboard-interactive", authResult(u, err)).Inc()
+			return u, err
+		}
+	}
+}
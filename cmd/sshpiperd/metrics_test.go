@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDaemonMetricsNil(t *testing.T) {
+	var m *daemonMetrics
+
+	m.handshakeDone(time.Now(), "success")
+	m.pipeOpened()
+	m.pipeClosed()
+	m.recordingFailed()
+	m.forwardingRejected("local")
+
+	if m.pipeHook("downstream") != nil {
+		t.Error("expected nil pipe hook")
+	}
+	if m.pluginRPC("plugin") != nil {
+		t.Error("expected nil rpc callback")
+	}
+
+	config := &plugin.GrpcPluginConfig{}
+	m.instrumentAuth(config)
+	if config.PasswordCallback != nil {
+		t.Error("expected callbacks to be left unset")
+	}
+}
+
+func TestDaemonMetricsPipeHook(t *testing.T) {
+	m := newDaemonMetrics(prometheus.NewRegistry())
+	hook := m.pipeHook("downstream")
+
+	for _, packet := range [][]byte{
+		ssh.Marshal(channelOpen{Type: "session", SenderChannel: 1}),
+		ssh.Marshal(channelOpen{Type: "direct-tcpip", SenderChannel: 2}),
+		ssh.Marshal(channelOpen{Type: "custom@example.com", SenderChannel: 3}),
+		{msgChannelOpen},
+		{94, 0, 0, 0, 0},
+		{msgChannelData, 0, 0, 0, 1, 0, 0, 0, 3, 'a', 'b', 'c'},
+		{msgChannelExtendedData, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 2, 'e', 'r'},
+	} {
+		method, out, err := hook(packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if method != ssh.PipePacketHookTransform || len(out) != len(packet) {
+			t.Fatalf("expected packet to pass through unchanged, got %v", method)
+		}
+	}
+
+	for typ, want := range map[string]float64{"session": 1, "direct-tcpip": 1, "other": 1} {
+		if got := testutil.ToFloat64(m.channels.WithLabelValues(typ, "downstream")); got != want {
+			t.Errorf("channels %s = %v, want %v", typ, got, want)
+		}
+	}
+
+	if got := testutil.ToFloat64(m.pipeBytes.WithLabelValues("downstream")); got != 5 {
+		t.Errorf("expected only the channel data bytes to be counted, got %v", got)
+	}
+}
+
+func TestDaemonMetricsAuth(t *testing.T) {
+	m := newDaemonMetrics(prometheus.NewRegistry())
+
+	var upstream *ssh.Upstream
+	var err error
+
+	config := &plugin.GrpcPluginConfig{
+		PiperConfig: ssh.PiperConfig{
+			PasswordCallback: func(ssh.ConnMetadata, []byte, ssh.ChallengeContext) (*ssh.Upstream, error) {
+				return upstream, err
+			},
+		},
+	}
+	m.instrumentAuth(config)
+
+	if config.PublicKeyCallback != nil {
+		t.Error("expected unset callbacks to stay unset")
+	}
+
+	upstream = &ssh.Upstream{}
+	_, _ = config.PasswordCallback(nil, nil, nil)
+
+	upstream = nil
+	_, _ = config.PasswordCallback(nil, nil, nil)
+
+	err = errors.New("denied")
+	_, _ = config.PasswordCallback(nil, nil, nil)
+	_, _ = config.PasswordCallback(nil, nil, nil)
+
+	for result, want := range map[string]float64{"success": 1, "next_plugin": 1, "failure": 2} {
+		if got := testutil.ToFloat64(m.authAttempts.WithLabelValues("password", result)); got != want {
+			t.Errorf("password %s = %v, want %v", result, got, want)
+		}
+	}
+}
+
+func TestDaemonMetricsPluginRPC(t *testing.T) {
+	m := newDaemonMetrics(prometheus.NewRegistry())
+	onRPC := m.pluginRPC("kubernetes")
+
+	onRPC("PasswordCallback", time.Millisecond, nil)
+	onRPC("PasswordCallback", time.Millisecond, status.Error(codes.Unavailable, "down"))
+	onRPC("PasswordCallback", 0, plugin.ErrCircuitOpen)
+
+	if got := testutil.CollectAndCount(m.pluginRPCDuration); got != 1 {
+		t.Errorf("rpc duration series = %d, want 1", got)
+	}
+
+	for code, want := range map[string]float64{"Unavailable": 1, "circuit_open": 1} {
+		if got := testutil.ToFloat64(m.pluginRPCErrors.WithLabelValues("kubernetes", "PasswordCallback", code)); got != want {
+			t.Errorf("rpc errors %s = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestDaemonMetricsForwardingRejections(t *testing.T) {
+	m := newDaemonMetrics(prometheus.NewRegistry())
+
+	filter := newForwardingFilter(true, true)
+	filter.onReject = m.forwardingRejected
+
+	if _, _, err := filter.down(ssh.Marshal(globalRequest{Type: "tcpip-forward", WantReply: true})); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := filter.down(ssh.Marshal(channelOpen{Type: "direct-tcpip", SenderChannel: 1})); err != nil {
+		t.Fatal(err)
+	}
+
+	for kind, want := range map[string]float64{"remote": 1, "local": 1} {
+		if got := testutil.ToFloat64(m.forwardingRejections.WithLabelValues(kind)); got != want {
+			t.Errorf("forwarding rejections %s = %v, want %v", kind, got, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+)
+
+type channelRequest struct {
+	RecipientChannel uint32 `sshtype:"98"`
+	Request          string
+	WantReply        bool
+	Data             []byte `ssh:"rest"`
+}
+
+// maxPipeStatsEntries caps the exec commands and exit statuses kept per
+// pipe, a client running commands in a loop must not grow them unbounded.
+const maxPipeStatsEntries = 100
+
+// pipeStats collects the libplugin.PipeStats of a pipe from the packets
+// piped in both directions. Its hooks must be the last of their chains, to
+// only count what is forwarded.
+type pipeStats struct {
+	mu    sync.Mutex
+	stats libplugin.PipeStats
+}
+
+func newPipeStats(start time.Time) *pipeStats {
+	return &pipeStats{
+		stats: libplugin.PipeStats{
+			StartedAt: start.UnixMilli(),
+			Channels:  map[string]int64{},
+		},
+	}
+}
+
+// hook returns the hook of the packets sent by from, downstream or
+// upstream.
+func (s *pipeStats) hook(from string) ssh.PipePacketHook {
+	return func(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
+		s.observe(from, packet)
+		return ssh.PipePacketHookTransform, packet, nil
+	}
+}
+
+func (s *pipeStats) observe(from string, packet []byte) {
+	if len(packet) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch packet[0] {
+	case msgChannelData, msgChannelExtendedData:
+		n := int64(channelDataLen(packet))
+		if from == "downstream" {
+			s.stats.DownstreamBytes += n
+		} else {
+			s.stats.UpstreamBytes += n
+		}
+	case msgChannelOpen:
+		var open channelOpen
+		if err := ssh.Unmarshal(packet, &open); err == nil {
+			s.stats.Channels[open.Type]++
+		}
+	case msgChannelRequest:
+		var req channelRequest
+		if err := ssh.Unmarshal(packet, &req); err != nil {
+			return
+		}
+
+		switch {
+		case req.Request == "exec" && from == "downstream":
+			var exec struct{ Command string }
+			if err := ssh.Unmarshal(req.Data, &exec); err != nil {
+				return
+			}
+			if len(s.stats.ExecCommands) < maxPipeStatsEntries {
+				s.stats.ExecCommands = append(s.stats.ExecCommands, exec.Command)
+			} else {
+				s.stats.DroppedExecCommands++
+			}
+		case req.Request == "exit-status" && from == "upstream" && len(req.Data) >= 4:
+			if len(s.stats.ExitStatuses) < maxPipeStatsEntries {
+				s.stats.ExitStatuses = append(s.stats.ExitStatuses, binary.BigEndian.Uint32(req.Data))
+			} else {
+				s.stats.DroppedExitStatuses++
+			}
+		}
+	}
+}
+
+// end returns the statistics of the pipe closed at end with err, the error
+// returned by Wait.
+func (s *pipeStats) end(end time.Time, err error) *libplugin.PipeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := &libplugin.PipeStats{
+		StartedAt:       s.stats.StartedAt,
+		EndedAt:         end.UnixMilli(),
+		DownstreamBytes: s.stats.DownstreamBytes,
+		UpstreamBytes:   s.stats.UpstreamBytes,
+		Channels:        make(map[string]int64, len(s.stats.Channels)),
+		ExecCommands:    append([]string(nil), s.stats.ExecCommands...),
+		ExitStatuses:    append([]uint32(nil), s.stats.ExitStatuses...),
+
+		DroppedExecCommands: s.stats.DroppedExecCommands,
+		DroppedExitStatuses: s.stats.DroppedExitStatuses,
+	}
+	for typ, n := range s.stats.Channels {
+		stats.Channels[typ] = n
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		stats.CloseReason = err.Error()
+	}
+
+	return stats
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestPipeStats(t *testing.T) {
+	start := time.UnixMilli(1000)
+	s := newPipeStats(start)
+	down := s.hook("downstream")
+	up := s.hook("upstream")
+
+	exec := ssh.Marshal(channelRequest{RecipientChannel: 0, Request: "exec", WantReply: true, Data: ssh.Marshal(struct{ Command string }{"uptime"})})
+	exitStatus := ssh.Marshal(channelRequest{RecipientChannel: 0, Request: "exit-status", Data: []byte{0, 0, 0, 2}})
+
+	for _, tt := range []struct {
+		hook   ssh.PipePacketHook
+		packet []byte
+	}{
+		{down, ssh.Marshal(channelOpen{Type: "session", SenderChannel: 0})},
+		{down, ssh.Marshal(channelOpen{Type: "direct-tcpip", SenderChannel: 1})},
+		{up, ssh.Marshal(channelOpen{Type: "forwarded-tcpip", SenderChannel: 0})},
+		{down, exec},
+		{down, []byte{msgChannelData, 0, 0, 0, 0, 0, 0, 0, 2, 'h', 'i'}},
+		{up, []byte{msgChannelData, 0, 0, 0, 0, 0, 0, 0, 3, 'a', 'b', 'c'}},
+		{up, []byte{msgChannelExtendedData, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 'e'}},
+		{up, exitStatus},
+		// exec requests are only sent by the client
+		{up, exec},
+		{down, nil},
+	} {
+		method, out, err := tt.hook(tt.packet)
+		if err != nil || method != ssh.PipePacketHookTransform || len(out) != len(tt.packet) {
+			t.Fatalf("expected packet to pass through unchanged, got %v, %v", method, err)
+		}
+	}
+
+	stats := s.end(time.UnixMilli(3000), errors.New("killed"))
+
+	if stats.GetStartedAt() != 1000 || stats.GetEndedAt() != 3000 {
+		t.Errorf("unexpected times %v to %v", stats.GetStartedAt(), stats.GetEndedAt())
+	}
+	if stats.GetDownstreamBytes() != 2 || stats.GetUpstreamBytes() != 4 {
+		t.Errorf("unexpected bytes %v down, %v up", stats.GetDownstreamBytes(), stats.GetUpstreamBytes())
+	}
+	if want := map[string]int64{"session": 1, "direct-tcpip": 1, "forwarded-tcpip": 1}; !reflect.DeepEqual(stats.GetChannels(), want) {
+		t.Errorf("channels = %v, want %v", stats.GetChannels(), want)
+	}
+	if !reflect.DeepEqual(stats.GetExecCommands(), []string{"uptime"}) {
+		t.Errorf("exec commands = %v", stats.GetExecCommands())
+	}
+	if !reflect.DeepEqual(stats.GetExitStatuses(), []uint32{2}) {
+		t.Errorf("exit statuses = %v", stats.GetExitStatuses())
+	}
+	if stats.GetCloseReason() != "killed" {
+		t.Errorf("close reason = %q", stats.GetCloseReason())
+	}
+
+	if reason := s.end(time.Now(), io.EOF).GetCloseReason(); reason != "" {
+		t.Errorf("expected no close reason for a clean close, got %q", reason)
+	}
+}
+
+func TestPipeStatsCapsCommands(t *testing.T) {
+	s := newPipeStats(time.Now())
+	down := s.hook("downstream")
+	up := s.hook("upstream")
+
+	exec := ssh.Marshal(channelRequest{Request: "exec", WantReply: true, Data: ssh.Marshal(struct{ Command string }{"true"})})
+	exitStatus := ssh.Marshal(channelRequest{Request: "exit-status", Data: []byte{0, 0, 0, 0}})
+	for i := 0; i < maxPipeStatsEntries+5; i++ {
+		_, _, _ = down(exec)
+		_, _, _ = up(exitStatus)
+	}
+
+	stats := s.end(time.Now(), nil)
+	if len(stats.GetExecCommands()) != maxPipeStatsEntries || stats.GetDroppedExecCommands() != 5 {
+		t.Errorf("got %d exec commands, %d dropped", len(stats.GetExecCommands()), stats.GetDroppedExecCommands())
+	}
+	if len(stats.GetExitStatuses()) != maxPipeStatsEntries || stats.GetDroppedExitStatuses() != 5 {
+		t.Errorf("got %d exit statuses, %d dropped", len(stats.GetExitStatuses()), stats.GetDroppedExitStatuses())
+	}
+}
@@ -25,13 +25,13 @@ func wireRecordingPiper(t *testing.T, p *ssh.PiperConn, d *daemon) {
 	uphookchain := &hookChain{}
 	downhookchain := &hookChain{}
 
-	closeRecorder, ok := d.setupScreenRecording(p, uphookchain, downhookchain)
+	recorder, ok := d.setupScreenRecording(p, d.recordfmt, uphookchain, downhookchain)
 	if !ok {
 		t.Logf("screen recording setup rejected connection for user %q", p.DownstreamConnMeta().User())
 		return
 	}
-	if closeRecorder != nil {
-		defer closeRecorder()
+	if recorder != nil {
+		defer recorder.Close()
 	}
 
 	_ = p.WaitWithHook(uphookchain.hook(), downhookchain.hook())
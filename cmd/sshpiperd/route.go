@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"golang.org/x/crypto/ssh"
+)
+
+// pluginRoute sends the connections it matches through its own plugin
+// chain instead of all plugins.
+type pluginRoute struct {
+	name    string
+	match   routeMatch
+	plugins []*plugin.GrpcPlugin
+}
+
+// routeMatch are the conditions of a route. Every condition that is set
+// must hold, any value of a condition may match. An empty routeMatch
+// matches every connection.
+type routeMatch struct {
+	sources        []netip.Prefix
+	listeners      []listenerAddr
+	clientVersions []string
+	proxyTLVs      map[proxyproto.PP2Type][]byte
+}
+
+// listenerAddr is a local address, an invalid ip or an empty port matches
+// any.
+type listenerAddr struct {
+	ip   netip.Addr
+	port string
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+func parseListenerAddr(s string) (listenerAddr, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		// no port
+		host, port = s, ""
+	}
+
+	var l listenerAddr
+
+	if port != "" {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return l, fmt.Errorf("invalid listener port %q", port)
+		}
+		l.port = port
+	}
+
+	if host != "" {
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			return l, fmt.Errorf("invalid listener address %q: %w", s, err)
+		}
+		l.ip = ip.Unmap()
+	}
+
+	return l, nil
+}
+
+func (l listenerAddr) matches(addr netip.AddrPort) bool {
+	if l.ip.IsValid() && l.ip != addr.Addr().Unmap() {
+		return false
+	}
+
+	return l.port == "" || l.port == strconv.Itoa(int(addr.Port()))
+}
+
+func newRouteMatch(c *routeMatchConfig) (routeMatch, error) {
+	var m routeMatch
+
+	if c == nil {
+		return m, nil
+	}
+
+	for _, s := range c.Source {
+		prefix, err := parsePrefix(s)
+		if err != nil {
+			return m, fmt.Errorf("invalid source %q: %w", s, err)
+		}
+		m.sources = append(m.sources, prefix)
+	}
+
+	for _, s := range c.Listener {
+		l, err := parseListenerAddr(s)
+		if err != nil {
+			return m, err
+		}
+		m.listeners = append(m.listeners, l)
+	}
+
+	for _, pattern := range c.ClientVersion {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return m, fmt.Errorf("invalid client-version pattern %q: %w", pattern, err)
+		}
+		m.clientVersions = append(m.clientVersions, pattern)
+	}
+
+	for t, v := range c.ProxyTLV {
+		typ, err := strconv.ParseUint(t, 0, 8)
+		if err != nil {
+			return m, fmt.Errorf("invalid proxy-tlv type %q: %w", t, err)
+		}
+
+		if m.proxyTLVs == nil {
+			m.proxyTLVs = make(map[proxyproto.PP2Type][]byte)
+		}
+		m.proxyTLVs[proxyproto.PP2Type(typ)] = []byte(v)
+	}
+
+	return m, nil
+}
+
+func addrPort(addr net.Addr) (netip.AddrPort, bool) {
+	if addr == nil {
+		return netip.AddrPort{}, false
+	}
+
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.AddrPort{}, false
+	}
+
+	return ap, true
+}
+
+// matches reports whether the connection c, with conn its ssh handshake so
+// far, is sent through the route. The source is the client address, taken
+// from the PROXY header when there is one, the listener is the address c
+// was accepted on.
+func (m *routeMatch) matches(c net.Conn, conn ssh.ConnMetadata) bool {
+	var header *proxyproto.Header
+
+	raw := c
+	if pc, ok := c.(*proxyproto.Conn); ok {
+		raw = pc.Raw()
+		header = pc.ProxyHeader()
+	}
+
+	if len(m.sources) > 0 {
+		source, ok := addrPort(conn.RemoteAddr())
+		if !ok || !slices.ContainsFunc(m.sources, func(p netip.Prefix) bool { return p.Contains(source.Addr().Unmap()) }) {
+			return false
+		}
+	}
+
+	if len(m.listeners) > 0 {
+		local, ok := addrPort(raw.LocalAddr())
+		if !ok || !slices.ContainsFunc(m.listeners, func(l listenerAddr) bool { return l.matches(local) }) {
+			return false
+		}
+	}
+
+	if len(m.clientVersions) > 0 {
+		version := string(conn.ClientVersion())
+		if !slices.ContainsFunc(m.clientVersions, func(pattern string) bool {
+			ok, _ := path.Match(pattern, version)
+			return ok
+		}) {
+			return false
+		}
+	}
+
+	if len(m.proxyTLVs) > 0 {
+		if header == nil {
+			return false
+		}
+
+		tlvs, err := header.TLVs()
+		if err != nil {
+			slog.Debug("cannot parse proxy header tlvs", "remote_addr", conn.RemoteAddr(), "error", err)
+			return false
+		}
+
+		for typ, value := range m.proxyTLVs {
+			if !slices.ContainsFunc(tlvs, func(tlv proxyproto.TLV) bool { return tlv.Type == typ && bytes.Equal(tlv.Value, value) }) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pires/go-proxyproto"
+)
+
+type routeTestConnMeta struct {
+	remoteAddr    net.Addr
+	clientVersion string
+}
+
+func (m routeTestConnMeta) User() string          { return "user" }
+func (m routeTestConnMeta) SessionID() []byte     { return nil }
+func (m routeTestConnMeta) ClientVersion() []byte { return []byte(m.clientVersion) }
+func (m routeTestConnMeta) ServerVersion() []byte { return nil }
+func (m routeTestConnMeta) RemoteAddr() net.Addr  { return m.remoteAddr }
+func (m routeTestConnMeta) LocalAddr() net.Addr   { return nil }
+
+// acceptConn accepts a loopback connection, through the PROXY protocol when
+// header is set.
+func acceptConn(t *testing.T, header *proxyproto.Header) net.Conn {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	client, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	if header == nil {
+		conn, err := lis.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		return conn
+	}
+
+	if _, err := header.WriteTo(client); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := (&proxyproto.Listener{Listener: lis}).Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestRouteMatch(t *testing.T) {
+	header := proxyproto.HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 50000},
+		&net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 22},
+	)
+	if err := header.SetTLVs([]proxyproto.TLV{{Type: 0xE0, Value: []byte("internal")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	proxied := acceptConn(t, header)
+	direct := acceptConn(t, nil)
+
+	proxiedMeta := routeTestConnMeta{remoteAddr: proxied.RemoteAddr(), clientVersion: "SSH-2.0-OpenSSH_9.6"}
+	directMeta := routeTestConnMeta{remoteAddr: direct.RemoteAddr(), clientVersion: "SSH-2.0-PuTTY_Release_0.80"}
+
+	_, directPort, err := net.SplitHostPort(direct.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		match    routeMatchConfig
+		proxied  bool
+		direct   bool
+		parseErr bool
+	}{
+		{name: "empty", proxied: true, direct: true},
+		{name: "source cidr", match: routeMatchConfig{Source: []string{"10.0.0.0/8"}}, proxied: true},
+		{name: "source ip", match: routeMatchConfig{Source: []string{"192.0.2.1", "127.0.0.1"}}, direct: true},
+		{name: "listener ip", match: routeMatchConfig{Listener: []string{"127.0.0.1"}}, proxied: true, direct: true},
+		{name: "listener port", match: routeMatchConfig{Listener: []string{":" + directPort}}, direct: true},
+		{name: "listener other ip", match: routeMatchConfig{Listener: []string{"192.168.0.1:22"}}},
+		{name: "client version", match: routeMatchConfig{ClientVersion: []string{"SSH-2.0-OpenSSH_*"}}, proxied: true},
+		{name: "proxy tlv", match: routeMatchConfig{ProxyTLV: map[string]string{"0xE0": "internal"}}, proxied: true},
+		{name: "proxy tlv value", match: routeMatchConfig{ProxyTLV: map[string]string{"224": "external"}}},
+		{name: "all conditions", match: routeMatchConfig{Source: []string{"10.0.0.0/8"}, ClientVersion: []string{"SSH-2.0-PuTTY*"}}},
+		{name: "invalid source", match: routeMatchConfig{Source: []string{"10.0.0.0/33"}}, parseErr: true},
+		{name: "invalid listener", match: routeMatchConfig{Listener: []string{"localhost:22"}}, parseErr: true},
+		{name: "invalid pattern", match: routeMatchConfig{ClientVersion: []string{"SSH-["}}, parseErr: true},
+		{name: "invalid tlv type", match: routeMatchConfig{ProxyTLV: map[string]string{"0x100": "x"}}, parseErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newRouteMatch(&tt.match)
+			if tt.parseErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := m.matches(proxied, proxiedMeta); got != tt.proxied {
+				t.Errorf("proxied connection match = %v, want %v", got, tt.proxied)
+			}
+			if got := m.matches(direct, directMeta); got != tt.direct {
+				t.Errorf("direct connection match = %v, want %v", got, tt.direct)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+)
+
+const msgChannelWindowAdjust = 93
+
+// sessionPolicy is the policy a pipe runs with, the daemon flags overridden
+// by the libplugin.SessionPolicy of the plugin that authenticated it.
+type sessionPolicy struct {
+	// recordFormat is the screen recording format, empty to not record.
+	recordFormat string
+
+	disableLocalForward  bool
+	disableRemoteForward bool
+	disableAgentForward  bool
+	disableX11Forward    bool
+
+	idleTimeout    time.Duration
+	maxDuration    time.Duration
+	bandwidthLimit uint64
+
+	postAuthMessage string
+}
+
+// sessionPolicy merges the session policy set by the plugin, nil when none,
+// into the defaults of opts and the screen recording flags.
+func (d *daemon) sessionPolicy(opts connOptions, p *libplugin.SessionPolicy) sessionPolicy {
+	policy := sessionPolicy{
+		disableLocalForward:  opts.disableLocalForward,
+		disableRemoteForward: opts.disableRemoteForward,
+		disableAgentForward:  opts.disableAgentForward,
+		disableX11Forward:    opts.disableX11Forward,
+		idleTimeout:          opts.idleTimeout,
+		maxDuration:          opts.maxDuration,
+		bandwidthLimit:       opts.bandwidthLimit,
+	}
+	if d.recordRoot != nil {
+		policy.recordFormat = d.recordfmt
+	}
+
+	if p == nil {
+		return policy
+	}
+
+	switch format := p.GetRecordingFormat(); format {
+	case "":
+	case "asciicast", "typescript":
+		if policy.recordFormat != "" {
+			policy.recordFormat = format
+		}
+	default:
+		slog.Warn("ignoring invalid recording format in session policy", "format", format)
+	}
+
+	if p.Recording != nil {
+		switch {
+		case !p.GetRecording():
+			policy.recordFormat = ""
+		case d.recordRoot == nil:
+			slog.Warn("session policy asks for recording, but --screen-recording-dir is not set")
+		}
+	}
+
+	if p.LocalForwarding != nil {
+		policy.disableLocalForward = !p.GetLocalForwarding()
+	}
+	if p.RemoteForwarding != nil {
+		policy.disableRemoteForward = !p.GetRemoteForwarding()
+	}
+	if p.AgentForwarding != nil {
+		policy.disableAgentForward = !p.GetAgentForwarding()
+	}
+	if p.X11Forwarding != nil {
+		policy.disableX11Forward = !p.GetX11Forwarding()
+	}
+
+	if p.IdleTimeoutSeconds != nil {
+		policy.idleTimeout = time.Duration(p.GetIdleTimeoutSeconds()) * time.Second
+	}
+	if p.MaxDurationSeconds != nil {
+		policy.maxDuration = time.Duration(p.GetMaxDurationSeconds()) * time.Second
+	}
+	if p.BandwidthLimit != nil {
+		policy.bandwidthLimit = p.GetBandwidthLimit()
+	}
+
+	policy.postAuthMessage = p.GetPostAuthMessage()
+
+	return policy
+}
+
+var (
+	errSessionIdleTimeout = errors.New("session idle timeout")
+	errSessionMaxDuration = errors.New("session max duration reached")
+)
+
+// sessionLimits closes a pipe without channel data in either direction for
+// idleTimeout, or open for maxDuration. Its hook must come after the hooks
+// that may drop packets, to only count what is forwarded.
+type sessionLimits struct {
+	idleTimeout time.Duration
+	maxDuration time.Duration
+
+	mu           sync.Mutex
+	lastActivity time.Time
+	reason       error
+}
+
+func newSessionLimits(idleTimeout, maxDuration time.Duration, start time.Time) *sessionLimits {
+	return &sessionLimits{
+		idleTimeout:  idleTimeout,
+		maxDuration:  maxDuration,
+		lastActivity: start,
+	}
+}
+
+func (l *sessionLimits) hook(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
+	if len(packet) > 0 && (packet[0] == msgChannelData || packet[0] == msgChannelExtendedData) {
+		l.mu.Lock()
+		l.lastActivity = time.Now()
+		l.mu.Unlock()
+	}
+
+	return ssh.PipePacketHookTransform, packet, nil
+}
+
+// expired returns why the pipe must be closed at now, nil if it must not.
+func (l *sessionLimits) expired(start, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case l.maxDuration > 0 && now.Sub(start) >= l.maxDuration:
+		return errSessionMaxDuration
+	case l.idleTimeout > 0 && now.Sub(l.lastActivity) >= l.idleTimeout:
+		return errSessionIdleTimeout
+	}
+
+	return nil
+}
+
+// watch calls closePipe once a limit is reached, until done is closed.
+func (l *sessionLimits) watch(start time.Time, closePipe func(), done <-chan struct{}) {
+	interval := time.Second
+	for _, limit := range []time.Duration{l.idleTimeout / 4, l.maxDuration / 4} {
+		if limit > 0 && limit < interval {
+			interval = limit
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if err := l.expired(start, now); err != nil {
+				l.mu.Lock()
+				l.reason = err
+				l.mu.Unlock()
+
+				slog.Info("closing session", "reason", err)
+				closePipe()
+				return
+			}
+		}
+	}
+}
+
+// err returns the limit that closed the pipe, nil if none did.
+func (l *sessionLimits) err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.reason
+}
+
+// bandwidthLimiter delays the channel data of one direction of a pipe to
+// rate bytes per second, blocking the hook reading it.
+type bandwidthLimiter struct {
+	rate  uint64
+	sleep func(time.Duration)
+
+	// next is when the data already let through is paid for.
+	next time.Time
+}
+
+func newBandwidthLimiter(rate uint64) *bandwidthLimiter {
+	return &bandwidthLimiter{rate: rate, sleep: time.Sleep}
+}
+
+// delay returns how long to hold n bytes arriving at now.
+func (b *bandwidthLimiter) delay(n int, now time.Time) time.Duration {
+	wait := b.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+		b.next = now
+	}
+
+	b.next = b.next.Add(time.Duration(uint64(n) * uint64(time.Second) / b.rate))
+	return wait
+}
+
+func (b *bandwidthLimiter) hook(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
+	if n := channelDataLen(packet); n > 0 {
+		if wait := b.delay(n, time.Now()); wait > 0 {
+			b.sleep(wait)
+		}
+	}
+
+	return ssh.PipePacketHookTransform, packet, nil
+}
+
+type channelOpenConfirm struct {
+	RecipientChannel uint32 `sshtype:"91"`
+	SenderChannel    uint32
+	InitialWindow    uint32
+	MaximumPacket    uint32
+	TypeSpecificData []byte `ssh:"rest"`
+}
+
+type channelExtendedData struct {
+	RecipientChannel uint32 `sshtype:"95"`
+	DataTypeCode     uint32
+	Data             []byte
+}
+
+type channelWindowAdjust struct {
+	RecipientChannel uint32 `sshtype:"93"`
+	AdditionalBytes  uint32
+}
+
+// postAuthMessage writes message to the stderr of the first session
+// channel the client opens, right after the upstream server confirms it.
+//
+// The client counts the message against the window of the channel, which
+// the upstream server never sent. Its next window adjustments are reduced
+// by the size of the message, so both keep the same view of the window.
+type postAuthMessage struct {
+	writeDownstream func([]byte) error
+	message         []byte
+
+	mu sync.Mutex
+	// opening are the session channels opened by the client and not
+	// confirmed yet, by the channel id of the client.
+	opening map[uint32]channelOpen
+	sent    bool
+	// channel and deficit are the upstream channel id of the channel the
+	// message was sent to and the window adjustments still to reduce.
+	channel uint32
+	deficit uint32
+}
+
+func newPostAuthMessage(piper *ssh.PiperConn, message string) *postAuthMessage {
+	return &postAuthMessage{
+		writeDownstream: piper.WriteDownstreamPacket,
+		message:         []byte(message),
+		opening:         make(map[uint32]channelOpen),
+	}
+}
+
+// down handles the packets sent by the client.
+func (m *postAuthMessage) down(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
+	if len(packet) == 0 {
+		return ssh.PipePacketHookTransform, packet, nil
+	}
+
+	switch packet[0] {
+	case msgChannelOpen:
+		var open channelOpen
+		if err := ssh.Unmarshal(packet, &open); err != nil || open.Type != "session" {
+			break
+		}
+
+		m.mu.Lock()
+		if !m.sent {
+			m.opening[open.SenderChannel] = open
+		}
+		m.mu.Unlock()
+
+	case msgChannelWindowAdjust:
+		var adjust channelWindowAdjust
+		if err := ssh.Unmarshal(packet, &adjust); err != nil {
+			break
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.deficit == 0 || adjust.RecipientChannel != m.channel {
+			break
+		}
+
+		reduce := min(m.deficit, adjust.AdditionalBytes)
+		m.deficit -= reduce
+		adjust.AdditionalBytes -= reduce
+		if adjust.AdditionalBytes == 0 {
+			return ssh.PipePacketHookTransform, nil, nil
+		}
+		return ssh.PipePacketHookTransform, ssh.Marshal(adjust), nil
+	}
+
+	return ssh.PipePacketHookTransform, packet, nil
+}
+
+// up handles the packets sent by the upstream server.
+func (m *postAuthMessage) up(packet []byte) (ssh.PipePacketHookMethod, []byte, error) {
+	if len(packet) < 5 {
+		return ssh.PipePacketHookTransform, packet, nil
+	}
+
+	switch packet[0] {
+	case msgChannelOpenConfirm:
+		var confirm channelOpenConfirm
+		if err := ssh.Unmarshal(packet, &confirm); err != nil {
+			break
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		open, ok := m.opening[confirm.RecipientChannel]
+		if !ok || m.sent {
+			break
+		}
+		m.sent = true
+		m.opening = nil
+
+		message := m.message
+		// the maximum packet size counts the data only
+		if n := min(open.MaximumPacket, open.InitialWindow); uint32(len(message)) > n {
+			message = message[:n]
+		}
+
+		if err := m.writeDownstream(packet); err != nil {
+			return ssh.PipePacketHookTransform, nil, err
+		}
+
+		if len(message) > 0 {
+			if err := m.writeDownstream(ssh.Marshal(channelExtendedData{
+				RecipientChannel: confirm.RecipientChannel,
+				DataTypeCode:     1, // SSH_EXTENDED_DATA_STDERR
+				Data:             message,
+			})); err != nil {
+				return ssh.PipePacketHookTransform, nil, err
+			}
+		}
+
+		m.channel = confirm.SenderChannel
+		m.deficit = uint32(len(message))
+
+		return ssh.PipePacketHookTransform, nil, nil
+
+	case msgChannelOpenFailed:
+		m.mu.Lock()
+		delete(m.opening, binary.BigEndian.Uint32(packet[1:5]))
+		m.mu.Unlock()
+	}
+
+	return ssh.PipePacketHookTransform, packet, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tg123/sshpiper/libplugin"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSessionPolicyMerge(t *testing.T) {
+	root, err := os.OpenRoot(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	opts := connOptions{
+		disableRemoteForward: true,
+		idleTimeout:          time.Minute,
+		bandwidthLimit:       1000,
+	}
+
+	for _, tt := range []struct {
+		name   string
+		root   *os.Root
+		policy *libplugin.SessionPolicy
+		want   sessionPolicy
+	}{
+		{
+			name: "defaults",
+			root: root,
+			want: sessionPolicy{recordFormat: "asciicast", disableRemoteForward: true, idleTimeout: time.Minute, bandwidthLimit: 1000},
+		},
+		{
+			name:   "empty policy keeps defaults",
+			policy: &libplugin.SessionPolicy{},
+			want:   sessionPolicy{disableRemoteForward: true, idleTimeout: time.Minute, bandwidthLimit: 1000},
+		},
+		{
+			name: "overrides",
+			root: root,
+			policy: &libplugin.SessionPolicy{
+				RecordingFormat:    "typescript",
+				LocalForwarding:    proto.Bool(false),
+				RemoteForwarding:   proto.Bool(true),
+				AgentForwarding:    proto.Bool(false),
+				X11Forwarding:      proto.Bool(false),
+				IdleTimeoutSeconds: proto.Uint32(0),
+				MaxDurationSeconds: proto.Uint32(3600),
+				BandwidthLimit:     proto.Uint64(0),
+				PostAuthMessage:    "welcome",
+			},
+			want: sessionPolicy{
+				recordFormat:        "typescript",
+				disableLocalForward: true,
+				disableAgentForward: true,
+				disableX11Forward:   true,
+				maxDuration:         time.Hour,
+				postAuthMessage:     "welcome",
+			},
+		},
+		{
+			name:   "recording off",
+			root:   root,
+			policy: &libplugin.SessionPolicy{Recording: proto.Bool(false)},
+			want:   sessionPolicy{disableRemoteForward: true, idleTimeout: time.Minute, bandwidthLimit: 1000},
+		},
+		{
+			name:   "recording without recording dir",
+			policy: &libplugin.SessionPolicy{Recording: proto.Bool(true), RecordingFormat: "typescript"},
+			want:   sessionPolicy{disableRemoteForward: true, idleTimeout: time.Minute, bandwidthLimit: 1000},
+		},
+		{
+			name:   "invalid format",
+			root:   root,
+			policy: &libplugin.SessionPolicy{RecordingFormat: "mp4"},
+			want:   sessionPolicy{recordFormat: "asciicast", disableRemoteForward: true, idleTimeout: time.Minute, bandwidthLimit: 1000},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &daemon{recordfmt: "asciicast", recordRoot: tt.root}
+
+			if got := d.sessionPolicy(opts, tt.policy); got != tt.want {
+				t.Errorf("sessionPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionLimitsExpired(t *testing.T) {
+	start := time.Now()
+	l := newSessionLimits(time.Minute, time.Hour, start)
+
+	if err := l.expired(start, start.Add(59*time.Second)); err != nil {
+		t.Errorf("expected no limit reached, got %v", err)
+	}
+	if err := l.expired(start, start.Add(time.Minute)); err != errSessionIdleTimeout {
+		t.Errorf("expected idle timeout, got %v", err)
+	}
+
+	// only channel data counts as activity
+	l.hook([]byte{msgChannelWindowAdjust, 0, 0, 0, 0, 0, 0, 0, 1})
+	if err := l.expired(start, start.Add(time.Minute)); err != errSessionIdleTimeout {
+		t.Errorf("expected idle timeout, got %v", err)
+	}
+
+	l.hook([]byte{msgChannelData, 0, 0, 0, 0, 0, 0, 0, 1, 'a'})
+	if err := l.expired(start, time.Now().Add(59*time.Second)); err != nil {
+		t.Errorf("expected no limit reached after data, got %v", err)
+	}
+
+	if err := l.expired(start, start.Add(time.Hour)); err != errSessionMaxDuration {
+		t.Errorf("expected max duration, got %v", err)
+	}
+}
+
+func TestSessionLimitsWatch(t *testing.T) {
+	l := newSessionLimits(0, 20*time.Millisecond, time.Now())
+
+	closed := make(chan struct{})
+	go l.watch(time.Now(), func() { close(closed) }, make(chan struct{}))
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipe not closed")
+	}
+
+	if err := l.err(); err != errSessionMaxDuration {
+		t.Errorf("err() = %v, want %v", err, errSessionMaxDuration)
+	}
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	b := newBandwidthLimiter(100)
+	now := time.Unix(1000, 0)
+
+	if wait := b.delay(50, now); wait != 0 {
+		t.Errorf("first data delayed %v", wait)
+	}
+	if wait := b.delay(100, now.Add(100*time.Millisecond)); wait != 400*time.Millisecond {
+		t.Errorf("second data delayed %v, want 400ms", wait)
+	}
+	// idle time is not saved up
+	if wait := b.delay(10, now.Add(10*time.Second)); wait != 0 {
+		t.Errorf("data after idle delayed %v", wait)
+	}
+
+	var slept time.Duration
+	b = newBandwidthLimiter(1)
+	b.sleep = func(d time.Duration) { slept += d }
+	for range 2 {
+		b.hook([]byte{msgChannelData, 0, 0, 0, 0, 0, 0, 0, 1, 'a'})
+	}
+	b.hook([]byte{msgChannelWindowAdjust, 0, 0, 0, 0, 0, 0, 0, 1})
+	if slept <= 0 || slept > time.Second {
+		t.Errorf("slept %v, want up to 1s", slept)
+	}
+}
+
+func TestPostAuthMessage(t *testing.T) {
+	var written [][]byte
+	m := &postAuthMessage{
+		writeDownstream: func(packet []byte) error {
+			written = append(written, packet)
+			return nil
+		},
+		message: []byte("hello"),
+		opening: make(map[uint32]channelOpen),
+	}
+
+	pass := func(hook ssh.PipePacketHook, packet []byte) []byte {
+		t.Helper()
+		method, out, err := hook(packet)
+		if err != nil || method != ssh.PipePacketHookTransform {
+			t.Fatalf("unexpected %v, %v", method, err)
+		}
+		return out
+	}
+
+	// a channel that is not a session gets no message
+	pass(m.down, ssh.Marshal(channelOpen{Type: "direct-tcpip", SenderChannel: 1, InitialWindow: 100, MaximumPacket: 100}))
+	tcpConfirm := ssh.Marshal(channelOpenConfirm{RecipientChannel: 1, SenderChannel: 11})
+	if out := pass(m.up, tcpConfirm); !bytes.Equal(out, tcpConfirm) {
+		t.Fatalf("confirm of tcp channel changed to %v", out)
+	}
+
+	// truncated to the window of the client
+	pass(m.down, ssh.Marshal(channelOpen{Type: "session", SenderChannel: 2, InitialWindow: 4, MaximumPacket: 100}))
+	confirm := ssh.Marshal(channelOpenConfirm{RecipientChannel: 2, SenderChannel: 22})
+	if out := pass(m.up, confirm); out != nil {
+		t.Fatalf("expected confirm to be replaced, got %v", out)
+	}
+
+	want := [][]byte{confirm, ssh.Marshal(channelExtendedData{RecipientChannel: 2, DataTypeCode: 1, Data: []byte("hell")})}
+	if len(written) != 2 || !bytes.Equal(written[0], want[0]) || !bytes.Equal(written[1], want[1]) {
+		t.Fatalf("written = %v, want %v", written, want)
+	}
+
+	// only the first session
+	pass(m.down, ssh.Marshal(channelOpen{Type: "session", SenderChannel: 3, InitialWindow: 100, MaximumPacket: 100}))
+	if out := pass(m.up, ssh.Marshal(channelOpenConfirm{RecipientChannel: 3, SenderChannel: 33})); out == nil {
+		t.Fatal("confirm of second session dropped")
+	}
+
+	// the window adjustments of the client cover the message first
+	if out := pass(m.down, ssh.Marshal(channelWindowAdjust{RecipientChannel: 22, AdditionalBytes: 3})); out != nil {
+		t.Errorf("expected adjustment to be dropped, got %v", out)
+	}
+	if out := pass(m.down, ssh.Marshal(channelWindowAdjust{RecipientChannel: 33, AdditionalBytes: 3})); !bytes.Equal(out, ssh.Marshal(channelWindowAdjust{RecipientChannel: 33, AdditionalBytes: 3})) {
+		t.Errorf("adjustment of other channel changed to %v", out)
+	}
+	if out := pass(m.down, ssh.Marshal(channelWindowAdjust{RecipientChannel: 22, AdditionalBytes: 10})); !bytes.Equal(out, ssh.Marshal(channelWindowAdjust{RecipientChannel: 22, AdditionalBytes: 9})) {
+		t.Errorf("adjustment = %v, want 9 bytes", out)
+	}
+	if out := pass(m.down, ssh.Marshal(channelWindowAdjust{RecipientChannel: 22, AdditionalBytes: 10})); !bytes.Equal(out, ssh.Marshal(channelWindowAdjust{RecipientChannel: 22, AdditionalBytes: 10})) {
+		t.Errorf("adjustment = %v, want 10 bytes", out)
+	}
+}
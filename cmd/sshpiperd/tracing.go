@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/tg123/sshpiper/cmd/sshpiperd/internal/plugin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/ssh"
+)
+
+var tracer = otel.Tracer("github.com/tg123/sshpiper/cmd/sshpiperd")
+
+// tracingOptions are the --tracing-* flags.
+type tracingOptions struct {
+	exporter     string
+	otlpEndpoint string
+	otlpInsecure bool
+	file         string
+	sampleRatio  float64
+}
+
+// setupTracing installs the global tracer provider exporting to a OTLP
+// collector or to a file. shutdown flushes the pending spans.
+func setupTracing(opts tracingOptions) (shutdown func(context.Context) error, err error) {
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+
+	switch opts.exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.otlpEndpoint)}
+		if opts.otlpInsecure {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+
+		exporter, err = otlptracegrpc.New(context.Background(), clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create otlp trace exporter: %w", err)
+		}
+	case "file":
+		if opts.file == "" {
+			return nil, fmt.Errorf("--tracing-file is required by the file exporter")
+		}
+
+		file, err = os.OpenFile(opts.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, allowed: otlp, file", opts.exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("sshpiperd"),
+		semconv.ServiceVersion(version()),
+	))
+	if err != nil {
+		_ = exporter.Shutdown(context.Background())
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.sampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// connTrace is the handshake of a traced connection. The upstream auth span
+// starts when a plugin returns an upstream and ends when the upstream
+// rejects the auth or the handshake ends.
+type connTrace struct {
+	ctx context.Context
+
+	mu           sync.Mutex
+	upstreamAuth trace.Span
+}
+
+func (t *connTrace) startUpstreamAuth(conn ssh.ConnMetadata, u *ssh.Upstream) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.upstreamAuth != nil {
+		t.upstreamAuth.End()
+	}
+
+	_, t.upstreamAuth = tracer.Start(t.ctx, "upstream.auth", trace.WithAttributes(
+		attribute.String("upstream", u.Address),
+		attribute.String("upstream.user", u.ClientConfig.User),
+		attribute.String("downstream.user", conn.User()),
+	))
+}
+
+func (t *connTrace) endUpstreamAuth(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.upstreamAuth != nil {
+		endSpan(t.upstreamAuth, err)
+		t.upstreamAuth = nil
+	}
+}
+
+// authDone starts the upstream auth span when an auth callback returned an
+// upstream.
+func (t *connTrace) authDone(conn ssh.ConnMetadata, u *ssh.Upstream, err error) (*ssh.Upstream, error) {
+	if err == nil && u != nil {
+		t.startUpstreamAuth(conn, u)
+	}
+
+	return u, err
+}
+
+// instrument makes the callbacks of config, a per connection copy, trace
+// the plugin calls and the upstream auth under t.
+func (t *connTrace) instrument(config *ssh.PiperConfig) {
+	if cb := config.CreateChallengeContext; cb != nil {
+		config.CreateChallengeContext = func(conn ssh.ServerPreAuthConn) (ssh.ChallengeContext, error) {
+			return cb(plugin.WithTraceContext(conn, t.ctx))
+		}
+	}
+
+	if cb := config.NoClientAuthCallback; cb != nil {
+		config.NoClientAuthCallback = func(conn ssh.ConnMetadata, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+			u, err := cb(conn, challengeCtx)
+			return t.authDone(conn, u, err)
+		}
+	}
+
+	if cb := config.PasswordCallback; cb != nil {
+		config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+			u, err := cb(conn, password, challengeCtx)
+			return t.authDone(conn, u, err)
+		}
+	}
+
+	if cb := config.PublicKeyCallback; cb != nil {
+		config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+			u, err := cb(conn, key, challengeCtx)
+			return t.authDone(conn, u, err)
+		}
+	}
+
+	if cb := config.KeyboardInteractiveCallback; cb != nil {
+		config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge, challengeCtx ssh.ChallengeContext) (*ssh.Upstream, error) {
+			u, err := cb(conn, client, challengeCtx)
+			return t.authDone(conn, u, err)
+		}
+	}
+
+	cb := config.UpstreamAuthFailureCallback
+	config.UpstreamAuthFailureCallback = func(conn ssh.ConnMetadata, method string, err error, challengeCtx ssh.ChallengeContext) {
+		t.endUpstreamAuth(err)
+		if cb != nil {
+			cb(conn, method, err, challengeCtx)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSetupTracingInvalid(t *testing.T) {
+	if _, err := setupTracing(tracingOptions{exporter: "zipkin"}); err == nil {
+		t.Error("expected error for unknown exporter, got nil")
+	}
+	if _, err := setupTracing(tracingOptions{exporter: "file"}); err == nil {
+		t.Error("expected error for file exporter without file, got nil")
+	}
+}
+
+func TestConnTraceFileExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.json")
+
+	shutdown, err := setupTracing(tracingOptions{exporter: "file", file: file, sampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := tracer.Start(context.Background(), "sshpiperd.connection")
+	handshake := &connTrace{ctx: ctx}
+
+	config := &ssh.PiperConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte, ssh.ChallengeContext) (*ssh.Upstream, error) {
+			return &ssh.Upstream{Address: "upstream:22"}, nil
+		},
+	}
+	handshake.instrument(config)
+
+	if config.PublicKeyCallback != nil {
+		t.Error("expected unset callbacks to stay unset")
+	}
+
+	conn := routeTestConnMeta{}
+	if _, err := config.PasswordCallback(conn, []byte("pass"), nil); err != nil {
+		t.Fatal(err)
+	}
+	config.UpstreamAuthFailureCallback(conn, "password", errors.New("upstream rejected password"), nil)
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"Name":"sshpiperd.connection"`, `"Name":"upstream.auth"`, "upstream rejected password", span.SpanContext().TraceID().String()} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in traces, got %s", want, data)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,7 @@ const (
 type filePtyLogger struct {
 	typescript *os.File
 	timing     *os.File
+	files      []string
 
 	oldtime time.Time
 }
@@ -23,22 +26,26 @@ type filePtyLogger struct {
 // the configured screen recording root (see daemon.recordRoot); every file
 // this logger opens goes through root.OpenFile so that even if outputdir
 // contains a symlink planted by an attacker, the write cannot escape root.
-func newFilePtyLogger(root *os.Root, outputdir string) (*filePtyLogger, error) {
+// labels, the labels of the session, are appended to the header line like
+// script(1) does with its own metadata.
+func newFilePtyLogger(root *os.Root, outputdir string, labels map[string]string) (*filePtyLogger, error) {
 	now := time.Now()
 
 	filename := fmt.Sprintf("%d", now.Unix())
 
-	typescript, err := root.OpenFile(path.Join(outputdir, fmt.Sprintf("%v.typescript", filename)), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	files := []string{path.Join(outputdir, fmt.Sprintf("%v.typescript", filename)), path.Join(outputdir, fmt.Sprintf("%v.timing", filename))}
+
+	typescript, err := root.OpenFile(files[0], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = fmt.Fprintf(typescript, "Script started on %v\n", now.Format(time.ANSIC))
+	_, err = fmt.Fprintf(typescript, "Script started on %v%s\n", now.Format(time.ANSIC), typescriptLabels(labels))
 	if err != nil {
 		return nil, err
 	}
 
-	timing, err := root.OpenFile(path.Join(outputdir, fmt.Sprintf("%v.timing", filename)), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	timing, err := root.OpenFile(files[1], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +53,7 @@ func newFilePtyLogger(root *os.Root, outputdir string) (*filePtyLogger, error) {
 	return &filePtyLogger{
 		typescript: typescript,
 		timing:     timing,
+		files:      files,
 		oldtime:    time.Now(),
 	}, nil
 }
@@ -74,6 +82,12 @@ func (l *filePtyLogger) loggingTty(msg []byte) error {
 	return nil
 }
 
+// Files returns the paths of the .typescript and .timing files, relative
+// to the recording root.
+func (l *filePtyLogger) Files() []string {
+	return append([]string(nil), l.files...)
+}
+
 func (l *filePtyLogger) Close() (err error) {
 	// if _, err = ; err != nil {
 	// return err
@@ -85,3 +99,24 @@ func (l *filePtyLogger) Close() (err error) {
 
 	return nil // TODO
 }
+
+// typescriptLabels formats labels for the typescript header, sorted by name,
+// e.g. ` [team="db" ticket="INC-1"]`, empty without labels.
+func typescriptLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+
+	return " [" + strings.Join(pairs, " ") + "]"
+}
@@ -21,6 +21,61 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type SessionEventType int32
+
+const (
+	SessionEventType_SESSION_ADDED   SessionEventType = 0
+	SessionEventType_SESSION_UPDATED SessionEventType = 1
+	// The session carries the last state of the removed session.
+	SessionEventType_SESSION_REMOVED SessionEventType = 2
+	// Sent once, after the added events of the sessions open when the watch
+	// started. It carries no session.
+	SessionEventType_SESSION_SYNCED SessionEventType = 3
+)
+
+// Enum value maps for SessionEventType.
+var (
+	SessionEventType_name = map[int32]string{
+		0: "SESSION_ADDED",
+		1: "SESSION_UPDATED",
+		2: "SESSION_REMOVED",
+		3: "SESSION_SYNCED",
+	}
+	SessionEventType_value = map[string]int32{
+		"SESSION_ADDED":   0,
+		"SESSION_UPDATED": 1,
+		"SESSION_REMOVED": 2,
+		"SESSION_SYNCED":  3,
+	}
+)
+
+func (x SessionEventType) Enum() *SessionEventType {
+	p := new(SessionEventType)
+	*p = x
+	return p
+}
+
+func (x SessionEventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SessionEventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_admin_proto_enumTypes[0].Descriptor()
+}
+
+func (SessionEventType) Type() protoreflect.EnumType {
+	return &file_admin_proto_enumTypes[0]
+}
+
+func (x SessionEventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SessionEventType.Descriptor instead.
+func (SessionEventType) EnumDescriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{0}
+}
+
 type ServerInfoRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -67,7 +122,15 @@ type ServerInfoResponse struct {
 	// Address the SSH listener is bound to.
 	SshAddr string `protobuf:"bytes,3,opt,name=ssh_addr,json=sshAddr,proto3" json:"ssh_addr,omitempty"`
 	// Wall-clock time the daemon started, as a unix timestamp in seconds.
-	StartedAt     int64 `protobuf:"varint,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	StartedAt int64 `protobuf:"varint,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	// Plugins of the chain, in order.
+	Plugins []*PluginStatus `protobuf:"bytes,5,rep,name=plugins,proto3" json:"plugins,omitempty"`
+	// Role of the caller under --admin-grpc-policy-file: viewer, streamer or
+	// operator. Empty without a policy, every caller may then call every rpc.
+	Role string `protobuf:"bytes,6,opt,name=role,proto3" json:"role,omitempty"`
+	// Names of the rpcs the caller may call, e.g. KillSession, leaving out
+	// those disabled on this instance.
+	AllowedRpcs   []string `protobuf:"bytes,7,rep,name=allowed_rpcs,json=allowedRpcs,proto3" json:"allowed_rpcs,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -130,6 +193,257 @@ func (x *ServerInfoResponse) GetStartedAt() int64 {
 	return 0
 }
 
+func (x *ServerInfoResponse) GetPlugins() []*PluginStatus {
+	if x != nil {
+		return x.Plugins
+	}
+	return nil
+}
+
+func (x *ServerInfoResponse) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *ServerInfoResponse) GetAllowedRpcs() []string {
+	if x != nil {
+		return x.AllowedRpcs
+	}
+	return nil
+}
+
+type PluginStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// False while a supervised plugin is being restarted, new connections are
+	// refused meanwhile. Always true for plugins not supervised by the daemon.
+	Available bool `protobuf:"varint,2,opt,name=available,proto3" json:"available,omitempty"`
+	// Number of times the plugin was restarted, see --plugin-restart.
+	Restarts int64 `protobuf:"varint,3,opt,name=restarts,proto3" json:"restarts,omitempty"`
+	// Why the plugin last went down, empty if it never did.
+	LastError string `protobuf:"bytes,4,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	// Unix timestamp in seconds of the last restart, 0 if never restarted.
+	LastRestartAt int64 `protobuf:"varint,5,opt,name=last_restart_at,json=lastRestartAt,proto3" json:"last_restart_at,omitempty"`
+	// True while calls to the plugin are failed by its circuit breaker.
+	CircuitOpen bool `protobuf:"varint,6,opt,name=circuit_open,json=circuitOpen,proto3" json:"circuit_open,omitempty"`
+	// Statistics of the grpc calls made to the plugin, by rpc name.
+	Rpcs []*PluginRpcStats `protobuf:"bytes,7,rep,name=rpcs,proto3" json:"rpcs,omitempty"`
+	// Name and version the plugin reported in the handshake, empty for
+	// plugins built before the handshake.
+	PluginName    string `protobuf:"bytes,8,opt,name=plugin_name,json=pluginName,proto3" json:"plugin_name,omitempty"`
+	PluginVersion string `protobuf:"bytes,9,opt,name=plugin_version,json=pluginVersion,proto3" json:"plugin_version,omitempty"`
+	// Plugin protocol version and optional features the plugin reported.
+	ProtocolVersion uint32   `protobuf:"varint,10,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Features        []string `protobuf:"bytes,11,rep,name=features,proto3" json:"features,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *PluginStatus) Reset() {
+	*x = PluginStatus{}
+	mi := &file_admin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginStatus) ProtoMessage() {}
+
+func (x *PluginStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginStatus.ProtoReflect.Descriptor instead.
+func (*PluginStatus) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PluginStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PluginStatus) GetAvailable() bool {
+	if x != nil {
+		return x.Available
+	}
+	return false
+}
+
+func (x *PluginStatus) GetRestarts() int64 {
+	if x != nil {
+		return x.Restarts
+	}
+	return 0
+}
+
+func (x *PluginStatus) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *PluginStatus) GetLastRestartAt() int64 {
+	if x != nil {
+		return x.LastRestartAt
+	}
+	return 0
+}
+
+func (x *PluginStatus) GetCircuitOpen() bool {
+	if x != nil {
+		return x.CircuitOpen
+	}
+	return false
+}
+
+func (x *PluginStatus) GetRpcs() []*PluginRpcStats {
+	if x != nil {
+		return x.Rpcs
+	}
+	return nil
+}
+
+func (x *PluginStatus) GetPluginName() string {
+	if x != nil {
+		return x.PluginName
+	}
+	return ""
+}
+
+func (x *PluginStatus) GetPluginVersion() string {
+	if x != nil {
+		return x.PluginVersion
+	}
+	return ""
+}
+
+func (x *PluginStatus) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *PluginStatus) GetFeatures() []string {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+type PluginRpcStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Name of the rpc in libplugin, e.g. PasswordAuth.
+	Method string `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	Calls  int64  `protobuf:"varint,2,opt,name=calls,proto3" json:"calls,omitempty"`
+	Errors int64  `protobuf:"varint,3,opt,name=errors,proto3" json:"errors,omitempty"`
+	// Calls that ran into their deadline, also counted in errors.
+	Timeouts int64 `protobuf:"varint,4,opt,name=timeouts,proto3" json:"timeouts,omitempty"`
+	// Calls failed by the open circuit breaker without reaching the plugin,
+	// not counted in calls.
+	Rejected       int64 `protobuf:"varint,5,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	TotalLatencyUs int64 `protobuf:"varint,6,opt,name=total_latency_us,json=totalLatencyUs,proto3" json:"total_latency_us,omitempty"`
+	MaxLatencyUs   int64 `protobuf:"varint,7,opt,name=max_latency_us,json=maxLatencyUs,proto3" json:"max_latency_us,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PluginRpcStats) Reset() {
+	*x = PluginRpcStats{}
+	mi := &file_admin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginRpcStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginRpcStats) ProtoMessage() {}
+
+func (x *PluginRpcStats) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginRpcStats.ProtoReflect.Descriptor instead.
+func (*PluginRpcStats) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PluginRpcStats) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *PluginRpcStats) GetCalls() int64 {
+	if x != nil {
+		return x.Calls
+	}
+	return 0
+}
+
+func (x *PluginRpcStats) GetErrors() int64 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+func (x *PluginRpcStats) GetTimeouts() int64 {
+	if x != nil {
+		return x.Timeouts
+	}
+	return 0
+}
+
+func (x *PluginRpcStats) GetRejected() int64 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *PluginRpcStats) GetTotalLatencyUs() int64 {
+	if x != nil {
+		return x.TotalLatencyUs
+	}
+	return 0
+}
+
+func (x *PluginRpcStats) GetMaxLatencyUs() int64 {
+	if x != nil {
+		return x.MaxLatencyUs
+	}
+	return 0
+}
+
 type ListSessionsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -138,7 +452,7 @@ type ListSessionsRequest struct {
 
 func (x *ListSessionsRequest) Reset() {
 	*x = ListSessionsRequest{}
-	mi := &file_admin_proto_msgTypes[2]
+	mi := &file_admin_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -150,7 +464,7 @@ func (x *ListSessionsRequest) String() string {
 func (*ListSessionsRequest) ProtoMessage() {}
 
 func (x *ListSessionsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[2]
+	mi := &file_admin_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -163,7 +477,7 @@ func (x *ListSessionsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListSessionsRequest.ProtoReflect.Descriptor instead.
 func (*ListSessionsRequest) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{2}
+	return file_admin_proto_rawDescGZIP(), []int{4}
 }
 
 type ListSessionsResponse struct {
@@ -173,21 +487,1580 @@ type ListSessionsResponse struct {
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ListSessionsResponse) Reset() {
-	*x = ListSessionsResponse{}
-	mi := &file_admin_proto_msgTypes[3]
+func (x *ListSessionsResponse) Reset() {
+	*x = ListSessionsResponse{}
+	mi := &file_admin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionsResponse) ProtoMessage() {}
+
+func (x *ListSessionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSessionsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListSessionsResponse) GetSessions() []*Session {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+type Session struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Unique session id (matches the `uniq_id` used elsewhere in sshpiperd).
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Downstream (client) connection details.
+	DownstreamUser string `protobuf:"bytes,2,opt,name=downstream_user,json=downstreamUser,proto3" json:"downstream_user,omitempty"`
+	DownstreamAddr string `protobuf:"bytes,3,opt,name=downstream_addr,json=downstreamAddr,proto3" json:"downstream_addr,omitempty"`
+	// Upstream (target server) connection details.
+	UpstreamUser string `protobuf:"bytes,4,opt,name=upstream_user,json=upstreamUser,proto3" json:"upstream_user,omitempty"`
+	UpstreamAddr string `protobuf:"bytes,5,opt,name=upstream_addr,json=upstreamAddr,proto3" json:"upstream_addr,omitempty"`
+	// Wall-clock time the pipe was established, as a unix timestamp in seconds.
+	StartedAt int64 `protobuf:"varint,6,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	// True if at least one shell/exec channel is currently being recorded
+	// and can therefore be live-streamed via StreamSession.
+	Streamable bool `protobuf:"varint,7,opt,name=streamable,proto3" json:"streamable,omitempty"`
+	// Labels the plugins attached to the session, e.g. ticket or team.
+	Labels map[string]string `protobuf:"bytes,8,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// True while the session is paused by PauseSession.
+	Paused bool `protobuf:"varint,9,opt,name=paused,proto3" json:"paused,omitempty"`
+	// Number of open channels, of every type.
+	Channels uint32 `protobuf:"varint,10,opt,name=channels,proto3" json:"channels,omitempty"`
+	// Bytes of channel data received from the downstream client and from the
+	// upstream server.
+	DownstreamBytes uint64 `protobuf:"varint,11,opt,name=downstream_bytes,json=downstreamBytes,proto3" json:"downstream_bytes,omitempty"`
+	UpstreamBytes   uint64 `protobuf:"varint,12,opt,name=upstream_bytes,json=upstreamBytes,proto3" json:"upstream_bytes,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Session) Reset() {
+	*x = Session{}
+	mi := &file_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Session) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Session) ProtoMessage() {}
+
+func (x *Session) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Session.ProtoReflect.Descriptor instead.
+func (*Session) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Session) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Session) GetDownstreamUser() string {
+	if x != nil {
+		return x.DownstreamUser
+	}
+	return ""
+}
+
+func (x *Session) GetDownstreamAddr() string {
+	if x != nil {
+		return x.DownstreamAddr
+	}
+	return ""
+}
+
+func (x *Session) GetUpstreamUser() string {
+	if x != nil {
+		return x.UpstreamUser
+	}
+	return ""
+}
+
+func (x *Session) GetUpstreamAddr() string {
+	if x != nil {
+		return x.UpstreamAddr
+	}
+	return ""
+}
+
+func (x *Session) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *Session) GetStreamable() bool {
+	if x != nil {
+		return x.Streamable
+	}
+	return false
+}
+
+func (x *Session) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Session) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *Session) GetChannels() uint32 {
+	if x != nil {
+		return x.Channels
+	}
+	return 0
+}
+
+func (x *Session) GetDownstreamBytes() uint64 {
+	if x != nil {
+		return x.DownstreamBytes
+	}
+	return 0
+}
+
+func (x *Session) GetUpstreamBytes() uint64 {
+	if x != nil {
+		return x.UpstreamBytes
+	}
+	return 0
+}
+
+type GetSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSessionRequest) Reset() {
+	*x = GetSessionRequest{}
+	mi := &file_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSessionRequest) ProtoMessage() {}
+
+func (x *GetSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSessionRequest.ProtoReflect.Descriptor instead.
+func (*GetSessionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetSessionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetSessionResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Session *Session               `protobuf:"bytes,1,opt,name=session,proto3" json:"session,omitempty"`
+	// Open channels, by id.
+	Channels []*Channel `protobuf:"bytes,2,rep,name=channels,proto3" json:"channels,omitempty"`
+	// Unix timestamp in seconds of the last channel data sent by either side,
+	// 0 if there was none.
+	LastActivityAt int64 `protobuf:"varint,3,opt,name=last_activity_at,json=lastActivityAt,proto3" json:"last_activity_at,omitempty"`
+	// Algorithms negotiated with the downstream client and with the upstream
+	// server.
+	DownstreamAlgorithms *Algorithms `protobuf:"bytes,4,opt,name=downstream_algorithms,json=downstreamAlgorithms,proto3" json:"downstream_algorithms,omitempty"`
+	UpstreamAlgorithms   *Algorithms `protobuf:"bytes,5,opt,name=upstream_algorithms,json=upstreamAlgorithms,proto3" json:"upstream_algorithms,omitempty"`
+	// SSH version strings of the downstream client and of the upstream
+	// server, e.g. SSH-2.0-OpenSSH_9.6.
+	ClientVersion string `protobuf:"bytes,6,opt,name=client_version,json=clientVersion,proto3" json:"client_version,omitempty"`
+	ServerVersion string `protobuf:"bytes,7,opt,name=server_version,json=serverVersion,proto3" json:"server_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSessionResponse) Reset() {
+	*x = GetSessionResponse{}
+	mi := &file_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSessionResponse) ProtoMessage() {}
+
+func (x *GetSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSessionResponse.ProtoReflect.Descriptor instead.
+func (*GetSessionResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetSessionResponse) GetSession() *Session {
+	if x != nil {
+		return x.Session
+	}
+	return nil
+}
+
+func (x *GetSessionResponse) GetChannels() []*Channel {
+	if x != nil {
+		return x.Channels
+	}
+	return nil
+}
+
+func (x *GetSessionResponse) GetLastActivityAt() int64 {
+	if x != nil {
+		return x.LastActivityAt
+	}
+	return 0
+}
+
+func (x *GetSessionResponse) GetDownstreamAlgorithms() *Algorithms {
+	if x != nil {
+		return x.DownstreamAlgorithms
+	}
+	return nil
+}
+
+func (x *GetSessionResponse) GetUpstreamAlgorithms() *Algorithms {
+	if x != nil {
+		return x.UpstreamAlgorithms
+	}
+	return nil
+}
+
+func (x *GetSessionResponse) GetClientVersion() string {
+	if x != nil {
+		return x.ClientVersion
+	}
+	return ""
+}
+
+func (x *GetSessionResponse) GetServerVersion() string {
+	if x != nil {
+		return x.ServerVersion
+	}
+	return ""
+}
+
+type Channel struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Client-side channel id, as in AsciicastHeader.channel_id.
+	Id uint32 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Channel type, e.g. session or direct-tcpip.
+	Type string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	// Request starting a session channel: shell, exec or subsystem, empty
+	// before it is sent.
+	Request string `protobuf:"bytes,3,opt,name=request,proto3" json:"request,omitempty"`
+	// The command of an exec request or the name of a subsystem.
+	Command string `protobuf:"bytes,4,opt,name=command,proto3" json:"command,omitempty"`
+	// Terminal size from the pty-req and window-change requests, 0 without a
+	// pty.
+	Width  uint32 `protobuf:"varint,5,opt,name=width,proto3" json:"width,omitempty"`
+	Height uint32 `protobuf:"varint,6,opt,name=height,proto3" json:"height,omitempty"`
+	// Unix timestamp in seconds the channel was opened.
+	StartedAt int64 `protobuf:"varint,7,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	// Bytes of channel data received on the channel from the downstream
+	// client and from the upstream server.
+	DownstreamBytes uint64 `protobuf:"varint,8,opt,name=downstream_bytes,json=downstreamBytes,proto3" json:"downstream_bytes,omitempty"`
+	UpstreamBytes   uint64 `protobuf:"varint,9,opt,name=upstream_bytes,json=upstreamBytes,proto3" json:"upstream_bytes,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Channel) Reset() {
+	*x = Channel{}
+	mi := &file_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Channel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Channel) ProtoMessage() {}
+
+func (x *Channel) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Channel.ProtoReflect.Descriptor instead.
+func (*Channel) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Channel) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Channel) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Channel) GetRequest() string {
+	if x != nil {
+		return x.Request
+	}
+	return ""
+}
+
+func (x *Channel) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *Channel) GetWidth() uint32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Channel) GetHeight() uint32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Channel) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *Channel) GetDownstreamBytes() uint64 {
+	if x != nil {
+		return x.DownstreamBytes
+	}
+	return 0
+}
+
+func (x *Channel) GetUpstreamBytes() uint64 {
+	if x != nil {
+		return x.UpstreamBytes
+	}
+	return 0
+}
+
+// Algorithms negotiated on one leg of a session. in and out are seen from
+// sshpiperd: in is what it receives, out what it sends.
+type Algorithms struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kex           string                 `protobuf:"bytes,1,opt,name=kex,proto3" json:"kex,omitempty"`
+	HostKey       string                 `protobuf:"bytes,2,opt,name=host_key,json=hostKey,proto3" json:"host_key,omitempty"`
+	CipherIn      string                 `protobuf:"bytes,3,opt,name=cipher_in,json=cipherIn,proto3" json:"cipher_in,omitempty"`
+	CipherOut     string                 `protobuf:"bytes,4,opt,name=cipher_out,json=cipherOut,proto3" json:"cipher_out,omitempty"`
+	MacIn         string                 `protobuf:"bytes,5,opt,name=mac_in,json=macIn,proto3" json:"mac_in,omitempty"`
+	MacOut        string                 `protobuf:"bytes,6,opt,name=mac_out,json=macOut,proto3" json:"mac_out,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Algorithms) Reset() {
+	*x = Algorithms{}
+	mi := &file_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Algorithms) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Algorithms) ProtoMessage() {}
+
+func (x *Algorithms) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Algorithms.ProtoReflect.Descriptor instead.
+func (*Algorithms) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Algorithms) GetKex() string {
+	if x != nil {
+		return x.Kex
+	}
+	return ""
+}
+
+func (x *Algorithms) GetHostKey() string {
+	if x != nil {
+		return x.HostKey
+	}
+	return ""
+}
+
+func (x *Algorithms) GetCipherIn() string {
+	if x != nil {
+		return x.CipherIn
+	}
+	return ""
+}
+
+func (x *Algorithms) GetCipherOut() string {
+	if x != nil {
+		return x.CipherOut
+	}
+	return ""
+}
+
+func (x *Algorithms) GetMacIn() string {
+	if x != nil {
+		return x.MacIn
+	}
+	return ""
+}
+
+func (x *Algorithms) GetMacOut() string {
+	if x != nil {
+		return x.MacOut
+	}
+	return ""
+}
+
+type ListSessionHistoryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Exact downstream (client) user.
+	DownstreamUser string `protobuf:"bytes,1,opt,name=downstream_user,json=downstreamUser,proto3" json:"downstream_user,omitempty"`
+	// Upstream address, as host:port or host only.
+	UpstreamAddr string `protobuf:"bytes,2,opt,name=upstream_addr,json=upstreamAddr,proto3" json:"upstream_addr,omitempty"`
+	// Labels the session must all have, see Session.labels.
+	Labels map[string]string `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Only sessions still open at or after since and opened before until,
+	// as unix timestamps in seconds. 0 leaves the bound open.
+	Since int64 `protobuf:"varint,4,opt,name=since,proto3" json:"since,omitempty"`
+	Until int64 `protobuf:"varint,5,opt,name=until,proto3" json:"until,omitempty"`
+	// Maximum number of sessions returned, 50 by default and at most 1000.
+	PageSize int32 `protobuf:"varint,6,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token of the previous page, or the cursor of the last
+	// session seen, to get the sessions that ended before it.
+	PageToken     string `protobuf:"bytes,7,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSessionHistoryRequest) Reset() {
+	*x = ListSessionHistoryRequest{}
+	mi := &file_admin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionHistoryRequest) ProtoMessage() {}
+
+func (x *ListSessionHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionHistoryRequest.ProtoReflect.Descriptor instead.
+func (*ListSessionHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListSessionHistoryRequest) GetDownstreamUser() string {
+	if x != nil {
+		return x.DownstreamUser
+	}
+	return ""
+}
+
+func (x *ListSessionHistoryRequest) GetUpstreamAddr() string {
+	if x != nil {
+		return x.UpstreamAddr
+	}
+	return ""
+}
+
+func (x *ListSessionHistoryRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *ListSessionHistoryRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *ListSessionHistoryRequest) GetUntil() int64 {
+	if x != nil {
+		return x.Until
+	}
+	return 0
+}
+
+func (x *ListSessionHistoryRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListSessionHistoryRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListSessionHistoryResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Sessions []*SessionHistoryEntry `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	// Token of the next page, empty on the last one.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSessionHistoryResponse) Reset() {
+	*x = ListSessionHistoryResponse{}
+	mi := &file_admin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionHistoryResponse) ProtoMessage() {}
+
+func (x *ListSessionHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionHistoryResponse.ProtoReflect.Descriptor instead.
+func (*ListSessionHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListSessionHistoryResponse) GetSessions() []*SessionHistoryEntry {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+func (x *ListSessionHistoryResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type SessionHistoryEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The session as it was listed, with the bytes of channel data it
+	// forwarded in each direction.
+	Session *Session `protobuf:"bytes,1,opt,name=session,proto3" json:"session,omitempty"`
+	// Unix timestamp in seconds the session ended.
+	EndedAt int64 `protobuf:"varint,2,opt,name=ended_at,json=endedAt,proto3" json:"ended_at,omitempty"`
+	// Why the session ended, empty when the client disconnected.
+	CloseReason string `protobuf:"bytes,3,opt,name=close_reason,json=closeReason,proto3" json:"close_reason,omitempty"`
+	// The commands of the exec requests of the client, in order.
+	Commands []string `protobuf:"bytes,4,rep,name=commands,proto3" json:"commands,omitempty"`
+	// Channels opened, by type.
+	Channels map[string]int64 `protobuf:"bytes,5,rep,name=channels,proto3" json:"channels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Exit statuses sent by the upstream server, in order.
+	ExitStatuses []uint32 `protobuf:"varint,6,rep,packed,name=exit_statuses,json=exitStatuses,proto3" json:"exit_statuses,omitempty"`
+	// Paths of the screen recordings of the session, relative to
+	// --screen-recording-dir.
+	RecordingFiles []string `protobuf:"bytes,7,rep,name=recording_files,json=recordingFiles,proto3" json:"recording_files,omitempty"`
+	ClientVersion  string   `protobuf:"bytes,8,opt,name=client_version,json=clientVersion,proto3" json:"client_version,omitempty"`
+	ServerVersion  string   `protobuf:"bytes,9,opt,name=server_version,json=serverVersion,proto3" json:"server_version,omitempty"`
+	// page_token listing the sessions that ended before this one.
+	Cursor string `protobuf:"bytes,10,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// Number of commands and exit statuses left out of commands and
+	// exit_statuses, which only keep the first ones.
+	DroppedCommands     int64 `protobuf:"varint,11,opt,name=dropped_commands,json=droppedCommands,proto3" json:"dropped_commands,omitempty"`
+	DroppedExitStatuses int64 `protobuf:"varint,12,opt,name=dropped_exit_statuses,json=droppedExitStatuses,proto3" json:"dropped_exit_statuses,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SessionHistoryEntry) Reset() {
+	*x = SessionHistoryEntry{}
+	mi := &file_admin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionHistoryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionHistoryEntry) ProtoMessage() {}
+
+func (x *SessionHistoryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionHistoryEntry.ProtoReflect.Descriptor instead.
+func (*SessionHistoryEntry) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SessionHistoryEntry) GetSession() *Session {
+	if x != nil {
+		return x.Session
+	}
+	return nil
+}
+
+func (x *SessionHistoryEntry) GetEndedAt() int64 {
+	if x != nil {
+		return x.EndedAt
+	}
+	return 0
+}
+
+func (x *SessionHistoryEntry) GetCloseReason() string {
+	if x != nil {
+		return x.CloseReason
+	}
+	return ""
+}
+
+func (x *SessionHistoryEntry) GetCommands() []string {
+	if x != nil {
+		return x.Commands
+	}
+	return nil
+}
+
+func (x *SessionHistoryEntry) GetChannels() map[string]int64 {
+	if x != nil {
+		return x.Channels
+	}
+	return nil
+}
+
+func (x *SessionHistoryEntry) GetExitStatuses() []uint32 {
+	if x != nil {
+		return x.ExitStatuses
+	}
+	return nil
+}
+
+func (x *SessionHistoryEntry) GetRecordingFiles() []string {
+	if x != nil {
+		return x.RecordingFiles
+	}
+	return nil
+}
+
+func (x *SessionHistoryEntry) GetClientVersion() string {
+	if x != nil {
+		return x.ClientVersion
+	}
+	return ""
+}
+
+func (x *SessionHistoryEntry) GetServerVersion() string {
+	if x != nil {
+		return x.ServerVersion
+	}
+	return ""
+}
+
+func (x *SessionHistoryEntry) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *SessionHistoryEntry) GetDroppedCommands() int64 {
+	if x != nil {
+		return x.DroppedCommands
+	}
+	return 0
+}
+
+func (x *SessionHistoryEntry) GetDroppedExitStatuses() int64 {
+	if x != nil {
+		return x.DroppedExitStatuses
+	}
+	return 0
+}
+
+type ListRecordingsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only the recordings of this session.
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Exact downstream (client) user.
+	DownstreamUser string `protobuf:"bytes,2,opt,name=downstream_user,json=downstreamUser,proto3" json:"downstream_user,omitempty"`
+	// Only recordings still written at or after since and started before
+	// until, as unix timestamps in seconds. 0 leaves the bound open.
+	Since int64 `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"`
+	Until int64 `protobuf:"varint,4,opt,name=until,proto3" json:"until,omitempty"`
+	// Maximum number of recordings returned, 50 by default and at most 1000.
+	PageSize int32 `protobuf:"varint,5,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token of the previous page, or the cursor of the last
+	// recording seen, to get the recordings listed after it.
+	PageToken     string `protobuf:"bytes,6,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRecordingsRequest) Reset() {
+	*x = ListRecordingsRequest{}
+	mi := &file_admin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRecordingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRecordingsRequest) ProtoMessage() {}
+
+func (x *ListRecordingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRecordingsRequest.ProtoReflect.Descriptor instead.
+func (*ListRecordingsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ListRecordingsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ListRecordingsRequest) GetDownstreamUser() string {
+	if x != nil {
+		return x.DownstreamUser
+	}
+	return ""
+}
+
+func (x *ListRecordingsRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *ListRecordingsRequest) GetUntil() int64 {
+	if x != nil {
+		return x.Until
+	}
+	return 0
+}
+
+func (x *ListRecordingsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListRecordingsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListRecordingsResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Recordings []*Recording           `protobuf:"bytes,1,rep,name=recordings,proto3" json:"recordings,omitempty"`
+	// Token of the next page, empty on the last one.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRecordingsResponse) Reset() {
+	*x = ListRecordingsResponse{}
+	mi := &file_admin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRecordingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRecordingsResponse) ProtoMessage() {}
+
+func (x *ListRecordingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRecordingsResponse.ProtoReflect.Descriptor instead.
+func (*ListRecordingsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListRecordingsResponse) GetRecordings() []*Recording {
+	if x != nil {
+		return x.Recordings
+	}
+	return nil
+}
+
+func (x *ListRecordingsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type Recording struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Path relative to --screen-recording-dir, the path of GetRecording.
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// "asciicast" or "typescript".
+	Format string `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`
+	// Session and downstream user of the recording, empty when sshpiperd
+	// cannot tell, e.g. the session of a recording under
+	// --username-as-recorddir without a session history.
+	SessionId      string `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	DownstreamUser string `protobuf:"bytes,4,opt,name=downstream_user,json=downstreamUser,proto3" json:"downstream_user,omitempty"`
+	// Unix timestamps in seconds the recording started and was last written.
+	StartedAt  int64 `protobuf:"varint,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	ModifiedAt int64 `protobuf:"varint,6,opt,name=modified_at,json=modifiedAt,proto3" json:"modified_at,omitempty"`
+	// Size in bytes.
+	Size uint64 `protobuf:"varint,7,opt,name=size,proto3" json:"size,omitempty"`
+	// Path of the timing file of a typescript recording, see scriptreplay(1).
+	TimingPath string `protobuf:"bytes,8,opt,name=timing_path,json=timingPath,proto3" json:"timing_path,omitempty"`
+	// page_token listing the recordings after this one.
+	Cursor        string `protobuf:"bytes,9,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Recording) Reset() {
+	*x = Recording{}
+	mi := &file_admin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Recording) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Recording) ProtoMessage() {}
+
+func (x *Recording) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Recording.ProtoReflect.Descriptor instead.
+func (*Recording) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Recording) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Recording) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *Recording) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Recording) GetDownstreamUser() string {
+	if x != nil {
+		return x.DownstreamUser
+	}
+	return ""
+}
+
+func (x *Recording) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *Recording) GetModifiedAt() int64 {
+	if x != nil {
+		return x.ModifiedAt
+	}
+	return 0
+}
+
+func (x *Recording) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Recording) GetTimingPath() string {
+	if x != nil {
+		return x.TimingPath
+	}
+	return ""
+}
+
+func (x *Recording) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type GetRecordingRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Recording.path or Recording.timing_path.
+	Path          string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRecordingRequest) Reset() {
+	*x = GetRecordingRequest{}
+	mi := &file_admin_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRecordingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRecordingRequest) ProtoMessage() {}
+
+func (x *GetRecordingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRecordingRequest.ProtoReflect.Descriptor instead.
+func (*GetRecordingRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *GetRecordingRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type RecordingChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordingChunk) Reset() {
+	*x = RecordingChunk{}
+	mi := &file_admin_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordingChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordingChunk) ProtoMessage() {}
+
+func (x *RecordingChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordingChunk.ProtoReflect.Descriptor instead.
+func (*RecordingChunk) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *RecordingChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type WatchSessionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// How often the counters of the sessions are refreshed, 5 by default and
+	// at least 1.
+	IntervalSeconds uint32 `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *WatchSessionsRequest) Reset() {
+	*x = WatchSessionsRequest{}
+	mi := &file_admin_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchSessionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchSessionsRequest) ProtoMessage() {}
+
+func (x *WatchSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchSessionsRequest.ProtoReflect.Descriptor instead.
+func (*WatchSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *WatchSessionsRequest) GetIntervalSeconds() uint32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+type SessionEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          SessionEventType       `protobuf:"varint,1,opt,name=type,proto3,enum=libadmin.SessionEventType" json:"type,omitempty"`
+	Session       *Session               `protobuf:"bytes,2,opt,name=session,proto3" json:"session,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionEvent) Reset() {
+	*x = SessionEvent{}
+	mi := &file_admin_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionEvent) ProtoMessage() {}
+
+func (x *SessionEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionEvent.ProtoReflect.Descriptor instead.
+func (*SessionEvent) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *SessionEvent) GetType() SessionEventType {
+	if x != nil {
+		return x.Type
+	}
+	return SessionEventType_SESSION_ADDED
+}
+
+func (x *SessionEvent) GetSession() *Session {
+	if x != nil {
+		return x.Session
+	}
+	return nil
+}
+
+type KillSessionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Sent to the client in the SSH disconnect message when set, at most
+	// 4096 bytes. Control characters are removed.
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillSessionRequest) Reset() {
+	*x = KillSessionRequest{}
+	mi := &file_admin_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillSessionRequest) ProtoMessage() {}
+
+func (x *KillSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillSessionRequest.ProtoReflect.Descriptor instead.
+func (*KillSessionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *KillSessionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *KillSessionRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type KillSessionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// True if a matching session was found and signalled to close.
+	Killed        bool `protobuf:"varint,1,opt,name=killed,proto3" json:"killed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillSessionResponse) Reset() {
+	*x = KillSessionResponse{}
+	mi := &file_admin_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillSessionResponse) ProtoMessage() {}
+
+func (x *KillSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillSessionResponse.ProtoReflect.Descriptor instead.
+func (*KillSessionResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *KillSessionResponse) GetKilled() bool {
+	if x != nil {
+		return x.Killed
+	}
+	return false
+}
+
+type KillSessionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Exact downstream (client) user.
+	DownstreamUser string `protobuf:"bytes,1,opt,name=downstream_user,json=downstreamUser,proto3" json:"downstream_user,omitempty"`
+	// Upstream address, as host:port or host only.
+	UpstreamAddr string `protobuf:"bytes,2,opt,name=upstream_addr,json=upstreamAddr,proto3" json:"upstream_addr,omitempty"`
+	// CIDR the downstream address is in, e.g. 10.0.0.0/8.
+	DownstreamCidr string `protobuf:"bytes,3,opt,name=downstream_cidr,json=downstreamCidr,proto3" json:"downstream_cidr,omitempty"`
+	// Labels the session must all have, see Session.labels.
+	Labels map[string]string `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// As in KillSessionRequest.
+	Reason        string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillSessionsRequest) Reset() {
+	*x = KillSessionsRequest{}
+	mi := &file_admin_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillSessionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillSessionsRequest) ProtoMessage() {}
+
+func (x *KillSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillSessionsRequest.ProtoReflect.Descriptor instead.
+func (*KillSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *KillSessionsRequest) GetDownstreamUser() string {
+	if x != nil {
+		return x.DownstreamUser
+	}
+	return ""
+}
+
+func (x *KillSessionsRequest) GetUpstreamAddr() string {
+	if x != nil {
+		return x.UpstreamAddr
+	}
+	return ""
+}
+
+func (x *KillSessionsRequest) GetDownstreamCidr() string {
+	if x != nil {
+		return x.DownstreamCidr
+	}
+	return ""
+}
+
+func (x *KillSessionsRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *KillSessionsRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type KillSessionsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The sessions that matched and were signalled to close.
+	Sessions      []*Session `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillSessionsResponse) Reset() {
+	*x = KillSessionsResponse{}
+	mi := &file_admin_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillSessionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillSessionsResponse) ProtoMessage() {}
+
+func (x *KillSessionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillSessionsResponse.ProtoReflect.Descriptor instead.
+func (*KillSessionsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *KillSessionsResponse) GetSessions() []*Session {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+type MessageSessionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Text of the message, at most 4096 bytes. Control characters other than
+	// newlines are removed.
+	Message       string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageSessionRequest) Reset() {
+	*x = MessageSessionRequest{}
+	mi := &file_admin_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageSessionRequest) ProtoMessage() {}
+
+func (x *MessageSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageSessionRequest.ProtoReflect.Descriptor instead.
+func (*MessageSessionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *MessageSessionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *MessageSessionRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type MessageSessionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Number of session channels the message was written to.
+	Channels      int32 `protobuf:"varint,1,opt,name=channels,proto3" json:"channels,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageSessionResponse) Reset() {
+	*x = MessageSessionResponse{}
+	mi := &file_admin_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListSessionsResponse) String() string {
+func (x *MessageSessionResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListSessionsResponse) ProtoMessage() {}
+func (*MessageSessionResponse) ProtoMessage() {}
 
-func (x *ListSessionsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[3]
+func (x *MessageSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -198,52 +2071,40 @@ func (x *ListSessionsResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListSessionsResponse.ProtoReflect.Descriptor instead.
-func (*ListSessionsResponse) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use MessageSessionResponse.ProtoReflect.Descriptor instead.
+func (*MessageSessionResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *ListSessionsResponse) GetSessions() []*Session {
+func (x *MessageSessionResponse) GetChannels() int32 {
 	if x != nil {
-		return x.Sessions
+		return x.Channels
 	}
-	return nil
+	return 0
 }
 
-type Session struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Unique session id (matches the `uniq_id` used elsewhere in sshpiperd).
-	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	// Downstream (client) connection details.
-	DownstreamUser string `protobuf:"bytes,2,opt,name=downstream_user,json=downstreamUser,proto3" json:"downstream_user,omitempty"`
-	DownstreamAddr string `protobuf:"bytes,3,opt,name=downstream_addr,json=downstreamAddr,proto3" json:"downstream_addr,omitempty"`
-	// Upstream (target server) connection details.
-	UpstreamUser string `protobuf:"bytes,4,opt,name=upstream_user,json=upstreamUser,proto3" json:"upstream_user,omitempty"`
-	UpstreamAddr string `protobuf:"bytes,5,opt,name=upstream_addr,json=upstreamAddr,proto3" json:"upstream_addr,omitempty"`
-	// Wall-clock time the pipe was established, as a unix timestamp in seconds.
-	StartedAt int64 `protobuf:"varint,6,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
-	// True if at least one shell/exec channel is currently being recorded
-	// and can therefore be live-streamed via StreamSession.
-	Streamable    bool `protobuf:"varint,7,opt,name=streamable,proto3" json:"streamable,omitempty"`
+type PauseSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Session) Reset() {
-	*x = Session{}
-	mi := &file_admin_proto_msgTypes[4]
+func (x *PauseSessionRequest) Reset() {
+	*x = PauseSessionRequest{}
+	mi := &file_admin_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Session) String() string {
+func (x *PauseSessionRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Session) ProtoMessage() {}
+func (*PauseSessionRequest) ProtoMessage() {}
 
-func (x *Session) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[4]
+func (x *PauseSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -254,82 +2115,85 @@ func (x *Session) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Session.ProtoReflect.Descriptor instead.
-func (*Session) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use PauseSessionRequest.ProtoReflect.Descriptor instead.
+func (*PauseSessionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{27}
 }
 
-func (x *Session) GetId() string {
+func (x *PauseSessionRequest) GetId() string {
 	if x != nil {
 		return x.Id
 	}
 	return ""
 }
 
-func (x *Session) GetDownstreamUser() string {
-	if x != nil {
-		return x.DownstreamUser
-	}
-	return ""
+type PauseSessionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// True if a matching session was found and paused.
+	Paused        bool `protobuf:"varint,1,opt,name=paused,proto3" json:"paused,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Session) GetDownstreamAddr() string {
-	if x != nil {
-		return x.DownstreamAddr
-	}
-	return ""
+func (x *PauseSessionResponse) Reset() {
+	*x = PauseSessionResponse{}
+	mi := &file_admin_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *Session) GetUpstreamUser() string {
-	if x != nil {
-		return x.UpstreamUser
-	}
-	return ""
+func (x *PauseSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func (x *Session) GetUpstreamAddr() string {
+func (*PauseSessionResponse) ProtoMessage() {}
+
+func (x *PauseSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[28]
 	if x != nil {
-		return x.UpstreamAddr
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *Session) GetStartedAt() int64 {
-	if x != nil {
-		return x.StartedAt
-	}
-	return 0
+// Deprecated: Use PauseSessionResponse.ProtoReflect.Descriptor instead.
+func (*PauseSessionResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *Session) GetStreamable() bool {
+func (x *PauseSessionResponse) GetPaused() bool {
 	if x != nil {
-		return x.Streamable
+		return x.Paused
 	}
 	return false
 }
 
-type KillSessionRequest struct {
+type ResumeSessionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *KillSessionRequest) Reset() {
-	*x = KillSessionRequest{}
-	mi := &file_admin_proto_msgTypes[5]
+func (x *ResumeSessionRequest) Reset() {
+	*x = ResumeSessionRequest{}
+	mi := &file_admin_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *KillSessionRequest) String() string {
+func (x *ResumeSessionRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*KillSessionRequest) ProtoMessage() {}
+func (*ResumeSessionRequest) ProtoMessage() {}
 
-func (x *KillSessionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[5]
+func (x *ResumeSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -340,41 +2204,41 @@ func (x *KillSessionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use KillSessionRequest.ProtoReflect.Descriptor instead.
-func (*KillSessionRequest) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use ResumeSessionRequest.ProtoReflect.Descriptor instead.
+func (*ResumeSessionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{29}
 }
 
-func (x *KillSessionRequest) GetId() string {
+func (x *ResumeSessionRequest) GetId() string {
 	if x != nil {
 		return x.Id
 	}
 	return ""
 }
 
-type KillSessionResponse struct {
+type ResumeSessionResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
-	// True if a matching session was found and signalled to close.
-	Killed        bool `protobuf:"varint,1,opt,name=killed,proto3" json:"killed,omitempty"`
+	// True if a matching session was found and resumed.
+	Resumed       bool `protobuf:"varint,1,opt,name=resumed,proto3" json:"resumed,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *KillSessionResponse) Reset() {
-	*x = KillSessionResponse{}
-	mi := &file_admin_proto_msgTypes[6]
+func (x *ResumeSessionResponse) Reset() {
+	*x = ResumeSessionResponse{}
+	mi := &file_admin_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *KillSessionResponse) String() string {
+func (x *ResumeSessionResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*KillSessionResponse) ProtoMessage() {}
+func (*ResumeSessionResponse) ProtoMessage() {}
 
-func (x *KillSessionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[6]
+func (x *ResumeSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -385,14 +2249,14 @@ func (x *KillSessionResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use KillSessionResponse.ProtoReflect.Descriptor instead.
-func (*KillSessionResponse) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use ResumeSessionResponse.ProtoReflect.Descriptor instead.
+func (*ResumeSessionResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *KillSessionResponse) GetKilled() bool {
+func (x *ResumeSessionResponse) GetResumed() bool {
 	if x != nil {
-		return x.Killed
+		return x.Resumed
 	}
 	return false
 }
@@ -400,9 +2264,10 @@ func (x *KillSessionResponse) GetKilled() bool {
 type StreamSessionRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	// If true, the server will replay the asciicast captured so far before
-	// switching to live streaming. If false, the client only receives new
-	// frames from the moment the stream is opened (plus a synthetic header).
+	// If true, the server may replay cached header frame(s) for the session
+	// before switching to live streaming. Previously captured event frames
+	// ("o"/"i"/"r") are not replayed. If false, the client only receives
+	// new frames from the moment the stream is opened.
 	Replay        bool `protobuf:"varint,2,opt,name=replay,proto3" json:"replay,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -410,7 +2275,7 @@ type StreamSessionRequest struct {
 
 func (x *StreamSessionRequest) Reset() {
 	*x = StreamSessionRequest{}
-	mi := &file_admin_proto_msgTypes[7]
+	mi := &file_admin_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -422,7 +2287,7 @@ func (x *StreamSessionRequest) String() string {
 func (*StreamSessionRequest) ProtoMessage() {}
 
 func (x *StreamSessionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[7]
+	mi := &file_admin_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -435,7 +2300,7 @@ func (x *StreamSessionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamSessionRequest.ProtoReflect.Descriptor instead.
 func (*StreamSessionRequest) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{7}
+	return file_admin_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *StreamSessionRequest) GetId() string {
@@ -452,6 +2317,90 @@ func (x *StreamSessionRequest) GetReplay() bool {
 	return false
 }
 
+type AttachSessionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Session to attach to, read from the first request only.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Channel to write to, the first shell/exec channel of the session when
+	// unset. Read from the first request only.
+	ChannelId *uint32 `protobuf:"varint,2,opt,name=channel_id,json=channelId,proto3,oneof" json:"channel_id,omitempty"`
+	// Name of the admin shown to the user, read from the first request only.
+	// The common name of the client certificate is shown instead when the
+	// admin API requires one.
+	Name string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// Same as StreamSessionRequest.replay, read from the first request only.
+	Replay bool `protobuf:"varint,4,opt,name=replay,proto3" json:"replay,omitempty"`
+	// Input to write to the channel.
+	Input         []byte `protobuf:"bytes,5,opt,name=input,proto3" json:"input,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AttachSessionRequest) Reset() {
+	*x = AttachSessionRequest{}
+	mi := &file_admin_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttachSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttachSessionRequest) ProtoMessage() {}
+
+func (x *AttachSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttachSessionRequest.ProtoReflect.Descriptor instead.
+func (*AttachSessionRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *AttachSessionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AttachSessionRequest) GetChannelId() uint32 {
+	if x != nil && x.ChannelId != nil {
+		return *x.ChannelId
+	}
+	return 0
+}
+
+func (x *AttachSessionRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AttachSessionRequest) GetReplay() bool {
+	if x != nil {
+		return x.Replay
+	}
+	return false
+}
+
+func (x *AttachSessionRequest) GetInput() []byte {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
 // SessionFrame mirrors the asciicast v2 file format:
 // the first message is always a header; subsequent messages are events.
 type SessionFrame struct {
@@ -467,7 +2416,7 @@ type SessionFrame struct {
 
 func (x *SessionFrame) Reset() {
 	*x = SessionFrame{}
-	mi := &file_admin_proto_msgTypes[8]
+	mi := &file_admin_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -479,7 +2428,7 @@ func (x *SessionFrame) String() string {
 func (*SessionFrame) ProtoMessage() {}
 
 func (x *SessionFrame) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[8]
+	mi := &file_admin_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -492,7 +2441,7 @@ func (x *SessionFrame) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SessionFrame.ProtoReflect.Descriptor instead.
 func (*SessionFrame) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{8}
+	return file_admin_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *SessionFrame) GetFrame() isSessionFrame_Frame {
@@ -551,7 +2500,7 @@ type AsciicastHeader struct {
 
 func (x *AsciicastHeader) Reset() {
 	*x = AsciicastHeader{}
-	mi := &file_admin_proto_msgTypes[9]
+	mi := &file_admin_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -563,7 +2512,7 @@ func (x *AsciicastHeader) String() string {
 func (*AsciicastHeader) ProtoMessage() {}
 
 func (x *AsciicastHeader) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[9]
+	mi := &file_admin_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -576,7 +2525,7 @@ func (x *AsciicastHeader) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AsciicastHeader.ProtoReflect.Descriptor instead.
 func (*AsciicastHeader) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{9}
+	return file_admin_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *AsciicastHeader) GetWidth() int32 {
@@ -629,7 +2578,7 @@ type AsciicastEvent struct {
 
 func (x *AsciicastEvent) Reset() {
 	*x = AsciicastEvent{}
-	mi := &file_admin_proto_msgTypes[10]
+	mi := &file_admin_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -641,7 +2590,7 @@ func (x *AsciicastEvent) String() string {
 func (*AsciicastEvent) ProtoMessage() {}
 
 func (x *AsciicastEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_admin_proto_msgTypes[10]
+	mi := &file_admin_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -654,7 +2603,7 @@ func (x *AsciicastEvent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AsciicastEvent.ProtoReflect.Descriptor instead.
 func (*AsciicastEvent) Descriptor() ([]byte, []int) {
-	return file_admin_proto_rawDescGZIP(), []int{10}
+	return file_admin_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *AsciicastEvent) GetTime() float64 {
@@ -685,21 +2634,150 @@ func (x *AsciicastEvent) GetChannelId() uint32 {
 	return 0
 }
 
+type ReloadHostKeysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReloadHostKeysRequest) Reset() {
+	*x = ReloadHostKeysRequest{}
+	mi := &file_admin_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReloadHostKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadHostKeysRequest) ProtoMessage() {}
+
+func (x *ReloadHostKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadHostKeysRequest.ProtoReflect.Descriptor instead.
+func (*ReloadHostKeysRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{36}
+}
+
+type ReloadHostKeysResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// SHA256 fingerprints of the host keys loaded by the reload.
+	Fingerprints []string `protobuf:"bytes,1,rep,name=fingerprints,proto3" json:"fingerprints,omitempty"`
+	// SHA256 fingerprints of the previous host keys, still presented to new
+	// connections until retire_at while the new keys are advertised via
+	// hostkeys-00@openssh.com. Empty unless --server-key-rotation-window is
+	// set.
+	RetiringFingerprints []string `protobuf:"bytes,2,rep,name=retiring_fingerprints,json=retiringFingerprints,proto3" json:"retiring_fingerprints,omitempty"`
+	// Unix timestamp in seconds the retiring keys stop being presented, 0
+	// when there are none.
+	RetireAt      int64 `protobuf:"varint,3,opt,name=retire_at,json=retireAt,proto3" json:"retire_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReloadHostKeysResponse) Reset() {
+	*x = ReloadHostKeysResponse{}
+	mi := &file_admin_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReloadHostKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadHostKeysResponse) ProtoMessage() {}
+
+func (x *ReloadHostKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadHostKeysResponse.ProtoReflect.Descriptor instead.
+func (*ReloadHostKeysResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *ReloadHostKeysResponse) GetFingerprints() []string {
+	if x != nil {
+		return x.Fingerprints
+	}
+	return nil
+}
+
+func (x *ReloadHostKeysResponse) GetRetiringFingerprints() []string {
+	if x != nil {
+		return x.RetiringFingerprints
+	}
+	return nil
+}
+
+func (x *ReloadHostKeysResponse) GetRetireAt() int64 {
+	if x != nil {
+		return x.RetireAt
+	}
+	return 0
+}
+
 var File_admin_proto protoreflect.FileDescriptor
 
 const file_admin_proto_rawDesc = "" +
 	"\n" +
 	"\vadmin.proto\x12\blibadmin\"\x13\n" +
-	"\x11ServerInfoRequest\"x\n" +
+	"\x11ServerInfoRequest\"\xe1\x01\n" +
 	"\x12ServerInfoResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
 	"\aversion\x18\x02 \x01(\tR\aversion\x12\x19\n" +
 	"\bssh_addr\x18\x03 \x01(\tR\asshAddr\x12\x1d\n" +
 	"\n" +
-	"started_at\x18\x04 \x01(\x03R\tstartedAt\"\x15\n" +
+	"started_at\x18\x04 \x01(\x03R\tstartedAt\x120\n" +
+	"\aplugins\x18\x05 \x03(\v2\x16.libadmin.PluginStatusR\aplugins\x12\x12\n" +
+	"\x04role\x18\x06 \x01(\tR\x04role\x12!\n" +
+	"\fallowed_rpcs\x18\a \x03(\tR\vallowedRpcs\"\x83\x03\n" +
+	"\fPluginStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
+	"\tavailable\x18\x02 \x01(\bR\tavailable\x12\x1a\n" +
+	"\brestarts\x18\x03 \x01(\x03R\brestarts\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x04 \x01(\tR\tlastError\x12&\n" +
+	"\x0flast_restart_at\x18\x05 \x01(\x03R\rlastRestartAt\x12!\n" +
+	"\fcircuit_open\x18\x06 \x01(\bR\vcircuitOpen\x12,\n" +
+	"\x04rpcs\x18\a \x03(\v2\x18.libadmin.PluginRpcStatsR\x04rpcs\x12\x1f\n" +
+	"\vplugin_name\x18\b \x01(\tR\n" +
+	"pluginName\x12%\n" +
+	"\x0eplugin_version\x18\t \x01(\tR\rpluginVersion\x12)\n" +
+	"\x10protocol_version\x18\n" +
+	" \x01(\rR\x0fprotocolVersion\x12\x1a\n" +
+	"\bfeatures\x18\v \x03(\tR\bfeatures\"\xde\x01\n" +
+	"\x0ePluginRpcStats\x12\x16\n" +
+	"\x06method\x18\x01 \x01(\tR\x06method\x12\x14\n" +
+	"\x05calls\x18\x02 \x01(\x03R\x05calls\x12\x16\n" +
+	"\x06errors\x18\x03 \x01(\x03R\x06errors\x12\x1a\n" +
+	"\btimeouts\x18\x04 \x01(\x03R\btimeouts\x12\x1a\n" +
+	"\brejected\x18\x05 \x01(\x03R\brejected\x12(\n" +
+	"\x10total_latency_us\x18\x06 \x01(\x03R\x0etotalLatencyUs\x12$\n" +
+	"\x0emax_latency_us\x18\a \x01(\x03R\fmaxLatencyUs\"\x15\n" +
 	"\x13ListSessionsRequest\"E\n" +
 	"\x14ListSessionsResponse\x12-\n" +
-	"\bsessions\x18\x01 \x03(\v2\x11.libadmin.SessionR\bsessions\"\xf4\x01\n" +
+	"\bsessions\x18\x01 \x03(\v2\x11.libadmin.SessionR\bsessions\"\xec\x03\n" +
 	"\aSession\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12'\n" +
 	"\x0fdownstream_user\x18\x02 \x01(\tR\x0edownstreamUser\x12'\n" +
@@ -710,14 +2788,155 @@ const file_admin_proto_rawDesc = "" +
 	"started_at\x18\x06 \x01(\x03R\tstartedAt\x12\x1e\n" +
 	"\n" +
 	"streamable\x18\a \x01(\bR\n" +
-	"streamable\"$\n" +
+	"streamable\x125\n" +
+	"\x06labels\x18\b \x03(\v2\x1d.libadmin.Session.LabelsEntryR\x06labels\x12\x16\n" +
+	"\x06paused\x18\t \x01(\bR\x06paused\x12\x1a\n" +
+	"\bchannels\x18\n" +
+	" \x01(\rR\bchannels\x12)\n" +
+	"\x10downstream_bytes\x18\v \x01(\x04R\x0fdownstreamBytes\x12%\n" +
+	"\x0eupstream_bytes\x18\f \x01(\x04R\rupstreamBytes\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"#\n" +
+	"\x11GetSessionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xfa\x02\n" +
+	"\x12GetSessionResponse\x12+\n" +
+	"\asession\x18\x01 \x01(\v2\x11.libadmin.SessionR\asession\x12-\n" +
+	"\bchannels\x18\x02 \x03(\v2\x11.libadmin.ChannelR\bchannels\x12(\n" +
+	"\x10last_activity_at\x18\x03 \x01(\x03R\x0elastActivityAt\x12I\n" +
+	"\x15downstream_algorithms\x18\x04 \x01(\v2\x14.libadmin.AlgorithmsR\x14downstreamAlgorithms\x12E\n" +
+	"\x13upstream_algorithms\x18\x05 \x01(\v2\x14.libadmin.AlgorithmsR\x12upstreamAlgorithms\x12%\n" +
+	"\x0eclient_version\x18\x06 \x01(\tR\rclientVersion\x12%\n" +
+	"\x0eserver_version\x18\a \x01(\tR\rserverVersion\"\x80\x02\n" +
+	"\aChannel\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x18\n" +
+	"\arequest\x18\x03 \x01(\tR\arequest\x12\x18\n" +
+	"\acommand\x18\x04 \x01(\tR\acommand\x12\x14\n" +
+	"\x05width\x18\x05 \x01(\rR\x05width\x12\x16\n" +
+	"\x06height\x18\x06 \x01(\rR\x06height\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\a \x01(\x03R\tstartedAt\x12)\n" +
+	"\x10downstream_bytes\x18\b \x01(\x04R\x0fdownstreamBytes\x12%\n" +
+	"\x0eupstream_bytes\x18\t \x01(\x04R\rupstreamBytes\"\xa5\x01\n" +
+	"\n" +
+	"Algorithms\x12\x10\n" +
+	"\x03kex\x18\x01 \x01(\tR\x03kex\x12\x19\n" +
+	"\bhost_key\x18\x02 \x01(\tR\ahostKey\x12\x1b\n" +
+	"\tcipher_in\x18\x03 \x01(\tR\bcipherIn\x12\x1d\n" +
+	"\n" +
+	"cipher_out\x18\x04 \x01(\tR\tcipherOut\x12\x15\n" +
+	"\x06mac_in\x18\x05 \x01(\tR\x05macIn\x12\x17\n" +
+	"\amac_out\x18\x06 \x01(\tR\x06macOut\"\xd5\x02\n" +
+	"\x19ListSessionHistoryRequest\x12'\n" +
+	"\x0fdownstream_user\x18\x01 \x01(\tR\x0edownstreamUser\x12#\n" +
+	"\rupstream_addr\x18\x02 \x01(\tR\fupstreamAddr\x12G\n" +
+	"\x06labels\x18\x03 \x03(\v2/.libadmin.ListSessionHistoryRequest.LabelsEntryR\x06labels\x12\x14\n" +
+	"\x05since\x18\x04 \x01(\x03R\x05since\x12\x14\n" +
+	"\x05until\x18\x05 \x01(\x03R\x05until\x12\x1b\n" +
+	"\tpage_size\x18\x06 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\a \x01(\tR\tpageToken\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x7f\n" +
+	"\x1aListSessionHistoryResponse\x129\n" +
+	"\bsessions\x18\x01 \x03(\v2\x1d.libadmin.SessionHistoryEntryR\bsessions\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\xb5\x04\n" +
+	"\x13SessionHistoryEntry\x12+\n" +
+	"\asession\x18\x01 \x01(\v2\x11.libadmin.SessionR\asession\x12\x19\n" +
+	"\bended_at\x18\x02 \x01(\x03R\aendedAt\x12!\n" +
+	"\fclose_reason\x18\x03 \x01(\tR\vcloseReason\x12\x1a\n" +
+	"\bcommands\x18\x04 \x03(\tR\bcommands\x12G\n" +
+	"\bchannels\x18\x05 \x03(\v2+.libadmin.SessionHistoryEntry.ChannelsEntryR\bchannels\x12#\n" +
+	"\rexit_statuses\x18\x06 \x03(\rR\fexitStatuses\x12'\n" +
+	"\x0frecording_files\x18\a \x03(\tR\x0erecordingFiles\x12%\n" +
+	"\x0eclient_version\x18\b \x01(\tR\rclientVersion\x12%\n" +
+	"\x0eserver_version\x18\t \x01(\tR\rserverVersion\x12\x16\n" +
+	"\x06cursor\x18\n" +
+	" \x01(\tR\x06cursor\x12)\n" +
+	"\x10dropped_commands\x18\v \x01(\x03R\x0fdroppedCommands\x122\n" +
+	"\x15dropped_exit_statuses\x18\f \x01(\x03R\x13droppedExitStatuses\x1a;\n" +
+	"\rChannelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xc7\x01\n" +
+	"\x15ListRecordingsRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12'\n" +
+	"\x0fdownstream_user\x18\x02 \x01(\tR\x0edownstreamUser\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\x03R\x05since\x12\x14\n" +
+	"\x05until\x18\x04 \x01(\x03R\x05until\x12\x1b\n" +
+	"\tpage_size\x18\x05 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x06 \x01(\tR\tpageToken\"u\n" +
+	"\x16ListRecordingsResponse\x123\n" +
+	"\n" +
+	"recordings\x18\x01 \x03(\v2\x13.libadmin.RecordingR\n" +
+	"recordings\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\x8c\x02\n" +
+	"\tRecording\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x03 \x01(\tR\tsessionId\x12'\n" +
+	"\x0fdownstream_user\x18\x04 \x01(\tR\x0edownstreamUser\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\x05 \x01(\x03R\tstartedAt\x12\x1f\n" +
+	"\vmodified_at\x18\x06 \x01(\x03R\n" +
+	"modifiedAt\x12\x12\n" +
+	"\x04size\x18\a \x01(\x04R\x04size\x12\x1f\n" +
+	"\vtiming_path\x18\b \x01(\tR\n" +
+	"timingPath\x12\x16\n" +
+	"\x06cursor\x18\t \x01(\tR\x06cursor\")\n" +
+	"\x13GetRecordingRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"$\n" +
+	"\x0eRecordingChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"A\n" +
+	"\x14WatchSessionsRequest\x12)\n" +
+	"\x10interval_seconds\x18\x01 \x01(\rR\x0fintervalSeconds\"k\n" +
+	"\fSessionEvent\x12.\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1a.libadmin.SessionEventTypeR\x04type\x12+\n" +
+	"\asession\x18\x02 \x01(\v2\x11.libadmin.SessionR\asession\"<\n" +
 	"\x12KillSessionRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"-\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"-\n" +
 	"\x13KillSessionResponse\x12\x16\n" +
-	"\x06killed\x18\x01 \x01(\bR\x06killed\">\n" +
+	"\x06killed\x18\x01 \x01(\bR\x06killed\"\xa2\x02\n" +
+	"\x13KillSessionsRequest\x12'\n" +
+	"\x0fdownstream_user\x18\x01 \x01(\tR\x0edownstreamUser\x12#\n" +
+	"\rupstream_addr\x18\x02 \x01(\tR\fupstreamAddr\x12'\n" +
+	"\x0fdownstream_cidr\x18\x03 \x01(\tR\x0edownstreamCidr\x12A\n" +
+	"\x06labels\x18\x04 \x03(\v2).libadmin.KillSessionsRequest.LabelsEntryR\x06labels\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"E\n" +
+	"\x14KillSessionsResponse\x12-\n" +
+	"\bsessions\x18\x01 \x03(\v2\x11.libadmin.SessionR\bsessions\"A\n" +
+	"\x15MessageSessionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"4\n" +
+	"\x16MessageSessionResponse\x12\x1a\n" +
+	"\bchannels\x18\x01 \x01(\x05R\bchannels\"%\n" +
+	"\x13PauseSessionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\".\n" +
+	"\x14PauseSessionResponse\x12\x16\n" +
+	"\x06paused\x18\x01 \x01(\bR\x06paused\"&\n" +
+	"\x14ResumeSessionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"1\n" +
+	"\x15ResumeSessionResponse\x12\x18\n" +
+	"\aresumed\x18\x01 \x01(\bR\aresumed\">\n" +
 	"\x14StreamSessionRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
-	"\x06replay\x18\x02 \x01(\bR\x06replay\"~\n" +
+	"\x06replay\x18\x02 \x01(\bR\x06replay\"\x9b\x01\n" +
+	"\x14AttachSessionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\"\n" +
+	"\n" +
+	"channel_id\x18\x02 \x01(\rH\x00R\tchannelId\x88\x01\x01\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x16\n" +
+	"\x06replay\x18\x04 \x01(\bR\x06replay\x12\x14\n" +
+	"\x05input\x18\x05 \x01(\fR\x05inputB\r\n" +
+	"\v_channel_id\"~\n" +
 	"\fSessionFrame\x123\n" +
 	"\x06header\x18\x01 \x01(\v2\x19.libadmin.AsciicastHeaderH\x00R\x06header\x120\n" +
 	"\x05event\x18\x02 \x01(\v2\x18.libadmin.AsciicastEventH\x00R\x05eventB\a\n" +
@@ -737,13 +2956,35 @@ const file_admin_proto_rawDesc = "" +
 	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x12\n" +
 	"\x04data\x18\x03 \x01(\fR\x04data\x12\x1d\n" +
 	"\n" +
-	"channel_id\x18\x04 \x01(\rR\tchannelId2\xc6\x02\n" +
+	"channel_id\x18\x04 \x01(\rR\tchannelId\"\x17\n" +
+	"\x15ReloadHostKeysRequest\"\x8e\x01\n" +
+	"\x16ReloadHostKeysResponse\x12\"\n" +
+	"\ffingerprints\x18\x01 \x03(\tR\ffingerprints\x123\n" +
+	"\x15retiring_fingerprints\x18\x02 \x03(\tR\x14retiringFingerprints\x12\x1b\n" +
+	"\tretire_at\x18\x03 \x01(\x03R\bretireAt*c\n" +
+	"\x10SessionEventType\x12\x11\n" +
+	"\rSESSION_ADDED\x10\x00\x12\x13\n" +
+	"\x0fSESSION_UPDATED\x10\x01\x12\x13\n" +
+	"\x0fSESSION_REMOVED\x10\x02\x12\x12\n" +
+	"\x0eSESSION_SYNCED\x10\x032\xd8\t\n" +
 	"\rSshPiperAdmin\x12I\n" +
 	"\n" +
 	"ServerInfo\x12\x1b.libadmin.ServerInfoRequest\x1a\x1c.libadmin.ServerInfoResponse\"\x00\x12O\n" +
-	"\fListSessions\x12\x1d.libadmin.ListSessionsRequest\x1a\x1e.libadmin.ListSessionsResponse\"\x00\x12L\n" +
-	"\vKillSession\x12\x1c.libadmin.KillSessionRequest\x1a\x1d.libadmin.KillSessionResponse\"\x00\x12K\n" +
-	"\rStreamSession\x12\x1e.libadmin.StreamSessionRequest\x1a\x16.libadmin.SessionFrame\"\x000\x01B$Z\"github.com/tg123/sshpiper/libadminb\x06proto3"
+	"\fListSessions\x12\x1d.libadmin.ListSessionsRequest\x1a\x1e.libadmin.ListSessionsResponse\"\x00\x12I\n" +
+	"\n" +
+	"GetSession\x12\x1b.libadmin.GetSessionRequest\x1a\x1c.libadmin.GetSessionResponse\"\x00\x12a\n" +
+	"\x12ListSessionHistory\x12#.libadmin.ListSessionHistoryRequest\x1a$.libadmin.ListSessionHistoryResponse\"\x00\x12U\n" +
+	"\x0eListRecordings\x12\x1f.libadmin.ListRecordingsRequest\x1a .libadmin.ListRecordingsResponse\"\x00\x12K\n" +
+	"\fGetRecording\x12\x1d.libadmin.GetRecordingRequest\x1a\x18.libadmin.RecordingChunk\"\x000\x01\x12K\n" +
+	"\rWatchSessions\x12\x1e.libadmin.WatchSessionsRequest\x1a\x16.libadmin.SessionEvent\"\x000\x01\x12L\n" +
+	"\vKillSession\x12\x1c.libadmin.KillSessionRequest\x1a\x1d.libadmin.KillSessionResponse\"\x00\x12O\n" +
+	"\fKillSessions\x12\x1d.libadmin.KillSessionsRequest\x1a\x1e.libadmin.KillSessionsResponse\"\x00\x12K\n" +
+	"\rStreamSession\x12\x1e.libadmin.StreamSessionRequest\x1a\x16.libadmin.SessionFrame\"\x000\x01\x12M\n" +
+	"\rAttachSession\x12\x1e.libadmin.AttachSessionRequest\x1a\x16.libadmin.SessionFrame\"\x00(\x010\x01\x12U\n" +
+	"\x0eMessageSession\x12\x1f.libadmin.MessageSessionRequest\x1a .libadmin.MessageSessionResponse\"\x00\x12O\n" +
+	"\fPauseSession\x12\x1d.libadmin.PauseSessionRequest\x1a\x1e.libadmin.PauseSessionResponse\"\x00\x12R\n" +
+	"\rResumeSession\x12\x1e.libadmin.ResumeSessionRequest\x1a\x1f.libadmin.ResumeSessionResponse\"\x00\x12U\n" +
+	"\x0eReloadHostKeys\x12\x1f.libadmin.ReloadHostKeysRequest\x1a .libadmin.ReloadHostKeysResponse\"\x00B$Z\"github.com/tg123/sshpiper/libadminb\x06proto3"
 
 var (
 	file_admin_proto_rawDescOnce sync.Once
@@ -757,39 +2998,110 @@ func file_admin_proto_rawDescGZIP() []byte {
 	return file_admin_proto_rawDescData
 }
 
-var file_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_admin_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 43)
 var file_admin_proto_goTypes = []any{
-	(*ServerInfoRequest)(nil),    // 0: libadmin.ServerInfoRequest
-	(*ServerInfoResponse)(nil),   // 1: libadmin.ServerInfoResponse
-	(*ListSessionsRequest)(nil),  // 2: libadmin.ListSessionsRequest
-	(*ListSessionsResponse)(nil), // 3: libadmin.ListSessionsResponse
-	(*Session)(nil),              // 4: libadmin.Session
-	(*KillSessionRequest)(nil),   // 5: libadmin.KillSessionRequest
-	(*KillSessionResponse)(nil),  // 6: libadmin.KillSessionResponse
-	(*StreamSessionRequest)(nil), // 7: libadmin.StreamSessionRequest
-	(*SessionFrame)(nil),         // 8: libadmin.SessionFrame
-	(*AsciicastHeader)(nil),      // 9: libadmin.AsciicastHeader
-	(*AsciicastEvent)(nil),       // 10: libadmin.AsciicastEvent
-	nil,                          // 11: libadmin.AsciicastHeader.EnvEntry
+	(SessionEventType)(0),              // 0: libadmin.SessionEventType
+	(*ServerInfoRequest)(nil),          // 1: libadmin.ServerInfoRequest
+	(*ServerInfoResponse)(nil),         // 2: libadmin.ServerInfoResponse
+	(*PluginStatus)(nil),               // 3: libadmin.PluginStatus
+	(*PluginRpcStats)(nil),             // 4: libadmin.PluginRpcStats
+	(*ListSessionsRequest)(nil),        // 5: libadmin.ListSessionsRequest
+	(*ListSessionsResponse)(nil),       // 6: libadmin.ListSessionsResponse
+	(*Session)(nil),                    // 7: libadmin.Session
+	(*GetSessionRequest)(nil),          // 8: libadmin.GetSessionRequest
+	(*GetSessionResponse)(nil),         // 9: libadmin.GetSessionResponse
+	(*Channel)(nil),                    // 10: libadmin.Channel
+	(*Algorithms)(nil),                 // 11: libadmin.Algorithms
+	(*ListSessionHistoryRequest)(nil),  // 12: libadmin.ListSessionHistoryRequest
+	(*ListSessionHistoryResponse)(nil), // 13: libadmin.ListSessionHistoryResponse
+	(*SessionHistoryEntry)(nil),        // 14: libadmin.SessionHistoryEntry
+	(*ListRecordingsRequest)(nil),      // 15: libadmin.ListRecordingsRequest
+	(*ListRecordingsResponse)(nil),     // 16: libadmin.ListRecordingsResponse
+	(*Recording)(nil),                  // 17: libadmin.Recording
+	(*GetRecordingRequest)(nil),        // 18: libadmin.GetRecordingRequest
+	(*RecordingChunk)(nil),             // 19: libadmin.RecordingChunk
+	(*WatchSessionsRequest)(nil),       // 20: libadmin.WatchSessionsRequest
+	(*SessionEvent)(nil),               // 21: libadmin.SessionEvent
+	(*KillSessionRequest)(nil),         // 22: libadmin.KillSessionRequest
+	(*KillSessionResponse)(nil),        // 23: libadmin.KillSessionResponse
+	(*KillSessionsRequest)(nil),        // 24: libadmin.KillSessionsRequest
+	(*KillSessionsResponse)(nil),       // 25: libadmin.KillSessionsResponse
+	(*MessageSessionRequest)(nil),      // 26: libadmin.MessageSessionRequest
+	(*MessageSessionResponse)(nil),     // 27: libadmin.MessageSessionResponse
+	(*PauseSessionRequest)(nil),        // 28: libadmin.PauseSessionRequest
+	(*PauseSessionResponse)(nil),       // 29: libadmin.PauseSessionResponse
+	(*ResumeSessionRequest)(nil),       // 30: libadmin.ResumeSessionRequest
+	(*ResumeSessionResponse)(nil),      // 31: libadmin.ResumeSessionResponse
+	(*StreamSessionRequest)(nil),       // 32: libadmin.StreamSessionRequest
+	(*AttachSessionRequest)(nil),       // 33: libadmin.AttachSessionRequest
+	(*SessionFrame)(nil),               // 34: libadmin.SessionFrame
+	(*AsciicastHeader)(nil),            // 35: libadmin.AsciicastHeader
+	(*AsciicastEvent)(nil),             // 36: libadmin.AsciicastEvent
+	(*ReloadHostKeysRequest)(nil),      // 37: libadmin.ReloadHostKeysRequest
+	(*ReloadHostKeysResponse)(nil),     // 38: libadmin.ReloadHostKeysResponse
+	nil,                                // 39: libadmin.Session.LabelsEntry
+	nil,                                // 40: libadmin.ListSessionHistoryRequest.LabelsEntry
+	nil,                                // 41: libadmin.SessionHistoryEntry.ChannelsEntry
+	nil,                                // 42: libadmin.KillSessionsRequest.LabelsEntry
+	nil,                                // 43: libadmin.AsciicastHeader.EnvEntry
 }
 var file_admin_proto_depIdxs = []int32{
-	4,  // 0: libadmin.ListSessionsResponse.sessions:type_name -> libadmin.Session
-	9,  // 1: libadmin.SessionFrame.header:type_name -> libadmin.AsciicastHeader
-	10, // 2: libadmin.SessionFrame.event:type_name -> libadmin.AsciicastEvent
-	11, // 3: libadmin.AsciicastHeader.env:type_name -> libadmin.AsciicastHeader.EnvEntry
-	0,  // 4: libadmin.SshPiperAdmin.ServerInfo:input_type -> libadmin.ServerInfoRequest
-	2,  // 5: libadmin.SshPiperAdmin.ListSessions:input_type -> libadmin.ListSessionsRequest
-	5,  // 6: libadmin.SshPiperAdmin.KillSession:input_type -> libadmin.KillSessionRequest
-	7,  // 7: libadmin.SshPiperAdmin.StreamSession:input_type -> libadmin.StreamSessionRequest
-	1,  // 8: libadmin.SshPiperAdmin.ServerInfo:output_type -> libadmin.ServerInfoResponse
-	3,  // 9: libadmin.SshPiperAdmin.ListSessions:output_type -> libadmin.ListSessionsResponse
-	6,  // 10: libadmin.SshPiperAdmin.KillSession:output_type -> libadmin.KillSessionResponse
-	8,  // 11: libadmin.SshPiperAdmin.StreamSession:output_type -> libadmin.SessionFrame
-	8,  // [8:12] is the sub-list for method output_type
-	4,  // [4:8] is the sub-list for method input_type
-	4,  // [4:4] is the sub-list for extension type_name
-	4,  // [4:4] is the sub-list for extension extendee
-	0,  // [0:4] is the sub-list for field type_name
+	3,  // 0: libadmin.ServerInfoResponse.plugins:type_name -> libadmin.PluginStatus
+	4,  // 1: libadmin.PluginStatus.rpcs:type_name -> libadmin.PluginRpcStats
+	7,  // 2: libadmin.ListSessionsResponse.sessions:type_name -> libadmin.Session
+	39, // 3: libadmin.Session.labels:type_name -> libadmin.Session.LabelsEntry
+	7,  // 4: libadmin.GetSessionResponse.session:type_name -> libadmin.Session
+	10, // 5: libadmin.GetSessionResponse.channels:type_name -> libadmin.Channel
+	11, // 6: libadmin.GetSessionResponse.downstream_algorithms:type_name -> libadmin.Algorithms
+	11, // 7: libadmin.GetSessionResponse.upstream_algorithms:type_name -> libadmin.Algorithms
+	40, // 8: libadmin.ListSessionHistoryRequest.labels:type_name -> libadmin.ListSessionHistoryRequest.LabelsEntry
+	14, // 9: libadmin.ListSessionHistoryResponse.sessions:type_name -> libadmin.SessionHistoryEntry
+	7,  // 10: libadmin.SessionHistoryEntry.session:type_name -> libadmin.Session
+	41, // 11: libadmin.SessionHistoryEntry.channels:type_name -> libadmin.SessionHistoryEntry.ChannelsEntry
+	17, // 12: libadmin.ListRecordingsResponse.recordings:type_name -> libadmin.Recording
+	0,  // 13: libadmin.SessionEvent.type:type_name -> libadmin.SessionEventType
+	7,  // 14: libadmin.SessionEvent.session:type_name -> libadmin.Session
+	42, // 15: libadmin.KillSessionsRequest.labels:type_name -> libadmin.KillSessionsRequest.LabelsEntry
+	7,  // 16: libadmin.KillSessionsResponse.sessions:type_name -> libadmin.Session
+	35, // 17: libadmin.SessionFrame.header:type_name -> libadmin.AsciicastHeader
+	36, // 18: libadmin.SessionFrame.event:type_name -> libadmin.AsciicastEvent
+	43, // 19: libadmin.AsciicastHeader.env:type_name -> libadmin.AsciicastHeader.EnvEntry
+	1,  // 20: libadmin.SshPiperAdmin.ServerInfo:input_type -> libadmin.ServerInfoRequest
+	5,  // 21: libadmin.SshPiperAdmin.ListSessions:input_type -> libadmin.ListSessionsRequest
+	8,  // 22: libadmin.SshPiperAdmin.GetSession:input_type -> libadmin.GetSessionRequest
+	12, // 23: libadmin.SshPiperAdmin.ListSessionHistory:input_type -> libadmin.ListSessionHistoryRequest
+	15, // 24: libadmin.SshPiperAdmin.ListRecordings:input_type -> libadmin.ListRecordingsRequest
+	18, // 25: libadmin.SshPiperAdmin.GetRecording:input_type -> libadmin.GetRecordingRequest
+	20, // 26: libadmin.SshPiperAdmin.WatchSessions:input_type -> libadmin.WatchSessionsRequest
+	22, // 27: libadmin.SshPiperAdmin.KillSession:input_type -> libadmin.KillSessionRequest
+	24, // 28: libadmin.SshPiperAdmin.KillSessions:input_type -> libadmin.KillSessionsRequest
+	32, // 29: libadmin.SshPiperAdmin.StreamSession:input_type -> libadmin.StreamSessionRequest
+	33, // 30: libadmin.SshPiperAdmin.AttachSession:input_type -> libadmin.AttachSessionRequest
+	26, // 31: libadmin.SshPiperAdmin.MessageSession:input_type -> libadmin.MessageSessionRequest
+	28, // 32: libadmin.SshPiperAdmin.PauseSession:input_type -> libadmin.PauseSessionRequest
+	30, // 33: libadmin.SshPiperAdmin.ResumeSession:input_type -> libadmin.ResumeSessionRequest
+	37, // 34: libadmin.SshPiperAdmin.ReloadHostKeys:input_type -> libadmin.ReloadHostKeysRequest
+	2,  // 35: libadmin.SshPiperAdmin.ServerInfo:output_type -> libadmin.ServerInfoResponse
+	6,  // 36: libadmin.SshPiperAdmin.ListSessions:output_type -> libadmin.ListSessionsResponse
+	9,  // 37: libadmin.SshPiperAdmin.GetSession:output_type -> libadmin.GetSessionResponse
+	13, // 38: libadmin.SshPiperAdmin.ListSessionHistory:output_type -> libadmin.ListSessionHistoryResponse
+	16, // 39: libadmin.SshPiperAdmin.ListRecordings:output_type -> libadmin.ListRecordingsResponse
+	19, // 40: libadmin.SshPiperAdmin.GetRecording:output_type -> libadmin.RecordingChunk
+	21, // 41: libadmin.SshPiperAdmin.WatchSessions:output_type -> libadmin.SessionEvent
+	23, // 42: libadmin.SshPiperAdmin.KillSession:output_type -> libadmin.KillSessionResponse
+	25, // 43: libadmin.SshPiperAdmin.KillSessions:output_type -> libadmin.KillSessionsResponse
+	34, // 44: libadmin.SshPiperAdmin.StreamSession:output_type -> libadmin.SessionFrame
+	34, // 45: libadmin.SshPiperAdmin.AttachSession:output_type -> libadmin.SessionFrame
+	27, // 46: libadmin.SshPiperAdmin.MessageSession:output_type -> libadmin.MessageSessionResponse
+	29, // 47: libadmin.SshPiperAdmin.PauseSession:output_type -> libadmin.PauseSessionResponse
+	31, // 48: libadmin.SshPiperAdmin.ResumeSession:output_type -> libadmin.ResumeSessionResponse
+	38, // 49: libadmin.SshPiperAdmin.ReloadHostKeys:output_type -> libadmin.ReloadHostKeysResponse
+	35, // [35:50] is the sub-list for method output_type
+	20, // [20:35] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_admin_proto_init() }
@@ -797,7 +3109,8 @@ func file_admin_proto_init() {
 	if File_admin_proto != nil {
 		return
 	}
-	file_admin_proto_msgTypes[8].OneofWrappers = []any{
+	file_admin_proto_msgTypes[32].OneofWrappers = []any{}
+	file_admin_proto_msgTypes[33].OneofWrappers = []any{
 		(*SessionFrame_Header)(nil),
 		(*SessionFrame_Event)(nil),
 	}
@@ -806,13 +3119,14 @@ func file_admin_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_admin_proto_rawDesc), len(file_admin_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   12,
+			NumEnums:      1,
+			NumMessages:   43,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_admin_proto_goTypes,
 		DependencyIndexes: file_admin_proto_depIdxs,
+		EnumInfos:         file_admin_proto_enumTypes,
 		MessageInfos:      file_admin_proto_msgTypes,
 	}.Build()
 	File_admin_proto = out.File
@@ -19,10 +19,21 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	SshPiperAdmin_ServerInfo_FullMethodName    = "/libadmin.SshPiperAdmin/ServerInfo"
-	SshPiperAdmin_ListSessions_FullMethodName  = "/libadmin.SshPiperAdmin/ListSessions"
-	SshPiperAdmin_KillSession_FullMethodName   = "/libadmin.SshPiperAdmin/KillSession"
-	SshPiperAdmin_StreamSession_FullMethodName = "/libadmin.SshPiperAdmin/StreamSession"
+	SshPiperAdmin_ServerInfo_FullMethodName         = "/libadmin.SshPiperAdmin/ServerInfo"
+	SshPiperAdmin_ListSessions_FullMethodName       = "/libadmin.SshPiperAdmin/ListSessions"
+	SshPiperAdmin_GetSession_FullMethodName         = "/libadmin.SshPiperAdmin/GetSession"
+	SshPiperAdmin_ListSessionHistory_FullMethodName = "/libadmin.SshPiperAdmin/ListSessionHistory"
+	SshPiperAdmin_ListRecordings_FullMethodName     = "/libadmin.SshPiperAdmin/ListRecordings"
+	SshPiperAdmin_GetRecording_FullMethodName       = "/libadmin.SshPiperAdmin/GetRecording"
+	SshPiperAdmin_WatchSessions_FullMethodName      = "/libadmin.SshPiperAdmin/WatchSessions"
+	SshPiperAdmin_KillSession_FullMethodName        = "/libadmin.SshPiperAdmin/KillSession"
+	SshPiperAdmin_KillSessions_FullMethodName       = "/libadmin.SshPiperAdmin/KillSessions"
+	SshPiperAdmin_StreamSession_FullMethodName      = "/libadmin.SshPiperAdmin/StreamSession"
+	SshPiperAdmin_AttachSession_FullMethodName      = "/libadmin.SshPiperAdmin/AttachSession"
+	SshPiperAdmin_MessageSession_FullMethodName     = "/libadmin.SshPiperAdmin/MessageSession"
+	SshPiperAdmin_PauseSession_FullMethodName       = "/libadmin.SshPiperAdmin/PauseSession"
+	SshPiperAdmin_ResumeSession_FullMethodName      = "/libadmin.SshPiperAdmin/ResumeSession"
+	SshPiperAdmin_ReloadHostKeys_FullMethodName     = "/libadmin.SshPiperAdmin/ReloadHostKeys"
 )
 
 // SshPiperAdminClient is the client API for SshPiperAdmin service.
@@ -37,14 +48,58 @@ type SshPiperAdminClient interface {
 	ServerInfo(ctx context.Context, in *ServerInfoRequest, opts ...grpc.CallOption) (*ServerInfoResponse, error)
 	// ListSessions returns all currently active piped SSH sessions.
 	ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	// GetSession returns a session with its open channels, the negotiated
+	// algorithms and the versions of both sides. NOT_FOUND when there is no
+	// such session.
+	GetSession(ctx context.Context, in *GetSessionRequest, opts ...grpc.CallOption) (*GetSessionResponse, error)
+	// ListSessionHistory returns the finished sessions matching all the given
+	// filters, most recently ended first. FAILED_PRECONDITION unless
+	// sshpiperd keeps a history, see --admin-grpc-history-file.
+	ListSessionHistory(ctx context.Context, in *ListSessionHistoryRequest, opts ...grpc.CallOption) (*ListSessionHistoryResponse, error)
+	// ListRecordings returns the screen recordings under
+	// --screen-recording-dir matching all the given filters, most recently
+	// modified first. FAILED_PRECONDITION unless screen recording is enabled.
+	ListRecordings(ctx context.Context, in *ListRecordingsRequest, opts ...grpc.CallOption) (*ListRecordingsResponse, error)
+	// GetRecording streams the content of a file listed by ListRecordings,
+	// a recording or the timing file of a typescript, in chunks. NOT_FOUND
+	// when there is no such file.
+	GetRecording(ctx context.Context, in *GetRecordingRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RecordingChunk], error)
+	// WatchSessions streams the sessions as they come and go: an added event
+	// for every open session, a synced event, then added, updated and removed
+	// events as they happen. Updates of the counters are sent periodically.
+	WatchSessions(ctx context.Context, in *WatchSessionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SessionEvent], error)
 	// KillSession closes the sshpiperd pipe identified by id, terminating
 	// both the downstream and upstream SSH connections.
 	KillSession(ctx context.Context, in *KillSessionRequest, opts ...grpc.CallOption) (*KillSessionResponse, error)
+	// KillSessions closes every pipe matching all the given selectors, at
+	// least one of which is required.
+	KillSessions(ctx context.Context, in *KillSessionsRequest, opts ...grpc.CallOption) (*KillSessionsResponse, error)
 	// StreamSession streams asciicast v2 frames captured from the upstream
 	// shell/exec channel of the given session in real time. The first frame
 	// sent by the server is always a header frame describing the terminal,
 	// followed by output ("o") and resize ("r") frames as they happen.
 	StreamSession(ctx context.Context, in *StreamSessionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SessionFrame], error)
+	// AttachSession attaches read-write to a shell/exec channel of a live
+	// session. The first request names the session and the channel, the
+	// input of every request is written to the channel as if typed by the
+	// client. The server sends the frames of the channel like StreamSession,
+	// and ends the stream when the channel closes. The user sees a line
+	// telling an admin joined and left. Refused unless sshpiperd runs with
+	// --admin-grpc-allow-attach.
+	AttachSession(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AttachSessionRequest, SessionFrame], error)
+	// MessageSession writes a message to the stderr of every open session
+	// channel of the session, e.g. to warn its user before killing it.
+	MessageSession(ctx context.Context, in *MessageSessionRequest, opts ...grpc.CallOption) (*MessageSessionResponse, error)
+	// PauseSession holds the channel data sent by the client of the session,
+	// and everything it sends after, until ResumeSession. The connection
+	// stays open meanwhile, e.g. to freeze input during an investigation.
+	PauseSession(ctx context.Context, in *PauseSessionRequest, opts ...grpc.CallOption) (*PauseSessionResponse, error)
+	// ResumeSession forwards what PauseSession held.
+	ResumeSession(ctx context.Context, in *ResumeSessionRequest, opts ...grpc.CallOption) (*ResumeSessionResponse, error)
+	// ReloadHostKeys re-reads the configured host keys and certificates
+	// (--server-key, --server-cert, ...) for new connections, the same as
+	// sending SIGHUP to sshpiperd. Live sessions are not affected.
+	ReloadHostKeys(ctx context.Context, in *ReloadHostKeysRequest, opts ...grpc.CallOption) (*ReloadHostKeysResponse, error)
 }
 
 type sshPiperAdminClient struct {
@@ -75,6 +130,74 @@ func (c *sshPiperAdminClient) ListSessions(ctx context.Context, in *ListSessions
 	return out, nil
 }
 
+func (c *sshPiperAdminClient) GetSession(ctx context.Context, in *GetSessionRequest, opts ...grpc.CallOption) (*GetSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSessionResponse)
+	err := c.cc.Invoke(ctx, SshPiperAdmin_GetSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sshPiperAdminClient) ListSessionHistory(ctx context.Context, in *ListSessionHistoryRequest, opts ...grpc.CallOption) (*ListSessionHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSessionHistoryResponse)
+	err := c.cc.Invoke(ctx, SshPiperAdmin_ListSessionHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sshPiperAdminClient) ListRecordings(ctx context.Context, in *ListRecordingsRequest, opts ...grpc.CallOption) (*ListRecordingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRecordingsResponse)
+	err := c.cc.Invoke(ctx, SshPiperAdmin_ListRecordings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sshPiperAdminClient) GetRecording(ctx context.Context, in *GetRecordingRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RecordingChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SshPiperAdmin_ServiceDesc.Streams[0], SshPiperAdmin_GetRecording_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetRecordingRequest, RecordingChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SshPiperAdmin_GetRecordingClient = grpc.ServerStreamingClient[RecordingChunk]
+
+func (c *sshPiperAdminClient) WatchSessions(ctx context.Context, in *WatchSessionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SessionEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SshPiperAdmin_ServiceDesc.Streams[1], SshPiperAdmin_WatchSessions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchSessionsRequest, SessionEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SshPiperAdmin_WatchSessionsClient = grpc.ServerStreamingClient[SessionEvent]
+
 func (c *sshPiperAdminClient) KillSession(ctx context.Context, in *KillSessionRequest, opts ...grpc.CallOption) (*KillSessionResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(KillSessionResponse)
@@ -85,9 +208,19 @@ func (c *sshPiperAdminClient) KillSession(ctx context.Context, in *KillSessionRe
 	return out, nil
 }
 
+func (c *sshPiperAdminClient) KillSessions(ctx context.Context, in *KillSessionsRequest, opts ...grpc.CallOption) (*KillSessionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KillSessionsResponse)
+	err := c.cc.Invoke(ctx, SshPiperAdmin_KillSessions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *sshPiperAdminClient) StreamSession(ctx context.Context, in *StreamSessionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SessionFrame], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &SshPiperAdmin_ServiceDesc.Streams[0], SshPiperAdmin_StreamSession_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &SshPiperAdmin_ServiceDesc.Streams[2], SshPiperAdmin_StreamSession_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -104,6 +237,59 @@ func (c *sshPiperAdminClient) StreamSession(ctx context.Context, in *StreamSessi
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type SshPiperAdmin_StreamSessionClient = grpc.ServerStreamingClient[SessionFrame]
 
+func (c *sshPiperAdminClient) AttachSession(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AttachSessionRequest, SessionFrame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SshPiperAdmin_ServiceDesc.Streams[3], SshPiperAdmin_AttachSession_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AttachSessionRequest, SessionFrame]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SshPiperAdmin_AttachSessionClient = grpc.BidiStreamingClient[AttachSessionRequest, SessionFrame]
+
+func (c *sshPiperAdminClient) MessageSession(ctx context.Context, in *MessageSessionRequest, opts ...grpc.CallOption) (*MessageSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageSessionResponse)
+	err := c.cc.Invoke(ctx, SshPiperAdmin_MessageSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sshPiperAdminClient) PauseSession(ctx context.Context, in *PauseSessionRequest, opts ...grpc.CallOption) (*PauseSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PauseSessionResponse)
+	err := c.cc.Invoke(ctx, SshPiperAdmin_PauseSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sshPiperAdminClient) ResumeSession(ctx context.Context, in *ResumeSessionRequest, opts ...grpc.CallOption) (*ResumeSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResumeSessionResponse)
+	err := c.cc.Invoke(ctx, SshPiperAdmin_ResumeSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sshPiperAdminClient) ReloadHostKeys(ctx context.Context, in *ReloadHostKeysRequest, opts ...grpc.CallOption) (*ReloadHostKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReloadHostKeysResponse)
+	err := c.cc.Invoke(ctx, SshPiperAdmin_ReloadHostKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SshPiperAdminServer is the server API for SshPiperAdmin service.
 // All implementations must embed UnimplementedSshPiperAdminServer
 // for forward compatibility.
@@ -116,14 +302,58 @@ type SshPiperAdminServer interface {
 	ServerInfo(context.Context, *ServerInfoRequest) (*ServerInfoResponse, error)
 	// ListSessions returns all currently active piped SSH sessions.
 	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	// GetSession returns a session with its open channels, the negotiated
+	// algorithms and the versions of both sides. NOT_FOUND when there is no
+	// such session.
+	GetSession(context.Context, *GetSessionRequest) (*GetSessionResponse, error)
+	// ListSessionHistory returns the finished sessions matching all the given
+	// filters, most recently ended first. FAILED_PRECONDITION unless
+	// sshpiperd keeps a history, see --admin-grpc-history-file.
+	ListSessionHistory(context.Context, *ListSessionHistoryRequest) (*ListSessionHistoryResponse, error)
+	// ListRecordings returns the screen recordings under
+	// --screen-recording-dir matching all the given filters, most recently
+	// modified first. FAILED_PRECONDITION unless screen recording is enabled.
+	ListRecordings(context.Context, *ListRecordingsRequest) (*ListRecordingsResponse, error)
+	// GetRecording streams the content of a file listed by ListRecordings,
+	// a recording or the timing file of a typescript, in chunks. NOT_FOUND
+	// when there is no such file.
+	GetRecording(*GetRecordingRequest, grpc.ServerStreamingServer[RecordingChunk]) error
+	// WatchSessions streams the sessions as they come and go: an added event
+	// for every open session, a synced event, then added, updated and removed
+	// events as they happen. Updates of the counters are sent periodically.
+	WatchSessions(*WatchSessionsRequest, grpc.ServerStreamingServer[SessionEvent]) error
 	// KillSession closes the sshpiperd pipe identified by id, terminating
 	// both the downstream and upstream SSH connections.
 	KillSession(context.Context, *KillSessionRequest) (*KillSessionResponse, error)
+	// KillSessions closes every pipe matching all the given selectors, at
+	// least one of which is required.
+	KillSessions(context.Context, *KillSessionsRequest) (*KillSessionsResponse, error)
 	// StreamSession streams asciicast v2 frames captured from the upstream
 	// shell/exec channel of the given session in real time. The first frame
 	// sent by the server is always a header frame describing the terminal,
 	// followed by output ("o") and resize ("r") frames as they happen.
 	StreamSession(*StreamSessionRequest, grpc.ServerStreamingServer[SessionFrame]) error
+	// AttachSession attaches read-write to a shell/exec channel of a live
+	// session. The first request names the session and the channel, the
+	// input of every request is written to the channel as if typed by the
+	// client. The server sends the frames of the channel like StreamSession,
+	// and ends the stream when the channel closes. The user sees a line
+	// telling an admin joined and left. Refused unless sshpiperd runs with
+	// --admin-grpc-allow-attach.
+	AttachSession(grpc.BidiStreamingServer[AttachSessionRequest, SessionFrame]) error
+	// MessageSession writes a message to the stderr of every open session
+	// channel of the session, e.g. to warn its user before killing it.
+	MessageSession(context.Context, *MessageSessionRequest) (*MessageSessionResponse, error)
+	// PauseSession holds the channel data sent by the client of the session,
+	// and everything it sends after, until ResumeSession. The connection
+	// stays open meanwhile, e.g. to freeze input during an investigation.
+	PauseSession(context.Context, *PauseSessionRequest) (*PauseSessionResponse, error)
+	// ResumeSession forwards what PauseSession held.
+	ResumeSession(context.Context, *ResumeSessionRequest) (*ResumeSessionResponse, error)
+	// ReloadHostKeys re-reads the configured host keys and certificates
+	// (--server-key, --server-cert, ...) for new connections, the same as
+	// sending SIGHUP to sshpiperd. Live sessions are not affected.
+	ReloadHostKeys(context.Context, *ReloadHostKeysRequest) (*ReloadHostKeysResponse, error)
 	mustEmbedUnimplementedSshPiperAdminServer()
 }
 
@@ -140,12 +370,45 @@ func (UnimplementedSshPiperAdminServer) ServerInfo(context.Context, *ServerInfoR
 func (UnimplementedSshPiperAdminServer) ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ListSessions not implemented")
 }
+func (UnimplementedSshPiperAdminServer) GetSession(context.Context, *GetSessionRequest) (*GetSessionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetSession not implemented")
+}
+func (UnimplementedSshPiperAdminServer) ListSessionHistory(context.Context, *ListSessionHistoryRequest) (*ListSessionHistoryResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListSessionHistory not implemented")
+}
+func (UnimplementedSshPiperAdminServer) ListRecordings(context.Context, *ListRecordingsRequest) (*ListRecordingsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListRecordings not implemented")
+}
+func (UnimplementedSshPiperAdminServer) GetRecording(*GetRecordingRequest, grpc.ServerStreamingServer[RecordingChunk]) error {
+	return status.Error(codes.Unimplemented, "method GetRecording not implemented")
+}
+func (UnimplementedSshPiperAdminServer) WatchSessions(*WatchSessionsRequest, grpc.ServerStreamingServer[SessionEvent]) error {
+	return status.Error(codes.Unimplemented, "method WatchSessions not implemented")
+}
 func (UnimplementedSshPiperAdminServer) KillSession(context.Context, *KillSessionRequest) (*KillSessionResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method KillSession not implemented")
 }
+func (UnimplementedSshPiperAdminServer) KillSessions(context.Context, *KillSessionsRequest) (*KillSessionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method KillSessions not implemented")
+}
 func (UnimplementedSshPiperAdminServer) StreamSession(*StreamSessionRequest, grpc.ServerStreamingServer[SessionFrame]) error {
 	return status.Error(codes.Unimplemented, "method StreamSession not implemented")
 }
+func (UnimplementedSshPiperAdminServer) AttachSession(grpc.BidiStreamingServer[AttachSessionRequest, SessionFrame]) error {
+	return status.Error(codes.Unimplemented, "method AttachSession not implemented")
+}
+func (UnimplementedSshPiperAdminServer) MessageSession(context.Context, *MessageSessionRequest) (*MessageSessionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method MessageSession not implemented")
+}
+func (UnimplementedSshPiperAdminServer) PauseSession(context.Context, *PauseSessionRequest) (*PauseSessionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method PauseSession not implemented")
+}
+func (UnimplementedSshPiperAdminServer) ResumeSession(context.Context, *ResumeSessionRequest) (*ResumeSessionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ResumeSession not implemented")
+}
+func (UnimplementedSshPiperAdminServer) ReloadHostKeys(context.Context, *ReloadHostKeysRequest) (*ReloadHostKeysResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ReloadHostKeys not implemented")
+}
 func (UnimplementedSshPiperAdminServer) mustEmbedUnimplementedSshPiperAdminServer() {}
 func (UnimplementedSshPiperAdminServer) testEmbeddedByValue()                       {}
 
@@ -203,6 +466,82 @@ func _SshPiperAdmin_ListSessions_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SshPiperAdmin_GetSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperAdminServer).GetSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperAdmin_GetSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperAdminServer).GetSession(ctx, req.(*GetSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SshPiperAdmin_ListSessionHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperAdminServer).ListSessionHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperAdmin_ListSessionHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperAdminServer).ListSessionHistory(ctx, req.(*ListSessionHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SshPiperAdmin_ListRecordings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRecordingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperAdminServer).ListRecordings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperAdmin_ListRecordings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperAdminServer).ListRecordings(ctx, req.(*ListRecordingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SshPiperAdmin_GetRecording_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetRecordingRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SshPiperAdminServer).GetRecording(m, &grpc.GenericServerStream[GetRecordingRequest, RecordingChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SshPiperAdmin_GetRecordingServer = grpc.ServerStreamingServer[RecordingChunk]
+
+func _SshPiperAdmin_WatchSessions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchSessionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SshPiperAdminServer).WatchSessions(m, &grpc.GenericServerStream[WatchSessionsRequest, SessionEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SshPiperAdmin_WatchSessionsServer = grpc.ServerStreamingServer[SessionEvent]
+
 func _SshPiperAdmin_KillSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(KillSessionRequest)
 	if err := dec(in); err != nil {
@@ -221,6 +560,24 @@ func _SshPiperAdmin_KillSession_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SshPiperAdmin_KillSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KillSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperAdminServer).KillSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperAdmin_KillSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperAdminServer).KillSessions(ctx, req.(*KillSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SshPiperAdmin_StreamSession_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(StreamSessionRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -232,6 +589,85 @@ func _SshPiperAdmin_StreamSession_Handler(srv interface{}, stream grpc.ServerStr
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type SshPiperAdmin_StreamSessionServer = grpc.ServerStreamingServer[SessionFrame]
 
+func _SshPiperAdmin_AttachSession_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SshPiperAdminServer).AttachSession(&grpc.GenericServerStream[AttachSessionRequest, SessionFrame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SshPiperAdmin_AttachSessionServer = grpc.BidiStreamingServer[AttachSessionRequest, SessionFrame]
+
+func _SshPiperAdmin_MessageSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperAdminServer).MessageSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperAdmin_MessageSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperAdminServer).MessageSession(ctx, req.(*MessageSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SshPiperAdmin_PauseSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperAdminServer).PauseSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperAdmin_PauseSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperAdminServer).PauseSession(ctx, req.(*PauseSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SshPiperAdmin_ResumeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperAdminServer).ResumeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperAdmin_ResumeSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperAdminServer).ResumeSession(ctx, req.(*ResumeSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SshPiperAdmin_ReloadHostKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadHostKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperAdminServer).ReloadHostKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperAdmin_ReloadHostKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperAdminServer).ReloadHostKeys(ctx, req.(*ReloadHostKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SshPiperAdmin_ServiceDesc is the grpc.ServiceDesc for SshPiperAdmin service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -247,17 +683,65 @@ var SshPiperAdmin_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListSessions",
 			Handler:    _SshPiperAdmin_ListSessions_Handler,
 		},
+		{
+			MethodName: "GetSession",
+			Handler:    _SshPiperAdmin_GetSession_Handler,
+		},
+		{
+			MethodName: "ListSessionHistory",
+			Handler:    _SshPiperAdmin_ListSessionHistory_Handler,
+		},
+		{
+			MethodName: "ListRecordings",
+			Handler:    _SshPiperAdmin_ListRecordings_Handler,
+		},
 		{
 			MethodName: "KillSession",
 			Handler:    _SshPiperAdmin_KillSession_Handler,
 		},
+		{
+			MethodName: "KillSessions",
+			Handler:    _SshPiperAdmin_KillSessions_Handler,
+		},
+		{
+			MethodName: "MessageSession",
+			Handler:    _SshPiperAdmin_MessageSession_Handler,
+		},
+		{
+			MethodName: "PauseSession",
+			Handler:    _SshPiperAdmin_PauseSession_Handler,
+		},
+		{
+			MethodName: "ResumeSession",
+			Handler:    _SshPiperAdmin_ResumeSession_Handler,
+		},
+		{
+			MethodName: "ReloadHostKeys",
+			Handler:    _SshPiperAdmin_ReloadHostKeys_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetRecording",
+			Handler:       _SshPiperAdmin_GetRecording_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchSessions",
+			Handler:       _SshPiperAdmin_WatchSessions_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "StreamSession",
 			Handler:       _SshPiperAdmin_StreamSession_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "AttachSession",
+			Handler:       _SshPiperAdmin_AttachSession_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "admin.proto",
 }
@@ -217,6 +217,24 @@ func (a *Aggregator) ClientFor(instanceID string) *Client {
 // combined session list. Per-instance failures are returned as the second
 // value but do not abort the call.
 func (a *Aggregator) ListAllSessions(ctx context.Context) ([]AggregatedSession, []error) {
+	return a.fanOutSessions(func(c *Client) ([]*Session, error) {
+		return c.ListSessions(ctx)
+	})
+}
+
+// KillSessions asks every backend in parallel to close the sessions
+// matching req, and returns the combined list of closed sessions.
+// Per-instance failures are returned as the second value but do not abort
+// the call.
+func (a *Aggregator) KillSessions(ctx context.Context, req *KillSessionsRequest) ([]AggregatedSession, []error) {
+	return a.fanOutSessions(func(c *Client) ([]*Session, error) {
+		return c.KillSessions(ctx, req)
+	})
+}
+
+// fanOutSessions calls call on every backend in parallel and combines the
+// sessions returned.
+func (a *Aggregator) fanOutSessions(call func(*Client) ([]*Session, error)) ([]AggregatedSession, []error) {
 	a.mu.Lock()
 	type job struct {
 		id   string
@@ -239,7 +257,7 @@ func (a *Aggregator) ListAllSessions(ctx context.Context) ([]AggregatedSession,
 		wg.Add(1)
 		go func(j job) {
 			defer wg.Done()
-			sessions, err := j.c.ListSessions(ctx)
+			sessions, err := call(j.c)
 			if err != nil {
 				mu.Lock()
 				errs = append(errs, &AggregatorError{InstanceID: j.id, InstanceAddr: j.addr, Err: err})
@@ -262,11 +280,53 @@ func (a *Aggregator) ListAllSessions(ctx context.Context) ([]AggregatedSession,
 
 // KillSession routes a kill request to the named instance.
 func (a *Aggregator) KillSession(ctx context.Context, instanceID, sessionID string) (bool, error) {
+	return a.KillSessionWithReason(ctx, instanceID, sessionID, "")
+}
+
+// KillSessionWithReason routes a kill request with a reason told to the
+// client to the named instance.
+func (a *Aggregator) KillSessionWithReason(ctx context.Context, instanceID, sessionID, reason string) (bool, error) {
 	c := a.ClientFor(instanceID)
 	if c == nil {
 		return false, fmt.Errorf("unknown admin instance %q", instanceID)
 	}
-	return c.KillSession(ctx, sessionID)
+	return c.KillSessionWithReason(ctx, sessionID, reason)
+}
+
+// MessageSession routes a message request to the named instance.
+func (a *Aggregator) MessageSession(ctx context.Context, instanceID, sessionID, message string) (int32, error) {
+	c := a.ClientFor(instanceID)
+	if c == nil {
+		return 0, fmt.Errorf("unknown admin instance %q", instanceID)
+	}
+	return c.MessageSession(ctx, sessionID, message)
+}
+
+// GetSession routes a session detail request to the named instance.
+func (a *Aggregator) GetSession(ctx context.Context, instanceID, sessionID string) (*GetSessionResponse, error) {
+	c := a.ClientFor(instanceID)
+	if c == nil {
+		return nil, fmt.Errorf("unknown admin instance %q", instanceID)
+	}
+	return c.GetSession(ctx, sessionID)
+}
+
+// PauseSession routes a pause request to the named instance.
+func (a *Aggregator) PauseSession(ctx context.Context, instanceID, sessionID string) (bool, error) {
+	c := a.ClientFor(instanceID)
+	if c == nil {
+		return false, fmt.Errorf("unknown admin instance %q", instanceID)
+	}
+	return c.PauseSession(ctx, sessionID)
+}
+
+// ResumeSession routes a resume request to the named instance.
+func (a *Aggregator) ResumeSession(ctx context.Context, instanceID, sessionID string) (bool, error) {
+	c := a.ClientFor(instanceID)
+	if c == nil {
+		return false, fmt.Errorf("unknown admin instance %q", instanceID)
+	}
+	return c.ResumeSession(ctx, sessionID)
 }
 
 // StreamSession opens a server-streaming RPC against the named instance
@@ -295,3 +355,23 @@ func (a *Aggregator) StreamSession(ctx context.Context, instanceID, sessionID st
 		}
 	}
 }
+
+// AttachSession opens a bidirectional AttachSession RPC against the named
+// instance and sends first, which names the session. The caller writes
+// input with Send, detaches with CloseSend, and reads the frames of the
+// channel with Recv until io.EOF.
+func (a *Aggregator) AttachSession(ctx context.Context, instanceID string, first *AttachSessionRequest) (SshPiperAdmin_AttachSessionClient, error) {
+	c := a.ClientFor(instanceID)
+	if c == nil {
+		return nil, fmt.Errorf("unknown admin instance %q", instanceID)
+	}
+
+	stream, err := c.RPC().AttachSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(first); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
@@ -2,7 +2,10 @@ package libadmin
 
 import (
 	"context"
+	"errors"
 	"net"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -16,6 +19,18 @@ type stubServer struct {
 	addr     string
 	sessions []*Session
 	killed   string
+	reason   string
+	// history is listed by ListSessionHistory, most recently ended first,
+	// with the cursors set to the indexes
+	history []*SessionHistoryEntry
+	// recordings are listed by ListRecordings, most recently modified
+	// first, with the cursors set to the indexes, GetRecording sends their
+	// path twice
+	recordings []*Recording
+
+	// watch is closed to end the running WatchSessions streams
+	mu    sync.Mutex
+	watch chan struct{}
 }
 
 func (s *stubServer) ServerInfo(_ context.Context, _ *ServerInfoRequest) (*ServerInfoResponse, error) {
@@ -26,14 +41,91 @@ func (s *stubServer) ListSessions(_ context.Context, _ *ListSessionsRequest) (*L
 	return &ListSessionsResponse{Sessions: s.sessions}, nil
 }
 
+func (s *stubServer) ListSessionHistory(_ context.Context, req *ListSessionHistoryRequest) (*ListSessionHistoryResponse, error) {
+	start := 0
+	if req.GetPageToken() != "" {
+		i, err := strconv.Atoi(req.GetPageToken())
+		if err != nil {
+			return nil, err
+		}
+		start = i + 1
+	}
+	end := min(start+int(req.GetPageSize()), len(s.history))
+	resp := &ListSessionHistoryResponse{Sessions: s.history[start:end]}
+	if end < len(s.history) {
+		resp.NextPageToken = strconv.Itoa(end - 1)
+	}
+	return resp, nil
+}
+
+func (s *stubServer) ListRecordings(_ context.Context, req *ListRecordingsRequest) (*ListRecordingsResponse, error) {
+	start := 0
+	if req.GetPageToken() != "" {
+		i, err := strconv.Atoi(req.GetPageToken())
+		if err != nil {
+			return nil, err
+		}
+		start = i + 1
+	}
+	end := min(start+int(req.GetPageSize()), len(s.recordings))
+	resp := &ListRecordingsResponse{Recordings: s.recordings[start:end]}
+	if end < len(s.recordings) {
+		resp.NextPageToken = strconv.Itoa(end - 1)
+	}
+	return resp, nil
+}
+
+func (s *stubServer) GetRecording(req *GetRecordingRequest, stream SshPiperAdmin_GetRecordingServer) error {
+	for range 2 {
+		if err := stream.Send(&RecordingChunk{Data: []byte(req.GetPath())}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *stubServer) KillSession(_ context.Context, req *KillSessionRequest) (*KillSessionResponse, error) {
 	s.killed = req.GetId()
+	s.reason = req.GetReason()
 	return &KillSessionResponse{Killed: true}, nil
 }
 
+func (s *stubServer) KillSessions(_ context.Context, req *KillSessionsRequest) (*KillSessionsResponse, error) {
+	var out []*Session
+	for _, sess := range s.sessions {
+		if sess.GetDownstreamUser() == req.GetDownstreamUser() {
+			out = append(out, sess)
+		}
+	}
+	return &KillSessionsResponse{Sessions: out}, nil
+}
+
+// WatchSessions sends the sessions and a synced event, then waits until
+// the watch is ended.
+func (s *stubServer) WatchSessions(_ *WatchSessionsRequest, stream SshPiperAdmin_WatchSessionsServer) error {
+	s.mu.Lock()
+	sessions, end := s.sessions, s.watch
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		if err := stream.Send(&SessionEvent{Type: SessionEventType_SESSION_ADDED, Session: sess}); err != nil {
+			return err
+		}
+	}
+	if err := stream.Send(&SessionEvent{Type: SessionEventType_SESSION_SYNCED}); err != nil {
+		return err
+	}
+	select {
+	case <-stream.Context().Done():
+		return nil
+	case <-end:
+		return errors.New("watch ended")
+	}
+}
+
 func startStub(t *testing.T, id string, sessions []*Session) (*stubServer, string) {
 	t.Helper()
-	stub := &stubServer{id: id, addr: id + "-ssh", sessions: sessions}
+	stub := &stubServer{id: id, addr: id + "-ssh", sessions: sessions, watch: make(chan struct{})}
 	gs := grpc.NewServer()
 	RegisterSshPiperAdminServer(gs, stub)
 	lis, err := net.Listen("tcp", "127.0.0.1:0")
@@ -84,11 +176,16 @@ func TestAggregator_RefreshAndListAcrossInstances(t *testing.T) {
 		t.Fatalf("session→instance mapping wrong: %+v", byID)
 	}
 
-	if _, err := agg.KillSession(ctx, "piper-a", "a1"); err != nil {
+	if _, err := agg.KillSessionWithReason(ctx, "piper-a", "a1", "bye"); err != nil {
 		t.Fatalf("KillSession: %v", err)
 	}
-	if stubA.killed != "a1" {
-		t.Fatalf("stubA.killed = %q", stubA.killed)
+	if stubA.killed != "a1" || stubA.reason != "bye" {
+		t.Fatalf("stubA.killed = %q, reason = %q", stubA.killed, stubA.reason)
+	}
+
+	killed, errs := agg.KillSessions(ctx, &KillSessionsRequest{DownstreamUser: "u3"})
+	if len(errs) != 0 || len(killed) != 1 || killed[0].InstanceID != "piper-b" || killed[0].Session.GetId() != "b1" {
+		t.Fatalf("KillSessions = %+v, %v", killed, errs)
 	}
 
 	if _, err := agg.KillSession(ctx, "unknown", "x"); err == nil {
@@ -0,0 +1,98 @@
+package libadmin
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// OnBehalfOfMetadataKey is the gRPC metadata key naming who a client, such
+// as sshpiperd-admin serve, calls the admin API for. sshpiperd records it in
+// its audit log next to the client certificate, which it does not replace.
+const OnBehalfOfMetadataKey = "x-sshpiper-admin-on-behalf-of"
+
+// WithOnBehalfOf returns ctx sending who in the OnBehalfOfMetadataKey
+// metadata of the rpcs made with it.
+func WithOnBehalfOf(ctx context.Context, who string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, OnBehalfOfMetadataKey, who)
+}
+
+// AuditRecord is one line of the audit log of sshpiperd, a JSONL file with
+// a record per admin rpc.
+type AuditRecord struct {
+	// Time is when the rpc was called, or ended for the end record of a
+	// streaming rpc, see Stream.
+	Time time.Time `json:"time"`
+	// RPC is the name of the rpc, e.g. KillSession.
+	RPC string `json:"rpc"`
+	// Caller is the subject of the client certificate, empty without mTLS.
+	Caller     string `json:"caller,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	// Role is the role of the caller, empty without a policy.
+	Role string `json:"role,omitempty"`
+	// OnBehalfOf is the OnBehalfOfMetadataKey metadata of the rpc, e.g. the
+	// key fingerprint of a sshpiperd-admin serve user.
+	OnBehalfOf string `json:"on_behalf_of,omitempty"`
+	// Sessions are the ids of the sessions the rpc targeted, or killed for
+	// KillSessions.
+	Sessions []string `json:"sessions,omitempty"`
+	// Recording is the path of the file of GetRecording.
+	Recording string `json:"recording,omitempty"`
+	// Result is the gRPC status code of the rpc, e.g. OK or
+	// PermissionDenied, and Error its message.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// DurationMs is how long a streaming rpc lasted.
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	// Stream is start on the record written once a streaming rpc received
+	// its first request, which has no Result yet, and end on the one written
+	// when it ended. It is empty for unary rpcs.
+	Stream string `json:"stream,omitempty"`
+	// PrevHash is the hex SHA-256 of the previous line of the log, empty on
+	// the first one, so that removing or changing a line breaks the chain.
+	PrevHash string `json:"prev_hash"`
+}
+
+// AuditLineHash returns the hash of a line of the audit log, without its
+// trailing newline, to put in the PrevHash of the next record.
+func AuditLineHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditLog reads an audit log and checks its hash chain. It returns
+// the number of records and the hash of the last line, even when the
+// chain is broken, in which case the error names the first bad line.
+func VerifyAuditLog(r io.Reader) (int, string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var (
+		n        int
+		prev     string
+		chainErr error
+	)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		n++
+		if chainErr == nil {
+			var rec AuditRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				chainErr = fmt.Errorf("audit log line %d: %w", n, err)
+			} else if rec.PrevHash != prev {
+				chainErr = fmt.Errorf("audit log line %d: prev_hash %q does not match the previous line %q", n, rec.PrevHash, prev)
+			}
+		}
+		prev = AuditLineHash(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return n, prev, err
+	}
+	return n, prev, chainErr
+}
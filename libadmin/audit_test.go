@@ -0,0 +1,48 @@
+package libadmin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithOnBehalfOf(t *testing.T) {
+	md, _ := metadata.FromOutgoingContext(WithOnBehalfOf(context.Background(), "alice SHA256:abc"))
+	if got := md.Get(OnBehalfOfMetadataKey); len(got) != 1 || got[0] != "alice SHA256:abc" {
+		t.Fatalf("metadata = %v", md)
+	}
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	line1 := `{"time":"2026-01-01T00:00:00Z","rpc":"ListSessions","result":"OK","prev_hash":""}`
+	line2 := `{"time":"2026-01-01T00:00:01Z","rpc":"KillSession","sessions":["s1"],"result":"OK","prev_hash":"` + AuditLineHash([]byte(line1)) + `"}`
+	line3 := `{"time":"2026-01-01T00:00:02Z","rpc":"KillSession","sessions":["s2"],"result":"OK","prev_hash":"` + AuditLineHash([]byte(line2)) + `"}`
+
+	n, last, err := VerifyAuditLog(strings.NewReader(line1 + "\n" + line2 + "\n" + line3 + "\n"))
+	if n != 3 || last != AuditLineHash([]byte(line3)) || err != nil {
+		t.Fatalf("VerifyAuditLog = %d, %s, %v", n, last, err)
+	}
+	if n, _, err := VerifyAuditLog(strings.NewReader("")); n != 0 || err != nil {
+		t.Fatalf("VerifyAuditLog of an empty log = %d, %v", n, err)
+	}
+
+	for name, tc := range map[string]struct {
+		log, want string
+	}{
+		"first removed":  {line2 + "\n" + line3 + "\n", "line 1"},
+		"middle removed": {line1 + "\n" + line3 + "\n", "line 2"},
+		"changed":        {line1 + "\n" + strings.Replace(line2, "s1", "s9", 1) + "\n" + line3 + "\n", "line 3"},
+		"not json":       {line1 + "\nnope\n", "line 2"},
+	} {
+		n, last, err := VerifyAuditLog(strings.NewReader(tc.log))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+		}
+		lines := strings.Split(strings.TrimSpace(tc.log), "\n")
+		if n != len(lines) || last != AuditLineHash([]byte(lines[len(lines)-1])) {
+			t.Errorf("%s: VerifyAuditLog = %d, %s", name, n, last)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
 	"os"
 	"sync"
 
@@ -116,22 +117,113 @@ func (c *Client) ListSessions(ctx context.Context) ([]*Session, error) {
 	return resp.GetSessions(), nil
 }
 
+// GetSession returns session id of this sshpiperd instance with its
+// channels, algorithms and versions.
+func (c *Client) GetSession(ctx context.Context, id string) (*GetSessionResponse, error) {
+	return c.rpc.GetSession(ctx, &GetSessionRequest{Id: id})
+}
+
+// ListSessionHistory returns a page of the finished sessions of this
+// sshpiperd instance matching req, most recently ended first.
+func (c *Client) ListSessionHistory(ctx context.Context, req *ListSessionHistoryRequest) (*ListSessionHistoryResponse, error) {
+	return c.rpc.ListSessionHistory(ctx, req)
+}
+
+// ListRecordings returns a page of the screen recordings of this sshpiperd
+// instance matching req, most recently modified first.
+func (c *Client) ListRecordings(ctx context.Context, req *ListRecordingsRequest) (*ListRecordingsResponse, error) {
+	return c.rpc.ListRecordings(ctx, req)
+}
+
+// GetRecording copies the recording file path, as listed by
+// ListRecordings, of this sshpiperd instance to w.
+func (c *Client) GetRecording(ctx context.Context, path string, w io.Writer) error {
+	stream, err := c.rpc.GetRecording(ctx, &GetRecordingRequest{Path: path})
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk.GetData()); err != nil {
+			return err
+		}
+	}
+}
+
 // KillSession asks this sshpiperd instance to close session id.
 func (c *Client) KillSession(ctx context.Context, id string) (bool, error) {
-	resp, err := c.rpc.KillSession(ctx, &KillSessionRequest{Id: id})
+	return c.KillSessionWithReason(ctx, id, "")
+}
+
+// KillSessionWithReason is KillSession telling the client reason when not
+// empty.
+func (c *Client) KillSessionWithReason(ctx context.Context, id, reason string) (bool, error) {
+	resp, err := c.rpc.KillSession(ctx, &KillSessionRequest{Id: id, Reason: reason})
 	if err != nil {
 		return false, err
 	}
 	return resp.GetKilled(), nil
 }
 
+// KillSessions asks this sshpiperd instance to close every session matching
+// req, and returns them.
+func (c *Client) KillSessions(ctx context.Context, req *KillSessionsRequest) ([]*Session, error) {
+	resp, err := c.rpc.KillSessions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetSessions(), nil
+}
+
+// MessageSession asks this sshpiperd instance to write message to the
+// stderr of the session channels of session id, and returns how many
+// channels it was written to.
+func (c *Client) MessageSession(ctx context.Context, id, message string) (int32, error) {
+	resp, err := c.rpc.MessageSession(ctx, &MessageSessionRequest{Id: id, Message: message})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetChannels(), nil
+}
+
+// PauseSession asks this sshpiperd instance to stop forwarding the input of
+// session id until ResumeSession.
+func (c *Client) PauseSession(ctx context.Context, id string) (bool, error) {
+	resp, err := c.rpc.PauseSession(ctx, &PauseSessionRequest{Id: id})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetPaused(), nil
+}
+
+// ResumeSession asks this sshpiperd instance to forward the input of session
+// id again.
+func (c *Client) ResumeSession(ctx context.Context, id string) (bool, error) {
+	resp, err := c.rpc.ResumeSession(ctx, &ResumeSessionRequest{Id: id})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetResumed(), nil
+}
+
+// ReloadHostKeys asks this sshpiperd instance to reload its host keys.
+func (c *Client) ReloadHostKeys(ctx context.Context) (*ReloadHostKeysResponse, error) {
+	return c.rpc.ReloadHostKeys(ctx, &ReloadHostKeysRequest{})
+}
+
 // Discovery resolves the set of sshpiperd instances the admin tool should
 // talk to. The aggregator calls Endpoints periodically (or on demand) so
 // implementations may return a freshly-resolved list each time.
 //
 // A static, command-line-supplied list is the simplest implementation;
-// future implementations could pull from DNS SRV records, Kubernetes
-// services, or a service registry.
+// see also DNSDiscovery, FileDiscovery, MultiDiscovery and the
+// libadmin/kubediscovery package.
 type Discovery interface {
 	Endpoints(ctx context.Context) ([]string, error)
 }
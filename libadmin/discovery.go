@@ -0,0 +1,202 @@
+package libadmin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveryWatcher is a Discovery that can tell when its endpoints may have
+// changed, so that they are refreshed right away rather than on the next
+// periodic Refresh.
+type DiscoveryWatcher interface {
+	Discovery
+	// Watch returns a channel receiving a value after each change, closed
+	// once ctx is done.
+	Watch(ctx context.Context) <-chan struct{}
+}
+
+// resolver is the part of net.Resolver used by DNSDiscovery.
+type resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DNSDiscovery resolves the endpoints from DNS on every call, e.g. the
+// records of a headless Kubernetes service, which has one per ready pod.
+type DNSDiscovery struct {
+	host     string
+	port     string
+	srv      bool
+	resolver resolver
+}
+
+// NewDNSDiscovery returns a DNSDiscovery connecting to every A and AAAA
+// record of the host of hostport on its port.
+func NewDNSDiscovery(hostport string) (*DNSDiscovery, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, fmt.Errorf("dns discovery: %w", err)
+	}
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("dns discovery: %q must be host:port", hostport)
+	}
+	return &DNSDiscovery{host: host, port: port, resolver: net.DefaultResolver}, nil
+}
+
+// NewSRVDiscovery returns a DNSDiscovery connecting to the target and port
+// of every SRV record of name, e.g.
+// _admin._tcp.sshpiperd.default.svc.cluster.local.
+func NewSRVDiscovery(name string) *DNSDiscovery {
+	return &DNSDiscovery{host: name, srv: true, resolver: net.DefaultResolver}
+}
+
+// Endpoints implements Discovery.
+func (d *DNSDiscovery) Endpoints(ctx context.Context) ([]string, error) {
+	var addrs []string
+	if d.srv {
+		_, records, err := d.resolver.LookupSRV(ctx, "", "", d.host)
+		if err != nil {
+			return nil, fmt.Errorf("lookup SRV %s: %w", d.host, err)
+		}
+		for _, r := range records {
+			addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), fmt.Sprint(r.Port)))
+		}
+	} else {
+		ips, err := d.resolver.LookupHost(ctx, d.host)
+		if err != nil {
+			return nil, fmt.Errorf("lookup %s: %w", d.host, err)
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, d.port))
+		}
+	}
+	slices.Sort(addrs)
+	return slices.Compact(addrs), nil
+}
+
+// FileDiscovery reads the endpoints from a file on every call, one host:port
+// per line. Blank lines and lines starting with # are ignored. It watches
+// the file for changes.
+type FileDiscovery struct {
+	path         string
+	pollInterval time.Duration
+}
+
+// NewFileDiscovery returns a FileDiscovery reading path.
+func NewFileDiscovery(path string) *FileDiscovery {
+	return &FileDiscovery{path: path, pollInterval: 2 * time.Second}
+}
+
+// Endpoints implements Discovery. A missing file is an error rather than
+// no endpoints, so that Refresh keeps the instances while the file is
+// being replaced.
+func (f *FileDiscovery) Endpoints(_ context.Context) ([]string, error) {
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("file discovery: %w", err)
+	}
+	var addrs []string
+	for line := range bytes.Lines(b) {
+		addr := strings.TrimSpace(string(line))
+		if addr == "" || strings.HasPrefix(addr, "#") {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// Watch implements DiscoveryWatcher. It polls the modification time and
+// size of the file, which also catches it being replaced by a rename, as
+// Kubernetes does with ConfigMap volumes.
+func (f *FileDiscovery) Watch(ctx context.Context) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	stamp := func() string {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprint(info.ModTime().UnixNano(), info.Size())
+	}
+	last := stamp()
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(f.pollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			if s := stamp(); s != last {
+				last = s
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// MultiDiscovery is the union of the endpoints of several Discoverys.
+type MultiDiscovery []Discovery
+
+// Endpoints implements Discovery. It fails if any of the Discoverys fails,
+// rather than returning only some endpoints, which Refresh would take as
+// the others being gone.
+func (m MultiDiscovery) Endpoints(ctx context.Context) ([]string, error) {
+	var addrs []string
+	for _, d := range m {
+		a, err := d.Endpoints(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range a {
+			if !slices.Contains(addrs, addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// Watch implements DiscoveryWatcher, merging the changes of the
+// Discoverys that are DiscoveryWatchers.
+func (m MultiDiscovery) Watch(ctx context.Context) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	var watches []<-chan struct{}
+	for _, d := range m {
+		if w, ok := d.(DiscoveryWatcher); ok {
+			watches = append(watches, w.Watch(ctx))
+		}
+	}
+	var wg sync.WaitGroup
+	for _, w := range watches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range w {
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,121 @@
+package libadmin
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if a, ok := r.hosts[host]; ok {
+		return a, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	if a, ok := r.srvs[name]; ok {
+		return name, a, nil
+	}
+	return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	res := &fakeResolver{
+		hosts: map[string][]string{"sshpiperd": {"10.0.0.2", "10.0.0.1", "fd00::1", "10.0.0.1"}},
+		srvs: map[string][]*net.SRV{"_admin._tcp.sshpiperd": {
+			{Target: "b.sshpiperd.", Port: 2223},
+			{Target: "a.sshpiperd.", Port: 2222},
+		}},
+	}
+
+	d, err := NewDNSDiscovery("sshpiperd:2222")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.resolver = res
+	got, err := d.Endpoints(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.1:2222", "10.0.0.2:2222", "[fd00::1]:2222"}; !slices.Equal(got, want) {
+		t.Fatalf("A endpoints = %v, want %v", got, want)
+	}
+
+	s := NewSRVDiscovery("_admin._tcp.sshpiperd")
+	s.resolver = res
+	got, err = s.Endpoints(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.sshpiperd:2222", "b.sshpiperd:2223"}; !slices.Equal(got, want) {
+		t.Fatalf("SRV endpoints = %v, want %v", got, want)
+	}
+
+	d.host = "gone"
+	if _, err := d.Endpoints(context.Background()); err == nil {
+		t.Fatal("expected an error for a missing host")
+	}
+	for _, bad := range []string{"sshpiperd", ":2222", "sshpiperd:"} {
+		if _, err := NewDNSDiscovery(bad); err == nil {
+			t.Fatalf("NewDNSDiscovery(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestFileDiscovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints")
+	if err := os.WriteFile(path, []byte("# sshpiperd\n\n a:2222 \nb:2222\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d := NewFileDiscovery(path)
+	d.pollInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := MultiDiscovery{NewStaticDiscovery([]string{"b:2222", "c:2222"}), d}.Watch(ctx)
+
+	got, err := MultiDiscovery{d, NewStaticDiscovery([]string{"b:2222", "c:2222"})}.Endpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a:2222", "b:2222", "c:2222"}; !slices.Equal(got, want) {
+		t.Fatalf("endpoints = %v, want %v", got, want)
+	}
+
+	// replaced by a rename, like a ConfigMap volume
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("d:2222\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change seen")
+	}
+	if got, err := d.Endpoints(ctx); err != nil || !slices.Equal(got, []string{"d:2222"}) {
+		t.Fatalf("endpoints = %v, %v", got, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (MultiDiscovery{d}).Endpoints(ctx); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+
+	cancel()
+	for range changes {
+	}
+}
@@ -0,0 +1,58 @@
+package libadmin
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultHistoryPageSize is the page size of ListSessionHistory when the
+// request leaves it unset.
+const DefaultHistoryPageSize = 50
+
+// maxHistoryPageSize is the largest page sshpiperd returns.
+const maxHistoryPageSize = 1000
+
+// ErrBadHistoryPageToken is returned by Aggregator.ListSessionHistory for
+// a page token it did not hand out.
+var ErrBadHistoryPageToken = errors.New("invalid history page token")
+
+// AggregatedHistoryEntry is one finished session as seen by the
+// aggregator, with the instance it ran on.
+type AggregatedHistoryEntry struct {
+	InstanceID   string
+	InstanceAddr string
+	Entry        *SessionHistoryEntry
+}
+
+// ListSessionHistory queries every backend in parallel and returns a page
+// of their finished sessions matching req, most recently ended first, and
+// the token of the next page, empty on the last one. req.PageToken is a
+// token returned by a previous call, not one of a single instance.
+// Per-instance failures are returned as the third value but do not abort
+// the call; the failed instances are queried again for the next page.
+func (a *Aggregator) ListSessionHistory(ctx context.Context, req *ListSessionHistoryRequest) ([]AggregatedHistoryEntry, string, []error) {
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = DefaultHistoryPageSize
+	}
+	pageSize = min(pageSize, maxHistoryPageSize)
+
+	page, next, errs := aggregatePages(ctx, a, req.GetPageToken(), pageSize, ErrBadHistoryPageToken,
+		func(ctx context.Context, c *Client, token string) ([]*SessionHistoryEntry, string, error) {
+			r := proto.Clone(req).(*ListSessionHistoryRequest)
+			r.PageSize = int32(pageSize) //nolint:gosec // at most maxHistoryPageSize
+			r.PageToken = token
+			resp, err := c.ListSessionHistory(ctx, r)
+			return resp.GetSessions(), resp.GetNextPageToken(), err
+		},
+		func(x, y *SessionHistoryEntry) bool { return x.GetEndedAt() > y.GetEndedAt() },
+		(*SessionHistoryEntry).GetCursor,
+	)
+	out := make([]AggregatedHistoryEntry, 0, len(page))
+	for _, e := range page {
+		out = append(out, AggregatedHistoryEntry{InstanceID: e.instanceID, InstanceAddr: e.instanceAddr, Entry: e.entry})
+	}
+	return out, next, errs
+}
@@ -0,0 +1,70 @@
+package libadmin
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stubHistory returns entries ending at the given times, with ids prefix
+// and their index.
+func stubHistory(prefix string, ended ...int64) []*SessionHistoryEntry {
+	out := make([]*SessionHistoryEntry, len(ended))
+	for i, e := range ended {
+		out[i] = &SessionHistoryEntry{
+			Session: &Session{Id: prefix + strconv.Itoa(i)},
+			EndedAt: e,
+			Cursor:  strconv.Itoa(i),
+		}
+	}
+	return out
+}
+
+func TestAggregator_ListSessionHistory(t *testing.T) {
+	stubA, addrA := startStub(t, "piper-a", nil)
+	stubA.history = stubHistory("a", 100, 80, 60, 40)
+	stubB, addrB := startStub(t, "piper-b", nil)
+	stubB.history = stubHistory("b", 90, 50)
+
+	agg := NewAggregator(NewStaticDiscovery([]string{addrA, addrB}), DialOptions{Insecure: true})
+	defer agg.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, errs := agg.Refresh(ctx); len(errs) != 0 {
+		t.Fatalf("Refresh errors: %v", errs)
+	}
+
+	var got []string
+	token := ""
+	for page := 0; ; page++ {
+		if page > 3 {
+			t.Fatalf("too many pages, got %v", got)
+		}
+		entries, next, errs := agg.ListSessionHistory(ctx, &ListSessionHistoryRequest{PageSize: 2, PageToken: token})
+		if len(errs) != 0 {
+			t.Fatalf("ListSessionHistory errors: %v", errs)
+		}
+		if len(entries) > 2 {
+			t.Fatalf("page %d has %d entries", page, len(entries))
+		}
+		for _, e := range entries {
+			got = append(got, e.InstanceID+"/"+e.Entry.GetSession().GetId())
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	want := []string{"piper-a/a0", "piper-b/b0", "piper-a/a1", "piper-a/a2", "piper-b/b1", "piper-a/a3"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("history = %v, want %v", got, want)
+	}
+
+	if _, _, errs := agg.ListSessionHistory(ctx, &ListSessionHistoryRequest{PageToken: "!"}); len(errs) != 1 {
+		t.Fatalf("bad token errors = %v", errs)
+	}
+}
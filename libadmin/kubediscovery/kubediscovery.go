@@ -0,0 +1,150 @@
+// Package kubediscovery implements a libadmin.Discovery of the sshpiperd
+// pods behind Kubernetes services, from their EndpointSlices. It is kept out
+// of libadmin so that the users of libadmin, sshpiperd among them, do not
+// depend on client-go.
+package kubediscovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// retryInterval is how long Watch waits before watching again after the
+// API server refused a watch.
+const retryInterval = 5 * time.Second
+
+// Discovery lists the ready endpoints of the EndpointSlices matching a label
+// selector. The EndpointSlice controller copies the labels of a service to
+// its slices, so the selector may be the one of the sshpiperd services, or
+// kubernetes.io/service-name=<service>.
+type Discovery struct {
+	client    kubernetes.Interface
+	namespace string
+	selector  string
+	port      string
+}
+
+// New returns a Discovery of the EndpointSlices of namespace, all of them
+// when empty, matching selector. port is the name or number of the admin
+// gRPC port in the slices, which may be empty when they have a single port.
+func New(client kubernetes.Interface, namespace, selector, port string) *Discovery {
+	return &Discovery{client: client, namespace: namespace, selector: selector, port: port}
+}
+
+// NewFromKubeconfig returns a Discovery using the kubeconfig at path, or
+// the default loading rules and the in-cluster config when empty. An empty
+// namespace is the one of the kubeconfig.
+func NewFromKubeconfig(path, namespace, selector, port string) (*Discovery, error) {
+	loader := clientcmd.NewDefaultClientConfigLoadingRules()
+	loader.ExplicitPath = path
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, &clientcmd.ConfigOverrides{})
+
+	config, err := kubeConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		if namespace, _, err = kubeConfig.Namespace(); err != nil {
+			return nil, err
+		}
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return New(client, namespace, selector, port), nil
+}
+
+// Endpoints implements libadmin.Discovery.
+func (d *Discovery) Endpoints(ctx context.Context) ([]string, error) {
+	list, err := d.client.DiscoveryV1().EndpointSlices(d.namespace).List(ctx, metav1.ListOptions{LabelSelector: d.selector})
+	if err != nil {
+		return nil, fmt.Errorf("list endpointslices %q: %w", d.selector, err)
+	}
+	var addrs []string
+	for _, s := range list.Items {
+		port, ok := d.slicePort(&s)
+		if !ok {
+			continue
+		}
+		for _, e := range s.Endpoints {
+			// nil is unknown, which consumers must take as ready
+			if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+				continue
+			}
+			for _, a := range e.Addresses {
+				addrs = append(addrs, net.JoinHostPort(a, strconv.Itoa(int(port))))
+			}
+		}
+	}
+	slices.Sort(addrs)
+	return slices.Compact(addrs), nil
+}
+
+// slicePort returns the admin port of s.
+func (d *Discovery) slicePort(s *discoveryv1.EndpointSlice) (int32, bool) {
+	for _, p := range s.Ports {
+		if p.Port == nil {
+			continue
+		}
+		switch {
+		case d.port == "" && len(s.Ports) == 1,
+			p.Name != nil && *p.Name == d.port,
+			strconv.Itoa(int(*p.Port)) == d.port:
+			return *p.Port, true
+		}
+	}
+	return 0, false
+}
+
+// Watch implements libadmin.DiscoveryWatcher, with a value for every event
+// of the matching EndpointSlices, such as a pod scaled up becoming ready.
+func (d *Discovery) Watch(ctx context.Context) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			w, err := d.client.DiscoveryV1().EndpointSlices(d.namespace).Watch(ctx, metav1.ListOptions{LabelSelector: d.selector})
+			if err != nil {
+				slog.Debug("watch endpointslices failed", "selector", d.selector, "error", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(retryInterval):
+				}
+				continue
+			}
+			d.forward(ctx, w, ch)
+		}
+	}()
+	return ch
+}
+
+// forward sends a value to ch for the events of w until it ends.
+func (d *Discovery) forward(ctx context.Context, w watch.Interface, ch chan<- struct{}) {
+	defer w.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
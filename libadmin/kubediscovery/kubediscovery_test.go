@@ -0,0 +1,90 @@
+package kubediscovery
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
+
+func endpointSlice(name string, labels map[string]string, ports []discoveryv1.EndpointPort, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta:  metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       ports,
+		Endpoints:   endpoints,
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	app := map[string]string{"app": "sshpiperd"}
+	ports := []discoveryv1.EndpointPort{
+		{Name: ptrTo("ssh"), Port: ptrTo[int32](2222)},
+		{Name: ptrTo("admin"), Port: ptrTo[int32](2223)},
+	}
+	client := fake.NewClientset(
+		endpointSlice("a", app, ports,
+			discoveryv1.Endpoint{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: ptrTo(true)}},
+			discoveryv1.Endpoint{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Ready: ptrTo(false)}},
+		),
+		endpointSlice("b", app, ports,
+			discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}},
+		),
+		endpointSlice("other", map[string]string{"app": "sshd"}, ports,
+			discoveryv1.Endpoint{Addresses: []string{"10.0.1.1"}},
+		),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for port, want := range map[string][]string{
+		"admin": {"10.0.0.1:2223", "10.0.0.2:2223"},
+		"2222":  {"10.0.0.1:2222", "10.0.0.2:2222"},
+		// ambiguous
+		"": nil,
+	} {
+		got, err := New(client, "default", "app=sshpiperd", port).Endpoints(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("port %q: endpoints = %v, want %v", port, got, want)
+		}
+	}
+
+	d := New(client, "", "app=sshpiperd", "")
+	changes := d.Watch(ctx)
+	// the fake clientset only sends the events after the watch started
+	time.Sleep(100 * time.Millisecond)
+	_, err := client.DiscoveryV1().EndpointSlices("default").Create(ctx, endpointSlice("c", app,
+		[]discoveryv1.EndpointPort{{Port: ptrTo[int32](2223)}},
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.4"}},
+	), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change seen")
+	}
+	got, err := d.Endpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.4:2223"}; !slices.Equal(got, want) {
+		t.Fatalf("endpoints = %v, want %v", got, want)
+	}
+
+	cancel()
+	for range changes {
+	}
+}
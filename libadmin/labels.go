@@ -0,0 +1,47 @@
+package libadmin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseLabelSelector parses key=value pairs, e.g. from repeated --label
+// flags, into a selector for MatchLabels.
+func ParseLabelSelector(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	selector := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label selector %q, want key=value", pair)
+		}
+		selector[key] = value
+	}
+	return selector, nil
+}
+
+// MatchLabels reports whether labels has every entry of selector. An empty
+// selector matches every session.
+func MatchLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatLabels returns labels as key=value pairs sorted by key and joined
+// by commas.
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,45 @@
+package libadmin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	got, err := ParseLabelSelector([]string{"team=db", "env=", "ticket=INC=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"team": "db", "env": "", "ticket": "INC=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLabelSelector() = %v, want %v", got, want)
+	}
+
+	for _, pair := range []string{"team", "=db"} {
+		if _, err := ParseLabelSelector([]string{pair}); err == nil {
+			t.Errorf("ParseLabelSelector(%q) should fail", pair)
+		}
+	}
+}
+
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{"team": "db", "env": "prod"}
+
+	for _, tt := range []struct {
+		selector map[string]string
+		want     bool
+	}{
+		{nil, true},
+		{map[string]string{"team": "db"}, true},
+		{map[string]string{"team": "db", "env": "prod"}, true},
+		{map[string]string{"team": "web"}, false},
+		{map[string]string{"ticket": ""}, false},
+	} {
+		if got := MatchLabels(labels, tt.selector); got != tt.want {
+			t.Errorf("MatchLabels(%v) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+
+	if got := FormatLabels(labels); got != "env=prod,team=db" {
+		t.Errorf("FormatLabels() = %q", got)
+	}
+}
@@ -0,0 +1,115 @@
+package libadmin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// pageEntry is an entry of a page merged by aggregatePages, with the
+// instance it was listed by.
+type pageEntry[T any] struct {
+	instanceID   string
+	instanceAddr string
+	entry        T
+}
+
+// pageLister lists the page of c after token, returning its entries and
+// the token of its next page.
+type pageLister[T any] func(ctx context.Context, c *Client, token string) ([]T, string, error)
+
+// aggregatePages queries every backend in parallel with list and merges
+// their pages into one of at most pageSize entries ordered by less, and the
+// token of the next page, empty on the last one. Every backend must list
+// its entries in the order of less, and cursor must return the token
+// listing the entries of a backend after one of them. pageToken is a token
+// returned by a previous call, refused with badToken otherwise. Per-instance
+// failures are returned as the third value but do not abort the call; the
+// failed instances are queried again for the next page.
+func aggregatePages[T any](ctx context.Context, a *Aggregator, pageToken string, pageSize int, badToken error, list pageLister[T], less func(x, y T) bool, cursor func(T) string) ([]pageEntry[T], string, []error) {
+	// tokens holds the token of every instance not yet exhausted, all of
+	// them on the first page
+	var tokens map[string]string
+	if pageToken != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil || json.Unmarshal(raw, &tokens) != nil {
+			return nil, "", []error{badToken}
+		}
+	}
+
+	a.mu.Lock()
+	type job struct {
+		id    string
+		addr  string
+		c     *Client
+		token string
+
+		entries []T
+		next    string
+		err     error
+	}
+	jobs := make([]*job, 0, len(a.infos))
+	for id, cache := range a.infos {
+		token, ok := tokens[id]
+		if tokens != nil && !ok {
+			continue
+		}
+		jobs = append(jobs, &job{id: id, addr: cache.Addr, c: a.clients[cache.Addr], token: token})
+	}
+	a.mu.Unlock()
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].id < jobs[k].id })
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			j.entries, j.next, j.err = list(ctx, j.c, j.token)
+		}(j)
+	}
+	wg.Wait()
+
+	// every instance lists its entries in the order of less, so the stable
+	// sort keeps the entries taken from each one a prefix of its page
+	var out []pageEntry[T]
+	var errs []error
+	for _, j := range jobs {
+		if j.err != nil {
+			errs = append(errs, &AggregatorError{InstanceID: j.id, InstanceAddr: j.addr, Err: j.err})
+			continue
+		}
+		for _, e := range j.entries {
+			out = append(out, pageEntry[T]{instanceID: j.id, instanceAddr: j.addr, entry: e})
+		}
+	}
+	sort.SliceStable(out, func(i, k int) bool {
+		return less(out[i].entry, out[k].entry)
+	})
+	if len(out) > pageSize {
+		out = out[:pageSize]
+	}
+
+	taken := make(map[string]int)
+	for _, e := range out {
+		taken[e.instanceID]++
+	}
+	next := make(map[string]string)
+	for _, j := range jobs {
+		n := taken[j.id]
+		switch {
+		case j.err != nil || n == 0 && len(j.entries) > 0:
+			next[j.id] = j.token
+		case n < len(j.entries):
+			next[j.id] = cursor(j.entries[n-1])
+		case j.next != "":
+			next[j.id] = j.next
+		}
+	}
+	if len(next) == 0 {
+		return out, "", errs
+	}
+	raw, _ := json.Marshal(next)
+	return out, base64.RawURLEncoding.EncodeToString(raw), errs
+}
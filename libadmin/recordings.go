@@ -0,0 +1,74 @@
+package libadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// AggregatedRecording is a screen recording as seen by the aggregator, with
+// the instance it is stored on.
+type AggregatedRecording struct {
+	InstanceID   string
+	InstanceAddr string
+	Recording    *Recording
+}
+
+// DefaultRecordingsPageSize is the page size of ListRecordings when the
+// request leaves it unset.
+const DefaultRecordingsPageSize = 50
+
+// maxRecordingsPageSize is the largest page sshpiperd returns.
+const maxRecordingsPageSize = 1000
+
+// ErrBadRecordingsPageToken is returned by Aggregator.ListRecordings for a
+// page token it did not hand out.
+var ErrBadRecordingsPageToken = errors.New("invalid recordings page token")
+
+// ListRecordings queries every backend in parallel and returns a page of
+// their recordings matching req, most recently modified first, and the
+// token of the next page, empty on the last one. req.PageToken is a token
+// returned by a previous call, not one of a single instance. Per-instance
+// failures are returned as the third value but do not abort the call; the
+// failed instances are queried again for the next page.
+func (a *Aggregator) ListRecordings(ctx context.Context, req *ListRecordingsRequest) ([]AggregatedRecording, string, []error) {
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = DefaultRecordingsPageSize
+	}
+	pageSize = min(pageSize, maxRecordingsPageSize)
+
+	page, next, errs := aggregatePages(ctx, a, req.GetPageToken(), pageSize, ErrBadRecordingsPageToken,
+		func(ctx context.Context, c *Client, token string) ([]*Recording, string, error) {
+			r := proto.Clone(req).(*ListRecordingsRequest)
+			r.PageSize = int32(pageSize) //nolint:gosec // at most maxRecordingsPageSize
+			r.PageToken = token
+			resp, err := c.ListRecordings(ctx, r)
+			return resp.GetRecordings(), resp.GetNextPageToken(), err
+		},
+		func(x, y *Recording) bool {
+			if x.GetModifiedAt() != y.GetModifiedAt() {
+				return x.GetModifiedAt() > y.GetModifiedAt()
+			}
+			return x.GetPath() < y.GetPath()
+		},
+		(*Recording).GetCursor,
+	)
+	out := make([]AggregatedRecording, 0, len(page))
+	for _, e := range page {
+		out = append(out, AggregatedRecording{InstanceID: e.instanceID, InstanceAddr: e.instanceAddr, Recording: e.entry})
+	}
+	return out, next, errs
+}
+
+// GetRecording copies the recording file path of the named instance to w.
+func (a *Aggregator) GetRecording(ctx context.Context, instanceID, path string, w io.Writer) error {
+	c := a.ClientFor(instanceID)
+	if c == nil {
+		return fmt.Errorf("unknown admin instance %q", instanceID)
+	}
+	return c.GetRecording(ctx, path, w)
+}
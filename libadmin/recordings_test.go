@@ -0,0 +1,65 @@
+package libadmin
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAggregator_Recordings(t *testing.T) {
+	stubA, addrA := startStub(t, "piper-a", nil)
+	stubA.recordings = []*Recording{{Path: "s1/shell-channel-0.cast", ModifiedAt: 100, Cursor: "0"}, {Path: "s2/1.typescript", ModifiedAt: 50, Cursor: "1"}}
+	stubB, addrB := startStub(t, "piper-b", nil)
+	stubB.recordings = []*Recording{{Path: "s3/shell-channel-0.cast", ModifiedAt: 80, Cursor: "0"}}
+
+	agg := NewAggregator(NewStaticDiscovery([]string{addrA, addrB}), DialOptions{Insecure: true})
+	defer agg.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, errs := agg.Refresh(ctx); len(errs) != 0 {
+		t.Fatalf("Refresh errors: %v", errs)
+	}
+
+	var got []string
+	token := ""
+	for page := 0; ; page++ {
+		if page > 3 {
+			t.Fatalf("too many pages, got %v", got)
+		}
+		recordings, next, errs := agg.ListRecordings(ctx, &ListRecordingsRequest{PageSize: 2, PageToken: token})
+		if len(errs) != 0 {
+			t.Fatalf("ListRecordings errors: %v", errs)
+		}
+		if len(recordings) > 2 {
+			t.Fatalf("page %d has %d recordings", page, len(recordings))
+		}
+		for _, r := range recordings {
+			got = append(got, r.InstanceID+"/"+r.Recording.GetPath())
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	want := []string{"piper-a/s1/shell-channel-0.cast", "piper-b/s3/shell-channel-0.cast", "piper-a/s2/1.typescript"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("ListRecordings = %v, want %v", got, want)
+	}
+	if _, _, errs := agg.ListRecordings(ctx, &ListRecordingsRequest{PageToken: "!"}); len(errs) != 1 {
+		t.Fatalf("bad token errors = %v", errs)
+	}
+
+	var buf bytes.Buffer
+	if err := agg.GetRecording(ctx, "piper-b", "s3/shell-channel-0.cast", &buf); err != nil {
+		t.Fatalf("GetRecording: %v", err)
+	}
+	if buf.String() != "s3/shell-channel-0.casts3/shell-channel-0.cast" {
+		t.Fatalf("GetRecording wrote %q", buf.String())
+	}
+	if err := agg.GetRecording(ctx, "nope", "x.cast", &buf); err == nil {
+		t.Fatal("GetRecording of an unknown instance succeeded")
+	}
+}
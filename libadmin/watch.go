@@ -0,0 +1,178 @@
+package libadmin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// watchInstancesInterval is how often WatchAllSessions looks for
+	// instances added by Refresh.
+	watchInstancesInterval = 5 * time.Second
+	// watchRetryMin and watchRetryMax bound the backoff between the
+	// attempts to watch an instance.
+	watchRetryMin = time.Second
+	watchRetryMax = 30 * time.Second
+)
+
+// AggregatedSessionEvent is a SessionEvent of one instance, or an error
+// watching it.
+type AggregatedSessionEvent struct {
+	InstanceID   string
+	InstanceAddr string
+	Type         SessionEventType
+	Session      *Session
+	// Err is set, with no session, when the watch of the instance failed.
+	// It is retried until the instance goes away.
+	Err error
+}
+
+// WatchAllSessions watches the sessions of every instance, including the
+// ones found by later Refresh calls, and calls handler with their events,
+// one at a time, until ctx is done. interval is passed on as
+// WatchSessionsRequest.interval_seconds.
+//
+// A failed watch is retried with backoff. When it reconnects, or when the
+// instance goes away, removed events are made up for the sessions that
+// went away meanwhile, so handler always sees a consistent view.
+func (a *Aggregator) WatchAllSessions(ctx context.Context, interval time.Duration, handler func(AggregatedSessionEvent)) error {
+	var mu sync.Mutex
+	emit := func(ev AggregatedSessionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() == nil {
+			handler(ev)
+		}
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ticker := time.NewTicker(watchInstancesInterval)
+	defer ticker.Stop()
+
+	watching := make(map[string]bool)
+	gone := make(chan string)
+	for {
+		for id := range a.Instances() {
+			if watching[id] {
+				continue
+			}
+			watching[id] = true
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				a.watchInstance(ctx, id, interval, emit)
+				select {
+				case gone <- id:
+				case <-ctx.Done():
+				}
+			}(id)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case id := <-gone:
+			delete(watching, id)
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchInstance watches instance id until ctx is done or it goes away.
+func (a *Aggregator) watchInstance(ctx context.Context, id string, interval time.Duration, emit func(AggregatedSessionEvent)) {
+	// known are the sessions handed to emit and not removed yet
+	known := make(map[string]*Session)
+	var addr string
+	removeAll := func() {
+		for sid, s := range known {
+			delete(known, sid)
+			emit(AggregatedSessionEvent{InstanceID: id, InstanceAddr: addr, Type: SessionEventType_SESSION_REMOVED, Session: s})
+		}
+	}
+
+	backoff := watchRetryMin
+	for {
+		a.mu.Lock()
+		cache, ok := a.infos[id]
+		var c *Client
+		if ok {
+			c = a.clients[cache.Addr]
+		}
+		a.mu.Unlock()
+		if c == nil {
+			removeAll()
+			return
+		}
+		addr = cache.Addr
+
+		err := watchOnce(ctx, c, id, addr, interval, known, emit, func() { backoff = watchRetryMin })
+		if ctx.Err() != nil {
+			return
+		}
+		emit(AggregatedSessionEvent{InstanceID: id, InstanceAddr: addr, Err: &AggregatorError{InstanceID: id, InstanceAddr: addr, Err: err}})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, watchRetryMax)
+	}
+}
+
+// watchOnce runs one WatchSessions stream, updating known, and calls
+// synced once the stream caught up. It always returns an error.
+func watchOnce(ctx context.Context, c *Client, id, addr string, interval time.Duration, known map[string]*Session, emit func(AggregatedSessionEvent), synced func()) error {
+	stream, err := c.RPC().WatchSessions(ctx, &WatchSessionsRequest{IntervalSeconds: uint32(interval / time.Second)}) //nolint:gosec // seconds
+	if err != nil {
+		return err
+	}
+
+	// seen are the sessions added before the stream synced, the others in
+	// known went away while not watching
+	seen := make(map[string]bool)
+	isSynced := false
+	for {
+		ev, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return errors.New("watch ended")
+		}
+		if err != nil {
+			return err
+		}
+
+		out := AggregatedSessionEvent{InstanceID: id, InstanceAddr: addr, Type: ev.GetType(), Session: ev.GetSession()}
+		switch ev.GetType() {
+		case SessionEventType_SESSION_ADDED, SessionEventType_SESSION_UPDATED:
+			sid := ev.GetSession().GetId()
+			if _, ok := known[sid]; ok && ev.GetType() == SessionEventType_SESSION_ADDED {
+				// still there after a reconnect
+				out.Type = SessionEventType_SESSION_UPDATED
+			}
+			known[sid] = ev.GetSession()
+			if !isSynced {
+				seen[sid] = true
+			}
+		case SessionEventType_SESSION_REMOVED:
+			delete(known, ev.GetSession().GetId())
+		case SessionEventType_SESSION_SYNCED:
+			if isSynced {
+				continue
+			}
+			for sid, s := range known {
+				if !seen[sid] {
+					delete(known, sid)
+					emit(AggregatedSessionEvent{InstanceID: id, InstanceAddr: addr, Type: SessionEventType_SESSION_REMOVED, Session: s})
+				}
+			}
+			isSynced = true
+			synced()
+		}
+		emit(out)
+	}
+}
@@ -0,0 +1,81 @@
+package libadmin
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAggregator_WatchAllSessions(t *testing.T) {
+	stubA, addrA := startStub(t, "piper-a", []*Session{{Id: "a1"}, {Id: "a2"}})
+	_, addrB := startStub(t, "piper-b", []*Session{{Id: "b1"}})
+
+	agg := NewAggregator(NewStaticDiscovery([]string{addrA, addrB}), DialOptions{Insecure: true})
+	defer agg.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, errs := agg.Refresh(ctx); len(errs) != 0 {
+		t.Fatalf("Refresh errors: %v", errs)
+	}
+
+	events := make(chan AggregatedSessionEvent, 100)
+	watchCtx, stop := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- agg.WatchAllSessions(watchCtx, time.Second, func(ev AggregatedSessionEvent) { events <- ev })
+	}()
+
+	// next returns the next event of instance, skipping errors
+	next := func(instance string) AggregatedSessionEvent {
+		t.Helper()
+		for {
+			select {
+			case ev := <-events:
+				if ev.InstanceID == instance && ev.Err == nil {
+					return ev
+				}
+			case <-ctx.Done():
+				t.Fatalf("no event from %s", instance)
+			}
+		}
+	}
+
+	sessions := map[string]string{}
+	for synced := 0; synced < 2; {
+		ev := <-events
+		if ev.Type == SessionEventType_SESSION_SYNCED {
+			synced++
+			continue
+		}
+		if ev.Type != SessionEventType_SESSION_ADDED {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+		sessions[ev.Session.GetId()] = ev.InstanceID
+	}
+	if len(sessions) != 3 || sessions["a1"] != "piper-a" || sessions["b1"] != "piper-b" {
+		t.Fatalf("sessions = %v", sessions)
+	}
+
+	// a2 goes away while the watch of piper-a reconnects
+	stubA.mu.Lock()
+	stubA.sessions = []*Session{{Id: "a1"}}
+	close(stubA.watch)
+	stubA.watch = make(chan struct{})
+	stubA.mu.Unlock()
+
+	if ev := next("piper-a"); ev.Type != SessionEventType_SESSION_UPDATED || ev.Session.GetId() != "a1" {
+		t.Fatalf("expected a1 to be updated, got %+v", ev)
+	}
+	if ev := next("piper-a"); ev.Type != SessionEventType_SESSION_REMOVED || ev.Session.GetId() != "a2" {
+		t.Fatalf("expected a2 to be removed, got %+v", ev)
+	}
+	if ev := next("piper-a"); ev.Type != SessionEventType_SESSION_SYNCED {
+		t.Fatalf("expected synced, got %+v", ev)
+	}
+
+	stop()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("WatchAllSessions() = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,125 @@
+package libplugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// ProtocolVersion is the version of the plugin protocol, bumped on changes
+// old plugins or old sshpiperd cannot work with. Additions that are safe to
+// ignore are announced as features instead.
+const ProtocolVersion = 1
+
+// Optional features of the plugin protocol, exchanged in the Handshake.
+const (
+	// FeaturePing is the Ping RPC.
+	FeaturePing = "ping"
+
+	// FeatureUpstreamEnv is Upstream.env.
+	FeatureUpstreamEnv = "upstream-env"
+
+	// FeatureUpstreamKnownHostsData is Upstream.known_hosts_data.
+	FeatureUpstreamKnownHostsData = "upstream-known-hosts-data"
+
+	// FeatureUpstreamNextPlugin is Upstream.next_plugin.
+	FeatureUpstreamNextPlugin = "upstream-next-plugin"
+
+	// FeatureUpstreamRetryCurrentPlugin is Upstream.retry_current_plugin.
+	FeatureUpstreamRetryCurrentPlugin = "upstream-retry-current-plugin"
+
+	// FeaturePipeEnd is the PipeEndNotice RPC and its PipeEnd callback.
+	FeaturePipeEnd = "pipe-end"
+
+	// FeatureAuthorizeChannel is the AuthorizeChannel RPC and its callback.
+	FeatureAuthorizeChannel = "authorize-channel"
+
+	// FeatureSessionPolicy is Upstream.session_policy.
+	FeatureSessionPolicy = "session-policy"
+
+	// FeatureSessionLabels is Upstream.labels and the LabelMetaPrefix
+	// entries of NextPluginAuth.meta.
+	FeatureSessionLabels = "session-labels"
+)
+
+// Features are the optional features implemented by this libplugin.
+var Features = []string{
+	FeaturePing,
+	FeatureUpstreamEnv,
+	FeatureUpstreamKnownHostsData,
+	FeatureUpstreamNextPlugin,
+	FeatureUpstreamRetryCurrentPlugin,
+	FeaturePipeEnd,
+	FeatureAuthorizeChannel,
+	FeatureSessionPolicy,
+	FeatureSessionLabels,
+}
+
+// DaemonInfo is what sshpiperd sent in the Handshake.
+type DaemonInfo struct {
+	ProtocolVersion uint32
+	Version         string
+	Features        []string
+}
+
+// HasFeature reports whether sshpiperd announced feature.
+func (d DaemonInfo) HasFeature(feature string) bool {
+	return slices.Contains(d.Features, feature)
+}
+
+func (s *server) Daemon() DaemonInfo {
+	s.daemonMu.Lock()
+	defer s.daemonMu.Unlock()
+	return s.daemon
+}
+
+func (s *server) Handshake(ctx context.Context, req *HandshakeRequest) (*HandshakeResponse, error) {
+	daemon := DaemonInfo{
+		ProtocolVersion: req.GetProtocolVersion(),
+		Version:         req.GetVersion(),
+		Features:        req.GetFeatures(),
+	}
+
+	var missing []string
+	for _, f := range s.config.RequiredFeatures {
+		if !daemon.HasFeature(f) {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "sshpiperd %v does not support features %v required by the plugin, upgrade sshpiperd", daemon.Version, missing)
+	}
+
+	if s.config.HandshakeCallback != nil {
+		if err := s.config.HandshakeCallback(daemon); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+
+	s.daemonMu.Lock()
+	s.daemon = daemon
+	s.daemonMu.Unlock()
+
+	name := s.config.Name
+	if name == "" {
+		name = filepath.Base(os.Args[0])
+	}
+
+	version := s.config.Version
+	if bi, ok := debug.ReadBuildInfo(); ok && version == "" {
+		version = bi.Main.Version
+	}
+
+	return &HandshakeResponse{
+		ProtocolVersion:  ProtocolVersion,
+		Name:             name,
+		Version:          version,
+		Features:         Features,
+		RequiredFeatures: s.config.RequiredFeatures,
+	}, nil
+}
@@ -73,6 +73,57 @@ func (AuthMethod) EnumDescriptor() ([]byte, []int) {
 	return file_plugin_proto_rawDescGZIP(), []int{0}
 }
 
+type ChannelDecision int32
+
+const (
+	ChannelDecision_CHANNEL_ALLOW ChannelDecision = 0
+	ChannelDecision_CHANNEL_DENY  ChannelDecision = 1
+	// Only for shell, exec and subsystem requests, see
+	// AuthorizeChannelResponse, allows the others.
+	ChannelDecision_CHANNEL_REWRITE ChannelDecision = 2
+)
+
+// Enum value maps for ChannelDecision.
+var (
+	ChannelDecision_name = map[int32]string{
+		0: "CHANNEL_ALLOW",
+		1: "CHANNEL_DENY",
+		2: "CHANNEL_REWRITE",
+	}
+	ChannelDecision_value = map[string]int32{
+		"CHANNEL_ALLOW":   0,
+		"CHANNEL_DENY":    1,
+		"CHANNEL_REWRITE": 2,
+	}
+)
+
+func (x ChannelDecision) Enum() *ChannelDecision {
+	p := new(ChannelDecision)
+	*p = x
+	return p
+}
+
+func (x ChannelDecision) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChannelDecision) Descriptor() protoreflect.EnumDescriptor {
+	return file_plugin_proto_enumTypes[1].Descriptor()
+}
+
+func (ChannelDecision) Type() protoreflect.EnumType {
+	return &file_plugin_proto_enumTypes[1]
+}
+
+func (x ChannelDecision) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChannelDecision.Descriptor instead.
+func (ChannelDecision) EnumDescriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{1}
+}
+
 type ConnMeta struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserName      string                 `protobuf:"bytes,1,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
@@ -170,6 +221,14 @@ type Upstream struct {
 	// upstream server must allow the variable via its AcceptEnv (or
 	// equivalent) configuration for the value to take effect.
 	Env map[string]string `protobuf:"bytes,7,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Settings of the session overriding the sshpiperd defaults for this
+	// connection, unset fields keep the defaults.
+	SessionPolicy *SessionPolicy `protobuf:"bytes,8,opt,name=session_policy,json=sessionPolicy,proto3" json:"session_policy,omitempty"`
+	// Labels of the session, such as a ticket number or a team, shown by the
+	// admin API and written into the recording headers. They are merged over
+	// the labels passed along the chain in NextPluginAuth.meta under the
+	// "label." prefix.
+	Labels map[string]string `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	// Types that are valid to be assigned to Auth:
 	//
 	//	*Upstream_None
@@ -265,6 +324,20 @@ func (x *Upstream) GetEnv() map[string]string {
 	return nil
 }
 
+func (x *Upstream) GetSessionPolicy() *SessionPolicy {
+	if x != nil {
+		return x.SessionPolicy
+	}
+	return nil
+}
+
+func (x *Upstream) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
 func (x *Upstream) GetAuth() isUpstream_Auth {
 	if x != nil {
 		return x.Auth
@@ -366,6 +439,128 @@ func (*Upstream_NextPlugin) isUpstream_Auth() {}
 
 func (*Upstream_RetryCurrentPlugin) isUpstream_Auth() {}
 
+type SessionPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Record the session, only when sshpiperd has a --screen-recording-dir.
+	Recording *bool `protobuf:"varint,1,opt,name=recording,proto3,oneof" json:"recording,omitempty"`
+	// asciicast or typescript.
+	RecordingFormat  string `protobuf:"bytes,2,opt,name=recording_format,json=recordingFormat,proto3" json:"recording_format,omitempty"`
+	LocalForwarding  *bool  `protobuf:"varint,3,opt,name=local_forwarding,json=localForwarding,proto3,oneof" json:"local_forwarding,omitempty"`
+	RemoteForwarding *bool  `protobuf:"varint,4,opt,name=remote_forwarding,json=remoteForwarding,proto3,oneof" json:"remote_forwarding,omitempty"`
+	AgentForwarding  *bool  `protobuf:"varint,5,opt,name=agent_forwarding,json=agentForwarding,proto3,oneof" json:"agent_forwarding,omitempty"`
+	X11Forwarding    *bool  `protobuf:"varint,6,opt,name=x11_forwarding,json=x11Forwarding,proto3,oneof" json:"x11_forwarding,omitempty"`
+	// Close the session after so many seconds without channel data, or
+	// after so many seconds in total. 0 means no limit.
+	IdleTimeoutSeconds *uint32 `protobuf:"varint,7,opt,name=idle_timeout_seconds,json=idleTimeoutSeconds,proto3,oneof" json:"idle_timeout_seconds,omitempty"`
+	MaxDurationSeconds *uint32 `protobuf:"varint,8,opt,name=max_duration_seconds,json=maxDurationSeconds,proto3,oneof" json:"max_duration_seconds,omitempty"`
+	// Channel data bytes per second in each direction, 0 means no limit.
+	BandwidthLimit *uint64 `protobuf:"varint,9,opt,name=bandwidth_limit,json=bandwidthLimit,proto3,oneof" json:"bandwidth_limit,omitempty"`
+	// Shown to the client on the stderr of its first session.
+	PostAuthMessage string `protobuf:"bytes,10,opt,name=post_auth_message,json=postAuthMessage,proto3" json:"post_auth_message,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SessionPolicy) Reset() {
+	*x = SessionPolicy{}
+	mi := &file_plugin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionPolicy) ProtoMessage() {}
+
+func (x *SessionPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionPolicy.ProtoReflect.Descriptor instead.
+func (*SessionPolicy) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SessionPolicy) GetRecording() bool {
+	if x != nil && x.Recording != nil {
+		return *x.Recording
+	}
+	return false
+}
+
+func (x *SessionPolicy) GetRecordingFormat() string {
+	if x != nil {
+		return x.RecordingFormat
+	}
+	return ""
+}
+
+func (x *SessionPolicy) GetLocalForwarding() bool {
+	if x != nil && x.LocalForwarding != nil {
+		return *x.LocalForwarding
+	}
+	return false
+}
+
+func (x *SessionPolicy) GetRemoteForwarding() bool {
+	if x != nil && x.RemoteForwarding != nil {
+		return *x.RemoteForwarding
+	}
+	return false
+}
+
+func (x *SessionPolicy) GetAgentForwarding() bool {
+	if x != nil && x.AgentForwarding != nil {
+		return *x.AgentForwarding
+	}
+	return false
+}
+
+func (x *SessionPolicy) GetX11Forwarding() bool {
+	if x != nil && x.X11Forwarding != nil {
+		return *x.X11Forwarding
+	}
+	return false
+}
+
+func (x *SessionPolicy) GetIdleTimeoutSeconds() uint32 {
+	if x != nil && x.IdleTimeoutSeconds != nil {
+		return *x.IdleTimeoutSeconds
+	}
+	return 0
+}
+
+func (x *SessionPolicy) GetMaxDurationSeconds() uint32 {
+	if x != nil && x.MaxDurationSeconds != nil {
+		return *x.MaxDurationSeconds
+	}
+	return 0
+}
+
+func (x *SessionPolicy) GetBandwidthLimit() uint64 {
+	if x != nil && x.BandwidthLimit != nil {
+		return *x.BandwidthLimit
+	}
+	return 0
+}
+
+func (x *SessionPolicy) GetPostAuthMessage() string {
+	if x != nil {
+		return x.PostAuthMessage
+	}
+	return ""
+}
+
 type UpstreamNoneAuth struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -374,7 +569,7 @@ type UpstreamNoneAuth struct {
 
 func (x *UpstreamNoneAuth) Reset() {
 	*x = UpstreamNoneAuth{}
-	mi := &file_plugin_proto_msgTypes[2]
+	mi := &file_plugin_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -386,7 +581,7 @@ func (x *UpstreamNoneAuth) String() string {
 func (*UpstreamNoneAuth) ProtoMessage() {}
 
 func (x *UpstreamNoneAuth) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[2]
+	mi := &file_plugin_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -399,7 +594,7 @@ func (x *UpstreamNoneAuth) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpstreamNoneAuth.ProtoReflect.Descriptor instead.
 func (*UpstreamNoneAuth) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{2}
+	return file_plugin_proto_rawDescGZIP(), []int{3}
 }
 
 type UpstreamPasswordAuth struct {
@@ -411,7 +606,7 @@ type UpstreamPasswordAuth struct {
 
 func (x *UpstreamPasswordAuth) Reset() {
 	*x = UpstreamPasswordAuth{}
-	mi := &file_plugin_proto_msgTypes[3]
+	mi := &file_plugin_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -423,7 +618,7 @@ func (x *UpstreamPasswordAuth) String() string {
 func (*UpstreamPasswordAuth) ProtoMessage() {}
 
 func (x *UpstreamPasswordAuth) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[3]
+	mi := &file_plugin_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -436,7 +631,7 @@ func (x *UpstreamPasswordAuth) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpstreamPasswordAuth.ProtoReflect.Descriptor instead.
 func (*UpstreamPasswordAuth) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{3}
+	return file_plugin_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *UpstreamPasswordAuth) GetPassword() string {
@@ -456,7 +651,7 @@ type UpstreamPrivateKeyAuth struct {
 
 func (x *UpstreamPrivateKeyAuth) Reset() {
 	*x = UpstreamPrivateKeyAuth{}
-	mi := &file_plugin_proto_msgTypes[4]
+	mi := &file_plugin_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -468,7 +663,7 @@ func (x *UpstreamPrivateKeyAuth) String() string {
 func (*UpstreamPrivateKeyAuth) ProtoMessage() {}
 
 func (x *UpstreamPrivateKeyAuth) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[4]
+	mi := &file_plugin_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -481,7 +676,7 @@ func (x *UpstreamPrivateKeyAuth) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpstreamPrivateKeyAuth.ProtoReflect.Descriptor instead.
 func (*UpstreamPrivateKeyAuth) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{4}
+	return file_plugin_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *UpstreamPrivateKeyAuth) GetPrivateKey() []byte {
@@ -507,7 +702,7 @@ type UpstreamRemoteSignerAuth struct {
 
 func (x *UpstreamRemoteSignerAuth) Reset() {
 	*x = UpstreamRemoteSignerAuth{}
-	mi := &file_plugin_proto_msgTypes[5]
+	mi := &file_plugin_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -519,7 +714,7 @@ func (x *UpstreamRemoteSignerAuth) String() string {
 func (*UpstreamRemoteSignerAuth) ProtoMessage() {}
 
 func (x *UpstreamRemoteSignerAuth) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[5]
+	mi := &file_plugin_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -532,7 +727,7 @@ func (x *UpstreamRemoteSignerAuth) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpstreamRemoteSignerAuth.ProtoReflect.Descriptor instead.
 func (*UpstreamRemoteSignerAuth) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{5}
+	return file_plugin_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *UpstreamRemoteSignerAuth) GetMeta() string {
@@ -551,7 +746,7 @@ type UpstreamNextPluginAuth struct {
 
 func (x *UpstreamNextPluginAuth) Reset() {
 	*x = UpstreamNextPluginAuth{}
-	mi := &file_plugin_proto_msgTypes[6]
+	mi := &file_plugin_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -563,7 +758,7 @@ func (x *UpstreamNextPluginAuth) String() string {
 func (*UpstreamNextPluginAuth) ProtoMessage() {}
 
 func (x *UpstreamNextPluginAuth) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[6]
+	mi := &file_plugin_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -576,7 +771,7 @@ func (x *UpstreamNextPluginAuth) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpstreamNextPluginAuth.ProtoReflect.Descriptor instead.
 func (*UpstreamNextPluginAuth) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{6}
+	return file_plugin_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *UpstreamNextPluginAuth) GetMeta() map[string]string {
@@ -595,7 +790,7 @@ type UpstreamRetryCurrentPluginAuth struct {
 
 func (x *UpstreamRetryCurrentPluginAuth) Reset() {
 	*x = UpstreamRetryCurrentPluginAuth{}
-	mi := &file_plugin_proto_msgTypes[7]
+	mi := &file_plugin_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -607,7 +802,7 @@ func (x *UpstreamRetryCurrentPluginAuth) String() string {
 func (*UpstreamRetryCurrentPluginAuth) ProtoMessage() {}
 
 func (x *UpstreamRetryCurrentPluginAuth) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[7]
+	mi := &file_plugin_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -620,7 +815,7 @@ func (x *UpstreamRetryCurrentPluginAuth) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpstreamRetryCurrentPluginAuth.ProtoReflect.Descriptor instead.
 func (*UpstreamRetryCurrentPluginAuth) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{7}
+	return file_plugin_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *UpstreamRetryCurrentPluginAuth) GetMeta() map[string]string {
@@ -641,7 +836,7 @@ type StartLogRequest struct {
 
 func (x *StartLogRequest) Reset() {
 	*x = StartLogRequest{}
-	mi := &file_plugin_proto_msgTypes[8]
+	mi := &file_plugin_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -653,7 +848,7 @@ func (x *StartLogRequest) String() string {
 func (*StartLogRequest) ProtoMessage() {}
 
 func (x *StartLogRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[8]
+	mi := &file_plugin_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -666,7 +861,7 @@ func (x *StartLogRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StartLogRequest.ProtoReflect.Descriptor instead.
 func (*StartLogRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{8}
+	return file_plugin_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *StartLogRequest) GetUniqId() string {
@@ -699,7 +894,7 @@ type Log struct {
 
 func (x *Log) Reset() {
 	*x = Log{}
-	mi := &file_plugin_proto_msgTypes[9]
+	mi := &file_plugin_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -711,7 +906,7 @@ func (x *Log) String() string {
 func (*Log) ProtoMessage() {}
 
 func (x *Log) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[9]
+	mi := &file_plugin_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -724,7 +919,7 @@ func (x *Log) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Log.ProtoReflect.Descriptor instead.
 func (*Log) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{9}
+	return file_plugin_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Log) GetMessage() string {
@@ -734,27 +929,33 @@ func (x *Log) GetMessage() string {
 	return ""
 }
 
-type ListCallbackRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+type HandshakeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Plugin protocol version spoken by sshpiperd, see ProtocolVersion.
+	ProtocolVersion uint32 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	// Version of sshpiperd.
+	Version string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	// Optional features of the plugin protocol supported by sshpiperd.
+	Features      []string `protobuf:"bytes,3,rep,name=features,proto3" json:"features,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ListCallbackRequest) Reset() {
-	*x = ListCallbackRequest{}
-	mi := &file_plugin_proto_msgTypes[10]
+func (x *HandshakeRequest) Reset() {
+	*x = HandshakeRequest{}
+	mi := &file_plugin_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListCallbackRequest) String() string {
+func (x *HandshakeRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListCallbackRequest) ProtoMessage() {}
+func (*HandshakeRequest) ProtoMessage() {}
 
-func (x *ListCallbackRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[10]
+func (x *HandshakeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -765,76 +966,62 @@ func (x *ListCallbackRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListCallbackRequest.ProtoReflect.Descriptor instead.
-func (*ListCallbackRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{10}
-}
-
-type ListCallbackResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Callbacks     []string               `protobuf:"bytes,1,rep,name=callbacks,proto3" json:"callbacks,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *ListCallbackResponse) Reset() {
-	*x = ListCallbackResponse{}
-	mi := &file_plugin_proto_msgTypes[11]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *ListCallbackResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
+// Deprecated: Use HandshakeRequest.ProtoReflect.Descriptor instead.
+func (*HandshakeRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{11}
 }
 
-func (*ListCallbackResponse) ProtoMessage() {}
-
-func (x *ListCallbackResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[11]
+func (x *HandshakeRequest) GetProtocolVersion() uint32 {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.ProtocolVersion
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use ListCallbackResponse.ProtoReflect.Descriptor instead.
-func (*ListCallbackResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{11}
+func (x *HandshakeRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
 }
 
-func (x *ListCallbackResponse) GetCallbacks() []string {
+func (x *HandshakeRequest) GetFeatures() []string {
 	if x != nil {
-		return x.Callbacks
+		return x.Features
 	}
 	return nil
 }
 
-type NewConnectionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Meta          *ConnMeta              `protobuf:"bytes,1,opt,name=meta,proto3" json:"meta,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *NewConnectionRequest) Reset() {
-	*x = NewConnectionRequest{}
+type HandshakeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Plugin protocol version spoken by the plugin.
+	ProtocolVersion uint32 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	// Name and version the plugin reports itself as.
+	Name    string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Version string `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	// Optional features of the plugin protocol supported by the plugin.
+	Features []string `protobuf:"bytes,4,rep,name=features,proto3" json:"features,omitempty"`
+	// Features sshpiperd must support, sshpiperd refuses the plugin
+	// otherwise.
+	RequiredFeatures []string `protobuf:"bytes,5,rep,name=required_features,json=requiredFeatures,proto3" json:"required_features,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *HandshakeResponse) Reset() {
+	*x = HandshakeResponse{}
 	mi := &file_plugin_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *NewConnectionRequest) String() string {
+func (x *HandshakeResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*NewConnectionRequest) ProtoMessage() {}
+func (*HandshakeResponse) ProtoMessage() {}
 
-func (x *NewConnectionRequest) ProtoReflect() protoreflect.Message {
+func (x *HandshakeResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_plugin_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -846,38 +1033,66 @@ func (x *NewConnectionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use NewConnectionRequest.ProtoReflect.Descriptor instead.
-func (*NewConnectionRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use HandshakeResponse.ProtoReflect.Descriptor instead.
+func (*HandshakeResponse) Descriptor() ([]byte, []int) {
 	return file_plugin_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *NewConnectionRequest) GetMeta() *ConnMeta {
+func (x *HandshakeResponse) GetProtocolVersion() uint32 {
 	if x != nil {
-		return x.Meta
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *HandshakeResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *HandshakeResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *HandshakeResponse) GetFeatures() []string {
+	if x != nil {
+		return x.Features
 	}
 	return nil
 }
 
-type NewConnectionResponse struct {
+func (x *HandshakeResponse) GetRequiredFeatures() []string {
+	if x != nil {
+		return x.RequiredFeatures
+	}
+	return nil
+}
+
+type ListCallbackRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *NewConnectionResponse) Reset() {
-	*x = NewConnectionResponse{}
+func (x *ListCallbackRequest) Reset() {
+	*x = ListCallbackRequest{}
 	mi := &file_plugin_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *NewConnectionResponse) String() string {
+func (x *ListCallbackRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*NewConnectionResponse) ProtoMessage() {}
+func (*ListCallbackRequest) ProtoMessage() {}
 
-func (x *NewConnectionResponse) ProtoReflect() protoreflect.Message {
+func (x *ListCallbackRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_plugin_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -889,9 +1104,205 @@ func (x *NewConnectionResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use NewConnectionResponse.ProtoReflect.Descriptor instead.
-func (*NewConnectionResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{13}
+// Deprecated: Use ListCallbackRequest.ProtoReflect.Descriptor instead.
+func (*ListCallbackRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{13}
+}
+
+type ListCallbackResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Callbacks     []string               `protobuf:"bytes,1,rep,name=callbacks,proto3" json:"callbacks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCallbackResponse) Reset() {
+	*x = ListCallbackResponse{}
+	mi := &file_plugin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCallbackResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCallbackResponse) ProtoMessage() {}
+
+func (x *ListCallbackResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCallbackResponse.ProtoReflect.Descriptor instead.
+func (*ListCallbackResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ListCallbackResponse) GetCallbacks() []string {
+	if x != nil {
+		return x.Callbacks
+	}
+	return nil
+}
+
+type PingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_plugin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{15}
+}
+
+type PingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_plugin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{16}
+}
+
+type NewConnectionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *ConnMeta              `protobuf:"bytes,1,opt,name=meta,proto3" json:"meta,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NewConnectionRequest) Reset() {
+	*x = NewConnectionRequest{}
+	mi := &file_plugin_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewConnectionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewConnectionRequest) ProtoMessage() {}
+
+func (x *NewConnectionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewConnectionRequest.ProtoReflect.Descriptor instead.
+func (*NewConnectionRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *NewConnectionRequest) GetMeta() *ConnMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+type NewConnectionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NewConnectionResponse) Reset() {
+	*x = NewConnectionResponse{}
+	mi := &file_plugin_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewConnectionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewConnectionResponse) ProtoMessage() {}
+
+func (x *NewConnectionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewConnectionResponse.ProtoReflect.Descriptor instead.
+func (*NewConnectionResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{18}
 }
 
 type NextAuthMethodsRequest struct {
@@ -903,7 +1314,7 @@ type NextAuthMethodsRequest struct {
 
 func (x *NextAuthMethodsRequest) Reset() {
 	*x = NextAuthMethodsRequest{}
-	mi := &file_plugin_proto_msgTypes[14]
+	mi := &file_plugin_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -915,7 +1326,7 @@ func (x *NextAuthMethodsRequest) String() string {
 func (*NextAuthMethodsRequest) ProtoMessage() {}
 
 func (x *NextAuthMethodsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[14]
+	mi := &file_plugin_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -928,7 +1339,7 @@ func (x *NextAuthMethodsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NextAuthMethodsRequest.ProtoReflect.Descriptor instead.
 func (*NextAuthMethodsRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{14}
+	return file_plugin_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *NextAuthMethodsRequest) GetMeta() *ConnMeta {
@@ -947,7 +1358,7 @@ type NextAuthMethodsResponse struct {
 
 func (x *NextAuthMethodsResponse) Reset() {
 	*x = NextAuthMethodsResponse{}
-	mi := &file_plugin_proto_msgTypes[15]
+	mi := &file_plugin_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -959,7 +1370,7 @@ func (x *NextAuthMethodsResponse) String() string {
 func (*NextAuthMethodsResponse) ProtoMessage() {}
 
 func (x *NextAuthMethodsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[15]
+	mi := &file_plugin_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -972,7 +1383,7 @@ func (x *NextAuthMethodsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NextAuthMethodsResponse.ProtoReflect.Descriptor instead.
 func (*NextAuthMethodsResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{15}
+	return file_plugin_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *NextAuthMethodsResponse) GetMethods() []AuthMethod {
@@ -991,7 +1402,7 @@ type NoneAuthRequest struct {
 
 func (x *NoneAuthRequest) Reset() {
 	*x = NoneAuthRequest{}
-	mi := &file_plugin_proto_msgTypes[16]
+	mi := &file_plugin_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1003,7 +1414,7 @@ func (x *NoneAuthRequest) String() string {
 func (*NoneAuthRequest) ProtoMessage() {}
 
 func (x *NoneAuthRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[16]
+	mi := &file_plugin_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1016,7 +1427,7 @@ func (x *NoneAuthRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NoneAuthRequest.ProtoReflect.Descriptor instead.
 func (*NoneAuthRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{16}
+	return file_plugin_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *NoneAuthRequest) GetMeta() *ConnMeta {
@@ -1035,7 +1446,7 @@ type NoneAuthResponse struct {
 
 func (x *NoneAuthResponse) Reset() {
 	*x = NoneAuthResponse{}
-	mi := &file_plugin_proto_msgTypes[17]
+	mi := &file_plugin_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1047,7 +1458,7 @@ func (x *NoneAuthResponse) String() string {
 func (*NoneAuthResponse) ProtoMessage() {}
 
 func (x *NoneAuthResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[17]
+	mi := &file_plugin_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1060,7 +1471,7 @@ func (x *NoneAuthResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NoneAuthResponse.ProtoReflect.Descriptor instead.
 func (*NoneAuthResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{17}
+	return file_plugin_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *NoneAuthResponse) GetUpstream() *Upstream {
@@ -1080,7 +1491,7 @@ type PasswordAuthRequest struct {
 
 func (x *PasswordAuthRequest) Reset() {
 	*x = PasswordAuthRequest{}
-	mi := &file_plugin_proto_msgTypes[18]
+	mi := &file_plugin_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1092,7 +1503,7 @@ func (x *PasswordAuthRequest) String() string {
 func (*PasswordAuthRequest) ProtoMessage() {}
 
 func (x *PasswordAuthRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[18]
+	mi := &file_plugin_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1105,7 +1516,7 @@ func (x *PasswordAuthRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PasswordAuthRequest.ProtoReflect.Descriptor instead.
 func (*PasswordAuthRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{18}
+	return file_plugin_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *PasswordAuthRequest) GetMeta() *ConnMeta {
@@ -1131,7 +1542,7 @@ type PasswordAuthResponse struct {
 
 func (x *PasswordAuthResponse) Reset() {
 	*x = PasswordAuthResponse{}
-	mi := &file_plugin_proto_msgTypes[19]
+	mi := &file_plugin_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1143,7 +1554,7 @@ func (x *PasswordAuthResponse) String() string {
 func (*PasswordAuthResponse) ProtoMessage() {}
 
 func (x *PasswordAuthResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[19]
+	mi := &file_plugin_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1156,7 +1567,7 @@ func (x *PasswordAuthResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PasswordAuthResponse.ProtoReflect.Descriptor instead.
 func (*PasswordAuthResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{19}
+	return file_plugin_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *PasswordAuthResponse) GetUpstream() *Upstream {
@@ -1176,7 +1587,7 @@ type PublicKeyAuthRequest struct {
 
 func (x *PublicKeyAuthRequest) Reset() {
 	*x = PublicKeyAuthRequest{}
-	mi := &file_plugin_proto_msgTypes[20]
+	mi := &file_plugin_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1188,7 +1599,7 @@ func (x *PublicKeyAuthRequest) String() string {
 func (*PublicKeyAuthRequest) ProtoMessage() {}
 
 func (x *PublicKeyAuthRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[20]
+	mi := &file_plugin_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1201,7 +1612,7 @@ func (x *PublicKeyAuthRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PublicKeyAuthRequest.ProtoReflect.Descriptor instead.
 func (*PublicKeyAuthRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{20}
+	return file_plugin_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *PublicKeyAuthRequest) GetMeta() *ConnMeta {
@@ -1227,7 +1638,7 @@ type PublicKeyAuthResponse struct {
 
 func (x *PublicKeyAuthResponse) Reset() {
 	*x = PublicKeyAuthResponse{}
-	mi := &file_plugin_proto_msgTypes[21]
+	mi := &file_plugin_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1239,7 +1650,7 @@ func (x *PublicKeyAuthResponse) String() string {
 func (*PublicKeyAuthResponse) ProtoMessage() {}
 
 func (x *PublicKeyAuthResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[21]
+	mi := &file_plugin_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1252,7 +1663,7 @@ func (x *PublicKeyAuthResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PublicKeyAuthResponse.ProtoReflect.Descriptor instead.
 func (*PublicKeyAuthResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{21}
+	return file_plugin_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *PublicKeyAuthResponse) GetUpstream() *Upstream {
@@ -1271,7 +1682,7 @@ type KeyboardInteractiveUserResponse struct {
 
 func (x *KeyboardInteractiveUserResponse) Reset() {
 	*x = KeyboardInteractiveUserResponse{}
-	mi := &file_plugin_proto_msgTypes[22]
+	mi := &file_plugin_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1283,7 +1694,7 @@ func (x *KeyboardInteractiveUserResponse) String() string {
 func (*KeyboardInteractiveUserResponse) ProtoMessage() {}
 
 func (x *KeyboardInteractiveUserResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[22]
+	mi := &file_plugin_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1296,7 +1707,7 @@ func (x *KeyboardInteractiveUserResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeyboardInteractiveUserResponse.ProtoReflect.Descriptor instead.
 func (*KeyboardInteractiveUserResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{22}
+	return file_plugin_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *KeyboardInteractiveUserResponse) GetAnswers() []string {
@@ -1317,7 +1728,7 @@ type KeyboardInteractivePromptRequest struct {
 
 func (x *KeyboardInteractivePromptRequest) Reset() {
 	*x = KeyboardInteractivePromptRequest{}
-	mi := &file_plugin_proto_msgTypes[23]
+	mi := &file_plugin_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1329,7 +1740,7 @@ func (x *KeyboardInteractivePromptRequest) String() string {
 func (*KeyboardInteractivePromptRequest) ProtoMessage() {}
 
 func (x *KeyboardInteractivePromptRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[23]
+	mi := &file_plugin_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1342,7 +1753,7 @@ func (x *KeyboardInteractivePromptRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeyboardInteractivePromptRequest.ProtoReflect.Descriptor instead.
 func (*KeyboardInteractivePromptRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{23}
+	return file_plugin_proto_rawDescGZIP(), []int{28}
 }
 
 func (x *KeyboardInteractivePromptRequest) GetName() string {
@@ -1374,7 +1785,7 @@ type KeyboardInteractiveMetaRequest struct {
 
 func (x *KeyboardInteractiveMetaRequest) Reset() {
 	*x = KeyboardInteractiveMetaRequest{}
-	mi := &file_plugin_proto_msgTypes[24]
+	mi := &file_plugin_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1386,7 +1797,7 @@ func (x *KeyboardInteractiveMetaRequest) String() string {
 func (*KeyboardInteractiveMetaRequest) ProtoMessage() {}
 
 func (x *KeyboardInteractiveMetaRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[24]
+	mi := &file_plugin_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1399,7 +1810,7 @@ func (x *KeyboardInteractiveMetaRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeyboardInteractiveMetaRequest.ProtoReflect.Descriptor instead.
 func (*KeyboardInteractiveMetaRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{24}
+	return file_plugin_proto_rawDescGZIP(), []int{29}
 }
 
 type KeyboardInteractiveMetaResponse struct {
@@ -1411,7 +1822,7 @@ type KeyboardInteractiveMetaResponse struct {
 
 func (x *KeyboardInteractiveMetaResponse) Reset() {
 	*x = KeyboardInteractiveMetaResponse{}
-	mi := &file_plugin_proto_msgTypes[25]
+	mi := &file_plugin_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1423,7 +1834,7 @@ func (x *KeyboardInteractiveMetaResponse) String() string {
 func (*KeyboardInteractiveMetaResponse) ProtoMessage() {}
 
 func (x *KeyboardInteractiveMetaResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[25]
+	mi := &file_plugin_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1436,7 +1847,7 @@ func (x *KeyboardInteractiveMetaResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeyboardInteractiveMetaResponse.ProtoReflect.Descriptor instead.
 func (*KeyboardInteractiveMetaResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{25}
+	return file_plugin_proto_rawDescGZIP(), []int{30}
 }
 
 func (x *KeyboardInteractiveMetaResponse) GetMeta() *ConnMeta {
@@ -1455,7 +1866,7 @@ type KeyboardInteractiveFinishRequest struct {
 
 func (x *KeyboardInteractiveFinishRequest) Reset() {
 	*x = KeyboardInteractiveFinishRequest{}
-	mi := &file_plugin_proto_msgTypes[26]
+	mi := &file_plugin_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1467,7 +1878,7 @@ func (x *KeyboardInteractiveFinishRequest) String() string {
 func (*KeyboardInteractiveFinishRequest) ProtoMessage() {}
 
 func (x *KeyboardInteractiveFinishRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[26]
+	mi := &file_plugin_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1480,7 +1891,7 @@ func (x *KeyboardInteractiveFinishRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeyboardInteractiveFinishRequest.ProtoReflect.Descriptor instead.
 func (*KeyboardInteractiveFinishRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{26}
+	return file_plugin_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *KeyboardInteractiveFinishRequest) GetUpstream() *Upstream {
@@ -1506,7 +1917,7 @@ type KeyboardInteractiveAuthMessage struct {
 
 func (x *KeyboardInteractiveAuthMessage) Reset() {
 	*x = KeyboardInteractiveAuthMessage{}
-	mi := &file_plugin_proto_msgTypes[27]
+	mi := &file_plugin_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1518,7 +1929,7 @@ func (x *KeyboardInteractiveAuthMessage) String() string {
 func (*KeyboardInteractiveAuthMessage) ProtoMessage() {}
 
 func (x *KeyboardInteractiveAuthMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[27]
+	mi := &file_plugin_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1531,7 +1942,7 @@ func (x *KeyboardInteractiveAuthMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeyboardInteractiveAuthMessage.ProtoReflect.Descriptor instead.
 func (*KeyboardInteractiveAuthMessage) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{27}
+	return file_plugin_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *KeyboardInteractiveAuthMessage) GetMessage() isKeyboardInteractiveAuthMessage_Message {
@@ -1632,7 +2043,7 @@ type UpstreamAuthFailureNoticeRequest struct {
 
 func (x *UpstreamAuthFailureNoticeRequest) Reset() {
 	*x = UpstreamAuthFailureNoticeRequest{}
-	mi := &file_plugin_proto_msgTypes[28]
+	mi := &file_plugin_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1644,7 +2055,7 @@ func (x *UpstreamAuthFailureNoticeRequest) String() string {
 func (*UpstreamAuthFailureNoticeRequest) ProtoMessage() {}
 
 func (x *UpstreamAuthFailureNoticeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[28]
+	mi := &file_plugin_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1657,7 +2068,7 @@ func (x *UpstreamAuthFailureNoticeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpstreamAuthFailureNoticeRequest.ProtoReflect.Descriptor instead.
 func (*UpstreamAuthFailureNoticeRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{28}
+	return file_plugin_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *UpstreamAuthFailureNoticeRequest) GetMeta() *ConnMeta {
@@ -1696,7 +2107,7 @@ type UpstreamAuthFailureNoticeResponse struct {
 
 func (x *UpstreamAuthFailureNoticeResponse) Reset() {
 	*x = UpstreamAuthFailureNoticeResponse{}
-	mi := &file_plugin_proto_msgTypes[29]
+	mi := &file_plugin_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1708,7 +2119,7 @@ func (x *UpstreamAuthFailureNoticeResponse) String() string {
 func (*UpstreamAuthFailureNoticeResponse) ProtoMessage() {}
 
 func (x *UpstreamAuthFailureNoticeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[29]
+	mi := &file_plugin_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1721,7 +2132,7 @@ func (x *UpstreamAuthFailureNoticeResponse) ProtoReflect() protoreflect.Message
 
 // Deprecated: Use UpstreamAuthFailureNoticeResponse.ProtoReflect.Descriptor instead.
 func (*UpstreamAuthFailureNoticeResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{29}
+	return file_plugin_proto_rawDescGZIP(), []int{34}
 }
 
 type BannerRequest struct {
@@ -1733,7 +2144,7 @@ type BannerRequest struct {
 
 func (x *BannerRequest) Reset() {
 	*x = BannerRequest{}
-	mi := &file_plugin_proto_msgTypes[30]
+	mi := &file_plugin_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1745,7 +2156,7 @@ func (x *BannerRequest) String() string {
 func (*BannerRequest) ProtoMessage() {}
 
 func (x *BannerRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[30]
+	mi := &file_plugin_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1758,7 +2169,7 @@ func (x *BannerRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BannerRequest.ProtoReflect.Descriptor instead.
 func (*BannerRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{30}
+	return file_plugin_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *BannerRequest) GetMeta() *ConnMeta {
@@ -1777,7 +2188,7 @@ type BannerResponse struct {
 
 func (x *BannerResponse) Reset() {
 	*x = BannerResponse{}
-	mi := &file_plugin_proto_msgTypes[31]
+	mi := &file_plugin_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1789,7 +2200,7 @@ func (x *BannerResponse) String() string {
 func (*BannerResponse) ProtoMessage() {}
 
 func (x *BannerResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[31]
+	mi := &file_plugin_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1802,7 +2213,7 @@ func (x *BannerResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BannerResponse.ProtoReflect.Descriptor instead.
 func (*BannerResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{31}
+	return file_plugin_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *BannerResponse) GetMessage() string {
@@ -1824,7 +2235,7 @@ type VerifyHostKeyRequest struct {
 
 func (x *VerifyHostKeyRequest) Reset() {
 	*x = VerifyHostKeyRequest{}
-	mi := &file_plugin_proto_msgTypes[32]
+	mi := &file_plugin_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1836,7 +2247,7 @@ func (x *VerifyHostKeyRequest) String() string {
 func (*VerifyHostKeyRequest) ProtoMessage() {}
 
 func (x *VerifyHostKeyRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[32]
+	mi := &file_plugin_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1849,7 +2260,7 @@ func (x *VerifyHostKeyRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VerifyHostKeyRequest.ProtoReflect.Descriptor instead.
 func (*VerifyHostKeyRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{32}
+	return file_plugin_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *VerifyHostKeyRequest) GetMeta() *ConnMeta {
@@ -1889,7 +2300,7 @@ type VerifyHostKeyResponse struct {
 
 func (x *VerifyHostKeyResponse) Reset() {
 	*x = VerifyHostKeyResponse{}
-	mi := &file_plugin_proto_msgTypes[33]
+	mi := &file_plugin_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1901,7 +2312,7 @@ func (x *VerifyHostKeyResponse) String() string {
 func (*VerifyHostKeyResponse) ProtoMessage() {}
 
 func (x *VerifyHostKeyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[33]
+	mi := &file_plugin_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1914,7 +2325,7 @@ func (x *VerifyHostKeyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VerifyHostKeyResponse.ProtoReflect.Descriptor instead.
 func (*VerifyHostKeyResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{33}
+	return file_plugin_proto_rawDescGZIP(), []int{38}
 }
 
 func (x *VerifyHostKeyResponse) GetVerified() bool {
@@ -1933,7 +2344,7 @@ type PipeStartNoticeRequest struct {
 
 func (x *PipeStartNoticeRequest) Reset() {
 	*x = PipeStartNoticeRequest{}
-	mi := &file_plugin_proto_msgTypes[34]
+	mi := &file_plugin_proto_msgTypes[39]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1945,7 +2356,7 @@ func (x *PipeStartNoticeRequest) String() string {
 func (*PipeStartNoticeRequest) ProtoMessage() {}
 
 func (x *PipeStartNoticeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[34]
+	mi := &file_plugin_proto_msgTypes[39]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1958,7 +2369,7 @@ func (x *PipeStartNoticeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PipeStartNoticeRequest.ProtoReflect.Descriptor instead.
 func (*PipeStartNoticeRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{34}
+	return file_plugin_proto_rawDescGZIP(), []int{39}
 }
 
 func (x *PipeStartNoticeRequest) GetMeta() *ConnMeta {
@@ -1976,7 +2387,7 @@ type PipeStartNoticeResponse struct {
 
 func (x *PipeStartNoticeResponse) Reset() {
 	*x = PipeStartNoticeResponse{}
-	mi := &file_plugin_proto_msgTypes[35]
+	mi := &file_plugin_proto_msgTypes[40]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1988,7 +2399,7 @@ func (x *PipeStartNoticeResponse) String() string {
 func (*PipeStartNoticeResponse) ProtoMessage() {}
 
 func (x *PipeStartNoticeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[35]
+	mi := &file_plugin_proto_msgTypes[40]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2001,7 +2412,7 @@ func (x *PipeStartNoticeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PipeStartNoticeResponse.ProtoReflect.Descriptor instead.
 func (*PipeStartNoticeResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{35}
+	return file_plugin_proto_rawDescGZIP(), []int{40}
 }
 
 type PipeErrorNoticeRequest struct {
@@ -2014,7 +2425,7 @@ type PipeErrorNoticeRequest struct {
 
 func (x *PipeErrorNoticeRequest) Reset() {
 	*x = PipeErrorNoticeRequest{}
-	mi := &file_plugin_proto_msgTypes[36]
+	mi := &file_plugin_proto_msgTypes[41]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2026,7 +2437,7 @@ func (x *PipeErrorNoticeRequest) String() string {
 func (*PipeErrorNoticeRequest) ProtoMessage() {}
 
 func (x *PipeErrorNoticeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[36]
+	mi := &file_plugin_proto_msgTypes[41]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2039,7 +2450,7 @@ func (x *PipeErrorNoticeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PipeErrorNoticeRequest.ProtoReflect.Descriptor instead.
 func (*PipeErrorNoticeRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{36}
+	return file_plugin_proto_rawDescGZIP(), []int{41}
 }
 
 func (x *PipeErrorNoticeRequest) GetMeta() *ConnMeta {
@@ -2064,7 +2475,7 @@ type PipeErrorNoticeResponse struct {
 
 func (x *PipeErrorNoticeResponse) Reset() {
 	*x = PipeErrorNoticeResponse{}
-	mi := &file_plugin_proto_msgTypes[37]
+	mi := &file_plugin_proto_msgTypes[42]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2076,7 +2487,7 @@ func (x *PipeErrorNoticeResponse) String() string {
 func (*PipeErrorNoticeResponse) ProtoMessage() {}
 
 func (x *PipeErrorNoticeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[37]
+	mi := &file_plugin_proto_msgTypes[42]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2089,7 +2500,436 @@ func (x *PipeErrorNoticeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PipeErrorNoticeResponse.ProtoReflect.Descriptor instead.
 func (*PipeErrorNoticeResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{37}
+	return file_plugin_proto_rawDescGZIP(), []int{42}
+}
+
+// PipeStats describes a pipe once it is closed.
+type PipeStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Unix timestamps in milliseconds.
+	StartedAt int64 `protobuf:"varint,1,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	EndedAt   int64 `protobuf:"varint,2,opt,name=ended_at,json=endedAt,proto3" json:"ended_at,omitempty"`
+	// Channel data bytes sent by the client and by the upstream server,
+	// without ssh framing.
+	DownstreamBytes int64 `protobuf:"varint,3,opt,name=downstream_bytes,json=downstreamBytes,proto3" json:"downstream_bytes,omitempty"`
+	UpstreamBytes   int64 `protobuf:"varint,4,opt,name=upstream_bytes,json=upstreamBytes,proto3" json:"upstream_bytes,omitempty"`
+	// Number of channels opened by channel type, e.g. session or
+	// direct-tcpip.
+	Channels map[string]int64 `protobuf:"bytes,5,rep,name=channels,proto3" json:"channels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Commands of the exec requests, in order, the first 100 only.
+	ExecCommands []string `protobuf:"bytes,6,rep,name=exec_commands,json=execCommands,proto3" json:"exec_commands,omitempty"`
+	// Exit statuses sent by the upstream server, in order, the first 100
+	// only.
+	ExitStatuses []uint32 `protobuf:"varint,7,rep,packed,name=exit_statuses,json=exitStatuses,proto3" json:"exit_statuses,omitempty"`
+	// Why the pipe was closed, empty when both sides closed it cleanly.
+	CloseReason string `protobuf:"bytes,8,opt,name=close_reason,json=closeReason,proto3" json:"close_reason,omitempty"`
+	// Number of exec commands and exit statuses left out of exec_commands
+	// and exit_statuses.
+	DroppedExecCommands int64 `protobuf:"varint,9,opt,name=dropped_exec_commands,json=droppedExecCommands,proto3" json:"dropped_exec_commands,omitempty"`
+	DroppedExitStatuses int64 `protobuf:"varint,10,opt,name=dropped_exit_statuses,json=droppedExitStatuses,proto3" json:"dropped_exit_statuses,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *PipeStats) Reset() {
+	*x = PipeStats{}
+	mi := &file_plugin_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PipeStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipeStats) ProtoMessage() {}
+
+func (x *PipeStats) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipeStats.ProtoReflect.Descriptor instead.
+func (*PipeStats) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *PipeStats) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *PipeStats) GetEndedAt() int64 {
+	if x != nil {
+		return x.EndedAt
+	}
+	return 0
+}
+
+func (x *PipeStats) GetDownstreamBytes() int64 {
+	if x != nil {
+		return x.DownstreamBytes
+	}
+	return 0
+}
+
+func (x *PipeStats) GetUpstreamBytes() int64 {
+	if x != nil {
+		return x.UpstreamBytes
+	}
+	return 0
+}
+
+func (x *PipeStats) GetChannels() map[string]int64 {
+	if x != nil {
+		return x.Channels
+	}
+	return nil
+}
+
+func (x *PipeStats) GetExecCommands() []string {
+	if x != nil {
+		return x.ExecCommands
+	}
+	return nil
+}
+
+func (x *PipeStats) GetExitStatuses() []uint32 {
+	if x != nil {
+		return x.ExitStatuses
+	}
+	return nil
+}
+
+func (x *PipeStats) GetCloseReason() string {
+	if x != nil {
+		return x.CloseReason
+	}
+	return ""
+}
+
+func (x *PipeStats) GetDroppedExecCommands() int64 {
+	if x != nil {
+		return x.DroppedExecCommands
+	}
+	return 0
+}
+
+func (x *PipeStats) GetDroppedExitStatuses() int64 {
+	if x != nil {
+		return x.DroppedExitStatuses
+	}
+	return 0
+}
+
+type PipeEndNoticeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *ConnMeta              `protobuf:"bytes,1,opt,name=meta,proto3" json:"meta,omitempty"`
+	Stats         *PipeStats             `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PipeEndNoticeRequest) Reset() {
+	*x = PipeEndNoticeRequest{}
+	mi := &file_plugin_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PipeEndNoticeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipeEndNoticeRequest) ProtoMessage() {}
+
+func (x *PipeEndNoticeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipeEndNoticeRequest.ProtoReflect.Descriptor instead.
+func (*PipeEndNoticeRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *PipeEndNoticeRequest) GetMeta() *ConnMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *PipeEndNoticeRequest) GetStats() *PipeStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type PipeEndNoticeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PipeEndNoticeResponse) Reset() {
+	*x = PipeEndNoticeResponse{}
+	mi := &file_plugin_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PipeEndNoticeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipeEndNoticeResponse) ProtoMessage() {}
+
+func (x *PipeEndNoticeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipeEndNoticeResponse.ProtoReflect.Descriptor instead.
+func (*PipeEndNoticeResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{45}
+}
+
+// AuthorizeChannelRequest is sent for what the client asks once the pipe
+// is established.
+type AuthorizeChannelRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Meta  *ConnMeta              `protobuf:"bytes,1,opt,name=meta,proto3" json:"meta,omitempty"`
+	// channel-open, shell, exec, subsystem, pty-req, env, tcpip-forward or
+	// streamlocal-forward@openssh.com.
+	Request string `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`
+	// Type of the channel opened, or the request is made on, e.g. session or
+	// direct-tcpip. Empty for forward requests.
+	ChannelType string `protobuf:"bytes,3,opt,name=channel_type,json=channelType,proto3" json:"channel_type,omitempty"`
+	// exec: the command.
+	Command string `protobuf:"bytes,4,opt,name=command,proto3" json:"command,omitempty"`
+	// subsystem: the subsystem, e.g. sftp.
+	Subsystem string `protobuf:"bytes,5,opt,name=subsystem,proto3" json:"subsystem,omitempty"`
+	// pty-req: the TERM of the terminal.
+	Term string `protobuf:"bytes,6,opt,name=term,proto3" json:"term,omitempty"`
+	// env: the variable.
+	EnvName  string `protobuf:"bytes,7,opt,name=env_name,json=envName,proto3" json:"env_name,omitempty"`
+	EnvValue string `protobuf:"bytes,8,opt,name=env_value,json=envValue,proto3" json:"env_value,omitempty"`
+	// direct-tcpip: the destination, tcpip-forward: the address to listen on
+	// the upstream server.
+	Host string `protobuf:"bytes,9,opt,name=host,proto3" json:"host,omitempty"`
+	Port uint32 `protobuf:"varint,10,opt,name=port,proto3" json:"port,omitempty"`
+	// direct-streamlocal@openssh.com: the destination,
+	// streamlocal-forward@openssh.com: the socket to listen on the upstream
+	// server.
+	SocketPath    string `protobuf:"bytes,11,opt,name=socket_path,json=socketPath,proto3" json:"socket_path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthorizeChannelRequest) Reset() {
+	*x = AuthorizeChannelRequest{}
+	mi := &file_plugin_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthorizeChannelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorizeChannelRequest) ProtoMessage() {}
+
+func (x *AuthorizeChannelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorizeChannelRequest.ProtoReflect.Descriptor instead.
+func (*AuthorizeChannelRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *AuthorizeChannelRequest) GetMeta() *ConnMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *AuthorizeChannelRequest) GetRequest() string {
+	if x != nil {
+		return x.Request
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelRequest) GetChannelType() string {
+	if x != nil {
+		return x.ChannelType
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelRequest) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelRequest) GetSubsystem() string {
+	if x != nil {
+		return x.Subsystem
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelRequest) GetTerm() string {
+	if x != nil {
+		return x.Term
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelRequest) GetEnvName() string {
+	if x != nil {
+		return x.EnvName
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelRequest) GetEnvValue() string {
+	if x != nil {
+		return x.EnvValue
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelRequest) GetPort() uint32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *AuthorizeChannelRequest) GetSocketPath() string {
+	if x != nil {
+		return x.SocketPath
+	}
+	return ""
+}
+
+type AuthorizeChannelResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Decision ChannelDecision        `protobuf:"varint,1,opt,name=decision,proto3,enum=libplugin.ChannelDecision" json:"decision,omitempty"`
+	// Why the request was denied, sent to the client when the protocol
+	// allows it, i.e. for channel-open.
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// CHANNEL_REWRITE: the command to run instead, turns a shell request
+	// into an exec.
+	Command string `protobuf:"bytes,3,opt,name=command,proto3" json:"command,omitempty"`
+	// CHANNEL_REWRITE: the subsystem to start instead, takes precedence
+	// over command.
+	Subsystem     string `protobuf:"bytes,4,opt,name=subsystem,proto3" json:"subsystem,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthorizeChannelResponse) Reset() {
+	*x = AuthorizeChannelResponse{}
+	mi := &file_plugin_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthorizeChannelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorizeChannelResponse) ProtoMessage() {}
+
+func (x *AuthorizeChannelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorizeChannelResponse.ProtoReflect.Descriptor instead.
+func (*AuthorizeChannelResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *AuthorizeChannelResponse) GetDecision() ChannelDecision {
+	if x != nil {
+		return x.Decision
+	}
+	return ChannelDecision_CHANNEL_ALLOW
+}
+
+func (x *AuthorizeChannelResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelResponse) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *AuthorizeChannelResponse) GetSubsystem() string {
+	if x != nil {
+		return x.Subsystem
+	}
+	return ""
 }
 
 type PipeCreateErrorNoticeRequest struct {
@@ -2102,7 +2942,7 @@ type PipeCreateErrorNoticeRequest struct {
 
 func (x *PipeCreateErrorNoticeRequest) Reset() {
 	*x = PipeCreateErrorNoticeRequest{}
-	mi := &file_plugin_proto_msgTypes[38]
+	mi := &file_plugin_proto_msgTypes[48]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2114,7 +2954,7 @@ func (x *PipeCreateErrorNoticeRequest) String() string {
 func (*PipeCreateErrorNoticeRequest) ProtoMessage() {}
 
 func (x *PipeCreateErrorNoticeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[38]
+	mi := &file_plugin_proto_msgTypes[48]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2127,7 +2967,7 @@ func (x *PipeCreateErrorNoticeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PipeCreateErrorNoticeRequest.ProtoReflect.Descriptor instead.
 func (*PipeCreateErrorNoticeRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{38}
+	return file_plugin_proto_rawDescGZIP(), []int{48}
 }
 
 func (x *PipeCreateErrorNoticeRequest) GetFromAddr() string {
@@ -2152,7 +2992,7 @@ type PipeCreateErrorNoticeResponse struct {
 
 func (x *PipeCreateErrorNoticeResponse) Reset() {
 	*x = PipeCreateErrorNoticeResponse{}
-	mi := &file_plugin_proto_msgTypes[39]
+	mi := &file_plugin_proto_msgTypes[49]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2164,7 +3004,7 @@ func (x *PipeCreateErrorNoticeResponse) String() string {
 func (*PipeCreateErrorNoticeResponse) ProtoMessage() {}
 
 func (x *PipeCreateErrorNoticeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[39]
+	mi := &file_plugin_proto_msgTypes[49]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2177,7 +3017,7 @@ func (x *PipeCreateErrorNoticeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PipeCreateErrorNoticeResponse.ProtoReflect.Descriptor instead.
 func (*PipeCreateErrorNoticeResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{39}
+	return file_plugin_proto_rawDescGZIP(), []int{49}
 }
 
 type KeyboardInteractivePromptRequest_Question struct {
@@ -2190,7 +3030,7 @@ type KeyboardInteractivePromptRequest_Question struct {
 
 func (x *KeyboardInteractivePromptRequest_Question) Reset() {
 	*x = KeyboardInteractivePromptRequest_Question{}
-	mi := &file_plugin_proto_msgTypes[44]
+	mi := &file_plugin_proto_msgTypes[55]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2202,7 +3042,7 @@ func (x *KeyboardInteractivePromptRequest_Question) String() string {
 func (*KeyboardInteractivePromptRequest_Question) ProtoMessage() {}
 
 func (x *KeyboardInteractivePromptRequest_Question) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_proto_msgTypes[44]
+	mi := &file_plugin_proto_msgTypes[55]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2215,7 +3055,7 @@ func (x *KeyboardInteractivePromptRequest_Question) ProtoReflect() protoreflect.
 
 // Deprecated: Use KeyboardInteractivePromptRequest_Question.ProtoReflect.Descriptor instead.
 func (*KeyboardInteractivePromptRequest_Question) Descriptor() ([]byte, []int) {
-	return file_plugin_proto_rawDescGZIP(), []int{23, 0}
+	return file_plugin_proto_rawDescGZIP(), []int{28, 0}
 }
 
 func (x *KeyboardInteractivePromptRequest_Question) GetText() string {
@@ -2244,7 +3084,7 @@ const file_plugin_proto_rawDesc = "" +
 	"\bmetadata\x18\x04 \x03(\v2!.libplugin.ConnMeta.MetadataEntryR\bmetadata\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xda\x05\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8f\a\n" +
 	"\bUpstream\x12\x16\n" +
 	"\x04host\x18\x01 \x01(\tB\x02\x18\x01R\x04host\x12\x16\n" +
 	"\x04port\x18\x02 \x01(\x05B\x02\x18\x01R\x04port\x12\x1b\n" +
@@ -2252,7 +3092,9 @@ const file_plugin_proto_rawDesc = "" +
 	"\x0fignore_host_key\x18\x04 \x01(\bB\x02\x18\x01R\rignoreHostKey\x12\x10\n" +
 	"\x03uri\x18\x05 \x01(\tR\x03uri\x12(\n" +
 	"\x10known_hosts_data\x18\x06 \x01(\fR\x0eknownHostsData\x12.\n" +
-	"\x03env\x18\a \x03(\v2\x1c.libplugin.Upstream.EnvEntryR\x03env\x121\n" +
+	"\x03env\x18\a \x03(\v2\x1c.libplugin.Upstream.EnvEntryR\x03env\x12?\n" +
+	"\x0esession_policy\x18\b \x01(\v2\x18.libplugin.SessionPolicyR\rsessionPolicy\x127\n" +
+	"\x06labels\x18\t \x03(\v2\x1f.libplugin.Upstream.LabelsEntryR\x06labels\x121\n" +
 	"\x04none\x18d \x01(\v2\x1b.libplugin.UpstreamNoneAuthH\x00R\x04none\x12=\n" +
 	"\bpassword\x18e \x01(\v2\x1f.libplugin.UpstreamPasswordAuthH\x00R\bpassword\x12D\n" +
 	"\vprivate_key\x18f \x01(\v2!.libplugin.UpstreamPrivateKeyAuthH\x00R\n" +
@@ -2263,8 +3105,32 @@ const file_plugin_proto_rawDesc = "" +
 	"\x14retry_current_plugin\x18\xc9\x01 \x01(\v2).libplugin.UpstreamRetryCurrentPluginAuthH\x00R\x12retryCurrentPlugin\x1a6\n" +
 	"\bEnvEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x06\n" +
-	"\x04auth\"\x12\n" +
+	"\x04auth\"\x8a\x05\n" +
+	"\rSessionPolicy\x12!\n" +
+	"\trecording\x18\x01 \x01(\bH\x00R\trecording\x88\x01\x01\x12)\n" +
+	"\x10recording_format\x18\x02 \x01(\tR\x0frecordingFormat\x12.\n" +
+	"\x10local_forwarding\x18\x03 \x01(\bH\x01R\x0flocalForwarding\x88\x01\x01\x120\n" +
+	"\x11remote_forwarding\x18\x04 \x01(\bH\x02R\x10remoteForwarding\x88\x01\x01\x12.\n" +
+	"\x10agent_forwarding\x18\x05 \x01(\bH\x03R\x0fagentForwarding\x88\x01\x01\x12*\n" +
+	"\x0ex11_forwarding\x18\x06 \x01(\bH\x04R\rx11Forwarding\x88\x01\x01\x125\n" +
+	"\x14idle_timeout_seconds\x18\a \x01(\rH\x05R\x12idleTimeoutSeconds\x88\x01\x01\x125\n" +
+	"\x14max_duration_seconds\x18\b \x01(\rH\x06R\x12maxDurationSeconds\x88\x01\x01\x12,\n" +
+	"\x0fbandwidth_limit\x18\t \x01(\x04H\aR\x0ebandwidthLimit\x88\x01\x01\x12*\n" +
+	"\x11post_auth_message\x18\n" +
+	" \x01(\tR\x0fpostAuthMessageB\f\n" +
+	"\n" +
+	"_recordingB\x13\n" +
+	"\x11_local_forwardingB\x14\n" +
+	"\x12_remote_forwardingB\x13\n" +
+	"\x11_agent_forwardingB\x11\n" +
+	"\x0f_x11_forwardingB\x17\n" +
+	"\x15_idle_timeout_secondsB\x17\n" +
+	"\x15_max_duration_secondsB\x12\n" +
+	"\x10_bandwidth_limit\"\x12\n" +
 	"\x10UpstreamNoneAuth\"2\n" +
 	"\x14UpstreamPasswordAuth\x12\x1a\n" +
 	"\bpassword\x18\x01 \x01(\tR\bpassword\"]\n" +
@@ -2289,10 +3155,22 @@ const file_plugin_proto_rawDesc = "" +
 	"\x05level\x18\x02 \x01(\tR\x05level\x12\x10\n" +
 	"\x03tty\x18\x03 \x01(\bR\x03tty\"\x1f\n" +
 	"\x03Log\x12\x18\n" +
-	"\amessage\x18\x01 \x01(\tR\amessage\"\x15\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"s\n" +
+	"\x10HandshakeRequest\x12)\n" +
+	"\x10protocol_version\x18\x01 \x01(\rR\x0fprotocolVersion\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x1a\n" +
+	"\bfeatures\x18\x03 \x03(\tR\bfeatures\"\xb5\x01\n" +
+	"\x11HandshakeResponse\x12)\n" +
+	"\x10protocol_version\x18\x01 \x01(\rR\x0fprotocolVersion\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12\x1a\n" +
+	"\bfeatures\x18\x04 \x03(\tR\bfeatures\x12+\n" +
+	"\x11required_features\x18\x05 \x03(\tR\x10requiredFeatures\"\x15\n" +
 	"\x13ListCallbackRequest\"4\n" +
 	"\x14ListCallbackResponse\x12\x1c\n" +
-	"\tcallbacks\x18\x01 \x03(\tR\tcallbacks\"?\n" +
+	"\tcallbacks\x18\x01 \x03(\tR\tcallbacks\"\r\n" +
+	"\vPingRequest\"\x0e\n" +
+	"\fPingResponse\"?\n" +
 	"\x14NewConnectionRequest\x12'\n" +
 	"\x04meta\x18\x01 \x01(\v2\x13.libplugin.ConnMetaR\x04meta\"\x17\n" +
 	"\x15NewConnectionResponse\"A\n" +
@@ -2361,7 +3239,46 @@ const file_plugin_proto_rawDesc = "" +
 	"\x16PipeErrorNoticeRequest\x12'\n" +
 	"\x04meta\x18\x01 \x01(\v2\x13.libplugin.ConnMetaR\x04meta\x12\x14\n" +
 	"\x05error\x18\x02 \x01(\tR\x05error\"\x19\n" +
-	"\x17PipeErrorNoticeResponse\"Q\n" +
+	"\x17PipeErrorNoticeResponse\"\xe9\x03\n" +
+	"\tPipeStats\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\x01 \x01(\x03R\tstartedAt\x12\x19\n" +
+	"\bended_at\x18\x02 \x01(\x03R\aendedAt\x12)\n" +
+	"\x10downstream_bytes\x18\x03 \x01(\x03R\x0fdownstreamBytes\x12%\n" +
+	"\x0eupstream_bytes\x18\x04 \x01(\x03R\rupstreamBytes\x12>\n" +
+	"\bchannels\x18\x05 \x03(\v2\".libplugin.PipeStats.ChannelsEntryR\bchannels\x12#\n" +
+	"\rexec_commands\x18\x06 \x03(\tR\fexecCommands\x12#\n" +
+	"\rexit_statuses\x18\a \x03(\rR\fexitStatuses\x12!\n" +
+	"\fclose_reason\x18\b \x01(\tR\vcloseReason\x122\n" +
+	"\x15dropped_exec_commands\x18\t \x01(\x03R\x13droppedExecCommands\x122\n" +
+	"\x15dropped_exit_statuses\x18\n" +
+	" \x01(\x03R\x13droppedExitStatuses\x1a;\n" +
+	"\rChannelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"k\n" +
+	"\x14PipeEndNoticeRequest\x12'\n" +
+	"\x04meta\x18\x01 \x01(\v2\x13.libplugin.ConnMetaR\x04meta\x12*\n" +
+	"\x05stats\x18\x02 \x01(\v2\x14.libplugin.PipeStatsR\x05stats\"\x17\n" +
+	"\x15PipeEndNoticeResponse\"\xcc\x02\n" +
+	"\x17AuthorizeChannelRequest\x12'\n" +
+	"\x04meta\x18\x01 \x01(\v2\x13.libplugin.ConnMetaR\x04meta\x12\x18\n" +
+	"\arequest\x18\x02 \x01(\tR\arequest\x12!\n" +
+	"\fchannel_type\x18\x03 \x01(\tR\vchannelType\x12\x18\n" +
+	"\acommand\x18\x04 \x01(\tR\acommand\x12\x1c\n" +
+	"\tsubsystem\x18\x05 \x01(\tR\tsubsystem\x12\x12\n" +
+	"\x04term\x18\x06 \x01(\tR\x04term\x12\x19\n" +
+	"\benv_name\x18\a \x01(\tR\aenvName\x12\x1b\n" +
+	"\tenv_value\x18\b \x01(\tR\benvValue\x12\x12\n" +
+	"\x04host\x18\t \x01(\tR\x04host\x12\x12\n" +
+	"\x04port\x18\n" +
+	" \x01(\rR\x04port\x12\x1f\n" +
+	"\vsocket_path\x18\v \x01(\tR\n" +
+	"socketPath\"\xa4\x01\n" +
+	"\x18AuthorizeChannelResponse\x126\n" +
+	"\bdecision\x18\x01 \x01(\x0e2\x1a.libplugin.ChannelDecisionR\bdecision\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x18\n" +
+	"\acommand\x18\x03 \x01(\tR\acommand\x12\x1c\n" +
+	"\tsubsystem\x18\x04 \x01(\tR\tsubsystem\"Q\n" +
 	"\x1cPipeCreateErrorNoticeRequest\x12\x1b\n" +
 	"\tfrom_addr\x18\x01 \x01(\tR\bfromAddr\x12\x14\n" +
 	"\x05error\x18\x02 \x01(\tR\x05error\"\x1f\n" +
@@ -2371,10 +3288,16 @@ const file_plugin_proto_rawDesc = "" +
 	"\x04NONE\x10\x00\x12\f\n" +
 	"\bPASSWORD\x10\x01\x12\r\n" +
 	"\tPUBLICKEY\x10\x02\x12\x18\n" +
-	"\x14KEYBOARD_INTERACTIVE\x10\x032\xec\t\n" +
+	"\x14KEYBOARD_INTERACTIVE\x10\x03*K\n" +
+	"\x0fChannelDecision\x12\x11\n" +
+	"\rCHANNEL_ALLOW\x10\x00\x12\x10\n" +
+	"\fCHANNEL_DENY\x10\x01\x12\x13\n" +
+	"\x0fCHANNEL_REWRITE\x10\x022\xa6\f\n" +
 	"\x0eSshPiperPlugin\x126\n" +
-	"\x04Logs\x12\x1a.libplugin.StartLogRequest\x1a\x0e.libplugin.Log\"\x000\x01\x12R\n" +
-	"\rListCallbacks\x12\x1e.libplugin.ListCallbackRequest\x1a\x1f.libplugin.ListCallbackResponse\"\x00\x12T\n" +
+	"\x04Logs\x12\x1a.libplugin.StartLogRequest\x1a\x0e.libplugin.Log\"\x000\x01\x12H\n" +
+	"\tHandshake\x12\x1b.libplugin.HandshakeRequest\x1a\x1c.libplugin.HandshakeResponse\"\x00\x12R\n" +
+	"\rListCallbacks\x12\x1e.libplugin.ListCallbackRequest\x1a\x1f.libplugin.ListCallbackResponse\"\x00\x129\n" +
+	"\x04Ping\x12\x16.libplugin.PingRequest\x1a\x17.libplugin.PingResponse\"\x00\x12T\n" +
 	"\rNewConnection\x12\x1f.libplugin.NewConnectionRequest\x1a .libplugin.NewConnectionResponse\"\x00\x12Z\n" +
 	"\x0fNextAuthMethods\x12!.libplugin.NextAuthMethodsRequest\x1a\".libplugin.NextAuthMethodsResponse\"\x00\x12E\n" +
 	"\bNoneAuth\x12\x1a.libplugin.NoneAuthRequest\x1a\x1b.libplugin.NoneAuthResponse\"\x00\x12Q\n" +
@@ -2386,7 +3309,9 @@ const file_plugin_proto_rawDesc = "" +
 	"\rVerifyHostKey\x12\x1f.libplugin.VerifyHostKeyRequest\x1a .libplugin.VerifyHostKeyResponse\"\x00\x12l\n" +
 	"\x15PipeCreateErrorNotice\x12'.libplugin.PipeCreateErrorNoticeRequest\x1a(.libplugin.PipeCreateErrorNoticeResponse\"\x00\x12Z\n" +
 	"\x0fPipeStartNotice\x12!.libplugin.PipeStartNoticeRequest\x1a\".libplugin.PipeStartNoticeResponse\"\x00\x12Z\n" +
-	"\x0fPipeErrorNotice\x12!.libplugin.PipeErrorNoticeRequest\x1a\".libplugin.PipeErrorNoticeResponse\"\x00B%Z#github.com/tg123/sshpiper/libpluginb\x06proto3"
+	"\x0fPipeErrorNotice\x12!.libplugin.PipeErrorNoticeRequest\x1a\".libplugin.PipeErrorNoticeResponse\"\x00\x12T\n" +
+	"\rPipeEndNotice\x12\x1f.libplugin.PipeEndNoticeRequest\x1a .libplugin.PipeEndNoticeResponse\"\x00\x12]\n" +
+	"\x10AuthorizeChannel\x12\".libplugin.AuthorizeChannelRequest\x1a#.libplugin.AuthorizeChannelResponse\"\x00B%Z#github.com/tg123/sshpiper/libpluginb\x06proto3"
 
 var (
 	file_plugin_proto_rawDescOnce sync.Once
@@ -2400,123 +3325,151 @@ func file_plugin_proto_rawDescGZIP() []byte {
 	return file_plugin_proto_rawDescData
 }
 
-var file_plugin_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_plugin_proto_msgTypes = make([]protoimpl.MessageInfo, 45)
+var file_plugin_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_plugin_proto_msgTypes = make([]protoimpl.MessageInfo, 57)
 var file_plugin_proto_goTypes = []any{
 	(AuthMethod)(0),                                   // 0: libplugin.AuthMethod
-	(*ConnMeta)(nil),                                  // 1: libplugin.ConnMeta
-	(*Upstream)(nil),                                  // 2: libplugin.Upstream
-	(*UpstreamNoneAuth)(nil),                          // 3: libplugin.UpstreamNoneAuth
-	(*UpstreamPasswordAuth)(nil),                      // 4: libplugin.UpstreamPasswordAuth
-	(*UpstreamPrivateKeyAuth)(nil),                    // 5: libplugin.UpstreamPrivateKeyAuth
-	(*UpstreamRemoteSignerAuth)(nil),                  // 6: libplugin.UpstreamRemoteSignerAuth
-	(*UpstreamNextPluginAuth)(nil),                    // 7: libplugin.UpstreamNextPluginAuth
-	(*UpstreamRetryCurrentPluginAuth)(nil),            // 8: libplugin.UpstreamRetryCurrentPluginAuth
-	(*StartLogRequest)(nil),                           // 9: libplugin.StartLogRequest
-	(*Log)(nil),                                       // 10: libplugin.Log
-	(*ListCallbackRequest)(nil),                       // 11: libplugin.ListCallbackRequest
-	(*ListCallbackResponse)(nil),                      // 12: libplugin.ListCallbackResponse
-	(*NewConnectionRequest)(nil),                      // 13: libplugin.NewConnectionRequest
-	(*NewConnectionResponse)(nil),                     // 14: libplugin.NewConnectionResponse
-	(*NextAuthMethodsRequest)(nil),                    // 15: libplugin.NextAuthMethodsRequest
-	(*NextAuthMethodsResponse)(nil),                   // 16: libplugin.NextAuthMethodsResponse
-	(*NoneAuthRequest)(nil),                           // 17: libplugin.NoneAuthRequest
-	(*NoneAuthResponse)(nil),                          // 18: libplugin.NoneAuthResponse
-	(*PasswordAuthRequest)(nil),                       // 19: libplugin.PasswordAuthRequest
-	(*PasswordAuthResponse)(nil),                      // 20: libplugin.PasswordAuthResponse
-	(*PublicKeyAuthRequest)(nil),                      // 21: libplugin.PublicKeyAuthRequest
-	(*PublicKeyAuthResponse)(nil),                     // 22: libplugin.PublicKeyAuthResponse
-	(*KeyboardInteractiveUserResponse)(nil),           // 23: libplugin.KeyboardInteractiveUserResponse
-	(*KeyboardInteractivePromptRequest)(nil),          // 24: libplugin.KeyboardInteractivePromptRequest
-	(*KeyboardInteractiveMetaRequest)(nil),            // 25: libplugin.KeyboardInteractiveMetaRequest
-	(*KeyboardInteractiveMetaResponse)(nil),           // 26: libplugin.KeyboardInteractiveMetaResponse
-	(*KeyboardInteractiveFinishRequest)(nil),          // 27: libplugin.KeyboardInteractiveFinishRequest
-	(*KeyboardInteractiveAuthMessage)(nil),            // 28: libplugin.KeyboardInteractiveAuthMessage
-	(*UpstreamAuthFailureNoticeRequest)(nil),          // 29: libplugin.UpstreamAuthFailureNoticeRequest
-	(*UpstreamAuthFailureNoticeResponse)(nil),         // 30: libplugin.UpstreamAuthFailureNoticeResponse
-	(*BannerRequest)(nil),                             // 31: libplugin.BannerRequest
-	(*BannerResponse)(nil),                            // 32: libplugin.BannerResponse
-	(*VerifyHostKeyRequest)(nil),                      // 33: libplugin.VerifyHostKeyRequest
-	(*VerifyHostKeyResponse)(nil),                     // 34: libplugin.VerifyHostKeyResponse
-	(*PipeStartNoticeRequest)(nil),                    // 35: libplugin.PipeStartNoticeRequest
-	(*PipeStartNoticeResponse)(nil),                   // 36: libplugin.PipeStartNoticeResponse
-	(*PipeErrorNoticeRequest)(nil),                    // 37: libplugin.PipeErrorNoticeRequest
-	(*PipeErrorNoticeResponse)(nil),                   // 38: libplugin.PipeErrorNoticeResponse
-	(*PipeCreateErrorNoticeRequest)(nil),              // 39: libplugin.PipeCreateErrorNoticeRequest
-	(*PipeCreateErrorNoticeResponse)(nil),             // 40: libplugin.PipeCreateErrorNoticeResponse
-	nil,                                               // 41: libplugin.ConnMeta.MetadataEntry
-	nil,                                               // 42: libplugin.Upstream.EnvEntry
-	nil,                                               // 43: libplugin.UpstreamNextPluginAuth.MetaEntry
-	nil,                                               // 44: libplugin.UpstreamRetryCurrentPluginAuth.MetaEntry
-	(*KeyboardInteractivePromptRequest_Question)(nil), // 45: libplugin.KeyboardInteractivePromptRequest.Question
+	(ChannelDecision)(0),                              // 1: libplugin.ChannelDecision
+	(*ConnMeta)(nil),                                  // 2: libplugin.ConnMeta
+	(*Upstream)(nil),                                  // 3: libplugin.Upstream
+	(*SessionPolicy)(nil),                             // 4: libplugin.SessionPolicy
+	(*UpstreamNoneAuth)(nil),                          // 5: libplugin.UpstreamNoneAuth
+	(*UpstreamPasswordAuth)(nil),                      // 6: libplugin.UpstreamPasswordAuth
+	(*UpstreamPrivateKeyAuth)(nil),                    // 7: libplugin.UpstreamPrivateKeyAuth
+	(*UpstreamRemoteSignerAuth)(nil),                  // 8: libplugin.UpstreamRemoteSignerAuth
+	(*UpstreamNextPluginAuth)(nil),                    // 9: libplugin.UpstreamNextPluginAuth
+	(*UpstreamRetryCurrentPluginAuth)(nil),            // 10: libplugin.UpstreamRetryCurrentPluginAuth
+	(*StartLogRequest)(nil),                           // 11: libplugin.StartLogRequest
+	(*Log)(nil),                                       // 12: libplugin.Log
+	(*HandshakeRequest)(nil),                          // 13: libplugin.HandshakeRequest
+	(*HandshakeResponse)(nil),                         // 14: libplugin.HandshakeResponse
+	(*ListCallbackRequest)(nil),                       // 15: libplugin.ListCallbackRequest
+	(*ListCallbackResponse)(nil),                      // 16: libplugin.ListCallbackResponse
+	(*PingRequest)(nil),                               // 17: libplugin.PingRequest
+	(*PingResponse)(nil),                              // 18: libplugin.PingResponse
+	(*NewConnectionRequest)(nil),                      // 19: libplugin.NewConnectionRequest
+	(*NewConnectionResponse)(nil),                     // 20: libplugin.NewConnectionResponse
+	(*NextAuthMethodsRequest)(nil),                    // 21: libplugin.NextAuthMethodsRequest
+	(*NextAuthMethodsResponse)(nil),                   // 22: libplugin.NextAuthMethodsResponse
+	(*NoneAuthRequest)(nil),                           // 23: libplugin.NoneAuthRequest
+	(*NoneAuthResponse)(nil),                          // 24: libplugin.NoneAuthResponse
+	(*PasswordAuthRequest)(nil),                       // 25: libplugin.PasswordAuthRequest
+	(*PasswordAuthResponse)(nil),                      // 26: libplugin.PasswordAuthResponse
+	(*PublicKeyAuthRequest)(nil),                      // 27: libplugin.PublicKeyAuthRequest
+	(*PublicKeyAuthResponse)(nil),                     // 28: libplugin.PublicKeyAuthResponse
+	(*KeyboardInteractiveUserResponse)(nil),           // 29: libplugin.KeyboardInteractiveUserResponse
+	(*KeyboardInteractivePromptRequest)(nil),          // 30: libplugin.KeyboardInteractivePromptRequest
+	(*KeyboardInteractiveMetaRequest)(nil),            // 31: libplugin.KeyboardInteractiveMetaRequest
+	(*KeyboardInteractiveMetaResponse)(nil),           // 32: libplugin.KeyboardInteractiveMetaResponse
+	(*KeyboardInteractiveFinishRequest)(nil),          // 33: libplugin.KeyboardInteractiveFinishRequest
+	(*KeyboardInteractiveAuthMessage)(nil),            // 34: libplugin.KeyboardInteractiveAuthMessage
+	(*UpstreamAuthFailureNoticeRequest)(nil),          // 35: libplugin.UpstreamAuthFailureNoticeRequest
+	(*UpstreamAuthFailureNoticeResponse)(nil),         // 36: libplugin.UpstreamAuthFailureNoticeResponse
+	(*BannerRequest)(nil),                             // 37: libplugin.BannerRequest
+	(*BannerResponse)(nil),                            // 38: libplugin.BannerResponse
+	(*VerifyHostKeyRequest)(nil),                      // 39: libplugin.VerifyHostKeyRequest
+	(*VerifyHostKeyResponse)(nil),                     // 40: libplugin.VerifyHostKeyResponse
+	(*PipeStartNoticeRequest)(nil),                    // 41: libplugin.PipeStartNoticeRequest
+	(*PipeStartNoticeResponse)(nil),                   // 42: libplugin.PipeStartNoticeResponse
+	(*PipeErrorNoticeRequest)(nil),                    // 43: libplugin.PipeErrorNoticeRequest
+	(*PipeErrorNoticeResponse)(nil),                   // 44: libplugin.PipeErrorNoticeResponse
+	(*PipeStats)(nil),                                 // 45: libplugin.PipeStats
+	(*PipeEndNoticeRequest)(nil),                      // 46: libplugin.PipeEndNoticeRequest
+	(*PipeEndNoticeResponse)(nil),                     // 47: libplugin.PipeEndNoticeResponse
+	(*AuthorizeChannelRequest)(nil),                   // 48: libplugin.AuthorizeChannelRequest
+	(*AuthorizeChannelResponse)(nil),                  // 49: libplugin.AuthorizeChannelResponse
+	(*PipeCreateErrorNoticeRequest)(nil),              // 50: libplugin.PipeCreateErrorNoticeRequest
+	(*PipeCreateErrorNoticeResponse)(nil),             // 51: libplugin.PipeCreateErrorNoticeResponse
+	nil,                                               // 52: libplugin.ConnMeta.MetadataEntry
+	nil,                                               // 53: libplugin.Upstream.EnvEntry
+	nil,                                               // 54: libplugin.Upstream.LabelsEntry
+	nil,                                               // 55: libplugin.UpstreamNextPluginAuth.MetaEntry
+	nil,                                               // 56: libplugin.UpstreamRetryCurrentPluginAuth.MetaEntry
+	(*KeyboardInteractivePromptRequest_Question)(nil), // 57: libplugin.KeyboardInteractivePromptRequest.Question
+	nil, // 58: libplugin.PipeStats.ChannelsEntry
 }
 var file_plugin_proto_depIdxs = []int32{
-	41, // 0: libplugin.ConnMeta.metadata:type_name -> libplugin.ConnMeta.MetadataEntry
-	42, // 1: libplugin.Upstream.env:type_name -> libplugin.Upstream.EnvEntry
-	3,  // 2: libplugin.Upstream.none:type_name -> libplugin.UpstreamNoneAuth
-	4,  // 3: libplugin.Upstream.password:type_name -> libplugin.UpstreamPasswordAuth
-	5,  // 4: libplugin.Upstream.private_key:type_name -> libplugin.UpstreamPrivateKeyAuth
-	6,  // 5: libplugin.Upstream.remote_signer:type_name -> libplugin.UpstreamRemoteSignerAuth
-	7,  // 6: libplugin.Upstream.next_plugin:type_name -> libplugin.UpstreamNextPluginAuth
-	8,  // 7: libplugin.Upstream.retry_current_plugin:type_name -> libplugin.UpstreamRetryCurrentPluginAuth
-	43, // 8: libplugin.UpstreamNextPluginAuth.meta:type_name -> libplugin.UpstreamNextPluginAuth.MetaEntry
-	44, // 9: libplugin.UpstreamRetryCurrentPluginAuth.meta:type_name -> libplugin.UpstreamRetryCurrentPluginAuth.MetaEntry
-	1,  // 10: libplugin.NewConnectionRequest.meta:type_name -> libplugin.ConnMeta
-	1,  // 11: libplugin.NextAuthMethodsRequest.meta:type_name -> libplugin.ConnMeta
-	0,  // 12: libplugin.NextAuthMethodsResponse.methods:type_name -> libplugin.AuthMethod
-	1,  // 13: libplugin.NoneAuthRequest.meta:type_name -> libplugin.ConnMeta
-	2,  // 14: libplugin.NoneAuthResponse.upstream:type_name -> libplugin.Upstream
-	1,  // 15: libplugin.PasswordAuthRequest.meta:type_name -> libplugin.ConnMeta
-	2,  // 16: libplugin.PasswordAuthResponse.upstream:type_name -> libplugin.Upstream
-	1,  // 17: libplugin.PublicKeyAuthRequest.meta:type_name -> libplugin.ConnMeta
-	2,  // 18: libplugin.PublicKeyAuthResponse.upstream:type_name -> libplugin.Upstream
-	45, // 19: libplugin.KeyboardInteractivePromptRequest.questions:type_name -> libplugin.KeyboardInteractivePromptRequest.Question
-	1,  // 20: libplugin.KeyboardInteractiveMetaResponse.meta:type_name -> libplugin.ConnMeta
-	2,  // 21: libplugin.KeyboardInteractiveFinishRequest.upstream:type_name -> libplugin.Upstream
-	24, // 22: libplugin.KeyboardInteractiveAuthMessage.prompt_request:type_name -> libplugin.KeyboardInteractivePromptRequest
-	23, // 23: libplugin.KeyboardInteractiveAuthMessage.user_response:type_name -> libplugin.KeyboardInteractiveUserResponse
-	25, // 24: libplugin.KeyboardInteractiveAuthMessage.meta_request:type_name -> libplugin.KeyboardInteractiveMetaRequest
-	26, // 25: libplugin.KeyboardInteractiveAuthMessage.meta_response:type_name -> libplugin.KeyboardInteractiveMetaResponse
-	27, // 26: libplugin.KeyboardInteractiveAuthMessage.finish_request:type_name -> libplugin.KeyboardInteractiveFinishRequest
-	1,  // 27: libplugin.UpstreamAuthFailureNoticeRequest.meta:type_name -> libplugin.ConnMeta
-	0,  // 28: libplugin.UpstreamAuthFailureNoticeRequest.allowed_methods:type_name -> libplugin.AuthMethod
-	1,  // 29: libplugin.BannerRequest.meta:type_name -> libplugin.ConnMeta
-	1,  // 30: libplugin.VerifyHostKeyRequest.meta:type_name -> libplugin.ConnMeta
-	1,  // 31: libplugin.PipeStartNoticeRequest.meta:type_name -> libplugin.ConnMeta
-	1,  // 32: libplugin.PipeErrorNoticeRequest.meta:type_name -> libplugin.ConnMeta
-	9,  // 33: libplugin.SshPiperPlugin.Logs:input_type -> libplugin.StartLogRequest
-	11, // 34: libplugin.SshPiperPlugin.ListCallbacks:input_type -> libplugin.ListCallbackRequest
-	13, // 35: libplugin.SshPiperPlugin.NewConnection:input_type -> libplugin.NewConnectionRequest
-	15, // 36: libplugin.SshPiperPlugin.NextAuthMethods:input_type -> libplugin.NextAuthMethodsRequest
-	17, // 37: libplugin.SshPiperPlugin.NoneAuth:input_type -> libplugin.NoneAuthRequest
-	19, // 38: libplugin.SshPiperPlugin.PasswordAuth:input_type -> libplugin.PasswordAuthRequest
-	21, // 39: libplugin.SshPiperPlugin.PublicKeyAuth:input_type -> libplugin.PublicKeyAuthRequest
-	28, // 40: libplugin.SshPiperPlugin.KeyboardInteractiveAuth:input_type -> libplugin.KeyboardInteractiveAuthMessage
-	29, // 41: libplugin.SshPiperPlugin.UpstreamAuthFailureNotice:input_type -> libplugin.UpstreamAuthFailureNoticeRequest
-	31, // 42: libplugin.SshPiperPlugin.Banner:input_type -> libplugin.BannerRequest
-	33, // 43: libplugin.SshPiperPlugin.VerifyHostKey:input_type -> libplugin.VerifyHostKeyRequest
-	39, // 44: libplugin.SshPiperPlugin.PipeCreateErrorNotice:input_type -> libplugin.PipeCreateErrorNoticeRequest
-	35, // 45: libplugin.SshPiperPlugin.PipeStartNotice:input_type -> libplugin.PipeStartNoticeRequest
-	37, // 46: libplugin.SshPiperPlugin.PipeErrorNotice:input_type -> libplugin.PipeErrorNoticeRequest
-	10, // 47: libplugin.SshPiperPlugin.Logs:output_type -> libplugin.Log
-	12, // 48: libplugin.SshPiperPlugin.ListCallbacks:output_type -> libplugin.ListCallbackResponse
-	14, // 49: libplugin.SshPiperPlugin.NewConnection:output_type -> libplugin.NewConnectionResponse
-	16, // 50: libplugin.SshPiperPlugin.NextAuthMethods:output_type -> libplugin.NextAuthMethodsResponse
-	18, // 51: libplugin.SshPiperPlugin.NoneAuth:output_type -> libplugin.NoneAuthResponse
-	20, // 52: libplugin.SshPiperPlugin.PasswordAuth:output_type -> libplugin.PasswordAuthResponse
-	22, // 53: libplugin.SshPiperPlugin.PublicKeyAuth:output_type -> libplugin.PublicKeyAuthResponse
-	28, // 54: libplugin.SshPiperPlugin.KeyboardInteractiveAuth:output_type -> libplugin.KeyboardInteractiveAuthMessage
-	30, // 55: libplugin.SshPiperPlugin.UpstreamAuthFailureNotice:output_type -> libplugin.UpstreamAuthFailureNoticeResponse
-	32, // 56: libplugin.SshPiperPlugin.Banner:output_type -> libplugin.BannerResponse
-	34, // 57: libplugin.SshPiperPlugin.VerifyHostKey:output_type -> libplugin.VerifyHostKeyResponse
-	40, // 58: libplugin.SshPiperPlugin.PipeCreateErrorNotice:output_type -> libplugin.PipeCreateErrorNoticeResponse
-	36, // 59: libplugin.SshPiperPlugin.PipeStartNotice:output_type -> libplugin.PipeStartNoticeResponse
-	38, // 60: libplugin.SshPiperPlugin.PipeErrorNotice:output_type -> libplugin.PipeErrorNoticeResponse
-	47, // [47:61] is the sub-list for method output_type
-	33, // [33:47] is the sub-list for method input_type
-	33, // [33:33] is the sub-list for extension type_name
-	33, // [33:33] is the sub-list for extension extendee
-	0,  // [0:33] is the sub-list for field type_name
+	52, // 0: libplugin.ConnMeta.metadata:type_name -> libplugin.ConnMeta.MetadataEntry
+	53, // 1: libplugin.Upstream.env:type_name -> libplugin.Upstream.EnvEntry
+	4,  // 2: libplugin.Upstream.session_policy:type_name -> libplugin.SessionPolicy
+	54, // 3: libplugin.Upstream.labels:type_name -> libplugin.Upstream.LabelsEntry
+	5,  // 4: libplugin.Upstream.none:type_name -> libplugin.UpstreamNoneAuth
+	6,  // 5: libplugin.Upstream.password:type_name -> libplugin.UpstreamPasswordAuth
+	7,  // 6: libplugin.Upstream.private_key:type_name -> libplugin.UpstreamPrivateKeyAuth
+	8,  // 7: libplugin.Upstream.remote_signer:type_name -> libplugin.UpstreamRemoteSignerAuth
+	9,  // 8: libplugin.Upstream.next_plugin:type_name -> libplugin.UpstreamNextPluginAuth
+	10, // 9: libplugin.Upstream.retry_current_plugin:type_name -> libplugin.UpstreamRetryCurrentPluginAuth
+	55, // 10: libplugin.UpstreamNextPluginAuth.meta:type_name -> libplugin.UpstreamNextPluginAuth.MetaEntry
+	56, // 11: libplugin.UpstreamRetryCurrentPluginAuth.meta:type_name -> libplugin.UpstreamRetryCurrentPluginAuth.MetaEntry
+	2,  // 12: libplugin.NewConnectionRequest.meta:type_name -> libplugin.ConnMeta
+	2,  // 13: libplugin.NextAuthMethodsRequest.meta:type_name -> libplugin.ConnMeta
+	0,  // 14: libplugin.NextAuthMethodsResponse.methods:type_name -> libplugin.AuthMethod
+	2,  // 15: libplugin.NoneAuthRequest.meta:type_name -> libplugin.ConnMeta
+	3,  // 16: libplugin.NoneAuthResponse.upstream:type_name -> libplugin.Upstream
+	2,  // 17: libplugin.PasswordAuthRequest.meta:type_name -> libplugin.ConnMeta
+	3,  // 18: libplugin.PasswordAuthResponse.upstream:type_name -> libplugin.Upstream
+	2,  // 19: libplugin.PublicKeyAuthRequest.meta:type_name -> libplugin.ConnMeta
+	3,  // 20: libplugin.PublicKeyAuthResponse.upstream:type_name -> libplugin.Upstream
+	57, // 21: libplugin.KeyboardInteractivePromptRequest.questions:type_name -> libplugin.KeyboardInteractivePromptRequest.Question
+	2,  // 22: libplugin.KeyboardInteractiveMetaResponse.meta:type_name -> libplugin.ConnMeta
+	3,  // 23: libplugin.KeyboardInteractiveFinishRequest.upstream:type_name -> libplugin.Upstream
+	30, // 24: libplugin.KeyboardInteractiveAuthMessage.prompt_request:type_name -> libplugin.KeyboardInteractivePromptRequest
+	29, // 25: libplugin.KeyboardInteractiveAuthMessage.user_response:type_name -> libplugin.KeyboardInteractiveUserResponse
+	31, // 26: libplugin.KeyboardInteractiveAuthMessage.meta_request:type_name -> libplugin.KeyboardInteractiveMetaRequest
+	32, // 27: libplugin.KeyboardInteractiveAuthMessage.meta_response:type_name -> libplugin.KeyboardInteractiveMetaResponse
+	33, // 28: libplugin.KeyboardInteractiveAuthMessage.finish_request:type_name -> libplugin.KeyboardInteractiveFinishRequest
+	2,  // 29: libplugin.UpstreamAuthFailureNoticeRequest.meta:type_name -> libplugin.ConnMeta
+	0,  // 30: libplugin.UpstreamAuthFailureNoticeRequest.allowed_methods:type_name -> libplugin.AuthMethod
+	2,  // 31: libplugin.BannerRequest.meta:type_name -> libplugin.ConnMeta
+	2,  // 32: libplugin.VerifyHostKeyRequest.meta:type_name -> libplugin.ConnMeta
+	2,  // 33: libplugin.PipeStartNoticeRequest.meta:type_name -> libplugin.ConnMeta
+	2,  // 34: libplugin.PipeErrorNoticeRequest.meta:type_name -> libplugin.ConnMeta
+	58, // 35: libplugin.PipeStats.channels:type_name -> libplugin.PipeStats.ChannelsEntry
+	2,  // 36: libplugin.PipeEndNoticeRequest.meta:type_name -> libplugin.ConnMeta
+	45, // 37: libplugin.PipeEndNoticeRequest.stats:type_name -> libplugin.PipeStats
+	2,  // 38: libplugin.AuthorizeChannelRequest.meta:type_name -> libplugin.ConnMeta
+	1,  // 39: libplugin.AuthorizeChannelResponse.decision:type_name -> libplugin.ChannelDecision
+	11, // 40: libplugin.SshPiperPlugin.Logs:input_type -> libplugin.StartLogRequest
+	13, // 41: libplugin.SshPiperPlugin.Handshake:input_type -> libplugin.HandshakeRequest
+	15, // 42: libplugin.SshPiperPlugin.ListCallbacks:input_type -> libplugin.ListCallbackRequest
+	17, // 43: libplugin.SshPiperPlugin.Ping:input_type -> libplugin.PingRequest
+	19, // 44: libplugin.SshPiperPlugin.NewConnection:input_type -> libplugin.NewConnectionRequest
+	21, // 45: libplugin.SshPiperPlugin.NextAuthMethods:input_type -> libplugin.NextAuthMethodsRequest
+	23, // 46: libplugin.SshPiperPlugin.NoneAuth:input_type -> libplugin.NoneAuthRequest
+	25, // 47: libplugin.SshPiperPlugin.PasswordAuth:input_type -> libplugin.PasswordAuthRequest
+	27, // 48: libplugin.SshPiperPlugin.PublicKeyAuth:input_type -> libplugin.PublicKeyAuthRequest
+	34, // 49: libplugin.SshPiperPlugin.KeyboardInteractiveAuth:input_type -> libplugin.KeyboardInteractiveAuthMessage
+	35, // 50: libplugin.SshPiperPlugin.UpstreamAuthFailureNotice:input_type -> libplugin.UpstreamAuthFailureNoticeRequest
+	37, // 51: libplugin.SshPiperPlugin.Banner:input_type -> libplugin.BannerRequest
+	39, // 52: libplugin.SshPiperPlugin.VerifyHostKey:input_type -> libplugin.VerifyHostKeyRequest
+	50, // 53: libplugin.SshPiperPlugin.PipeCreateErrorNotice:input_type -> libplugin.PipeCreateErrorNoticeRequest
+	41, // 54: libplugin.SshPiperPlugin.PipeStartNotice:input_type -> libplugin.PipeStartNoticeRequest
+	43, // 55: libplugin.SshPiperPlugin.PipeErrorNotice:input_type -> libplugin.PipeErrorNoticeRequest
+	46, // 56: libplugin.SshPiperPlugin.PipeEndNotice:input_type -> libplugin.PipeEndNoticeRequest
+	48, // 57: libplugin.SshPiperPlugin.AuthorizeChannel:input_type -> libplugin.AuthorizeChannelRequest
+	12, // 58: libplugin.SshPiperPlugin.Logs:output_type -> libplugin.Log
+	14, // 59: libplugin.SshPiperPlugin.Handshake:output_type -> libplugin.HandshakeResponse
+	16, // 60: libplugin.SshPiperPlugin.ListCallbacks:output_type -> libplugin.ListCallbackResponse
+	18, // 61: libplugin.SshPiperPlugin.Ping:output_type -> libplugin.PingResponse
+	20, // 62: libplugin.SshPiperPlugin.NewConnection:output_type -> libplugin.NewConnectionResponse
+	22, // 63: libplugin.SshPiperPlugin.NextAuthMethods:output_type -> libplugin.NextAuthMethodsResponse
+	24, // 64: libplugin.SshPiperPlugin.NoneAuth:output_type -> libplugin.NoneAuthResponse
+	26, // 65: libplugin.SshPiperPlugin.PasswordAuth:output_type -> libplugin.PasswordAuthResponse
+	28, // 66: libplugin.SshPiperPlugin.PublicKeyAuth:output_type -> libplugin.PublicKeyAuthResponse
+	34, // 67: libplugin.SshPiperPlugin.KeyboardInteractiveAuth:output_type -> libplugin.KeyboardInteractiveAuthMessage
+	36, // 68: libplugin.SshPiperPlugin.UpstreamAuthFailureNotice:output_type -> libplugin.UpstreamAuthFailureNoticeResponse
+	38, // 69: libplugin.SshPiperPlugin.Banner:output_type -> libplugin.BannerResponse
+	40, // 70: libplugin.SshPiperPlugin.VerifyHostKey:output_type -> libplugin.VerifyHostKeyResponse
+	51, // 71: libplugin.SshPiperPlugin.PipeCreateErrorNotice:output_type -> libplugin.PipeCreateErrorNoticeResponse
+	42, // 72: libplugin.SshPiperPlugin.PipeStartNotice:output_type -> libplugin.PipeStartNoticeResponse
+	44, // 73: libplugin.SshPiperPlugin.PipeErrorNotice:output_type -> libplugin.PipeErrorNoticeResponse
+	47, // 74: libplugin.SshPiperPlugin.PipeEndNotice:output_type -> libplugin.PipeEndNoticeResponse
+	49, // 75: libplugin.SshPiperPlugin.AuthorizeChannel:output_type -> libplugin.AuthorizeChannelResponse
+	58, // [58:76] is the sub-list for method output_type
+	40, // [40:58] is the sub-list for method input_type
+	40, // [40:40] is the sub-list for extension type_name
+	40, // [40:40] is the sub-list for extension extendee
+	0,  // [0:40] is the sub-list for field type_name
 }
 
 func init() { file_plugin_proto_init() }
@@ -2532,7 +3485,8 @@ func file_plugin_proto_init() {
 		(*Upstream_NextPlugin)(nil),
 		(*Upstream_RetryCurrentPlugin)(nil),
 	}
-	file_plugin_proto_msgTypes[27].OneofWrappers = []any{
+	file_plugin_proto_msgTypes[2].OneofWrappers = []any{}
+	file_plugin_proto_msgTypes[32].OneofWrappers = []any{
 		(*KeyboardInteractiveAuthMessage_PromptRequest)(nil),
 		(*KeyboardInteractiveAuthMessage_UserResponse)(nil),
 		(*KeyboardInteractiveAuthMessage_MetaRequest)(nil),
@@ -2544,8 +3498,8 @@ func file_plugin_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_plugin_proto_rawDesc), len(file_plugin_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   45,
+			NumEnums:      2,
+			NumMessages:   57,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -6,6 +6,10 @@ import (
 	"strconv"
 )
 
+// LabelMetaPrefix marks the entries of NextPluginAuth.meta that are labels
+// of the session, e.g. "label.ticket", see Upstream.labels.
+const LabelMetaPrefix = "label."
+
 // GetOrGenerateUri returns the existing Uri if set, otherwise constructs it from Host and Port.
 func (x *Upstream) GetOrGenerateUri() string {
 	uri := x.GetUri()
@@ -20,7 +20,9 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	SshPiperPlugin_Logs_FullMethodName                      = "/libplugin.SshPiperPlugin/Logs"
+	SshPiperPlugin_Handshake_FullMethodName                 = "/libplugin.SshPiperPlugin/Handshake"
 	SshPiperPlugin_ListCallbacks_FullMethodName             = "/libplugin.SshPiperPlugin/ListCallbacks"
+	SshPiperPlugin_Ping_FullMethodName                      = "/libplugin.SshPiperPlugin/Ping"
 	SshPiperPlugin_NewConnection_FullMethodName             = "/libplugin.SshPiperPlugin/NewConnection"
 	SshPiperPlugin_NextAuthMethods_FullMethodName           = "/libplugin.SshPiperPlugin/NextAuthMethods"
 	SshPiperPlugin_NoneAuth_FullMethodName                  = "/libplugin.SshPiperPlugin/NoneAuth"
@@ -33,6 +35,8 @@ const (
 	SshPiperPlugin_PipeCreateErrorNotice_FullMethodName     = "/libplugin.SshPiperPlugin/PipeCreateErrorNotice"
 	SshPiperPlugin_PipeStartNotice_FullMethodName           = "/libplugin.SshPiperPlugin/PipeStartNotice"
 	SshPiperPlugin_PipeErrorNotice_FullMethodName           = "/libplugin.SshPiperPlugin/PipeErrorNotice"
+	SshPiperPlugin_PipeEndNotice_FullMethodName             = "/libplugin.SshPiperPlugin/PipeEndNotice"
+	SshPiperPlugin_AuthorizeChannel_FullMethodName          = "/libplugin.SshPiperPlugin/AuthorizeChannel"
 )
 
 // SshPiperPluginClient is the client API for SshPiperPlugin service.
@@ -40,7 +44,9 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type SshPiperPluginClient interface {
 	Logs(ctx context.Context, in *StartLogRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Log], error)
+	Handshake(ctx context.Context, in *HandshakeRequest, opts ...grpc.CallOption) (*HandshakeResponse, error)
 	ListCallbacks(ctx context.Context, in *ListCallbackRequest, opts ...grpc.CallOption) (*ListCallbackResponse, error)
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 	NewConnection(ctx context.Context, in *NewConnectionRequest, opts ...grpc.CallOption) (*NewConnectionResponse, error)
 	NextAuthMethods(ctx context.Context, in *NextAuthMethodsRequest, opts ...grpc.CallOption) (*NextAuthMethodsResponse, error)
 	NoneAuth(ctx context.Context, in *NoneAuthRequest, opts ...grpc.CallOption) (*NoneAuthResponse, error)
@@ -53,6 +59,8 @@ type SshPiperPluginClient interface {
 	PipeCreateErrorNotice(ctx context.Context, in *PipeCreateErrorNoticeRequest, opts ...grpc.CallOption) (*PipeCreateErrorNoticeResponse, error)
 	PipeStartNotice(ctx context.Context, in *PipeStartNoticeRequest, opts ...grpc.CallOption) (*PipeStartNoticeResponse, error)
 	PipeErrorNotice(ctx context.Context, in *PipeErrorNoticeRequest, opts ...grpc.CallOption) (*PipeErrorNoticeResponse, error)
+	PipeEndNotice(ctx context.Context, in *PipeEndNoticeRequest, opts ...grpc.CallOption) (*PipeEndNoticeResponse, error)
+	AuthorizeChannel(ctx context.Context, in *AuthorizeChannelRequest, opts ...grpc.CallOption) (*AuthorizeChannelResponse, error)
 }
 
 type sshPiperPluginClient struct {
@@ -82,6 +90,16 @@ func (c *sshPiperPluginClient) Logs(ctx context.Context, in *StartLogRequest, op
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type SshPiperPlugin_LogsClient = grpc.ServerStreamingClient[Log]
 
+func (c *sshPiperPluginClient) Handshake(ctx context.Context, in *HandshakeRequest, opts ...grpc.CallOption) (*HandshakeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HandshakeResponse)
+	err := c.cc.Invoke(ctx, SshPiperPlugin_Handshake_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *sshPiperPluginClient) ListCallbacks(ctx context.Context, in *ListCallbackRequest, opts ...grpc.CallOption) (*ListCallbackResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ListCallbackResponse)
@@ -92,6 +110,16 @@ func (c *sshPiperPluginClient) ListCallbacks(ctx context.Context, in *ListCallba
 	return out, nil
 }
 
+func (c *sshPiperPluginClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PingResponse)
+	err := c.cc.Invoke(ctx, SshPiperPlugin_Ping_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *sshPiperPluginClient) NewConnection(ctx context.Context, in *NewConnectionRequest, opts ...grpc.CallOption) (*NewConnectionResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(NewConnectionResponse)
@@ -215,12 +243,34 @@ func (c *sshPiperPluginClient) PipeErrorNotice(ctx context.Context, in *PipeErro
 	return out, nil
 }
 
+func (c *sshPiperPluginClient) PipeEndNotice(ctx context.Context, in *PipeEndNoticeRequest, opts ...grpc.CallOption) (*PipeEndNoticeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PipeEndNoticeResponse)
+	err := c.cc.Invoke(ctx, SshPiperPlugin_PipeEndNotice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sshPiperPluginClient) AuthorizeChannel(ctx context.Context, in *AuthorizeChannelRequest, opts ...grpc.CallOption) (*AuthorizeChannelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthorizeChannelResponse)
+	err := c.cc.Invoke(ctx, SshPiperPlugin_AuthorizeChannel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SshPiperPluginServer is the server API for SshPiperPlugin service.
 // All implementations must embed UnimplementedSshPiperPluginServer
 // for forward compatibility.
 type SshPiperPluginServer interface {
 	Logs(*StartLogRequest, grpc.ServerStreamingServer[Log]) error
+	Handshake(context.Context, *HandshakeRequest) (*HandshakeResponse, error)
 	ListCallbacks(context.Context, *ListCallbackRequest) (*ListCallbackResponse, error)
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	NewConnection(context.Context, *NewConnectionRequest) (*NewConnectionResponse, error)
 	NextAuthMethods(context.Context, *NextAuthMethodsRequest) (*NextAuthMethodsResponse, error)
 	NoneAuth(context.Context, *NoneAuthRequest) (*NoneAuthResponse, error)
@@ -233,6 +283,8 @@ type SshPiperPluginServer interface {
 	PipeCreateErrorNotice(context.Context, *PipeCreateErrorNoticeRequest) (*PipeCreateErrorNoticeResponse, error)
 	PipeStartNotice(context.Context, *PipeStartNoticeRequest) (*PipeStartNoticeResponse, error)
 	PipeErrorNotice(context.Context, *PipeErrorNoticeRequest) (*PipeErrorNoticeResponse, error)
+	PipeEndNotice(context.Context, *PipeEndNoticeRequest) (*PipeEndNoticeResponse, error)
+	AuthorizeChannel(context.Context, *AuthorizeChannelRequest) (*AuthorizeChannelResponse, error)
 	mustEmbedUnimplementedSshPiperPluginServer()
 }
 
@@ -246,9 +298,15 @@ type UnimplementedSshPiperPluginServer struct{}
 func (UnimplementedSshPiperPluginServer) Logs(*StartLogRequest, grpc.ServerStreamingServer[Log]) error {
 	return status.Error(codes.Unimplemented, "method Logs not implemented")
 }
+func (UnimplementedSshPiperPluginServer) Handshake(context.Context, *HandshakeRequest) (*HandshakeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Handshake not implemented")
+}
 func (UnimplementedSshPiperPluginServer) ListCallbacks(context.Context, *ListCallbackRequest) (*ListCallbackResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ListCallbacks not implemented")
 }
+func (UnimplementedSshPiperPluginServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Ping not implemented")
+}
 func (UnimplementedSshPiperPluginServer) NewConnection(context.Context, *NewConnectionRequest) (*NewConnectionResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method NewConnection not implemented")
 }
@@ -285,6 +343,12 @@ func (UnimplementedSshPiperPluginServer) PipeStartNotice(context.Context, *PipeS
 func (UnimplementedSshPiperPluginServer) PipeErrorNotice(context.Context, *PipeErrorNoticeRequest) (*PipeErrorNoticeResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method PipeErrorNotice not implemented")
 }
+func (UnimplementedSshPiperPluginServer) PipeEndNotice(context.Context, *PipeEndNoticeRequest) (*PipeEndNoticeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method PipeEndNotice not implemented")
+}
+func (UnimplementedSshPiperPluginServer) AuthorizeChannel(context.Context, *AuthorizeChannelRequest) (*AuthorizeChannelResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method AuthorizeChannel not implemented")
+}
 func (UnimplementedSshPiperPluginServer) mustEmbedUnimplementedSshPiperPluginServer() {}
 func (UnimplementedSshPiperPluginServer) testEmbeddedByValue()                        {}
 
@@ -317,6 +381,24 @@ func _SshPiperPlugin_Logs_Handler(srv interface{}, stream grpc.ServerStream) err
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type SshPiperPlugin_LogsServer = grpc.ServerStreamingServer[Log]
 
+func _SshPiperPlugin_Handshake_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HandshakeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperPluginServer).Handshake(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperPlugin_Handshake_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperPluginServer).Handshake(ctx, req.(*HandshakeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SshPiperPlugin_ListCallbacks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListCallbackRequest)
 	if err := dec(in); err != nil {
@@ -335,6 +417,24 @@ func _SshPiperPlugin_ListCallbacks_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SshPiperPlugin_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperPluginServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperPlugin_Ping_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperPluginServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SshPiperPlugin_NewConnection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(NewConnectionRequest)
 	if err := dec(in); err != nil {
@@ -540,6 +640,42 @@ func _SshPiperPlugin_PipeErrorNotice_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SshPiperPlugin_PipeEndNotice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PipeEndNoticeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperPluginServer).PipeEndNotice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperPlugin_PipeEndNotice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperPluginServer).PipeEndNotice(ctx, req.(*PipeEndNoticeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SshPiperPlugin_AuthorizeChannel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuthorizeChannelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SshPiperPluginServer).AuthorizeChannel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SshPiperPlugin_AuthorizeChannel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SshPiperPluginServer).AuthorizeChannel(ctx, req.(*AuthorizeChannelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SshPiperPlugin_ServiceDesc is the grpc.ServiceDesc for SshPiperPlugin service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -547,10 +683,18 @@ var SshPiperPlugin_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "libplugin.SshPiperPlugin",
 	HandlerType: (*SshPiperPluginServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Handshake",
+			Handler:    _SshPiperPlugin_Handshake_Handler,
+		},
 		{
 			MethodName: "ListCallbacks",
 			Handler:    _SshPiperPlugin_ListCallbacks_Handler,
 		},
+		{
+			MethodName: "Ping",
+			Handler:    _SshPiperPlugin_Ping_Handler,
+		},
 		{
 			MethodName: "NewConnection",
 			Handler:    _SshPiperPlugin_NewConnection_Handler,
@@ -595,6 +739,14 @@ var SshPiperPlugin_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "PipeErrorNotice",
 			Handler:    _SshPiperPlugin_PipeErrorNotice_Handler,
 		},
+		{
+			MethodName: "PipeEndNotice",
+			Handler:    _SshPiperPlugin_PipeEndNotice_Handler,
+		},
+		{
+			MethodName: "AuthorizeChannel",
+			Handler:    _SshPiperPlugin_AuthorizeChannel_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -7,6 +7,9 @@ import (
 	"io"
 	"net"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/tg123/remotesigner/grpcsigner"
 	"github.com/tg123/sshpiper/libplugin/connovergrpc"
@@ -42,9 +45,28 @@ func (c *ConnMeta) GetMeta(key string) string {
 	return c.Metadata[key]
 }
 
+// traceShutdownTimeout bounds flushing the spans when the plugin stops.
+const traceShutdownTimeout = 5 * time.Second
+
 type KeyboardInteractiveChallenge func(user, instruction string, question string, echo bool) (answer string, err error)
 
 type SshPiperPluginConfig struct {
+	// Name and Version identify the plugin to sshpiperd in the Handshake.
+	// Name defaults to the executable name, Version to the module version
+	// of the build.
+	Name    string
+	Version string
+
+	// RequiredFeatures are the optional features of the plugin protocol,
+	// e.g. FeatureUpstreamEnv, the plugin cannot work without. sshpiperd
+	// refuses to load the plugin when it lacks one of them.
+	RequiredFeatures []string
+
+	// HandshakeCallback is called with what sshpiperd sent in the
+	// Handshake. Returning an error refuses the daemon, sshpiperd then
+	// fails to load the plugin with the error.
+	HandshakeCallback func(daemon DaemonInfo) error
+
 	NewConnectionCallback func(conn ConnMetadata) error
 
 	NextAuthMethodsCallback func(conn ConnMetadata) ([]string, error)
@@ -69,6 +91,18 @@ type SshPiperPluginConfig struct {
 
 	PipeErrorCallback func(conn ConnMetadata, err error)
 
+	// PipeEndCallback is called with the statistics of every pipe once it
+	// is closed, after PipeErrorCallback. It is only called by sshpiperd
+	// announcing FeaturePipeEnd, older ones ignore it.
+	PipeEndCallback func(conn ConnMetadata, stats *PipeStats)
+
+	// AuthorizeChannelCallback is asked about every channel the client
+	// opens and every shell, exec, subsystem, pty-req, env and remote
+	// forward request once the pipe is established. Returning an error
+	// denies the request. It is only called by sshpiperd announcing
+	// FeatureAuthorizeChannel, older ones ignore it.
+	AuthorizeChannelCallback func(conn ConnMetadata, req *AuthorizeChannelRequest) (*AuthorizeChannelResponse, error)
+
 	CreateConnCallback connovergrpc.CreateConnFunc
 
 	GrpcRemoteSignerFactory grpcsigner.SignerFactory
@@ -95,6 +129,10 @@ type SshPiperPlugin interface {
 	// the slog default to write to the supplied writer.
 	SetConfigLoggerCallback(cb ConfigLogger)
 
+	// Daemon returns what sshpiperd sent in its last Handshake, the zero
+	// value before the Handshake or with a sshpiperd built before it.
+	Daemon() DaemonInfo
+
 	// Serve blocks on the underlying gRPC listener until it is closed.
 	// Call it from main after constructing the plugin and configuring
 	// any callbacks. It returns the error reported by grpc.Server.Serve.
@@ -116,18 +154,31 @@ type SshPiperPlugin interface {
 // On error the original os.Stdout is left untouched and the gRPC
 // listener is closed.
 func NewFromStdio(config SshPiperPluginConfig) (SshPiperPlugin, error) {
+	name := config.Name
+	if name == "" {
+		name = filepath.Base(os.Args[0])
+	}
+
+	traceShutdown, err := SetupTracingFromEnv(name)
+	if err != nil {
+		return nil, err
+	}
+
 	stdout := os.Stdout
-	g := grpc.NewServer()
+	g := grpc.NewServer(TraceServerOptions()...)
 	l, err := ioconn.ListenFromSingleIO(os.Stdin, stdout)
 	if err != nil {
+		_ = traceShutdown(context.Background())
 		return nil, err
 	}
 
 	s, err := newFromGrpc(config, g, l)
 	if err != nil {
 		_ = l.Close()
+		_ = traceShutdown(context.Background())
 		return nil, err
 	}
+	s.traceShutdown = traceShutdown
 
 	os.Stdout = s.logwriter
 
@@ -142,7 +193,9 @@ func NewFromStdio(config SshPiperPluginConfig) (SshPiperPlugin, error) {
 //
 // Unlike NewFromStdio, this constructor performs no global side effects:
 // os.Stdout is left alone, and the caller owns the lifetimes of both
-// the grpc.Server and the listener.
+// the grpc.Server and the listener. Tracing is not set up either: create
+// the grpc.Server with TraceServerOptions and call SetupTracingFromEnv to
+// continue the traces of sshpiperd.
 func NewFromGrpc(config SshPiperPluginConfig, grpc *grpc.Server, listener net.Listener) (SshPiperPlugin, error) {
 	return newFromGrpc(config, grpc, listener)
 }
@@ -215,6 +268,13 @@ type server struct {
 	logs        chan string
 	logwriter   *os.File
 	logreader   *os.File
+
+	daemonMu sync.Mutex
+	daemon   DaemonInfo
+
+	// traceShutdown flushes the spans when Serve returns, set by
+	// NewFromStdio.
+	traceShutdown func(context.Context) error
 }
 
 func (s *server) GetGrpcServer() *grpc.Server {
@@ -232,6 +292,13 @@ func (s *server) Serve() error {
 	// returns on EOF), at which point the reader end can be released.
 	_ = s.logwriter.Close()
 	_ = s.logreader.Close()
+
+	if s.traceShutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), traceShutdownTimeout)
+		defer cancel()
+		_ = s.traceShutdown(ctx)
+	}
+
 	return err
 }
 
@@ -251,6 +318,11 @@ func (s *server) Logs(req *StartLogRequest, stream SshPiperPlugin_LogsServer) er
 	return nil
 }
 
+// Ping answers the readiness checks of sshpiperd.
+func (s *server) Ping(ctx context.Context, req *PingRequest) (*PingResponse, error) {
+	return &PingResponse{}, nil
+}
+
 func (s *server) ListCallbacks(ctx context.Context, req *ListCallbackRequest) (*ListCallbackResponse, error) {
 	var cb []string
 
@@ -306,6 +378,15 @@ func (s *server) ListCallbacks(ctx context.Context, req *ListCallbackRequest) (*
 		cb = append(cb, "PipeCreateError")
 	}
 
+	// sshpiperd refuses callbacks it does not know
+	if s.config.PipeEndCallback != nil && s.Daemon().HasFeature(FeaturePipeEnd) {
+		cb = append(cb, "PipeEnd")
+	}
+
+	if s.config.AuthorizeChannelCallback != nil && s.Daemon().HasFeature(FeatureAuthorizeChannel) {
+		cb = append(cb, "AuthorizeChannel")
+	}
+
 	return &ListCallbackResponse{
 		Callbacks: cb,
 	}, nil
@@ -316,7 +397,7 @@ func (s *server) NewConnection(ctx context.Context, req *NewConnectionRequest) (
 		return nil, status.Errorf(codes.Unimplemented, "method NewConnection not implemented")
 	}
 
-	if err := s.config.NewConnectionCallback(req.Meta); err != nil {
+	if err := s.config.NewConnectionCallback(withConnContext(ctx, req.Meta)); err != nil {
 		return nil, err
 	}
 
@@ -328,7 +409,7 @@ func (s *server) NextAuthMethods(ctx context.Context, req *NextAuthMethodsReques
 		return nil, status.Errorf(codes.Unimplemented, "method NextAuthMethods not implemented")
 	}
 
-	methods, err := s.config.NextAuthMethodsCallback(req.Meta)
+	methods, err := s.config.NextAuthMethodsCallback(withConnContext(ctx, req.Meta))
 	if err != nil {
 		return nil, err
 	}
@@ -351,7 +432,7 @@ func (s *server) NoneAuth(ctx context.Context, req *NoneAuthRequest) (*NoneAuthR
 		return nil, status.Errorf(codes.Unimplemented, "method NoneAuth not implemented")
 	}
 
-	upstream, err := s.config.NoClientAuthCallback(req.Meta)
+	upstream, err := s.config.NoClientAuthCallback(withConnContext(ctx, req.Meta))
 	if err != nil {
 		return nil, err
 	}
@@ -366,7 +447,7 @@ func (s *server) PasswordAuth(ctx context.Context, req *PasswordAuthRequest) (*P
 		return nil, status.Errorf(codes.Unimplemented, "method PasswordAuth not implemented")
 	}
 
-	upstream, err := s.config.PasswordCallback(req.Meta, req.Password)
+	upstream, err := s.config.PasswordCallback(withConnContext(ctx, req.Meta), req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -381,7 +462,7 @@ func (s *server) PublicKeyAuth(ctx context.Context, req *PublicKeyAuthRequest) (
 		return nil, status.Errorf(codes.Unimplemented, "method PublicKeyAuth not implemented")
 	}
 
-	upstream, err := s.config.PublicKeyCallback(req.Meta, req.PublicKey)
+	upstream, err := s.config.PublicKeyCallback(withConnContext(ctx, req.Meta), req.PublicKey)
 	if err != nil {
 		return nil, err
 	}
@@ -412,7 +493,7 @@ func (s *server) KeyboardInteractiveAuth(stream SshPiperPlugin_KeyboardInteracti
 		return status.Errorf(codes.InvalidArgument, "missing meta")
 	}
 
-	upstream, err := s.config.KeyboardInteractiveCallback(meta.Meta, func(user, instruction string, question string, echo bool) (answer string, err error) {
+	upstream, err := s.config.KeyboardInteractiveCallback(withConnContext(stream.Context(), meta.Meta), func(user, instruction string, question string, echo bool) (answer string, err error) {
 		var questions []*KeyboardInteractivePromptRequest_Question
 		if question != "" {
 			questions = append(questions, &KeyboardInteractivePromptRequest_Question{
@@ -485,7 +566,7 @@ func (s *server) UpstreamAuthFailureNotice(ctx context.Context, req *UpstreamAut
 		methods = append(methods, m)
 	}
 
-	s.config.UpstreamAuthFailureCallback(req.Meta, req.Method, fmt.Errorf("%v", req.Error), methods)
+	s.config.UpstreamAuthFailureCallback(withConnContext(ctx, req.Meta), req.Method, fmt.Errorf("%v", req.Error), methods)
 
 	return &UpstreamAuthFailureNoticeResponse{}, nil
 }
@@ -495,7 +576,7 @@ func (s *server) Banner(ctx context.Context, req *BannerRequest) (*BannerRespons
 		return nil, status.Errorf(codes.Unimplemented, "method Banner not implemented")
 	}
 
-	msg := s.config.BannerCallback(req.Meta)
+	msg := s.config.BannerCallback(withConnContext(ctx, req.Meta))
 
 	return &BannerResponse{
 		Message: msg,
@@ -507,7 +588,7 @@ func (s *server) VerifyHostKey(ctx context.Context, req *VerifyHostKeyRequest) (
 		return nil, status.Errorf(codes.Unimplemented, "method VerifyHostKey not implemented")
 	}
 
-	err := s.config.VerifyHostKeyCallback(req.Meta, req.Hostname, req.Netaddress, req.Key)
+	err := s.config.VerifyHostKeyCallback(withConnContext(ctx, req.Meta), req.Hostname, req.Netaddress, req.Key)
 	if err != nil {
 		return nil, err
 	}
@@ -522,7 +603,7 @@ func (s *server) PipeStartNotice(ctx context.Context, req *PipeStartNoticeReques
 		return nil, status.Errorf(codes.Unimplemented, "method PipeStartNotice not implemented")
 	}
 
-	s.config.PipeStartCallback(req.Meta)
+	s.config.PipeStartCallback(withConnContext(ctx, req.Meta))
 
 	return &PipeStartNoticeResponse{}, nil
 }
@@ -532,11 +613,38 @@ func (s *server) PipeErrorNotice(ctx context.Context, req *PipeErrorNoticeReques
 		return nil, status.Errorf(codes.Unimplemented, "method PipeErrorNotice not implemented")
 	}
 
-	s.config.PipeErrorCallback(req.Meta, fmt.Errorf("%v", req.Error))
+	s.config.PipeErrorCallback(withConnContext(ctx, req.Meta), fmt.Errorf("%v", req.Error))
 
 	return &PipeErrorNoticeResponse{}, nil
 }
 
+func (s *server) PipeEndNotice(ctx context.Context, req *PipeEndNoticeRequest) (*PipeEndNoticeResponse, error) {
+	if s.config.PipeEndCallback == nil {
+		return nil, status.Errorf(codes.Unimplemented, "method PipeEndNotice not implemented")
+	}
+
+	s.config.PipeEndCallback(withConnContext(ctx, req.Meta), req.GetStats())
+
+	return &PipeEndNoticeResponse{}, nil
+}
+
+func (s *server) AuthorizeChannel(ctx context.Context, req *AuthorizeChannelRequest) (*AuthorizeChannelResponse, error) {
+	if s.config.AuthorizeChannelCallback == nil {
+		return nil, status.Errorf(codes.Unimplemented, "method AuthorizeChannel not implemented")
+	}
+
+	resp, err := s.config.AuthorizeChannelCallback(withConnContext(ctx, req.Meta), req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil {
+		resp = &AuthorizeChannelResponse{}
+	}
+
+	return resp, nil
+}
+
 func (s *server) PipeCreateErrorNotice(ctx context.Context, req *PipeCreateErrorNoticeRequest) (*PipeCreateErrorNoticeResponse, error) {
 	if s.config.PipeCreateErrorCallback == nil {
 		return nil, status.Errorf(codes.Unimplemented, "method PipeCreateErrorNotice not implemented")
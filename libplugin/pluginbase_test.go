@@ -1,9 +1,17 @@
 package libplugin
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestNewFromStdioRedirectsStdoutToLogger(t *testing.T) {
@@ -78,3 +86,150 @@ func TestNewFromStdioRedirectsStdoutToLogger(t *testing.T) {
 		t.Fatalf("failed to close listener: %v", err)
 	}
 }
+
+func TestServerHandshake(t *testing.T) {
+	var daemon DaemonInfo
+	s := &server{config: SshPiperPluginConfig{
+		Name:             "fixed",
+		Version:          "v1.2.3",
+		RequiredFeatures: []string{FeatureUpstreamEnv},
+		HandshakeCallback: func(d DaemonInfo) error {
+			daemon = d
+			if d.Version == "v0" {
+				return errors.New("sshpiperd v0 is too old")
+			}
+			return nil
+		},
+	}}
+
+	req := &HandshakeRequest{ProtocolVersion: ProtocolVersion, Version: "v9", Features: Features}
+	resp, err := s.Handshake(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.GetProtocolVersion() != ProtocolVersion || resp.GetName() != "fixed" || resp.GetVersion() != "v1.2.3" {
+		t.Errorf("unexpected handshake response %v", resp)
+	}
+	if !slices.Contains(resp.GetFeatures(), FeaturePing) {
+		t.Errorf("expected %v in features %v", FeaturePing, resp.GetFeatures())
+	}
+	if !slices.Equal(resp.GetRequiredFeatures(), []string{FeatureUpstreamEnv}) {
+		t.Errorf("required features = %v", resp.GetRequiredFeatures())
+	}
+	if daemon.Version != "v9" || !daemon.HasFeature(FeatureUpstreamEnv) {
+		t.Errorf("callback got daemon %+v", daemon)
+	}
+	if got := s.Daemon(); got.Version != "v9" || got.ProtocolVersion != ProtocolVersion {
+		t.Errorf("Daemon() = %+v", got)
+	}
+
+	for _, tt := range []struct {
+		name string
+		req  *HandshakeRequest
+		want string
+	}{
+		{name: "missing required feature", req: &HandshakeRequest{ProtocolVersion: ProtocolVersion, Version: "v8"}, want: "does not support features [upstream-env]"},
+		{name: "refused by callback", req: &HandshakeRequest{ProtocolVersion: ProtocolVersion, Version: "v0", Features: Features}, want: "sshpiperd v0 is too old"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Handshake(context.Background(), tt.req)
+			if status.Code(err) != codes.FailedPrecondition || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Handshake error = %v, want FailedPrecondition with %q", err, tt.want)
+			}
+		})
+	}
+	if got := s.Daemon(); got.Version != "v9" {
+		t.Errorf("refused handshakes must not replace the daemon, got %+v", got)
+	}
+
+	s = &server{}
+	resp, err = s.Handshake(context.Background(), &HandshakeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetName() != filepath.Base(os.Args[0]) {
+		t.Errorf("name = %v, want the executable name", resp.GetName())
+	}
+}
+
+func TestServerPipeEnd(t *testing.T) {
+	var got *PipeStats
+	var user string
+	s := &server{config: SshPiperPluginConfig{
+		PipeEndCallback: func(conn ConnMetadata, stats *PipeStats) {
+			user = conn.User()
+			got = stats
+		},
+	}}
+
+	listed := func() bool {
+		resp, err := s.ListCallbacks(context.Background(), &ListCallbackRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.Contains(resp.GetCallbacks(), "PipeEnd")
+	}
+
+	if listed() {
+		t.Error("PipeEnd must not be listed to a sshpiperd without the pipe-end feature")
+	}
+
+	if _, err := s.Handshake(context.Background(), &HandshakeRequest{ProtocolVersion: ProtocolVersion, Features: Features}); err != nil {
+		t.Fatal(err)
+	}
+	if !listed() {
+		t.Error("expected PipeEnd to be listed")
+	}
+
+	stats := &PipeStats{DownstreamBytes: 3, ExecCommands: []string{"ls"}, ExitStatuses: []uint32{0}}
+	if _, err := s.PipeEndNotice(context.Background(), &PipeEndNoticeRequest{Meta: &ConnMeta{UserName: "user"}, Stats: stats}); err != nil {
+		t.Fatal(err)
+	}
+	if user != "user" || got != stats {
+		t.Errorf("callback got %v, %v", user, got)
+	}
+}
+
+func TestServerAuthorizeChannel(t *testing.T) {
+	s := &server{config: SshPiperPluginConfig{
+		AuthorizeChannelCallback: func(conn ConnMetadata, req *AuthorizeChannelRequest) (*AuthorizeChannelResponse, error) {
+			switch req.GetCommand() {
+			case "rm -rf /":
+				return nil, errors.New("not allowed")
+			case "bash":
+				return &AuthorizeChannelResponse{Decision: ChannelDecision_CHANNEL_REWRITE, Command: "bash --restricted"}, nil
+			}
+			return nil, nil
+		},
+	}}
+
+	if _, err := s.Handshake(context.Background(), &HandshakeRequest{ProtocolVersion: ProtocolVersion, Features: Features}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.ListCallbacks(context.Background(), &ListCallbackRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(resp.GetCallbacks(), "AuthorizeChannel") {
+		t.Errorf("expected AuthorizeChannel in %v", resp.GetCallbacks())
+	}
+
+	for _, tt := range []struct {
+		command string
+		want    ChannelDecision
+		wantErr bool
+	}{
+		{command: "ls", want: ChannelDecision_CHANNEL_ALLOW},
+		{command: "bash", want: ChannelDecision_CHANNEL_REWRITE},
+		{command: "rm -rf /", wantErr: true},
+	} {
+		resp, err := s.AuthorizeChannel(context.Background(), &AuthorizeChannelRequest{Meta: &ConnMeta{}, Request: "exec", Command: tt.command})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%v: err = %v", tt.command, err)
+		}
+		if err == nil && resp.GetDecision() != tt.want {
+			t.Errorf("%v: decision = %v, want %v", tt.command, resp.GetDecision(), tt.want)
+		}
+	}
+}
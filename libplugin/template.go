@@ -38,6 +38,10 @@ func CreateAndRunPluginTemplate(t *PluginTemplate) {
 				return err
 			}
 
+			if config.Name == "" {
+				config.Name = t.Name
+			}
+
 			p, err := NewFromStdio(*config)
 			if err != nil {
 				return err
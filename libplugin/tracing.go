@@ -0,0 +1,185 @@
+package libplugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// tracePropagator reads the W3C trace context sshpiperd sends along with
+// every call, independent of the propagator installed in the plugin.
+var tracePropagator = propagation.TraceContext{}
+
+func startServerSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = tracePropagator.Extract(ctx, metadataCarrier(md))
+
+	return otel.Tracer("github.com/tg123/sshpiper/libplugin").Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+func endServerSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceUnaryServerInterceptor continues the trace of the sshpiperd
+// connection with a span per plugin call. Spans are exported by the tracer
+// provider installed with otel.SetTracerProvider, see SetupTracingFromEnv.
+// NewFromStdio installs it, pass TraceServerOptions to the grpc.Server
+// given to NewFromGrpc.
+func TraceUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, span := startServerSpan(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		endServerSpan(span, err)
+		return resp, err
+	}
+}
+
+// TraceStreamServerInterceptor is the streaming counterpart of
+// TraceUnaryServerInterceptor, used by KeyboardInteractiveAuth.
+func TraceStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startServerSpan(ss.Context(), info.FullMethod)
+		err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+		endServerSpan(span, err)
+		return err
+	}
+}
+
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataCarrier adapts grpc metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// TraceServerOptions returns the interceptors tracing the plugin calls, for
+// the grpc.Server passed to NewFromGrpc. NewFromStdio uses them already.
+func TraceServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(TraceUnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(TraceStreamServerInterceptor()),
+	}
+}
+
+// SetupTracingFromEnv installs the global tracer provider when tracing is
+// enabled by the standard OpenTelemetry variables: OTEL_TRACES_EXPORTER
+// set to otlp or console, or an OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT given. The OTLP exporter speaks grpc
+// and the console exporter writes to stderr, stdout being the transport of
+// the plugin. OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES and
+// OTEL_TRACES_SAMPLER are honored, the service name defaults to name.
+//
+// NewFromStdio calls it with the name of the plugin and Serve calls
+// shutdown once the plugin stops. shutdown flushes the pending spans.
+func SetupTracingFromEnv(name string) (shutdown func(context.Context) error, err error) {
+	return setupTracingFromEnv(name, os.Stderr)
+}
+
+func setupTracingFromEnv(name string, console io.Writer) (shutdown func(context.Context) error, err error) {
+	ctx := context.Background()
+
+	exporterName := strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER"))
+	if exporterName == "" && (os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "") {
+		exporterName = "otlp"
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch exporterName {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracegrpc.New(ctx)
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(console))
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q, allowed: otlp, console, none", exporterName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(name)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// connMetaContext is the ConnMetadata passed to the callbacks, with the
+// context of the plugin call, see ConnContext.
+type connMetaContext struct {
+	*ConnMeta
+	ctx context.Context
+}
+
+func withConnContext(ctx context.Context, meta *ConnMeta) ConnMetadata {
+	return &connMetaContext{ConnMeta: meta, ctx: ctx}
+}
+
+// ConnContext returns the context of the plugin call conn was passed to a
+// callback in. It carries the span of the call, callbacks start child spans
+// from it, e.g. around a lookup in a database.
+func ConnContext(conn ConnMetadata) context.Context {
+	if c, ok := conn.(*connMetaContext); ok {
+		return c.ctx
+	}
+
+	return context.Background()
+}
@@ -0,0 +1,129 @@
+package libplugin
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTraceUnaryServerInterceptorContinuesTrace(t *testing.T) {
+	const traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", traceparent))
+
+	var got trace.SpanContext
+	_, err := TraceUnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/libplugin.SshPiperPlugin/PasswordAuth"}, func(ctx context.Context, req any) (any, error) {
+		got = trace.SpanContextFromContext(ctx)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("trace id = %v, want the trace of sshpiperd", got.TraceID())
+	}
+}
+
+func TestTraceUnaryServerInterceptorWithoutTrace(t *testing.T) {
+	var got trace.SpanContext
+	_, err := TraceUnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/libplugin.SshPiperPlugin/NoneAuth"}, func(ctx context.Context, req any) (any, error) {
+		got = trace.SpanContextFromContext(ctx)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.IsValid() {
+		t.Errorf("expected no trace, got %v", got.TraceID())
+	}
+}
+
+func TestSetupTracingFromEnvExportsPluginSpans(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "console")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var exported bytes.Buffer
+	shutdown, err := setupTracingFromEnv("test-plugin", &exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer(TraceServerOptions()...)
+	p, err := NewFromGrpc(SshPiperPluginConfig{
+		PasswordCallback: func(conn ConnMetadata, password []byte) (*Upstream, error) {
+			_, span := otel.Tracer("test").Start(ConnContext(conn), "lookup user")
+			span.End()
+			return &Upstream{}, nil
+		},
+	}, s, lis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = p.Serve() }()
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	const traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", traceparent)
+	if _, err := NewSshPiperPluginClient(conn).PasswordAuth(ctx, &PasswordAuthRequest{Meta: &ConnMeta{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"Name":"/libplugin.SshPiperPlugin/PasswordAuth"`, `"Name":"lookup user"`, "0af7651916cd43dd8448eb211c80319c", "test-plugin"} {
+		if !strings.Contains(exported.String(), want) {
+			t.Errorf("expected %s in exported spans, got %s", want, exported.String())
+		}
+	}
+}
+
+func TestSetupTracingFromEnvDisabled(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	previous := otel.GetTracerProvider()
+	if _, err := SetupTracingFromEnv("test-plugin"); err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != previous {
+		t.Error("expected no tracer provider without OTEL_* variables")
+	}
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+	if _, err := SetupTracingFromEnv("test-plugin"); err == nil {
+		t.Error("expected error for unsupported exporter")
+	}
+}
+
+func TestConnContextWithoutCall(t *testing.T) {
+	if ConnContext(&ConnMeta{}) != context.Background() {
+		t.Error("expected the background context for a plain ConnMeta")
+	}
+}
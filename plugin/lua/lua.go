@@ -76,6 +76,7 @@ func (p *luaPlugin) CreateConfig() (*libplugin.SshPiperPluginConfig, error) {
 		{"sshpiper_on_pipe_create_error", func() { config.PipeCreateErrorCallback = p.handlePipeCreateError }},
 		{"sshpiper_on_pipe_start", func() { config.PipeStartCallback = p.handlePipeStart }},
 		{"sshpiper_on_pipe_error", func() { config.PipeErrorCallback = p.handlePipeError }},
+		{"sshpiper_on_authorize_channel", func() { config.AuthorizeChannelCallback = p.handleAuthorizeChannel }},
 	}
 
 	hasAnyCallback := false
@@ -544,36 +545,19 @@ func (p *luaPlugin) parseUpstreamTable(L *lua.LState, value lua.LValue, conn lib
 	// sent as an SSH "env" channel-request after the upstream confirms
 	// the channel open. The upstream sshd must accept the variable via
 	// AcceptEnv for it to take effect.
-	if envVal := L.GetField(table, "env"); envVal != lua.LNil {
-		envTbl, ok := envVal.(*lua.LTable)
-		if !ok {
-			return nil, fmt.Errorf("env must be a table")
-		}
-		env := make(map[string]string)
-		var perr error
-		envTbl.ForEach(func(k, v lua.LValue) {
-			if perr != nil {
-				return
-			}
-			ks, ok := k.(lua.LString)
-			if !ok {
-				perr = fmt.Errorf("env keys must be strings, got %s", k.Type())
-				return
-			}
-			vs, ok := v.(lua.LString)
-			if !ok {
-				perr = fmt.Errorf("env value for %q must be a string, got %s", string(ks), v.Type())
-				return
-			}
-			env[string(ks)] = string(vs)
-		})
-		if perr != nil {
-			return nil, perr
-		}
-		if len(env) > 0 {
-			upstream.Env = env
-		}
+	env, err := stringMapField(L, table, "env")
+	if err != nil {
+		return nil, err
 	}
+	upstream.Env = env
+
+	// Optional labels: map of labels of the session, e.g. ticket or team,
+	// shown by the sshpiperd admin API and written into the recordings.
+	labels, err := stringMapField(L, table, "labels")
+	if err != nil {
+		return nil, err
+	}
+	upstream.Labels = labels
 
 	// Handle authentication
 	privateKeyDataVal := L.GetField(table, "private_key_data")
@@ -913,3 +897,101 @@ func (p *luaPlugin) handlePipeError(conn libplugin.ConnMetadata, callbackErr err
 		slog.Error("lua error in sshpiper_on_pipe_error", "error", err)
 	}
 }
+
+func (p *luaPlugin) handleAuthorizeChannel(conn libplugin.ConnMetadata, req *libplugin.AuthorizeChannelRequest) (*libplugin.AuthorizeChannelResponse, error) {
+	L, err := p.getLuaState()
+	if err != nil {
+		return nil, err
+	}
+	defer p.putLuaState(L)
+
+	connTable := p.createConnTable(L, conn)
+
+	reqTable := L.NewTable()
+	L.SetField(reqTable, "request", lua.LString(req.GetRequest()))
+	L.SetField(reqTable, "channel_type", lua.LString(req.GetChannelType()))
+	L.SetField(reqTable, "command", lua.LString(req.GetCommand()))
+	L.SetField(reqTable, "subsystem", lua.LString(req.GetSubsystem()))
+	L.SetField(reqTable, "term", lua.LString(req.GetTerm()))
+	L.SetField(reqTable, "env_name", lua.LString(req.GetEnvName()))
+	L.SetField(reqTable, "env_value", lua.LString(req.GetEnvValue()))
+	L.SetField(reqTable, "host", lua.LString(req.GetHost()))
+	L.SetField(reqTable, "port", lua.LNumber(req.GetPort()))
+	L.SetField(reqTable, "socket_path", lua.LString(req.GetSocketPath()))
+
+	fn := L.GetGlobal("sshpiper_on_authorize_channel")
+	if fn == lua.LNil {
+		return nil, fmt.Errorf("sshpiper_on_authorize_channel function not defined in Lua script")
+	}
+
+	if err := L.CallByParam(lua.P{
+		Fn:      fn,
+		NRet:    1,
+		Protect: true,
+	}, connTable, reqTable); err != nil {
+		return nil, fmt.Errorf("lua error in sshpiper_on_authorize_channel: %w", err)
+	}
+
+	result := L.Get(-1)
+	L.Pop(1)
+
+	switch v := result.(type) {
+	case *lua.LNilType:
+		return &libplugin.AuthorizeChannelResponse{}, nil
+	case lua.LBool:
+		if bool(v) {
+			return &libplugin.AuthorizeChannelResponse{}, nil
+		}
+		return &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_DENY}, nil
+	case lua.LString:
+		return &libplugin.AuthorizeChannelResponse{Decision: libplugin.ChannelDecision_CHANNEL_DENY, Message: string(v)}, nil
+	case *lua.LTable:
+		return &libplugin.AuthorizeChannelResponse{
+			Decision:  libplugin.ChannelDecision_CHANNEL_REWRITE,
+			Command:   lua.LVAsString(v.RawGetString("command")),
+			Subsystem: lua.LVAsString(v.RawGetString("subsystem")),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("sshpiper_on_authorize_channel returned unexpected %v", result.Type())
+}
+
+// stringMapField returns the table of strings by strings in field name of
+// table, nil when it is unset or empty.
+func stringMapField(L *lua.LState, table *lua.LTable, name string) (map[string]string, error) {
+	val := L.GetField(table, name)
+	if val == lua.LNil {
+		return nil, nil
+	}
+
+	tbl, ok := val.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a table", name)
+	}
+
+	m := make(map[string]string)
+	var perr error
+	tbl.ForEach(func(k, v lua.LValue) {
+		if perr != nil {
+			return
+		}
+		ks, ok := k.(lua.LString)
+		if !ok {
+			perr = fmt.Errorf("%s keys must be strings, got %s", name, k.Type())
+			return
+		}
+		vs, ok := v.(lua.LString)
+		if !ok {
+			perr = fmt.Errorf("%s value for %q must be a string, got %s", name, string(ks), v.Type())
+			return
+		}
+		m[string(ks)] = string(vs)
+	})
+	if perr != nil {
+		return nil, perr
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/tg123/sshpiper/libplugin"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -472,6 +474,69 @@ end
 	}
 }
 
+func TestLuaPluginAuthorizeChannel(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "authorize.lua")
+
+	script := `
+function sshpiper_on_authorize_channel(conn, req)
+    if req.request == "exec" and req.command:match("^sudo ") then
+        return "sudo is not allowed"
+    end
+    if req.request == "shell" and conn.sshpiper_user == "backup" then
+        return { subsystem = "sftp" }
+    end
+    if req.request == "channel-open" and req.port == 22 then
+        return false
+    end
+    return true
+end
+`
+
+	if err := os.WriteFile(scriptPath, []byte(script), 0o644); err != nil {
+		t.Fatalf("Failed to create test script: %v", err)
+	}
+
+	plugin := &luaPlugin{
+		ScriptPath: scriptPath,
+	}
+
+	config, err := plugin.CreateConfig()
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	if config.AuthorizeChannelCallback == nil {
+		t.Fatal("AuthorizeChannelCallback not registered")
+	}
+
+	alice := &mockConnMetadata{username: "alice"}
+	backup := &mockConnMetadata{username: "backup"}
+
+	for _, tt := range []struct {
+		conn     libplugin.ConnMetadata
+		req      *libplugin.AuthorizeChannelRequest
+		decision libplugin.ChannelDecision
+		message  string
+		rewrite  string
+	}{
+		{conn: alice, req: &libplugin.AuthorizeChannelRequest{Request: "exec", Command: "ls"}},
+		{conn: alice, req: &libplugin.AuthorizeChannelRequest{Request: "exec", Command: "sudo ls"}, decision: libplugin.ChannelDecision_CHANNEL_DENY, message: "sudo is not allowed"},
+		{conn: alice, req: &libplugin.AuthorizeChannelRequest{Request: "channel-open", ChannelType: "direct-tcpip", Host: "db", Port: 22}, decision: libplugin.ChannelDecision_CHANNEL_DENY},
+		{conn: alice, req: &libplugin.AuthorizeChannelRequest{Request: "shell"}},
+		{conn: backup, req: &libplugin.AuthorizeChannelRequest{Request: "shell"}, decision: libplugin.ChannelDecision_CHANNEL_REWRITE, rewrite: "sftp"},
+	} {
+		resp, err := config.AuthorizeChannelCallback(tt.conn, tt.req)
+		if err != nil {
+			t.Fatalf("AuthorizeChannelCallback(%v) failed: %v", tt.req, err)
+		}
+
+		if resp.GetDecision() != tt.decision || resp.GetMessage() != tt.message || resp.GetSubsystem() != tt.rewrite {
+			t.Errorf("AuthorizeChannelCallback(%v) = %v", tt.req, resp)
+		}
+	}
+}
+
 func TestLuaPluginSearchPath(t *testing.T) {
 	tmpDir := t.TempDir()
 	moduleDir := filepath.Join(tmpDir, "modules")
@@ -705,6 +770,45 @@ end
 	}
 }
 
+func TestLuaPluginUpstreamLabels(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "test.lua")
+
+	script := `
+function sshpiper_on_password(conn, password)
+    return {
+        host = "localhost:2222",
+        labels = {
+            ticket = "INC-1",
+            team = "db",
+        },
+    }
+end
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o644); err != nil {
+		t.Fatalf("Failed to create test script: %v", err)
+	}
+
+	plugin := &luaPlugin{ScriptPath: scriptPath}
+	config, err := plugin.CreateConfig()
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	conn := &mockConnMetadata{username: "alice", uniqueID: "uid"}
+	upstream, err := config.PasswordCallback(conn, []byte("p"))
+	if err != nil {
+		t.Fatalf("PasswordCallback failed: %v", err)
+	}
+
+	if got, want := upstream.Labels, map[string]string{"ticket": "INC-1", "team": "db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels = %v, want %v", got, want)
+	}
+	if upstream.Env != nil {
+		t.Errorf("env = %v, want nil", upstream.Env)
+	}
+}
+
 func TestLuaPluginUpstreamEnvMustBeTable(t *testing.T) {
 	tmpDir := t.TempDir()
 	scriptPath := filepath.Join(tmpDir, "test.lua")
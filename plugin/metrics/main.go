@@ -45,13 +45,20 @@ func main() {
 				Value:    false,
 				EnvVars:  []string{"SSHPIPERD_METRICS_COLLECT_UPSTREAM_AUTH_FAILURES"},
 			},
+			&cli.BoolFlag{
+				Name:     "collect-pipe-stats",
+				Usage:    "Collect metrics on the duration and bytes of closed pipes",
+				Required: false,
+				Value:    false,
+				EnvVars:  []string{"SSHPIPERD_METRICS_COLLECT_PIPE_STATS"},
+			},
 		},
 		CreateConfig: func(c *cli.Context) (*libplugin.SshPiperPluginConfig, error) {
 			port := c.Int("port")
 			address := c.String("address")
 			bindAddress := fmt.Sprintf("%v:%v", address, port)
 			metrics, config := newPrometheusMetrics(
-				c.Bool("collect-pipe-create-errors"), c.Bool("collect-upstream-auth-failures"),
+				c.Bool("collect-pipe-create-errors"), c.Bool("collect-upstream-auth-failures"), c.Bool("collect-pipe-stats"),
 			)
 			go func(metrics *prometheusMetrics, bindAddress string) {
 				if err := metrics.ListenAndServe(bindAddress); err != nil {
@@ -64,7 +71,7 @@ func main() {
 	})
 }
 
-func newPrometheusMetrics(collectPipeCreateErrors, collectUpstreamAuthFailures bool) (*prometheusMetrics, *libplugin.SshPiperPluginConfig) {
+func newPrometheusMetrics(collectPipeCreateErrors, collectUpstreamAuthFailures, collectPipeStats bool) (*prometheusMetrics, *libplugin.SshPiperPluginConfig) {
 	registry := prometheus.NewRegistry()
 	openConnections := prometheus.NewGaugeVec(
 		// sshpiper_pipe_open_connections
@@ -116,6 +123,30 @@ func newPrometheusMetrics(collectPipeCreateErrors, collectUpstreamAuthFailures b
 		registry.MustRegister(metrics.upstreamAuthFailures)
 		config.UpstreamAuthFailureCallback = metrics.upstreamAuthFailureCallback
 	}
+	if collectPipeStats {
+		metrics.pipeDuration = prometheus.NewHistogram(
+			// sshpiper_pipe_duration_seconds
+			prometheus.HistogramOpts{
+				Namespace: "sshpiper",
+				Subsystem: "pipe",
+				Name:      "duration_seconds",
+				Help:      "Duration of closed pipes",
+				Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+			},
+		)
+		metrics.pipeBytes = prometheus.NewCounterVec(
+			// sshpiper_pipe_bytes
+			prometheus.CounterOpts{
+				Namespace: "sshpiper",
+				Subsystem: "pipe",
+				Name:      "bytes",
+				Help:      "Channel data bytes of closed pipes partitioned by the side that sent them, downstream or upstream",
+			},
+			[]string{"from"},
+		)
+		registry.MustRegister(metrics.pipeDuration, metrics.pipeBytes)
+		config.PipeEndCallback = metrics.pipeEndCallback
+	}
 	return metrics, config
 }
 
@@ -125,6 +156,8 @@ type prometheusMetrics struct {
 	openConnections      *prometheus.GaugeVec
 	pipeCreateErrors     *prometheus.CounterVec
 	upstreamAuthFailures *prometheus.CounterVec
+	pipeDuration         prometheus.Histogram
+	pipeBytes            *prometheus.CounterVec
 }
 
 func (ms *prometheusMetrics) ListenAndServe(addr string) error {
@@ -167,6 +200,12 @@ func (ms *prometheusMetrics) upstreamAuthFailureCallback(conn libplugin.ConnMeta
 	counter.Inc()
 }
 
+func (ms *prometheusMetrics) pipeEndCallback(_ libplugin.ConnMetadata, stats *libplugin.PipeStats) {
+	ms.pipeDuration.Observe(float64(stats.GetEndedAt()-stats.GetStartedAt()) / 1000)
+	ms.pipeBytes.WithLabelValues("downstream").Add(float64(stats.GetDownstreamBytes()))
+	ms.pipeBytes.WithLabelValues("upstream").Add(float64(stats.GetUpstreamBytes()))
+}
+
 type errorLogger struct{}
 
 func (l errorLogger) Println(v ...any) {